@@ -0,0 +1,230 @@
+// Package cloudrunprovider is the stable API for embedding Cloud Run service
+// discovery and Traefik configuration generation in other Go programs, such
+// as custom controllers or CI checks.
+//
+// Discover lists the Traefik-enabled services in the configured projects,
+// Build turns services into a Traefik dynamic configuration, and Generate
+// does both. Behaviour is configured with options:
+//
+//	config, err := cloudrunprovider.Generate(ctx,
+//		cloudrunprovider.WithProjects("my-project-stg", "my-home-stg"),
+//		cloudrunprovider.WithRegion("us-central1"),
+//	)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	data, err := cloudrunprovider.Marshal(config)
+//
+// Without WithCloudRunClient and WithTokenSource, the Cloud Run Admin API and
+// identity tokens are accessed with Application Default Credentials.
+package cloudrunprovider
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/internal/gcp"
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/internal/logging"
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/internal/redact"
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/provider"
+	run "google.golang.org/api/run/v1"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultRegion is the Cloud Run region used when WithRegion is not given
+const DefaultRegion = "us-central1"
+
+// Service is a discovered Cloud Run service and its labels
+type Service = provider.CloudRunService
+
+// DynamicConfig is the generated Traefik dynamic configuration
+type DynamicConfig = provider.DynamicConfig
+
+// CloudRunClient lists Cloud Run services; see WithCloudRunClient
+type CloudRunClient = provider.CloudRunClient
+
+// TokenSource provides identity tokens for service URLs; see WithTokenSource
+type TokenSource = provider.TokenSource
+
+// Option configures Discover, Build and Generate
+type Option func(*settings)
+
+// settings collects the options for one call
+type settings struct {
+	config    provider.Config
+	client    CloudRunClient
+	tokens    TokenSource
+	logOutput io.Writer
+}
+
+// WithConfig starts from a full provider configuration. Options given after
+// it override the corresponding fields.
+func WithConfig(config provider.Config) Option {
+	return func(s *settings) {
+		s.config = config
+	}
+}
+
+// WithProjects sets the GCP projects to discover services in
+func WithProjects(projectIDs ...string) Option {
+	return func(s *settings) {
+		s.config.ProjectIDs = projectIDs
+	}
+}
+
+// WithRegion sets the Cloud Run region (default us-central1)
+func WithRegion(region string) Option {
+	return func(s *settings) {
+		s.config.Region = region
+	}
+}
+
+// WithCloudRunClient replaces the Cloud Run Admin API client, e.g. with a fake in tests
+func WithCloudRunClient(client CloudRunClient) Option {
+	return func(s *settings) {
+		s.client = client
+	}
+}
+
+// WithTokenSource replaces the identity token source (the metadata server, or ADC in dev mode)
+func WithTokenSource(tokens TokenSource) Option {
+	return func(s *settings) {
+		s.tokens = tokens
+	}
+}
+
+// WithLogOutput sends provider logs to w. Logs are discarded by default.
+func WithLogOutput(w io.Writer) Option {
+	return func(s *settings) {
+		s.logOutput = w
+	}
+}
+
+// WithLogHandler sends provider logs to an slog.Handler, so they join the
+// caller's logs. logr users can pass logr.ToSlogHandler(logger).
+func WithLogHandler(handler slog.Handler) Option {
+	return func(s *settings) {
+		s.config.LogHandler = handler
+	}
+}
+
+// newSettings applies opts over the defaults
+func newSettings(opts []Option) *settings {
+	s := &settings{logOutput: io.Discard}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.config.Region == "" {
+		s.config.Region = DefaultRegion
+	}
+	return s
+}
+
+// newProvider creates a provider from the settings. A Cloud Run Admin API
+// client is only created when needClient is true and none was supplied.
+func (s *settings) newProvider(ctx context.Context, needClient bool) (*provider.Provider, error) {
+	client := s.client
+	if client == nil && needClient {
+		credentials, err := gcp.LoadCredentials(s.config.CredentialsFile, s.config.CredentialsJSON)
+		if err != nil {
+			return nil, err
+		}
+		runService, err := run.NewService(ctx, credentials.ClientOptions()...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Cloud Run service: %w", err)
+		}
+		client = provider.NewCloudRunClient(runService)
+	}
+
+	logLevel := logging.LevelInfo
+	if level := s.config.LogLevel; level != "" {
+		if parsed, err := logging.ParseLevel(level); err == nil {
+			logLevel = parsed
+		}
+	}
+	logger := logging.New(&logging.Config{
+		Level:   logLevel,
+		Format:  logging.FormatText,
+		Output:  s.logOutput,
+		Redact:  redact.Default.String,
+		Handler: s.config.LogHandler,
+	}).WithPrefix("CloudRunProvider")
+
+	config := s.config
+	return provider.NewWithClients(&config, client, s.tokens, logger)
+}
+
+// Discover lists the Traefik-enabled Cloud Run services in the configured
+// projects. If some projects can't be listed, the services of the others are
+// returned together with an error describing the failures.
+func Discover(ctx context.Context, opts ...Option) ([]Service, error) {
+	p, err := newSettings(opts).newProvider(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	return p.Discover()
+}
+
+// Build generates the Traefik dynamic configuration for services, e.g. from
+// Discover or built by hand. Nothing is listed, so no Cloud Run client is
+// needed; identity tokens are still fetched unless token injection is
+// "plugin". When no projects are configured, the services' projects are used.
+func Build(services []Service, opts ...Option) (*DynamicConfig, error) {
+	s := newSettings(opts)
+	if len(s.config.ProjectIDs) == 0 {
+		seen := make(map[string]bool)
+		for _, service := range services {
+			if service.ProjectID != "" && !seen[service.ProjectID] {
+				seen[service.ProjectID] = true
+				s.config.ProjectIDs = append(s.config.ProjectIDs, service.ProjectID)
+			}
+		}
+	}
+
+	p, err := s.newProvider(context.Background(), false)
+	if err != nil {
+		return nil, err
+	}
+	return p.Build(services)
+}
+
+// Generate discovers services and builds their configuration. Unlike the
+// provider's polling loop, it fails if any project can't be listed, so a
+// partial configuration is never returned.
+func Generate(ctx context.Context, opts ...Option) (*DynamicConfig, error) {
+	p, err := newSettings(opts).newProvider(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	services, err := p.Discover()
+	if err != nil {
+		return nil, err
+	}
+	return p.Build(services)
+}
+
+// Marshal encodes a configuration as YAML, the way the provider writes
+// routes.yml (without the header comments)
+func Marshal(config *DynamicConfig) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(config); err != nil {
+		return nil, fmt.Errorf("failed to encode YAML: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal parses a routes file written by Marshal, the provider, or by hand
+// in Traefik's file provider format. Files from older provider versions are
+// migrated first. Keys the provider doesn't model are dropped and returned
+// as warnings.
+func Unmarshal(data []byte) (*DynamicConfig, []string, error) {
+	return provider.ParseDynamicConfig(data)
+}
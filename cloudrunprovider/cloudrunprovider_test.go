@@ -0,0 +1,150 @@
+package cloudrunprovider
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	run "google.golang.org/api/run/v1"
+)
+
+// fakeClient serves canned services per parent, failing for parents in errs
+type fakeClient struct {
+	services map[string][]*run.Service
+	errs     map[string]error
+}
+
+func (c *fakeClient) ListServices(parent, _ string) (*run.ListServicesResponse, error) {
+	if err := c.errs[parent]; err != nil {
+		return nil, err
+	}
+	return &run.ListServicesResponse{Items: c.services[parent]}, nil
+}
+
+// fakeTokens returns a fixed token for every audience
+type fakeTokens struct{}
+
+func (fakeTokens) GetToken(string) (string, error) {
+	return "eyJfake", nil
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{
+		services: map[string][]*run.Service{
+			"projects/labs/locations/us-central1": {
+				{
+					Metadata: &run.ObjectMeta{Name: "lab1", Labels: map[string]string{
+						"traefik_enable":                 "true",
+						"traefik_http_routers_lab1_rule": "PathPrefix(`/lab1`)",
+					}},
+					Status: &run.ServiceStatus{Url: "https://lab1.run.app"},
+				},
+			},
+		},
+		errs: map[string]error{
+			"projects/broken/locations/us-central1": errors.New("permission denied"),
+		},
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	services, err := Discover(context.Background(),
+		WithProjects("labs"),
+		WithCloudRunClient(newFakeClient()),
+	)
+	if err != nil {
+		t.Fatalf("Discover failed: %v", err)
+	}
+	if len(services) != 1 || services[0].Name != "lab1" || services[0].ProjectID != "labs" {
+		t.Errorf("Expected lab1 in project labs, got %+v", services)
+	}
+}
+
+func TestDiscover_LogHandler(t *testing.T) {
+	var logs bytes.Buffer
+	_, err := Discover(context.Background(),
+		WithProjects("labs"),
+		WithCloudRunClient(newFakeClient()),
+		WithLogHandler(slog.NewJSONHandler(&logs, nil)),
+	)
+	if err != nil {
+		t.Fatalf("Discover failed: %v", err)
+	}
+	if !strings.Contains(logs.String(), `"component":"CloudRunProvider"`) {
+		t.Errorf("Expected provider logs in the handler, got %q", logs.String())
+	}
+}
+
+func TestDiscover_PartialFailure(t *testing.T) {
+	services, err := Discover(context.Background(),
+		WithProjects("labs", "broken"),
+		WithCloudRunClient(newFakeClient()),
+	)
+	if err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Errorf("Expected listing error for broken project, got: %v", err)
+	}
+	if len(services) != 1 {
+		t.Errorf("Expected services from the healthy project, got %+v", services)
+	}
+}
+
+func TestBuild(t *testing.T) {
+	config, err := Build([]Service{{
+		Name:      "lab1",
+		URL:       "https://lab1.run.app",
+		ProjectID: "labs",
+		Labels: map[string]string{
+			"traefik_enable":                 "true",
+			"traefik_http_routers_lab1_rule": "PathPrefix(`/lab1`)",
+		},
+	}}, WithTokenSource(fakeTokens{}))
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	if _, ok := config.HTTP.Routers["lab1"]; !ok {
+		t.Error("Expected lab1 router")
+	}
+	if _, ok := config.HTTP.Middlewares["lab1-auth"]; !ok {
+		t.Error("Expected lab1-auth middleware")
+	}
+}
+
+func TestGenerate_FailsOnDiscoveryError(t *testing.T) {
+	_, err := Generate(context.Background(),
+		WithProjects("labs", "broken"),
+		WithCloudRunClient(newFakeClient()),
+		WithTokenSource(fakeTokens{}),
+	)
+	if err == nil {
+		t.Fatal("Expected Generate to fail when a project can't be listed")
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	config, err := Generate(context.Background(),
+		WithProjects("labs"),
+		WithCloudRunClient(newFakeClient()),
+		WithTokenSource(fakeTokens{}),
+	)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	data, err := Marshal(config)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if !strings.Contains(string(data), "rule: PathPrefix(`/lab1`)") {
+		t.Errorf("Expected lab1 rule in YAML, got:\n%s", data)
+	}
+
+	parsed, warnings, err := Unmarshal(data)
+	if err != nil || len(warnings) > 0 {
+		t.Fatalf("Unmarshal failed: %v (warnings %v)", err, warnings)
+	}
+	if again, _ := Marshal(parsed); !bytes.Equal(again, data) {
+		t.Errorf("Marshal(Unmarshal(data)) changed the YAML:\n%s\nwant:\n%s", again, data)
+	}
+}
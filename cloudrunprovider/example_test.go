@@ -0,0 +1,51 @@
+package cloudrunprovider_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/cloudrunprovider"
+)
+
+// Generate the routes for two projects and write them where Traefik's file
+// provider watches
+func ExampleGenerate() {
+	config, err := cloudrunprovider.Generate(context.Background(),
+		cloudrunprovider.WithProjects("my-project-stg", "my-home-stg"),
+		cloudrunprovider.WithRegion("us-central1"),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	data, err := cloudrunprovider.Marshal(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile("/etc/traefik/dynamic/routes.yml", data, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Fail a CI check when a Traefik-enabled service defines no routers
+func ExampleDiscover() {
+	services, err := cloudrunprovider.Discover(context.Background(),
+		cloudrunprovider.WithProjects("my-project-stg"),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, service := range services {
+		config, err := cloudrunprovider.Build([]cloudrunprovider.Service{service})
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(config.HTTP.Services) == 0 {
+			fmt.Printf("%s has traefik_enable=true but no router labels\n", service.Name)
+			os.Exit(1)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+// Command plugin-catalog generates the .traefik.yml manifest the Traefik
+// Plugin Catalog reads and validates it against the plugin configuration.
+//
+// Usage:
+//
+//	go run ./cmd/plugin-catalog [-root .] [-check]
+//	go run ./cmd/plugin-catalog -wasm bin/wasm
+//
+// With -check the manifest is not written; the command fails if the
+// committed manifest differs from the generated one (for CI). With -wasm the
+// manifest of the Wasm token middleware is written next to the plugin.wasm
+// built into that directory instead.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/internal/catalog"
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/plugin"
+)
+
+func main() {
+	root := flag.String("root", ".", "module root directory")
+	check := flag.Bool("check", false, "fail if the manifest is out of date instead of writing it")
+	wasmDir := flag.String("wasm", "", "write the Wasm token middleware manifest into this directory")
+	flag.Parse()
+
+	var err error
+	if *wasmDir != "" {
+		err = runWasm(*wasmDir)
+	} else {
+		err = run(*root, *check)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(root string, check bool) error {
+	goMod, err := os.ReadFile(filepath.Join(root, "go.mod"))
+	if err != nil {
+		return fmt.Errorf("failed to read go.mod: %w", err)
+	}
+	modulePath, err := catalog.ModulePath(goMod)
+	if err != nil {
+		return err
+	}
+
+	manifest := catalog.PluginManifest(modulePath)
+	if err := catalog.Validate(manifest, root, modulePath, plugin.CreateConfig()); err != nil {
+		return fmt.Errorf("invalid manifest:\n%w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := catalog.Write(&buf, manifest); err != nil {
+		return err
+	}
+
+	manifestPath := filepath.Join(root, catalog.ManifestFile)
+	if check {
+		current, err := os.ReadFile(manifestPath)
+		if err != nil {
+			return fmt.Errorf("failed to read manifest: %w", err)
+		}
+		if !bytes.Equal(current, buf.Bytes()) {
+			return fmt.Errorf("%s is out of date; run 'make plugin-package'", manifestPath)
+		}
+		fmt.Fprintf(os.Stderr, "✅ %s is up to date\n", manifestPath)
+		return nil
+	}
+
+	if err := os.WriteFile(manifestPath, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "✅ Wrote %s (import %s)\n", manifestPath, manifest.Import)
+	return nil
+}
+
+func runWasm(dir string) error {
+	manifest := catalog.TokenMiddlewareWasmManifest()
+	if err := catalog.Validate(manifest, dir, "", nil); err != nil {
+		return fmt.Errorf("invalid manifest:\n%w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := catalog.Write(&buf, manifest); err != nil {
+		return err
+	}
+	manifestPath := filepath.Join(dir, catalog.ManifestFile)
+	if err := os.WriteFile(manifestPath, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "✅ Wrote %s\n", manifestPath)
+	return nil
+}
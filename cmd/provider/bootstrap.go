@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/provider"
+)
+
+// bootstrapCommand is the subcommand that prints a Traefik static config snippet
+const bootstrapCommand = "bootstrap"
+
+// defaultListenPort is the port Cloud Run sends traffic to unless PORT is set
+const defaultListenPort = "8080"
+
+// googleFrontEndRanges are the source ranges of Google's front ends and load
+// balancer proxies, which sit in front of Cloud Run and set X-Forwarded-For
+var googleFrontEndRanges = []string{
+	"35.191.0.0/16",
+	"130.211.0.0/22",
+}
+
+// loopbackRanges are trusted so forwardAuth requests Traefik sends to itself
+// (see AddUserAuthMiddleware) keep their X-Forwarded-* headers
+var loopbackRanges = []string{
+	"127.0.0.1/8",
+	"::1",
+}
+
+// bootstrapData is the input to staticConfigTemplate
+type bootstrapData struct {
+	Port            string
+	DynamicDir      string
+	TrustedIPs      []string
+	TokenPlugin     bool
+	TokenPluginName string
+}
+
+// staticConfigTemplate renders a Traefik static configuration matching what
+// the generated dynamic configuration assumes
+var staticConfigTemplate = template.Must(template.New("static").Parse(`# Traefik static configuration generated by traefik-cloudrun-provider bootstrap
+#
+# Matches the dynamic configuration the provider writes:
+# - generated routers use the "web" entry point
+# - routes.yml is read by the file provider from the provider's output directory
+# - the api@internal routers (DASHBOARD_ENABLED=true) need the API enabled
+
+entryPoints:
+  web:
+    # Cloud Run terminates TLS and sends plain HTTP to this port
+    address: "0.0.0.0:{{ .Port }}"
+    forwardedHeaders:
+      # Loopback keeps X-Forwarded-Uri on forwardAuth requests Traefik sends to itself;
+      # Google ranges preserve client IPs set by Cloud Run's ingress
+      # (TRUSTED_IPS_FETCH=true uses the published netblocks instead of the built-in list)
+      trustedIPs:
+{{- range .TrustedIPs }}
+        - "{{ . }}"
+{{- end }}
+
+providers:
+  file:
+    directory: {{ .DynamicDir }}
+    watch: true
+
+api:
+  dashboard: true
+  insecure: false
+{{- if .TokenPlugin }}
+
+# Token middleware plugin used by TOKEN_INJECTION=plugin and REQUEST_ID_ENABLED
+experimental:
+  localPlugins:
+    {{ .TokenPluginName }}:
+      moduleName: github.com/pci-tamper-protect/traefik-cloudrun-provider/middleware
+{{- end }}
+
+log:
+  level: INFO
+  format: json
+
+accessLog:
+  format: json
+`))
+
+// runBootstrap prints a Traefik static configuration snippet for this
+// deployment to stdout. Returns the process exit code.
+func runBootstrap(config *AppConfig) int {
+	if err := writeStaticConfig(os.Stdout, config); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to generate static configuration: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// writeStaticConfig renders the static configuration for config to w
+func writeStaticConfig(w io.Writer, config *AppConfig) error {
+	port := strings.TrimSpace(os.Getenv("PORT"))
+	if port == "" {
+		port = defaultListenPort
+	}
+
+	pluginName := config.TokenPluginName
+	if pluginName == "" {
+		pluginName = provider.DefaultTokenPluginName
+	}
+
+	return staticConfigTemplate.Execute(w, bootstrapData{
+		Port:            port,
+		DynamicDir:      getDir(config.OutputFile),
+		TrustedIPs:      trustedIPs(newRangesFetcher(config)),
+		TokenPlugin:     config.TokenInjection == provider.TokenInjectionPlugin || config.RequestIDEnabled,
+		TokenPluginName: pluginName,
+	})
+}
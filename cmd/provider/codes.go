@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/internal/logging"
+)
+
+// codesCommand is the subcommand that lists the PLUGIN_* log codes
+const codesCommand = "codes"
+
+// runCodes prints every PLUGIN_* code logged in the code field, with its
+// description and the suggested operator action, as a table or, with
+// --json, as a JSON array to build alert rules from. It needs no
+// configuration. Returns the process exit code.
+func runCodes(args []string) int {
+	asJSON := false
+	for _, arg := range args {
+		switch arg {
+		case "--json", "-json":
+			asJSON = true
+		default:
+			fmt.Fprintf(os.Stderr, "❌ Unknown argument %q (usage: codes [--json])\n", arg)
+			return 2
+		}
+	}
+
+	codes := logging.Codes()
+	if asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(codes); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Failed to encode codes: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CODE\tSEVERITY\tDESCRIPTION")
+	for _, info := range codes {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", info.Code, info.Severity, info.Description)
+		if info.Action != "" {
+			fmt.Fprintf(w, "\t\t→ %s\n", info.Action)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to write codes: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/provider"
+	"gopkg.in/yaml.v3"
+)
+
+// FileConfig is the optional provider config file (CONFIG_FILE).
+// Fields left empty keep the value loaded from the environment.
+// In daemon mode the file is watched and changes are applied without a restart.
+type FileConfig struct {
+	ProjectIDs         []string `yaml:"projectIDs,omitempty"`
+	Region             string   `yaml:"region,omitempty"`
+	PollInterval       string   `yaml:"pollInterval,omitempty"`
+	IncludeServices    []string `yaml:"includeServices,omitempty"`
+	ExcludeServices    []string `yaml:"excludeServices,omitempty"`
+	DefaultMiddlewares []string `yaml:"defaultMiddlewares,omitempty"`
+
+	// Cloud Run for Anthos namespaces discovered alongside projectIDs
+	Anthos []provider.AnthosTarget `yaml:"anthos,omitempty"`
+
+	// Named auth providers selected with the traefik_auth_provider label
+	AuthProviders []provider.AuthProviderConfig `yaml:"authProviders,omitempty"`
+
+	// Labels applied to every service of a project, keyed by project ID
+	ProjectDefaults map[string]map[string]string `yaml:"projectDefaults,omitempty"`
+
+	// Entrypoints and TLS by rule type for routers without an entrypoints label
+	AutoEntryPoints bool                               `yaml:"autoEntryPoints,omitempty"`
+	EntryPointRules map[string]provider.EntryPointRule `yaml:"entryPointRules,omitempty"`
+
+	// Traefik API and dashboard routers; replaces the DASHBOARD_* settings
+	Dashboard *provider.DashboardConfig `yaml:"dashboard,omitempty"`
+
+	// Guardrails on the rules services may generate, by project and service
+	RulePolicies []provider.RulePolicy `yaml:"rulePolicies,omitempty"`
+}
+
+// loadFileConfig reads and parses the provider config file
+func loadFileConfig(path string) (*FileConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var fileConfig FileConfig
+	if err := yaml.Unmarshal(data, &fileConfig); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return &fileConfig, nil
+}
+
+// apply returns a copy of base with the file's settings layered on top
+func (f *FileConfig) apply(base *AppConfig) (*AppConfig, error) {
+	merged := *base
+
+	if len(f.ProjectIDs) > 0 {
+		merged.ProjectIDs = f.ProjectIDs
+	}
+	if f.Region != "" {
+		merged.Region = f.Region
+	}
+	if f.PollInterval != "" {
+		interval, err := time.ParseDuration(f.PollInterval)
+		if err != nil || interval <= 0 {
+			return nil, fmt.Errorf("invalid pollInterval %q", f.PollInterval)
+		}
+		merged.PollInterval = interval
+	}
+	if len(f.IncludeServices) > 0 {
+		merged.IncludeServices = f.IncludeServices
+	}
+	if len(f.ExcludeServices) > 0 {
+		merged.ExcludeServices = f.ExcludeServices
+	}
+	if len(f.DefaultMiddlewares) > 0 {
+		merged.DefaultMiddlewares = f.DefaultMiddlewares
+	}
+	if len(f.Anthos) > 0 {
+		merged.AnthosTargets = f.Anthos
+	}
+	if len(f.AuthProviders) > 0 {
+		merged.AuthProviders = f.AuthProviders
+	}
+	if len(f.ProjectDefaults) > 0 {
+		merged.ProjectDefaults = f.ProjectDefaults
+	}
+	if f.AutoEntryPoints {
+		merged.AutoEntryPoints = true
+	}
+	if len(f.EntryPointRules) > 0 {
+		merged.EntryPointRules = f.EntryPointRules
+	}
+	if f.Dashboard != nil {
+		merged.Dashboard = *f.Dashboard
+	}
+	if len(f.RulePolicies) > 0 {
+		merged.RulePolicies = f.RulePolicies
+	}
+
+	return &merged, nil
+}
+
+// configWatcher detects changes to the config file by modification time.
+// Polling the mtime on each daemon tick avoids a file-notification dependency.
+type configWatcher struct {
+	path    string
+	modTime time.Time
+}
+
+// newConfigWatcher creates a watcher primed with the file's current mtime
+func newConfigWatcher(path string) *configWatcher {
+	w := &configWatcher{path: path}
+	if info, err := os.Stat(path); err == nil {
+		w.modTime = info.ModTime()
+	}
+	return w
+}
+
+// changed reports whether the file was modified since the last call
+func (w *configWatcher) changed() bool {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return false
+	}
+	if info.ModTime().Equal(w.modTime) {
+		return false
+	}
+	w.modTime = info.ModTime()
+	return true
+}
+
+// configDiff describes the settings that differ between two configs,
+// one "field: old -> new" entry per changed field
+func configDiff(old, updated *AppConfig) []string {
+	var diff []string
+
+	oldValue := reflect.ValueOf(*old)
+	newValue := reflect.ValueOf(*updated)
+	for i := 0; i < oldValue.NumField(); i++ {
+		a := oldValue.Field(i).Interface()
+		b := newValue.Field(i).Interface()
+		if !reflect.DeepEqual(a, b) {
+			diff = append(diff, fmt.Sprintf("%s: %v -> %v", oldValue.Type().Field(i).Name, a, b))
+		}
+	}
+	return diff
+}
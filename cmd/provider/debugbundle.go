@@ -0,0 +1,202 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/internal/redact"
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/provider"
+	"gopkg.in/yaml.v3"
+)
+
+// debugBundleCommand is the subcommand that collects a zip of diagnostics
+// to attach to support issues
+const debugBundleCommand = "debug-bundle"
+
+// secretSettingPattern matches settings whose values are credentials or
+// point at them and never go into a bundle, even redacted
+var secretSettingPattern = regexp.MustCompile(`(?i)(json|token|dsn|secret|password|users|webhook)$`)
+
+// runDebugBundle generates the routes once and writes a zip to
+// DEBUG_BUNDLE_FILE (default: cloudrun-provider-debug-<time>.zip) holding the
+// sanitized routes, the generation report, the logs of the run, a redacted
+// summary of the configuration and the API latencies. Nothing else is
+// written. Returns the process exit code.
+func runDebugBundle(config *AppConfig) int {
+	redactor, err := newRedactor(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return 1
+	}
+	bundleFile := os.Getenv("DEBUG_BUNDLE_FILE")
+	if bundleFile == "" {
+		bundleFile = fmt.Sprintf("cloudrun-provider-debug-%s.zip", time.Now().UTC().Format("20060102T150405Z"))
+	}
+
+	// Logs go to stderr as usual and into the bundle
+	var logs bytes.Buffer
+	logOutput := io.MultiWriter(os.Stderr, &logs)
+	log.SetOutput(logOutput)
+	defer log.SetOutput(os.Stderr)
+
+	providerConfig := newProviderConfig(config)
+	providerConfig.LogOutput = logOutput
+	p, err := provider.New(providerConfig)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to create provider: %v\n", err)
+		return 1
+	}
+	refreshTrustedIPs(config, newRangesFetcher(config))
+
+	// A failed generation is what bundles are usually for: keep going and
+	// bundle whatever was collected
+	var dynamicConfig *provider.DynamicConfig
+	configChan := make(chan *provider.DynamicConfig, 1)
+	if err := p.RunOnce(configChan); err != nil {
+		log.Printf("❌ Failed to generate config: %v", err)
+	} else {
+		select {
+		case dynamicConfig = <-configChan:
+		case <-time.After(60 * time.Second):
+			log.Printf("❌ Timeout waiting for configuration")
+		}
+	}
+
+	var routes bytes.Buffer
+	if dynamicConfig != nil {
+		fmt.Fprintf(&routes, "# Sanitized preview: tokens are truncated, this file won't work in Traefik\n")
+		writeHeader(&routes, dynamicConfig.Generation())
+		writeSkippedSummary(&routes, dynamicConfig.Skipped())
+		encoder := yaml.NewEncoder(&routes)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(dynamicConfig.Sanitized()); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Failed to encode YAML: %v\n", err)
+			return 1
+		}
+		_ = encoder.Close()
+	}
+	report, err := json.MarshalIndent(p.LastReport(), "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to encode report: %v\n", err)
+		return 1
+	}
+	environment, err := environmentSummary(config, redactor)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to summarize environment: %v\n", err)
+		return 1
+	}
+	stats := struct {
+		CloudRun []provider.APICallStats `json:"cloudRun"`
+		Tokens   any                     `json:"tokens,omitempty"`
+	}{CloudRun: p.APIStats()}
+	if tokenStats, ok := p.TokenStats(); ok {
+		stats.Tokens = tokenStats
+	}
+	apiStats, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to encode API stats: %v\n", err)
+		return 1
+	}
+
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{"routes.sanitized.yml", routes.Bytes()},
+		{"report.json", report},
+		{"logs.txt", []byte(redactor.String(logs.String()))},
+		{"environment.yml", environment},
+		{"api-stats.json", apiStats},
+	}
+	// The routes file currently served, to compare with this run
+	if current, err := os.ReadFile(config.OutputFile); err == nil {
+		files = append(files, struct {
+			name string
+			data []byte
+		}{"current-routes.yml", []byte(redactor.String(string(current)))})
+	}
+
+	var bundle bytes.Buffer
+	archive := zip.NewWriter(&bundle)
+	created := time.Now()
+	for _, file := range files {
+		w, err := archive.CreateHeader(&zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: created})
+		if err == nil {
+			_, err = w.Write(file.data)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Failed to write %s to the bundle: %v\n", file.name, err)
+			return 1
+		}
+	}
+	if err := archive.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to write the bundle: %v\n", err)
+		return 1
+	}
+	if err := os.WriteFile(bundleFile, bundle.Bytes(), 0600); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to write %s: %v\n", bundleFile, err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "📦 Debug bundle written to %s (%d files)\n", bundleFile, len(files))
+	return 0
+}
+
+// environmentSummary describes the build and the effective configuration.
+// Credential settings are replaced entirely; tokens and email addresses in
+// the others are redacted.
+func environmentSummary(config *AppConfig, redactor *redact.Policy) ([]byte, error) {
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	var settings map[string]any
+	if err := yaml.Unmarshal(data, &settings); err != nil {
+		return nil, err
+	}
+
+	summary := map[string]any{
+		"go":       runtime.Version(),
+		"platform": runtime.GOOS + "/" + runtime.GOARCH,
+		"config":   redactSettings(settings, redactor),
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		summary["version"] = info.Main.Version
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				summary["revision"] = setting.Value
+			}
+		}
+	}
+	return yaml.Marshal(summary)
+}
+
+// redactSettings walks a decoded configuration, redacting credential
+// settings by key and tokens and addresses in every other string
+func redactSettings(value any, redactor *redact.Policy) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			if secretSettingPattern.MatchString(key) && item != nil && item != "" {
+				v[key] = redact.Redacted
+				continue
+			}
+			v[key] = redactSettings(item, redactor)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = redactSettings(item, redactor)
+		}
+	case string:
+		return redactor.String(v)
+	}
+	return value
+}
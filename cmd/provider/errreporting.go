@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"runtime/debug"
+	"time"
+
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/internal/errreport"
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/internal/gcp"
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/internal/logging"
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/internal/redact"
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/provider"
+)
+
+// errorReportingGCP reports to Cloud Error Reporting (ERROR_REPORTING=gcp)
+const errorReportingGCP = "gcp"
+
+// errorReportTimeout bounds each report so a slow tracker can't hold up
+// generation
+const errorReportTimeout = 10 * time.Second
+
+// errorReporter sends generation failures, services that failed to
+// process and panics to the tracker configured by ERROR_REPORTING or
+// SENTRY_DSN. A nil errorReporter reports nothing.
+type errorReporter struct {
+	reporter errreport.Reporter
+	redactor *redact.Policy
+}
+
+// newRedactor returns the redaction policy of REDACT_HEADERS, REDACT_CLAIMS
+// and REDACT_EMAILS
+func newRedactor(config *AppConfig) (*redact.Policy, error) {
+	return redact.New(redact.Rules{Headers: config.RedactHeaders, Claims: config.RedactClaims, Emails: config.RedactEmails})
+}
+
+// newErrorReporter returns the configured error reporter, or nil when
+// neither ERROR_REPORTING nor SENTRY_DSN is set
+func newErrorReporter(config *AppConfig) (*errorReporter, error) {
+	redactor, err := newRedactor(config)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case config.ErrorReporting != "" && config.ErrorReporting != errorReportingGCP:
+		return nil, fmt.Errorf("invalid ERROR_REPORTING %q (expected gcp)", config.ErrorReporting)
+	case config.ErrorReporting == errorReportingGCP && config.SentryDSN != "":
+		return nil, fmt.Errorf("ERROR_REPORTING=gcp and SENTRY_DSN are mutually exclusive")
+	case config.ErrorReporting == errorReportingGCP:
+		credentials, err := gcp.LoadCredentials(config.CredentialsFile, config.CredentialsJSON)
+		if err != nil {
+			return nil, err
+		}
+		project := config.ErrorReportingProject
+		if project == "" {
+			project = config.ProjectIDs[0]
+		}
+		reporter, err := errreport.NewGCP(context.Background(), project, "", config.Environment, credentials.ClientOptions()...)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(os.Stderr, "🚨 Reporting errors to Cloud Error Reporting in project %s\n", project)
+		return &errorReporter{reporter: reporter, redactor: redactor}, nil
+	case config.SentryDSN != "":
+		reporter, err := errreport.NewSentry(config.SentryDSN, config.Environment, "", nil)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(os.Stderr, "🚨 Reporting errors to Sentry\n")
+		return &errorReporter{reporter: reporter, redactor: redactor}, nil
+	}
+	return nil, nil
+}
+
+// report sends one event with credentials and addresses redacted, logging
+// (not returning) delivery failures
+func (r *errorReporter) report(event errreport.Event) {
+	if r == nil {
+		return
+	}
+	event.Message = r.redactor.String(event.Message)
+	ctx, cancel := context.WithTimeout(context.Background(), errorReportTimeout)
+	defer cancel()
+	if err := r.reporter.Report(ctx, event); err != nil {
+		log.Printf("Warning: failed to report error: %v", err)
+	}
+}
+
+// generationFailed reports a failed generation cycle, with the service
+// whose token failure aborted it if that was the cause. A generation
+// refused for removing too many routers or for deprecated labels is
+// reported as such.
+func (r *errorReporter) generationFailed(err error) {
+	event := errreport.Event{Message: err.Error(), Code: logging.CodeConfigGenerationError}
+	var tokenErr *provider.TokenError
+	var removalErr *provider.RemovalGuardError
+	var deprecationErr *provider.DeprecationError
+	switch {
+	case errors.As(err, &tokenErr):
+		event.Service = tokenErr.Service
+	case errors.As(err, &removalErr):
+		event.Code = logging.CodeRouterRemovalRefused
+	case errors.As(err, &deprecationErr):
+		event.Code = logging.CodeDeprecatedRefused
+	}
+	r.report(event)
+}
+
+// writeFailed reports a routes file that couldn't be written or promoted
+func (r *errorReporter) writeFailed(err error) {
+	r.report(errreport.Event{Message: err.Error(), Code: logging.CodeConfigSentError})
+}
+
+// servicesFailed reports the services left out of a generation because
+// processing them failed (token failures, invalid labels). Filtered,
+// conflicting and degraded services are expected and not reported.
+func (r *errorReporter) servicesFailed(report *provider.GenerationReport) {
+	if r == nil || report == nil {
+		return
+	}
+	for _, skipped := range report.Skipped {
+		if skipped.Degraded || (skipped.Reason != provider.SkipReasonTokenFailure && skipped.Reason != provider.SkipReasonInvalid) {
+			continue
+		}
+		r.report(errreport.Event{
+			Message: fmt.Sprintf("%s: %s", skipped.Reason, skipped.Detail),
+			Code:    logging.CodeServiceProcessingError,
+			Project: skipped.Project,
+			Service: skipped.Service,
+		})
+	}
+}
+
+// recoverPanic reports a panic with its stack and panics again, so the
+// process still crashes (and restarts) as it would without reporting.
+// Deferred in main.
+func (r *errorReporter) recoverPanic() {
+	if r == nil {
+		return
+	}
+	if recovered := recover(); recovered != nil {
+		r.report(errreport.Event{
+			Message: fmt.Sprintf("panic: %v", recovered),
+			Stack:   string(debug.Stack()),
+			Code:    logging.CodeProviderPanic,
+		})
+		panic(recovered)
+	}
+}
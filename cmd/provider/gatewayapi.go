@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/provider"
+	"gopkg.in/yaml.v3"
+)
+
+// writeGatewayAPI writes the configuration as Kubernetes Gateway API manifests
+// (one YAML document per object), for use with kubectl apply or a GitOps tool
+func writeGatewayAPI(outputFile string, config *provider.DynamicConfig, opts provider.GatewayAPIOptions) error {
+	objects, warnings := config.GatewayAPIObjects(opts)
+	for _, warning := range warnings {
+		fmt.Fprintf(os.Stderr, "   WARNING: Gateway API export: %s\n", warning)
+	}
+
+	file, err := os.Create(outputFile)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+
+	fmt.Fprintf(file, "# Auto-generated Kubernetes Gateway API manifests from Cloud Run service labels\n")
+	fmt.Fprintf(file, "# Generated at: %s\n", time.Now().UTC().Format(time.RFC3339))
+	if generation := config.Generation(); generation > 0 {
+		fmt.Fprintf(file, "# Generation: %d\n", generation)
+	}
+	fmt.Fprintf(file, "# Environment: %s\n", os.Getenv("ENVIRONMENT"))
+	fmt.Fprintf(file, "#\n")
+	fmt.Fprintf(file, "# This file is generated by traefik-cloudrun-provider\n\n")
+
+	encoder := yaml.NewEncoder(file)
+	encoder.SetIndent(2)
+	for _, object := range objects {
+		if err := encoder.Encode(object); err != nil {
+			return fmt.Errorf("failed to encode YAML: %w", err)
+		}
+	}
+	return encoder.Close()
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/internal/logging"
+)
+
+// Log file rotation defaults (LOG_MAX_SIZE, LOG_MAX_BACKUPS)
+const (
+	defaultLogMaxSize    = 100 // Megabytes
+	defaultLogMaxBackups = 5
+)
+
+// openLogFile opens LOG_FILE, if set, and sends the provider and Go logs to
+// it as well as to the console, so history survives restarts on a VM
+func openLogFile(config *AppConfig) error {
+	if config.LogFile == "" {
+		return nil
+	}
+	file, err := logging.OpenRotatingFile(config.LogFile, logging.RotationConfig{
+		MaxSize:    int64(config.LogMaxSize) << 20,
+		MaxAge:     config.LogMaxAge,
+		MaxBackups: config.LogMaxBackups,
+	})
+	if err != nil {
+		return err
+	}
+	config.LogWriter = file
+	log.SetOutput(io.MultiWriter(os.Stderr, file))
+	return nil
+}
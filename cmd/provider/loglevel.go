@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/provider"
+)
+
+// logLevelSwitch changes the daemon's log level at runtime, from SIGUSR2
+// or the /log-level endpoint, without a restart. A level set at runtime is
+// kept when the config file reload swaps the provider.
+type logLevelSwitch struct {
+	mu       sync.Mutex
+	provider *provider.Provider
+	base     string // Level the provider was started with (LOG_LEVEL)
+	override string // Level set at runtime ("" = none)
+}
+
+// newLogLevelSwitch returns the switch for p
+func newLogLevelSwitch(p *provider.Provider) *logLevelSwitch {
+	return &logLevelSwitch{provider: p, base: p.LogLevel()}
+}
+
+// set switches to a new provider, applying the runtime level to it
+func (s *logLevelSwitch) set(p *provider.Provider) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.provider = p
+	if s.override != "" {
+		_ = p.SetLogLevel(s.override) // Validated when it was set
+	}
+}
+
+// change sets the log level (DEBUG, INFO, WARN or ERROR)
+func (s *logLevelSwitch) change(level string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.provider.SetLogLevel(level); err != nil {
+		return err
+	}
+	s.override = s.provider.LogLevel()
+	fmt.Fprintf(os.Stderr, "🔧 Log level set to %s\n", s.override)
+	return nil
+}
+
+// toggle switches between DEBUG and the level the provider was started with
+func (s *logLevelSwitch) toggle() {
+	level := "DEBUG"
+	if strings.EqualFold(s.current(), level) {
+		level = s.base
+	}
+	_ = s.change(level) // Both levels are valid
+}
+
+// current returns the log level in effect
+func (s *logLevelSwitch) current() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.provider.LogLevel()
+}
+
+// watchLogLevelSignal toggles the log level on every SIGUSR2, right away
+// rather than between generation cycles
+func watchLogLevelSignal(levels *logLevelSwitch) {
+	usr2Chan := make(chan os.Signal, 1)
+	signal.Notify(usr2Chan, syscall.SIGUSR2)
+	go func() {
+		for range usr2Chan {
+			levels.toggle()
+		}
+	}()
+}
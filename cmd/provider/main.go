@@ -1,15 +1,22 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/internal/consul"
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/internal/schema"
 	"github.com/pci-tamper-protect/traefik-cloudrun-provider/provider"
 	"gopkg.in/yaml.v3"
 )
@@ -19,10 +26,35 @@ const (
 	defaultRegion       = "us-central1"
 	defaultOutputFile   = "/etc/traefik/dynamic/routes.yml"
 	defaultPollInterval = 30 * time.Second
+	defaultDrainGrace   = 10 * time.Second
+	stdoutOutput        = "-" // Output file printing the routes to stdout
+)
+
+// Output formats (OUTPUT_FORMAT)
+const (
+	outputFormatTraefik    = "traefik"     // Traefik file provider routes.yml
+	outputFormatGatewayAPI = "gateway-api" // Kubernetes Gateway API HTTPRoutes and Services
+)
+
+// failOnDeprecatedFlag makes deprecated settings and label forms fail the
+// run, like FAIL_ON_DEPRECATED=true, e.g. in CI
+const failOnDeprecatedFlag = "--fail-on-deprecated"
+
+// Shutdown modes for daemon mode (SHUTDOWN_MODE)
+const (
+	shutdownModeNone  = "none"
+	shutdownModeFlush = "flush"
+	shutdownModeDrain = "drain"
 )
 
 func main() {
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
+
+	// The code catalog needs no configuration
+	if len(os.Args) > 1 && os.Args[1] == codesCommand {
+		os.Exit(runCodes(os.Args[2:]))
+	}
+	failOnDeprecated := takeFlag(failOnDeprecatedFlag)
 	fmt.Fprintf(os.Stderr, "🚀 Starting traefik-cloudrun-provider at %s\n", time.Now().UTC().Format(time.RFC3339))
 
 	// Load .env file if it exists (optional, silently ignore if not found)
@@ -35,16 +67,65 @@ func main() {
 	}
 
 	// Load configuration from environment
-	config := loadConfig()
+	envConfig := loadConfig()
+
+	// Keep the logs in a rotated file as well, if configured
+	if err := openLogFile(envConfig); err != nil {
+		log.Fatalf("Failed to open LOG_FILE: %v", err)
+	}
+
+	// Layer the optional config file on top of the environment
+	config := envConfig
+	if envConfig.ConfigFile != "" {
+		fileConfig, err := loadFileConfig(envConfig.ConfigFile)
+		if err != nil {
+			log.Fatalf("Failed to load config file: %v", err)
+		}
+		if config, err = fileConfig.apply(envConfig); err != nil {
+			log.Fatalf("Invalid config file %s: %v", envConfig.ConfigFile, err)
+		}
+	}
+
+	if failOnDeprecated {
+		config.FailOnDeprecated = true
+	}
+
+	switch subcommand() {
+	case preflightCommand:
+		os.Exit(runPreflight(config))
+	case bootstrapCommand:
+		os.Exit(runBootstrap(config))
+	case verifyCommand:
+		os.Exit(runVerify(config))
+	case previewCommand:
+		os.Exit(runPreview(config))
+	case debugBundleCommand:
+		os.Exit(runDebugBundle(config))
+	}
 
 	fmt.Fprintf(os.Stderr, "🔍 Generating Traefik routes from Cloud Run service labels...\n")
 	fmt.Fprintf(os.Stderr, "   Environment: %s\n", config.Environment)
 	fmt.Fprintf(os.Stderr, "   Projects: %v\n", config.ProjectIDs)
 	fmt.Fprintf(os.Stderr, "   Region: %s\n", config.Region)
-	fmt.Fprintf(os.Stderr, "   Output: %s\n", config.OutputFile)
+	if config.Stdout {
+		fmt.Fprintf(os.Stderr, "   Output: stdout (via %s)\n", config.OutputFile)
+	} else {
+		fmt.Fprintf(os.Stderr, "   Output: %s\n", config.OutputFile)
+	}
 	fmt.Fprintf(os.Stderr, "   Mode: %s\n", config.Mode)
+	fmt.Fprintf(os.Stderr, "   Output Format: %s\n", config.OutputFormat)
+	if config.MultiTenant {
+		fmt.Fprintf(os.Stderr, "   Multi-Tenant: per-tenant files next to %s\n", config.OutputFile)
+	}
+	if config.ConfigFile != "" {
+		fmt.Fprintf(os.Stderr, "   Config File: %s\n", config.ConfigFile)
+	}
+	if config.ConsulAddress != "" {
+		fmt.Fprintf(os.Stderr, "   Consul: %s\n", config.ConsulAddress)
+	}
 	if config.Mode == "daemon" {
-		fmt.Fprintf(os.Stderr, "   Poll Interval: %s\n", config.PollInterval)
+		fmt.Fprintf(os.Stderr, "   Poll: %s\n", newPollSchedule(config))
+		fmt.Fprintf(os.Stderr, "   Shutdown Mode: %s\n", config.ShutdownMode)
 	}
 	fmt.Fprintf(os.Stderr, "\n")
 
@@ -53,38 +134,152 @@ func main() {
 		log.Fatalf("Failed to create output directory: %v", err)
 	}
 
-	// Create provider
-	providerConfig := &provider.Config{
-		ProjectIDs:   config.ProjectIDs,
-		Region:       config.Region,
-		PollInterval: config.PollInterval,
+	// Upgrade a routes file written by an older provider version
+	if config.OutputFormat == outputFormatTraefik {
+		if err := migrateRoutesFile(config.OutputFile); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+
+	// Report failures and panics to an error tracker, if configured
+	reporter, err := newErrorReporter(config)
+	if err != nil {
+		log.Fatalf("Failed to set up error reporting: %v", err)
 	}
+	defer reporter.recoverPanic()
 
-	p, err := provider.New(providerConfig)
+	// Create provider
+	p, err := provider.New(newProviderConfig(config))
 	if err != nil {
 		log.Fatalf("Failed to create provider: %v", err)
 	}
 
+	// The routes being replaced are the baseline for MAX_ROUTER_REMOVAL
+	if config.MaxRouterRemoval > 0 && config.OutputFormat == outputFormatTraefik {
+		previous, err := loadRoutes(config)
+		if err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		p.SetPrevious(previous)
+	}
+
 	if config.Mode == "daemon" {
-		runDaemon(p, config)
+		runDaemon(p, config, envConfig, reporter)
 	} else {
-		runOnce(p, config)
+		runOnce(p, config, reporter)
 	}
 }
 
+// newProviderConfig maps the application configuration onto the provider's configuration
+func newProviderConfig(config *AppConfig) *provider.Config {
+	providerConfig := &provider.Config{
+		ProjectIDs:            config.ProjectIDs,
+		Region:                config.Region,
+		PollInterval:          config.PollInterval,
+		ListCacheTTL:          config.ListCacheTTL,
+		ScanJitter:            config.ScanJitter,
+		StaleRouteGracePeriod: config.StaleRouteGracePeriod,
+		RouteDeletionDelay:    config.RouteDeletionDelay,
+		MaxRouterRemoval:      config.MaxRouterRemoval,
+		AllowRouterRemoval:    config.AllowRouterRemoval,
+		FailOnDeprecated:      config.FailOnDeprecated,
+		ProjectRequestBudget:  config.ProjectRequestBudget,
+		IncrementalUpdates:    config.IncrementalUpdates,
+		FragmentMaxAge:        config.FragmentMaxAge,
+		TokenInjection:        config.TokenInjection,
+		TokenPluginName:       config.TokenPluginName,
+		ShareAuthMiddlewares:  config.ShareAuthMiddlewares,
+		TokenFailurePolicy:    config.TokenFailurePolicy,
+		AccessTokenServices:   config.AccessTokenServices,
+		AccessTokenScopes:     config.AccessTokenScopes,
+		LabelValidation:       config.LabelValidation,
+		UserAuth:              config.UserAuth,
+		IncludeServices:       config.IncludeServices,
+		ExcludeServices:       config.ExcludeServices,
+		Maintenance:           config.Maintenance,
+		MaintenanceService:    config.MaintenanceService,
+		CatchAllServices:      config.CatchAllServices,
+		RulePolicies:          config.RulePolicies,
+		DefaultMiddlewares:    config.DefaultMiddlewares,
+		ColdStartByScale:      config.ColdStartByScale,
+		ColdStartMiddlewares:  config.ColdStartMiddlewares,
+		ColdStartTimeout:      config.ColdStartTimeout,
+		RequestTimeouts:       config.RequestTimeouts,
+		NamePrefix:            config.NamePrefix,
+		Environment:           config.Environment,
+		EnvironmentSuffixes:   config.EnvironmentSuffixes,
+		RouterConflictPolicy:  config.RouterConflictPolicy,
+		ReadinessPolicy:       config.ReadinessPolicy,
+		ZeroTrafficPolicy:     config.ZeroTrafficPolicy,
+		PriorityTiePolicy:     config.PriorityTiePolicy,
+		TraefikVersion:        config.TraefikVersion,
+		AnthosTargets:         config.AnthosTargets,
+		AuthProviders:         config.AuthProviders,
+		ProjectDefaults:       config.ProjectDefaults,
+		DefaultsService:       config.DefaultsService,
+		AutoEntryPoints:       config.AutoEntryPoints,
+		EntryPointRules:       config.EntryPointRules,
+		Dashboard:             config.Dashboard,
+		CredentialsFile:       config.CredentialsFile,
+		CredentialsJSON:       config.CredentialsJSON,
+		RunAPIEndpoint:        config.RunAPIEndpoint,
+		RouteTagging:          config.RouteTagging,
+		RouteTagHeader:        config.RouteTagHeader,
+		RequestIDEnabled:      config.RequestIDEnabled,
+		RequestIDHeader:       config.RequestIDHeader,
+		BreakerThreshold:      config.BreakerThreshold,
+		BreakerCooldown:       config.BreakerCooldown,
+		SelfTest:              config.SelfTest || config.PromotionProbe,
+		SelfTestConcurrency:   config.SelfTestConcurrency,
+		SelfTestTimeout:       config.SelfTestTimeout,
+		DNSCheck:              config.DNSCheck,
+		DNSCheckTimeout:       config.DNSCheckTimeout,
+		RedactHeaders:         config.RedactHeaders,
+		RedactClaims:          config.RedactClaims,
+		RedactEmails:          config.RedactEmails,
+	}
+	// Keep stdout for the routes when printing them there
+	if config.Stdout {
+		providerConfig.LogOutput = os.Stderr
+	}
+	if config.LogWriter != nil {
+		console := providerConfig.LogOutput
+		if console == nil {
+			console = os.Stdout
+		}
+		providerConfig.LogOutput = io.MultiWriter(console, config.LogWriter)
+	}
+	return providerConfig
+}
+
 // runOnce generates configuration once and exits
-func runOnce(p *provider.Provider, config *AppConfig) {
+func runOnce(p *provider.Provider, config *AppConfig, reporter *errorReporter) {
+	refreshTrustedIPs(config, newRangesFetcher(config))
+
 	configChan := make(chan *provider.DynamicConfig, 1)
 	if err := p.RunOnce(configChan); err != nil {
+		reporter.generationFailed(err)
 		log.Fatalf("Failed to generate config: %v", err)
 	}
 
 	select {
 	case dynamicConfig := <-configChan:
-		if err := writeRoutes(config.OutputFile, dynamicConfig); err != nil {
+		reporter.servicesFailed(p.LastReport())
+		sinks, err := newOutputSinks(config)
+		if err != nil {
+			log.Fatalf("Invalid OUTPUT_SINKS: %v", err)
+		}
+		if err := publish(config, newPromotionGate(config), sinks, dynamicConfig, p.LastReport()); err != nil {
+			reporter.writeFailed(err)
 			log.Fatalf("Failed to write routes file: %v", err)
 		}
 		printSummary(config.OutputFile, dynamicConfig)
+		printProbeResults(p)
+		if registrar := newRegistrar(config); registrar != nil {
+			if err := registrar.Sync(p.LastReport().Discovered); err != nil {
+				log.Fatalf("Failed to register services in Consul: %v", err)
+			}
+		}
 
 	case <-time.After(60 * time.Second):
 		log.Fatalf("Timeout waiting for configuration")
@@ -93,70 +288,481 @@ func runOnce(p *provider.Provider, config *AppConfig) {
 
 // runDaemon runs continuously, regenerating routes on interval.
 // Uses RunOnce per tick so no background polling goroutines accumulate.
-func runDaemon(p *provider.Provider, config *AppConfig) {
-	fmt.Fprintf(os.Stderr, "🔄 Running in daemon mode (poll every %s)\n", config.PollInterval)
+// If CONFIG_FILE is set, the file is checked for changes on every tick and
+// applied on top of envConfig without restarting.
+func runDaemon(p *provider.Provider, config, envConfig *AppConfig, reporter *errorReporter) {
+	schedule := newPollSchedule(config)
+	fmt.Fprintf(os.Stderr, "🔄 Running in daemon mode (poll %s)\n", schedule)
 
 	// Handle graceful shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
-	ticker := time.NewTicker(config.PollInterval)
-	defer ticker.Stop()
+	poll := time.NewTimer(schedule.delay(time.Now()))
+	defer poll.Stop()
+
+	var watcher *configWatcher
+	if config.ConfigFile != "" {
+		watcher = newConfigWatcher(config.ConfigFile)
+	}
+
+	// Kept across cycles so services that disappear are deregistered
+	registrar := newRegistrar(config)
+
+	// Kept across cycles so only changed routers need approval
+	gate := newPromotionGate(config)
+
+	// Kept across cycles so sink failures are counted
+	sinks, err := newOutputSinks(config)
+	if err != nil {
+		log.Fatalf("Invalid OUTPUT_SINKS: %v", err)
+	}
+
+	// Kept across cycles so ranges are only refetched when due
+	rangesFetcher := newRangesFetcher(config)
+	refreshTrustedIPs(config, rangesFetcher)
+
+	// Forced token rotation, from SIGUSR1 or the metrics server
+	rotateChan := make(chan []string)
+	usr1Chan := make(chan os.Signal, 1)
+	signal.Notify(usr1Chan, syscall.SIGUSR1)
+	levels := newLogLevelSwitch(p)
+	watchLogLevelSignal(levels)
+	metrics := startMetricsServer(config, p, rotateChan, levels)
+	exporter, err := startMonitoringExporter(config, p)
+	if err != nil {
+		log.Fatalf("Failed to start Cloud Monitoring export: %v", err)
+	}
 
 	// Generate initial configuration
-	generateAndWrite(p, config)
+	generateAndWrite(p, config, registrar, gate, sinks, reporter)
 
-	generation := 1
 	for {
 		select {
-		case <-ticker.C:
-			generation++
-			fmt.Fprintf(os.Stderr, "\n🔄 [Gen %d] Regenerating routes at %s\n", generation, time.Now().Format(time.RFC3339))
-			generateAndWrite(p, config)
+		case <-poll.C:
+			if watcher != nil && watcher.changed() {
+				p, config = reloadConfig(p, config, envConfig)
+				schedule = newPollSchedule(config)
+				metrics.set(p)
+				levels.set(p)
+				exporter.set(p)
+			}
+			// Scheduled from the start of this cycle, like a ticker
+			poll.Reset(schedule.delay(time.Now()))
+
+			refreshTrustedIPs(config, rangesFetcher)
+
+			fmt.Fprintf(os.Stderr, "\n🔄 Regenerating routes at %s\n", time.Now().Format(time.RFC3339))
+			generateAndWrite(p, config, registrar, gate, sinks, reporter)
+
+		case <-usr1Chan:
+			rotateTokens(p, config, registrar, gate, sinks, reporter, nil)
+
+		case audiences := <-rotateChan:
+			rotateTokens(p, config, registrar, gate, sinks, reporter, audiences)
 
 		case sig := <-sigChan:
+			// Generation runs on this goroutine, so any in-flight cycle has
+			// already finished by the time the signal is handled here
 			fmt.Fprintf(os.Stderr, "\n⏹️  Received %s, shutting down...\n", sig)
+			shutdown(p, config, registrar, gate, sinks, reporter, sigChan)
 			return
 		}
 	}
 }
 
-// generateAndWrite runs one discovery cycle and writes routes.yml.
+// rotateTokens drops cached identity tokens (all of them, or those for
+// audiences) and regenerates the routes right away, so auth middlewares
+// carry freshly minted tokens
+func rotateTokens(p *provider.Provider, config *AppConfig, registrar *consul.Registrar, gate *promotionGate, sinks *outputSinks, reporter *errorReporter, audiences []string) {
+	if len(audiences) == 0 {
+		fmt.Fprintf(os.Stderr, "\n🔑 Rotating all identity tokens at %s\n", time.Now().Format(time.RFC3339))
+	} else {
+		fmt.Fprintf(os.Stderr, "\n🔑 Rotating identity tokens for %s at %s\n", strings.Join(audiences, ", "), time.Now().Format(time.RFC3339))
+	}
+	p.InvalidateTokens(audiences...)
+	generateAndWrite(p, config, registrar, gate, sinks, reporter)
+}
+
+// newReloadedProvider creates the provider for a reloaded configuration
+// (replaced in tests)
+var newReloadedProvider = provider.New
+
+// reloadConfig re-reads the config file and swaps in a new provider if the
+// effective configuration changed, carrying over the state the current one
+// built up across polls. On any error the current provider and
+// configuration are kept.
+func reloadConfig(p *provider.Provider, config, envConfig *AppConfig) (*provider.Provider, *AppConfig) {
+	fmt.Fprintf(os.Stderr, "\n📝 Config file %s changed, reloading...\n", config.ConfigFile)
+
+	fileConfig, err := loadFileConfig(config.ConfigFile)
+	if err != nil {
+		log.Printf("Error reloading config file, keeping current configuration: %v", err)
+		return p, config
+	}
+	updated, err := fileConfig.apply(envConfig)
+	if err != nil {
+		log.Printf("Invalid config file, keeping current configuration: %v", err)
+		return p, config
+	}
+
+	diff := configDiff(config, updated)
+	if len(diff) == 0 {
+		fmt.Fprintf(os.Stderr, "   No effective changes\n")
+		return p, config
+	}
+
+	newProvider, err := newReloadedProvider(newProviderConfig(updated))
+	if err != nil {
+		log.Printf("Error creating provider from reloaded config, keeping current configuration: %v", err)
+		return p, config
+	}
+	newProvider.Inherit(p)
+
+	for _, change := range diff {
+		fmt.Fprintf(os.Stderr, "   %s\n", change)
+	}
+	fmt.Fprintf(os.Stderr, "✅ Applied reloaded configuration\n")
+	return newProvider, updated
+}
+
+// shutdown performs the configured final write before the daemon exits.
+//   - "flush": regenerate and write one last configuration
+//   - "drain": write a configuration with all Cloud Run routes removed, then
+//     wait DRAIN_GRACE_PERIOD so Traefik can pick it up and finish in-flight requests
+//
+// A second signal during the grace period exits immediately.
+func shutdown(p *provider.Provider, config *AppConfig, registrar *consul.Registrar, gate *promotionGate, sinks *outputSinks, reporter *errorReporter, sigChan <-chan os.Signal) {
+	switch config.ShutdownMode {
+	case shutdownModeFlush:
+		fmt.Fprintf(os.Stderr, "💾 Flushing final configuration...\n")
+		generateAndWrite(p, config, registrar, gate, sinks, reporter)
+
+	case shutdownModeDrain:
+		fmt.Fprintf(os.Stderr, "🚰 Draining routes (grace period %s)...\n", config.DrainGracePeriod)
+		drainConfig := p.DrainConfig()
+		if err := writeOutput(config, drainConfig, nil); err != nil {
+			log.Printf("Error writing drain routes file: %v", err)
+			return
+		}
+		sinks.push(config)
+		if err := writeStdout(config); err != nil {
+			log.Printf("Error printing drain routes: %v", err)
+		}
+		printSummary(config.OutputFile, drainConfig)
+
+		select {
+		case <-time.After(config.DrainGracePeriod):
+		case sig := <-sigChan:
+			fmt.Fprintf(os.Stderr, "⏹️  Received %s during drain, exiting now\n", sig)
+		}
+	}
+}
+
+// generateAndWrite runs one discovery cycle and writes routes.yml (through
+// the promotion gate, if any), then registers the discovered services in
+// Consul if a registrar is given.
 // Creates a fresh channel each call — avoids goroutine accumulation from Start().
-func generateAndWrite(p *provider.Provider, config *AppConfig) {
+func generateAndWrite(p *provider.Provider, config *AppConfig, registrar *consul.Registrar, gate *promotionGate, sinks *outputSinks, reporter *errorReporter) {
 	configChan := make(chan *provider.DynamicConfig, 1)
 	if err := p.RunOnce(configChan); err != nil {
 		log.Printf("Error generating config: %v", err)
+		reporter.generationFailed(err)
 		return
 	}
 
 	select {
 	case dynamicConfig := <-configChan:
-		if err := writeRoutes(config.OutputFile, dynamicConfig); err != nil {
+		reporter.servicesFailed(p.LastReport())
+		if err := publish(config, gate, sinks, dynamicConfig, p.LastReport()); err != nil {
 			log.Printf("Error writing routes file: %v", err)
+			reporter.writeFailed(err)
 		} else {
 			printSummary(config.OutputFile, dynamicConfig)
 		}
+		printProbeResults(p)
+		printBreakerStatus(p)
+		printStaleProjects(p)
+		if registrar != nil {
+			if err := registrar.Sync(p.LastReport().Discovered); err != nil {
+				log.Printf("Error registering services in Consul: %v", err)
+			}
+		}
 	case <-time.After(60 * time.Second):
 		log.Printf("Timeout waiting for configuration")
 	}
 }
 
+// newRegistrar returns the Consul registrar, or nil if CONSUL_ADDR is not set
+func newRegistrar(config *AppConfig) *consul.Registrar {
+	if config.ConsulAddress == "" {
+		return nil
+	}
+	return consul.NewRegistrar(config.ConsulAddress, config.ConsulToken)
+}
+
+// printBreakerStatus reports projects and services skipped after repeated failures
+func printBreakerStatus(p *provider.Provider) {
+	for _, status := range p.BreakerStatus() {
+		if status.Open {
+			fmt.Fprintf(os.Stderr, "⛔ Skipping %s until %s after %d consecutive failures: %s\n",
+				status.Key, status.OpenUntil.Format(time.RFC3339), status.ConsecutiveFailures, status.LastError)
+		}
+	}
+}
+
+// printStaleProjects reports projects whose routes were kept from an earlier
+// list because listing them failed, and services kept after disappearing
+func printStaleProjects(p *provider.Provider) {
+	report := p.LastReport()
+	if report == nil {
+		return
+	}
+	for _, stale := range report.Stale {
+		fmt.Fprintf(os.Stderr, "🕰️  Keeping %d services of %s listed at %s: %s\n",
+			stale.Services, stale.Project, stale.ListedAt.Format(time.RFC3339), stale.Error)
+	}
+	for _, departing := range report.Departing {
+		fmt.Fprintf(os.Stderr, "🕰️  Keeping routes of %s (%s), missing from discovery for %d polls\n",
+			departing.Service, departing.Project, departing.MissedPolls)
+	}
+}
+
+// printProbeResults reports backends whose host didn't resolve and the
+// backend self-test results from the last generation
+func printProbeResults(p *provider.Provider) {
+	report := p.LastReport()
+	if report == nil {
+		return
+	}
+	for _, backend := range report.Unresolved {
+		fmt.Fprintf(os.Stderr, "🌐 %s (%q) routers=%v doesn't resolve: %s\n",
+			backend.Service, backend.URL, backend.Routers, backend.Error)
+	}
+	if len(report.Probes) == 0 {
+		return
+	}
+	failed := report.FailedProbes()
+	fmt.Fprintf(os.Stderr, "🩺 Self-test: %d/%d backends reachable\n", len(report.Probes)-len(failed), len(report.Probes))
+	for _, probe := range failed {
+		detail := probe.Error
+		if probe.StatusCode != 0 {
+			detail = fmt.Sprintf("HTTP %d", probe.StatusCode)
+		}
+		fmt.Fprintf(os.Stderr, "   ❌ %s (%s) routers=%v: %s %s\n",
+			probe.Service, probe.URL, probe.Routers, probe.Status, detail)
+	}
+}
+
 func printSummary(outputFile string, dynamicConfig *provider.DynamicConfig) {
-	fmt.Fprintf(os.Stderr, "✅ Routes file generated at %s\n", outputFile)
+	fmt.Fprintf(os.Stderr, "✅ Routes file generated at %s (generation %d)\n", outputFile, dynamicConfig.Generation())
 	fmt.Fprintf(os.Stderr, "📊 Summary: Routers=%d Services=%d Middlewares=%d\n",
 		len(dynamicConfig.HTTP.Routers),
 		len(dynamicConfig.HTTP.Services),
 		len(dynamicConfig.HTTP.Middlewares))
+	for _, s := range dynamicConfig.Skipped() {
+		fmt.Fprintf(os.Stderr, "   ⚠️  %s (%s): %s %s\n", s.Service, s.Project, s.Reason, s.Detail)
+	}
 }
 
 type AppConfig struct {
-	Environment  string
-	ProjectIDs   []string
-	Region       string
-	OutputFile   string
-	Mode         string // "once" or "daemon"
-	PollInterval time.Duration
+	Environment          string
+	EnvironmentSuffixes  []string // Stripped from service names in router conflicts (default: common ones plus -<Environment>)
+	RouterConflictPolicy string   // Which service keeps a router defined twice (default: dedicated-wins)
+	ReadinessPolicy      string   // Services that aren't ready: ignore (default), skip or failover
+	ZeroTrafficPolicy    string   // Services serving 0% of traffic: route (default), drop or deprioritize
+	PriorityTiePolicy    string   // Routers sharing a priority with overlapping rules: warn (default) or bump
+	ProjectIDs           []string
+	Region               string
+	OutputFile           string
+	Stdout               bool   // Print the routes to stdout after writing them to OutputFile (a temporary file)
+	OutputFormat         string // "traefik" or "gateway-api"
+	MultiTenant          bool   // Write each tenant's routes to its own file (traefik format only)
+	SkippedSummary       bool   // List skipped services in a comment in the routes file (traefik format only)
+	Mode                 string // "once" or "daemon"
+	PollInterval         time.Duration
+	PollJitter           time.Duration // Max random delay added to each daemon poll
+	PollAlign            bool          // Poll at wall-clock multiples of PollInterval
+
+	// Kubernetes Gateway API export settings (OUTPUT_FORMAT=gateway-api)
+	GatewayAPI provider.GatewayAPIOptions
+
+	// API quota settings
+	ListCacheTTL          time.Duration
+	ScanJitter            time.Duration
+	StaleRouteGracePeriod time.Duration
+	RouteDeletionDelay    int
+	MaxRouterRemoval      int  // Percent of the live routers a generation may remove (0 = no limit)
+	AllowRouterRemoval    bool // Override MaxRouterRemoval
+	FailOnDeprecated      bool // Exit non-zero instead of warning about deprecated settings (CI)
+	ProjectRequestBudget  int
+
+	// Incremental update settings
+	IncrementalUpdates bool
+	FragmentMaxAge     time.Duration
+
+	// Token injection mode ("static" or "plugin")
+	TokenInjection  string
+	TokenPluginName string
+
+	// One auth-<hash> middleware per backend URL instead of one per service
+	ShareAuthMiddlewares bool
+
+	// Token fetch failure policy ("emit-without-auth", "skip-route" or "fail-generation")
+	TokenFailurePolicy string
+
+	// Services allowed access tokens (glob patterns) and the scopes those are requested for
+	AccessTokenServices []string
+	AccessTokenScopes   []string
+
+	// Unknown traefik_* label handling ("ignore", "warn" or "strict")
+	LabelValidation string
+
+	// User auth (forwardAuth) settings
+	UserAuth provider.UserAuthConfig
+
+	// Service filters (glob patterns on Cloud Run service names)
+	IncludeServices []string
+	ExcludeServices []string
+
+	// Maintenance mode (glob patterns on Cloud Run service names) and where
+	// their routers point instead (default: a service answering 503)
+	Maintenance        []string
+	MaintenanceService string
+
+	// Services allowed to define catch-all routers (glob patterns, default home-index*)
+	CatchAllServices []string
+
+	// Guardrails on generated rules (config file only)
+	RulePolicies []provider.RulePolicy
+
+	// Middlewares appended to every generated router
+	DefaultMiddlewares []string
+
+	// Cold-start retry and timeout only for services that scale to zero
+	ColdStartByScale     bool
+	ColdStartMiddlewares []string
+	ColdStartTimeout     time.Duration
+	RequestTimeouts      bool
+
+	// Prefix for generated router, service and middleware names
+	NamePrefix string
+
+	// Traefik version the output is written for ("v2" or "v3")
+	TraefikVersion string
+
+	// Explicit credentials instead of the ambient identity
+	CredentialsFile string
+	CredentialsJSON string
+
+	// Cloud Run Admin API endpoint override
+	RunAPIEndpoint string
+
+	// Cloud Run for Anthos namespaces (config file only)
+	AnthosTargets []provider.AnthosTarget
+
+	// Named auth providers for the traefik_auth_provider label (config file only)
+	AuthProviders []provider.AuthProviderConfig
+
+	// Project label defaults (config file only) and the defaults service name
+	ProjectDefaults map[string]map[string]string
+	DefaultsService string
+
+	// Entrypoints and TLS assigned by rule type (rules: config file only)
+	AutoEntryPoints bool
+	EntryPointRules map[string]provider.EntryPointRule
+
+	// Traefik API and dashboard routers, off unless enabled
+	Dashboard provider.DashboardConfig
+
+	// Route name header on every generated router
+	RouteTagging   bool
+	RouteTagHeader string
+
+	// Request ID middleware on every generated router
+	RequestIDEnabled bool
+	RequestIDHeader  string
+
+	// Error budget per project/service
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+
+	// Backend self-test after generation
+	SelfTest            bool
+	SelfTestConcurrency int
+	SelfTestTimeout     time.Duration
+
+	// Backend host name resolution before emitting
+	DNSCheck        bool
+	DNSCheckTimeout time.Duration
+
+	// Optional YAML config file layered over the environment (watched in daemon mode)
+	ConfigFile string
+
+	// Shutdown behavior in daemon mode
+	ShutdownMode     string // "none", "flush" or "drain"
+	DrainGracePeriod time.Duration
+
+	// Consul agent to register discovered services with (empty = disabled)
+	ConsulAddress string
+	ConsulToken   string
+
+	// Trusted IPs for X-Forwarded-* headers, from Google's published netblocks
+	TrustedIPsFile    string        // Static config snippet kept up to date (empty = disabled)
+	TrustedIPsFetch   bool          // Use the published ranges in bootstrap output
+	TrustedIPsURL     string        // Range list URL (default: goog.json)
+	TrustedIPsRefresh time.Duration // How often ranges are refetched (default: 24h)
+
+	// Address to serve Prometheus metrics on in daemon mode (empty = disabled)
+	MetricsAddr string
+
+	// Export the same metrics to Cloud Monitoring in daemon mode: "gcp" or
+	// empty (disabled), the project written to (default: first project) and
+	// how often (default: 1m)
+	MonitoringExport   string
+	MonitoringProject  string
+	MonitoringInterval time.Duration
+
+	// Report generation failures, failed services and panics to Cloud Error
+	// Reporting (ErrorReporting "gcp", in ErrorReportingProject, default:
+	// first project) or to the Sentry project of SentryDSN (empty = off)
+	ErrorReporting        string
+	ErrorReportingProject string
+	SentryDSN             string
+
+	// Redaction of credentials and email addresses in logs, previews and
+	// error reports: extra credential headers, JWT claims kept readable and
+	// the email mode (mask, hide or keep; default mask)
+	RedactHeaders []string
+	RedactClaims  []string
+	RedactEmails  string
+
+	// Two-phase output: stage each configuration and only promote it to the
+	// output file once the webhook and/or self-test approve (empty/false = off)
+	StagingFile      string // Default: <output file>.staging
+	PromotionWebhook string
+	PromotionProbe   bool
+	PromotionTimeout time.Duration
+
+	// Signature embedded in every written routes file: a local HMAC key or
+	// a Cloud KMS MAC key version (empty = unsigned)
+	SigningKeyFile string
+	SigningKMSKey  string
+
+	// JSON route manifest written next to the routes (empty = disabled)
+	ManifestFile string
+
+	// Extra destinations the routes files are copied to after each write:
+	// gs:// objects, http(s):// endpoints or local paths
+	OutputSinks []string
+
+	// Log file kept besides the console output, rotated by size (MB) and/or
+	// age, keeping LogMaxBackups rotated files (empty = console only)
+	LogFile       string
+	LogMaxSize    int
+	LogMaxAge     time.Duration
+	LogMaxBackups int
+	LogWriter     io.Writer `yaml:"-"` // LogFile, once opened
 }
 
 func loadConfig() *AppConfig {
@@ -185,9 +791,23 @@ func loadConfig() *AppConfig {
 		region = defaultRegion
 	}
 
+	// Output file is the first argument, or the second after a subcommand,
+	// or OUTPUT. "-" prints the routes to stdout: they are written to a
+	// private temporary file first, so signing and sinks work as usual.
 	outputFile := defaultOutputFile
-	if len(os.Args) > 1 {
-		outputFile = os.Args[1]
+	if output := os.Getenv("OUTPUT"); output != "" {
+		outputFile = output
+	}
+	if args := commandArgs(); len(args) > 0 {
+		outputFile = args[0]
+	}
+	stdout := outputFile == stdoutOutput
+	if stdout {
+		dir, err := os.MkdirTemp("", "traefik-cloudrun-provider-")
+		if err != nil {
+			log.Fatalf("Failed to create temporary output directory: %v", err)
+		}
+		outputFile = filepath.Join(dir, filepath.Base(defaultOutputFile))
 	}
 
 	// Mode: "once" (default) or "daemon"
@@ -196,24 +816,250 @@ func loadConfig() *AppConfig {
 		mode = "once"
 	}
 
+	// Output format: "traefik" (default) or "gateway-api"
+	outputFormat := strings.ToLower(os.Getenv("OUTPUT_FORMAT"))
+	switch outputFormat {
+	case "":
+		outputFormat = outputFormatTraefik
+	case outputFormatTraefik, outputFormatGatewayAPI:
+	default:
+		log.Fatalf("Invalid OUTPUT_FORMAT %q (expected traefik or gateway-api)", outputFormat)
+	}
+	multiTenant := os.Getenv("MULTI_TENANT") == "true"
+	if multiTenant && outputFormat != outputFormatTraefik {
+		log.Fatalf("MULTI_TENANT requires OUTPUT_FORMAT=traefik")
+	}
+
 	// Poll interval for daemon mode
-	pollInterval := defaultPollInterval
-	if intervalStr := os.Getenv("POLL_INTERVAL"); intervalStr != "" {
-		if seconds, err := strconv.Atoi(intervalStr); err == nil {
-			pollInterval = time.Duration(seconds) * time.Second
-		} else if parsed, err := time.ParseDuration(intervalStr); err == nil {
-			pollInterval = parsed
-		}
+	pollInterval := durationFromEnv("POLL_INTERVAL", defaultPollInterval)
+
+	// Shutdown mode for daemon mode: "none" (default), "flush" or "drain"
+	shutdownMode := strings.ToLower(os.Getenv("SHUTDOWN_MODE"))
+	switch shutdownMode {
+	case "":
+		shutdownMode = shutdownModeNone
+	case shutdownModeNone, shutdownModeFlush, shutdownModeDrain:
+	default:
+		log.Fatalf("Invalid SHUTDOWN_MODE %q (expected none, flush or drain)", shutdownMode)
 	}
 
+	// Metrics export besides Prometheus: "gcp" (Cloud Monitoring) or none
+	monitoringExport := strings.ToLower(os.Getenv("MONITORING_EXPORT"))
+	if monitoringExport != "" && monitoringExport != monitoringExportGCP {
+		log.Fatalf("Invalid MONITORING_EXPORT %q (expected gcp)", monitoringExport)
+	}
+
+	// Quota controls: reuse cached list responses, spread project scans,
+	// and cap List calls per project per minute
+	projectRequestBudget := intFromEnv("PROJECT_REQUEST_BUDGET", 0)
+
 	return &AppConfig{
-		Environment:  env,
-		ProjectIDs:   projectIDs,
-		Region:       region,
-		OutputFile:   outputFile,
-		Mode:         mode,
-		PollInterval: pollInterval,
+		Environment:           env,
+		ProjectIDs:            projectIDs,
+		Region:                region,
+		OutputFile:            outputFile,
+		Stdout:                stdout,
+		OutputFormat:          outputFormat,
+		MultiTenant:           multiTenant,
+		SkippedSummary:        os.Getenv("SKIPPED_SUMMARY") != "false",
+		Mode:                  mode,
+		PollInterval:          pollInterval,
+		PollJitter:            durationFromEnv("POLL_JITTER", 0),
+		PollAlign:             os.Getenv("POLL_ALIGN") == "true",
+		ListCacheTTL:          durationFromEnv("LIST_CACHE_TTL", 0),
+		ScanJitter:            durationFromEnv("SCAN_JITTER", 0),
+		StaleRouteGracePeriod: durationFromEnv("STALE_ROUTE_GRACE_PERIOD", 0),
+		RouteDeletionDelay:    intFromEnv("ROUTE_DELETION_DELAY", 0),
+		MaxRouterRemoval:      intFromEnv("MAX_ROUTER_REMOVAL", 0),
+		AllowRouterRemoval:    os.Getenv("ALLOW_ROUTER_REMOVAL") == "true",
+		FailOnDeprecated:      os.Getenv("FAIL_ON_DEPRECATED") == "true",
+		ProjectRequestBudget:  projectRequestBudget,
+		IncrementalUpdates:    os.Getenv("INCREMENTAL_UPDATES") == "true",
+		FragmentMaxAge:        durationFromEnv("FRAGMENT_MAX_AGE", 0),
+		TokenInjection:        os.Getenv("TOKEN_INJECTION"),
+		TokenPluginName:       os.Getenv("TOKEN_PLUGIN_NAME"),
+		ShareAuthMiddlewares:  os.Getenv("SHARE_AUTH_MIDDLEWARES") == "true",
+		TokenFailurePolicy:    os.Getenv("TOKEN_FAILURE_POLICY"),
+		AccessTokenServices:   listFromEnv("ACCESS_TOKEN_SERVICES"),
+		AccessTokenScopes:     listFromEnv("ACCESS_TOKEN_SCOPES"),
+		LabelValidation:       os.Getenv("LABEL_VALIDATION"),
+		UserAuth: provider.UserAuthConfig{
+			MiddlewareNames:     listFromEnv("USER_AUTH_MIDDLEWARES"),
+			CheckBaseURL:        os.Getenv("USER_AUTH_CHECK_BASE_URL"),
+			CheckPath:           os.Getenv("USER_AUTH_CHECK_PATH"),
+			AuthResponseHeaders: listFromEnv("USER_AUTH_RESPONSE_HEADERS"),
+			AuthRequestHeaders:  listFromEnv("USER_AUTH_REQUEST_HEADERS"),
+		},
+		IncludeServices:      listFromEnv("INCLUDE_SERVICES"),
+		ExcludeServices:      listFromEnv("EXCLUDE_SERVICES"),
+		Maintenance:          listFromEnv("MAINTENANCE"),
+		MaintenanceService:   os.Getenv("MAINTENANCE_SERVICE"),
+		CatchAllServices:     listFromEnv("CATCH_ALL_SERVICES"),
+		DefaultMiddlewares:   listFromEnv("DEFAULT_MIDDLEWARES"),
+		ColdStartByScale:     os.Getenv("COLD_START_BY_SCALE") == "true",
+		ColdStartMiddlewares: listFromEnv("COLD_START_MIDDLEWARES"),
+		ColdStartTimeout:     durationFromEnv("COLD_START_TIMEOUT", 0),
+		RequestTimeouts:      os.Getenv("REQUEST_TIMEOUTS") == "true",
+		NamePrefix:           os.Getenv("NAME_PREFIX"),
+		EnvironmentSuffixes:  listFromEnv("ENVIRONMENT_SUFFIXES"),
+		RouterConflictPolicy: os.Getenv("ROUTER_CONFLICT_POLICY"),
+		ReadinessPolicy:      os.Getenv("READINESS_POLICY"),
+		ZeroTrafficPolicy:    os.Getenv("ZERO_TRAFFIC_POLICY"),
+		PriorityTiePolicy:    os.Getenv("PRIORITY_TIE_POLICY"),
+		TraefikVersion:       os.Getenv("TRAEFIK_VERSION"),
+		CredentialsFile:      os.Getenv("PROVIDER_CREDENTIALS_FILE"),
+		CredentialsJSON:      os.Getenv("PROVIDER_CREDENTIALS_JSON"),
+		RunAPIEndpoint:       os.Getenv("CLOUDRUN_API_ENDPOINT"),
+		DefaultsService:      os.Getenv("DEFAULTS_SERVICE"),
+		AutoEntryPoints:      os.Getenv("AUTO_ENTRYPOINTS") == "true",
+		RouteTagging:         os.Getenv("ROUTE_TAGGING") == "true",
+		RouteTagHeader:       os.Getenv("ROUTE_TAG_HEADER"),
+		RequestIDEnabled:     os.Getenv("REQUEST_ID_ENABLED") == "true",
+		RequestIDHeader:      os.Getenv("REQUEST_ID_HEADER"),
+		Dashboard: provider.DashboardConfig{
+			Enabled:        os.Getenv("DASHBOARD_ENABLED") == "true",
+			EntryPoints:    listFromEnv("DASHBOARD_ENTRYPOINTS"),
+			Host:           os.Getenv("DASHBOARD_HOST"),
+			Priority:       intFromEnv("DASHBOARD_PRIORITY", 0),
+			AllowedIPs:     listFromEnv("DASHBOARD_ALLOWED_IPS"),
+			BasicAuthUsers: listFromEnv("DASHBOARD_BASIC_AUTH_USERS"),
+		},
+		BreakerThreshold:    intFromEnv("BREAKER_THRESHOLD", 0),
+		BreakerCooldown:     durationFromEnv("BREAKER_COOLDOWN", 0),
+		SelfTest:            os.Getenv("SELF_TEST") == "true",
+		SelfTestConcurrency: intFromEnv("SELF_TEST_CONCURRENCY", 0),
+		SelfTestTimeout:     durationFromEnv("SELF_TEST_TIMEOUT", 0),
+		DNSCheck:            os.Getenv("DNS_CHECK") == "true",
+		DNSCheckTimeout:     durationFromEnv("DNS_CHECK_TIMEOUT", 0),
+		ConfigFile:          os.Getenv("CONFIG_FILE"),
+		ShutdownMode:        shutdownMode,
+		DrainGracePeriod:    durationFromEnv("DRAIN_GRACE_PERIOD", defaultDrainGrace),
+		GatewayAPI: provider.GatewayAPIOptions{
+			Namespace:        os.Getenv("K8S_NAMESPACE"),
+			GatewayName:      os.Getenv("GATEWAY_NAME"),
+			GatewayNamespace: os.Getenv("GATEWAY_NAMESPACE"),
+		},
+		ConsulAddress: os.Getenv("CONSUL_ADDR"),
+		ConsulToken:   os.Getenv("CONSUL_TOKEN"),
+
+		TrustedIPsFile:    os.Getenv("TRUSTED_IPS_FILE"),
+		TrustedIPsFetch:   os.Getenv("TRUSTED_IPS_FETCH") == "true",
+		TrustedIPsURL:     os.Getenv("TRUSTED_IPS_URL"),
+		TrustedIPsRefresh: durationFromEnv("TRUSTED_IPS_REFRESH", 0),
+
+		MetricsAddr: os.Getenv("METRICS_ADDR"),
+
+		ErrorReporting:        strings.ToLower(os.Getenv("ERROR_REPORTING")),
+		ErrorReportingProject: os.Getenv("ERROR_REPORTING_PROJECT"),
+		SentryDSN:             os.Getenv("SENTRY_DSN"),
+
+		RedactHeaders: listFromEnv("REDACT_HEADERS"),
+		RedactClaims:  listFromEnv("REDACT_CLAIMS"),
+		RedactEmails:  strings.ToLower(os.Getenv("REDACT_EMAILS")),
+
+		MonitoringExport:   monitoringExport,
+		MonitoringProject:  os.Getenv("MONITORING_PROJECT"),
+		MonitoringInterval: durationFromEnv("MONITORING_INTERVAL", 0),
+
+		StagingFile:      os.Getenv("STAGING_FILE"),
+		PromotionWebhook: os.Getenv("PROMOTION_WEBHOOK"),
+		PromotionProbe:   os.Getenv("PROMOTION_PROBE") == "true",
+		PromotionTimeout: durationFromEnv("PROMOTION_TIMEOUT", 0),
+
+		SigningKeyFile: os.Getenv("SIGNING_KEY_FILE"),
+		SigningKMSKey:  os.Getenv("SIGNING_KMS_KEY"),
+
+		ManifestFile: os.Getenv("MANIFEST_FILE"),
+		OutputSinks:  listFromEnv("OUTPUT_SINKS"),
+
+		LogFile:       os.Getenv("LOG_FILE"),
+		LogMaxSize:    intFromEnv("LOG_MAX_SIZE", defaultLogMaxSize),
+		LogMaxAge:     durationFromEnv("LOG_MAX_AGE", 0),
+		LogMaxBackups: intFromEnv("LOG_MAX_BACKUPS", defaultLogMaxBackups),
+	}
+}
+
+// subcommand returns the subcommand given as the first argument
+// (preflight, bootstrap, verify, preview or debug-bundle), or "" when running the provider itself
+func subcommand() string {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case preflightCommand, bootstrapCommand, verifyCommand, previewCommand, debugBundleCommand:
+			return os.Args[1]
+		}
+	}
+	return ""
+}
+
+// takeFlag removes every occurrence of flag from the arguments, so the
+// positional arguments keep their places, and reports whether it was given
+func takeFlag(flag string) bool {
+	args := os.Args[:1]
+	found := false
+	for _, arg := range os.Args[1:] {
+		if arg == flag {
+			found = true
+			continue
+		}
+		args = append(args, arg)
+	}
+	os.Args = args
+	return found
+}
+
+// commandArgs returns the positional arguments after any subcommand
+func commandArgs() []string {
+	if subcommand() != "" {
+		return os.Args[2:]
+	}
+	return os.Args[1:]
+}
+
+// intFromEnv reads a non-negative integer from the environment
+func intFromEnv(name string, defaultValue int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 0 {
+		log.Printf("Warning: Invalid %s %q, using default %d", name, value, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+// listFromEnv reads a comma-separated list from the environment.
+// Returns nil when unset so provider defaults apply.
+func listFromEnv(name string) []string {
+	value := os.Getenv(name)
+	if value == "" {
+		return nil
+	}
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
 	}
+	return items
+}
+
+// durationFromEnv reads a duration from the environment.
+// Accepts plain seconds ("30") or Go duration strings ("30s", "1m").
+func durationFromEnv(name string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if parsed, err := time.ParseDuration(value); err == nil {
+		return parsed
+	}
+	log.Printf("Warning: Invalid %s %q, using default %s", name, value, defaultValue)
+	return defaultValue
 }
 
 func getDir(path string) string {
@@ -225,20 +1071,199 @@ func getDir(path string) string {
 	return "."
 }
 
-func writeRoutes(outputFile string, config *provider.DynamicConfig) error {
+// writeHeader writes the routes file header comment, including the schema
+// version and the generation that produced the routes (0 = unknown), which
+// matches the generation field of that cycle's log lines
+func writeHeader(w io.Writer, generation uint64) {
+	fmt.Fprintf(w, "# Auto-generated Traefik routes from Cloud Run service labels\n")
+	fmt.Fprintf(w, "# Generated at: %s\n", time.Now().UTC().Format(time.RFC3339))
+	if generation > 0 {
+		fmt.Fprintf(w, "# Generation: %d\n", generation)
+	}
+	fmt.Fprintf(w, "# Environment: %s\n", os.Getenv("ENVIRONMENT"))
+	fmt.Fprintf(w, "%s\n", schema.Header())
+	fmt.Fprintf(w, "#\n")
+	fmt.Fprintf(w, "# This file is generated by traefik-cloudrun-provider\n")
+	fmt.Fprintf(w, "# Labels follow the same format as docker-compose.yml\n\n")
+}
+
+// writeSkippedSummary lists the Traefik-enabled services missing from (or
+// degraded in) the routes file, so a missing route can be explained at a glance
+func writeSkippedSummary(w io.Writer, skipped []provider.SkippedService) {
+	if len(skipped) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "# Skipped services (%d):\n", len(skipped))
+	for _, s := range skipped {
+		status := "skipped"
+		if s.Degraded {
+			status = "degraded"
+		}
+		line := fmt.Sprintf("#   %s (%s): %s, %s", s.Service, s.Project, status, s.Reason)
+		if s.Detail != "" {
+			line += ": " + strings.ReplaceAll(s.Detail, "\n", " ")
+		}
+		fmt.Fprintf(w, "%s\n", line)
+	}
+	fmt.Fprintf(w, "\n")
+}
+
+// migrateRoutesFile upgrades a routes file left by an older provider version
+// to the current schema, so Traefik keeps a compatible config until the first
+// discovery cycle replaces it
+func migrateRoutesFile(outputFile string) error {
+	data, err := os.ReadFile(outputFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read routes file: %w", err)
+	}
+
+	body, fromVersion, migrated, err := schema.Migrate(data)
+	if err != nil || !migrated {
+		return err
+	}
+
+	var buf bytes.Buffer
+	writeHeader(&buf, 0)
+	buf.Write(body)
+	if err := os.WriteFile(outputFile, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write migrated routes file: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "🔁 Migrated %s from schema version %d to %d\n", outputFile, fromVersion, schema.CurrentVersion)
+	return nil
+}
+
+// loadRoutes reads the routers of the routes files currently written for
+// config, or returns nil if there are none
+func loadRoutes(config *AppConfig) (*provider.DynamicConfig, error) {
+	files, err := outputFiles(config)
+	if err != nil {
+		return nil, err
+	}
+	var routes *provider.DynamicConfig
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read routes file: %w", err)
+		}
+		var fileRoutes provider.DynamicConfig
+		if err := yaml.Unmarshal(data, &fileRoutes); err != nil {
+			return nil, fmt.Errorf("failed to parse routes file %s: %w", file, err)
+		}
+		if routes == nil {
+			routes = provider.NewDynamicConfig()
+		}
+		for name, router := range fileRoutes.HTTP.Routers {
+			routes.HTTP.Routers[name] = router
+		}
+	}
+	return routes, nil
+}
+
+// publish writes a generated configuration to the output file, staged and
+// checked first when a promotion gate is configured, copies it to the output
+// sinks and writes the route manifest if one is configured
+func publish(config *AppConfig, gate *promotionGate, sinks *outputSinks, dynamicConfig *provider.DynamicConfig, report *provider.GenerationReport) error {
+	var err error
+	if gate != nil {
+		err = gate.write(config, dynamicConfig, report)
+	} else {
+		err = writeOutput(config, dynamicConfig, provider.Tenants(report.Discovered))
+	}
+	if err != nil {
+		return err
+	}
+	sinks.push(config)
+	if err := writeStdout(config); err != nil {
+		return err
+	}
+	return writeManifest(config.ManifestFile, dynamicConfig.Manifest(report.Discovered, report.GeneratedAt))
+}
+
+// writeStdout prints the routes files just written to stdout when the
+// output is "-". When several documents can follow each other (daemon mode,
+// tenant files), each starts with a YAML document separator.
+func writeStdout(config *AppConfig) error {
+	if !config.Stdout {
+		return nil
+	}
+	files, err := outputFiles(config)
+	if err != nil {
+		return err
+	}
+	separate := config.Mode == "daemon" || len(files) > 1
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		if separate {
+			data = append([]byte("---\n"), data...)
+		}
+		if _, err := os.Stdout.Write(data); err != nil {
+			return fmt.Errorf("failed to write routes to stdout: %w", err)
+		}
+	}
+	return nil
+}
+
+// writeManifest writes the route manifest as indented JSON, if path is set
+func writeManifest(path string, manifest *provider.RouteManifest) error {
+	if path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode route manifest: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write route manifest: %w", err)
+	}
+	return nil
+}
+
+// writeOutput writes the generated configuration in the configured output
+// format, signed if a signing key is configured
+func writeOutput(config *AppConfig, dynamicConfig *provider.DynamicConfig, tenants map[string]string) error {
+	var err error
+	switch {
+	case config.OutputFormat == outputFormatGatewayAPI:
+		err = writeGatewayAPI(config.OutputFile, dynamicConfig, config.GatewayAPI)
+	case config.MultiTenant:
+		err = writeTenantRoutes(config.OutputFile, dynamicConfig, tenants, skippedSummary(config, dynamicConfig))
+	default:
+		err = writeRoutes(config.OutputFile, dynamicConfig, skippedSummary(config, dynamicConfig))
+	}
+	if err != nil {
+		return err
+	}
+	return signOutput(config)
+}
+
+// skippedSummary returns the skipped services to list in the routes file
+func skippedSummary(config *AppConfig, dynamicConfig *provider.DynamicConfig) []provider.SkippedService {
+	if !config.SkippedSummary {
+		return nil
+	}
+	return dynamicConfig.Skipped()
+}
+
+// writeRoutes writes the configuration as a Traefik file provider config,
+// with a commented summary of the skipped services after the header
+func writeRoutes(outputFile string, config *provider.DynamicConfig, skipped []provider.SkippedService) error {
 	file, err := os.Create(outputFile)
 	if err != nil {
 		return fmt.Errorf("failed to create file: %w", err)
 	}
 	defer file.Close()
 
-	// Write header comment
-	fmt.Fprintf(file, "# Auto-generated Traefik routes from Cloud Run service labels\n")
-	fmt.Fprintf(file, "# Generated at: %s\n", time.Now().UTC().Format(time.RFC3339))
-	fmt.Fprintf(file, "# Environment: %s\n", os.Getenv("ENVIRONMENT"))
-	fmt.Fprintf(file, "#\n")
-	fmt.Fprintf(file, "# This file is generated by traefik-cloudrun-provider\n")
-	fmt.Fprintf(file, "# Labels follow the same format as docker-compose.yml\n\n")
+	writeHeader(file, config.Generation())
+	writeSkippedSummary(file, skipped)
 
 	// Write YAML
 	encoder := yaml.NewEncoder(file)
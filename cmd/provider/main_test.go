@@ -0,0 +1,65 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/provider"
+	run "google.golang.org/api/run/v1"
+)
+
+// emptyRunClient lists no services
+type emptyRunClient struct{}
+
+func (emptyRunClient) ListServices(string, string) (*run.ListServicesResponse, error) {
+	return &run.ListServicesResponse{}, nil
+}
+
+// staticTokens returns the same token for every audience
+type staticTokens string
+
+func (s staticTokens) GetToken(string) (string, error) { return string(s), nil }
+
+// newTestProvider creates a provider without connecting to GCP
+func newTestProvider(config *provider.Config) (*provider.Provider, error) {
+	return provider.NewWithClients(config, emptyRunClient{}, staticTokens("eyJfake"), nil)
+}
+
+func TestReloadConfig_CarriesOverState(t *testing.T) {
+	newReloadedProvider = newTestProvider
+	t.Cleanup(func() { newReloadedProvider = provider.New })
+
+	path := filepath.Join(t.TempDir(), "provider.yml")
+	if err := os.WriteFile(path, []byte("pollInterval: 1m\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	envConfig := &AppConfig{
+		ProjectIDs:   []string{"test-project"},
+		Region:       "us-central1",
+		PollInterval: 30 * time.Second,
+		ConfigFile:   path,
+	}
+	p, err := newTestProvider(newProviderConfig(envConfig))
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	if err := p.RunOnce(make(chan *provider.DynamicConfig, 1)); err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+	if err := p.SetLogLevel("DEBUG"); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, config := reloadConfig(p, envConfig, envConfig)
+	if reloaded == p || config.PollInterval != time.Minute {
+		t.Fatalf("Expected a provider for the reloaded configuration, got poll interval %s", config.PollInterval)
+	}
+	if reloaded.LogLevel() != "DEBUG" {
+		t.Errorf("Expected the runtime log level kept, got %s", reloaded.LogLevel())
+	}
+	if reloaded.LastReport() == nil || reloaded.LastReport() != p.LastReport() {
+		t.Error("Expected the last report carried over")
+	}
+}
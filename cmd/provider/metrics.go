@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/internal/gcp"
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/provider"
+)
+
+// Paths served on METRICS_ADDR
+const (
+	metricsPath      = "/metrics"
+	rotateTokensPath = "/rotate-tokens"
+	logLevelPath     = "/log-level"
+)
+
+// metricsServer serves Prometheus metrics for the current provider on
+// METRICS_ADDR, plus the token rotation trigger and the log level. The
+// daemon swaps the provider when the config file changes, which resets the
+// token counters.
+type metricsServer struct {
+	provider atomic.Pointer[provider.Provider]
+	rotate   chan<- []string // Token rotation requests for the daemon loop
+	levels   *logLevelSwitch
+}
+
+// startMetricsServer starts serving metrics for p in the background, or
+// returns nil when METRICS_ADDR is unset. Rotation requests are sent to
+// rotate, so they run on the daemon's generation goroutine; log level
+// changes are applied through levels right away.
+func startMetricsServer(config *AppConfig, p *provider.Provider, rotate chan<- []string, levels *logLevelSwitch) *metricsServer {
+	if config.MetricsAddr == "" {
+		return nil
+	}
+	m := &metricsServer{rotate: rotate, levels: levels}
+	m.provider.Store(p)
+
+	mux := http.NewServeMux()
+	mux.Handle(metricsPath, m)
+	mux.HandleFunc(rotateTokensPath, m.rotateTokens)
+	mux.HandleFunc(logLevelPath, m.logLevel)
+	server := &http.Server{Addr: config.MetricsAddr, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		if err := server.ListenAndServe(); err != nil {
+			log.Printf("Warning: metrics server stopped: %v", err)
+		}
+	}()
+	fmt.Fprintf(os.Stderr, "📈 Serving metrics on %s%s\n", config.MetricsAddr, metricsPath)
+	return m
+}
+
+// set switches the metrics to a new provider
+func (m *metricsServer) set(p *provider.Provider) {
+	if m != nil {
+		m.provider.Store(p)
+	}
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format
+func (m *metricsServer) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	if stats, ok := m.provider.Load().TokenStats(); ok {
+		writeTokenMetrics(w, stats)
+	}
+}
+
+// rotateTokens queues a forced token rotation. POST with no parameters
+// rotates every token; repeated audience parameters limit it to those
+// audiences.
+func (m *metricsServer) rotateTokens(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	select {
+	case m.rotate <- r.URL.Query()["audience"]:
+		w.WriteHeader(http.StatusAccepted)
+	case <-r.Context().Done():
+	}
+}
+
+// logLevel returns the current log level on GET and sets it on PUT or
+// POST, from the level parameter or the request body (e.g. DEBUG)
+func (m *metricsServer) logLevel(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		level := r.URL.Query().Get("level")
+		if level == "" {
+			body, err := io.ReadAll(io.LimitReader(r.Body, 64))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			level = strings.TrimSpace(string(body))
+		}
+		if err := m.levels.change(level); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, PUT, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	fmt.Fprintln(w, m.levels.current())
+}
+
+// writeTokenMetrics writes the token cache and fetch counters
+func writeTokenMetrics(w io.Writer, stats gcp.TokenStats) {
+	writeMetric(w, "cloudrun_provider_token_cache_hits_total", "counter", "Token requests answered from the cache.", float64(stats.Hits))
+	writeMetric(w, "cloudrun_provider_token_cache_misses_total", "counter", "Token requests that needed a fetch.", float64(stats.Misses))
+	writeMetric(w, "cloudrun_provider_tokens_cached", "gauge", "Identity tokens in the cache.", float64(stats.Cached))
+	writeMetric(w, "cloudrun_provider_tokens_expired", "gauge", "Cached identity tokens past their expiry.", float64(stats.Expired))
+
+	writeHistogram(w, "cloudrun_provider_token_fetch_duration_seconds", "Duration of identity token fetches.", stats.FetchLatency)
+	writeHistogram(w, "cloudrun_provider_token_expiry_seconds", "Remaining lifetime of unexpired cached tokens.", stats.Expiry)
+
+	fmt.Fprintf(w, "# HELP cloudrun_provider_token_fetch_failures_total Failed identity token fetches per audience.\n")
+	fmt.Fprintf(w, "# TYPE cloudrun_provider_token_fetch_failures_total counter\n")
+	audiences := make([]string, 0, len(stats.Failures))
+	for audience := range stats.Failures {
+		audiences = append(audiences, audience)
+	}
+	sort.Strings(audiences)
+	for _, audience := range audiences {
+		fmt.Fprintf(w, "cloudrun_provider_token_fetch_failures_total{audience=%s} %d\n", strconv.Quote(audience), stats.Failures[audience])
+	}
+}
+
+// writeMetric writes a single-sample metric
+func writeMetric(w io.Writer, name, kind, help string, value float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %s\n", name, help, name, kind, name, formatFloat(value))
+}
+
+// writeHistogram writes a histogram with cumulative buckets in seconds
+func writeHistogram(w io.Writer, name, help string, h gcp.Histogram) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+	var cumulative uint64
+	for i, bound := range h.Bounds {
+		cumulative += h.Counts[i]
+		fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", name, formatFloat(bound.Seconds()), cumulative)
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", name, h.Count)
+	fmt.Fprintf(w, "%s_sum %s\n%s_count %d\n", name, formatFloat(h.Sum.Seconds()), name, h.Count)
+}
+
+// formatFloat formats a sample value the shortest way
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/internal/gcp"
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/provider"
+	monitoring "google.golang.org/api/monitoring/v3"
+)
+
+// monitoringExportGCP exports the metrics to Cloud Monitoring
+// (MONITORING_EXPORT=gcp)
+const monitoringExportGCP = "gcp"
+
+// defaultMonitoringInterval is how often metrics are exported when
+// MONITORING_INTERVAL is unset. Cloud Monitoring rejects points written
+// more often than every 5s per time series.
+const defaultMonitoringInterval = time.Minute
+
+// Cloud Monitoring custom metric types are the Prometheus names under this
+// prefix, e.g. custom.googleapis.com/cloudrun_provider_token_cache_hits_total
+const monitoringMetricPrefix = "custom.googleapis.com/"
+
+// maxTimeSeriesPerRequest is Cloud Monitoring's limit per CreateTimeSeries call
+const maxTimeSeriesPerRequest = 200
+
+// monitoringExporter writes the counters served on METRICS_ADDR to Cloud
+// Monitoring as custom metrics of the global resource, for teams without a
+// Prometheus stack. Counters are cumulative since the exporter started or
+// the provider was last swapped (which resets them).
+type monitoringExporter struct {
+	service  *monitoring.Service
+	project  string
+	interval time.Duration
+
+	mu       sync.Mutex
+	provider *provider.Provider
+	start    time.Time
+}
+
+// startMonitoringExporter starts exporting the metrics of p in the
+// background, or returns nil when MONITORING_EXPORT is unset
+func startMonitoringExporter(config *AppConfig, p *provider.Provider) (*monitoringExporter, error) {
+	if config.MonitoringExport == "" {
+		return nil, nil
+	}
+
+	credentials, err := gcp.LoadCredentials(config.CredentialsFile, config.CredentialsJSON)
+	if err != nil {
+		return nil, err
+	}
+	service, err := monitoring.NewService(context.Background(), credentials.ClientOptions()...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Cloud Monitoring client: %w", err)
+	}
+
+	m := &monitoringExporter{
+		service:  service,
+		project:  config.MonitoringProject,
+		interval: config.MonitoringInterval,
+		provider: p,
+		start:    time.Now(),
+	}
+	if m.project == "" {
+		m.project = config.ProjectIDs[0]
+	}
+	if m.interval <= 0 {
+		m.interval = defaultMonitoringInterval
+	}
+
+	go func() {
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			if err := m.export(now); err != nil {
+				log.Printf("Warning: Cloud Monitoring export failed: %v", err)
+			}
+		}
+	}()
+	fmt.Fprintf(os.Stderr, "📈 Exporting metrics to Cloud Monitoring in project %s every %s\n", m.project, m.interval)
+	return m, nil
+}
+
+// set switches the exporter to a new provider, restarting the cumulative
+// counters
+func (m *monitoringExporter) set(p *provider.Provider) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.provider, m.start = p, time.Now()
+}
+
+// export writes the current metric values
+func (m *monitoringExporter) export(now time.Time) error {
+	m.mu.Lock()
+	p, start := m.provider, m.start
+	m.mu.Unlock()
+
+	stats, ok := p.TokenStats()
+	if !ok {
+		return nil
+	}
+	series := tokenTimeSeries(m.project, stats, start, now)
+	for len(series) > 0 {
+		n := min(len(series), maxTimeSeriesPerRequest)
+		request := &monitoring.CreateTimeSeriesRequest{TimeSeries: series[:n]}
+		if _, err := m.service.Projects.TimeSeries.Create("projects/"+m.project, request).Do(); err != nil {
+			return fmt.Errorf("failed to write time series: %w", err)
+		}
+		series = series[n:]
+	}
+	return nil
+}
+
+// tokenTimeSeries converts the token metrics of writeTokenMetrics into
+// Cloud Monitoring time series. Cumulative series start at start.
+func tokenTimeSeries(project string, stats gcp.TokenStats, start, now time.Time) []*monitoring.TimeSeries {
+	resource := &monitoring.MonitoredResource{Type: "global", Labels: map[string]string{"project_id": project}}
+	cumulative := &monitoring.TimeInterval{StartTime: start.UTC().Format(time.RFC3339Nano), EndTime: now.UTC().Format(time.RFC3339Nano)}
+	gauge := &monitoring.TimeInterval{EndTime: cumulative.EndTime}
+
+	timeSeries := func(name string, labels map[string]string, kind string, value *monitoring.TypedValue) *monitoring.TimeSeries {
+		interval, valueType := gauge, "INT64"
+		if kind == "CUMULATIVE" {
+			interval = cumulative
+		}
+		if value.DistributionValue != nil {
+			valueType = "DISTRIBUTION"
+		}
+		return &monitoring.TimeSeries{
+			Metric:     &monitoring.Metric{Type: monitoringMetricPrefix + name, Labels: labels},
+			Resource:   resource,
+			MetricKind: kind,
+			ValueType:  valueType,
+			Points:     []*monitoring.Point{{Interval: interval, Value: value}},
+		}
+	}
+	int64Value := func(v uint64) *monitoring.TypedValue {
+		n := int64(v)
+		return &monitoring.TypedValue{Int64Value: &n}
+	}
+
+	series := []*monitoring.TimeSeries{
+		timeSeries("cloudrun_provider_token_cache_hits_total", nil, "CUMULATIVE", int64Value(stats.Hits)),
+		timeSeries("cloudrun_provider_token_cache_misses_total", nil, "CUMULATIVE", int64Value(stats.Misses)),
+		timeSeries("cloudrun_provider_tokens_cached", nil, "GAUGE", int64Value(uint64(stats.Cached))),
+		timeSeries("cloudrun_provider_tokens_expired", nil, "GAUGE", int64Value(uint64(stats.Expired))),
+		timeSeries("cloudrun_provider_token_fetch_duration_seconds", nil, "CUMULATIVE", distributionValue(stats.FetchLatency)),
+		timeSeries("cloudrun_provider_token_expiry_seconds", nil, "GAUGE", distributionValue(stats.Expiry)),
+	}
+
+	audiences := make([]string, 0, len(stats.Failures))
+	for audience := range stats.Failures {
+		audiences = append(audiences, audience)
+	}
+	sort.Strings(audiences)
+	for _, audience := range audiences {
+		series = append(series, timeSeries("cloudrun_provider_token_fetch_failures_total",
+			map[string]string{"audience": audience}, "CUMULATIVE", int64Value(stats.Failures[audience])))
+	}
+	return series
+}
+
+// distributionValue converts a histogram into a distribution with explicit
+// bucket bounds in seconds
+func distributionValue(h gcp.Histogram) *monitoring.TypedValue {
+	bounds := make([]float64, len(h.Bounds))
+	for i, bound := range h.Bounds {
+		bounds[i] = bound.Seconds()
+	}
+	counts := make([]int64, len(h.Counts))
+	for i, count := range h.Counts {
+		counts[i] = int64(count)
+	}
+	var mean float64
+	if h.Count > 0 {
+		mean = h.Sum.Seconds() / float64(h.Count)
+	}
+	return &monitoring.TypedValue{DistributionValue: &monitoring.Distribution{
+		Count:         int64(h.Count),
+		Mean:          mean,
+		BucketOptions: &monitoring.BucketOptions{ExplicitBuckets: &monitoring.Explicit{Bounds: bounds}},
+		BucketCounts:  counts,
+	}}
+}
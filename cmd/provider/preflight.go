@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/internal/gcp"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/googleapi"
+	run "google.golang.org/api/run/v1"
+)
+
+// preflightCommand is the subcommand that runs the preflight checks
+const preflightCommand = "preflight"
+
+// preflightAudience is used to test token minting when no service URL is known
+const preflightAudience = "https://preflight.run.app"
+
+// preflightCheck is one line of the preflight report
+type preflightCheck struct {
+	name   string
+	ok     bool
+	detail string
+	fix    string // Suggested remedy when the check fails
+}
+
+// runPreflight verifies credentials, Cloud Run API access, token minting and
+// output path writability, and prints an actionable report. The checks use
+// the identity and endpoints the provider would (PROVIDER_CREDENTIALS_*,
+// CLOUDRUN_API_ENDPOINT, GCE_METADATA_HOST, IMPERSONATE_SERVICE_ACCOUNT).
+// Returns the process exit code: 0 if all checks pass, 1 otherwise.
+func runPreflight(config *AppConfig) int {
+	ctx := context.Background()
+	var checks []preflightCheck
+
+	credentials, err := gcp.LoadCredentials(config.CredentialsFile, config.CredentialsJSON)
+	if err != nil {
+		checks = append(checks, preflightCheck{
+			name:   "Credentials",
+			detail: err.Error(),
+			fix:    "Check PROVIDER_CREDENTIALS_FILE or PROVIDER_CREDENTIALS_JSON",
+		})
+		return printPreflight(checks)
+	}
+	checks = append(checks, checkCredentials(ctx, credentials))
+
+	audience := preflightAudience
+	runService, err := run.NewService(ctx, gcp.APIClientOptions(config.RunAPIEndpoint, credentials)...)
+	if err != nil {
+		checks = append(checks, preflightCheck{
+			name:   "Cloud Run client",
+			detail: err.Error(),
+			fix:    "Fix credentials first (see above)",
+		})
+	} else {
+		for _, projectID := range config.ProjectIDs {
+			check, serviceURL := checkProject(runService, projectID, config.Region)
+			checks = append(checks, check)
+			if audience == preflightAudience && serviceURL != "" {
+				audience = serviceURL
+			}
+		}
+	}
+
+	tokens := gcp.NewTokenManagerWithCredentials(credentials)
+	checks = append(checks, checkTokenMinting(tokens, audience), checkOutputWritable(config.OutputFile))
+	return printPreflight(checks)
+}
+
+// printPreflight prints the report and returns the exit code
+func printPreflight(checks []preflightCheck) int {
+	fmt.Fprintf(os.Stderr, "🩺 Preflight checks\n")
+	failed := 0
+	for _, check := range checks {
+		if check.ok {
+			fmt.Fprintf(os.Stderr, "   ✅ %s: %s\n", check.name, check.detail)
+			continue
+		}
+		failed++
+		fmt.Fprintf(os.Stderr, "   ❌ %s: %s\n", check.name, check.detail)
+		if check.fix != "" {
+			fmt.Fprintf(os.Stderr, "      → %s\n", check.fix)
+		}
+	}
+
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "\n❌ %d of %d checks failed\n", failed, len(checks))
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "\n✅ All %d checks passed\n", len(checks))
+	return 0
+}
+
+// checkCredentials reports which identity the provider uses: the explicit
+// credentials, the metadata server (GCE_METADATA_HOST) or ADC
+func checkCredentials(ctx context.Context, credentials *gcp.Credentials) preflightCheck {
+	check := preflightCheck{name: "Credentials"}
+	if credentials != nil {
+		check.ok = true
+		check.detail = fmt.Sprintf("using explicit %s credentials (PROVIDER_CREDENTIALS_FILE or PROVIDER_CREDENTIALS_JSON)", credentials.Type)
+		return check
+	}
+
+	host := gcp.MetadataHost()
+	client := &http.Client{Timeout: 2 * time.Second}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+host+"/computeMetadata/v1/project/project-id", nil)
+	if err == nil {
+		req.Header.Set("Metadata-Flavor", "Google")
+		if resp, err := client.Do(req); err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				check.ok = true
+				check.detail = fmt.Sprintf("metadata server available at %s", host)
+				return check
+			}
+		}
+	}
+
+	if _, err := google.FindDefaultCredentials(ctx, run.CloudPlatformScope); err != nil {
+		check.detail = fmt.Sprintf("no metadata server and no ADC: %v", err)
+		check.fix = "Run 'gcloud auth application-default login' and set CLOUDRUN_PROVIDER_DEV_MODE=true for local use"
+		return check
+	}
+
+	check.ok = true
+	check.detail = "using Application Default Credentials (metadata server not available)"
+	if account := os.Getenv("IMPERSONATE_SERVICE_ACCOUNT"); account != "" {
+		check.detail += ", impersonating " + account
+	}
+	return check
+}
+
+// checkProject lists Cloud Run services in a project to verify the API is
+// enabled and run.services.list is granted. Also returns the URL of a
+// service, if any, to use as the audience for the token check.
+func checkProject(runService *run.APIService, projectID, region string) (preflightCheck, string) {
+	check := preflightCheck{name: fmt.Sprintf("Project %s", projectID)}
+
+	parent := fmt.Sprintf("projects/%s/locations/%s", projectID, region)
+	resp, err := runService.Projects.Locations.Services.List(parent).Do()
+	if err != nil {
+		check.detail = err.Error()
+		var apiErr *googleapi.Error
+		switch {
+		case errors.As(err, &apiErr) && apiErr.Code == http.StatusForbidden &&
+			(strings.Contains(apiErr.Message, "has not been used") || strings.Contains(apiErr.Message, "disabled")):
+			check.detail = "Cloud Run Admin API is not enabled"
+			check.fix = fmt.Sprintf("gcloud services enable run.googleapis.com --project=%s", projectID)
+		case errors.As(err, &apiErr) && apiErr.Code == http.StatusForbidden:
+			check.detail = "permission run.services.list denied"
+			check.fix = fmt.Sprintf("Grant roles/run.viewer on project %s to the provider's service account", projectID)
+		case errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound:
+			check.fix = fmt.Sprintf("Check the project ID and REGION (%s)", region)
+		}
+		return check, ""
+	}
+
+	enabled := 0
+	serviceURL := ""
+	for _, svc := range resp.Items {
+		if svc.Metadata != nil && svc.Metadata.Labels["traefik_enable"] == "true" {
+			enabled++
+		}
+		if serviceURL == "" && svc.Status != nil {
+			serviceURL = svc.Status.Url
+		}
+	}
+
+	check.ok = true
+	check.detail = fmt.Sprintf("run.services.list OK (%d services, %d with traefik_enable=true)", len(resp.Items), enabled)
+	return check, serviceURL
+}
+
+// checkTokenMinting verifies an identity token can be minted for audience
+// with the provider's token manager
+func checkTokenMinting(tokens *gcp.TokenManager, audience string) preflightCheck {
+	check := preflightCheck{name: "Identity token"}
+
+	token, err := tokens.GetToken(audience)
+	if err != nil {
+		check.detail = err.Error()
+		switch {
+		case errors.Is(err, gcp.ErrADCMissing) && os.Getenv("IMPERSONATE_SERVICE_ACCOUNT") != "":
+			check.fix = "Grant your account roles/iam.serviceAccountTokenCreator on IMPERSONATE_SERVICE_ACCOUNT"
+		case errors.Is(err, gcp.ErrADCMissing):
+			check.fix = "Run 'gcloud auth application-default login'; user credentials also need IMPERSONATE_SERVICE_ACCOUNT"
+		case errors.Is(err, gcp.ErrMetadataUnavailable):
+			check.fix = "Set CLOUDRUN_PROVIDER_DEV_MODE=true to use ADC outside GCP"
+		}
+		return check
+	}
+
+	check.ok = true
+	check.detail = fmt.Sprintf("minted token for %s via %s (%d bytes)", audience, tokens.CredentialPath(), len(token))
+	return check
+}
+
+// checkOutputWritable verifies the routes file's directory exists (or can be
+// created) and is writable
+func checkOutputWritable(outputFile string) preflightCheck {
+	check := preflightCheck{name: "Output path"}
+	dir := getDir(outputFile)
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		check.detail = fmt.Sprintf("cannot create %s: %v", dir, err)
+		check.fix = "Mount a writable volume at the output directory or pass a different output path"
+		return check
+	}
+
+	tmp, err := os.CreateTemp(dir, ".preflight-*")
+	if err != nil {
+		check.detail = fmt.Sprintf("%s is not writable: %v", dir, err)
+		check.fix = "Mount a writable volume at the output directory or pass a different output path"
+		return check
+	}
+	tmp.Close()
+	os.Remove(tmp.Name())
+
+	check.ok = true
+	check.detail = fmt.Sprintf("%s is writable", outputFile)
+	return check
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/tests/fake-run-api/fakerunapi"
+)
+
+// newFakeGCP serves a fake Cloud Run API and metadata server, with
+// GCE_METADATA_HOST pointed at it
+func newFakeGCP(t *testing.T) (*fakerunapi.Server, string) {
+	t.Helper()
+	fake := fakerunapi.New(&fakerunapi.Fixture{Services: []fakerunapi.Service{{
+		Project: "fake-project",
+		Region:  "us-central1",
+		Name:    "frontend",
+		Labels:  map[string]string{"traefik_enable": "true"},
+	}}})
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	t.Setenv("GCE_METADATA_HOST", strings.TrimPrefix(server.URL, "http://"))
+	t.Setenv("METADATA_RETRIES", "0")
+	return fake, server.URL
+}
+
+func TestRunPreflight_UsesProviderEndpoints(t *testing.T) {
+	fake, url := newFakeGCP(t)
+	config := &AppConfig{
+		ProjectIDs:     []string{"fake-project"},
+		Region:         "us-central1",
+		RunAPIEndpoint: url + "/",
+		OutputFile:     filepath.Join(t.TempDir(), "routes.yml"),
+	}
+	if code := runPreflight(config); code != 0 {
+		t.Fatalf("Expected all checks to pass, got exit code %d", code)
+	}
+	if fake.Requests("list") != 1 {
+		t.Errorf("Expected the project listed through CLOUDRUN_API_ENDPOINT, got %d list requests", fake.Requests("list"))
+	}
+	if fake.Requests("identity") != 1 {
+		t.Errorf("Expected a token minted by the GCE_METADATA_HOST server, got %d identity requests", fake.Requests("identity"))
+	}
+}
+
+func TestRunPreflight_InvalidCredentials(t *testing.T) {
+	fake, url := newFakeGCP(t)
+	config := &AppConfig{
+		ProjectIDs:      []string{"fake-project"},
+		Region:          "us-central1",
+		RunAPIEndpoint:  url + "/",
+		OutputFile:      filepath.Join(t.TempDir(), "routes.yml"),
+		CredentialsFile: filepath.Join(t.TempDir(), "missing.json"),
+	}
+	if code := runPreflight(config); code != 1 {
+		t.Errorf("Expected failure for unreadable credentials, got exit code %d", code)
+	}
+	if fake.Requests("list") != 0 || fake.Requests("identity") != 0 {
+		t.Error("Expected no checks with the ambient identity when the configured credentials can't be loaded")
+	}
+}
+
+func TestCheckCredentials_MetadataHost(t *testing.T) {
+	_, url := newFakeGCP(t)
+	check := checkCredentials(context.Background(), nil)
+	if !check.ok || !strings.Contains(check.detail, strings.TrimPrefix(url, "http://")) {
+		t.Errorf("Expected the metadata server at GCE_METADATA_HOST, got %+v", check)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/provider"
+	"gopkg.in/yaml.v3"
+)
+
+// previewCommand is the subcommand that prints the generated routes with
+// their tokens sanitized
+const previewCommand = "preview"
+
+// runPreview generates the routes once and prints them to stdout with every
+// token and email replaced by its log-safe preview, so the configuration can
+// be shared without hand-redacting routes.yml. Nothing is written. Returns
+// the process exit code.
+func runPreview(config *AppConfig) int {
+	providerConfig := newProviderConfig(config)
+	providerConfig.LogOutput = os.Stderr
+	p, err := provider.New(providerConfig)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to create provider: %v\n", err)
+		return 1
+	}
+	refreshTrustedIPs(config, newRangesFetcher(config))
+
+	configChan := make(chan *provider.DynamicConfig, 1)
+	if err := p.RunOnce(configChan); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to generate config: %v\n", err)
+		return 1
+	}
+	var dynamicConfig *provider.DynamicConfig
+	select {
+	case dynamicConfig = <-configChan:
+	case <-time.After(60 * time.Second):
+		fmt.Fprintf(os.Stderr, "❌ Timeout waiting for configuration\n")
+		return 1
+	}
+
+	fmt.Fprintf(os.Stdout, "# Sanitized preview: tokens are truncated, this file won't work in Traefik\n")
+	writeHeader(os.Stdout, dynamicConfig.Generation())
+	writeSkippedSummary(os.Stdout, dynamicConfig.Skipped())
+	encoder := yaml.NewEncoder(os.Stdout)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(dynamicConfig.Sanitized()); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to encode YAML: %v\n", err)
+		return 1
+	}
+	if err := encoder.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to encode YAML: %v\n", err)
+		return 1
+	}
+	return 0
+}
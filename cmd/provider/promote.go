@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/provider"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultPromotionTimeout bounds each promotion webhook call
+const defaultPromotionTimeout = 10 * time.Second
+
+// promotionGate stages each generated configuration and only promotes it to
+// the live output file once the promotion webhook and/or the self-test of
+// the changed routers approve it. It remembers the live configuration, so
+// it is kept across daemon cycles.
+type promotionGate struct {
+	stagingFile string
+	webhook     string
+	probe       bool
+	client      *http.Client
+
+	live *provider.DynamicConfig // Last promoted configuration (nil before the first)
+}
+
+// newPromotionGate returns the gate, or nil when neither PROMOTION_WEBHOOK
+// nor PROMOTION_PROBE is set
+func newPromotionGate(config *AppConfig) *promotionGate {
+	if config.PromotionWebhook == "" && !config.PromotionProbe {
+		return nil
+	}
+	stagingFile := config.StagingFile
+	if stagingFile == "" {
+		stagingFile = config.OutputFile + ".staging"
+	}
+	timeout := config.PromotionTimeout
+	if timeout <= 0 {
+		timeout = defaultPromotionTimeout
+	}
+	return &promotionGate{
+		stagingFile: stagingFile,
+		webhook:     config.PromotionWebhook,
+		probe:       config.PromotionProbe,
+		client:      &http.Client{Timeout: timeout},
+	}
+}
+
+// promotionRequest is the JSON body POSTed to the promotion webhook
+type promotionRequest struct {
+	StagingFile    string   `json:"stagingFile"`
+	ChangedRouters []string `json:"changedRouters"`
+	RemovedRouters []string `json:"removedRouters"`
+	Routes         string   `json:"routes"` // The staged configuration as YAML, credentials redacted
+}
+
+// write writes the configuration to the staging file, checks it and, if
+// approved, writes it to the live output file. A rejected configuration
+// leaves the live routes untouched and returns an error saying why.
+func (g *promotionGate) write(config *AppConfig, dynamicConfig *provider.DynamicConfig, report *provider.GenerationReport) error {
+	staged := *config
+	staged.OutputFile = g.stagingFile
+	if err := writeOutput(&staged, dynamicConfig, provider.Tenants(report.Discovered)); err != nil {
+		return fmt.Errorf("failed to write staging file: %w", err)
+	}
+
+	changed, removed := changedRouters(g.live, dynamicConfig)
+	if len(changed) > 0 || len(removed) > 0 {
+		if err := g.check(dynamicConfig, changed, removed, report); err != nil {
+			return fmt.Errorf("not promoting %s: %w", g.stagingFile, err)
+		}
+		fmt.Fprintf(os.Stderr, "🚦 Promoting %s: %d routers changed, %d removed\n", g.stagingFile, len(changed), len(removed))
+	}
+
+	if err := writeOutput(config, dynamicConfig, provider.Tenants(report.Discovered)); err != nil {
+		return err
+	}
+	g.live = dynamicConfig
+	return nil
+}
+
+// check runs the built-in probe and the webhook against the changed routers
+// of dynamicConfig
+func (g *promotionGate) check(dynamicConfig *provider.DynamicConfig, changed, removed []string, report *provider.GenerationReport) error {
+	if g.probe {
+		if failed := failedRouters(changed, report.FailedProbes()); len(failed) > 0 {
+			return fmt.Errorf("self-test failed for changed routers %s", strings.Join(failed, ", "))
+		}
+	}
+	if g.webhook == "" {
+		return nil
+	}
+
+	// The webhook gets the sanitized configuration, never the auth tokens
+	// in the staging file
+	routes, err := yaml.Marshal(dynamicConfig.Sanitized())
+	if err != nil {
+		return fmt.Errorf("failed to encode staged configuration: %w", err)
+	}
+	body, err := json.Marshal(promotionRequest{
+		StagingFile:    g.stagingFile,
+		ChangedRouters: changed,
+		RemovedRouters: removed,
+		Routes:         string(routes),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode promotion request: %w", err)
+	}
+	resp, err := g.client.Post(g.webhook, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("promotion webhook failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		reason, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("promotion webhook rejected the configuration (%d): %s", resp.StatusCode, strings.TrimSpace(string(reason)))
+	}
+	return nil
+}
+
+// changedRouters returns the routers of next that are new or differ from
+// live (in the router itself, the service it uses or the middlewares it
+// references), and the routers of live that next no longer has. Auth
+// tokens aren't compared, so a token refresh alone changes no router.
+func changedRouters(live, next *provider.DynamicConfig) (changed, removed []string) {
+	if live == nil {
+		live = provider.NewDynamicConfig()
+	}
+	liveMiddlewares := live.WithoutCredentials().HTTP.Middlewares
+	nextMiddlewares := next.WithoutCredentials().HTTP.Middlewares
+	for name, router := range next.HTTP.Routers {
+		old, ok := live.HTTP.Routers[name]
+		if !ok || !reflect.DeepEqual(old, router) ||
+			!reflect.DeepEqual(live.HTTP.Services[router.Service], next.HTTP.Services[router.Service]) {
+			changed = append(changed, name)
+			continue
+		}
+		for _, middleware := range router.Middlewares {
+			if !reflect.DeepEqual(liveMiddlewares[middleware], nextMiddlewares[middleware]) {
+				changed = append(changed, name)
+				break
+			}
+		}
+	}
+	for name := range live.HTTP.Routers {
+		if _, ok := next.HTTP.Routers[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(changed)
+	sort.Strings(removed)
+	return changed, removed
+}
+
+// failedRouters returns the routers among changed whose backend failed its
+// self-test probe
+func failedRouters(changed []string, failed []provider.ProbeResult) []string {
+	isChanged := make(map[string]bool, len(changed))
+	for _, name := range changed {
+		isChanged[name] = true
+	}
+	var routers []string
+	for _, probe := range failed {
+		for _, name := range probe.Routers {
+			if isChanged[name] {
+				routers = append(routers, name)
+			}
+		}
+	}
+	sort.Strings(routers)
+	return routers
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"math/rand"
+	"time"
+)
+
+// pollSchedule decides when the daemon polls next. Jitter and wall-clock
+// alignment keep provider instances across environments from polling (and
+// bursting the Admin API) in lockstep.
+type pollSchedule struct {
+	interval time.Duration
+	jitter   time.Duration // Max random delay added to each poll (0 = none)
+	align    bool          // Poll at multiples of interval, e.g. :00 and :30 for 30m
+}
+
+// newPollSchedule returns the schedule of POLL_INTERVAL, POLL_JITTER and
+// POLL_ALIGN
+func newPollSchedule(config *AppConfig) pollSchedule {
+	return pollSchedule{interval: config.PollInterval, jitter: config.PollJitter, align: config.PollAlign}
+}
+
+// delay returns how long after now the next poll runs
+func (s pollSchedule) delay(now time.Time) time.Duration {
+	delay := s.interval
+	if s.align {
+		// Truncate works from the zero time, so intervals dividing an hour
+		// line up with the wall clock (in UTC) on every instance
+		delay = now.Truncate(s.interval).Add(s.interval).Sub(now)
+	}
+	if s.jitter > 0 {
+		delay += time.Duration(rand.Int63n(int64(s.jitter))) //nolint:gosec // jitter doesn't need crypto randomness
+	}
+	return delay
+}
+
+// String describes the schedule for the startup banner
+func (s pollSchedule) String() string {
+	description := "every " + s.interval.String()
+	if s.align {
+		description += ", aligned to the clock"
+	}
+	if s.jitter > 0 {
+		description += ", up to " + s.jitter.String() + " jitter"
+	}
+	return description
+}
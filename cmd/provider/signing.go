@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/internal/gcp"
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/internal/routesig"
+)
+
+// verifyCommand is the subcommand that checks the signature of routes files
+const verifyCommand = "verify"
+
+// newSigner returns the routes file signer configured by SIGNING_KEY_FILE
+// or SIGNING_KMS_KEY, or nil when signing is off
+func newSigner(config *AppConfig) (routesig.Signer, error) {
+	switch {
+	case config.SigningKeyFile != "" && config.SigningKMSKey != "":
+		return nil, fmt.Errorf("SIGNING_KEY_FILE and SIGNING_KMS_KEY are mutually exclusive")
+	case config.SigningKeyFile != "":
+		key, err := os.ReadFile(config.SigningKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read signing key: %w", err)
+		}
+		return routesig.NewHMAC([]byte(strings.TrimSpace(string(key))))
+	case config.SigningKMSKey != "":
+		credentials, err := gcp.LoadCredentials(config.CredentialsFile, config.CredentialsJSON)
+		if err != nil {
+			return nil, err
+		}
+		return routesig.NewKMS(context.Background(), config.SigningKMSKey, credentials.ClientOptions()...)
+	}
+	return nil, nil
+}
+
+// outputFiles returns the routes files written for config: the output file
+// and, with MULTI_TENANT, the tenant files next to it
+func outputFiles(config *AppConfig) ([]string, error) {
+	files := []string{config.OutputFile}
+	if config.MultiTenant && config.OutputFormat != outputFormatGatewayAPI {
+		tenants, err := tenantFiles(config.OutputFile)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, tenants...)
+	}
+	return files, nil
+}
+
+// signOutput embeds a signature in every routes file written for config
+func signOutput(config *AppConfig) error {
+	signer, err := newSigner(config)
+	if err != nil || signer == nil {
+		return err
+	}
+	files, err := outputFiles(config)
+	if err != nil {
+		return err
+	}
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s for signing: %w", path, err)
+		}
+		signed, err := routesig.Sign(data, signer)
+		if err != nil {
+			return fmt.Errorf("failed to sign %s: %w", path, err)
+		}
+		if err := os.WriteFile(path, signed, 0644); err != nil {
+			return fmt.Errorf("failed to write signed %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// runVerify checks the signatures of the output file and, with MULTI_TENANT,
+// the tenant files, and prints one line per file.
+// Returns the process exit code: 0 if all are intact, 1 otherwise.
+func runVerify(config *AppConfig) int {
+	signer, err := newSigner(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return 1
+	}
+	if signer == nil {
+		fmt.Fprintf(os.Stderr, "❌ Set SIGNING_KEY_FILE or SIGNING_KMS_KEY to verify routes files\n")
+		return 1
+	}
+	files, err := outputFiles(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return 1
+	}
+
+	code := 0
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err == nil {
+			err = routesig.Verify(data, signer)
+		}
+		switch {
+		case err == nil:
+			fmt.Fprintf(os.Stderr, "✅ %s: signature valid\n", path)
+		case errors.Is(err, routesig.ErrTampered):
+			fmt.Fprintf(os.Stderr, "❌ %s: modified since it was generated\n", path)
+			code = 1
+		default:
+			fmt.Fprintf(os.Stderr, "❌ %s: %v\n", path, err)
+			code = 1
+		}
+	}
+	return code
+}
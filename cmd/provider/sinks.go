@@ -0,0 +1,390 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/internal/gcp"
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/internal/kube"
+	storage "google.golang.org/api/storage/v1"
+)
+
+// sinkTimeout bounds each push to an output sink
+const sinkTimeout = 30 * time.Second
+
+// sink is an extra destination the routes files are copied to
+type sink interface {
+	// put writes the file named name (its base name) with data
+	put(ctx context.Context, name string, data []byte) error
+	// all reports whether the sink takes every routes file (tenant files
+	// too) or only the output file
+	all() bool
+	// prune removes the routes files (by base name) for which stale returns
+	// true, e.g. the file of a tenant that is gone. Only called when all.
+	prune(ctx context.Context, stale func(name string) bool) error
+}
+
+// batchSink is a sink that writes every routes file and removes the stale
+// ones in a single update, instead of put per file and prune
+type batchSink interface {
+	putAll(ctx context.Context, files map[string][]byte, stale func(name string) bool) error
+}
+
+// sinkStatus tracks the pushes to one sink across cycles
+type sinkStatus struct {
+	lastSuccess         time.Time
+	consecutiveFailures int
+}
+
+// outputSinks copies the routes files to the sinks listed in OUTPUT_SINKS
+// after every successful write, e.g. a GCS object for a second Traefik or
+// an HTTP endpoint during a migration. A failing sink doesn't affect the
+// output file or the other sinks; its consecutive failures are reported.
+// Kept across daemon cycles.
+type outputSinks struct {
+	specs  []string
+	sinks  []sink
+	status []sinkStatus
+}
+
+// newOutputSinks parses OUTPUT_SINKS, or returns nil when it is empty.
+// Each entry is a gs://bucket/object URL, an http(s):// URL the output file
+// is POSTed to, a configmap:// or secret:// Kubernetes object, or a local
+// path; gs:// and path entries ending in "/" take every routes file under
+// its base name, Kubernetes objects every file as a key.
+func newOutputSinks(config *AppConfig) (*outputSinks, error) {
+	if len(config.OutputSinks) == 0 {
+		return nil, nil
+	}
+	s := &outputSinks{}
+	var (
+		objects *storage.ObjectsService
+		cluster *kube.Client
+	)
+	for _, spec := range config.OutputSinks {
+		var target sink
+		switch {
+		case strings.HasPrefix(spec, "gs://"):
+			bucket, object, _ := strings.Cut(strings.TrimPrefix(spec, "gs://"), "/")
+			if bucket == "" || object == "" {
+				return nil, fmt.Errorf("invalid output sink %q (expected gs://bucket/object or gs://bucket/prefix/)", spec)
+			}
+			if objects == nil {
+				credentials, err := gcp.LoadCredentials(config.CredentialsFile, config.CredentialsJSON)
+				if err != nil {
+					return nil, err
+				}
+				service, err := storage.NewService(context.Background(), credentials.ClientOptions()...)
+				if err != nil {
+					return nil, fmt.Errorf("failed to create Cloud Storage client: %w", err)
+				}
+				objects = service.Objects
+			}
+			target = &gcsSink{objects: objects, bucket: bucket, object: object}
+		case strings.HasPrefix(spec, "configmap://") || strings.HasPrefix(spec, "secret://"):
+			scheme, ref, _ := strings.Cut(spec, "://")
+			namespace, name, found := strings.Cut(ref, "/")
+			if !found {
+				namespace, name = "", ref
+			}
+			if name == "" || strings.Contains(name, "/") {
+				return nil, fmt.Errorf("invalid output sink %q (expected %s://[namespace/]name)", spec, scheme)
+			}
+			if cluster == nil {
+				var err error
+				if cluster, err = newKubeClient(config); err != nil {
+					return nil, err
+				}
+			}
+			kind := kube.KindConfigMap
+			if scheme == "secret" {
+				kind = kube.KindSecret
+			}
+			target = &kubeSink{client: cluster, kind: kind, namespace: namespace, name: name}
+		case strings.HasPrefix(spec, "http://") || strings.HasPrefix(spec, "https://"):
+			target = &httpSink{url: spec, client: &http.Client{Timeout: sinkTimeout}}
+		default:
+			target = fileSink(strings.TrimPrefix(spec, "file://"))
+		}
+		s.specs = append(s.specs, spec)
+		s.sinks = append(s.sinks, target)
+	}
+	s.status = make([]sinkStatus, len(s.sinks))
+	return s, nil
+}
+
+// push copies the routes files just written to every sink and reports the
+// result per sink
+func (s *outputSinks) push(config *AppConfig) {
+	if s == nil {
+		return
+	}
+	files, err := outputFiles(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  Not pushing to output sinks: %v\n", err)
+		return
+	}
+	contents := make(map[string][]byte, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  Not pushing to output sinks: failed to read %s: %v\n", file, err)
+			return
+		}
+		contents[file] = data
+	}
+
+	for i, target := range s.sinks {
+		err := s.pushTo(target, config.OutputFile, files, contents)
+		status := &s.status[i]
+		if err == nil {
+			status.lastSuccess, status.consecutiveFailures = time.Now(), 0
+			fmt.Fprintf(os.Stderr, "📤 Pushed routes to %s\n", s.specs[i])
+			continue
+		}
+		status.consecutiveFailures++
+		lastSuccess := "never"
+		if !status.lastSuccess.IsZero() {
+			lastSuccess = status.lastSuccess.Format(time.RFC3339)
+		}
+		fmt.Fprintf(os.Stderr, "⚠️  Failed to push routes to %s (%d consecutive failures, last success %s): %v\n",
+			s.specs[i], status.consecutiveFailures, lastSuccess, err)
+	}
+}
+
+// pushTo writes the output file, or every file for sinks taking all, to
+// target. Sinks taking all then lose the tenant files of earlier pushes
+// that outputFile no longer has.
+func (s *outputSinks) pushTo(target sink, outputFile string, files []string, contents map[string][]byte) error {
+	if !target.all() {
+		files = files[:1]
+	}
+	if batch, ok := target.(batchSink); ok {
+		named := make(map[string][]byte, len(files))
+		for _, file := range files {
+			named[filepath.Base(file)] = contents[file]
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), sinkTimeout)
+		defer cancel()
+		return batch.putAll(ctx, named, func(name string) bool {
+			_, ok := named[name]
+			return !ok && isTenantFileName(outputFile, name)
+		})
+	}
+	current := make(map[string]bool, len(files))
+	for _, file := range files {
+		ctx, cancel := context.WithTimeout(context.Background(), sinkTimeout)
+		err := target.put(ctx, filepath.Base(file), contents[file])
+		cancel()
+		if err != nil {
+			return err
+		}
+		current[filepath.Base(file)] = true
+	}
+	if !target.all() {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sinkTimeout)
+	defer cancel()
+	return target.prune(ctx, func(name string) bool {
+		return !current[name] && isTenantFileName(outputFile, name)
+	})
+}
+
+// isTenantFileName reports whether name is the base name of a tenant file
+// of outputFile (see tenantFile)
+func isTenantFileName(outputFile, name string) bool {
+	base := filepath.Base(outputFile)
+	ext := filepath.Ext(base)
+	tenant, ok := strings.CutPrefix(name, strings.TrimSuffix(base, ext)+"-")
+	return ok && strings.HasSuffix(tenant, ext) && len(tenant) > len(ext)
+}
+
+// fileSink copies the routes to a local path (a directory when ending in "/")
+type fileSink string
+
+func (f fileSink) all() bool { return strings.HasSuffix(string(f), "/") }
+
+func (f fileSink) put(_ context.Context, name string, data []byte) error {
+	path := string(f)
+	if f.all() {
+		path = filepath.Join(path, name)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	// Write then rename, so readers of the copy never see a partial file
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// prune removes the stale files of the directory that are generated tenant
+// files
+func (f fileSink) prune(_ context.Context, stale func(name string) bool) error {
+	entries, err := os.ReadDir(string(f))
+	if err != nil {
+		return fmt.Errorf("failed to list %s: %w", f, err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !stale(entry.Name()) {
+			continue
+		}
+		path := filepath.Join(string(f), entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil || !strings.Contains(string(data), "\n"+tenantMarker) {
+			continue // Not a generated tenant file
+		}
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// gcsSink uploads the routes to a Cloud Storage object (a prefix when the
+// object ends in "/")
+type gcsSink struct {
+	objects *storage.ObjectsService
+	bucket  string
+	object  string
+}
+
+func (g *gcsSink) all() bool { return strings.HasSuffix(g.object, "/") }
+
+func (g *gcsSink) put(ctx context.Context, name string, data []byte) error {
+	object := g.object
+	if g.all() {
+		object += name
+	}
+	_, err := g.objects.Insert(g.bucket, &storage.Object{Name: object, ContentType: contentType(name)}).
+		Media(bytes.NewReader(data)).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("failed to upload gs://%s/%s: %w", g.bucket, object, err)
+	}
+	return nil
+}
+
+// prune deletes the stale objects directly under the prefix
+func (g *gcsSink) prune(ctx context.Context, stale func(name string) bool) error {
+	var objects []string
+	err := g.objects.List(g.bucket).Prefix(g.object).Fields("items/name", "nextPageToken").Pages(ctx, func(page *storage.Objects) error {
+		for _, item := range page.Items {
+			name := strings.TrimPrefix(item.Name, g.object)
+			if !strings.Contains(name, "/") && stale(name) {
+				objects = append(objects, item.Name)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list gs://%s/%s: %w", g.bucket, g.object, err)
+	}
+	for _, object := range objects {
+		if err := g.objects.Delete(g.bucket, object).Context(ctx).Do(); err != nil {
+			return fmt.Errorf("failed to delete gs://%s/%s: %w", g.bucket, object, err)
+		}
+	}
+	return nil
+}
+
+// httpSink POSTs the output file to an endpoint
+type httpSink struct {
+	url    string
+	client *http.Client
+}
+
+func (h *httpSink) all() bool { return false }
+
+func (h *httpSink) prune(context.Context, func(string) bool) error { return nil }
+
+func (h *httpSink) put(ctx context.Context, name string, data []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType(name))
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post routes: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("sink returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// kubeSink writes the routes files as keys of a Kubernetes ConfigMap or
+// Secret, for a Traefik on GKE that mounts it into its file provider
+// directory. A ConfigMap holds at most 1MiB.
+type kubeSink struct {
+	client    *kube.Client
+	kind      string // kube.KindConfigMap or kube.KindSecret
+	namespace string // the client's namespace when empty
+	name      string
+}
+
+func (k *kubeSink) all() bool { return true }
+
+func (k *kubeSink) put(ctx context.Context, name string, data []byte) error {
+	return k.client.Apply(ctx, k.kind, k.namespace, k.name, map[string][]byte{name: data}, nil)
+}
+
+func (k *kubeSink) prune(ctx context.Context, stale func(name string) bool) error {
+	return k.putAll(ctx, nil, stale)
+}
+
+// putAll sets a key per file and removes the stale keys in one patch, so a
+// Traefik watching the mounted directory never sees a half-updated set
+func (k *kubeSink) putAll(ctx context.Context, files map[string][]byte, stale func(name string) bool) error {
+	keys, err := k.client.Keys(ctx, k.kind, k.namespace, k.name)
+	if err != nil {
+		return err
+	}
+	var remove []string
+	for _, key := range keys {
+		if _, ok := files[key]; !ok && stale(key) {
+			remove = append(remove, key)
+		}
+	}
+	return k.client.Apply(ctx, k.kind, k.namespace, k.name, files, remove)
+}
+
+// newKubeClient connects to the cluster the provider runs in, or to the
+// current context of KUBECONFIG. Kubeconfig users authenticating through
+// gke-gcloud-auth-plugin use the provider's Google access token instead.
+func newKubeClient(config *AppConfig) (*kube.Client, error) {
+	credentials, err := gcp.LoadCredentials(config.CredentialsFile, config.CredentialsJSON)
+	if err != nil {
+		return nil, err
+	}
+	tokens := gcp.NewTokenManagerWithCredentials(credentials)
+	client, err := kube.Load("", tokens.GetAccessToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kubernetes client: %w", err)
+	}
+	return client, nil
+}
+
+// contentType returns the media type of a routes file by extension
+func contentType(name string) string {
+	if strings.HasSuffix(name, ".json") {
+		return "application/json"
+	}
+	return "application/yaml"
+}
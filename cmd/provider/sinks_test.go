@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/internal/kube"
+	"google.golang.org/api/option"
+	storage "google.golang.org/api/storage/v1"
+)
+
+// tenantRoutes returns the routes files for outputFile with the given tenants
+func tenantRoutes(outputFile string, tenants ...string) ([]string, map[string][]byte) {
+	files := []string{outputFile}
+	contents := map[string][]byte{outputFile: []byte("http: {}\n")}
+	for _, tenant := range tenants {
+		file := tenantFile(outputFile, tenant)
+		files = append(files, file)
+		contents[file] = []byte("# Generated\n" + tenantMarker + tenant + "\n\nhttp: {}\n")
+	}
+	return files, contents
+}
+
+func TestIsTenantFileName(t *testing.T) {
+	for name, want := range map[string]bool{
+		"routes-a.yml":      true,
+		"routes-team-b.yml": true,
+		"routes.yml":        false,
+		"routes-.yml":       false,
+		"routes-a.json":     false,
+		"other-a.yml":       false,
+	} {
+		if got := isTenantFileName("/etc/traefik/routes.yml", name); got != want {
+			t.Errorf("isTenantFileName(%s) = %v, expected %v", name, got, want)
+		}
+	}
+}
+
+func TestFileSink_PrunesDepartedTenants(t *testing.T) {
+	outputFile := filepath.Join(t.TempDir(), "routes.yml")
+	dir := t.TempDir()
+	target := fileSink(dir + "/")
+	s := &outputSinks{}
+
+	files, contents := tenantRoutes(outputFile, "a", "b")
+	if err := s.pushTo(target, outputFile, files, contents); err != nil {
+		t.Fatalf("pushTo failed: %v", err)
+	}
+	// Not written by the provider, so kept whatever its name
+	if err := os.WriteFile(filepath.Join(dir, "routes-notes.yml"), []byte("notes"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	files, contents = tenantRoutes(outputFile, "a")
+	if err := s.pushTo(target, outputFile, files, contents); err != nil {
+		t.Fatalf("pushTo failed: %v", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if strings.Join(names, ",") != "routes-a.yml,routes-notes.yml,routes.yml" {
+		t.Errorf("Expected routes-b.yml removed, got %v", names)
+	}
+}
+
+func TestGCSSink_PrunesDepartedTenants(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		deleted []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/storage/v1/b/routes-bucket/o":
+			if r.URL.Query().Get("prefix") != "traefik/" {
+				t.Errorf("Expected prefix traefik/, got %s", r.URL.Query().Get("prefix"))
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"items": []map[string]string{
+				{"name": "traefik/routes.yml"},
+				{"name": "traefik/routes-a.yml"},
+				{"name": "traefik/routes-b.yml"},
+				{"name": "traefik/other.yml"},
+				{"name": "traefik/archive/routes-c.yml"},
+			}})
+		case r.Method == http.MethodDelete:
+			deleted = append(deleted, strings.TrimPrefix(r.URL.Path, "/storage/v1/b/routes-bucket/o/"))
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	service, err := storage.NewService(context.Background(), option.WithEndpoint(server.URL+"/storage/v1/"), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := &gcsSink{objects: service.Objects, bucket: "routes-bucket", object: "traefik/"}
+	current := map[string]bool{"routes.yml": true, "routes-a.yml": true}
+	err = target.prune(context.Background(), func(name string) bool {
+		return !current[name] && isTenantFileName("routes.yml", name)
+	})
+	if err != nil {
+		t.Fatalf("prune failed: %v", err)
+	}
+	if len(deleted) != 1 || deleted[0] != "traefik/routes-b.yml" {
+		t.Errorf("Expected only traefik/routes-b.yml deleted, got %v", deleted)
+	}
+}
+
+// fakeKubeAPI stores ConfigMap data, applying merge patches
+type fakeKubeAPI struct {
+	mu      sync.Mutex
+	data    map[string]any // nil until created
+	patches int
+}
+
+func (f *fakeKubeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Method != http.MethodPost && f.data == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	var object map[string]any
+	if r.Method != http.MethodGet {
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &object); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	switch r.Method {
+	case http.MethodGet:
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": f.data})
+	case http.MethodPost:
+		f.data = object["data"].(map[string]any)
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodPatch:
+		f.patches++
+		for key, value := range object["data"].(map[string]any) {
+			if value == nil {
+				delete(f.data, key)
+			} else {
+				f.data[key] = value
+			}
+		}
+	}
+}
+
+// keys returns the stored keys, sorted
+func (f *fakeKubeAPI) keys() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	keys := make([]string, 0, len(f.data))
+	for key := range f.data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// newFakeKubeClient returns a client for api through a kubeconfig
+func newFakeKubeClient(t *testing.T, api http.Handler) *kube.Client {
+	t.Helper()
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+	path := filepath.Join(t.TempDir(), "config")
+	kubeconfig := `current-context: dev
+clusters:
+- {name: dev, cluster: {server: "` + server.URL + `"}}
+contexts:
+- {name: dev, context: {cluster: dev, user: dev, namespace: traefik}}
+users:
+- {name: dev, user: {token: secret-token}}
+`
+	if err := os.WriteFile(path, []byte(kubeconfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+	client, err := kube.FromKubeconfig(path, nil)
+	if err != nil {
+		t.Fatalf("FromKubeconfig failed: %v", err)
+	}
+	return client
+}
+
+func TestKubeSink_UpdatesInOnePatch(t *testing.T) {
+	api := &fakeKubeAPI{}
+	target := &kubeSink{client: newFakeKubeClient(t, api), kind: kube.KindConfigMap, name: "routes"}
+	outputFile := "/etc/traefik/routes.yml"
+	s := &outputSinks{}
+
+	files, contents := tenantRoutes(outputFile, "a", "b")
+	if err := s.pushTo(target, outputFile, files, contents); err != nil {
+		t.Fatalf("pushTo failed: %v", err)
+	}
+	if keys := api.keys(); strings.Join(keys, ",") != "routes-a.yml,routes-b.yml,routes.yml" {
+		t.Errorf("Expected every routes file as a key, got %v", keys)
+	}
+
+	// Tenant c arrives as b leaves: one patch sets and removes the keys
+	api.patches = 0
+	files, contents = tenantRoutes(outputFile, "a", "c")
+	if err := s.pushTo(target, outputFile, files, contents); err != nil {
+		t.Fatalf("pushTo failed: %v", err)
+	}
+	if keys := api.keys(); strings.Join(keys, ",") != "routes-a.yml,routes-c.yml,routes.yml" {
+		t.Errorf("Expected routes-b.yml removed, got %v", keys)
+	}
+	if api.patches != 1 {
+		t.Errorf("Expected a single patch, got %d", api.patches)
+	}
+}
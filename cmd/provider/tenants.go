@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/provider"
+	"gopkg.in/yaml.v3"
+)
+
+// tenantMarker starts the header line that identifies a tenant routes file,
+// so files of tenants that disappeared can be removed safely
+const tenantMarker = "# Tenant: "
+
+// tenantFile returns the routes file of a tenant next to outputFile,
+// e.g. routes.yml -> routes-<tenant>.yml
+func tenantFile(outputFile, tenant string) string {
+	ext := filepath.Ext(outputFile)
+	return strings.TrimSuffix(outputFile, ext) + "-" + tenant + ext
+}
+
+// writeTenantRoutes splits the configuration by tenant and writes each
+// tenant's routers (named <tenant>-<router>) to its own file. Shared objects
+// go to outputFile. Tenant files from earlier runs whose tenant is gone are
+// removed. The skipped services summary goes to outputFile.
+func writeTenantRoutes(outputFile string, config *provider.DynamicConfig, tenants map[string]string, skipped []provider.SkippedService) error {
+	parts := config.SplitTenants(tenants)
+	if err := writeRoutes(outputFile, parts[""], skipped); err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(parts))
+	for tenant := range parts {
+		if tenant != "" {
+			names = append(names, tenant)
+		}
+	}
+	sort.Strings(names)
+
+	current := make(map[string]bool)
+	for _, tenant := range names {
+		path := tenantFile(outputFile, tenant)
+		current[path] = true
+		if err := writeTenantFile(path, tenant, parts[tenant]); err != nil {
+			return fmt.Errorf("tenant %s: %w", tenant, err)
+		}
+		fmt.Fprintf(os.Stderr, "🏢 Tenant %s: %d routers -> %s\n", tenant, len(parts[tenant].HTTP.Routers), path)
+	}
+
+	return removeStaleTenantFiles(outputFile, current)
+}
+
+// writeTenantFile writes one tenant's routes file
+func writeTenantFile(path, tenant string, config *provider.DynamicConfig) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+
+	writeHeader(file, config.Generation())
+	fmt.Fprintf(file, "%s%s\n\n", tenantMarker, tenant)
+
+	encoder := yaml.NewEncoder(file)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(config); err != nil {
+		return fmt.Errorf("failed to encode YAML: %w", err)
+	}
+	return encoder.Close()
+}
+
+// tenantFiles returns the generated tenant files next to outputFile
+func tenantFiles(outputFile string) ([]string, error) {
+	ext := filepath.Ext(outputFile)
+	matches, err := filepath.Glob(strings.TrimSuffix(outputFile, ext) + "-*" + ext)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, path := range matches {
+		data, err := os.ReadFile(path)
+		if err != nil || !strings.Contains(string(data), "\n"+tenantMarker) {
+			continue // Not a generated tenant file
+		}
+		files = append(files, path)
+	}
+	return files, nil
+}
+
+// removeStaleTenantFiles removes generated tenant files next to outputFile
+// that are not in current
+func removeStaleTenantFiles(outputFile string, current map[string]bool) error {
+	files, err := tenantFiles(outputFile)
+	if err != nil {
+		return err
+	}
+	for _, path := range files {
+		if current[path] {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("failed to remove stale tenant file: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "🧹 Removed routes of departed tenant: %s\n", path)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/internal/ipranges"
+	"gopkg.in/yaml.v3"
+)
+
+// trustedIPsSnippet is the static config fragment written to TRUSTED_IPS_FILE
+type trustedIPsSnippet struct {
+	EntryPoints map[string]entryPointSnippet `yaml:"entryPoints"`
+}
+
+// entryPointSnippet is the part of an entry point the snippet sets
+type entryPointSnippet struct {
+	ForwardedHeaders forwardedHeadersSnippet `yaml:"forwardedHeaders"`
+}
+
+// forwardedHeadersSnippet lists the sources trusted for X-Forwarded-* headers
+type forwardedHeadersSnippet struct {
+	TrustedIPs []string `yaml:"trustedIPs"`
+}
+
+// newRangesFetcher returns the IP range fetcher, or nil when neither
+// TRUSTED_IPS_FILE nor TRUSTED_IPS_FETCH asks for published ranges
+func newRangesFetcher(config *AppConfig) *ipranges.Fetcher {
+	if config.TrustedIPsFile == "" && !config.TrustedIPsFetch {
+		return nil
+	}
+	return ipranges.NewFetcher(config.TrustedIPsURL, config.TrustedIPsRefresh)
+}
+
+// trustedIPs returns the ranges to trust for X-Forwarded-* headers: loopback
+// plus the published Google ranges if fetcher is set (falling back to the
+// built-in front end ranges when they can't be fetched)
+func trustedIPs(fetcher *ipranges.Fetcher) []string {
+	ips := append([]string{}, loopbackRanges...)
+	if fetcher == nil {
+		return append(ips, googleFrontEndRanges...)
+	}
+	ranges, err := fetcher.Ranges(time.Now())
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	if len(ranges) == 0 {
+		return append(ips, googleFrontEndRanges...)
+	}
+	return append(ips, ranges...)
+}
+
+// refreshTrustedIPs rewrites TRUSTED_IPS_FILE when the fetched ranges are due
+// for a refresh. The file is only replaced if its content changes.
+func refreshTrustedIPs(config *AppConfig, fetcher *ipranges.Fetcher) {
+	if fetcher == nil || config.TrustedIPsFile == "" || !fetcher.Due(time.Now()) {
+		return
+	}
+
+	ips := trustedIPs(fetcher)
+	snippet := trustedIPsSnippet{EntryPoints: map[string]entryPointSnippet{
+		"web": {ForwardedHeaders: forwardedHeadersSnippet{TrustedIPs: ips}},
+	}}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# Trusted IPs for X-Forwarded-* headers, generated by traefik-cloudrun-provider\n")
+	fmt.Fprintf(&buf, "# Source: %s\n", fetcher.URL())
+	fmt.Fprintf(&buf, "# Merge into the web entry point of Traefik's static configuration\n\n")
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(snippet); err != nil {
+		log.Printf("Error encoding trusted IPs: %v", err)
+		return
+	}
+	encoder.Close()
+
+	if existing, err := os.ReadFile(config.TrustedIPsFile); err == nil && bytes.Equal(existing, buf.Bytes()) {
+		return
+	}
+	if err := os.WriteFile(config.TrustedIPsFile, buf.Bytes(), 0644); err != nil {
+		log.Printf("Error writing trusted IPs file: %v", err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "🌐 Trusted IPs written to %s (%d ranges)\n",
+		config.TrustedIPsFile, len(ips))
+}
@@ -0,0 +1,53 @@
+// Command yaegi-smoke loads the plugin named in .traefik.yml with Yaegi, as
+// the Traefik Plugin Catalog analyzer does, so interpreter incompatibilities
+// show up before publishing. Dependencies must be vendored first.
+//
+// Usage:
+//
+//	yaegi-smoke [module-root]
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/internal/catalog"
+)
+
+func main() {
+	root := "."
+	if len(os.Args) > 1 {
+		root = os.Args[1]
+	}
+
+	if err := run(root); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Yaegi smoke test failed: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(root string) error {
+	goMod, err := os.ReadFile(filepath.Join(root, "go.mod"))
+	if err != nil {
+		return fmt.Errorf("failed to read go.mod: %w", err)
+	}
+	modulePath, err := catalog.ModulePath(goMod)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(filepath.Join(root, catalog.ManifestFile))
+	if err != nil {
+		return fmt.Errorf("failed to read manifest: %w", err)
+	}
+	manifest, err := catalog.Read(data)
+	if err != nil {
+		return err
+	}
+
+	if err := catalog.Smoke(root, modulePath, manifest); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "✅ Yaegi loaded %s\n", manifest.Import)
+	return nil
+}
@@ -0,0 +1,209 @@
+// Package catalog packages the plugin for the Traefik Plugin Catalog: it
+// generates the .traefik.yml manifest the catalog analyzer reads, checks it
+// against the plugin's configuration, and loads the plugin with Yaegi the
+// way the analyzer (and Traefik) do.
+//
+// The analyzer rejects a repository whose manifest has no import path, whose
+// testData doesn't decode into the plugin configuration, or whose vendored
+// tree Yaegi can't interpret.
+package catalog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ManifestFile is the manifest's file name in the repository root
+const ManifestFile = ".traefik.yml"
+
+// Plugin types accepted by the catalog
+const (
+	TypeMiddleware = "middleware"
+	TypeProvider   = "provider"
+)
+
+// RuntimeWasm marks a Wasm plugin; the catalog loads WasmFile from the
+// release archive instead of interpreting Go sources
+const RuntimeWasm = "wasm"
+
+// WasmFile is the compiled module's file name next to the manifest
+const WasmFile = "plugin.wasm"
+
+// Manifest is the content of .traefik.yml
+type Manifest struct {
+	DisplayName string                 `yaml:"displayName"`
+	Type        string                 `yaml:"type"`
+	Runtime     string                 `yaml:"runtime,omitempty"`
+	Import      string                 `yaml:"import,omitempty"`
+	IconPath    string                 `yaml:"iconPath,omitempty"`
+	Summary     string                 `yaml:"summary"`
+	TestData    map[string]interface{} `yaml:"testData"`
+}
+
+// manifestHeader is written above the generated manifest
+const manifestHeader = `# Traefik Plugin Catalog manifest
+# Generated by cmd/plugin-catalog (make plugin-package / make wasm); do not edit.
+`
+
+// PluginManifest returns the manifest for this repository's provider plugin,
+// importing the plugin package of the given module path
+func PluginManifest(modulePath string) *Manifest {
+	return &Manifest{
+		DisplayName: "Cloud Run Provider",
+		Type:        TypeProvider,
+		Import:      modulePath + "/plugin",
+		IconPath:    "docs/icon.svg",
+		Summary:     "Dynamically discover Google Cloud Run services and generate Traefik routing configuration",
+		TestData: map[string]interface{}{
+			"projectIDs":   []interface{}{"test-project"},
+			"region":       "us-central1",
+			"pollInterval": "30s",
+		},
+	}
+}
+
+// TokenMiddlewareWasmManifest returns the manifest for the Wasm build of the
+// token middleware (middleware/wasm), packaged next to WasmFile
+func TokenMiddlewareWasmManifest() *Manifest {
+	return &Manifest{
+		DisplayName: "Cloud Run Token (Wasm)",
+		Type:        TypeMiddleware,
+		Runtime:     RuntimeWasm,
+		Summary:     "Inject Google Cloud Run identity tokens into requests to Cloud Run backends",
+		TestData: map[string]interface{}{
+			"audience": "https://example-abc.run.app",
+		},
+	}
+}
+
+// ModulePath returns the module path declared in a go.mod file
+func ModulePath(goMod []byte) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(goMod))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "module" {
+			return strings.Trim(fields[1], `"`), nil
+		}
+	}
+	return "", errors.New("go.mod has no module directive")
+}
+
+// Write encodes the manifest with the generated-file header
+func Write(w io.Writer, m *Manifest) error {
+	if _, err := io.WriteString(w, manifestHeader+"\n"); err != nil {
+		return err
+	}
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(m); err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	return encoder.Close()
+}
+
+// Read decodes a manifest
+func Read(data []byte) (*Manifest, error) {
+	var m Manifest
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	return &m, nil
+}
+
+// Validate checks the manifest the way the catalog analyzer does: required
+// fields are set, the icon exists under root, the import path belongs to
+// the module (or, for Wasm plugins, WasmFile exists under root), and
+// testData decodes into config (the value CreateConfig returns) without
+// unknown keys. A nil config skips the testData check. All problems are
+// returned together.
+func Validate(m *Manifest, root, modulePath string, config interface{}) error {
+	var problems []error
+	if m.DisplayName == "" {
+		problems = append(problems, errors.New("displayName is required"))
+	}
+	if m.Type != TypeMiddleware && m.Type != TypeProvider {
+		problems = append(problems, fmt.Errorf("type %q must be %q or %q", m.Type, TypeMiddleware, TypeProvider))
+	}
+	if m.Runtime == RuntimeWasm {
+		if _, err := os.Stat(filepath.Join(root, WasmFile)); err != nil {
+			problems = append(problems, fmt.Errorf("wasm module: %w", err))
+		}
+	} else if m.Import == "" {
+		problems = append(problems, errors.New("import is required"))
+	} else if m.Import != modulePath && !strings.HasPrefix(m.Import, modulePath+"/") {
+		problems = append(problems, fmt.Errorf("import %q is not in module %s", m.Import, modulePath))
+	}
+	if m.Summary == "" {
+		problems = append(problems, errors.New("summary is required"))
+	}
+	if m.IconPath != "" {
+		if _, err := os.Stat(filepath.Join(root, m.IconPath)); err != nil {
+			problems = append(problems, fmt.Errorf("iconPath %s: %w", m.IconPath, err))
+		}
+	}
+	if len(m.TestData) == 0 {
+		problems = append(problems, errors.New("testData is required"))
+	} else if config != nil {
+		if err := checkTestData(m.TestData, config); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	return errors.Join(problems...)
+}
+
+// checkTestData reports testData keys config has no JSON field for, then
+// decodes testData into a copy of config. Durations are given as strings
+// ("30s") in the manifest, as Traefik accepts them; those keys are only
+// checked for presence.
+func checkTestData(testData map[string]interface{}, config interface{}) error {
+	t := reflect.TypeOf(config)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	fields := make(map[string]reflect.Type, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			fields[name] = t.Field(i).Type
+		}
+	}
+
+	var unknown []string
+	decodable := make(map[string]interface{}, len(testData))
+	for key, value := range testData {
+		typ, ok := fields[key]
+		if !ok {
+			unknown = append(unknown, key)
+			continue
+		}
+		if _, isString := value.(string); isString && typ.String() == "time.Duration" {
+			continue
+		}
+		decodable[key] = value
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("testData keys not in the plugin configuration: %s", strings.Join(unknown, ", "))
+	}
+
+	data, err := json.Marshal(decodable)
+	if err != nil {
+		return fmt.Errorf("failed to encode testData: %w", err)
+	}
+	if err := json.Unmarshal(data, reflect.New(t).Interface()); err != nil {
+		return fmt.Errorf("testData does not match the plugin configuration: %w", err)
+	}
+	return nil
+}
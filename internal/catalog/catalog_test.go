@@ -0,0 +1,118 @@
+package catalog
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testModule = "github.com/pci-tamper-protect/traefik-cloudrun-provider"
+
+// testConfig mirrors the shape of the plugin configuration
+type testConfig struct {
+	ProjectIDs   []string      `json:"projectIDs,omitempty"`
+	Region       string        `json:"region,omitempty"`
+	PollInterval time.Duration `json:"pollInterval,omitempty"`
+}
+
+func TestModulePath(t *testing.T) {
+	path, err := ModulePath([]byte("// comment\nmodule " + testModule + "\n\ngo 1.24.0\n"))
+	if err != nil || path != testModule {
+		t.Errorf("ModulePath = %q, %v", path, err)
+	}
+	if _, err := ModulePath([]byte("go 1.24.0\n")); err == nil {
+		t.Error("Expected error for go.mod without module directive")
+	}
+}
+
+func TestWriteRead(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, PluginManifest(testModule)); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), "# Traefik Plugin Catalog manifest") {
+		t.Errorf("Expected generated-file header, got:\n%s", buf.String())
+	}
+
+	m, err := Read(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if m.Import != testModule+"/plugin" || m.Type != TypeProvider {
+		t.Errorf("Read = %+v", m)
+	}
+}
+
+// TestManifestUpToDate fails when .traefik.yml wasn't regenerated after the
+// manifest changed
+func TestManifestUpToDate(t *testing.T) {
+	root := filepath.Join("..", "..")
+	current, err := os.ReadFile(filepath.Join(root, ManifestFile))
+	if err != nil {
+		t.Fatalf("Failed to read manifest: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := Write(&buf, PluginManifest(testModule)); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if !bytes.Equal(current, buf.Bytes()) {
+		t.Errorf("%s is out of date; run 'make plugin-package'", ManifestFile)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	root := filepath.Join("..", "..")
+	if err := Validate(PluginManifest(testModule), root, testModule, &testConfig{}); err != nil {
+		t.Errorf("Expected plugin manifest to be valid, got: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(m *Manifest)
+		want   string
+	}{
+		{"missing import", func(m *Manifest) { m.Import = "" }, "import is required"},
+		{"foreign import", func(m *Manifest) { m.Import = "github.com/other/plugin" }, "not in module"},
+		{"bad type", func(m *Manifest) { m.Type = "router" }, "type \"router\""},
+		{"missing icon", func(m *Manifest) { m.IconPath = "docs/missing.svg" }, "iconPath docs/missing.svg"},
+		{"no test data", func(m *Manifest) { m.TestData = nil }, "testData is required"},
+		{"unknown key", func(m *Manifest) { m.TestData["projects"] = "x" }, "not in the plugin configuration: projects"},
+		{"wrong value type", func(m *Manifest) { m.TestData["region"] = 5 }, "does not match"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := PluginManifest(testModule)
+			tt.modify(m)
+			err := Validate(m, root, testModule, &testConfig{})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestValidate_Wasm(t *testing.T) {
+	dir := t.TempDir()
+	m := TokenMiddlewareWasmManifest()
+	if err := Validate(m, dir, "", nil); err == nil || !strings.Contains(err.Error(), "wasm module") {
+		t.Errorf("Expected missing wasm module error, got %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, WasmFile), []byte("\x00asm"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Validate(m, dir, "", nil); err != nil {
+		t.Errorf("Expected Wasm manifest without import to be valid, got: %v", err)
+	}
+}
+
+func TestSmokeRequiresVendor(t *testing.T) {
+	err := Smoke(t.TempDir(), testModule, PluginManifest(testModule))
+	if err == nil || !strings.Contains(err.Error(), "vendor directory missing") {
+		t.Errorf("Expected missing vendor error, got %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+package catalog
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/traefik/yaegi/interp"
+	"github.com/traefik/yaegi/stdlib"
+)
+
+// Smoke loads the manifest's import path from the module at root with Yaegi,
+// the way the catalog analyzer and Traefik's local plugin mode do, and calls
+// CreateConfig and checks that New exists. Dependencies must be vendored:
+// Yaegi only resolves imports from GOPATH and vendor directories.
+func Smoke(root, modulePath string, m *Manifest) error {
+	if _, err := os.Stat(filepath.Join(root, "vendor")); err != nil {
+		return fmt.Errorf("vendor directory missing (run 'go mod vendor'): %w", err)
+	}
+
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+	goPath, err := os.MkdirTemp("", "yaegi-smoke-")
+	if err != nil {
+		return fmt.Errorf("failed to create GOPATH: %w", err)
+	}
+	defer os.RemoveAll(goPath)
+
+	// Traefik looks for plugins in <GOPATH>/src/<module-path>/
+	srcDir := filepath.Join(goPath, "src", filepath.FromSlash(modulePath))
+	if err := os.MkdirAll(filepath.Dir(srcDir), 0755); err != nil {
+		return fmt.Errorf("failed to create GOPATH: %w", err)
+	}
+	if err := os.Symlink(root, srcDir); err != nil {
+		return fmt.Errorf("failed to link module into GOPATH: %w", err)
+	}
+
+	i := interp.New(interp.Options{GoPath: goPath})
+	if err := i.Use(stdlib.Symbols); err != nil {
+		return fmt.Errorf("failed to load stdlib symbols: %w", err)
+	}
+
+	pkg := path.Base(m.Import)
+	steps := []struct{ name, code string }{
+		{"import", fmt.Sprintf("import %q", m.Import)},
+		{"CreateConfig", pkg + ".CreateConfig()"},
+		{"New", pkg + ".New"},
+	}
+	for _, step := range steps {
+		if err := eval(i, step.code); err != nil {
+			return fmt.Errorf("%s %s: %w", step.name, m.Import, err)
+		}
+	}
+	return nil
+}
+
+// eval runs code in the interpreter, turning Yaegi panics (which it raises
+// for unsupported constructs) into errors
+func eval(i *interp.Interpreter, code string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("yaegi panic: %v", r)
+		}
+	}()
+	_, err = i.Eval(code)
+	return err
+}
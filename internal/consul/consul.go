@@ -0,0 +1,206 @@
+// Package consul registers discovered Cloud Run services in a Consul catalog
+// through the local agent's HTTP API, so consumers other than Traefik can
+// find them.
+package consul
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/provider"
+)
+
+// TagsLabel lists extra Consul tags for a service, separated like router
+// middlewares (__, ; or ,)
+const TagsLabel = "consul_tags"
+
+// RegisterLabel set to "false" keeps a service out of Consul
+const RegisterLabel = "consul_register"
+
+// idPrefix prefixes the IDs of every service this registrar owns, so
+// deregistration never touches services registered by anything else
+const idPrefix = "cloudrun-"
+
+// Registration is the body of PUT /v1/agent/service/register
+type Registration struct {
+	ID      string            `json:"ID"`
+	Name    string            `json:"Name"`
+	Address string            `json:"Address"`
+	Port    int               `json:"Port"`
+	Tags    []string          `json:"Tags,omitempty"`
+	Meta    map[string]string `json:"Meta,omitempty"`
+}
+
+// Registrar keeps the Consul catalog in sync with the discovered services
+type Registrar struct {
+	address string // Consul agent base URL, e.g. http://127.0.0.1:8500
+	token   string // ACL token (optional)
+	client  *http.Client
+
+	// IDs registered by the last successful sync
+	registered map[string]bool
+}
+
+// NewRegistrar creates a registrar for the Consul agent at address
+func NewRegistrar(address, token string) *Registrar {
+	return &Registrar{
+		address:    strings.TrimSuffix(address, "/"),
+		token:      token,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		registered: make(map[string]bool),
+	}
+}
+
+// Sync registers every service and deregisters the ones registered by the
+// previous sync that have disappeared. Failures are collected and returned
+// together; successfully synced services stay registered.
+func (r *Registrar) Sync(services []provider.CloudRunService) error {
+	var errs []string
+	current := make(map[string]bool)
+
+	for _, service := range services {
+		if service.Labels[RegisterLabel] == "false" {
+			continue
+		}
+		registration, err := NewRegistration(service)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		current[registration.ID] = true
+		if err := r.register(registration); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	for id := range r.registered {
+		if current[id] {
+			continue
+		}
+		if err := r.deregister(id); err != nil {
+			errs = append(errs, err.Error())
+			current[id] = true // Retry on the next sync
+		}
+	}
+	r.registered = current
+
+	if len(errs) > 0 {
+		return fmt.Errorf("consul sync: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// NewRegistration builds the Consul registration for a Cloud Run service.
+// Tags are the service's router names plus the consul_tags label; Meta
+// records where the service runs.
+func NewRegistration(service provider.CloudRunService) (Registration, error) {
+	parsed, err := url.Parse(service.URL)
+	if err != nil || parsed.Hostname() == "" {
+		return Registration{}, fmt.Errorf("service %s: invalid URL %q", service.Name, service.URL)
+	}
+	port := 443
+	if parsed.Scheme == "http" {
+		port = 80
+	}
+	if p := parsed.Port(); p != "" {
+		if port, err = strconv.Atoi(p); err != nil {
+			return Registration{}, fmt.Errorf("service %s: invalid port in URL %q", service.Name, service.URL)
+		}
+	}
+
+	var tags []string
+	for key := range service.Labels {
+		// traefik_http_routers_<router>_<property>
+		parts := strings.SplitN(key, "_", 5)
+		if len(parts) == 5 && strings.HasPrefix(key, "traefik_http_routers_") {
+			tags = appendMissing(tags, "router="+parts[3])
+		}
+	}
+	sort.Strings(tags)
+	if value, ok := service.Labels[TagsLabel]; ok {
+		for _, tag := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ';' }) {
+			for _, t := range strings.Split(tag, "__") {
+				if t = strings.TrimSpace(t); t != "" {
+					tags = appendMissing(tags, t)
+				}
+			}
+		}
+	}
+
+	meta := map[string]string{
+		"cloudrun_project": service.ProjectID,
+		"cloudrun_region":  service.Region,
+		"cloudrun_url":     service.URL,
+	}
+	if service.Revision != "" {
+		meta["cloudrun_revision"] = service.Revision
+	}
+
+	return Registration{
+		ID:      idPrefix + service.ProjectID + "-" + service.Name,
+		Name:    service.Name,
+		Address: parsed.Hostname(),
+		Port:    port,
+		Tags:    tags,
+		Meta:    meta,
+	}, nil
+}
+
+// register upserts one service through the agent
+func (r *Registrar) register(registration Registration) error {
+	body, err := json.Marshal(registration)
+	if err != nil {
+		return fmt.Errorf("register %s: %w", registration.ID, err)
+	}
+	if err := r.put("/v1/agent/service/register", body); err != nil {
+		return fmt.Errorf("register %s: %w", registration.ID, err)
+	}
+	return nil
+}
+
+// deregister removes one service through the agent
+func (r *Registrar) deregister(id string) error {
+	if err := r.put("/v1/agent/service/deregister/"+url.PathEscape(id), nil); err != nil {
+		return fmt.Errorf("deregister %s: %w", id, err)
+	}
+	return nil
+}
+
+// put sends a PUT request to the agent and checks for a 2xx response
+func (r *Registrar) put(path string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPut, r.address+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if r.token != "" {
+		req.Header.Set("X-Consul-Token", r.token)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("consul returned HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// appendMissing appends tag unless it's already in tags
+func appendMissing(tags []string, tag string) []string {
+	for _, existing := range tags {
+		if existing == tag {
+			return tags
+		}
+	}
+	return append(tags, tag)
+}
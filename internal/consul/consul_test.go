@@ -0,0 +1,109 @@
+package consul
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/provider"
+)
+
+// fakeAgent records register and deregister calls
+type fakeAgent struct {
+	mu           sync.Mutex
+	registered   map[string]Registration
+	deregistered []string
+	token        string
+}
+
+func (a *fakeAgent) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.token = r.Header.Get("X-Consul-Token")
+	switch {
+	case r.URL.Path == "/v1/agent/service/register":
+		var reg Registration
+		if err := json.NewDecoder(r.Body).Decode(&reg); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		a.registered[reg.ID] = reg
+	case strings.HasPrefix(r.URL.Path, "/v1/agent/service/deregister/"):
+		id := strings.TrimPrefix(r.URL.Path, "/v1/agent/service/deregister/")
+		a.deregistered = append(a.deregistered, id)
+		delete(a.registered, id)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestNewRegistration(t *testing.T) {
+	reg, err := NewRegistration(provider.CloudRunService{
+		Name:      "lab1",
+		URL:       "https://lab1-123.us-central1.run.app",
+		ProjectID: "labs",
+		Region:    "us-central1",
+		Labels: map[string]string{
+			"traefik_http_routers_lab1_rule":        "PathPrefix(`/lab1`)",
+			"traefik_http_routers_lab1-static_rule": "PathPrefix(`/lab1/css/`)",
+			TagsLabel:                               "team-labs__public",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Expected registration, got: %v", err)
+	}
+	if reg.ID != "cloudrun-labs-lab1" || reg.Address != "lab1-123.us-central1.run.app" || reg.Port != 443 {
+		t.Errorf("Unexpected registration: %+v", reg)
+	}
+	wantTags := []string{"router=lab1", "router=lab1-static", "team-labs", "public"}
+	if !reflect.DeepEqual(reg.Tags, wantTags) {
+		t.Errorf("Expected tags %v, got %v", wantTags, reg.Tags)
+	}
+	if reg.Meta["cloudrun_project"] != "labs" {
+		t.Errorf("Expected project in meta, got %v", reg.Meta)
+	}
+}
+
+func TestRegistrarSync(t *testing.T) {
+	agent := &fakeAgent{registered: make(map[string]Registration)}
+	server := httptest.NewServer(agent)
+	defer server.Close()
+
+	r := NewRegistrar(server.URL, "secret")
+	lab1 := provider.CloudRunService{Name: "lab1", URL: "https://lab1.run.app", ProjectID: "labs"}
+	lab2 := provider.CloudRunService{Name: "lab2", URL: "https://lab2.run.app", ProjectID: "labs"}
+	optOut := provider.CloudRunService{Name: "private", URL: "https://private.run.app", ProjectID: "labs",
+		Labels: map[string]string{RegisterLabel: "false"}}
+
+	if err := r.Sync([]provider.CloudRunService{lab1, lab2, optOut}); err != nil {
+		t.Fatalf("First sync failed: %v", err)
+	}
+	if len(agent.registered) != 2 || agent.token != "secret" {
+		t.Errorf("Expected 2 services registered with the ACL token, got %v (token %q)", agent.registered, agent.token)
+	}
+
+	if err := r.Sync([]provider.CloudRunService{lab1}); err != nil {
+		t.Fatalf("Second sync failed: %v", err)
+	}
+	if !reflect.DeepEqual(agent.deregistered, []string{"cloudrun-labs-lab2"}) {
+		t.Errorf("Expected lab2 to be deregistered, got %v", agent.deregistered)
+	}
+}
+
+func TestRegistrarSync_AgentError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	err := NewRegistrar(server.URL, "").Sync([]provider.CloudRunService{
+		{Name: "lab1", URL: "https://lab1.run.app", ProjectID: "labs"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "HTTP 403") {
+		t.Errorf("Expected HTTP 403 error, got: %v", err)
+	}
+}
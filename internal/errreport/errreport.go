@@ -0,0 +1,52 @@
+// Package errreport sends provider failures to an error tracker - Google
+// Cloud Error Reporting or Sentry - so recurring failures show up in the
+// alerting teams already have instead of only in the logs.
+package errreport
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultService is the service name errors are reported under
+const DefaultService = "traefik-cloudrun-provider"
+
+// Event is a failure to report
+type Event struct {
+	Message string    // Error message
+	Stack   string    // Goroutine stack, for panics (optional)
+	Code    string    // Log code, e.g. PLUGIN_009_ERROR_CONFIG_GENERATION_FAILED
+	Project string    // Project the failure concerns (optional)
+	Service string    // Cloud Run service the failure concerns (optional)
+	Time    time.Time // When it happened (default: now)
+}
+
+// Reporter sends events to an error tracker
+type Reporter interface {
+	Report(ctx context.Context, event Event) error
+}
+
+// summary returns the event message with its context, for trackers that
+// only take a message
+func (e Event) summary() string {
+	var context []string
+	for _, field := range []struct{ key, value string }{{"code", e.Code}, {"project", e.Project}, {"service", e.Service}} {
+		if field.value != "" {
+			context = append(context, field.key+"="+field.value)
+		}
+	}
+	if len(context) == 0 {
+		return e.Message
+	}
+	return fmt.Sprintf("%s (%s)", e.Message, strings.Join(context, ", "))
+}
+
+// eventTime returns the event time, defaulting to now
+func (e Event) eventTime() time.Time {
+	if e.Time.IsZero() {
+		return time.Now()
+	}
+	return e.Time
+}
@@ -0,0 +1,89 @@
+package errreport
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEvent_Summary(t *testing.T) {
+	event := Event{Message: "token fetch failed", Code: "PLUGIN_008_ERROR_TOKEN_INVALID", Service: "lab1"}
+	if got := event.summary(); got != "token fetch failed (code=PLUGIN_008_ERROR_TOKEN_INVALID, service=lab1)" {
+		t.Errorf("Unexpected summary: %s", got)
+	}
+	if got := (Event{Message: "boom"}).summary(); got != "boom" {
+		t.Errorf("Expected bare message, got %s", got)
+	}
+}
+
+func TestNewSentry_InvalidDSN(t *testing.T) {
+	for _, dsn := range []string{"", "https://sentry.example.com/42", "https://key@sentry.example.com/", "not a url"} {
+		if _, err := NewSentry(dsn, "", "", nil); err == nil {
+			t.Errorf("Expected %q to be rejected", dsn)
+		}
+	}
+}
+
+func TestSentryReporter_Report(t *testing.T) {
+	var (
+		path, auth string
+		received   sentryEvent
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, auth = r.URL.Path, r.Header.Get("X-Sentry-Auth")
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("Invalid event body: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	dsn := strings.Replace(server.URL, "http://", "http://public-key@", 1) + "/sentry/42"
+	reporter, err := NewSentry(dsn, "stg", "1.2.3", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = reporter.Report(context.Background(), Event{
+		Message: "failed to list services",
+		Code:    "PLUGIN_009_ERROR_CONFIG_GENERATION_FAILED",
+		Project: "labs-stg",
+		Time:    time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatalf("Report failed: %v", err)
+	}
+
+	if path != "/sentry/api/42/store/" {
+		t.Errorf("Expected store endpoint of project 42, got %s", path)
+	}
+	if !strings.Contains(auth, "sentry_key=public-key") {
+		t.Errorf("Expected the DSN key in X-Sentry-Auth, got %s", auth)
+	}
+	if received.Message != "failed to list services" || received.Environment != "stg" || received.Release != "1.2.3" {
+		t.Errorf("Unexpected event: %+v", received)
+	}
+	if received.Tags["project"] != "labs-stg" || received.Tags["code"] == "" || received.Tags["service"] != "" {
+		t.Errorf("Unexpected tags: %v", received.Tags)
+	}
+	if received.Timestamp != "2026-10-01T12:00:00Z" || len(received.EventID) != 32 {
+		t.Errorf("Unexpected timestamp or event ID: %s %s", received.Timestamp, received.EventID)
+	}
+}
+
+func TestSentryReporter_ReportError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "rate limited", http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	reporter, err := NewSentry(strings.Replace(server.URL, "http://", "http://key@", 1)+"/1", "", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reporter.Report(context.Background(), Event{Message: "boom"}); err == nil || !strings.Contains(err.Error(), "429") {
+		t.Errorf("Expected status error, got %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+package errreport
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"time"
+
+	errorreporting "google.golang.org/api/clouderrorreporting/v1beta1"
+	"google.golang.org/api/option"
+)
+
+// gcpReporter reports to Google Cloud Error Reporting
+type gcpReporter struct {
+	events  *errorreporting.ProjectsEventsService
+	project string
+	service *errorreporting.ServiceContext
+}
+
+// NewGCP returns a reporter writing to Cloud Error Reporting in projectID
+// under service (default DefaultService). The caller needs
+// roles/errorreporting.writer.
+func NewGCP(ctx context.Context, projectID, service, version string, opts ...option.ClientOption) (Reporter, error) {
+	client, err := errorreporting.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Error Reporting client: %w", err)
+	}
+	if service == "" {
+		service = DefaultService
+	}
+	return &gcpReporter{
+		events:  client.Projects.Events,
+		project: "projects/" + projectID,
+		service: &errorreporting.ServiceContext{Service: service, Version: version},
+	}, nil
+}
+
+// Report sends the event. Error Reporting groups events by stack trace;
+// events without one are grouped by their code, set as the report
+// location's function name.
+func (r *gcpReporter) Report(ctx context.Context, event Event) error {
+	reported := &errorreporting.ReportedErrorEvent{
+		EventTime:      event.eventTime().UTC().Format(time.RFC3339Nano),
+		Message:        event.summary(),
+		ServiceContext: r.service,
+	}
+	if event.Stack != "" {
+		reported.Message += "\n\n" + event.Stack
+	} else {
+		location := &errorreporting.SourceLocation{FunctionName: event.Code}
+		if _, file, line, ok := runtime.Caller(1); ok {
+			location.FilePath, location.LineNumber = file, int64(line)
+		}
+		reported.Context = &errorreporting.ErrorContext{ReportLocation: location}
+	}
+
+	if _, err := r.events.Report(r.project, reported).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("failed to report error: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package errreport
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// sentryReporter reports to Sentry's store endpoint
+type sentryReporter struct {
+	storeURL    string
+	auth        string // X-Sentry-Auth header
+	environment string
+	release     string
+	client      *http.Client
+}
+
+// NewSentry returns a reporter sending to the Sentry project of dsn
+// (https://<key>@<host>/<project id>), tagging events with environment and
+// release. A nil client uses a client with a 10s timeout.
+func NewSentry(dsn, environment, release string, client *http.Client) (Reporter, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Sentry DSN: %w", err)
+	}
+	key := u.User.Username()
+	path, projectID := "", strings.Trim(u.Path, "/")
+	if i := strings.LastIndex(projectID, "/"); i >= 0 {
+		path, projectID = "/"+projectID[:i], projectID[i+1:]
+	}
+	if u.Scheme == "" || u.Host == "" || key == "" || projectID == "" {
+		return nil, fmt.Errorf("invalid Sentry DSN: expected https://<key>@<host>/<project id>")
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &sentryReporter{
+		storeURL:    fmt.Sprintf("%s://%s%s/api/%s/store/", u.Scheme, u.Host, path, projectID),
+		auth:        fmt.Sprintf("Sentry sentry_version=7, sentry_client=%s, sentry_key=%s", DefaultService, key),
+		environment: environment,
+		release:     release,
+		client:      client,
+	}, nil
+}
+
+// sentryEvent is the subset of Sentry's event payload the reporter sends
+type sentryEvent struct {
+	EventID     string            `json:"event_id"`
+	Timestamp   string            `json:"timestamp"`
+	Level       string            `json:"level"`
+	Platform    string            `json:"platform"`
+	Logger      string            `json:"logger"`
+	Message     string            `json:"message"`
+	Environment string            `json:"environment,omitempty"`
+	Release     string            `json:"release,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	Extra       map[string]string `json:"extra,omitempty"`
+	Fingerprint []string          `json:"fingerprint,omitempty"`
+}
+
+// Report sends the event. Events are tagged with their code, project and
+// service, and grouped by code.
+func (r *sentryReporter) Report(ctx context.Context, event Event) error {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return fmt.Errorf("failed to generate event ID: %w", err)
+	}
+	payload := sentryEvent{
+		EventID:     hex.EncodeToString(id),
+		Timestamp:   event.eventTime().UTC().Format(time.RFC3339),
+		Level:       "error",
+		Platform:    "go",
+		Logger:      DefaultService,
+		Message:     event.Message,
+		Environment: r.environment,
+		Release:     r.release,
+		Tags:        make(map[string]string),
+	}
+	for key, value := range map[string]string{"code": event.Code, "project": event.Project, "service": event.Service} {
+		if value != "" {
+			payload.Tags[key] = value
+		}
+	}
+	if event.Code != "" {
+		payload.Fingerprint = []string{event.Code, event.Project, event.Service}
+	}
+	if event.Stack != "" {
+		payload.Extra = map[string]string{"stack": event.Stack}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.storeURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Sentry-Auth", r.auth)
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to report error: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("sentry returned %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
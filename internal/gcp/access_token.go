@@ -0,0 +1,142 @@
+package gcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/impersonate"
+)
+
+// AccessTokenScope is the OAuth scope of GetAccessToken's tokens
+const AccessTokenScope = "https://www.googleapis.com/auth/cloud-platform"
+
+// accessTokenKey caches access tokens next to the identity tokens, which
+// are keyed by audience URL; the scopes are appended
+const accessTokenKey = "access_token"
+
+// GetAccessToken gets an OAuth access token for AccessTokenScope, for the
+// provider's own API calls (e.g. a GKE cluster's Kubernetes API)
+func (tm *TokenManager) GetAccessToken() (string, error) {
+	return tm.GetScopedAccessToken(AccessTokenScope)
+}
+
+// GetScopedAccessToken gets an OAuth access token for scopes, for backends
+// that check access tokens rather than identity tokens (Cloud Functions
+// behind API Gateway, GCS-backed sites). Access tokens have no audience, so
+// one token serves every backend using the same scopes. Caching, sharing of
+// concurrent fetches and the credential order (explicit credentials,
+// metadata server, ADC) are the same as for GetToken; the token is cached
+// no longer than its expiry.
+func (tm *TokenManager) GetScopedAccessToken(scopes ...string) (string, error) {
+	if len(scopes) == 0 {
+		return "", fmt.Errorf("no access token scopes given")
+	}
+	return tm.get(accessTokenKey+":"+strings.Join(scopes, " "), func() (string, time.Time, error) {
+		return tm.fetchAccess(scopes)
+	})
+}
+
+// fetchAccessToken fetches a new access token for scopes with the explicit
+// credentials, the metadata server or ADC
+func (tm *TokenManager) fetchAccessToken(scopes []string) (string, time.Time, error) {
+	if tm.credentials != nil {
+		creds, err := google.CredentialsFromJSON(context.Background(), tm.credentials.JSON, scopes...)
+		if err != nil {
+			return "", time.Time{}, fmt.Errorf("failed to create token source from credentials: %w", err)
+		}
+		return accessToken(creds.TokenSource, "credentials")
+	}
+
+	tm.mu.RLock()
+	metadataChecked, hasMetadata := tm.metadataChecked, tm.hasMetadata
+	tm.mu.RUnlock()
+
+	if !metadataChecked || hasMetadata {
+		token, expiry, err := tm.fetchAccessFromMetadata(scopes)
+		if err != nil {
+			if tm.isNoSuchHost(err) {
+				tm.setMetadataAvailable(false)
+				if tm.devMode {
+					return tm.fetchAccessFromADC(scopes)
+				}
+				return "", time.Time{}, fmt.Errorf("%w (running locally?): use CLOUDRUN_PROVIDER_DEV_MODE=true and gcloud auth application-default login", ErrMetadataUnavailable)
+			}
+			return "", time.Time{}, err
+		}
+		tm.setMetadataAvailable(true)
+		return token, expiry, nil
+	}
+	if tm.devMode {
+		return tm.fetchAccessFromADC(scopes)
+	}
+	return "", time.Time{}, fmt.Errorf("%w and dev mode disabled", ErrMetadataUnavailable)
+}
+
+// fetchAccessFromMetadata fetches an access token for the default service
+// account from the metadata server
+func (tm *TokenManager) fetchAccessFromMetadata(scopes []string) (string, time.Time, error) {
+	body, err := tm.getMetadata("instance/service-accounts/default/token?scopes=" + url.QueryEscape(strings.Join(scopes, ",")))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	var resp struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to parse access token: %w", err)
+	}
+	if resp.AccessToken == "" {
+		return "", time.Time{}, fmt.Errorf("metadata server returned empty access token")
+	}
+
+	var expiry time.Time
+	if resp.ExpiresIn > 0 {
+		expiry = time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second)
+	}
+	return resp.AccessToken, expiry, nil
+}
+
+// fetchAccessFromADC fetches an access token with Application Default
+// Credentials, impersonating IMPERSONATE_SERVICE_ACCOUNT when it is set.
+// Unlike identity tokens, user credentials can mint access tokens directly.
+func (tm *TokenManager) fetchAccessFromADC(scopes []string) (string, time.Time, error) {
+	ctx := context.Background()
+
+	if tm.impersonateServiceAccount != "" {
+		tokenSource, err := impersonate.CredentialsTokenSource(ctx, impersonate.CredentialsConfig{
+			TargetPrincipal: tm.impersonateServiceAccount,
+			Scopes:          scopes,
+		})
+		if err != nil {
+			return "", time.Time{}, fmt.Errorf("%w: failed to create impersonated token source for %s: %w",
+				ErrADCMissing, tm.impersonateServiceAccount, err)
+		}
+		return accessToken(tokenSource, "impersonation")
+	}
+
+	creds, err := google.FindDefaultCredentials(ctx, scopes...)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("%w: failed to find default credentials (did you run 'gcloud auth application-default login'?): %w", ErrADCMissing, err)
+	}
+	return accessToken(creds.TokenSource, "ADC")
+}
+
+// accessToken fetches a token from tokenSource; source names it in errors
+func accessToken(tokenSource oauth2.TokenSource, source string) (string, time.Time, error) {
+	token, err := tokenSource.Token()
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to fetch access token from %s: %w", source, err)
+	}
+	if token.AccessToken == "" {
+		return "", time.Time{}, fmt.Errorf("%s returned empty access token", source)
+	}
+	return token.AccessToken, token.Expiry, nil
+}
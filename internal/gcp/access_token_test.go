@@ -0,0 +1,95 @@
+package gcp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenManager_GetAccessTokenFromMetadata(t *testing.T) {
+	metadata := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/computeMetadata/v1/instance/service-accounts/default/token" ||
+			r.URL.Query().Get("scopes") != AccessTokenScope {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"access_token":"ya29.token","expires_in":120,"token_type":"Bearer"}`))
+	}))
+	defer metadata.Close()
+
+	tm := newTokenManager()
+	tm.metadataHost = strings.TrimPrefix(metadata.URL, "http://")
+
+	token, err := tm.GetAccessToken()
+	if err != nil {
+		t.Fatalf("GetAccessToken failed: %v", err)
+	}
+	if token != "ya29.token" {
+		t.Errorf("Expected access token, got %q", token)
+	}
+
+	// Cached no longer than the token's own expiry, not tokenCacheDuration
+	cached := tm.cache[accessTokenKey+":"+AccessTokenScope]
+	if cached == nil || time.Until(cached.ExpiresAt) > 2*time.Minute {
+		t.Errorf("Expected token cached until its expiry, got %+v", cached)
+	}
+}
+
+func TestTokenManager_GetAccessTokenCached(t *testing.T) {
+	tm := newTokenManager()
+	fetches := 0
+	tm.fetchAccess = func([]string) (string, time.Time, error) {
+		fetches++
+		return "ya29.token", time.Time{}, nil
+	}
+	tm.fetch = func(audience string) (string, error) { return "eyJ.id", nil }
+
+	for i := 0; i < 3; i++ {
+		if token, err := tm.GetAccessToken(); err != nil || token != "ya29.token" {
+			t.Fatalf("GetAccessToken = %q, %v", token, err)
+		}
+	}
+	if fetches != 1 {
+		t.Errorf("Expected one fetch, got %d", fetches)
+	}
+
+	// Identity tokens are cached separately
+	if token, _ := tm.GetToken("https://svc.run.app"); token != "eyJ.id" {
+		t.Errorf("Expected identity token, got %q", token)
+	}
+
+	tm.ClearCache()
+	if _, err := tm.GetAccessToken(); err != nil || fetches != 2 {
+		t.Errorf("Expected a fetch after ClearCache, got %d fetches (err %v)", fetches, err)
+	}
+}
+
+func TestTokenManager_GetScopedAccessToken(t *testing.T) {
+	tm := newTokenManager()
+	var requested []string
+	tm.fetchAccess = func(scopes []string) (string, time.Time, error) {
+		requested = append(requested, strings.Join(scopes, " "))
+		return "ya29." + scopes[0], time.Time{}, nil
+	}
+
+	const readOnly = "https://www.googleapis.com/auth/devstorage.read_only"
+	if token, err := tm.GetScopedAccessToken(readOnly); err != nil || token != "ya29."+readOnly {
+		t.Fatalf("GetScopedAccessToken = %q, %v", token, err)
+	}
+	// Tokens for other scopes are fetched and cached separately
+	if token, err := tm.GetAccessToken(); err != nil || token != "ya29."+AccessTokenScope {
+		t.Fatalf("GetAccessToken = %q, %v", token, err)
+	}
+	if _, err := tm.GetScopedAccessToken(readOnly); err != nil {
+		t.Fatalf("GetScopedAccessToken failed: %v", err)
+	}
+	if len(requested) != 2 || requested[0] != readOnly || requested[1] != AccessTokenScope {
+		t.Errorf("Expected one fetch per scope, got %v", requested)
+	}
+
+	if _, err := tm.GetScopedAccessToken(); err == nil {
+		t.Error("Expected an error without scopes")
+	}
+}
@@ -0,0 +1,47 @@
+package gcp
+
+import (
+	"net/url"
+	"strings"
+)
+
+// cloudRunDomains are the hosts whose identity token audience is the bare
+// service URL, so any path on them is dropped
+var cloudRunDomains = []string{".run.app"}
+
+// NormalizeAudience returns the canonical form of a token audience, so
+// spellings of the same audience share one cached token: the scheme and
+// host are lowercased, default ports and trailing slashes removed, and for
+// Cloud Run service URLs (*.run.app) the path, query and fragment are
+// dropped. Other URLs are treated as custom audiences and keep their path.
+// Values that aren't absolute URLs are only trimmed.
+func NormalizeAudience(audience string) string {
+	audience = strings.TrimSpace(audience)
+	u, err := url.Parse(audience)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return audience
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	if port := u.Port(); (u.Scheme == "https" && port == "443") || (u.Scheme == "http" && port == "80") {
+		u.Host = u.Hostname()
+	}
+
+	if isCloudRunHost(u.Hostname()) {
+		return u.Scheme + "://" + u.Host
+	}
+	u.Path = strings.TrimRight(u.Path, "/")
+	u.RawPath = ""
+	return u.String()
+}
+
+// isCloudRunHost reports whether host is a Cloud Run service URL host
+func isCloudRunHost(host string) bool {
+	for _, domain := range cloudRunDomains {
+		if strings.HasSuffix(host, domain) {
+			return true
+		}
+	}
+	return false
+}
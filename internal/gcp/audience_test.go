@@ -0,0 +1,30 @@
+package gcp
+
+import "testing"
+
+func TestNormalizeAudience(t *testing.T) {
+	tests := []struct {
+		audience string
+		expected string
+	}{
+		{"https://lab1-abc-uc.a.run.app", "https://lab1-abc-uc.a.run.app"},
+		{"https://lab1-abc-uc.a.run.app/", "https://lab1-abc-uc.a.run.app"},
+		{"HTTPS://Lab1-ABC-uc.a.run.app/api/v1?x=1#top", "https://lab1-abc-uc.a.run.app"},
+		{"https://lab1-abc-uc.a.run.app:443", "https://lab1-abc-uc.a.run.app"},
+		{"  https://lab1-abc-uc.a.run.app  ", "https://lab1-abc-uc.a.run.app"},
+		{"https://API.example.com/v1/", "https://api.example.com/v1"},
+		{"https://api.example.com/v1?aud=x", "https://api.example.com/v1?aud=x"},
+		{"http://backend.internal:80/", "http://backend.internal"},
+		{"http://backend.internal:8080", "http://backend.internal:8080"},
+		{"my-custom-audience", "my-custom-audience"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.audience, func(t *testing.T) {
+			if got := NormalizeAudience(tt.audience); got != tt.expected {
+				t.Errorf("NormalizeAudience(%q) = %q, want %q", tt.audience, got, tt.expected)
+			}
+		})
+	}
+}
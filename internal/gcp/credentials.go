@@ -0,0 +1,81 @@
+package gcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"google.golang.org/api/option"
+)
+
+// Credentials are explicit credentials used instead of the ambient identity
+// (metadata server or ADC), e.g. a least-privilege service account that
+// differs from Traefik's runtime identity
+type Credentials struct {
+	JSON []byte
+	Type option.CredentialsType
+}
+
+// credentialTypes maps the JSON "type" field to the credential types that can
+// mint identity tokens. authorized_user (gcloud user) credentials can't.
+var credentialTypes = map[string]option.CredentialsType{
+	"service_account":              option.ServiceAccount,
+	"impersonated_service_account": option.ImpersonatedServiceAccount,
+	"external_account":             option.ExternalAccount,
+}
+
+// LoadCredentials reads credentials from path or, when path is empty, from
+// the inline JSON. Returns nil when neither is set, meaning ambient
+// credentials are used.
+func LoadCredentials(path, inline string) (*Credentials, error) {
+	var data []byte
+	switch {
+	case path != "" && strings.TrimSpace(inline) != "":
+		return nil, fmt.Errorf("credentials file and credentials JSON are mutually exclusive")
+	case path != "":
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, fmt.Errorf("failed to read credentials file: %w", err)
+		}
+	case strings.TrimSpace(inline) != "":
+		data = []byte(inline)
+	default:
+		return nil, nil
+	}
+
+	var header struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, fmt.Errorf("failed to parse credentials: %w", err)
+	}
+	credType, ok := credentialTypes[header.Type]
+	if !ok {
+		return nil, fmt.Errorf("unsupported credentials type %q (expected service_account, impersonated_service_account or external_account)", header.Type)
+	}
+	return &Credentials{JSON: data, Type: credType}, nil
+}
+
+// ClientOptions returns the API client options that authenticate with the
+// credentials; nil credentials give no options (ambient credentials)
+func (c *Credentials) ClientOptions() []option.ClientOption {
+	if c == nil {
+		return nil
+	}
+	return []option.ClientOption{option.WithAuthCredentialsJSON(c.Type, c.JSON)}
+}
+
+// APIClientOptions returns the client options for a Google API at endpoint
+// (the default API host when empty) authenticating with credentials.
+// Plain http endpoints are emulators such as tests/fake-run-api and get no
+// authentication.
+func APIClientOptions(endpoint string, credentials *Credentials) []option.ClientOption {
+	if endpoint == "" {
+		return credentials.ClientOptions()
+	}
+	if strings.HasPrefix(endpoint, "http://") {
+		return []option.ClientOption{option.WithEndpoint(endpoint), option.WithoutAuthentication()}
+	}
+	return append([]option.ClientOption{option.WithEndpoint(endpoint)}, credentials.ClientOptions()...)
+}
@@ -0,0 +1,69 @@
+package gcp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/api/option"
+)
+
+const serviceAccountJSON = `{"type": "service_account", "client_email": "router@test-project.iam.gserviceaccount.com"}`
+
+func TestLoadCredentials(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "key.json")
+	if err := os.WriteFile(path, []byte(serviceAccountJSON), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	fromFile, err := LoadCredentials(path, "")
+	if err != nil {
+		t.Fatalf("Expected credentials from file, got: %v", err)
+	}
+	if fromFile.Type != option.ServiceAccount || len(fromFile.ClientOptions()) != 1 {
+		t.Errorf("Unexpected credentials: %+v", fromFile)
+	}
+
+	inline, err := LoadCredentials("", serviceAccountJSON)
+	if err != nil || inline.Type != option.ServiceAccount {
+		t.Errorf("Expected inline service account credentials, got %+v (err %v)", inline, err)
+	}
+
+	none, err := LoadCredentials("", "")
+	if err != nil || none != nil || none.ClientOptions() != nil {
+		t.Errorf("Expected no credentials, got %+v (err %v)", none, err)
+	}
+}
+
+func TestLoadCredentials_Invalid(t *testing.T) {
+	tests := map[string]struct{ path, inline string }{
+		"both set":       {path: "key.json", inline: serviceAccountJSON},
+		"missing file":   {path: filepath.Join(t.TempDir(), "missing.json")},
+		"not JSON":       {inline: "not-json"},
+		"user account":   {inline: `{"type": "authorized_user"}`},
+		"no type in key": {inline: `{}`},
+	}
+	for name, tt := range tests {
+		if _, err := LoadCredentials(tt.path, tt.inline); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestAPIClientOptions(t *testing.T) {
+	credentials, err := LoadCredentials("", serviceAccountJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := APIClientOptions("", credentials); len(got) != 1 {
+		t.Errorf("Default endpoint: expected only the credentials option, got %d options", len(got))
+	}
+	if got := APIClientOptions("https://europe-west1-run.googleapis.com/", credentials); len(got) != 2 {
+		t.Errorf("Regional endpoint: expected endpoint and credentials options, got %d options", len(got))
+	}
+	// An emulator gets no credentials: WithoutAuthentication conflicts with them
+	if got := APIClientOptions("http://localhost:8085/", credentials); len(got) != 2 {
+		t.Errorf("Emulator endpoint: expected endpoint and no-auth options, got %d options", len(got))
+	}
+}
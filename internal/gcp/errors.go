@@ -0,0 +1,14 @@
+package gcp
+
+import "errors"
+
+// Sentinel errors returned (wrapped) by TokenManager, for use with errors.Is
+var (
+	// ErrMetadataUnavailable means the GCP metadata server could not be reached
+	// or did not return a token (e.g. running outside GCP without dev mode)
+	ErrMetadataUnavailable = errors.New("metadata server not available")
+
+	// ErrADCMissing means Application Default Credentials are missing or can't
+	// mint identity tokens (e.g. user credentials without impersonation)
+	ErrADCMissing = errors.New("ADC credentials unavailable")
+)
@@ -0,0 +1,53 @@
+package gcp
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	secretmanager "google.golang.org/api/secretmanager/v1"
+)
+
+// SecretVersionName returns the Secret Manager version resource name for
+// name, which is either a full version name
+// (projects/<p>/secrets/<s>/versions/<v>) or a secret name
+// (projects/<p>/secrets/<s>), meaning its latest version
+func SecretVersionName(name string) (string, error) {
+	parts := strings.Split(strings.Trim(name, "/"), "/")
+	switch {
+	case len(parts) == 4 && parts[0] == "projects" && parts[2] == "secrets":
+		return strings.Join(parts, "/") + "/versions/latest", nil
+	case len(parts) == 6 && parts[0] == "projects" && parts[2] == "secrets" && parts[4] == "versions":
+		return strings.Join(parts, "/"), nil
+	}
+	return "", fmt.Errorf("invalid secret %q (expected projects/<project>/secrets/<secret>[/versions/<version>])", name)
+}
+
+// AccessSecret returns the payload of a Secret Manager secret version (see
+// SecretVersionName) with surrounding whitespace trimmed. Nil credentials
+// use the ambient identity.
+func AccessSecret(ctx context.Context, name string, credentials *Credentials) (string, error) {
+	version, err := SecretVersionName(name)
+	if err != nil {
+		return "", err
+	}
+
+	service, err := secretmanager.NewService(ctx, credentials.ClientOptions()...)
+	if err != nil {
+		return "", fmt.Errorf("failed to create Secret Manager client: %w", err)
+	}
+	resp, err := service.Projects.Secrets.Versions.Access(version).Context(ctx).Do()
+	if err != nil {
+		return "", fmt.Errorf("failed to access secret %s: %w", version, err)
+	}
+	if resp.Payload == nil {
+		return "", fmt.Errorf("secret %s has no payload", version)
+	}
+
+	data, err := base64.StdEncoding.DecodeString(resp.Payload.Data)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode secret %s: %w", version, err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
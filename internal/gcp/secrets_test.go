@@ -0,0 +1,26 @@
+package gcp
+
+import "testing"
+
+func TestSecretVersionName(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"projects/p/secrets/api-token", "projects/p/secrets/api-token/versions/latest"},
+		{"projects/p/secrets/api-token/versions/3", "projects/p/secrets/api-token/versions/3"},
+		{"/projects/p/secrets/api-token/", "projects/p/secrets/api-token/versions/latest"},
+	}
+	for _, tt := range tests {
+		got, err := SecretVersionName(tt.name)
+		if err != nil || got != tt.expected {
+			t.Errorf("SecretVersionName(%q) = %q, %v; expected %q", tt.name, got, err, tt.expected)
+		}
+	}
+
+	for _, name := range []string{"", "api-token", "projects/p/secrets", "projects/p/keys/k"} {
+		if _, err := SecretVersionName(name); err == nil {
+			t.Errorf("Expected error for %q", name)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -14,6 +15,32 @@ import (
 	"google.golang.org/api/impersonate"
 )
 
+// defaultMetadataHost is the GCP metadata server
+const defaultMetadataHost = "metadata.google.internal"
+
+// metadataProbeTimeout bounds the startup probe of the metadata server
+const metadataProbeTimeout = 2 * time.Second
+
+// Metadata server token request defaults, overridable with METADATA_TIMEOUT
+// and METADATA_RETRIES
+const (
+	defaultMetadataTimeout = 5 * time.Second
+	defaultMetadataRetries = 2
+)
+
+// metadataRetryBackoff is the delay before the first retry; it doubles on
+// every further attempt
+var metadataRetryBackoff = 100 * time.Millisecond
+
+// Credential paths reported by CredentialPath
+const (
+	CredentialPathExplicit      = "explicit-credentials"
+	CredentialPathMetadata      = "metadata-server"
+	CredentialPathADC           = "adc"
+	CredentialPathImpersonation = "adc-impersonation"
+	CredentialPathNone          = "none"
+)
+
 // TokenManager manages GCP identity tokens with caching and refresh
 type TokenManager struct {
 	cache                     map[string]*CachedToken
@@ -23,6 +50,24 @@ type TokenManager struct {
 	hasMetadata               bool          // Is metadata server available?
 	impersonateServiceAccount string        // Service account to impersonate for identity tokens
 	tokenCacheDuration        time.Duration // How long to cache tokens (default 55 minutes)
+	credentials               *Credentials  // Explicit credentials; skips metadata server and ADC when set
+	metadataHost              string        // Metadata server host[:port] (GCE_METADATA_HOST)
+	metadataTimeout           time.Duration // Timeout per metadata server request
+	metadataRetries           int           // Retries of transient metadata server failures
+
+	// In-flight fetches by audience, so concurrent callers share one fetch
+	inflight    map[string]*tokenFetch
+	fetch       func(audience string) (string, error)     // fetchToken; replaced in tests
+	fetchAccess func([]string) (string, time.Time, error) // fetchAccessToken; replaced in tests
+
+	counters tokenCounters // Hits, misses, fetch latency and failures; see Stats
+}
+
+// tokenFetch is a fetch in progress; done is closed once token and err are set
+type tokenFetch struct {
+	done  chan struct{}
+	token string
+	err   error
 }
 
 // CachedToken represents a cached identity token with expiry
@@ -31,8 +76,29 @@ type CachedToken struct {
 	ExpiresAt time.Time
 }
 
-// NewTokenManager creates a new token manager
+// NewTokenManager creates a new token manager and probes the metadata server,
+// so HasMetadataServer and CredentialPath are known before the first fetch
 func NewTokenManager() *TokenManager {
+	tm := newTokenManager()
+	tm.probeMetadata()
+	return tm
+}
+
+// NewTokenManagerWithCredentials creates a token manager that mints tokens
+// with explicit credentials instead of the metadata server or ADC.
+// Nil credentials behave like NewTokenManager.
+func NewTokenManagerWithCredentials(credentials *Credentials) *TokenManager {
+	if credentials == nil {
+		return NewTokenManager()
+	}
+	tm := newTokenManager()
+	tm.credentials = credentials
+	return tm
+}
+
+// newTokenManager creates a token manager from the environment without
+// probing the metadata server
+func newTokenManager() *TokenManager {
 	// Auto-detect development mode
 	devMode := os.Getenv("CLOUDRUN_PROVIDER_DEV_MODE") == "true" ||
 		os.Getenv("K_SERVICE") == "" // K_SERVICE is set in Cloud Run
@@ -49,77 +115,208 @@ func NewTokenManager() *TokenManager {
 		}
 	}
 
-	return &TokenManager{
+	metadataTimeout := defaultMetadataTimeout
+	if timeoutStr := os.Getenv("METADATA_TIMEOUT"); timeoutStr != "" {
+		if d, err := time.ParseDuration(timeoutStr); err == nil && d > 0 {
+			metadataTimeout = d
+		}
+	}
+	metadataRetries := defaultMetadataRetries
+	if retriesStr := os.Getenv("METADATA_RETRIES"); retriesStr != "" {
+		if n, err := strconv.Atoi(retriesStr); err == nil && n >= 0 {
+			metadataRetries = n
+		}
+	}
+
+	tm := &TokenManager{
 		cache:                     make(map[string]*CachedToken),
 		devMode:                   devMode,
 		impersonateServiceAccount: impersonateSA,
 		tokenCacheDuration:        cacheDuration,
+		metadataHost:              MetadataHost(),
+		metadataTimeout:           metadataTimeout,
+		metadataRetries:           metadataRetries,
+		inflight:                  make(map[string]*tokenFetch),
+		counters:                  newTokenCounters(),
+	}
+	tm.fetch = tm.fetchToken
+	tm.fetchAccess = tm.fetchAccessToken
+	return tm
+}
+
+// MetadataHost returns the metadata server host[:port]: GCE_METADATA_HOST
+// when set (a metadata proxy or emulator, as in the Google client libraries),
+// otherwise metadata.google.internal
+func MetadataHost() string {
+	if host := strings.TrimSpace(os.Getenv("GCE_METADATA_HOST")); host != "" {
+		return strings.TrimSuffix(strings.TrimPrefix(host, "http://"), "/")
+	}
+	return defaultMetadataHost
+}
+
+// probeMetadata checks whether the metadata server answers. Only a failed
+// DNS lookup (not running on GCP) marks it unavailable; other errors, such as
+// a timeout while the network comes up, leave the decision to the first fetch.
+func (tm *TokenManager) probeMetadata() {
+	req, err := http.NewRequest("GET", "http://"+tm.metadataHost+"/computeMetadata/v1/", nil)
+	if err != nil {
+		return
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	client := &http.Client{Timeout: metadataProbeTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		if tm.isNoSuchHost(err) {
+			tm.setMetadataAvailable(false)
+		}
+		return
+	}
+	resp.Body.Close()
+	tm.setMetadataAvailable(resp.StatusCode == http.StatusOK && resp.Header.Get("Metadata-Flavor") == "Google")
+}
+
+// setMetadataAvailable records the outcome of a metadata server check
+func (tm *TokenManager) setMetadataAvailable(available bool) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.metadataChecked = true
+	tm.hasMetadata = available
+}
+
+// isNoSuchHost reports whether err is a failed metadata server DNS lookup
+func (tm *TokenManager) isNoSuchHost(err error) bool {
+	return strings.Contains(err.Error(), "no such host") ||
+		strings.Contains(err.Error(), "lookup "+tm.metadataHost)
+}
+
+// CredentialPath returns how tokens will be minted: with explicit
+// credentials, from the metadata server, with ADC (optionally impersonating
+// a service account), or "none" when the metadata server is unavailable
+// outside dev mode. Before the metadata server has been checked it is
+// assumed to be available.
+func (tm *TokenManager) CredentialPath() string {
+	if tm.credentials != nil {
+		return CredentialPathExplicit
+	}
+	tm.mu.RLock()
+	metadataChecked, hasMetadata := tm.metadataChecked, tm.hasMetadata
+	tm.mu.RUnlock()
+
+	switch {
+	case !metadataChecked || hasMetadata:
+		return CredentialPathMetadata
+	case !tm.devMode:
+		return CredentialPathNone
+	case tm.impersonateServiceAccount != "":
+		return CredentialPathImpersonation
+	default:
+		return CredentialPathADC
 	}
 }
 
 // GetToken gets an identity token for the given audience (service URL)
 // Returns cached token if valid, otherwise fetches new token
 // Uses metadata server in GCP, falls back to ADC in local development
+//
+// Concurrent calls for the same audience share a single fetch: the first
+// caller fetches and the others wait for its result. Audiences are
+// normalized first (see NormalizeAudience), so spellings of the same URL
+// share one token.
 func (tm *TokenManager) GetToken(audience string) (string, error) {
-	// Check cache first
-	tm.mu.RLock()
-	cached, ok := tm.cache[audience]
-	tm.mu.RUnlock()
+	audience = NormalizeAudience(audience)
+	return tm.get(audience, func() (string, time.Time, error) {
+		token, err := tm.fetch(audience)
+		return token, time.Time{}, err
+	})
+}
 
-	if ok && time.Now().Before(cached.ExpiresAt) {
+// get returns the token cached under key or fetches it, sharing one fetch
+// between concurrent callers. A zero expiry from fetch means the token is
+// cached for tokenCacheDuration; otherwise the earlier of the two is used.
+func (tm *TokenManager) get(key string, fetch func() (string, time.Time, error)) (string, error) {
+	tm.mu.Lock()
+	if cached, ok := tm.cache[key]; ok && time.Now().Before(cached.ExpiresAt) {
+		tm.counters.hits++
+		tm.mu.Unlock()
 		return cached.Token, nil
 	}
+	tm.counters.misses++
+	if call, ok := tm.inflight[key]; ok {
+		tm.mu.Unlock()
+		<-call.done
+		return call.token, call.err
+	}
+	call := &tokenFetch{done: make(chan struct{})}
+	tm.inflight[key] = call
+	tm.mu.Unlock()
+
+	start := time.Now()
+	var expiry time.Time
+	call.token, expiry, call.err = fetch()
+	latency := time.Since(start)
+
+	tm.mu.Lock()
+	delete(tm.inflight, key)
+	tm.counters.fetchLatency.observe(latency)
+	if call.err != nil {
+		tm.counters.failures[key]++
+	} else {
+		// Cache token using configured duration
+		// Default is 55 minutes (GCP tokens expire after 1 hour)
+		expiresAt := time.Now().Add(tm.tokenCacheDuration)
+		if !expiry.IsZero() && expiry.Before(expiresAt) {
+			expiresAt = expiry
+		}
+		tm.cache[key] = &CachedToken{
+			Token:     call.token,
+			ExpiresAt: expiresAt,
+		}
+	}
+	tm.mu.Unlock()
+	close(call.done)
+
+	return call.token, call.err
+}
 
-	// Fetch new token
-	var token string
-	var err error
+// fetchToken fetches a new identity token with the explicit credentials, the
+// metadata server or ADC
+func (tm *TokenManager) fetchToken(audience string) (string, error) {
+	if tm.credentials != nil {
+		// Explicit credentials take precedence over the ambient identity
+		return tm.fetchWithCredentials(audience)
+	}
 
-	// Try metadata server first (works in Cloud Run/GCE/GKE)
-	if !tm.metadataChecked || tm.hasMetadata {
-		token, err = tm.fetchFromMetadata(audience)
+	tm.mu.RLock()
+	metadataChecked, hasMetadata := tm.metadataChecked, tm.hasMetadata
+	tm.mu.RUnlock()
+
+	if !metadataChecked || hasMetadata {
+		// Try metadata server first (works in Cloud Run/GCE/GKE)
+		token, err := tm.fetchFromMetadata(audience)
 		if err != nil {
 			// Check if it's a "no such host" error (running locally)
-			if strings.Contains(err.Error(), "no such host") ||
-				strings.Contains(err.Error(), "lookup metadata.google.internal") {
-				tm.mu.Lock()
-				tm.metadataChecked = true
-				tm.hasMetadata = false
-				tm.mu.Unlock()
+			if tm.isNoSuchHost(err) {
+				tm.setMetadataAvailable(false)
 
 				// Fall back to ADC in development mode
 				if tm.devMode {
 					return tm.fetchFromADC(audience)
 				}
-				return "", fmt.Errorf("metadata server not available (running locally?): use CLOUDRUN_PROVIDER_DEV_MODE=true and gcloud auth application-default login")
+				return "", fmt.Errorf("%w (running locally?): use CLOUDRUN_PROVIDER_DEV_MODE=true and gcloud auth application-default login", ErrMetadataUnavailable)
 			}
 			return "", err
 		}
 
 		// Metadata server worked
-		tm.mu.Lock()
-		tm.metadataChecked = true
-		tm.hasMetadata = true
-		tm.mu.Unlock()
-	} else if tm.devMode {
-		// Metadata server not available, use ADC
-		token, err = tm.fetchFromADC(audience)
-		if err != nil {
-			return "", err
-		}
-	} else {
-		return "", fmt.Errorf("metadata server not available and dev mode disabled")
+		tm.setMetadataAvailable(true)
+		return token, nil
 	}
-
-	// Cache token using configured duration
-	// Default is 55 minutes (GCP tokens expire after 1 hour)
-	tm.mu.Lock()
-	tm.cache[audience] = &CachedToken{
-		Token:     token,
-		ExpiresAt: time.Now().Add(tm.tokenCacheDuration),
+	if tm.devMode {
+		// Metadata server not available, use ADC
+		return tm.fetchFromADC(audience)
 	}
-	tm.mu.Unlock()
-
-	return token, nil
+	return "", fmt.Errorf("%w and dev mode disabled", ErrMetadataUnavailable)
 }
 
 // fetchFromMetadata fetches an identity token from the GCP metadata server
@@ -127,21 +324,50 @@ func (tm *TokenManager) GetToken(audience string) (string, error) {
 func (tm *TokenManager) fetchFromMetadata(audience string) (string, error) {
 	// URL-encode the audience
 	encodedAudience := strings.ReplaceAll(strings.ReplaceAll(audience, ":", "%3A"), "/", "%2F")
-	url := fmt.Sprintf(
-		"http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/identity?audience=%s",
-		encodedAudience,
-	)
+	body, err := tm.getMetadata("instance/service-accounts/default/identity?audience=" + encodedAudience)
+	if err != nil {
+		return "", err
+	}
+
+	tokenStr := strings.TrimSpace(string(body))
+	if !strings.HasPrefix(tokenStr, "eyJ") {
+		return "", fmt.Errorf("token doesn't look valid (doesn't start with eyJ)")
+	}
+
+	return tokenStr, nil
+}
+
+// getMetadata reads a path under /computeMetadata/v1/ from the metadata
+// server. Timeouts, connection errors and 5xx responses are retried up to
+// metadataRetries times with exponential backoff. A failed DNS lookup (not
+// running on GCP) and other responses are returned immediately.
+func (tm *TokenManager) getMetadata(path string) ([]byte, error) {
+	backoff := metadataRetryBackoff
+	for attempt := 0; ; attempt++ {
+		body, retry, err := tm.requestMetadata(path)
+		if err == nil || !retry || attempt >= tm.metadataRetries {
+			return body, err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// requestMetadata makes one metadata server request and reports whether a
+// failure is worth retrying
+func (tm *TokenManager) requestMetadata(path string) (body []byte, retry bool, err error) {
+	url := fmt.Sprintf("http://%s/computeMetadata/v1/%s", tm.metadataHost, path)
 
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return nil, false, fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("Metadata-Flavor", "Google")
 
-	client := &http.Client{Timeout: 5 * time.Second}
+	client := &http.Client{Timeout: tm.metadataTimeout}
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to fetch token from metadata server: %w", err)
+		return nil, !tm.isNoSuchHost(err), fmt.Errorf("%w: failed to fetch token: %w", ErrMetadataUnavailable, err)
 	}
 	defer resp.Body.Close()
 
@@ -150,20 +376,14 @@ func (tm *TokenManager) fetchFromMetadata(audience string) (string, error) {
 		if err != nil {
 			body = []byte("<failed to read body>")
 		}
-		return "", fmt.Errorf("metadata server returned %d: %s", resp.StatusCode, string(body))
+		return nil, resp.StatusCode >= 500, fmt.Errorf("%w: returned %d: %s", ErrMetadataUnavailable, resp.StatusCode, string(body))
 	}
 
-	token, err := io.ReadAll(resp.Body)
+	body, err = io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("failed to read token: %w", err)
+		return nil, true, fmt.Errorf("failed to read token: %w", err)
 	}
-
-	tokenStr := strings.TrimSpace(string(token))
-	if !strings.HasPrefix(tokenStr, "eyJ") {
-		return "", fmt.Errorf("token doesn't look valid (doesn't start with eyJ)")
-	}
-
-	return tokenStr, nil
+	return body, false, nil
 }
 
 // fetchFromADC fetches an identity token using Application Default Credentials
@@ -188,13 +408,13 @@ func (tm *TokenManager) fetchFromADC(audience string) (string, error) {
 		// Check if it's the "unsupported credentials type" error
 		if strings.Contains(err.Error(), "unsupported credentials type") ||
 			strings.Contains(err.Error(), "authorized_user") {
-			return "", fmt.Errorf("failed to create token source with ADC: %w\n"+
+			return "", fmt.Errorf("%w: failed to create token source: %w\n"+
 				"  HINT: User credentials cannot generate identity tokens directly.\n"+
 				"  Set IMPERSONATE_SERVICE_ACCOUNT=<service-account>@<project>.iam.gserviceaccount.com\n"+
 				"  to impersonate a service account that can generate identity tokens.\n"+
-				"  Your user account needs 'Service Account Token Creator' role on that SA.", err)
+				"  Your user account needs 'Service Account Token Creator' role on that SA.", ErrADCMissing, err)
 		}
-		return "", fmt.Errorf("failed to create token source with ADC (did you run 'gcloud auth application-default login'?): %w", err)
+		return "", fmt.Errorf("%w: failed to create token source (did you run 'gcloud auth application-default login'?): %w", ErrADCMissing, err)
 	}
 
 	token, err := tokenSource.Token()
@@ -209,6 +429,25 @@ func (tm *TokenManager) fetchFromADC(audience string) (string, error) {
 	return token.AccessToken, nil
 }
 
+// fetchWithCredentials fetches an identity token with the explicit credentials
+func (tm *TokenManager) fetchWithCredentials(audience string) (string, error) {
+	tokenSource, err := idtoken.NewTokenSource(context.Background(), audience,
+		idtoken.WithAuthCredentialsJSON(tm.credentials.Type, tm.credentials.JSON))
+	if err != nil {
+		return "", fmt.Errorf("failed to create token source from credentials: %w", err)
+	}
+
+	token, err := tokenSource.Token()
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch token with credentials: %w", err)
+	}
+	if token.AccessToken == "" {
+		return "", fmt.Errorf("credentials returned empty token")
+	}
+
+	return token.AccessToken, nil
+}
+
 // fetchWithImpersonation fetches an identity token by impersonating a service account
 // This allows user credentials to generate identity tokens for Cloud Run services
 func (tm *TokenManager) fetchWithImpersonation(ctx context.Context, audience string) (string, error) {
@@ -224,9 +463,9 @@ func (tm *TokenManager) fetchWithImpersonation(ctx context.Context, audience str
 	// This uses ADC (user credentials) to impersonate the service account
 	idTokenSource, err := impersonate.IDTokenSource(ctx, idTokenConfig)
 	if err != nil {
-		return "", fmt.Errorf("failed to create impersonated ID token source for %s: %w\n"+
+		return "", fmt.Errorf("%w: failed to create impersonated ID token source for %s: %w\n"+
 			"  HINT: Ensure your user account has 'Service Account Token Creator' role on %s",
-			tm.impersonateServiceAccount, err, tm.impersonateServiceAccount)
+			ErrADCMissing, tm.impersonateServiceAccount, err, tm.impersonateServiceAccount)
 	}
 
 	// Get the identity token
@@ -254,6 +493,18 @@ func (tm *TokenManager) HasMetadataServer() bool {
 	return tm.hasMetadata
 }
 
+// Invalidate drops the cached token for an audience so the next GetToken
+// mints a new one, e.g. when the token may have been exposed. It reports
+// whether a token was cached. A fetch already in flight is not affected.
+func (tm *TokenManager) Invalidate(audience string) bool {
+	audience = NormalizeAudience(audience)
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	_, ok := tm.cache[audience]
+	delete(tm.cache, audience)
+	return ok
+}
+
 // ClearCache clears all cached tokens
 func (tm *TokenManager) ClearCache() {
 	tm.mu.Lock()
@@ -261,7 +512,8 @@ func (tm *TokenManager) ClearCache() {
 	tm.cache = make(map[string]*CachedToken)
 }
 
-// CacheStats returns cache statistics for monitoring
+// CacheStats returns cache statistics for monitoring. See Stats for the
+// full set of counters.
 func (tm *TokenManager) CacheStats() (total int, expired int) {
 	tm.mu.RLock()
 	defer tm.mu.RUnlock()
@@ -1,6 +1,12 @@
 package gcp
 
 import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -72,3 +78,298 @@ func TestTokenManager_ClearCache(t *testing.T) {
 
 // Note: Testing fetchFromMetadata requires mocking the metadata server
 // or running in a GCP environment. Integration tests should cover this.
+
+func TestTokenManager_GetTokenSingleFlight(t *testing.T) {
+	tm := NewTokenManager()
+
+	var fetches int32
+	release := make(chan struct{})
+	tm.fetch = func(audience string) (string, error) {
+		atomic.AddInt32(&fetches, 1)
+		<-release
+		return "eyJ-" + audience, nil
+	}
+
+	const callers = 10
+	var wg sync.WaitGroup
+	tokens := make([]string, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			tokens[i], _ = tm.GetToken("https://svc.run.app")
+		}(i)
+	}
+
+	// Let every caller reach the in-flight fetch before it completes
+	for {
+		tm.mu.RLock()
+		_, started := tm.inflight["https://svc.run.app"]
+		tm.mu.RUnlock()
+		if started {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if fetches != 1 {
+		t.Errorf("Expected 1 fetch for concurrent callers, got %d", fetches)
+	}
+	for i, token := range tokens {
+		if token != "eyJ-https://svc.run.app" {
+			t.Errorf("Caller %d got token %q", i, token)
+		}
+	}
+
+	// The result is cached for later callers
+	if _, err := tm.GetToken("https://svc.run.app"); err != nil || fetches != 1 {
+		t.Errorf("Expected cached token, got err=%v fetches=%d", err, fetches)
+	}
+}
+
+func TestTokenManager_GetTokenErrorNotCached(t *testing.T) {
+	tm := NewTokenManager()
+
+	var fetches int32
+	tm.fetch = func(string) (string, error) {
+		atomic.AddInt32(&fetches, 1)
+		return "", errors.New("metadata unavailable")
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := tm.GetToken("https://svc.run.app"); err == nil {
+			t.Fatal("Expected fetch error")
+		}
+	}
+	if fetches != 2 {
+		t.Errorf("Expected failed fetch to be retried, got %d fetches", fetches)
+	}
+	if len(tm.inflight) != 0 {
+		t.Errorf("Expected no fetches left in flight, got %d", len(tm.inflight))
+	}
+}
+
+func TestTokenManager_Stats(t *testing.T) {
+	tm := NewTokenManager()
+	tm.fetch = func(audience string) (string, error) {
+		if audience == "https://broken.run.app" {
+			return "", errors.New("denied")
+		}
+		return "eyJtoken", nil
+	}
+
+	tm.GetToken("https://svc.run.app")    // miss, fetch
+	tm.GetToken("https://svc.run.app")    // hit
+	tm.GetToken("https://broken.run.app") // miss, failed fetch
+	tm.GetToken("https://broken.run.app") // miss, failed fetch
+	tm.cache["https://old.run.app"] = &CachedToken{Token: "eyJold", ExpiresAt: time.Now().Add(-time.Minute)}
+
+	stats := tm.Stats()
+	if stats.Hits != 1 || stats.Misses != 3 {
+		t.Errorf("Expected 1 hit and 3 misses, got %d and %d", stats.Hits, stats.Misses)
+	}
+	if stats.Cached != 2 || stats.Expired != 1 {
+		t.Errorf("Expected 2 cached and 1 expired, got %d and %d", stats.Cached, stats.Expired)
+	}
+	if stats.FetchLatency.Count != 3 || stats.FetchLatency.Counts[0] != 3 {
+		t.Errorf("Expected 3 fast fetches, got %+v", stats.FetchLatency)
+	}
+	if len(stats.Failures) != 1 || stats.Failures["https://broken.run.app"] != 2 {
+		t.Errorf("Expected 2 failures for the broken audience, got %v", stats.Failures)
+	}
+
+	// Default cache duration is 55m: the fresh token falls in the <= 1h bucket
+	if stats.Expiry.Count != 1 || stats.Expiry.Counts[len(expiryBuckets)-1] != 1 {
+		t.Errorf("Expected one token in the 1h expiry bucket, got %+v", stats.Expiry)
+	}
+
+	// Snapshots don't share state with the manager
+	stats.Failures["https://broken.run.app"] = 0
+	stats.FetchLatency.Counts[0] = 0
+	if again := tm.Stats(); again.Failures["https://broken.run.app"] != 2 || again.FetchLatency.Counts[0] != 3 {
+		t.Error("Expected Stats to return a copy")
+	}
+}
+
+func TestHistogram_Observe(t *testing.T) {
+	h := newHistogram([]time.Duration{time.Second, time.Minute})
+	for _, d := range []time.Duration{time.Second, 2 * time.Second, time.Hour} {
+		h.observe(d)
+	}
+	if h.Counts[0] != 1 || h.Counts[1] != 1 || h.Counts[2] != 1 {
+		t.Errorf("Unexpected bucket counts %v", h.Counts)
+	}
+	if h.Count != 3 || h.Sum != time.Hour+3*time.Second {
+		t.Errorf("Unexpected count %d / sum %s", h.Count, h.Sum)
+	}
+}
+
+func TestTokenManager_GetTokenNormalizesAudience(t *testing.T) {
+	tm := NewTokenManager()
+
+	var audiences []string
+	tm.fetch = func(audience string) (string, error) {
+		audiences = append(audiences, audience)
+		return "eyJtoken", nil
+	}
+
+	for _, audience := range []string{
+		"https://lab1-abc-uc.a.run.app",
+		"https://lab1-abc-uc.a.run.app/",
+		"HTTPS://LAB1-abc-uc.a.run.app/lab1",
+	} {
+		if _, err := tm.GetToken(audience); err != nil {
+			t.Fatalf("GetToken(%q) failed: %v", audience, err)
+		}
+	}
+
+	if len(audiences) != 1 || audiences[0] != "https://lab1-abc-uc.a.run.app" {
+		t.Errorf("Expected one fetch for the normalized audience, got %v", audiences)
+	}
+	if total, _ := tm.CacheStats(); total != 1 {
+		t.Errorf("Expected one cache entry, got %d", total)
+	}
+}
+
+func TestTokenManager_Invalidate(t *testing.T) {
+	tm := NewTokenManager()
+	tm.cache["https://svc.run.app"] = &CachedToken{Token: "eyJold", ExpiresAt: time.Now().Add(time.Hour)}
+
+	if !tm.Invalidate("https://SVC.run.app/") {
+		t.Error("Expected the cached token to be invalidated")
+	}
+	if tm.Invalidate("https://svc.run.app") {
+		t.Error("Expected nothing left to invalidate")
+	}
+
+	tm.fetch = func(string) (string, error) { return "eyJnew", nil }
+	if token, _ := tm.GetToken("https://svc.run.app"); token != "eyJnew" {
+		t.Errorf("Expected a freshly minted token, got %q", token)
+	}
+}
+
+func TestTokenManager_ProbeMetadata(t *testing.T) {
+	metadata := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Metadata-Flavor", "Google")
+	}))
+	defer metadata.Close()
+
+	tm := newTokenManager()
+	tm.metadataHost = strings.TrimPrefix(metadata.URL, "http://")
+	tm.probeMetadata()
+	if !tm.HasMetadataServer() || tm.CredentialPath() != CredentialPathMetadata {
+		t.Errorf("Expected metadata server to be detected, got path %q", tm.CredentialPath())
+	}
+
+	// A server that isn't the metadata server (no Metadata-Flavor response header)
+	other := httptest.NewServer(http.NotFoundHandler())
+	defer other.Close()
+	tm = newTokenManager()
+	tm.metadataHost = strings.TrimPrefix(other.URL, "http://")
+	tm.probeMetadata()
+	if tm.HasMetadataServer() {
+		t.Error("Expected a non-metadata server to be rejected")
+	}
+}
+
+func TestTokenManager_CredentialPath(t *testing.T) {
+	tm := newTokenManager()
+	if tm.CredentialPath() != CredentialPathMetadata {
+		t.Errorf("Expected metadata server to be assumed before probing, got %q", tm.CredentialPath())
+	}
+
+	tm.setMetadataAvailable(false)
+	tm.devMode = false
+	if tm.CredentialPath() != CredentialPathNone {
+		t.Errorf("Expected no credential path outside dev mode, got %q", tm.CredentialPath())
+	}
+	tm.devMode = true
+	if tm.CredentialPath() != CredentialPathADC {
+		t.Errorf("Expected ADC in dev mode, got %q", tm.CredentialPath())
+	}
+	tm.impersonateServiceAccount = "sa@project.iam.gserviceaccount.com"
+	if tm.CredentialPath() != CredentialPathImpersonation {
+		t.Errorf("Expected impersonation, got %q", tm.CredentialPath())
+	}
+
+	tm = NewTokenManagerWithCredentials(&Credentials{JSON: []byte("{}")})
+	if tm.CredentialPath() != CredentialPathExplicit {
+		t.Errorf("Expected explicit credentials, got %q", tm.CredentialPath())
+	}
+}
+
+func TestTokenManager_MetadataEnvironment(t *testing.T) {
+	t.Setenv("GCE_METADATA_HOST", "http://127.0.0.1:8080/")
+	t.Setenv("METADATA_TIMEOUT", "750ms")
+	t.Setenv("METADATA_RETRIES", "0")
+	tm := newTokenManager()
+	if tm.metadataHost != "127.0.0.1:8080" || tm.metadataTimeout != 750*time.Millisecond || tm.metadataRetries != 0 {
+		t.Errorf("Got host %q, timeout %v, retries %d", tm.metadataHost, tm.metadataTimeout, tm.metadataRetries)
+	}
+
+	// Invalid values keep the defaults
+	t.Setenv("GCE_METADATA_HOST", "")
+	t.Setenv("METADATA_TIMEOUT", "soon")
+	t.Setenv("METADATA_RETRIES", "-1")
+	tm = newTokenManager()
+	if tm.metadataHost != defaultMetadataHost || tm.metadataTimeout != defaultMetadataTimeout || tm.metadataRetries != defaultMetadataRetries {
+		t.Errorf("Got host %q, timeout %v, retries %d", tm.metadataHost, tm.metadataTimeout, tm.metadataRetries)
+	}
+}
+
+func TestTokenManager_FetchFromMetadataRetries(t *testing.T) {
+	defer func(backoff time.Duration) { metadataRetryBackoff = backoff }(metadataRetryBackoff)
+	metadataRetryBackoff = time.Millisecond
+
+	tests := []struct {
+		name     string
+		statuses []int // Response status per attempt; the last one repeats
+		retries  int
+		wantErr  bool
+		attempts int32
+	}{
+		{"succeeds after transient failures", []int{503, 500, 200}, 2, false, 3},
+		{"gives up after retries", []int{503}, 2, true, 3},
+		{"no retries", []int{503, 200}, 0, true, 1},
+		{"client errors are not retried", []int{403, 200}, 2, true, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			metadata := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := int(attempts.Add(1)) - 1
+				if n >= len(tt.statuses) {
+					n = len(tt.statuses) - 1
+				}
+				w.WriteHeader(tt.statuses[n])
+				if tt.statuses[n] == http.StatusOK {
+					w.Write([]byte("eyJ.token\n"))
+				}
+			}))
+			defer metadata.Close()
+
+			tm := newTokenManager()
+			tm.metadataHost = strings.TrimPrefix(metadata.URL, "http://")
+			tm.metadataRetries = tt.retries
+
+			token, err := tm.fetchFromMetadata("https://svc.run.app")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("fetchFromMetadata error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && token != "eyJ.token" {
+				t.Errorf("Expected token, got %q", token)
+			}
+			if got := attempts.Load(); got != tt.attempts {
+				t.Errorf("Expected %d attempts, got %d", tt.attempts, got)
+			}
+		})
+	}
+}
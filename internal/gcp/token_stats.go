@@ -0,0 +1,106 @@
+package gcp
+
+import "time"
+
+// Bucket upper bounds for the token histograms
+var (
+	fetchLatencyBuckets = []time.Duration{
+		50 * time.Millisecond, 100 * time.Millisecond, 250 * time.Millisecond,
+		500 * time.Millisecond, time.Second, 2500 * time.Millisecond, 5 * time.Second,
+	}
+	expiryBuckets = []time.Duration{5 * time.Minute, 15 * time.Minute, 30 * time.Minute, time.Hour}
+)
+
+// Histogram counts observations per bucket. Counts[i] holds observations
+// <= Bounds[i] (and above the previous bound); the last element counts those
+// above every bound.
+type Histogram struct {
+	Bounds []time.Duration
+	Counts []uint64
+	Sum    time.Duration
+	Count  uint64
+}
+
+// newHistogram returns an empty histogram with the given bucket bounds
+func newHistogram(bounds []time.Duration) Histogram {
+	return Histogram{Bounds: bounds, Counts: make([]uint64, len(bounds)+1)}
+}
+
+// observe adds one observation
+func (h *Histogram) observe(d time.Duration) {
+	i := 0
+	for i < len(h.Bounds) && d > h.Bounds[i] {
+		i++
+	}
+	h.Counts[i]++
+	h.Sum += d
+	h.Count++
+}
+
+// clone returns a copy that doesn't share the counts
+func (h Histogram) clone() Histogram {
+	h.Counts = append([]uint64(nil), h.Counts...)
+	return h
+}
+
+// TokenStats is a snapshot of the token cache and fetch counters for
+// monitoring. Counters start at zero when the TokenManager is created.
+type TokenStats struct {
+	Cached  int // Tokens in the cache
+	Expired int // Cached tokens past their expiry
+
+	Hits   uint64 // GetToken calls answered from the cache
+	Misses uint64 // GetToken calls that fetched or waited for a fetch
+
+	// FetchLatency is the duration of every token fetch, successful or not
+	FetchLatency Histogram
+
+	// Failures counts failed fetches per audience
+	Failures map[string]uint64
+
+	// Expiry buckets the unexpired cached tokens by remaining lifetime
+	Expiry Histogram
+}
+
+// tokenCounters are the TokenManager's running counters, guarded by its mutex
+type tokenCounters struct {
+	hits, misses uint64
+	fetchLatency Histogram
+	failures     map[string]uint64
+}
+
+// newTokenCounters returns zeroed counters
+func newTokenCounters() tokenCounters {
+	return tokenCounters{
+		fetchLatency: newHistogram(fetchLatencyBuckets),
+		failures:     make(map[string]uint64),
+	}
+}
+
+// Stats returns a snapshot of the cache and fetch counters
+func (tm *TokenManager) Stats() TokenStats {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+
+	stats := TokenStats{
+		Cached:       len(tm.cache),
+		Hits:         tm.counters.hits,
+		Misses:       tm.counters.misses,
+		FetchLatency: tm.counters.fetchLatency.clone(),
+		Failures:     make(map[string]uint64, len(tm.counters.failures)),
+		Expiry:       newHistogram(expiryBuckets),
+	}
+	for audience, n := range tm.counters.failures {
+		stats.Failures[audience] = n
+	}
+
+	now := time.Now()
+	for _, cached := range tm.cache {
+		if now.After(cached.ExpiresAt) {
+			stats.Expired++
+			continue
+		}
+		stats.Expiry.observe(cached.ExpiresAt.Sub(now))
+	}
+	return stats
+}
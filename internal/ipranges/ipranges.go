@@ -0,0 +1,123 @@
+// Package ipranges fetches Google's published IP ranges so the front ends
+// in front of Cloud Run can be trusted for X-Forwarded-* headers.
+package ipranges
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// GoogleRangesURL lists the IP ranges Google uses for its services,
+// including the Google Front Ends that proxy requests to Cloud Run
+const GoogleRangesURL = "https://www.gstatic.com/ipranges/goog.json"
+
+// DefaultRefresh is how long fetched ranges are reused before fetching again
+const DefaultRefresh = 24 * time.Hour
+
+// document is the published netblock format shared by goog.json and cloud.json
+type document struct {
+	SyncToken    string `json:"syncToken"`
+	CreationTime string `json:"creationTime"`
+	Prefixes     []struct {
+		IPv4Prefix string `json:"ipv4Prefix,omitempty"`
+		IPv6Prefix string `json:"ipv6Prefix,omitempty"`
+	} `json:"prefixes"`
+}
+
+// Fetcher fetches and caches a published range list
+type Fetcher struct {
+	url     string
+	refresh time.Duration
+	client  *http.Client
+
+	mu        sync.Mutex
+	ranges    []string
+	fetchedAt time.Time
+}
+
+// NewFetcher creates a fetcher for url ("" = GoogleRangesURL) that refetches
+// after refresh (0 = DefaultRefresh)
+func NewFetcher(url string, refresh time.Duration) *Fetcher {
+	if url == "" {
+		url = GoogleRangesURL
+	}
+	if refresh <= 0 {
+		refresh = DefaultRefresh
+	}
+	return &Fetcher{
+		url:     url,
+		refresh: refresh,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// URL returns the address ranges are fetched from
+func (f *Fetcher) URL() string {
+	return f.url
+}
+
+// Due reports whether the cached ranges are missing or older than the refresh interval
+func (f *Fetcher) Due(now time.Time) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.ranges == nil || now.Sub(f.fetchedAt) >= f.refresh
+}
+
+// Ranges returns the published CIDR ranges, fetching them when the cache is
+// due. If a refetch fails, the previously fetched ranges are returned along
+// with the error; with nothing cached only the error is returned.
+func (f *Fetcher) Ranges(now time.Time) ([]string, error) {
+	if !f.Due(now) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		return f.ranges, nil
+	}
+
+	ranges, err := f.fetch()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err != nil {
+		return f.ranges, err
+	}
+	f.ranges = ranges
+	f.fetchedAt = now
+	return ranges, nil
+}
+
+// fetch downloads and parses the range list, validating every prefix
+func (f *Fetcher) fetch() ([]string, error) {
+	resp, err := f.client.Get(f.url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch IP ranges from %s: %w", f.url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch IP ranges from %s: HTTP %d", f.url, resp.StatusCode)
+	}
+
+	var doc document
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to parse IP ranges from %s: %w", f.url, err)
+	}
+
+	ranges := make([]string, 0, len(doc.Prefixes))
+	for _, prefix := range doc.Prefixes {
+		cidr := prefix.IPv4Prefix
+		if cidr == "" {
+			cidr = prefix.IPv6Prefix
+		}
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return nil, fmt.Errorf("invalid prefix %q in IP ranges from %s", cidr, f.url)
+		}
+		ranges = append(ranges, cidr)
+	}
+	if len(ranges) == 0 {
+		return nil, fmt.Errorf("no prefixes in IP ranges from %s", f.url)
+	}
+	return ranges, nil
+}
@@ -0,0 +1,86 @@
+package ipranges
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const sampleRanges = `{
+  "syncToken": "1700000000000",
+  "creationTime": "2026-10-01T00:00:00",
+  "prefixes": [
+    {"ipv4Prefix": "35.191.0.0/16"},
+    {"ipv4Prefix": "130.211.0.0/22"},
+    {"ipv6Prefix": "2600:1900::/28"}
+  ]
+}`
+
+func TestFetcherRanges(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls++
+		w.Write([]byte(sampleRanges))
+	}))
+	defer server.Close()
+
+	f := NewFetcher(server.URL, time.Hour)
+	now := time.Now()
+
+	ranges, err := f.Ranges(now)
+	if err != nil {
+		t.Fatalf("Expected ranges, got: %v", err)
+	}
+	want := []string{"35.191.0.0/16", "130.211.0.0/22", "2600:1900::/28"}
+	if !reflect.DeepEqual(ranges, want) {
+		t.Errorf("Expected %v, got %v", want, ranges)
+	}
+
+	// Cached until the refresh interval passes
+	if _, err := f.Ranges(now.Add(30 * time.Minute)); err != nil || calls != 1 {
+		t.Errorf("Expected cached ranges without a second fetch, got %d fetches (err %v)", calls, err)
+	}
+	if !f.Due(now.Add(time.Hour)) {
+		t.Error("Expected ranges to be due after the refresh interval")
+	}
+}
+
+func TestFetcherRanges_KeepsCachedOnError(t *testing.T) {
+	fail := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(sampleRanges))
+	}))
+	defer server.Close()
+
+	f := NewFetcher(server.URL, time.Minute)
+	now := time.Now()
+	if _, err := f.Ranges(now); err != nil {
+		t.Fatalf("Expected first fetch to succeed, got: %v", err)
+	}
+
+	fail = true
+	ranges, err := f.Ranges(now.Add(2 * time.Minute))
+	if err == nil {
+		t.Error("Expected refetch error")
+	}
+	if len(ranges) != 3 {
+		t.Errorf("Expected previously fetched ranges, got %v", ranges)
+	}
+}
+
+func TestFetcherRanges_InvalidPrefix(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte(`{"prefixes": [{"ipv4Prefix": "not-a-cidr"}]}`))
+	}))
+	defer server.Close()
+
+	if _, err := NewFetcher(server.URL, 0).Ranges(time.Now()); err == nil {
+		t.Error("Expected invalid prefix to be rejected")
+	}
+}
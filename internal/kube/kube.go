@@ -0,0 +1,409 @@
+// Package kube writes ConfigMaps and Secrets through the Kubernetes API, so
+// the routes can be mounted into a Traefik running on GKE. It speaks the
+// REST API directly and supports the in-cluster service account and the
+// kubeconfig credentials the provider needs, not client-go's full set.
+package kube
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ManagedByLabel marks the objects the provider created
+const ManagedByLabel = "app.kubernetes.io/managed-by"
+
+// managedBy is the ManagedByLabel value
+const managedBy = "traefik-cloudrun-provider"
+
+// serviceAccountDir holds the in-cluster service account token, CA and namespace
+var serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// Object kinds Apply writes
+const (
+	KindConfigMap = "configmaps"
+	KindSecret    = "secrets"
+)
+
+// TokenSource returns a bearer token for the API server
+type TokenSource func() (string, error)
+
+// Client calls the API server of one cluster
+type Client struct {
+	server    string // API server base URL
+	namespace string // default namespace
+	token     TokenSource
+	client    *http.Client
+}
+
+// Load returns a client for the cluster the provider runs in, or, outside a
+// cluster, for the current context of the kubeconfig at path ($KUBECONFIG
+// or ~/.kube/config when empty). fallback authenticates kubeconfig users
+// without a token or client certificate, e.g. GKE users whose kubeconfig
+// runs gke-gcloud-auth-plugin: GKE accepts Google access tokens.
+func Load(path string, fallback TokenSource) (*Client, error) {
+	if path == "" && os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		return InCluster()
+	}
+	if path == "" {
+		path = os.Getenv("KUBECONFIG")
+	}
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to find kubeconfig: %w", err)
+		}
+		path = filepath.Join(home, ".kube", "config")
+	}
+	return FromKubeconfig(path, fallback)
+}
+
+// InCluster returns a client authenticating with the pod's service account
+func InCluster() (*Client, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("not running in a cluster (KUBERNETES_SERVICE_HOST/PORT not set)")
+	}
+	ca, err := os.ReadFile(filepath.Join(serviceAccountDir, "ca.crt"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account CA: %w", err)
+	}
+	tlsConfig, err := newTLSConfig(ca, false)
+	if err != nil {
+		return nil, err
+	}
+	namespace := "default"
+	if data, err := os.ReadFile(filepath.Join(serviceAccountDir, "namespace")); err == nil {
+		namespace = strings.TrimSpace(string(data))
+	}
+	return &Client{
+		server:    "https://" + net.JoinHostPort(host, port),
+		namespace: namespace,
+		// Re-read on every call: the kubelet rotates projected tokens
+		token:  fileToken(filepath.Join(serviceAccountDir, "token")),
+		client: newHTTPClient(tlsConfig, nil),
+	}, nil
+}
+
+// kubeconfig is the subset of a kubeconfig file the client reads
+type kubeconfig struct {
+	CurrentContext string `yaml:"current-context"`
+	Clusters       []struct {
+		Name    string `yaml:"name"`
+		Cluster struct {
+			Server                   string `yaml:"server"`
+			CertificateAuthority     string `yaml:"certificate-authority"`
+			CertificateAuthorityData string `yaml:"certificate-authority-data"`
+			InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify"`
+		} `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Contexts []struct {
+		Name    string `yaml:"name"`
+		Context struct {
+			Cluster   string `yaml:"cluster"`
+			User      string `yaml:"user"`
+			Namespace string `yaml:"namespace"`
+		} `yaml:"context"`
+	} `yaml:"contexts"`
+	Users []struct {
+		Name string `yaml:"name"`
+		User struct {
+			Token                 string `yaml:"token"`
+			TokenFile             string `yaml:"tokenFile"`
+			ClientCertificate     string `yaml:"client-certificate"`
+			ClientCertificateData string `yaml:"client-certificate-data"`
+			ClientKey             string `yaml:"client-key"`
+			ClientKeyData         string `yaml:"client-key-data"`
+		} `yaml:"user"`
+	} `yaml:"users"`
+}
+
+// FromKubeconfig returns a client for the current context of the kubeconfig
+// at path. See Load for fallback.
+func FromKubeconfig(path string, fallback TokenSource) (*Client, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read kubeconfig: %w", err)
+	}
+	var config kubeconfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse kubeconfig %s: %w", path, err)
+	}
+	dir := filepath.Dir(path)
+
+	var clusterName, userName, namespace string
+	for _, c := range config.Contexts {
+		if c.Name == config.CurrentContext {
+			clusterName, userName, namespace = c.Context.Cluster, c.Context.User, c.Context.Namespace
+		}
+	}
+	if clusterName == "" {
+		return nil, fmt.Errorf("kubeconfig %s: current context %q not found", path, config.CurrentContext)
+	}
+	if namespace == "" {
+		namespace = "default"
+	}
+
+	c := &Client{namespace: namespace}
+	var tlsConfig *tls.Config
+	for _, cluster := range config.Clusters {
+		if cluster.Name != clusterName {
+			continue
+		}
+		ca, err := readData(cluster.Cluster.CertificateAuthorityData, cluster.Cluster.CertificateAuthority, dir)
+		if err != nil {
+			return nil, fmt.Errorf("kubeconfig %s: cluster %s CA: %w", path, clusterName, err)
+		}
+		if tlsConfig, err = newTLSConfig(ca, cluster.Cluster.InsecureSkipTLSVerify); err != nil {
+			return nil, err
+		}
+		c.server = strings.TrimSuffix(cluster.Cluster.Server, "/")
+	}
+	if c.server == "" {
+		return nil, fmt.Errorf("kubeconfig %s: cluster %q has no server", path, clusterName)
+	}
+
+	var certificate *tls.Certificate
+	for _, user := range config.Users {
+		if user.Name != userName {
+			continue
+		}
+		u := user.User
+		switch {
+		case u.Token != "":
+			token := u.Token
+			c.token = func() (string, error) { return token, nil }
+		case u.TokenFile != "":
+			c.token = fileToken(resolve(u.TokenFile, dir))
+		case u.ClientCertificateData != "" || u.ClientCertificate != "":
+			cert, err := readData(u.ClientCertificateData, u.ClientCertificate, dir)
+			if err != nil {
+				return nil, fmt.Errorf("kubeconfig %s: user %s certificate: %w", path, userName, err)
+			}
+			key, err := readData(u.ClientKeyData, u.ClientKey, dir)
+			if err != nil {
+				return nil, fmt.Errorf("kubeconfig %s: user %s key: %w", path, userName, err)
+			}
+			pair, err := tls.X509KeyPair(cert, key)
+			if err != nil {
+				return nil, fmt.Errorf("kubeconfig %s: user %s: %w", path, userName, err)
+			}
+			certificate = &pair
+		}
+	}
+	if c.token == nil && certificate == nil {
+		if fallback == nil {
+			return nil, fmt.Errorf("kubeconfig %s: user %q has no token or client certificate", path, userName)
+		}
+		c.token = fallback
+	}
+	c.client = newHTTPClient(tlsConfig, certificate)
+	return c, nil
+}
+
+// Namespace returns the namespace objects are written to when none is given
+func (c *Client) Namespace() string {
+	return c.namespace
+}
+
+// Apply sets the data keys of a ConfigMap or Secret (kind KindConfigMap or
+// KindSecret) and deletes the keys in remove, in a single merge patch so
+// readers never see a partial update. It creates the object labelled
+// ManagedByLabel if it doesn't exist and data isn't empty. Other keys are
+// left alone. An empty namespace is the client's.
+func (c *Client) Apply(ctx context.Context, kind, namespace, name string, data map[string][]byte, remove []string) error {
+	if len(data) == 0 && len(remove) == 0 {
+		return nil
+	}
+	if namespace == "" {
+		namespace = c.namespace
+	}
+	values := make(map[string]string, len(data))
+	for key, value := range data {
+		if kind == KindSecret {
+			values[key] = base64.StdEncoding.EncodeToString(value)
+		} else {
+			values[key] = string(value)
+		}
+	}
+	patched := make(map[string]any, len(values)+len(remove))
+	for _, key := range remove {
+		patched[key] = nil // A merge patch removes keys set to null
+	}
+	for key, value := range values {
+		patched[key] = value
+	}
+
+	collection := fmt.Sprintf("/api/v1/namespaces/%s/%s", url.PathEscape(namespace), kind)
+	patch, err := json.Marshal(map[string]any{"data": patched})
+	if err != nil {
+		return fmt.Errorf("failed to encode %s patch: %w", kind, err)
+	}
+	status, err := c.do(ctx, http.MethodPatch, collection+"/"+url.PathEscape(name), "application/merge-patch+json", patch, nil)
+	if status != http.StatusNotFound {
+		return err
+	}
+	if len(values) == 0 {
+		return nil // Nothing to remove keys from
+	}
+
+	object := map[string]any{
+		"apiVersion": "v1",
+		"kind":       map[string]string{KindConfigMap: "ConfigMap", KindSecret: "Secret"}[kind],
+		"metadata": map[string]any{
+			"name":      name,
+			"namespace": namespace,
+			"labels":    map[string]string{ManagedByLabel: managedBy},
+		},
+		"data": values,
+	}
+	if kind == KindSecret {
+		object["type"] = "Opaque"
+	}
+	body, err := json.Marshal(object)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", kind, err)
+	}
+	_, err = c.do(ctx, http.MethodPost, collection, "application/json", body, nil)
+	return err
+}
+
+// Keys returns the data keys of a ConfigMap or Secret, or none if it doesn't
+// exist. An empty namespace is the client's.
+func (c *Client) Keys(ctx context.Context, kind, namespace, name string) ([]string, error) {
+	if namespace == "" {
+		namespace = c.namespace
+	}
+	var object struct {
+		Data map[string]string `json:"data"`
+	}
+	path := fmt.Sprintf("/api/v1/namespaces/%s/%s/%s", url.PathEscape(namespace), kind, url.PathEscape(name))
+	status, err := c.do(ctx, http.MethodGet, path, "", nil, &object)
+	if status == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(object.Data))
+	for key := range object.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// do sends one request, returning the status and an error for non-2xx
+// responses. The response of a successful request is decoded into out
+// unless it is nil.
+func (c *Client) do(ctx context.Context, method, path, contentType string, body []byte, out any) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.server+path, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.token != nil {
+		token, err := c.token()
+		if err != nil {
+			return 0, fmt.Errorf("failed to get Kubernetes API token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to call Kubernetes API: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		var status struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(message, &status) == nil && status.Message != "" {
+			message = []byte(status.Message)
+		}
+		return resp.StatusCode, fmt.Errorf("%s %s returned %d: %s", method, path, resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp.StatusCode, fmt.Errorf("failed to decode %s %s response: %w", method, path, err)
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+// fileToken reads a bearer token from path on every call
+func fileToken(path string) TokenSource {
+	return func() (string, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+}
+
+// readData returns base64 inline data, or the contents of file (relative to
+// dir); nil when neither is set
+func readData(inline, file, dir string) ([]byte, error) {
+	if inline != "" {
+		return base64.StdEncoding.DecodeString(inline)
+	}
+	if file != "" {
+		return os.ReadFile(resolve(file, dir))
+	}
+	return nil, nil
+}
+
+// resolve makes kubeconfig paths relative to the kubeconfig's directory
+func resolve(path, dir string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+// newTLSConfig trusts ca (the system roots when empty)
+func newTLSConfig(ca []byte, insecure bool) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12, InsecureSkipVerify: insecure}
+	if len(ca) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("invalid Kubernetes API CA certificate")
+		}
+		config.RootCAs = pool
+	}
+	return config, nil
+}
+
+// newHTTPClient returns a client using tlsConfig and, if set, a client certificate
+func newHTTPClient(tlsConfig *tls.Config, certificate *tls.Certificate) *http.Client {
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	if certificate != nil {
+		tlsConfig.Certificates = []tls.Certificate{*certificate}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Transport: transport, Timeout: 30 * time.Second}
+}
@@ -0,0 +1,232 @@
+package kube
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeAPIServer stores ConfigMaps and Secrets by path, applying merge
+// patches to their data
+type fakeAPIServer struct {
+	mu      sync.Mutex
+	objects map[string]map[string]any
+	auth    string
+	patches int
+}
+
+func (s *fakeAPIServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.auth = r.Header.Get("Authorization")
+	existing, exists := s.objects[r.URL.Path]
+	if r.Method == http.MethodGet {
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"kind":"Status","message":"not found"}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(existing)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	var object map[string]any
+	if err := json.Unmarshal(body, &object); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	switch r.Method {
+	case http.MethodPatch:
+		s.patches++
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"kind":"Status","message":"not found"}`))
+			return
+		}
+		data, _ := existing["data"].(map[string]any)
+		for key, value := range object["data"].(map[string]any) {
+			if value == nil {
+				delete(data, key)
+				continue
+			}
+			data[key] = value
+		}
+	case http.MethodPost:
+		name := object["metadata"].(map[string]any)["name"].(string)
+		s.objects[r.URL.Path+"/"+name] = object
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+func newTestClient(t *testing.T) (*Client, *fakeAPIServer) {
+	t.Helper()
+	api := &fakeAPIServer{objects: make(map[string]map[string]any)}
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+	path := filepath.Join(t.TempDir(), "config")
+	kubeconfig := `current-context: dev
+clusters:
+- name: dev-cluster
+  cluster:
+    server: ` + server.URL + `
+contexts:
+- name: dev
+  context: {cluster: dev-cluster, user: dev-user, namespace: traefik}
+users:
+- name: dev-user
+  user: {token: secret-token}
+`
+	if err := os.WriteFile(path, []byte(kubeconfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+	client, err := FromKubeconfig(path, nil)
+	if err != nil {
+		t.Fatalf("FromKubeconfig failed: %v", err)
+	}
+	return client, api
+}
+
+func TestFromKubeconfig(t *testing.T) {
+	client, _ := newTestClient(t)
+	if client.Namespace() != "traefik" {
+		t.Errorf("Expected the context namespace, got %s", client.Namespace())
+	}
+	if token, _ := client.token(); token != "secret-token" {
+		t.Errorf("Expected the user token, got %s", token)
+	}
+}
+
+func TestFromKubeconfig_Fallback(t *testing.T) {
+	// GKE kubeconfigs authenticate with an exec plugin the client doesn't run
+	path := filepath.Join(t.TempDir(), "config")
+	kubeconfig := `current-context: gke
+clusters:
+- {name: gke, cluster: {server: "https://10.0.0.1"}}
+contexts:
+- {name: gke, context: {cluster: gke, user: gke}}
+users:
+- {name: gke, user: {exec: {command: gke-gcloud-auth-plugin}}}
+`
+	if err := os.WriteFile(path, []byte(kubeconfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := FromKubeconfig(path, nil); err == nil {
+		t.Error("Expected an error without token, certificate or fallback")
+	}
+	client, err := FromKubeconfig(path, func() (string, error) { return "ya29.access", nil })
+	if err != nil {
+		t.Fatalf("FromKubeconfig failed: %v", err)
+	}
+	if token, _ := client.token(); token != "ya29.access" || client.Namespace() != "default" {
+		t.Errorf("Expected fallback token and default namespace, got %s %s", token, client.Namespace())
+	}
+}
+
+func TestFromKubeconfig_MissingContext(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(path, []byte("current-context: missing\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := FromKubeconfig(path, nil); err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Errorf("Expected missing context error, got %v", err)
+	}
+}
+
+func TestClient_ApplyConfigMap(t *testing.T) {
+	client, api := newTestClient(t)
+	ctx := context.Background()
+
+	// Created on first apply, patched afterwards
+	if err := client.Apply(ctx, KindConfigMap, "", "routes", map[string][]byte{"routes.yml": []byte("v1")}, nil); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if err := client.Apply(ctx, KindConfigMap, "", "routes", map[string][]byte{"routes-a.yml": []byte("a")}, nil); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	object, ok := api.objects["/api/v1/namespaces/traefik/configmaps/routes"]
+	if !ok {
+		t.Fatalf("Expected ConfigMap traefik/routes, got %v", api.objects)
+	}
+	data := object["data"].(map[string]any)
+	if data["routes.yml"] != "v1" || data["routes-a.yml"] != "a" {
+		t.Errorf("Expected both keys, got %v", data)
+	}
+	labels := object["metadata"].(map[string]any)["labels"].(map[string]any)
+	if labels[ManagedByLabel] != managedBy || api.auth != "Bearer secret-token" {
+		t.Errorf("Unexpected labels %v or auth %s", labels, api.auth)
+	}
+}
+
+func TestClient_ApplySecret(t *testing.T) {
+	client, api := newTestClient(t)
+	if err := client.Apply(context.Background(), KindSecret, "ingress", "routes", map[string][]byte{"routes.yml": []byte("v1")}, nil); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	object, ok := api.objects["/api/v1/namespaces/ingress/secrets/routes"]
+	if !ok {
+		t.Fatalf("Expected Secret ingress/routes, got %v", api.objects)
+	}
+	if value := object["data"].(map[string]any)["routes.yml"]; value != base64.StdEncoding.EncodeToString([]byte("v1")) {
+		t.Errorf("Expected base64 data, got %v", value)
+	}
+}
+
+func TestClient_ApplyError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"kind":"Status","message":"configmaps \"routes\" is forbidden"}`))
+	}))
+	defer server.Close()
+	client := &Client{server: server.URL, namespace: "default", client: server.Client()}
+	err := client.Apply(context.Background(), KindConfigMap, "", "routes", map[string][]byte{"routes.yml": nil}, nil)
+	if err == nil || !strings.Contains(err.Error(), "403") || !strings.Contains(err.Error(), "forbidden") {
+		t.Errorf("Expected forbidden error, got %v", err)
+	}
+}
+
+func TestClient_ApplyRemovesKeys(t *testing.T) {
+	client, api := newTestClient(t)
+	ctx := context.Background()
+
+	// A missing object has no keys and isn't created to remove some
+	if keys, err := client.Keys(ctx, KindConfigMap, "", "routes"); err != nil || len(keys) != 0 {
+		t.Fatalf("Keys = %v, %v; expected none", keys, err)
+	}
+	if err := client.Apply(ctx, KindConfigMap, "", "routes", nil, []string{"routes-a.yml"}); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if len(api.objects) != 0 {
+		t.Fatalf("Expected no object created, got %v", api.objects)
+	}
+
+	data := map[string][]byte{"routes.yml": []byte("v1"), "routes-b.yml": []byte("b"), "routes-a.yml": []byte("a")}
+	if err := client.Apply(ctx, KindConfigMap, "", "routes", data, nil); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	keys, err := client.Keys(ctx, KindConfigMap, "", "routes")
+	if err != nil || strings.Join(keys, ",") != "routes-a.yml,routes-b.yml,routes.yml" {
+		t.Fatalf("Keys = %v, %v; expected the sorted keys", keys, err)
+	}
+
+	// Updated and removed keys go in one patch
+	api.patches = 0
+	data = map[string][]byte{"routes.yml": []byte("v2"), "routes-a.yml": []byte("a")}
+	if err := client.Apply(ctx, KindConfigMap, "", "routes", data, []string{"routes-b.yml", "routes-c.yml"}); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	remaining := api.objects["/api/v1/namespaces/traefik/configmaps/routes"]["data"].(map[string]any)
+	if len(remaining) != 2 || remaining["routes.yml"] != "v2" || remaining["routes-a.yml"] != "a" {
+		t.Errorf("Expected routes-b.yml removed, got %v", remaining)
+	}
+	if api.patches != 1 {
+		t.Errorf("Expected a single patch, got %d", api.patches)
+	}
+}
@@ -0,0 +1,142 @@
+package logging
+
+import "strings"
+
+// CodeInfo documents a PLUGIN_* code for operators building alerts on the
+// code field of log entries
+type CodeInfo struct {
+	Code        string `json:"code"`
+	Severity    string `json:"severity"` // SUCCESS, INFO, WARN or ERROR, from the code itself
+	Description string `json:"description"`
+	Action      string `json:"action,omitempty"` // What an operator should do; "" when nothing
+}
+
+// catalog describes every code in codes.go, in declaration order
+var catalog = []CodeInfo{
+	{Code: CodeCreateConfigSuccess, Description: "Traefik created the plugin's default configuration"},
+	{Code: CodeCreateConfigError, Description: "The plugin's default configuration couldn't be created",
+		Action: "Check the plugin block of the Traefik static configuration"},
+
+	{Code: CodeNewSuccess, Description: "The plugin was instantiated"},
+	{Code: CodeNewError, Description: "The plugin couldn't be instantiated",
+		Action: "Check the error field and the plugin configuration, then restart Traefik"},
+	{Code: CodeNewConfigNil, Description: "Traefik passed no configuration to the plugin",
+		Action: "Add the plugin block to the Traefik static configuration"},
+	{Code: CodeNewProjectIDMissing, Description: "No GCP project is configured",
+		Action: "Set projectIDs in the plugin configuration or LABS_PROJECT_ID"},
+	{Code: CodeNewProjectIDFound, Description: "A GCP project was read from the environment"},
+	{Code: CodeNewCloudRunClientError, Description: "The Cloud Run Admin API client couldn't be created",
+		Action: "Check the credentials (ADC, PROVIDER_CREDENTIALS_FILE) and CLOUDRUN_API_ENDPOINT"},
+
+	{Code: CodeInitSuccess, Description: "The plugin initialized"},
+	{Code: CodeInitError, Description: "The plugin failed to initialize",
+		Action: "Check the error field; Traefik runs without the provider's routes until it's fixed"},
+
+	{Code: CodeProvideSuccess, Description: "The plugin started providing configuration to Traefik"},
+	{Code: CodeProvideError, Description: "The plugin couldn't start providing configuration",
+		Action: "Check the error field and restart Traefik"},
+	{Code: CodeProvideInitialConfigSuccess, Description: "The first configuration was generated and sent to Traefik"},
+	{Code: CodeProvideInitialConfigError, Description: "The first configuration couldn't be generated; polling retries it",
+		Action: "Run the provider's preflight command to check credentials, API access and IAM"},
+	{Code: CodeProvidePollLoopStarted, Description: "The poll loop started"},
+
+	{Code: CodePollStarted, Description: "A poll cycle started"},
+	{Code: CodePollSuccess, Description: "A poll cycle completed"},
+	{Code: CodePollError, Description: "A poll cycle failed; Traefik keeps the previous configuration",
+		Action: "Alert if it repeats: routes stop being updated and their tokens expire after an hour"},
+	{Code: CodePollStopped, Description: "Polling stopped, e.g. on shutdown"},
+
+	{Code: CodeServiceDiscoveryStarted, Description: "Listing Cloud Run services started"},
+	{Code: CodeServiceDiscoverySuccess, Description: "Cloud Run services were listed"},
+	{Code: CodeServiceDiscoveryError, Description: "Services of a project couldn't be listed",
+		Action: "Check that the service account has roles/run.viewer on the project and the API is enabled"},
+	{Code: CodeServiceDiscoveryNoServices, Description: "A project has no services labeled traefik_enable=true",
+		Action: "Label the services to route, or remove the project from the configuration"},
+	{Code: CodeServiceProcessingStarted, Description: "Processing a service's labels started"},
+	{Code: CodeServiceProcessingSuccess, Description: "A service's routers, services and middlewares were generated"},
+	{Code: CodeServiceProcessingError, Description: "A service or one of its routers was dropped: bad labels, an invalid rule or a failed token fetch",
+		Action: "Fix the service's traefik_* labels; the error field says which one"},
+	{Code: CodeServiceSkipped, Description: "A service was skipped: not enabled, filtered by INCLUDE_SERVICES/EXCLUDE_SERVICES or without traffic"},
+	{Code: CodeServiceNotReady, Description: "A service isn't ready; it was skipped or routed to its last ready revision (READINESS_POLICY)",
+		Action: "Check the service's latest revision in Cloud Run"},
+	{Code: CodeServiceNoURL, Description: "A service has no URL yet (still deploying); it was skipped or routed to its last known URL",
+		Action: "Alert if it persists: check the service's deployment in Cloud Run"},
+
+	{Code: CodeRouterConfigured, Description: "A router was generated"},
+	{Code: CodeRouterError, Description: "A router couldn't be generated",
+		Action: "Fix the router labels of the service"},
+	{Code: CodePriorityTie, Description: "Routers share a priority and entry point and their rules overlap, so Traefik orders them arbitrarily",
+		Action: "Set distinct traefik_http_routers_<name>_priority labels, or PRIORITY_TIE_POLICY=bump"},
+	{Code: CodeErrorPageMissing, Description: "An errors middleware names a service that isn't routed, so Traefik can't serve its error pages",
+		Action: "Check the traefik_errors_service label and that the error page service is Traefik-enabled and routed"},
+	{Code: CodeCatchAllRule, Description: "Routers whose rule matches every request (e.g. PathPrefix(`/`)) were dropped from a service not allowed to define catch-all routes",
+		Action: "Fix the router's rule or rule_id, or label the service traefik_allow_catch_all=true (or add it to CATCH_ALL_SERVICES)"},
+	{Code: CodeRulePolicy, Description: "Routers were dropped because a rule policy rejects their rule",
+		Action: "Change the router's rule, or ask the Traefik owners to adjust rulePolicies in the provider config"},
+
+	{Code: CodeTokenFetchSuccess, Description: "An identity token was fetched"},
+	{Code: CodeTokenFetchError, Description: "An identity token couldn't be fetched; requests to the service will be rejected",
+		Action: "Check the metadata server (GCE_METADATA_HOST) or credentials, and that the service account can mint tokens"},
+	{Code: CodeTokenInvalid, Description: "A fetched token doesn't look like a JWT",
+		Action: "Check what answers on GCE_METADATA_HOST and the credentials in use"},
+	{Code: CodeTokensInvalidated, Description: "Cached identity tokens were dropped on request and will be re-minted"},
+
+	{Code: CodeConfigGenerationStarted, Description: "Generating the configuration started"},
+	{Code: CodeConfigGenerationSuccess, Description: "The configuration was generated"},
+	{Code: CodeConfigGenerationError, Description: "The configuration couldn't be generated; the previous one stays in place",
+		Action: "Alert if it repeats: check the error field, or collect a debug bundle"},
+	{Code: CodeConfigSentSuccess, Description: "The configuration was written or sent to Traefik"},
+	{Code: CodeConfigSentError, Description: "The configuration couldn't be written to its outputs",
+		Action: "Check the output path, its permissions and the configured OUTPUT_SINKS"},
+
+	{Code: CodeInternalProviderCreated, Description: "The plugin created its internal provider"},
+	{Code: CodeInternalProviderError, Description: "The plugin couldn't create its internal provider",
+		Action: "Check the error field and the plugin configuration"},
+	{Code: CodeInternalProviderStarted, Description: "The plugin's internal provider started"},
+	{Code: CodeProviderPanic, Description: "The provider panicked",
+		Action: "Report it with a debug bundle; the process crashes and restarts with the routes file left in place"},
+
+	{Code: CodeBreakerOpened, Description: "A project exhausted its error budget and is skipped until the cool-down ends",
+		Action: "Fix the project's listing errors; BREAKER_THRESHOLD and BREAKER_COOLDOWN tune the breaker"},
+	{Code: CodeBreakerSkipped, Description: "A project was skipped because its circuit breaker is open"},
+
+	{Code: CodeStaleRoutesRetained, Description: "Listing a project failed, so its last listed routes were kept (STALE_ROUTE_GRACE_PERIOD)",
+		Action: "Fix the listing errors before the grace period ends and the routes are dropped"},
+	{Code: CodeServiceDeparting, Description: "A service missing from discovery keeps its routes until ROUTE_DELETION_DELAY passes"},
+
+	{Code: CodeRouterRemovalRefused, Description: "A configuration removing too many routers was refused (MAX_ROUTER_REMOVAL); the previous one stays in place",
+		Action: "Check whether the services were really deleted; if so, set ALLOW_ROUTER_REMOVAL=true for one run"},
+	{Code: CodeRouterRemovalAllowed, Description: "Routers beyond MAX_ROUTER_REMOVAL were removed because ALLOW_ROUTER_REMOVAL is set",
+		Action: "Unset ALLOW_ROUTER_REMOVAL once the removal is applied"},
+
+	{Code: CodeSelfTestFailed, Description: "A backend failed the self-test probe (SELF_TEST)",
+		Action: "Check the service is up and its identity token is accepted"},
+	{Code: CodeBackendUnresolved, Description: "A backend URL is empty or its host doesn't resolve (DNS_CHECK), e.g. a service still deploying",
+		Action: "Check the service finished deploying and its URL; Traefik answers 502 on its routes until it resolves"},
+
+	{Code: CodeDeprecatedSetting, Description: "A deprecated setting or label form is in use; it still works, migrated to its replacement",
+		Action: "Switch to the replacement field of the entry before the deprecated form is removed"},
+	{Code: CodeDeprecatedRefused, Description: "Deprecated settings or label forms are in use and FAIL_ON_DEPRECATED is set, so nothing was emitted",
+		Action: "Switch to the replacements listed in the error field"},
+}
+
+// Codes returns every PLUGIN_* code with its description and suggested
+// operator action, in declaration order
+func Codes() []CodeInfo {
+	codes := make([]CodeInfo, len(catalog))
+	for i, info := range catalog {
+		info.Severity = CodeSeverity(info.Code)
+		codes[i] = info
+	}
+	return codes
+}
+
+// CodeSeverity returns the severity part of a code (SUCCESS, INFO, WARN or
+// ERROR), e.g. WARN for PLUGIN_011_WARN_CIRCUIT_OPENED
+func CodeSeverity(code string) string {
+	parts := strings.SplitN(code, "_", 4)
+	if len(parts) < 3 {
+		return ""
+	}
+	return parts[2]
+}
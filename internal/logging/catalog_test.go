@@ -0,0 +1,72 @@
+package logging
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"testing"
+)
+
+// declaredCodes returns the values of the constants in codes.go, in order
+func declaredCodes(t *testing.T) []string {
+	t.Helper()
+	file, err := parser.ParseFile(token.NewFileSet(), "codes.go", nil, 0)
+	if err != nil {
+		t.Fatalf("Failed to parse codes.go: %v", err)
+	}
+	var codes []string
+	ast.Inspect(file, func(node ast.Node) bool {
+		spec, ok := node.(*ast.ValueSpec)
+		if !ok {
+			return true
+		}
+		for _, value := range spec.Values {
+			if lit, ok := value.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+				code, _ := strconv.Unquote(lit.Value)
+				codes = append(codes, code)
+			}
+		}
+		return false
+	})
+	return codes
+}
+
+func TestCodes_CoverCodesFile(t *testing.T) {
+	declared := declaredCodes(t)
+	codes := Codes()
+	if len(codes) != len(declared) {
+		t.Fatalf("Expected %d codes in the catalog, got %d", len(declared), len(codes))
+	}
+	for i, info := range codes {
+		if info.Code != declared[i] {
+			t.Errorf("Expected %s at position %d (codes.go order), got %s", declared[i], i, info.Code)
+		}
+		if info.Description == "" {
+			t.Errorf("Expected a description for %s", info.Code)
+		}
+		switch info.Severity {
+		case "SUCCESS", "INFO", "WARN":
+		case "ERROR":
+			if info.Action == "" {
+				t.Errorf("Expected an operator action for %s", info.Code)
+			}
+		default:
+			t.Errorf("Unexpected severity %q for %s", info.Severity, info.Code)
+		}
+	}
+}
+
+func TestCodeSeverity(t *testing.T) {
+	tests := map[string]string{
+		"PLUGIN_001_SUCCESS":                      "SUCCESS",
+		"PLUGIN_011_WARN_CIRCUIT_OPENED":          "WARN",
+		"PLUGIN_011_ERROR_ROUTER_REMOVAL_REFUSED": "ERROR",
+		"PLUGIN": "",
+	}
+	for code, want := range tests {
+		if got := CodeSeverity(code); got != want {
+			t.Errorf("CodeSeverity(%s) = %q, want %q", code, got, want)
+		}
+	}
+}
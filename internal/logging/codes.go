@@ -40,15 +40,22 @@ const (
 	CodeServiceProcessingSuccess   = "PLUGIN_006_SUCCESS_SERVICE_PROCESSED"
 	CodeServiceProcessingError     = "PLUGIN_006_ERROR_SERVICE_PROCESSING"
 	CodeServiceSkipped             = "PLUGIN_006_INFO_SERVICE_SKIPPED"
+	CodeServiceNotReady            = "PLUGIN_006_WARN_SERVICE_NOT_READY"
+	CodeServiceNoURL               = "PLUGIN_006_WARN_SERVICE_NO_URL"
 
 	// Router Configuration
 	CodeRouterConfigured = "PLUGIN_007_SUCCESS_ROUTER_CONFIGURED"
 	CodeRouterError      = "PLUGIN_007_ERROR_ROUTER_CONFIG"
+	CodePriorityTie      = "PLUGIN_007_WARN_PRIORITY_TIE"
+	CodeErrorPageMissing = "PLUGIN_007_WARN_ERROR_PAGE_SERVICE_MISSING"
+	CodeCatchAllRule     = "PLUGIN_007_ERROR_CATCH_ALL_RULE"
+	CodeRulePolicy       = "PLUGIN_007_ERROR_RULE_POLICY"
 
 	// Token Management
 	CodeTokenFetchSuccess = "PLUGIN_008_SUCCESS_TOKEN_FETCHED"
 	CodeTokenFetchError   = "PLUGIN_008_ERROR_TOKEN_FETCH_FAILED"
 	CodeTokenInvalid      = "PLUGIN_008_ERROR_TOKEN_INVALID"
+	CodeTokensInvalidated = "PLUGIN_008_WARN_TOKENS_INVALIDATED"
 
 	// Configuration Generation
 	CodeConfigGenerationStarted = "PLUGIN_009_INFO_CONFIG_GENERATION_STARTED"
@@ -61,6 +68,25 @@ const (
 	CodeInternalProviderCreated = "PLUGIN_010_SUCCESS_INTERNAL_PROVIDER_CREATED"
 	CodeInternalProviderError   = "PLUGIN_010_ERROR_INTERNAL_PROVIDER_FAILED"
 	CodeInternalProviderStarted = "PLUGIN_010_SUCCESS_INTERNAL_PROVIDER_STARTED"
+	CodeProviderPanic           = "PLUGIN_010_ERROR_PANIC"
+
+	// Error Budget / Circuit Breaker
+	CodeBreakerOpened  = "PLUGIN_011_WARN_CIRCUIT_OPENED"
+	CodeBreakerSkipped = "PLUGIN_011_INFO_CIRCUIT_SKIPPED"
+
+	CodeStaleRoutesRetained = "PLUGIN_011_WARN_STALE_ROUTES_RETAINED"
+	CodeServiceDeparting    = "PLUGIN_011_WARN_SERVICE_DEPARTING"
+
+	CodeRouterRemovalRefused = "PLUGIN_011_ERROR_ROUTER_REMOVAL_REFUSED"
+	CodeRouterRemovalAllowed = "PLUGIN_011_WARN_ROUTER_REMOVAL_ALLOWED"
+
+	// Backend Self-Test
+	CodeSelfTestFailed    = "PLUGIN_012_WARN_SELFTEST_FAILED"
+	CodeBackendUnresolved = "PLUGIN_012_WARN_BACKEND_UNRESOLVED"
+
+	// Deprecations
+	CodeDeprecatedSetting = "PLUGIN_013_WARN_DEPRECATED_SETTING"
+	CodeDeprecatedRefused = "PLUGIN_013_ERROR_DEPRECATED_REFUSED"
 )
 
 // GetCodeField returns a Field with the code for structured logging
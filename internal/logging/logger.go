@@ -4,8 +4,11 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
 	"os"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -26,6 +29,11 @@ var levelNames = map[Level]string{
 	LevelError: "ERROR",
 }
 
+// String returns the level name, as accepted by ParseLevel
+func (l Level) String() string {
+	return levelNames[l]
+}
+
 // Format represents log output formats
 type Format string
 
@@ -39,14 +47,27 @@ type Config struct {
 	Level  Level  // Minimum log level
 	Format Format // Output format
 	Output io.Writer
+
+	// Redact rewrites messages and field values before they are written,
+	// e.g. redact.Policy.String to hide tokens and email addresses (optional)
+	Redact func(string) string
+
+	// Handler receives entries instead of Output, e.g. an embedder's
+	// slog.Handler (or a logr.Logger's, through logr.ToSlogHandler), so they
+	// join its logs rather than a second format. Level and Redact still
+	// apply; Format and Output are ignored. (optional)
+	Handler slog.Handler
 }
 
 // Logger provides structured logging with configurable output
 type Logger struct {
-	level  Level
-	format Format
-	output io.Writer
-	prefix string
+	level   *atomic.Int32            // Shared with loggers derived by WithPrefix
+	context *atomic.Pointer[[]Field] // Fields added to every entry, shared the same way
+	format  Format
+	output  io.Writer
+	prefix  string
+	redact  func(string) string
+	handler slog.Handler
 }
 
 // New creates a new logger with the given configuration
@@ -54,21 +75,57 @@ func New(config *Config) *Logger {
 	if config.Output == nil {
 		config.Output = os.Stdout
 	}
+	level := new(atomic.Int32)
+	level.Store(int32(config.Level))
 	return &Logger{
-		level:  config.Level,
-		format: config.Format,
-		output: config.Output,
+		level:   level,
+		context: new(atomic.Pointer[[]Field]),
+		format:  config.Format,
+		output:  config.Output,
+		redact:  config.Redact,
+		handler: config.Handler,
 	}
 }
 
 // WithPrefix returns a new logger with the given prefix
 func (l *Logger) WithPrefix(prefix string) *Logger {
 	return &Logger{
-		level:  l.level,
-		format: l.format,
-		output: l.output,
-		prefix: prefix,
+		level:   l.level,
+		context: l.context,
+		format:  l.format,
+		output:  l.output,
+		prefix:  prefix,
+		redact:  l.redact,
+		handler: l.handler,
+	}
+}
+
+// SetLevel changes the minimum level of the logger and of the loggers it
+// shares it with through WithPrefix, taking effect immediately
+func (l *Logger) SetLevel(level Level) {
+	l.level.Store(int32(level))
+}
+
+// Level returns the minimum level logged
+func (l *Logger) Level() Level {
+	return Level(l.level.Load())
+}
+
+// SetContext sets fields added to every entry of the logger and of the
+// loggers it shares them with through WithPrefix, e.g. the ID of the
+// generation cycle in progress. No fields clears them.
+func (l *Logger) SetContext(fields ...Field) {
+	if len(fields) == 0 {
+		l.context.Store(nil)
+		return
 	}
+	l.context.Store(&fields)
+}
+
+// Enabled reports whether entries of level are written, so callers can
+// skip building expensive messages that would be dropped
+func (l *Logger) Enabled(level Level) bool {
+	return level >= l.Level()
 }
 
 // Debug logs a debug message
@@ -93,34 +150,61 @@ func (l *Logger) Error(msg string, fields ...Field) {
 
 // Debugf logs a formatted debug message
 func (l *Logger) Debugf(format string, args ...interface{}) {
-	l.log(LevelDebug, fmt.Sprintf(format, args...))
+	if l.Enabled(LevelDebug) {
+		l.log(LevelDebug, fmt.Sprintf(format, args...))
+	}
 }
 
 // Infof logs a formatted info message
 func (l *Logger) Infof(format string, args ...interface{}) {
-	l.log(LevelInfo, fmt.Sprintf(format, args...))
+	if l.Enabled(LevelInfo) {
+		l.log(LevelInfo, fmt.Sprintf(format, args...))
+	}
 }
 
 // Warnf logs a formatted warning message
 func (l *Logger) Warnf(format string, args ...interface{}) {
-	l.log(LevelWarn, fmt.Sprintf(format, args...))
+	if l.Enabled(LevelWarn) {
+		l.log(LevelWarn, fmt.Sprintf(format, args...))
+	}
 }
 
 // Errorf logs a formatted error message
 func (l *Logger) Errorf(format string, args ...interface{}) {
-	l.log(LevelError, fmt.Sprintf(format, args...))
+	if l.Enabled(LevelError) {
+		l.log(LevelError, fmt.Sprintf(format, args...))
+	}
 }
 
-// log writes a log entry
+// log writes a log entry. Field values are only rendered (and lazy fields
+// only evaluated) once the level is known to be enabled.
 func (l *Logger) log(level Level, msg string, fields ...Field) {
-	if level < l.level {
+	if !l.Enabled(level) {
 		return
 	}
 
 	timestamp := time.Now().UTC().Format(time.RFC3339)
 	levelName := levelNames[level]
+	if context := l.context.Load(); context != nil {
+		fields = append(append(make([]Field, 0, len(*context)+len(fields)), *context...), fields...)
+	}
 
-	if l.format == FormatJSON {
+	rendered := make([]Field, len(fields))
+	for i, f := range fields {
+		value := render(f.Value)
+		if l.redact != nil {
+			value = l.redact(value)
+		}
+		rendered[i] = Field{Key: f.Key, Value: value}
+	}
+	fields = rendered
+	if l.redact != nil {
+		msg = l.redact(msg)
+	}
+
+	if l.handler != nil {
+		l.writeHandler(level, msg, fields)
+	} else if l.format == FormatJSON {
 		l.logJSON(timestamp, levelName, msg, fields)
 	} else {
 		l.logText(timestamp, levelName, msg, fields)
@@ -143,7 +227,7 @@ func (l *Logger) logText(timestamp, level, msg string, fields []Field) {
 
 	// Add fields
 	for _, f := range fields {
-		parts = append(parts, fmt.Sprintf("%s=%v", f.Key, f.Value))
+		parts = append(parts, fmt.Sprintf("%s=%s", f.Key, f.Value))
 	}
 
 	fmt.Fprintln(l.output, strings.Join(parts, " "))
@@ -165,7 +249,7 @@ func (l *Logger) logJSON(timestamp, level, msg string, fields []Field) {
 
 	// Add fields
 	for _, f := range fields {
-		parts = append(parts, fmt.Sprintf(`"%s":"%v"`, f.Key, f.Value))
+		parts = append(parts, fmt.Sprintf(`"%s":"%s"`, f.Key, escapeJSON(f.Value.(string))))
 	}
 
 	fmt.Fprintf(l.output, "{%s}\n", strings.Join(parts, ","))
@@ -192,6 +276,57 @@ func Duration(key string, value time.Duration) Field {
 	return Field{Key: key, Value: value}
 }
 
+// Bool creates a bool field
+func Bool(key string, value bool) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Float64 creates a float field
+func Float64(key string, value float64) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Strings creates a field listing values, written as [a, b]
+func Strings(key string, values []string) Field {
+	return Field{Key: key, Value: stringsValue(values)}
+}
+
+// Time creates a time field, written in RFC 3339 UTC
+func Time(key string, value time.Time) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Lazy creates a field whose value is computed by fn only when the entry
+// is written, for values too expensive to build for filtered entries
+// (e.g. configuration dumps)
+func Lazy(key string, fn func() interface{}) Field {
+	return Field{Key: key, Value: lazyValue(fn)}
+}
+
+// lazyValue is the value of a Lazy field
+type lazyValue func() interface{}
+
+// stringsValue is the value of a Strings field
+type stringsValue []string
+
+// render formats a field value for output
+func render(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case lazyValue:
+		return render(v())
+	case stringsValue:
+		return "[" + strings.Join(v, ", ") + "]"
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
 // Error creates an error field
 func Error(err error) Field {
 	if err == nil {
@@ -166,6 +166,45 @@ func TestLogger_Fields(t *testing.T) {
 	}
 }
 
+func TestLogger_TypedFields(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&Config{Level: LevelInfo, Format: FormatText, Output: &buf})
+
+	at := time.Date(2026, 1, 2, 3, 4, 5, 0, time.FixedZone("CET", 3600))
+	logger.Info("test",
+		Bool("ok", true),
+		Float64("ratio", 0.25),
+		Strings("middlewares", []string{"lab1-auth", "retry"}),
+		Time("at", at),
+	)
+
+	want := "ok=true ratio=0.25 middlewares=[lab1-auth, retry] at=2026-01-02T02:04:05Z"
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("Expected %q in output, got: %s", want, buf.String())
+	}
+}
+
+func TestLogger_LazyField(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&Config{Level: LevelInfo, Format: FormatJSON, Output: &buf})
+
+	calls := 0
+	dump := Lazy("dump", func() interface{} {
+		calls++
+		return `{"a":1}`
+	})
+	logger.Debug("filtered", dump)
+	logger.Debugf("filtered %s", "too")
+	if calls != 0 || buf.Len() != 0 {
+		t.Fatalf("Expected filtered entries not to evaluate lazy fields, got %d calls and %q", calls, buf.String())
+	}
+
+	logger.Info("written", dump)
+	if calls != 1 || !strings.Contains(buf.String(), `"dump":"{\"a\":1}"`) {
+		t.Errorf("Expected the lazy field evaluated once and escaped, got %d calls and %s", calls, buf.String())
+	}
+}
+
 func TestLogger_ErrorField(t *testing.T) {
 	var buf bytes.Buffer
 	logger := New(&Config{
@@ -189,6 +228,64 @@ func TestLogger_ErrorField(t *testing.T) {
 	}
 }
 
+func TestLogger_Redact(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger := New(&Config{
+		Level:  LevelInfo,
+		Format: FormatText,
+		Output: &buf,
+		Redact: func(s string) string { return strings.ReplaceAll(s, "secret", "<redacted>") },
+	}).WithPrefix("TestComponent")
+
+	logger.Info("token secret", String("header", "Bearer secret"), Int("count", 1))
+
+	output := buf.String()
+	if strings.Contains(output, "secret") {
+		t.Errorf("Expected message and fields to be redacted, got: %s", output)
+	}
+	if !strings.Contains(output, "header=Bearer <redacted>") || !strings.Contains(output, "count=1") {
+		t.Errorf("Unexpected output: %s", output)
+	}
+}
+
+func TestLogger_SetLevel(t *testing.T) {
+	var buf bytes.Buffer
+	root := New(&Config{Level: LevelInfo, Format: FormatText, Output: &buf})
+	logger := root.WithPrefix("TestComponent")
+
+	logger.Debug("hidden")
+	root.SetLevel(LevelDebug)
+	logger.Debug("shown")
+
+	output := buf.String()
+	if strings.Contains(output, "hidden") || !strings.Contains(output, "shown") {
+		t.Errorf("Expected the new level to apply to derived loggers, got: %s", output)
+	}
+	if logger.Level() != LevelDebug || logger.Level().String() != "DEBUG" {
+		t.Errorf("Expected DEBUG, got %s", logger.Level())
+	}
+}
+
+func TestLogger_SetContext(t *testing.T) {
+	var buf bytes.Buffer
+	root := New(&Config{Level: LevelInfo, Format: FormatText, Output: &buf})
+	logger := root.WithPrefix("TestComponent")
+
+	root.SetContext(Field{Key: "generation", Value: uint64(7)})
+	logger.Info("during", String("project", "p"))
+	root.SetContext()
+	logger.Info("after")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.HasSuffix(lines[0], "during generation=7 project=p") {
+		t.Errorf("Expected the context field before the entry's fields, got: %q", lines)
+	}
+	if strings.Contains(lines[1], "generation") {
+		t.Errorf("Expected the context to be cleared, got: %s", lines[1])
+	}
+}
+
 func TestLogger_JSONEscaping(t *testing.T) {
 	var buf bytes.Buffer
 	logger := New(&Config{
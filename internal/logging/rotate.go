@@ -0,0 +1,160 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// rotatedSuffix is the time layout appended to rotated log files
+const rotatedSuffix = "20060102T150405.000Z"
+
+// RotationConfig sets when a RotatingFile is rotated and how many rotated
+// files are kept. Zero values disable the limit.
+type RotationConfig struct {
+	MaxSize    int64         // Rotate before a write would grow the file past this many bytes
+	MaxAge     time.Duration // Rotate once the file has been written to for this long
+	MaxBackups int           // Rotated files kept, oldest removed first
+}
+
+// RotatingFile is an io.Writer appending to a log file, which is renamed to
+// <path>.<UTC time> and started afresh when it reaches its size or age
+// limit. Rotation failures are reported on stderr and writing continues to
+// the current file, so logging never stops because of them.
+type RotatingFile struct {
+	mu     sync.Mutex
+	path   string
+	config RotationConfig
+	file   *os.File
+	size   int64
+	opened time.Time // When the current file was opened or rotated; its age counts from here
+	now    func() time.Time
+}
+
+// OpenRotatingFile opens (or creates) the log file at path for appending
+func OpenRotatingFile(path string, config RotationConfig) (*RotatingFile, error) {
+	f := &RotatingFile{path: path, config: config, now: time.Now}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// open opens the log file, creating its directory if needed
+func (f *RotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+		return fmt.Errorf("failed to create log directory: %w", err)
+	}
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+	f.file = file
+	f.size = info.Size()
+	f.opened = f.now()
+	return nil
+}
+
+// Write appends p to the log file, rotating it first if p would take it
+// past MaxSize or it is older than MaxAge
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.due(int64(len(p))) {
+		if err := f.rotate(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to rotate log file %s: %v\n", f.path, err)
+		}
+	}
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// due reports whether the file must be rotated before writing n bytes.
+// An empty file is never rotated, so a single oversized write still lands.
+func (f *RotatingFile) due(n int64) bool {
+	if f.size == 0 {
+		return false
+	}
+	if f.config.MaxSize > 0 && f.size+n > f.config.MaxSize {
+		return true
+	}
+	return f.config.MaxAge > 0 && f.now().Sub(f.opened) >= f.config.MaxAge
+}
+
+// rotate renames the current file aside, opens a new one and removes the
+// rotated files beyond MaxBackups
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	f.file = nil
+	rotated := f.path + "." + f.now().UTC().Format(rotatedSuffix)
+	if err := os.Rename(f.path, rotated); err != nil {
+		return err
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	return f.prune()
+}
+
+// prune removes the oldest rotated files beyond MaxBackups
+func (f *RotatingFile) prune() error {
+	if f.config.MaxBackups <= 0 {
+		return nil
+	}
+	rotated, err := f.Rotated()
+	if err != nil {
+		return err
+	}
+	for len(rotated) > f.config.MaxBackups {
+		if err := os.Remove(rotated[0]); err != nil {
+			return err
+		}
+		rotated = rotated[1:]
+	}
+	return nil
+}
+
+// Rotated returns the rotated files of the log file, oldest first
+func (f *RotatingFile) Rotated() ([]string, error) {
+	matches, err := filepath.Glob(f.path + ".*")
+	if err != nil {
+		return nil, err
+	}
+	rotated := matches[:0]
+	for _, match := range matches {
+		if _, err := time.Parse(rotatedSuffix, match[len(f.path)+1:]); err == nil {
+			rotated = append(rotated, match)
+		}
+	}
+	sort.Strings(rotated) // The time layout sorts chronologically
+	return rotated, nil
+}
+
+// Close closes the current log file
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
@@ -0,0 +1,71 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotatingFile_MaxSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "provider.log")
+	file, err := OpenRotatingFile(path, RotationConfig{MaxSize: 10, MaxBackups: 2})
+	if err != nil {
+		t.Fatalf("OpenRotatingFile failed: %v", err)
+	}
+	defer file.Close()
+	clock := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	file.now = func() time.Time { clock = clock.Add(time.Second); return clock }
+
+	for _, line := range []string{"one\n", "two\n", "three\n", "four\n", "five\n", "six\n"} {
+		if _, err := file.Write([]byte(line)); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+
+	rotated, err := file.Rotated()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rotated) != 2 {
+		t.Fatalf("Expected 2 rotated files kept, got %v", rotated)
+	}
+	oldest, _ := os.ReadFile(rotated[0])
+	current, _ := os.ReadFile(path)
+	if string(oldest) != "three\n" || string(current) != "six\n" {
+		t.Errorf("Unexpected contents: oldest %q, current %q", oldest, current)
+	}
+}
+
+func TestRotatingFile_MaxAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "provider.log")
+	if err := os.WriteFile(path, []byte("before restart\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := OpenRotatingFile(path, RotationConfig{MaxAge: time.Hour})
+	if err != nil {
+		t.Fatalf("OpenRotatingFile failed: %v", err)
+	}
+	defer file.Close()
+	clock := time.Now()
+	file.now = func() time.Time { return clock }
+
+	// Appends to the existing file until it is an hour old
+	_, _ = file.Write([]byte("first\n"))
+	clock = clock.Add(time.Hour)
+	_, _ = file.Write([]byte("second\n"))
+
+	rotated, _ := file.Rotated()
+	if len(rotated) != 1 {
+		t.Fatalf("Expected one rotated file, got %v", rotated)
+	}
+	old, _ := os.ReadFile(rotated[0])
+	current, _ := os.ReadFile(path)
+	if string(old) != "before restart\nfirst\n" || string(current) != "second\n" {
+		t.Errorf("Unexpected contents: rotated %q, current %q", old, current)
+	}
+	if !strings.HasPrefix(filepath.Base(rotated[0]), "provider.log.") {
+		t.Errorf("Unexpected rotated name %s", rotated[0])
+	}
+}
@@ -0,0 +1,133 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// SlogLevel returns the log/slog level matching level
+func (l Level) SlogLevel() slog.Level {
+	switch l {
+	case LevelDebug:
+		return slog.LevelDebug
+	case LevelWarn:
+		return slog.LevelWarn
+	case LevelError:
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// levelFromSlog returns the level a log/slog level is logged at. Levels in
+// between round down, so slog.LevelInfo+2 is logged as INFO.
+func levelFromSlog(level slog.Level) Level {
+	switch {
+	case level >= slog.LevelError:
+		return LevelError
+	case level >= slog.LevelWarn:
+		return LevelWarn
+	case level >= slog.LevelInfo:
+		return LevelInfo
+	default:
+		return LevelDebug
+	}
+}
+
+// writeHandler passes an entry to the slog.Handler set in Config.Handler
+// instead of formatting it. The logger's prefix becomes a "component"
+// attribute and field values are passed as the (redacted) strings the
+// text and JSON formats would write.
+func (l *Logger) writeHandler(level Level, msg string, fields []Field) {
+	ctx := context.Background()
+	if !l.handler.Enabled(ctx, level.SlogLevel()) {
+		return
+	}
+	record := slog.NewRecord(time.Now(), level.SlogLevel(), msg, 0)
+	if l.prefix != "" {
+		record.AddAttrs(slog.String("component", l.prefix))
+	}
+	for _, f := range fields {
+		record.AddAttrs(slog.String(f.Key, f.Value.(string)))
+	}
+	_ = l.handler.Handle(ctx, record) // Logging never fails the caller
+}
+
+// Handler returns a log/slog handler writing through the logger, so code
+// logging with slog (or with logr, through logr.FromSlogHandler) shares
+// its level, format, redaction and context fields. Attributes become
+// fields; groups are flattened into dotted keys.
+func (l *Logger) Handler() slog.Handler {
+	return &slogHandler{logger: l}
+}
+
+// slogHandler is the slog.Handler returned by Logger.Handler
+type slogHandler struct {
+	logger *Logger
+	fields []Field // Added by WithAttrs
+	group  string  // Key prefix added by WithGroup, "" or ending in "."
+}
+
+// Enabled reports whether the logger writes entries of level
+func (h *slogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.logger.Enabled(levelFromSlog(level))
+}
+
+// Handle writes record as a logger entry
+func (h *slogHandler) Handle(_ context.Context, record slog.Record) error {
+	fields := make([]Field, 0, len(h.fields)+record.NumAttrs())
+	fields = append(fields, h.fields...)
+	record.Attrs(func(attr slog.Attr) bool {
+		fields = appendAttr(fields, h.group, attr)
+		return true
+	})
+	h.logger.log(levelFromSlog(record.Level), record.Message, fields...)
+	return nil
+}
+
+// WithAttrs returns a handler adding attrs to every entry
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	fields := append([]Field(nil), h.fields...)
+	for _, attr := range attrs {
+		fields = appendAttr(fields, h.group, attr)
+	}
+	return &slogHandler{logger: h.logger, fields: fields, group: h.group}
+}
+
+// WithGroup returns a handler prefixing later attribute keys with name
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &slogHandler{logger: h.logger, fields: h.fields, group: h.group + name + "."}
+}
+
+// appendAttr appends attr to fields under the group key prefix, flattening
+// group attributes and dropping empty ones as slog handlers should
+func appendAttr(fields []Field, group string, attr slog.Attr) []Field {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return fields
+	}
+	if attr.Value.Kind() == slog.KindGroup {
+		prefix := group
+		if attr.Key != "" {
+			prefix = group + attr.Key + "."
+		}
+		for _, member := range attr.Value.Group() {
+			fields = appendAttr(fields, prefix, member)
+		}
+		return fields
+	}
+	key := strings.TrimSuffix(group+attr.Key, ".")
+	switch attr.Value.Kind() {
+	case slog.KindTime:
+		return append(fields, Time(key, attr.Value.Time()))
+	case slog.KindDuration:
+		return append(fields, Duration(key, attr.Value.Duration()))
+	default:
+		return append(fields, Any(key, attr.Value.Any()))
+	}
+}
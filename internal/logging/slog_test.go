@@ -0,0 +1,59 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogger_Handler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&Config{Level: LevelInfo, Format: FormatText, Output: &buf}).WithPrefix("Embedder")
+	logger.SetContext(Int("generation", 3))
+
+	log := slog.New(logger.Handler()).With("project", "p1").WithGroup("req")
+	log.Debug("filtered")
+	if buf.Len() != 0 {
+		t.Fatalf("Expected slog debug entries filtered at INFO, got %q", buf.String())
+	}
+
+	log.Warn("slow", "latency", 2*time.Second, slog.Group("route", "host", "a.example"))
+	output := buf.String()
+	for _, want := range []string{"[WARN] Embedder: slow", "generation=3", "project=p1", "req.latency=2s", "req.route.host=a.example"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in %q", want, output)
+		}
+	}
+
+	buf.Reset()
+	logger.SetLevel(LevelDebug)
+	log.Log(context.Background(), slog.LevelDebug-4, "trace")
+	if !strings.Contains(buf.String(), "[DEBUG] Embedder: trace") {
+		t.Errorf("Expected levels below debug logged as DEBUG, got %q", buf.String())
+	}
+}
+
+func TestLogger_ToHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&Config{
+		Level:   LevelInfo,
+		Output:  &bytes.Buffer{},
+		Redact:  func(s string) string { return strings.ReplaceAll(s, "secret", "[REDACTED]") },
+		Handler: slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}),
+	}).WithPrefix("CloudRunProvider")
+
+	logger.Debug("filtered")
+	logger.Warn("token secret", Int("routers", 2))
+	output := buf.String()
+	for _, want := range []string{`"level":"WARN"`, `"msg":"token [REDACTED]"`, `"component":"CloudRunProvider"`, `"routers":"2"`} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %s in %s", want, output)
+		}
+	}
+	if strings.Contains(output, "filtered") {
+		t.Errorf("Expected the logger level to apply before the handler, got %s", output)
+	}
+}
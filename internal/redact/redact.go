@@ -0,0 +1,233 @@
+// Package redact hides credentials and personal data in logs, previews and
+// reports. One Policy decides which headers carry credentials, which JWT
+// claims stay readable and how email addresses are masked, so every output
+// redacts the same way.
+package redact
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Email modes (Rules.Emails)
+const (
+	EmailsMask = "mask" // Keep the first 2 characters of the local part and the domain (default)
+	EmailsHide = "hide" // Keep only the domain
+	EmailsKeep = "keep" // Leave addresses as they are
+)
+
+// Redacted replaces values nothing of which can be shown
+const Redacted = "<redacted>"
+
+// previewChars are kept at each end of an opaque token
+const previewChars = 20
+
+// DefaultCredentialHeaders carry credentials in every policy
+var DefaultCredentialHeaders = []string{"Authorization", "X-Serverless-Authorization", "Proxy-Authorization"}
+
+// DefaultEmailHeaders carry user email addresses
+var DefaultEmailHeaders = []string{"X-User-Email"}
+
+// DefaultClaims are the JWT claims kept when a token is redacted: enough to
+// tell which audience and issuer a token is for and whether it expired
+var DefaultClaims = []string{"iss", "aud", "azp", "exp", "iat"}
+
+// Rules configure a Policy. Empty fields use the defaults.
+type Rules struct {
+	Headers      []string // Extra credential headers, added to DefaultCredentialHeaders
+	EmailHeaders []string // Extra email headers, added to DefaultEmailHeaders
+	Claims       []string // JWT claims kept, replacing DefaultClaims
+	Emails       string   // EmailsMask (default), EmailsHide or EmailsKeep
+}
+
+// Policy redacts values according to its rules. The zero value is not
+// usable; use New or Default.
+type Policy struct {
+	credentialHeaders map[string]bool // canonical header names
+	emailHeaders      map[string]bool
+	claims            []string
+	emails            string
+}
+
+// Default is the policy with the default rules
+var Default = MustNew(Rules{})
+
+// New returns the policy for rules
+func New(rules Rules) (*Policy, error) {
+	switch rules.Emails {
+	case "":
+		rules.Emails = EmailsMask
+	case EmailsMask, EmailsHide, EmailsKeep:
+	default:
+		return nil, fmt.Errorf("invalid email redaction %q (expected %s, %s or %s)", rules.Emails, EmailsMask, EmailsHide, EmailsKeep)
+	}
+	p := &Policy{
+		credentialHeaders: headerSet(DefaultCredentialHeaders, rules.Headers),
+		emailHeaders:      headerSet(DefaultEmailHeaders, rules.EmailHeaders),
+		claims:            rules.Claims,
+		emails:            rules.Emails,
+	}
+	if len(p.claims) == 0 {
+		p.claims = DefaultClaims
+	}
+	return p, nil
+}
+
+// MustNew is New for rules known to be valid
+func MustNew(rules Rules) *Policy {
+	p, err := New(rules)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// headerSet returns the canonical names of both lists
+func headerSet(defaults, extra []string) map[string]bool {
+	set := make(map[string]bool, len(defaults)+len(extra))
+	for _, name := range append(append([]string{}, defaults...), extra...) {
+		if name = strings.TrimSpace(name); name != "" {
+			set[http.CanonicalHeaderKey(name)] = true
+		}
+	}
+	return set
+}
+
+// Headers returns a copy of headers with credential and email values redacted
+func (p *Policy) Headers(headers map[string]string) map[string]string {
+	redacted := make(map[string]string, len(headers))
+	for name, value := range headers {
+		redacted[name] = p.Header(name, value)
+	}
+	return redacted
+}
+
+// CredentialHeader reports whether a header carries credentials under the
+// policy
+func (p *Policy) CredentialHeader(name string) bool {
+	return p.credentialHeaders[http.CanonicalHeaderKey(name)]
+}
+
+// Header redacts the value of one header if its name carries credentials or
+// an email address
+func (p *Policy) Header(name, value string) string {
+	canonical := http.CanonicalHeaderKey(name)
+	switch {
+	case p.CredentialHeader(canonical):
+		if token, ok := strings.CutPrefix(value, "Bearer "); ok {
+			return "Bearer " + p.Token(token)
+		}
+		return p.Token(value)
+	case p.emailHeaders[canonical]:
+		return p.Email(value)
+	}
+	return value
+}
+
+// Token redacts a credential. JWTs keep the policy's claims, e.g.
+// JWT(iss=https://accounts.google.com aud=https://lab1.run.app exp=...),
+// and lose their signature; other tokens keep their first and last 20
+// characters, or nothing when that would show most of the token.
+func (p *Policy) Token(token string) string {
+	if claims, ok := p.jwtClaims(token); ok {
+		return "JWT(" + claims + ")"
+	}
+	if len(token) <= 2*previewChars {
+		return Redacted
+	}
+	return token[:previewChars] + "..." + token[len(token)-previewChars:]
+}
+
+// jwtClaims renders the kept claims of a JWT, or reports that token isn't one
+func (p *Policy) jwtClaims(token string) (string, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || !strings.HasPrefix(parts[0], "eyJ") {
+		return "", false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return "", false
+	}
+	var claims map[string]any
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return "", false
+	}
+
+	kept := make([]string, 0, len(p.claims))
+	for _, name := range p.claims {
+		value, ok := claims[name]
+		if !ok {
+			continue
+		}
+		var rendered string
+		switch v := value.(type) {
+		case float64:
+			if name == "exp" || name == "iat" || name == "nbf" {
+				rendered = time.Unix(int64(v), 0).UTC().Format(time.RFC3339)
+			} else {
+				rendered = fmt.Sprint(v)
+			}
+		case string:
+			rendered = v
+			if strings.Contains(v, "@") {
+				rendered = p.Email(v)
+			}
+		default:
+			data, _ := json.Marshal(v)
+			rendered = string(data)
+		}
+		kept = append(kept, name+"="+rendered)
+	}
+	return strings.Join(kept, " "), true
+}
+
+// serviceAccountDomains hold service identities, not people; their
+// addresses are never masked
+var serviceAccountDomains = []string{".gserviceaccount.com", "gserviceaccount.com"}
+
+// Email masks an email address according to the policy
+func (p *Policy) Email(email string) string {
+	local, domain, ok := strings.Cut(email, "@")
+	if !ok || p.emails == EmailsKeep {
+		return email
+	}
+	for _, suffix := range serviceAccountDomains {
+		if strings.HasSuffix(domain, suffix) {
+			return email
+		}
+	}
+	if p.emails == EmailsHide {
+		return Redacted + "@" + domain
+	}
+	if len(local) <= 2 {
+		return email // Too short to mask meaningfully
+	}
+	return local[:2] + "@" + domain
+}
+
+var (
+	// tokenPattern matches bearer credentials, bare JWTs and OAuth access
+	// tokens in one pass, so a redacted token is never matched again
+	tokenPattern = regexp.MustCompile(`\bBearer\s+[A-Za-z0-9._~+/=-]+|\beyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*|\bya29\.[A-Za-z0-9._-]+`)
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`)
+)
+
+// String redacts the bearer tokens, JWTs, OAuth access tokens and email
+// addresses found in free text, such as a log message or an error
+func (p *Policy) String(s string) string {
+	if !strings.ContainsAny(s, ".@") {
+		return s // Fast path: no token or address can be present
+	}
+	s = tokenPattern.ReplaceAllStringFunc(s, func(match string) string {
+		if token, ok := strings.CutPrefix(match, "Bearer"); ok {
+			return "Bearer " + p.Token(strings.TrimLeft(token, " \t"))
+		}
+		return p.Token(match)
+	})
+	return emailPattern.ReplaceAllStringFunc(s, p.Email)
+}
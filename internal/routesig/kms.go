@@ -0,0 +1,52 @@
+package routesig
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	cloudkms "google.golang.org/api/cloudkms/v1"
+	"google.golang.org/api/option"
+)
+
+// kmsSigner signs with a Cloud KMS MAC key version, so the key never leaves KMS
+type kmsSigner struct {
+	versions   *cloudkms.ProjectsLocationsKeyRingsCryptoKeysCryptoKeyVersionsService
+	keyVersion string
+}
+
+// NewKMS returns a signer using the Cloud KMS HMAC key version keyVersion
+// (projects/<p>/locations/<l>/keyRings/<r>/cryptoKeys/<k>/cryptoKeyVersions/<n>).
+// The caller needs roles/cloudkms.signerVerifier on the key.
+func NewKMS(ctx context.Context, keyVersion string, opts ...option.ClientOption) (Signer, error) {
+	service, err := cloudkms.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Cloud KMS client: %w", err)
+	}
+	return &kmsSigner{versions: service.Projects.Locations.KeyRings.CryptoKeys.CryptoKeyVersions, keyVersion: keyVersion}, nil
+}
+
+func (s *kmsSigner) Algorithm() string { return "kms-hmac" }
+
+func (s *kmsSigner) KeyID() string { return s.keyVersion }
+
+func (s *kmsSigner) Sign(data []byte) ([]byte, error) {
+	resp, err := s.versions.MacSign(s.keyVersion, &cloudkms.MacSignRequest{
+		Data: base64.StdEncoding.EncodeToString(data),
+	}).Do()
+	if err != nil {
+		return nil, fmt.Errorf("KMS MacSign failed: %w", err)
+	}
+	return base64.StdEncoding.DecodeString(resp.Mac)
+}
+
+func (s *kmsSigner) Verify(data, mac []byte) (bool, error) {
+	resp, err := s.versions.MacVerify(s.keyVersion, &cloudkms.MacVerifyRequest{
+		Data: base64.StdEncoding.EncodeToString(data),
+		Mac:  base64.StdEncoding.EncodeToString(mac),
+	}).Do()
+	if err != nil {
+		return false, fmt.Errorf("KMS MacVerify failed: %w", err)
+	}
+	return resp.Success, nil
+}
@@ -0,0 +1,122 @@
+// Package routesig signs generated routes files so that manual edits can be
+// detected. The signature is embedded as the file's last line,
+//
+//	# signature: <algorithm> <key id> <base64 MAC>
+//
+// and covers everything before it, so the file stays valid YAML and Traefik
+// ignores the signature.
+package routesig
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// signaturePrefix starts the embedded signature line
+const signaturePrefix = "# signature: "
+
+// Errors returned by Verify
+var (
+	ErrUnsigned = errors.New("file is not signed")
+	ErrTampered = errors.New("signature does not match the file contents")
+)
+
+// Signer computes and checks MACs over routes files
+type Signer interface {
+	Algorithm() string // Algorithm name recorded in the signature line
+	KeyID() string     // Identifies the key, so the wrong key is reported as such
+	Sign(data []byte) ([]byte, error)
+	Verify(data, mac []byte) (bool, error)
+}
+
+// Sign returns data with its signature line appended, replacing any
+// signature it already carries
+func Sign(data []byte, signer Signer) ([]byte, error) {
+	content, _, _ := split(data)
+	if len(content) > 0 && !bytes.HasSuffix(content, []byte("\n")) {
+		content = append(content, '\n')
+	}
+	mac, err := signer.Sign(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign: %w", err)
+	}
+	line := fmt.Sprintf("%s%s %s %s\n", signaturePrefix, signer.Algorithm(), signer.KeyID(), base64.StdEncoding.EncodeToString(mac))
+	return append(content, line...), nil
+}
+
+// Verify checks the embedded signature of data. It returns ErrUnsigned if
+// there is none and ErrTampered if it doesn't match.
+func Verify(data []byte, signer Signer) error {
+	content, line, ok := split(data)
+	if !ok {
+		return ErrUnsigned
+	}
+	fields := strings.Fields(strings.TrimPrefix(line, signaturePrefix))
+	if len(fields) != 3 {
+		return fmt.Errorf("malformed signature line %q", line)
+	}
+	if fields[0] != signer.Algorithm() || fields[1] != signer.KeyID() {
+		return fmt.Errorf("signed with %s key %s, expected %s key %s", fields[0], fields[1], signer.Algorithm(), signer.KeyID())
+	}
+	mac, err := base64.StdEncoding.DecodeString(fields[2])
+	if err != nil {
+		return fmt.Errorf("malformed signature: %w", err)
+	}
+	valid, err := signer.Verify(content, mac)
+	if err != nil {
+		return fmt.Errorf("failed to verify: %w", err)
+	}
+	if !valid {
+		return ErrTampered
+	}
+	return nil
+}
+
+// split separates the signed content from the signature line at its end
+func split(data []byte) ([]byte, string, bool) {
+	trimmed := bytes.TrimSuffix(data, []byte("\n"))
+	start := bytes.LastIndexByte(trimmed, '\n') + 1
+	line := string(trimmed[start:])
+	if !strings.HasPrefix(line, signaturePrefix) {
+		return data, "", false
+	}
+	return trimmed[:start], line, true
+}
+
+// hmacSigner signs with a local HMAC-SHA256 key
+type hmacSigner struct {
+	key []byte
+}
+
+// NewHMAC returns a signer using key for HMAC-SHA256
+func NewHMAC(key []byte) (Signer, error) {
+	if len(key) < 16 {
+		return nil, fmt.Errorf("HMAC key must be at least 16 bytes, got %d", len(key))
+	}
+	return &hmacSigner{key: key}, nil
+}
+
+func (s *hmacSigner) Algorithm() string { return "hmac-sha256" }
+
+// KeyID is a fingerprint of the key, not the key itself
+func (s *hmacSigner) KeyID() string {
+	sum := sha256.Sum256(s.key)
+	return hex.EncodeToString(sum[:8])
+}
+
+func (s *hmacSigner) Sign(data []byte) ([]byte, error) {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write(data)
+	return mac.Sum(nil), nil
+}
+
+func (s *hmacSigner) Verify(data, mac []byte) (bool, error) {
+	expected, _ := s.Sign(data)
+	return hmac.Equal(expected, mac), nil
+}
@@ -0,0 +1,71 @@
+package routesig
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const routes = `# Generated by traefik-cloudrun-provider
+http:
+  routers:
+    api:
+      rule: PathPrefix(` + "`/api`" + `)
+`
+
+func newTestSigner(t *testing.T, key string) Signer {
+	t.Helper()
+	signer, err := NewHMAC([]byte(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+func TestSignVerify(t *testing.T) {
+	signer := newTestSigner(t, "0123456789abcdef0123456789abcdef")
+
+	signed, err := Sign([]byte(routes), signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(signed, []byte(routes)) || !strings.Contains(string(signed), "\n# signature: hmac-sha256 ") {
+		t.Fatalf("Expected the signature appended to the routes, got:\n%s", signed)
+	}
+	if err := Verify(signed, signer); err != nil {
+		t.Errorf("Verify failed: %v", err)
+	}
+
+	// Re-signing replaces the signature instead of stacking them
+	resigned, err := Sign(signed, signer)
+	if err != nil || !bytes.Equal(resigned, signed) {
+		t.Errorf("Expected re-signing to be idempotent, got:\n%s (%v)", resigned, err)
+	}
+}
+
+func TestVerify_Failures(t *testing.T) {
+	signer := newTestSigner(t, "0123456789abcdef0123456789abcdef")
+	signed, err := Sign([]byte(routes), signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := bytes.Replace(signed, []byte("/api"), []byte("/adm"), 1)
+	if err := Verify(tampered, signer); !errors.Is(err, ErrTampered) {
+		t.Errorf("Expected ErrTampered for an edited file, got %v", err)
+	}
+	if err := Verify([]byte(routes), signer); !errors.Is(err, ErrUnsigned) {
+		t.Errorf("Expected ErrUnsigned, got %v", err)
+	}
+	other := newTestSigner(t, "fedcba9876543210fedcba9876543210")
+	if err := Verify(signed, other); err == nil || !strings.Contains(err.Error(), "expected hmac-sha256 key") {
+		t.Errorf("Expected a wrong key error, got %v", err)
+	}
+}
+
+func TestNewHMAC_ShortKey(t *testing.T) {
+	if _, err := NewHMAC([]byte("short")); err == nil {
+		t.Error("Expected short keys to be rejected")
+	}
+}
@@ -0,0 +1,98 @@
+// Package schema versions the generated routes file and migrates files
+// written by older provider versions to the current structure.
+//
+// The version is recorded in the file header as "# Schema-Version: N".
+// Files without the header predate versioning and are treated as version 1.
+package schema
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CurrentVersion is the schema version written by this provider
+const CurrentVersion = 2
+
+// headerPrefix marks the schema version line in the file header
+const headerPrefix = "# Schema-Version:"
+
+// migration upgrades a decoded routes document from version N to N+1
+type migration func(doc map[string]interface{}) error
+
+// migrations[N] upgrades version N to N+1. Add an entry whenever the
+// generated structure changes in a way running Traefik instances would notice.
+var migrations = map[int]migration{
+	// Version 1 files are structurally identical to version 2; version 2
+	// only introduces the header, so there is nothing to rewrite
+	1: func(map[string]interface{}) error { return nil },
+}
+
+// Header returns the schema version header line
+func Header() string {
+	return fmt.Sprintf("%s %d", headerPrefix, CurrentVersion)
+}
+
+// DetectVersion returns the schema version recorded in a routes file's
+// header comments, or 1 if there is none
+func DetectVersion(data []byte) (int, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "#") {
+			if line == "" {
+				continue
+			}
+			break // Header comments end at the first YAML line
+		}
+		if strings.HasPrefix(line, headerPrefix) {
+			version, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, headerPrefix)))
+			if err != nil || version < 1 {
+				return 0, fmt.Errorf("invalid schema version header %q", line)
+			}
+			return version, nil
+		}
+	}
+	return 1, nil
+}
+
+// Migrate upgrades a routes file to CurrentVersion. It returns the migrated
+// YAML body (without header comments), the version the file was written
+// with, and whether any migration was needed.
+func Migrate(data []byte) ([]byte, int, bool, error) {
+	version, err := DetectVersion(data)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	if version > CurrentVersion {
+		return nil, version, false, fmt.Errorf("routes file schema version %d is newer than supported version %d", version, CurrentVersion)
+	}
+	if version == CurrentVersion {
+		return data, version, false, nil
+	}
+
+	doc := make(map[string]interface{})
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, version, false, fmt.Errorf("failed to parse routes file: %w", err)
+	}
+
+	for v := version; v < CurrentVersion; v++ {
+		migrate, ok := migrations[v]
+		if !ok {
+			return nil, version, false, fmt.Errorf("no migration from schema version %d", v)
+		}
+		if err := migrate(doc); err != nil {
+			return nil, version, false, fmt.Errorf("migration from schema version %d failed: %w", v, err)
+		}
+	}
+
+	out, err := yaml.Marshal(doc)
+	if err != nil {
+		return nil, version, false, fmt.Errorf("failed to encode migrated routes file: %w", err)
+	}
+	return out, version, true, nil
+}
@@ -0,0 +1,58 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetectVersion(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		expected int
+	}{
+		{"unversioned", "# Auto-generated Traefik routes\nhttp:\n  routers: {}\n", 1},
+		{"versioned", "# Auto-generated Traefik routes\n# Schema-Version: 2\n\nhttp: {}\n", 2},
+		{"header after yaml is ignored", "http: {}\n# Schema-Version: 5\n", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version, err := DetectVersion([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if version != tt.expected {
+				t.Errorf("Expected version %d, got %d", tt.expected, version)
+			}
+		})
+	}
+
+	if _, err := DetectVersion([]byte("# Schema-Version: two\n")); err == nil {
+		t.Error("Expected error for invalid version header")
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	v1 := "# Auto-generated Traefik routes\nhttp:\n  routers:\n    lab1:\n      rule: PathPrefix(`/lab1`)\n"
+
+	out, from, migrated, err := Migrate([]byte(v1))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if from != 1 || !migrated {
+		t.Errorf("Expected migration from version 1, got from=%d migrated=%v", from, migrated)
+	}
+	if !strings.Contains(string(out), "PathPrefix(`/lab1`)") {
+		t.Errorf("Expected router to survive migration, got:\n%s", out)
+	}
+
+	current := Header() + "\nhttp: {}\n"
+	if _, _, migrated, err := Migrate([]byte(current)); err != nil || migrated {
+		t.Errorf("Expected current version to be left alone, got migrated=%v err=%v", migrated, err)
+	}
+
+	if _, _, _, err := Migrate([]byte("# Schema-Version: 99\nhttp: {}\n")); err == nil {
+		t.Error("Expected error for newer schema version")
+	}
+}
@@ -0,0 +1,341 @@
+// Package middleware provides a Traefik HTTP middleware plugin that injects a
+// fresh Cloud Run identity token into each request.
+//
+// The provider's default mode bakes identity tokens into static headers
+// middlewares, which go stale if the config isn't regenerated before the token
+// expires. With TOKEN_INJECTION=plugin the provider instead emits middlewares
+// that reference this plugin, and the token is fetched (and cached) from the
+// metadata server at request time.
+//
+// The same plugin also backs the provider's REQUEST_ID_ENABLED option: with
+// requestIDHeader set (and no audience) it only generates a request ID for
+// requests that arrive without one.
+//
+// Only the standard library and internal/logging are used so that Traefik's
+// Yaegi interpreter can load this package as a local plugin.
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/internal/logging"
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/internal/redact"
+)
+
+const (
+	defaultHeaderName    = "X-Serverless-Authorization"
+	defaultCacheDuration = 55 * time.Minute // GCP identity tokens expire after 1 hour
+	defaultMetadataHost  = "metadata.google.internal"
+	metadataIdentityPath = "/computeMetadata/v1/instance/service-accounts/default/identity"
+	metadataTokenPath    = "/computeMetadata/v1/instance/service-accounts/default/token"
+)
+
+// Auth types, matching the provider's traefik_auth_type label
+const (
+	authTypeIDToken     = "id_token"
+	authTypeAccessToken = "access_token"
+)
+
+// Config represents the token middleware plugin configuration
+type Config struct {
+	// Audience is the Cloud Run service URL the token is minted for
+	Audience string `json:"audience,omitempty" yaml:"audience,omitempty"`
+
+	// HeaderName is the request header the token is written to.
+	// Defaults to X-Serverless-Authorization so the user's Authorization header passes through.
+	HeaderName string `json:"headerName,omitempty" yaml:"headerName,omitempty"`
+
+	// TokenCacheDuration is how long a fetched token is reused
+	TokenCacheDuration time.Duration `json:"tokenCacheDuration,omitempty" yaml:"tokenCacheDuration,omitempty"`
+
+	// AuthType is id_token (default) for an identity token minted for
+	// Audience, or access_token for an OAuth access token, which has no
+	// audience (set HeaderName to Authorization for those backends)
+	AuthType string `json:"authType,omitempty" yaml:"authType,omitempty"`
+
+	// Scopes are the OAuth scopes access tokens are requested for (default:
+	// the service account's scopes, as granted by the metadata server)
+	Scopes []string `json:"scopes,omitempty" yaml:"scopes,omitempty"`
+
+	// RequestIDHeader, when set, is filled with a random ID on requests that
+	// don't carry one and echoed on the response. Audience may then be empty
+	// to use the plugin for request IDs only.
+	RequestIDHeader string `json:"requestIDHeader,omitempty" yaml:"requestIDHeader,omitempty"`
+}
+
+// CreateConfig creates the default plugin configuration
+func CreateConfig() *Config {
+	return &Config{
+		HeaderName:         defaultHeaderName,
+		TokenCacheDuration: defaultCacheDuration,
+	}
+}
+
+// TokenInjector is an http.Handler that sets an identity token header before
+// passing the request to the next handler
+type TokenInjector struct {
+	next          http.Handler
+	name          string
+	audience      string
+	accessToken   bool // Fetch OAuth access tokens instead of identity tokens
+	headerName    string
+	requestID     string // Request ID header ("" = disabled)
+	cacheDuration time.Duration
+	metadataURL   string
+	client        *http.Client
+	logger        *logging.Logger
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+// New creates a new token middleware plugin
+func New(_ context.Context, next http.Handler, config *Config, name string) (http.Handler, error) {
+	if config == nil {
+		return nil, fmt.Errorf("config cannot be nil")
+	}
+	switch config.AuthType {
+	case "", authTypeIDToken:
+		if config.Audience == "" && config.RequestIDHeader == "" {
+			return nil, fmt.Errorf("audience must be specified")
+		}
+	case authTypeAccessToken:
+	default:
+		return nil, fmt.Errorf("authType %q must be %s or %s", config.AuthType, authTypeIDToken, authTypeAccessToken)
+	}
+	accessToken := config.AuthType == authTypeAccessToken
+
+	headerName := config.HeaderName
+	if headerName == "" {
+		headerName = defaultHeaderName
+	}
+	cacheDuration := config.TokenCacheDuration
+	if cacheDuration == 0 {
+		cacheDuration = defaultCacheDuration
+	}
+
+	logLevel := logging.LevelInfo
+	if level := os.Getenv("LOG_LEVEL"); level != "" {
+		if parsed, err := logging.ParseLevel(level); err == nil {
+			logLevel = parsed
+		}
+	}
+
+	logFormat := logging.FormatText
+	if format := os.Getenv("LOG_FORMAT"); format != "" {
+		if parsed, err := logging.ParseFormat(format); err == nil {
+			logFormat = parsed
+		}
+	}
+
+	logger := logging.New(&logging.Config{
+		Level:  logLevel,
+		Format: logFormat,
+		Output: os.Stdout,
+		Redact: redact.Default.String,
+	}).WithPrefix("CloudRunTokenMiddleware")
+
+	logger.Info("Token middleware created",
+		logging.String("name", name),
+		logging.String("audience", config.Audience),
+		logging.String("authType", config.AuthType),
+		logging.String("header", headerName),
+		logging.String("requestIDHeader", config.RequestIDHeader),
+	)
+
+	metadataURL := "http://" + metadataHost() + metadataIdentityPath
+	if accessToken {
+		metadataURL = "http://" + metadataHost() + metadataTokenPath
+		if len(config.Scopes) > 0 {
+			metadataURL += "?scopes=" + url.QueryEscape(strings.Join(config.Scopes, ","))
+		}
+	}
+
+	return &TokenInjector{
+		next:          next,
+		name:          name,
+		audience:      config.Audience,
+		accessToken:   accessToken,
+		headerName:    headerName,
+		requestID:     config.RequestIDHeader,
+		cacheDuration: cacheDuration,
+		metadataURL:   metadataURL,
+		client:        &http.Client{Timeout: 5 * time.Second},
+		logger:        logger,
+	}, nil
+}
+
+// ServeHTTP sets the request ID and injects the identity token, then calls the
+// next handler. If no token can be fetched the request continues without one
+// (a client-supplied token header is dropped) and Cloud Run will reject it
+// with 401, matching the provider's static mode.
+func (t *TokenInjector) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if t.requestID != "" {
+		id := req.Header.Get(t.requestID)
+		if id == "" {
+			id = newRequestID()
+			req.Header.Set(t.requestID, id)
+		}
+		rw.Header().Set(t.requestID, id)
+	}
+
+	if t.audience == "" && !t.accessToken {
+		t.next.ServeHTTP(rw, req)
+		return
+	}
+
+	token, err := t.getToken()
+	if err != nil {
+		t.logger.Error("Failed to fetch identity token",
+			logging.GetCodeField(logging.CodeTokenFetchError),
+			logging.String("name", t.name),
+			logging.String("audience", t.audience),
+			logging.Error(err),
+		)
+		req.Header.Del(t.headerName)
+	} else {
+		req.Header.Set(t.headerName, "Bearer "+token)
+	}
+
+	t.next.ServeHTTP(rw, req)
+}
+
+// metadataHost returns the metadata server host[:port]: GCE_METADATA_HOST
+// when set, as for the provider (see gcp.MetadataHost), otherwise
+// metadata.google.internal
+func metadataHost() string {
+	if host := strings.TrimSpace(os.Getenv("GCE_METADATA_HOST")); host != "" {
+		return strings.TrimSuffix(strings.TrimPrefix(host, "http://"), "/")
+	}
+	return defaultMetadataHost
+}
+
+// newRequestID returns a random 128-bit hex ID
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// getToken returns the cached token or fetches a new one from the metadata server
+func (t *TokenInjector) getToken() (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.token != "" && time.Now().Before(t.expiresAt) {
+		return t.token, nil
+	}
+
+	expiresAt := time.Now().Add(t.cacheDuration)
+	var token string
+	var err error
+	if t.accessToken {
+		var expiry time.Time
+		token, expiry, err = t.fetchAccessToken()
+		if !expiry.IsZero() && expiry.Before(expiresAt) {
+			expiresAt = expiry
+		}
+	} else {
+		token, err = t.fetchFromMetadata()
+	}
+	if err != nil {
+		return "", err
+	}
+
+	t.token = token
+	t.expiresAt = expiresAt
+	t.logger.Debug("Fetched identity token",
+		logging.GetCodeField(logging.CodeTokenFetchSuccess),
+		logging.String("audience", t.audience),
+		logging.Int("tokenLength", len(token)),
+	)
+	return token, nil
+}
+
+// fetchFromMetadata fetches an identity token from the GCP metadata server
+func (t *TokenInjector) fetchFromMetadata() (string, error) {
+	reqURL := t.metadataURL + "?audience=" + url.QueryEscape(t.audience)
+
+	req, err := http.NewRequest("GET", reqURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch token from metadata server: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("metadata server returned %d: %s", resp.StatusCode, string(body))
+	}
+
+	token := strings.TrimSpace(string(body))
+	if !strings.HasPrefix(token, "eyJ") {
+		return "", fmt.Errorf("token doesn't look valid (doesn't start with eyJ)")
+	}
+
+	return token, nil
+}
+
+// fetchAccessToken fetches an OAuth access token for the default service
+// account from the metadata server, with its expiry
+func (t *TokenInjector) fetchAccessToken() (string, time.Time, error) {
+	req, err := http.NewRequest("GET", t.metadataURL, nil)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to fetch access token from metadata server: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to read access token: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", time.Time{}, fmt.Errorf("metadata server returned %d: %s", resp.StatusCode, string(body))
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to parse access token: %w", err)
+	}
+	if token.AccessToken == "" {
+		return "", time.Time{}, fmt.Errorf("metadata server returned empty access token")
+	}
+
+	var expiry time.Time
+	if token.ExpiresIn > 0 {
+		expiry = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+	return token.AccessToken, expiry, nil
+}
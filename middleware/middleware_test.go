@@ -0,0 +1,193 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestInjector creates a TokenInjector pointed at a fake metadata server
+func newTestInjector(t *testing.T, metadataURL string, next http.Handler) *TokenInjector {
+	t.Helper()
+
+	config := CreateConfig()
+	config.Audience = "https://backend.run.app"
+
+	handler, err := New(context.Background(), next, config, "test")
+	if err != nil {
+		t.Fatalf("Failed to create middleware: %v", err)
+	}
+
+	injector := handler.(*TokenInjector)
+	injector.metadataURL = metadataURL
+	return injector
+}
+
+func TestNew_RequiresAudience(t *testing.T) {
+	_, err := New(context.Background(), http.NotFoundHandler(), CreateConfig(), "test")
+	if err == nil {
+		t.Fatal("Expected error when audience is not set")
+	}
+}
+
+func TestTokenInjector_InjectsAndCachesToken(t *testing.T) {
+	var fetches int32
+	metadata := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			t.Errorf("Expected Metadata-Flavor header")
+		}
+		if r.URL.Query().Get("audience") != "https://backend.run.app" {
+			t.Errorf("Unexpected audience: %s", r.URL.Query().Get("audience"))
+		}
+		_, _ = w.Write([]byte("eyJtest-token\n"))
+	}))
+	defer metadata.Close()
+
+	var gotHeader string
+	next := http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		gotHeader = r.Header.Get("X-Serverless-Authorization")
+	})
+
+	injector := newTestInjector(t, metadata.URL, next)
+
+	for i := 0; i < 3; i++ {
+		injector.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}
+
+	if gotHeader != "Bearer eyJtest-token" {
+		t.Errorf("Expected injected token, got %q", gotHeader)
+	}
+	if fetches != 1 {
+		t.Errorf("Expected token to be fetched once and cached, got %d fetches", fetches)
+	}
+}
+
+func TestTokenInjector_PassesThroughOnFetchError(t *testing.T) {
+	metadata := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "denied", http.StatusForbidden)
+	}))
+	defer metadata.Close()
+
+	called := false
+	next := http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		called = true
+		if r.Header.Get("X-Serverless-Authorization") != "" {
+			t.Error("Expected no token header when fetch fails")
+		}
+	})
+
+	injector := newTestInjector(t, metadata.URL, next)
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Serverless-Authorization", "Bearer client-supplied")
+	injector.ServeHTTP(httptest.NewRecorder(), req)
+
+	if !called {
+		t.Error("Expected request to continue to next handler")
+	}
+}
+
+func TestNew_MetadataHostFromEnv(t *testing.T) {
+	metadata := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != metadataIdentityPath {
+			t.Errorf("Unexpected metadata path %s", r.URL.Path)
+		}
+		_, _ = w.Write([]byte("eyJproxied-token"))
+	}))
+	defer metadata.Close()
+	t.Setenv("GCE_METADATA_HOST", metadata.URL+"/")
+
+	var gotHeader string
+	config := CreateConfig()
+	config.Audience = "https://backend.run.app"
+	handler, err := New(context.Background(), http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		gotHeader = r.Header.Get("X-Serverless-Authorization")
+	}), config, "test")
+	if err != nil {
+		t.Fatalf("Failed to create middleware: %v", err)
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	if gotHeader != "Bearer eyJproxied-token" {
+		t.Errorf("Expected token from GCE_METADATA_HOST, got %q", gotHeader)
+	}
+}
+
+func TestTokenInjector_RequestID(t *testing.T) {
+	config := CreateConfig()
+	config.RequestIDHeader = "X-Request-ID"
+
+	var gotID string
+	next := http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		gotID = r.Header.Get("X-Request-ID")
+		if r.Header.Get("X-Serverless-Authorization") != "" {
+			t.Error("Expected no token header without an audience")
+		}
+	})
+
+	handler, err := New(context.Background(), next, config, "request-id")
+	if err != nil {
+		t.Fatalf("Expected request-ID-only middleware without audience, got: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if len(gotID) != 32 {
+		t.Errorf("Expected generated 32-char request ID, got %q", gotID)
+	}
+	if rec.Header().Get("X-Request-ID") != gotID {
+		t.Errorf("Expected request ID echoed on response, got %q", rec.Header().Get("X-Request-ID"))
+	}
+
+	// An existing ID is kept
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Request-ID", "client-id")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if gotID != "client-id" {
+		t.Errorf("Expected incoming request ID to be kept, got %q", gotID)
+	}
+}
+
+func TestTokenInjector_AccessToken(t *testing.T) {
+	metadata := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("audience") != "" {
+			t.Errorf("Expected no audience for access tokens, got %s", r.URL.Query().Get("audience"))
+		}
+		_, _ = w.Write([]byte(`{"access_token":"ya29.test","expires_in":30,"token_type":"Bearer"}`))
+	}))
+	defer metadata.Close()
+
+	config := CreateConfig()
+	config.AuthType = authTypeAccessToken
+	config.HeaderName = "Authorization"
+	config.Scopes = []string{"https://www.googleapis.com/auth/userinfo.email"}
+	handler, err := New(context.Background(), http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer ya29.test" {
+			t.Errorf("Expected access token in Authorization, got %q", got)
+		}
+	}), config, "test")
+	if err != nil {
+		t.Fatalf("Expected access token config without audience to be valid: %v", err)
+	}
+
+	injector := handler.(*TokenInjector)
+	if want := "http://" + defaultMetadataHost + metadataTokenPath + "?scopes=" + url.QueryEscape(config.Scopes[0]); injector.metadataURL != want {
+		t.Errorf("Expected scoped token URL %s, got %s", want, injector.metadataURL)
+	}
+	injector.metadataURL = metadata.URL
+	injector.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	// Cached until the token's own expiry, not the 55m default
+	if remaining := time.Until(injector.expiresAt); remaining > time.Minute {
+		t.Errorf("Expected token cached until its expiry, got %v", remaining)
+	}
+
+	config.AuthType = "password"
+	if _, err := New(context.Background(), http.NotFoundHandler(), config, "test"); err == nil {
+		t.Error("Expected error for unknown authType")
+	}
+}
@@ -0,0 +1,101 @@
+//go:build wasip1
+
+package main
+
+import (
+	"strings"
+	"unsafe"
+)
+
+// Host functions of the http-wasm ABI (https://http-wasm.io/http-handler-abi/)
+
+//go:wasmimport http_handler get_config
+func hostGetConfig(buf unsafe.Pointer, bufLimit uint32) uint32
+
+//go:wasmimport http_handler log
+func hostLog(level int32, message unsafe.Pointer, messageLen uint32)
+
+//go:wasmimport http_handler get_header_values
+func hostGetHeaderValues(kind int32, name unsafe.Pointer, nameLen uint32, buf unsafe.Pointer, bufLimit uint32) uint64
+
+//go:wasmimport http_handler set_header_value
+func hostSetHeaderValue(kind int32, name unsafe.Pointer, nameLen uint32, value unsafe.Pointer, valueLen uint32)
+
+// abi implements host over the imported functions
+type abi struct{}
+
+// plugin is created when the host initializes the module; an invalid
+// configuration fails Traefik's plugin setup
+var plugin = func() *handler {
+	h, err := newHandler(readConfig())
+	if err != nil {
+		message := "CloudRunTokenMiddleware: " + err.Error()
+		abi{}.log(logLevelError, message)
+		panic(message)
+	}
+	return h
+}()
+
+// handleRequest is called for each request. The high 32 bits of the result
+// are a request context passed back to handle_response, the low bit tells
+// the host to call the next handler.
+//
+//go:wasmexport handle_request
+func handleRequest() uint64 {
+	plugin.handleRequest(abi{})
+	return 1
+}
+
+// handleResponse is called after the next handler; nothing to do
+//
+//go:wasmexport handle_response
+func handleResponse(reqCtx uint32, isError uint32) {}
+
+// readConfig returns the plugin configuration JSON
+func readConfig() []byte {
+	buf := make([]byte, 2048)
+	size := hostGetConfig(unsafe.Pointer(&buf[0]), uint32(len(buf)))
+	if int(size) > len(buf) {
+		buf = make([]byte, size)
+		size = hostGetConfig(unsafe.Pointer(&buf[0]), uint32(len(buf)))
+	}
+	return buf[:size]
+}
+
+// getHeader returns the first value of a header, or ""
+func (abi) getHeader(kind int32, name string) string {
+	nameBuf := []byte(name)
+	buf := make([]byte, 1024)
+	countLen := hostGetHeaderValues(kind, unsafe.Pointer(&nameBuf[0]), uint32(len(nameBuf)), unsafe.Pointer(&buf[0]), uint32(len(buf)))
+	size := uint32(countLen)
+	if countLen>>32 == 0 {
+		return ""
+	}
+	if int(size) > len(buf) {
+		buf = make([]byte, size)
+		hostGetHeaderValues(kind, unsafe.Pointer(&nameBuf[0]), uint32(len(nameBuf)), unsafe.Pointer(&buf[0]), uint32(len(buf)))
+	}
+	// Values are NUL-terminated
+	value, _, _ := strings.Cut(string(buf[:size]), "\x00")
+	return value
+}
+
+// setHeader replaces a header's values
+func (abi) setHeader(kind int32, name, value string) {
+	nameBuf, valueBuf := []byte(name), []byte(value)
+	if len(valueBuf) == 0 {
+		valueBuf = []byte{0} // Pointer must be valid even for an empty value
+		hostSetHeaderValue(kind, unsafe.Pointer(&nameBuf[0]), uint32(len(nameBuf)), unsafe.Pointer(&valueBuf[0]), 0)
+		return
+	}
+	hostSetHeaderValue(kind, unsafe.Pointer(&nameBuf[0]), uint32(len(nameBuf)), unsafe.Pointer(&valueBuf[0]), uint32(len(valueBuf)))
+}
+
+// log writes a message to Traefik's log
+func (abi) log(level int32, message string) {
+	if message == "" {
+		return
+	}
+	buf := []byte(message)
+	hostLog(level, unsafe.Pointer(&buf[0]), uint32(len(buf)))
+}
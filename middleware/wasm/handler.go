@@ -0,0 +1,172 @@
+// Command wasm is the token middleware built for Traefik's Wasm plugin
+// runtime (the http-wasm ABI), so it can be distributed through the plugin
+// catalog without depending on what Yaegi can interpret.
+//
+// It takes the same configuration as the Yaegi plugin in package middleware
+// (audience, headerName, requestIDHeader, tokenCacheDuration), but http-wasm
+// guests have no network access, so the identity token can't be fetched from
+// the metadata server. Instead it is read from tokenDir/<audience host>
+// (default /tokens, mounted into the plugin), which something outside
+// Traefik keeps fresh, e.g. a sidecar fetching tokens from the metadata
+// server. Files are re-read after tokenCacheDuration (default 1m).
+//
+// Build with:
+//
+//	GOOS=wasip1 GOARCH=wasm go build -buildmode=c-shared -o plugin.wasm ./middleware/wasm
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	defaultHeaderName    = "X-Serverless-Authorization"
+	defaultTokenDir      = "/tokens"
+	defaultCacheDuration = time.Minute
+)
+
+// Header kinds defined by the http-wasm ABI
+const (
+	headerKindRequest  = 0
+	headerKindResponse = 1
+)
+
+// logLevelError is the http-wasm ABI's error log level
+const logLevelError = 2
+
+// Config is the plugin configuration Traefik passes as JSON. Durations are
+// strings ("30s") as they appear in Traefik's dynamic configuration.
+type Config struct {
+	Audience           string `json:"audience,omitempty"`
+	HeaderName         string `json:"headerName,omitempty"`
+	TokenCacheDuration string `json:"tokenCacheDuration,omitempty"`
+	RequestIDHeader    string `json:"requestIDHeader,omitempty"`
+	TokenDir           string `json:"tokenDir,omitempty"`
+}
+
+// host is the part of the http-wasm host the handler uses
+type host interface {
+	getHeader(kind int32, name string) string
+	setHeader(kind int32, name, value string)
+	log(level int32, message string)
+}
+
+// handler injects the token and request ID into requests
+type handler struct {
+	headerName    string
+	requestID     string // Request ID header ("" = disabled)
+	tokenFile     string // "" when the plugin only sets request IDs
+	cacheDuration time.Duration
+
+	// Traefik runs one guest instance per concurrent request, so the
+	// cache needs no locking
+	token     string
+	expiresAt time.Time
+	now       func() time.Time
+}
+
+// newHandler validates the configuration
+func newHandler(data []byte) (*handler, error) {
+	var config Config
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &config); err != nil {
+			return nil, fmt.Errorf("failed to parse config: %w", err)
+		}
+	}
+	if config.Audience == "" && config.RequestIDHeader == "" {
+		return nil, errors.New("audience must be specified")
+	}
+
+	h := &handler{
+		headerName:    config.HeaderName,
+		requestID:     config.RequestIDHeader,
+		cacheDuration: defaultCacheDuration,
+		now:           time.Now,
+	}
+	if h.headerName == "" {
+		h.headerName = defaultHeaderName
+	}
+	if config.TokenCacheDuration != "" {
+		d, err := time.ParseDuration(config.TokenCacheDuration)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tokenCacheDuration: %w", err)
+		}
+		h.cacheDuration = d
+	}
+
+	if config.Audience != "" {
+		audience, err := url.Parse(config.Audience)
+		if err != nil || audience.Host == "" {
+			return nil, fmt.Errorf("audience %q is not a URL", config.Audience)
+		}
+		dir := config.TokenDir
+		if dir == "" {
+			dir = defaultTokenDir
+		}
+		h.tokenFile = filepath.Join(dir, audience.Hostname())
+	}
+	return h, nil
+}
+
+// handleRequest sets the request ID and the token header. A missing token is
+// logged and the request continues without it, as in the Yaegi plugin.
+func (h *handler) handleRequest(hst host) {
+	if h.requestID != "" {
+		id := hst.getHeader(headerKindRequest, h.requestID)
+		if id == "" {
+			id = newRequestID()
+			hst.setHeader(headerKindRequest, h.requestID, id)
+		}
+		hst.setHeader(headerKindResponse, h.requestID, id)
+	}
+
+	if h.tokenFile == "" {
+		return
+	}
+	token, err := h.getToken()
+	if err != nil {
+		hst.log(logLevelError, "failed to read identity token: "+err.Error())
+		return
+	}
+	hst.setHeader(headerKindRequest, h.headerName, "Bearer "+token)
+}
+
+// getToken returns the cached token or re-reads the token file
+func (h *handler) getToken() (string, error) {
+	if h.token != "" && h.now().Before(h.expiresAt) {
+		return h.token, nil
+	}
+
+	data, err := os.ReadFile(h.tokenFile)
+	if err != nil {
+		return "", err
+	}
+	token := strings.TrimSpace(string(data))
+	if !strings.HasPrefix(token, "eyJ") {
+		return "", fmt.Errorf("token in %s doesn't look valid (doesn't start with eyJ)", h.tokenFile)
+	}
+
+	h.token = token
+	h.expiresAt = h.now().Add(h.cacheDuration)
+	return token, nil
+}
+
+// newRequestID returns a random 128-bit hex ID
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+func main() {}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeHost records header changes and log messages
+type fakeHost struct {
+	headers map[int32]map[string]string
+	logs    []string
+}
+
+func newFakeHost() *fakeHost {
+	return &fakeHost{headers: map[int32]map[string]string{
+		headerKindRequest:  {},
+		headerKindResponse: {},
+	}}
+}
+
+func (f *fakeHost) getHeader(kind int32, name string) string { return f.headers[kind][name] }
+
+func (f *fakeHost) setHeader(kind int32, name, value string) { f.headers[kind][name] = value }
+
+func (f *fakeHost) log(_ int32, message string) { f.logs = append(f.logs, message) }
+
+func TestNewHandler(t *testing.T) {
+	h, err := newHandler([]byte(`{"audience":"https://lab1-abc.run.app","tokenCacheDuration":"5m"}`))
+	if err != nil {
+		t.Fatalf("newHandler failed: %v", err)
+	}
+	if h.tokenFile != filepath.Join(defaultTokenDir, "lab1-abc.run.app") {
+		t.Errorf("tokenFile = %q", h.tokenFile)
+	}
+	if h.headerName != defaultHeaderName || h.cacheDuration != 5*time.Minute {
+		t.Errorf("Unexpected handler settings: %+v", h)
+	}
+
+	tests := []struct {
+		name, config, want string
+	}{
+		{"no audience", `{}`, "audience must be specified"},
+		{"bad json", `{`, "failed to parse config"},
+		{"bad duration", `{"audience":"https://a.run.app","tokenCacheDuration":"soon"}`, "invalid tokenCacheDuration"},
+		{"audience not a URL", `{"audience":"lab1"}`, "is not a URL"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newHandler([]byte(tt.config))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestHandleRequest_InjectsAndCachesToken(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "lab1.run.app")
+	if err := os.WriteFile(tokenFile, []byte("eyJfirst\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := newHandler([]byte(`{"audience":"https://lab1.run.app","tokenDir":"` + dir + `"}`))
+	if err != nil {
+		t.Fatalf("newHandler failed: %v", err)
+	}
+	now := time.Now()
+	h.now = func() time.Time { return now }
+
+	host := newFakeHost()
+	h.handleRequest(host)
+	if got := host.headers[headerKindRequest][defaultHeaderName]; got != "Bearer eyJfirst" {
+		t.Errorf("Token header = %q", got)
+	}
+
+	// Rotated token is picked up only after the cache expires
+	if err := os.WriteFile(tokenFile, []byte("eyJsecond"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	h.handleRequest(host)
+	if got := host.headers[headerKindRequest][defaultHeaderName]; got != "Bearer eyJfirst" {
+		t.Errorf("Expected cached token, got %q", got)
+	}
+	now = now.Add(defaultCacheDuration)
+	h.handleRequest(host)
+	if got := host.headers[headerKindRequest][defaultHeaderName]; got != "Bearer eyJsecond" {
+		t.Errorf("Expected re-read token, got %q", got)
+	}
+}
+
+func TestHandleRequest_MissingToken(t *testing.T) {
+	h, err := newHandler([]byte(`{"audience":"https://lab1.run.app","tokenDir":"` + t.TempDir() + `"}`))
+	if err != nil {
+		t.Fatalf("newHandler failed: %v", err)
+	}
+
+	host := newFakeHost()
+	h.handleRequest(host)
+	if _, ok := host.headers[headerKindRequest][defaultHeaderName]; ok {
+		t.Error("Expected no token header when the token file is missing")
+	}
+	if len(host.logs) != 1 {
+		t.Errorf("Expected one error log, got %v", host.logs)
+	}
+}
+
+func TestHandleRequest_RequestID(t *testing.T) {
+	h, err := newHandler([]byte(`{"requestIDHeader":"X-Request-ID"}`))
+	if err != nil {
+		t.Fatalf("newHandler failed: %v", err)
+	}
+
+	host := newFakeHost()
+	h.handleRequest(host)
+	id := host.headers[headerKindRequest]["X-Request-ID"]
+	if len(id) != 32 || host.headers[headerKindResponse]["X-Request-ID"] != id {
+		t.Errorf("Expected generated ID on request and response, got %v", host.headers)
+	}
+
+	host = newFakeHost()
+	host.headers[headerKindRequest]["X-Request-ID"] = "abc"
+	h.handleRequest(host)
+	if host.headers[headerKindResponse]["X-Request-ID"] != "abc" {
+		t.Errorf("Expected incoming ID to be kept, got %v", host.headers)
+	}
+}
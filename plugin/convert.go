@@ -0,0 +1,380 @@
+package plugin
+
+import (
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/provider"
+	"github.com/traefik/genconf/dynamic"
+)
+
+// toDynamic converts the provider's configuration model to Traefik's
+// dynamic.Configuration, which plugin providers hand to Traefik.
+//
+// Every provider.MiddlewareConfig type has a dynamic equivalent except
+// headers.forwardedHeaders, which Traefik only supports on entrypoints; it is
+// dropped (the file provider's forwarded-headers middleware covers it).
+// Router observability options are dropped too: genconf's Router predates
+// them.
+func toDynamic(src *provider.DynamicConfig) *dynamic.Configuration {
+	cfg := &dynamic.Configuration{
+		HTTP: &dynamic.HTTPConfiguration{
+			Routers:     make(map[string]*dynamic.Router),
+			Services:    make(map[string]*dynamic.Service),
+			Middlewares: make(map[string]*dynamic.Middleware),
+		},
+	}
+
+	for name, router := range src.HTTP.Routers {
+		dst := &dynamic.Router{
+			Rule:        router.Rule,
+			Service:     router.Service,
+			Priority:    router.Priority,
+			EntryPoints: router.EntryPoints,
+			Middlewares: router.Middlewares,
+		}
+		if router.TLS != nil {
+			dst.TLS = &dynamic.RouterTLSConfig{CertResolver: router.TLS.CertResolver}
+		}
+		cfg.HTTP.Routers[name] = dst
+	}
+
+	for name, service := range src.HTTP.Services {
+		servers := make([]dynamic.Server, len(service.LoadBalancer.Servers))
+		for i, server := range service.LoadBalancer.Servers {
+			servers[i] = dynamic.Server{URL: server.URL}
+		}
+		passHostHeader := service.LoadBalancer.PassHostHeader
+		lb := &dynamic.ServersLoadBalancer{
+			Servers:          servers,
+			PassHostHeader:   &passHostHeader,
+			ServersTransport: service.LoadBalancer.ServersTransport,
+		}
+		if rf := service.LoadBalancer.ResponseForwarding; rf != nil {
+			lb.ResponseForwarding = &dynamic.ResponseForwarding{FlushInterval: rf.FlushInterval}
+		}
+		cfg.HTTP.Services[name] = &dynamic.Service{LoadBalancer: lb}
+	}
+
+	if len(src.HTTP.ServersTransports) > 0 {
+		cfg.HTTP.ServersTransports = make(map[string]*dynamic.ServersTransport)
+		for name, transport := range src.HTTP.ServersTransports {
+			dst := &dynamic.ServersTransport{
+				ServerName:   transport.ServerName,
+				DisableHTTP2: transport.DisableHTTP2,
+			}
+			if ft := transport.ForwardingTimeouts; ft != nil {
+				dst.ForwardingTimeouts = &dynamic.ForwardingTimeouts{
+					DialTimeout:           ft.DialTimeout,
+					ResponseHeaderTimeout: ft.ResponseHeaderTimeout,
+					IdleConnTimeout:       ft.IdleConnTimeout,
+				}
+			}
+			cfg.HTTP.ServersTransports[name] = dst
+		}
+	}
+
+	for name, middleware := range src.HTTP.Middlewares {
+		cfg.HTTP.Middlewares[name] = middlewareToDynamic(middleware)
+	}
+
+	return cfg
+}
+
+// middlewareToDynamic converts one middleware
+func middlewareToDynamic(src provider.MiddlewareConfig) *dynamic.Middleware {
+	mw := &dynamic.Middleware{}
+	if src.Headers != nil {
+		mw.Headers = &dynamic.Headers{
+			CustomRequestHeaders:              src.Headers.CustomRequestHeaders,
+			CustomResponseHeaders:             src.Headers.CustomResponseHeaders,
+			AccessControlAllowCredentials:     src.Headers.AccessControlAllowCredentials,
+			AccessControlAllowHeaders:         src.Headers.AccessControlAllowHeaders,
+			AccessControlAllowMethods:         src.Headers.AccessControlAllowMethods,
+			AccessControlAllowOriginList:      src.Headers.AccessControlAllowOriginList,
+			AccessControlAllowOriginListRegex: src.Headers.AccessControlAllowOriginListRegex,
+			AccessControlExposeHeaders:        src.Headers.AccessControlExposeHeaders,
+			AccessControlMaxAge:               src.Headers.AccessControlMaxAge,
+			AddVaryHeader:                     src.Headers.AddVaryHeader,
+		}
+	}
+	if src.ForwardAuth != nil {
+		mw.ForwardAuth = &dynamic.ForwardAuth{
+			Address:             src.ForwardAuth.Address,
+			TrustForwardHeader:  src.ForwardAuth.TrustForwardHeader,
+			AuthResponseHeaders: src.ForwardAuth.AuthResponseHeaders,
+			AuthRequestHeaders:  src.ForwardAuth.AuthRequestHeaders,
+		}
+	}
+	if src.Chain != nil {
+		mw.Chain = &dynamic.Chain{Middlewares: src.Chain.Middlewares}
+	}
+	if src.IPAllowList != nil {
+		mw.IPAllowList = &dynamic.IPAllowList{SourceRange: src.IPAllowList.SourceRange}
+	}
+	if src.IPWhiteList != nil {
+		mw.IPWhiteList = &dynamic.IPWhiteList{SourceRange: src.IPWhiteList.SourceRange}
+	}
+	if src.BasicAuth != nil {
+		mw.BasicAuth = &dynamic.BasicAuth{Users: dynamic.Users(src.BasicAuth.Users)}
+	}
+	if src.StripPrefix != nil {
+		mw.StripPrefix = &dynamic.StripPrefix{Prefixes: src.StripPrefix.Prefixes, ForceSlash: src.StripPrefix.ForceSlash}
+	}
+	if src.AddPrefix != nil {
+		mw.AddPrefix = &dynamic.AddPrefix{Prefix: src.AddPrefix.Prefix}
+	}
+	if src.ReplacePath != nil {
+		mw.ReplacePath = &dynamic.ReplacePath{Path: src.ReplacePath.Path}
+	}
+	if src.ReplacePathRegex != nil {
+		mw.ReplacePathRegex = &dynamic.ReplacePathRegex{Regex: src.ReplacePathRegex.Regex, Replacement: src.ReplacePathRegex.Replacement}
+	}
+	if src.RedirectScheme != nil {
+		mw.RedirectScheme = &dynamic.RedirectScheme{
+			Scheme:    src.RedirectScheme.Scheme,
+			Port:      src.RedirectScheme.Port,
+			Permanent: src.RedirectScheme.Permanent,
+		}
+	}
+	if src.RedirectRegex != nil {
+		mw.RedirectRegex = &dynamic.RedirectRegex{
+			Regex:       src.RedirectRegex.Regex,
+			Replacement: src.RedirectRegex.Replacement,
+			Permanent:   src.RedirectRegex.Permanent,
+		}
+	}
+	if src.RateLimit != nil {
+		mw.RateLimit = &dynamic.RateLimit{
+			Average: src.RateLimit.Average,
+			Period:  src.RateLimit.Period,
+			Burst:   src.RateLimit.Burst,
+		}
+		if criterion := src.RateLimit.SourceCriterion; criterion != nil {
+			mw.RateLimit.SourceCriterion = &dynamic.SourceCriterion{
+				RequestHeaderName: criterion.RequestHeaderName,
+				RequestHost:       criterion.RequestHost,
+			}
+			if criterion.IPStrategy != nil {
+				mw.RateLimit.SourceCriterion.IPStrategy = &dynamic.IPStrategy{
+					Depth:       criterion.IPStrategy.Depth,
+					ExcludedIPs: criterion.IPStrategy.ExcludedIPs,
+				}
+			}
+		}
+	}
+	if src.Retry != nil {
+		mw.Retry = &dynamic.Retry{Attempts: src.Retry.Attempts, InitialInterval: src.Retry.InitialInterval}
+	}
+	if src.CircuitBreaker != nil {
+		mw.CircuitBreaker = &dynamic.CircuitBreaker{
+			Expression:       src.CircuitBreaker.Expression,
+			CheckPeriod:      src.CircuitBreaker.CheckPeriod,
+			FallbackDuration: src.CircuitBreaker.FallbackDuration,
+			RecoveryDuration: src.CircuitBreaker.RecoveryDuration,
+		}
+	}
+	if src.Compress != nil {
+		mw.Compress = &dynamic.Compress{
+			ExcludedContentTypes: src.Compress.ExcludedContentTypes,
+			MinResponseBodyBytes: src.Compress.MinResponseBodyBytes,
+		}
+	}
+	if src.Buffering != nil {
+		mw.Buffering = &dynamic.Buffering{
+			MaxRequestBodyBytes:  src.Buffering.MaxRequestBodyBytes,
+			MemRequestBodyBytes:  src.Buffering.MemRequestBodyBytes,
+			MaxResponseBodyBytes: src.Buffering.MaxResponseBodyBytes,
+			MemResponseBodyBytes: src.Buffering.MemResponseBodyBytes,
+			RetryExpression:      src.Buffering.RetryExpression,
+		}
+	}
+	if src.Errors != nil {
+		mw.Errors = &dynamic.ErrorPage{Status: src.Errors.Status, Service: src.Errors.Service, Query: src.Errors.Query}
+	}
+	if len(src.Plugin) > 0 {
+		mw.Plugin = make(map[string]dynamic.PluginConf, len(src.Plugin))
+		for pluginName, pluginConf := range src.Plugin {
+			mw.Plugin[pluginName] = dynamic.PluginConf(pluginConf)
+		}
+	}
+	return mw
+}
+
+// fromDynamic converts a dynamic.Configuration back to the provider's model.
+// Settings the model has no field for are dropped.
+func fromDynamic(cfg *dynamic.Configuration) *provider.DynamicConfig {
+	dst := provider.NewDynamicConfig()
+	if cfg == nil || cfg.HTTP == nil {
+		return dst
+	}
+
+	for name, router := range cfg.HTTP.Routers {
+		routerConfig := provider.RouterConfig{
+			Rule:        router.Rule,
+			Service:     router.Service,
+			Priority:    router.Priority,
+			EntryPoints: router.EntryPoints,
+			Middlewares: router.Middlewares,
+		}
+		if router.TLS != nil {
+			routerConfig.TLS = &provider.RouterTLSConfig{CertResolver: router.TLS.CertResolver}
+		}
+		dst.AddRouter(name, routerConfig)
+	}
+
+	for name, service := range cfg.HTTP.Services {
+		if service.LoadBalancer == nil {
+			continue
+		}
+		lb := provider.LoadBalancerConfig{ServersTransport: service.LoadBalancer.ServersTransport}
+		for _, server := range service.LoadBalancer.Servers {
+			lb.Servers = append(lb.Servers, provider.ServerConfig{URL: server.URL})
+		}
+		if service.LoadBalancer.PassHostHeader != nil {
+			lb.PassHostHeader = *service.LoadBalancer.PassHostHeader
+		}
+		if rf := service.LoadBalancer.ResponseForwarding; rf != nil {
+			lb.ResponseForwarding = &provider.ResponseForwardingConfig{FlushInterval: rf.FlushInterval}
+		}
+		dst.AddService(name, provider.ServiceConfig{LoadBalancer: lb})
+	}
+
+	for name, transport := range cfg.HTTP.ServersTransports {
+		transportConfig := provider.ServersTransportConfig{
+			ServerName:   transport.ServerName,
+			DisableHTTP2: transport.DisableHTTP2,
+		}
+		if ft := transport.ForwardingTimeouts; ft != nil {
+			transportConfig.ForwardingTimeouts = &provider.ForwardingTimeoutsConfig{
+				DialTimeout:           ft.DialTimeout,
+				ResponseHeaderTimeout: ft.ResponseHeaderTimeout,
+				IdleConnTimeout:       ft.IdleConnTimeout,
+			}
+		}
+		dst.AddServersTransport(name, transportConfig)
+	}
+
+	for name, middleware := range cfg.HTTP.Middlewares {
+		dst.AddMiddleware(name, middlewareFromDynamic(middleware))
+	}
+
+	return dst
+}
+
+// middlewareFromDynamic converts one middleware back
+func middlewareFromDynamic(src *dynamic.Middleware) provider.MiddlewareConfig {
+	var mw provider.MiddlewareConfig
+	if src.Headers != nil {
+		mw.Headers = &provider.HeadersConfig{
+			CustomRequestHeaders:              src.Headers.CustomRequestHeaders,
+			CustomResponseHeaders:             src.Headers.CustomResponseHeaders,
+			AccessControlAllowCredentials:     src.Headers.AccessControlAllowCredentials,
+			AccessControlAllowHeaders:         src.Headers.AccessControlAllowHeaders,
+			AccessControlAllowMethods:         src.Headers.AccessControlAllowMethods,
+			AccessControlAllowOriginList:      src.Headers.AccessControlAllowOriginList,
+			AccessControlAllowOriginListRegex: src.Headers.AccessControlAllowOriginListRegex,
+			AccessControlExposeHeaders:        src.Headers.AccessControlExposeHeaders,
+			AccessControlMaxAge:               src.Headers.AccessControlMaxAge,
+			AddVaryHeader:                     src.Headers.AddVaryHeader,
+		}
+	}
+	if src.ForwardAuth != nil {
+		mw.ForwardAuth = &provider.ForwardAuthConfig{
+			Address:             src.ForwardAuth.Address,
+			TrustForwardHeader:  src.ForwardAuth.TrustForwardHeader,
+			AuthResponseHeaders: src.ForwardAuth.AuthResponseHeaders,
+			AuthRequestHeaders:  src.ForwardAuth.AuthRequestHeaders,
+		}
+	}
+	if src.Chain != nil {
+		mw.Chain = &provider.ChainConfig{Middlewares: src.Chain.Middlewares}
+	}
+	if src.IPAllowList != nil {
+		mw.IPAllowList = &provider.IPAllowListConfig{SourceRange: src.IPAllowList.SourceRange}
+	}
+	if src.IPWhiteList != nil {
+		mw.IPWhiteList = &provider.IPAllowListConfig{SourceRange: src.IPWhiteList.SourceRange}
+	}
+	if src.BasicAuth != nil {
+		mw.BasicAuth = &provider.BasicAuthConfig{Users: []string(src.BasicAuth.Users)}
+	}
+	if src.StripPrefix != nil {
+		mw.StripPrefix = &provider.StripPrefixConfig{Prefixes: src.StripPrefix.Prefixes, ForceSlash: src.StripPrefix.ForceSlash}
+	}
+	if src.AddPrefix != nil {
+		mw.AddPrefix = &provider.AddPrefixConfig{Prefix: src.AddPrefix.Prefix}
+	}
+	if src.ReplacePath != nil {
+		mw.ReplacePath = &provider.ReplacePathConfig{Path: src.ReplacePath.Path}
+	}
+	if src.ReplacePathRegex != nil {
+		mw.ReplacePathRegex = &provider.ReplacePathRegexConfig{Regex: src.ReplacePathRegex.Regex, Replacement: src.ReplacePathRegex.Replacement}
+	}
+	if src.RedirectScheme != nil {
+		mw.RedirectScheme = &provider.RedirectSchemeConfig{
+			Scheme:    src.RedirectScheme.Scheme,
+			Port:      src.RedirectScheme.Port,
+			Permanent: src.RedirectScheme.Permanent,
+		}
+	}
+	if src.RedirectRegex != nil {
+		mw.RedirectRegex = &provider.RedirectRegexConfig{
+			Regex:       src.RedirectRegex.Regex,
+			Replacement: src.RedirectRegex.Replacement,
+			Permanent:   src.RedirectRegex.Permanent,
+		}
+	}
+	if src.RateLimit != nil {
+		mw.RateLimit = &provider.RateLimitConfig{
+			Average: src.RateLimit.Average,
+			Period:  src.RateLimit.Period,
+			Burst:   src.RateLimit.Burst,
+		}
+		if criterion := src.RateLimit.SourceCriterion; criterion != nil {
+			mw.RateLimit.SourceCriterion = &provider.SourceCriterionConfig{
+				RequestHeaderName: criterion.RequestHeaderName,
+				RequestHost:       criterion.RequestHost,
+			}
+			if criterion.IPStrategy != nil {
+				mw.RateLimit.SourceCriterion.IPStrategy = &provider.IPStrategyConfig{
+					Depth:       criterion.IPStrategy.Depth,
+					ExcludedIPs: criterion.IPStrategy.ExcludedIPs,
+				}
+			}
+		}
+	}
+	if src.Retry != nil {
+		mw.Retry = &provider.RetryConfig{Attempts: src.Retry.Attempts, InitialInterval: src.Retry.InitialInterval}
+	}
+	if src.CircuitBreaker != nil {
+		mw.CircuitBreaker = &provider.CircuitBreakerConfig{
+			Expression:       src.CircuitBreaker.Expression,
+			CheckPeriod:      src.CircuitBreaker.CheckPeriod,
+			FallbackDuration: src.CircuitBreaker.FallbackDuration,
+			RecoveryDuration: src.CircuitBreaker.RecoveryDuration,
+		}
+	}
+	if src.Compress != nil {
+		mw.Compress = &provider.CompressConfig{
+			ExcludedContentTypes: src.Compress.ExcludedContentTypes,
+			MinResponseBodyBytes: src.Compress.MinResponseBodyBytes,
+		}
+	}
+	if src.Buffering != nil {
+		mw.Buffering = &provider.BufferingConfig{
+			MaxRequestBodyBytes:  src.Buffering.MaxRequestBodyBytes,
+			MemRequestBodyBytes:  src.Buffering.MemRequestBodyBytes,
+			MaxResponseBodyBytes: src.Buffering.MaxResponseBodyBytes,
+			MemResponseBodyBytes: src.Buffering.MemResponseBodyBytes,
+			RetryExpression:      src.Buffering.RetryExpression,
+		}
+	}
+	if src.Errors != nil {
+		mw.Errors = &provider.ErrorsConfig{Status: src.Errors.Status, Service: src.Errors.Service, Query: src.Errors.Query}
+	}
+	if len(src.Plugin) > 0 {
+		mw.Plugin = make(map[string]map[string]interface{}, len(src.Plugin))
+		for pluginName, pluginConf := range src.Plugin {
+			mw.Plugin[pluginName] = map[string]interface{}(pluginConf)
+		}
+	}
+	return mw
+}
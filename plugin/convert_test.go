@@ -0,0 +1,127 @@
+package plugin
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/provider"
+)
+
+// middlewareSamples has one middleware per provider.MiddlewareConfig field
+var middlewareSamples = map[string]provider.MiddlewareConfig{
+	"Headers": {Headers: &provider.HeadersConfig{
+		CustomRequestHeaders:         map[string]string{"X-Serverless-Authorization": "Bearer token"},
+		CustomResponseHeaders:        map[string]string{"X-Frame-Options": "DENY", "Server": ""},
+		AccessControlAllowOriginList: []string{"https://app.example.com"},
+		AccessControlAllowMethods:    []string{"GET", "POST"},
+		AccessControlMaxAge:          600,
+	}},
+	"ForwardAuth": {ForwardAuth: &provider.ForwardAuthConfig{
+		Address:             "https://home.run.app/api/auth/check",
+		TrustForwardHeader:  true,
+		AuthResponseHeaders: []string{"X-User-Id"},
+		AuthRequestHeaders:  []string{"Cookie"},
+	}},
+	"Chain":       {Chain: &provider.ChainConfig{Middlewares: []string{"a", "b@file"}}},
+	"IPAllowList": {IPAllowList: &provider.IPAllowListConfig{SourceRange: []string{"10.0.0.0/8"}}},
+	"IPWhiteList": {IPWhiteList: &provider.IPAllowListConfig{SourceRange: []string{"192.168.0.0/16"}}},
+	"BasicAuth":   {BasicAuth: &provider.BasicAuthConfig{Users: []string{"admin:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/"}}},
+	"StripPrefix": {StripPrefix: &provider.StripPrefixConfig{Prefixes: []string{"/api"}}},
+	"AddPrefix":   {AddPrefix: &provider.AddPrefixConfig{Prefix: "/v1"}},
+	"ReplacePath": {ReplacePath: &provider.ReplacePathConfig{Path: "/healthz"}},
+	"ReplacePathRegex": {ReplacePathRegex: &provider.ReplacePathRegexConfig{
+		Regex: "^/old/(.*)", Replacement: "/new/$1",
+	}},
+	"RedirectScheme": {RedirectScheme: &provider.RedirectSchemeConfig{Scheme: "https", Permanent: true}},
+	"RedirectRegex": {RedirectRegex: &provider.RedirectRegexConfig{
+		Regex: "^https://www\\.(.*)", Replacement: "https://$1",
+	}},
+	"RateLimit": {RateLimit: &provider.RateLimitConfig{
+		Average: 100, Period: "1m", Burst: 50,
+		SourceCriterion: &provider.SourceCriterionConfig{IPStrategy: &provider.IPStrategyConfig{Depth: 1}},
+	}},
+	"Retry": {Retry: &provider.RetryConfig{Attempts: 3, InitialInterval: "100ms"}},
+	"CircuitBreaker": {CircuitBreaker: &provider.CircuitBreakerConfig{
+		Expression: "NetworkErrorRatio() > 0.5", FallbackDuration: "10s",
+	}},
+	"Compress":  {Compress: &provider.CompressConfig{ExcludedContentTypes: []string{"text/event-stream"}}},
+	"Buffering": {Buffering: &provider.BufferingConfig{MaxRequestBodyBytes: 10 << 20}},
+	"Errors": {Errors: &provider.ErrorsConfig{
+		Status: []string{"502-503"}, Service: "warmup-page", Query: "/{status}.html",
+	}},
+	"Plugin": {Plugin: map[string]map[string]interface{}{
+		"cloudrun-token": {"audience": "https://svc.run.app"},
+	}},
+}
+
+func TestMiddlewareSamples_CoverModel(t *testing.T) {
+	modelType := reflect.TypeOf(provider.MiddlewareConfig{})
+	for i := 0; i < modelType.NumField(); i++ {
+		if _, ok := middlewareSamples[modelType.Field(i).Name]; !ok {
+			t.Errorf("No conversion sample for MiddlewareConfig.%s, add one to middlewareSamples", modelType.Field(i).Name)
+		}
+	}
+}
+
+func TestToDynamic_ConvertsEveryMiddlewareType(t *testing.T) {
+	for field, sample := range middlewareSamples {
+		src := provider.NewDynamicConfig()
+		src.AddMiddleware("mw", sample)
+
+		data, err := json.Marshal(toDynamic(src).HTTP.Middlewares["mw"])
+		if err != nil {
+			t.Fatalf("%s: %v", field, err)
+		}
+		if string(data) == "{}" {
+			t.Errorf("%s middleware was dropped by toDynamic", field)
+		}
+	}
+}
+
+func TestDynamicConversion_RoundTrip(t *testing.T) {
+	src := provider.NewDynamicConfig()
+	src.AddRouter("app", provider.RouterConfig{
+		Rule:        "Host(`app.example.com`)",
+		Service:     "app",
+		Priority:    200,
+		EntryPoints: []string{"web"},
+		Middlewares: []string{"app-auth", "retry-cold-start@file"},
+	})
+	src.AddRouter("app-secure", provider.RouterConfig{
+		Rule:        "Host(`app.example.com`)",
+		Service:     "app",
+		EntryPoints: []string{"websecure"},
+		Middlewares: []string{"app-auth"},
+		TLS:         &provider.RouterTLSConfig{CertResolver: "letsencrypt"},
+	})
+	src.AddService("app", provider.ServiceConfig{LoadBalancer: provider.LoadBalancerConfig{
+		Servers:            []provider.ServerConfig{{URL: "https://app.run.app"}},
+		ServersTransport:   "app-grpc",
+		ResponseForwarding: &provider.ResponseForwardingConfig{FlushInterval: "1ms"},
+	}})
+	src.AddServersTransport("app-grpc", provider.ServersTransportConfig{ServerName: "app.run.app"})
+	src.AddServersTransport("app-cold-start", provider.ServersTransportConfig{
+		ForwardingTimeouts: &provider.ForwardingTimeoutsConfig{ResponseHeaderTimeout: "1m0s"},
+	})
+	for field, sample := range middlewareSamples {
+		src.AddMiddleware(field, sample)
+	}
+
+	got := fromDynamic(toDynamic(src))
+	if !reflect.DeepEqual(got.HTTP, src.HTTP) {
+		t.Errorf("Round trip changed the configuration:\nwant %+v\ngot  %+v", src.HTTP, got.HTTP)
+	}
+}
+
+func TestToDynamic_DropsForwardedHeaders(t *testing.T) {
+	src := provider.NewDynamicConfig()
+	src.AddMiddleware("forwarded", provider.MiddlewareConfig{Headers: &provider.HeadersConfig{
+		ForwardedHeaders: &provider.ForwardedHeadersConfig{TrustedIPs: []string{"35.191.0.0/16"}},
+	}})
+
+	mw := toDynamic(src).HTTP.Middlewares["forwarded"]
+	if mw.Headers == nil || len(mw.Headers.CustomRequestHeaders) != 0 {
+		t.Errorf("Expected an empty headers middleware, got %+v", mw.Headers)
+	}
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/pci-tamper-protect/traefik-cloudrun-provider/internal/gcp"
 	"github.com/pci-tamper-protect/traefik-cloudrun-provider/internal/logging"
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/internal/redact"
 	"github.com/pci-tamper-protect/traefik-cloudrun-provider/provider"
 	"github.com/traefik/genconf/dynamic"
 	run "google.golang.org/api/run/v1"
@@ -22,8 +23,164 @@ type Config struct {
 	Region       string        `json:"region,omitempty" yaml:"region,omitempty"`
 	PollInterval time.Duration `json:"pollInterval,omitempty" yaml:"pollInterval,omitempty"`
 
+	// Cloud Run for Anthos namespaces discovered alongside projectIDs
+	AnthosTargets []provider.AnthosTarget `json:"anthosTargets,omitempty" yaml:"anthosTargets,omitempty"`
+
+	// Named auth providers (static bearer tokens, OIDC client credentials)
+	// selected with the traefik_auth_provider label
+	AuthProviders []provider.AuthProviderConfig `json:"authProviders,omitempty" yaml:"authProviders,omitempty"`
+
+	// Explicit credentials for listing services and minting tokens, so the
+	// plugin can use a least-privilege service account instead of Traefik's
+	// runtime identity. Set at most one.
+	CredentialsFile string `json:"credentialsFile,omitempty" yaml:"credentialsFile,omitempty"`
+	CredentialsJSON string `json:"credentialsJSON,omitempty" yaml:"credentialsJSON,omitempty"`
+
+	// Cloud Run Admin API endpoint override (regional endpoint or emulator)
+	RunAPIEndpoint string `json:"runAPIEndpoint,omitempty" yaml:"runAPIEndpoint,omitempty"`
+
 	// Token cache settings
 	TokenRefreshBefore time.Duration `json:"tokenRefreshBefore,omitempty" yaml:"tokenRefreshBefore,omitempty"`
+
+	// Token injection: "static" (default) or "plugin" (per-request token middleware plugin)
+	TokenInjection  string `json:"tokenInjection,omitempty" yaml:"tokenInjection,omitempty"`
+	TokenPluginName string `json:"tokenPluginName,omitempty" yaml:"tokenPluginName,omitempty"`
+
+	// One auth-<hash> middleware per backend URL instead of one per service
+	ShareAuthMiddlewares bool `json:"shareAuthMiddlewares,omitempty" yaml:"shareAuthMiddlewares,omitempty"`
+
+	// What to do when a service's token can't be fetched: "emit-without-auth" (default), "skip-route" or "fail-generation"
+	TokenFailurePolicy string `json:"tokenFailurePolicy,omitempty" yaml:"tokenFailurePolicy,omitempty"`
+
+	// Services allowed traefik_auth_type=access_token (glob patterns on service
+	// names or <project>/<service>) and the scopes their tokens are requested
+	// for (default userinfo.email)
+	AccessTokenServices []string `json:"accessTokenServices,omitempty" yaml:"accessTokenServices,omitempty"`
+	AccessTokenScopes   []string `json:"accessTokenScopes,omitempty" yaml:"accessTokenScopes,omitempty"`
+
+	// What to do with unrecognized traefik_* labels: "ignore" (default), "warn" or "strict" (skip the service)
+	LabelValidation string `json:"labelValidation,omitempty" yaml:"labelValidation,omitempty"`
+
+	// User auth settings. Unset values fall back to USER_AUTH_ENABLED, SKIP_AUTH_CHECK and HOME_INDEX_URL.
+	UserAuthEnabled         *bool    `json:"userAuthEnabled,omitempty" yaml:"userAuthEnabled,omitempty"`
+	SkipAuthCheck           *bool    `json:"skipAuthCheck,omitempty" yaml:"skipAuthCheck,omitempty"` // Deprecated: use userAuthEnabled=false
+	HomeIndexURL            string   `json:"homeIndexURL,omitempty" yaml:"homeIndexURL,omitempty"`
+	UserAuthMiddlewares     []string `json:"userAuthMiddlewares,omitempty" yaml:"userAuthMiddlewares,omitempty"`
+	UserAuthCheckBaseURL    string   `json:"userAuthCheckBaseURL,omitempty" yaml:"userAuthCheckBaseURL,omitempty"`
+	UserAuthCheckPath       string   `json:"userAuthCheckPath,omitempty" yaml:"userAuthCheckPath,omitempty"`
+	UserAuthResponseHeaders []string `json:"userAuthResponseHeaders,omitempty" yaml:"userAuthResponseHeaders,omitempty"`
+	UserAuthRequestHeaders  []string `json:"userAuthRequestHeaders,omitempty" yaml:"userAuthRequestHeaders,omitempty"`
+
+	// Middlewares appended to every generated router (default: retry-cold-start@file)
+	DefaultMiddlewares []string `json:"defaultMiddlewares,omitempty" yaml:"defaultMiddlewares,omitempty"`
+
+	// Cold-start retry middlewares (default retry-cold-start@file) and response
+	// header timeout (default 60s) only for services that scale to zero
+	ColdStartByScale     bool          `json:"coldStartByScale,omitempty" yaml:"coldStartByScale,omitempty"`
+	ColdStartMiddlewares []string      `json:"coldStartMiddlewares,omitempty" yaml:"coldStartMiddlewares,omitempty"`
+	ColdStartTimeout     time.Duration `json:"coldStartTimeout,omitempty" yaml:"coldStartTimeout,omitempty"`
+	RequestTimeouts      bool          `json:"requestTimeouts,omitempty" yaml:"requestTimeouts,omitempty"`
+
+	// Prefix for generated router, service and middleware names (e.g. "cloudrun-")
+	NamePrefix string `json:"namePrefix,omitempty" yaml:"namePrefix,omitempty"`
+
+	// Environment (e.g. "qa") and the environment suffixes stripped from
+	// service names in router conflicts; entries ending in "-" are prefixes.
+	// Default: -stg, -prd, -dev, -staging, -production plus "-<environment>"
+	Environment         string   `json:"environment,omitempty" yaml:"environment,omitempty"`
+	EnvironmentSuffixes []string `json:"environmentSuffixes,omitempty" yaml:"environmentSuffixes,omitempty"`
+
+	// Which service keeps a router defined by several services: "dedicated-wins"
+	// (default), "first-wins", "last-wins", "highest-priority-wins" or "error"
+	RouterConflictPolicy string `json:"routerConflictPolicy,omitempty" yaml:"routerConflictPolicy,omitempty"`
+
+	// Services whose Ready condition isn't True: "ignore" (default), "skip" or
+	// "failover" (keep routing while an older revision still serves)
+	ReadinessPolicy string `json:"readinessPolicy,omitempty" yaml:"readinessPolicy,omitempty"`
+
+	// Services serving 0% of traffic: "route" (default), "drop" or
+	// "deprioritize" (lowest router priority)
+	ZeroTrafficPolicy string `json:"zeroTrafficPolicy,omitempty" yaml:"zeroTrafficPolicy,omitempty"`
+
+	// Routers sharing an entry point and a priority with overlapping rules:
+	// "warn" (default) or "bump" (raise priorities, most specific rule first)
+	PriorityTiePolicy string `json:"priorityTiePolicy,omitempty" yaml:"priorityTiePolicy,omitempty"`
+
+	// Services in maintenance mode (glob patterns on Cloud Run service names,
+	// or label traefik_maintenance=true) and the service their routers point
+	// at instead (default: a service answering 503)
+	Maintenance        []string `json:"maintenance,omitempty" yaml:"maintenance,omitempty"`
+	MaintenanceService string   `json:"maintenanceService,omitempty" yaml:"maintenanceService,omitempty"`
+
+	// Services allowed to define routers matching every request, like
+	// PathPrefix(`/`) (glob patterns, default home-index*); others need the
+	// traefik_allow_catch_all=true label
+	CatchAllServices []string `json:"catchAllServices,omitempty" yaml:"catchAllServices,omitempty"`
+
+	// Guardrails on the rules services may generate: routers whose rule a
+	// policy covering the service's project and name rejects are dropped
+	RulePolicies []provider.RulePolicy `json:"rulePolicies,omitempty" yaml:"rulePolicies,omitempty"`
+
+	// Traefik version rules and middleware names are written for: "v2" (default) or "v3"
+	TraefikVersion string `json:"traefikVersion,omitempty" yaml:"traefikVersion,omitempty"`
+
+	// Labels applied to every service of a project unless the service sets
+	// them (keyed by project ID), and the name of the per-project defaults
+	// service whose labels are defaults too (default "defaults")
+	ProjectDefaults map[string]map[string]string `json:"projectDefaults,omitempty" yaml:"projectDefaults,omitempty"`
+	DefaultsService string                       `json:"defaultsService,omitempty" yaml:"defaultsService,omitempty"`
+
+	// Entrypoints and TLS by rule type for routers without an entrypoints
+	// label: websecure with TLS for Host rules, web for Path-only rules,
+	// overridable per rule type ("host", "path") with entryPointRules
+	AutoEntryPoints bool                               `json:"autoEntryPoints,omitempty" yaml:"autoEntryPoints,omitempty"`
+	EntryPointRules map[string]provider.EntryPointRule `json:"entryPointRules,omitempty" yaml:"entryPointRules,omitempty"`
+
+	// Traefik API and dashboard routers (api@internal), off unless enabled
+	Dashboard provider.DashboardConfig `json:"dashboard,omitempty" yaml:"dashboard,omitempty"`
+
+	// Route tagging: set routeTagHeader (default X-Route-Name) to the router name on every generated router
+	RouteTagging   bool   `json:"routeTagging,omitempty" yaml:"routeTagging,omitempty"`
+	RouteTagHeader string `json:"routeTagHeader,omitempty" yaml:"routeTagHeader,omitempty"`
+
+	// Request IDs: set requestIDHeader (default X-Request-ID) when absent, via the token middleware plugin
+	RequestIDEnabled bool   `json:"requestIDEnabled,omitempty" yaml:"requestIDEnabled,omitempty"`
+	RequestIDHeader  string `json:"requestIDHeader,omitempty" yaml:"requestIDHeader,omitempty"`
+
+	// API quota and incremental update settings
+	ListCacheTTL          time.Duration `json:"listCacheTTL,omitempty" yaml:"listCacheTTL,omitempty"`
+	ScanJitter            time.Duration `json:"scanJitter,omitempty" yaml:"scanJitter,omitempty"`
+	StaleRouteGracePeriod time.Duration `json:"staleRouteGracePeriod,omitempty" yaml:"staleRouteGracePeriod,omitempty"`
+	RouteDeletionDelay    int           `json:"routeDeletionDelay,omitempty" yaml:"routeDeletionDelay,omitempty"`
+	MaxRouterRemoval      int           `json:"maxRouterRemoval,omitempty" yaml:"maxRouterRemoval,omitempty"`
+	AllowRouterRemoval    bool          `json:"allowRouterRemoval,omitempty" yaml:"allowRouterRemoval,omitempty"`
+	FailOnDeprecated      bool          `json:"failOnDeprecated,omitempty" yaml:"failOnDeprecated,omitempty"`
+	ProjectRequestBudget  int           `json:"projectRequestBudget,omitempty" yaml:"projectRequestBudget,omitempty"`
+	IncrementalUpdates    bool          `json:"incrementalUpdates,omitempty" yaml:"incrementalUpdates,omitempty"`
+	FragmentMaxAge        time.Duration `json:"fragmentMaxAge,omitempty" yaml:"fragmentMaxAge,omitempty"`
+
+	// Error budget: skip a project/service for breakerCooldown after breakerThreshold consecutive failures
+	BreakerThreshold int           `json:"breakerThreshold,omitempty" yaml:"breakerThreshold,omitempty"`
+	BreakerCooldown  time.Duration `json:"breakerCooldown,omitempty" yaml:"breakerCooldown,omitempty"`
+
+	// Backend self-test: probe each generated backend with its token after generation
+	SelfTest            bool          `json:"selfTest,omitempty" yaml:"selfTest,omitempty"`
+	SelfTestConcurrency int           `json:"selfTestConcurrency,omitempty" yaml:"selfTestConcurrency,omitempty"`
+	SelfTestTimeout     time.Duration `json:"selfTestTimeout,omitempty" yaml:"selfTestTimeout,omitempty"`
+
+	// Backend DNS check: warn about backends whose host doesn't resolve before emitting
+	DNSCheck        bool          `json:"dnsCheck,omitempty" yaml:"dnsCheck,omitempty"`
+	DNSCheckTimeout time.Duration `json:"dnsCheckTimeout,omitempty" yaml:"dnsCheckTimeout,omitempty"`
+
+	// Logging. Unset values fall back to LOG_LEVEL and LOG_FORMAT.
+	LogLevel  string `json:"logLevel,omitempty" yaml:"logLevel,omitempty"`
+	LogFormat string `json:"logFormat,omitempty" yaml:"logFormat,omitempty"`
+
+	// Redaction of credentials and email addresses in logs: extra credential
+	// headers, JWT claims kept readable, email mode (mask, hide or keep)
+	RedactHeaders []string `json:"redactHeaders,omitempty" yaml:"redactHeaders,omitempty"`
+	RedactClaims  []string `json:"redactClaims,omitempty" yaml:"redactClaims,omitempty"`
+	RedactEmails  string   `json:"redactEmails,omitempty" yaml:"redactEmails,omitempty"`
 }
 
 // CreateConfig creates the default plugin configuration
@@ -54,6 +211,9 @@ type PluginProvider struct {
 	tokenManager *gcp.TokenManager
 	logger       *logging.Logger
 	stopChan     chan struct{}
+
+	// internalProvider is created on the first update and reused afterwards
+	internalProvider *provider.Provider
 }
 
 // New creates a new plugin provider
@@ -90,25 +250,39 @@ func New(ctx context.Context, config *Config, name string) (*PluginProvider, err
 		}
 	}
 
+	// Logging settings fall back to the environment
+	if config.LogLevel == "" {
+		config.LogLevel = os.Getenv("LOG_LEVEL")
+	}
+	if config.LogFormat == "" {
+		config.LogFormat = os.Getenv("LOG_FORMAT")
+	}
+
 	// Setup logger
 	logLevel := logging.LevelInfo
-	if level := os.Getenv("LOG_LEVEL"); level != "" {
+	if level := config.LogLevel; level != "" {
 		if parsed, err := logging.ParseLevel(level); err == nil {
 			logLevel = parsed
 		}
 	}
 
 	logFormat := logging.FormatText
-	if format := os.Getenv("LOG_FORMAT"); format != "" {
+	if format := config.LogFormat; format != "" {
 		if parsed, err := logging.ParseFormat(format); err == nil {
 			logFormat = parsed
 		}
 	}
 
+	redactor, err := redact.New(redact.Rules{Headers: config.RedactHeaders, Claims: config.RedactClaims, Emails: config.RedactEmails})
+	if err != nil {
+		return nil, err
+	}
+
 	logger := logging.New(&logging.Config{
 		Level:  logLevel,
 		Format: logFormat,
 		Output: os.Stdout,
+		Redact: redactor.String,
 	}).WithPrefix("CloudRunPlugin")
 
 	logger.Info("Plugin instantiated by Traefik - New() called",
@@ -119,9 +293,18 @@ func New(ctx context.Context, config *Config, name string) (*PluginProvider, err
 		logging.Duration("pollInterval", config.PollInterval),
 	)
 
+	credentials, err := gcp.LoadCredentials(config.CredentialsFile, config.CredentialsJSON)
+	if err != nil {
+		logger.Error("Failed to load credentials",
+			logging.GetCodeField(logging.CodeNewCloudRunClientError),
+			logging.Error(err),
+		)
+		return nil, fmt.Errorf("failed to load credentials: %w", err)
+	}
+
 	// Initialize Cloud Run client
 	logger.Info("Initializing Cloud Run API client...")
-	runService, err := run.NewService(ctx)
+	runService, err := run.NewService(ctx, gcp.APIClientOptions(config.RunAPIEndpoint, credentials)...)
 	if err != nil {
 		logger.Error("Failed to create Cloud Run service",
 			logging.GetCodeField(logging.CodeNewCloudRunClientError),
@@ -135,12 +318,17 @@ func New(ctx context.Context, config *Config, name string) (*PluginProvider, err
 	)
 
 	logger.Info("Initializing token manager...")
-	tokenManager := gcp.NewTokenManager()
-	if tokenManager.IsDevMode() {
+	tokenManager := gcp.NewTokenManagerWithCredentials(credentials)
+	if credentials != nil {
+		logger.Info("Token manager initialized (explicit credentials)")
+	} else if tokenManager.IsDevMode() {
 		logger.Warn("Running in development mode - will use ADC for tokens if metadata server unavailable")
 	} else {
 		logger.Info("Token manager initialized (production mode - using metadata server)")
 	}
+	logger.Info("Identity token credential path selected",
+		logging.String("credentialPath", tokenManager.CredentialPath()),
+	)
 
 	logger.Info("Plugin provider created successfully",
 		logging.GetCodeField(logging.CodeNewSuccess),
@@ -255,40 +443,27 @@ func (p *PluginProvider) updateConfig(cfgChan chan<- json.Marshaler) error {
 	startTime := time.Now()
 	p.logger.Info("Starting configuration update cycle...",
 		logging.GetCodeField(logging.CodeConfigGenerationStarted),
-		logging.String("timestamp", startTime.Format(time.RFC3339)),
+		logging.Time("timestamp", startTime),
 	)
 
-	// Create internal provider to reuse existing logic
-	p.logger.Debug("Creating internal provider instance...")
-	providerConfig := &provider.Config{
-		ProjectIDs:   p.config.ProjectIDs,
-		Region:       p.config.Region,
-		PollInterval: p.config.PollInterval,
-	}
-
-	internalProvider, err := provider.New(providerConfig)
+	// Reuse one internal provider across polls so its list cache, fragment
+	// cache and error budgets persist between cycles
+	internalProvider, err := p.getInternalProvider()
 	if err != nil {
-		p.logger.Error("Failed to create internal provider",
-			logging.GetCodeField(logging.CodeInternalProviderError),
-			logging.Error(err),
-		)
-		return fmt.Errorf("failed to create internal provider: %w", err)
+		return err
 	}
-	p.logger.Info("Internal provider created",
-		logging.GetCodeField(logging.CodeInternalProviderCreated),
-	)
 
 	// Generate configuration using internal provider
-	p.logger.Debug("Starting internal provider to discover services...")
+	p.logger.Debug("Running internal provider discovery cycle...")
 	internalConfigChan := make(chan *provider.DynamicConfig, 1)
-	if err := internalProvider.Start(internalConfigChan); err != nil {
-		p.logger.Error("Failed to start internal provider",
+	if err := internalProvider.RunOnce(internalConfigChan); err != nil {
+		p.logger.Error("Internal provider discovery failed",
 			logging.GetCodeField(logging.CodeInternalProviderError),
 			logging.Error(err),
 		)
-		return fmt.Errorf("failed to start internal provider: %w", err)
+		return fmt.Errorf("internal provider discovery failed: %w", err)
 	}
-	p.logger.Info("Internal provider started, waiting for configuration...",
+	p.logger.Info("Internal provider discovery complete, waiting for configuration...",
 		logging.GetCodeField(logging.CodeInternalProviderStarted),
 	)
 
@@ -320,28 +495,136 @@ func (p *PluginProvider) updateConfig(cfgChan chan<- json.Marshaler) error {
 			logging.GetCodeField(logging.CodeConfigSentSuccess),
 		)
 
-		// Stop internal provider
-		if err := internalProvider.Stop(); err != nil {
-			p.logger.Warn("Failed to stop internal provider", logging.Error(err))
-		}
-		p.logger.Debug("Internal provider stopped")
-
 	case <-time.After(60 * time.Second):
 		p.logger.Error("Timeout waiting for configuration from internal provider (60s)",
 			logging.GetCodeField(logging.CodeConfigGenerationError),
 		)
-		if err := internalProvider.Stop(); err != nil {
-			p.logger.Warn("Failed to stop internal provider", logging.Error(err))
-		}
 		return fmt.Errorf("timeout waiting for configuration")
 	}
 
+	// Surface projects/services that are being skipped after repeated failures
+	for _, status := range internalProvider.BreakerStatus() {
+		if status.Open {
+			p.logger.Warn("Skipping after repeated failures",
+				logging.GetCodeField(logging.CodeBreakerSkipped),
+				logging.String("key", status.Key),
+				logging.Int("consecutiveFailures", status.ConsecutiveFailures),
+				logging.Time("openUntil", status.OpenUntil),
+				logging.String("lastError", status.LastError),
+			)
+		}
+	}
+
 	p.logger.Info("updateConfig() completed successfully",
 		logging.GetCodeField(logging.CodeConfigGenerationSuccess),
 	)
 	return nil
 }
 
+// getInternalProvider returns the internal provider, creating it on first use
+func (p *PluginProvider) getInternalProvider() (*provider.Provider, error) {
+	if p.internalProvider != nil {
+		return p.internalProvider, nil
+	}
+
+	p.logger.Debug("Creating internal provider instance...")
+	internalProvider, err := provider.New(p.providerConfig())
+	if err != nil {
+		p.logger.Error("Failed to create internal provider",
+			logging.GetCodeField(logging.CodeInternalProviderError),
+			logging.Error(err),
+		)
+		return nil, fmt.Errorf("failed to create internal provider: %w", err)
+	}
+	p.logger.Info("Internal provider created",
+		logging.GetCodeField(logging.CodeInternalProviderCreated),
+	)
+
+	p.internalProvider = internalProvider
+	return internalProvider, nil
+}
+
+// providerConfig maps the plugin configuration onto the internal provider's configuration.
+// Settings left unset here fall back to environment variables inside the provider.
+func (p *PluginProvider) providerConfig() *provider.Config {
+	return &provider.Config{
+		ProjectIDs:            p.config.ProjectIDs,
+		Region:                p.config.Region,
+		PollInterval:          p.config.PollInterval,
+		AnthosTargets:         p.config.AnthosTargets,
+		AuthProviders:         p.config.AuthProviders,
+		CredentialsFile:       p.config.CredentialsFile,
+		CredentialsJSON:       p.config.CredentialsJSON,
+		RunAPIEndpoint:        p.config.RunAPIEndpoint,
+		TokenRefreshBefore:    p.config.TokenRefreshBefore,
+		TokenInjection:        p.config.TokenInjection,
+		TokenPluginName:       p.config.TokenPluginName,
+		ShareAuthMiddlewares:  p.config.ShareAuthMiddlewares,
+		TokenFailurePolicy:    p.config.TokenFailurePolicy,
+		AccessTokenServices:   p.config.AccessTokenServices,
+		AccessTokenScopes:     p.config.AccessTokenScopes,
+		LabelValidation:       p.config.LabelValidation,
+		UserAuthEnabled:       p.config.UserAuthEnabled,
+		SkipAuthCheck:         p.config.SkipAuthCheck,
+		HomeIndexURL:          p.config.HomeIndexURL,
+		DefaultMiddlewares:    p.config.DefaultMiddlewares,
+		ColdStartByScale:      p.config.ColdStartByScale,
+		ColdStartMiddlewares:  p.config.ColdStartMiddlewares,
+		ColdStartTimeout:      p.config.ColdStartTimeout,
+		RequestTimeouts:       p.config.RequestTimeouts,
+		NamePrefix:            p.config.NamePrefix,
+		Environment:           p.config.Environment,
+		EnvironmentSuffixes:   p.config.EnvironmentSuffixes,
+		RouterConflictPolicy:  p.config.RouterConflictPolicy,
+		ReadinessPolicy:       p.config.ReadinessPolicy,
+		ZeroTrafficPolicy:     p.config.ZeroTrafficPolicy,
+		PriorityTiePolicy:     p.config.PriorityTiePolicy,
+		Maintenance:           p.config.Maintenance,
+		MaintenanceService:    p.config.MaintenanceService,
+		CatchAllServices:      p.config.CatchAllServices,
+		RulePolicies:          p.config.RulePolicies,
+		TraefikVersion:        p.config.TraefikVersion,
+		ProjectDefaults:       p.config.ProjectDefaults,
+		DefaultsService:       p.config.DefaultsService,
+		AutoEntryPoints:       p.config.AutoEntryPoints,
+		EntryPointRules:       p.config.EntryPointRules,
+		Dashboard:             p.config.Dashboard,
+		RouteTagging:          p.config.RouteTagging,
+		RouteTagHeader:        p.config.RouteTagHeader,
+		RequestIDEnabled:      p.config.RequestIDEnabled,
+		RequestIDHeader:       p.config.RequestIDHeader,
+		ListCacheTTL:          p.config.ListCacheTTL,
+		ScanJitter:            p.config.ScanJitter,
+		StaleRouteGracePeriod: p.config.StaleRouteGracePeriod,
+		RouteDeletionDelay:    p.config.RouteDeletionDelay,
+		MaxRouterRemoval:      p.config.MaxRouterRemoval,
+		AllowRouterRemoval:    p.config.AllowRouterRemoval,
+		FailOnDeprecated:      p.config.FailOnDeprecated,
+		ProjectRequestBudget:  p.config.ProjectRequestBudget,
+		IncrementalUpdates:    p.config.IncrementalUpdates,
+		FragmentMaxAge:        p.config.FragmentMaxAge,
+		BreakerThreshold:      p.config.BreakerThreshold,
+		BreakerCooldown:       p.config.BreakerCooldown,
+		SelfTest:              p.config.SelfTest,
+		SelfTestConcurrency:   p.config.SelfTestConcurrency,
+		SelfTestTimeout:       p.config.SelfTestTimeout,
+		DNSCheck:              p.config.DNSCheck,
+		DNSCheckTimeout:       p.config.DNSCheckTimeout,
+		LogLevel:              p.config.LogLevel,
+		LogFormat:             p.config.LogFormat,
+		RedactHeaders:         p.config.RedactHeaders,
+		RedactClaims:          p.config.RedactClaims,
+		RedactEmails:          p.config.RedactEmails,
+		UserAuth: provider.UserAuthConfig{
+			MiddlewareNames:     p.config.UserAuthMiddlewares,
+			CheckBaseURL:        p.config.UserAuthCheckBaseURL,
+			CheckPath:           p.config.UserAuthCheckPath,
+			AuthResponseHeaders: p.config.UserAuthResponseHeaders,
+			AuthRequestHeaders:  p.config.UserAuthRequestHeaders,
+		},
+	}
+}
+
 // configWrapper wraps dynamic.Configuration to implement json.Marshaler
 type configWrapper struct {
 	*dynamic.Configuration
@@ -354,89 +637,32 @@ func (c *configWrapper) MarshalJSON() ([]byte, error) {
 
 // convertToTraefikConfig converts our DynamicConfig to Traefik's dynamic.Configuration
 func (p *PluginProvider) convertToTraefikConfig(src *provider.DynamicConfig) json.Marshaler {
-	cfg := &dynamic.Configuration{
-		HTTP: &dynamic.HTTPConfiguration{
-			Routers:     make(map[string]*dynamic.Router),
-			Services:    make(map[string]*dynamic.Service),
-			Middlewares: make(map[string]*dynamic.Middleware),
-		},
-	}
-
-	// Convert routers
-	for name, router := range src.HTTP.Routers {
-		cfg.HTTP.Routers[name] = &dynamic.Router{
-			Rule:        router.Rule,
-			Service:     router.Service,
-			Priority:    router.Priority,
-			EntryPoints: router.EntryPoints,
-			Middlewares: router.Middlewares,
-		}
-	}
-
-	// Convert services
-	for name, service := range src.HTTP.Services {
-		servers := make([]dynamic.Server, len(service.LoadBalancer.Servers))
-		for i, server := range service.LoadBalancer.Servers {
-			servers[i] = dynamic.Server{
-				URL: server.URL,
-			}
-		}
-
-		cfg.HTTP.Services[name] = &dynamic.Service{
-			LoadBalancer: &dynamic.ServersLoadBalancer{
-				Servers:        servers,
-				PassHostHeader: &service.LoadBalancer.PassHostHeader,
-			},
-		}
-	}
-
-	// Convert middlewares
 	p.logger.Debug("Converting middlewares to Traefik format",
 		logging.Int("count", len(src.HTTP.Middlewares)),
 	)
 	for name, middleware := range src.HTTP.Middlewares {
-		traefikMw := &dynamic.Middleware{}
-
-		// Convert headers middleware (custom request headers)
-		// Note: Forwarded headers are configured at entrypoint level in Traefik, not as middleware
-		// The forwarded-headers middleware in routes.yml is for the file provider
-		if middleware.Headers != nil {
-			traefikMw.Headers = &dynamic.Headers{
-				CustomRequestHeaders: middleware.Headers.CustomRequestHeaders,
-			}
-			// Note: ForwardedHeaders in our config is for YAML serialization only
-			// Traefik's dynamic.Headers doesn't have a ForwardedHeaders field
-			// Forwarded headers should be configured at entrypoint level or via file provider
-		}
-
-		cfg.HTTP.Middlewares[name] = traefikMw
-
 		// Log auth middlewares specifically to help debug
-		if middleware.Headers != nil && len(middleware.Headers.CustomRequestHeaders) > 0 {
-			hasAuth := false
+		if middleware.Headers != nil {
 			for headerName := range middleware.Headers.CustomRequestHeaders {
 				if headerName == "X-Serverless-Authorization" || headerName == "Authorization" {
-					hasAuth = true
+					p.logger.Info("✅ Auth middleware converted",
+						logging.String("name", name),
+						logging.Int("headerCount", len(middleware.Headers.CustomRequestHeaders)),
+					)
 					break
 				}
 			}
-			if hasAuth {
-				p.logger.Info("✅ Auth middleware converted",
-					logging.String("name", name),
-					logging.Int("headerCount", len(middleware.Headers.CustomRequestHeaders)),
-				)
-			}
 		}
 
-		// Log forwarded headers middleware (if configured)
+		// Forwarded headers can't be expressed as a middleware (see toDynamic)
 		if middleware.Headers != nil && middleware.Headers.ForwardedHeaders != nil {
-			p.logger.Debug("✅ Forwarded headers middleware converted",
+			p.logger.Debug("Forwarded headers not converted, configure them on the entrypoint",
 				logging.String("name", name),
-				logging.String("insecure", fmt.Sprintf("%v", middleware.Headers.ForwardedHeaders.Insecure)),
+				logging.Bool("insecure", middleware.Headers.ForwardedHeaders.Insecure),
 				logging.Int("trustedIPsCount", len(middleware.Headers.ForwardedHeaders.TrustedIPs)),
 			)
 		}
 	}
 
-	return &configWrapper{Configuration: cfg}
+	return &configWrapper{Configuration: toDynamic(src)}
 }
@@ -0,0 +1,43 @@
+package plugin
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestProviderConfig_MapsPluginOptions(t *testing.T) {
+	enabled := true
+	p := &PluginProvider{
+		config: &Config{
+			ProjectIDs:          []string{"proj"},
+			Region:              "europe-west1",
+			PollInterval:        10 * time.Second,
+			UserAuthEnabled:     &enabled,
+			HomeIndexURL:        "https://home.run.app",
+			UserAuthMiddlewares: []string{"portal-auth-check"},
+			UserAuthCheckPath:   "/auth",
+			DefaultMiddlewares:  []string{"compress@file"},
+			TokenInjection:      "plugin",
+			LogLevel:            "DEBUG",
+		},
+	}
+
+	cfg := p.providerConfig()
+
+	if !reflect.DeepEqual(cfg.ProjectIDs, []string{"proj"}) || cfg.Region != "europe-west1" || cfg.PollInterval != 10*time.Second {
+		t.Errorf("Unexpected GCP settings: %+v", cfg)
+	}
+	if cfg.UserAuthEnabled == nil || !*cfg.UserAuthEnabled || cfg.HomeIndexURL != "https://home.run.app" {
+		t.Errorf("Expected user auth settings to be mapped, got enabled=%v homeIndexURL=%q", cfg.UserAuthEnabled, cfg.HomeIndexURL)
+	}
+	if !reflect.DeepEqual(cfg.UserAuth.MiddlewareNames, []string{"portal-auth-check"}) || cfg.UserAuth.CheckPath != "/auth" {
+		t.Errorf("Unexpected user auth config: %+v", cfg.UserAuth)
+	}
+	if !reflect.DeepEqual(cfg.DefaultMiddlewares, []string{"compress@file"}) {
+		t.Errorf("Unexpected default middlewares: %v", cfg.DefaultMiddlewares)
+	}
+	if cfg.TokenInjection != "plugin" || cfg.LogLevel != "DEBUG" {
+		t.Errorf("Unexpected token injection/log level: %q/%q", cfg.TokenInjection, cfg.LogLevel)
+	}
+}
@@ -0,0 +1,190 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/internal/logging"
+	"google.golang.org/api/option"
+	run "google.golang.org/api/run/v1"
+)
+
+// AnthosTarget is a namespace of a Cloud Run for Anthos (Knative serving on
+// GKE) cluster to discover services from
+type AnthosTarget struct {
+	ProjectID string `json:"projectID" yaml:"projectID"` // Project owning the cluster
+	Location  string `json:"location" yaml:"location"`   // Cluster location (region or zone)
+	Cluster   string `json:"cluster" yaml:"cluster"`     // GKE cluster name
+	Namespace string `json:"namespace" yaml:"namespace"` // Kubernetes namespace (default: default)
+
+	// Endpoint is the base URL of the cluster's Kubernetes API, e.g. its
+	// Connect gateway URL (https://connectgateway.googleapis.com/v1/projects/<number>/locations/<location>/gkeMemberships/<membership>/).
+	// Services are listed from <Endpoint>apis/serving.knative.dev/v1/namespaces/<Namespace>/services.
+	Endpoint string `json:"endpoint" yaml:"endpoint"`
+}
+
+// key identifies the target in logs and the error budget
+func (t AnthosTarget) key() string {
+	return fmt.Sprintf("gke:%s/%s/%s/%s", t.ProjectID, t.Location, t.Cluster, t.Namespace)
+}
+
+// withDefaults fills unset fields
+func (t AnthosTarget) withDefaults() AnthosTarget {
+	if t.Namespace == "" {
+		t.Namespace = "default"
+	}
+	if t.Endpoint != "" && !strings.HasSuffix(t.Endpoint, "/") {
+		t.Endpoint += "/"
+	}
+	return t
+}
+
+// AnthosClient lists Knative services in a Cloud Run for Anthos namespace.
+// The default implementation uses the Cloud Run v1 API against the cluster's
+// endpoint; tests can substitute a fake.
+type AnthosClient interface {
+	// ListNamespaceServices returns one page of services in namespace on the
+	// cluster at endpoint; pageToken is empty for the first page
+	ListNamespaceServices(endpoint, namespace, pageToken string) (*run.ListServicesResponse, error)
+}
+
+// apiAnthosClient is the AnthosClient backed by the Cloud Run v1 API,
+// with one API service per cluster endpoint
+type apiAnthosClient struct {
+	ctx  context.Context
+	opts []option.ClientOption
+
+	mu       sync.Mutex
+	services map[string]*run.APIService
+}
+
+// NewAnthosClient creates an AnthosClient authenticating with opts (ADC when empty)
+func NewAnthosClient(ctx context.Context, opts ...option.ClientOption) AnthosClient {
+	return &apiAnthosClient{
+		ctx:      ctx,
+		opts:     opts,
+		services: make(map[string]*run.APIService),
+	}
+}
+
+// ListNamespaceServices lists one page of Knative services in a namespace
+func (c *apiAnthosClient) ListNamespaceServices(endpoint, namespace, pageToken string) (*run.ListServicesResponse, error) {
+	runService, err := c.service(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	call := runService.Namespaces.Services.List("namespaces/" + namespace)
+	if pageToken != "" {
+		call = call.Continue(pageToken)
+	}
+	return call.Do()
+}
+
+// service returns the API service for endpoint, creating it on first use
+func (c *apiAnthosClient) service(endpoint string) (*run.APIService, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if runService, ok := c.services[endpoint]; ok {
+		return runService, nil
+	}
+	opts := append([]option.ClientOption{option.WithEndpoint(endpoint)}, c.opts...)
+	runService, err := run.NewService(c.ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Cloud Run client for %s: %w", endpoint, err)
+	}
+	c.services[endpoint] = runService
+	return runService, nil
+}
+
+// discoverAnthos lists the Traefik-enabled services of every Anthos target,
+// with the same error budget handling as managed projects
+func (p *Provider) discoverAnthos() ([]CloudRunService, []error) {
+	var discovered []CloudRunService
+	var errs []error
+
+	for _, target := range p.config.AnthosTargets {
+		key := target.key()
+		if !p.breaker.allow(key, time.Now()) {
+			p.logger.Debug("Skipping Anthos namespace (error budget exhausted, cooling down)",
+				logging.GetCodeField(logging.CodeBreakerSkipped),
+				logging.String("target", key),
+			)
+			continue
+		}
+
+		services, err := p.listAnthosServices(target)
+		if err != nil {
+			p.logger.Error("Failed to list services in Anthos namespace",
+				logging.GetCodeField(logging.CodeServiceDiscoveryError),
+				logging.String("target", key),
+				logging.Error(err),
+			)
+			p.recordFailure(key, err)
+			errs = append(errs, err)
+			continue
+		}
+		p.breaker.recordSuccess(key)
+
+		p.logger.Info("Discovered Anthos services",
+			logging.GetCodeField(logging.CodeServiceDiscoverySuccess),
+			logging.String("target", key),
+			logging.Int("count", len(services)),
+		)
+		discovered = append(discovered, services...)
+	}
+
+	return discovered, errs
+}
+
+// listAnthosServices lists the services with traefik_enable=true in one
+// Anthos namespace, normalized like managed services
+func (p *Provider) listAnthosServices(target AnthosTarget) ([]CloudRunService, error) {
+	if p.anthos == nil {
+		return nil, fmt.Errorf("no Anthos client configured")
+	}
+
+	var services []CloudRunService
+	pageToken := ""
+	for {
+		resp, err := p.anthos.ListNamespaceServices(target.Endpoint, target.Namespace, pageToken)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list services in %s: %w", target.key(), err)
+		}
+
+		for _, svc := range resp.Items {
+			labels, ok := traefikLabels(svc)
+			if !ok || svc.Status == nil {
+				continue
+			}
+			minScale, maxScale := scalingBounds(svc)
+			services = append(services, CloudRunService{
+				Name:            svc.Metadata.Name,
+				URL:             svc.Status.Url,
+				ProjectID:       target.ProjectID,
+				Region:          target.Location,
+				Platform:        PlatformGKE,
+				Cluster:         target.Cluster,
+				Namespace:       target.Namespace,
+				Labels:          labels,
+				ResourceVersion: svc.Metadata.ResourceVersion,
+				Revision:        latestReadyRevision(svc),
+				MinScale:        minScale,
+				MaxScale:        maxScale,
+				RequestTimeout:  requestTimeout(svc),
+				NotReady:        notReady(svc),
+				NoTraffic:       servesNoTraffic(svc),
+			})
+		}
+
+		if resp.Metadata == nil || resp.Metadata.Continue == "" {
+			break
+		}
+		pageToken = resp.Metadata.Continue
+	}
+
+	return services, nil
+}
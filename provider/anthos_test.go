@@ -0,0 +1,108 @@
+package provider
+
+import (
+	"errors"
+	"testing"
+
+	run "google.golang.org/api/run/v1"
+)
+
+// fakeAnthosClient serves canned List responses per endpoint and namespace
+type fakeAnthosClient struct {
+	services map[string][]*run.Service
+	err      error
+}
+
+func (c *fakeAnthosClient) ListNamespaceServices(endpoint, namespace, _ string) (*run.ListServicesResponse, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &run.ListServicesResponse{Items: c.services[endpoint+namespace]}, nil
+}
+
+func TestDiscover_AnthosTargets(t *testing.T) {
+	p, err := NewWithClients(&Config{
+		ProjectIDs: []string{"test-project"},
+		Region:     "us-central1",
+		AnthosTargets: []AnthosTarget{{
+			ProjectID: "gke-project",
+			Location:  "us-central1-a",
+			Cluster:   "hybrid",
+			Endpoint:  "https://gke.example.com",
+		}},
+	}, &fakeCloudRunClient{services: map[string][]*run.Service{
+		"projects/test-project/locations/us-central1": {
+			newFakeService("lab1", "https://lab1.run.app", map[string]string{
+				"traefik_enable":                 "true",
+				"traefik_http_routers_lab1_rule": "PathPrefix(`/lab1`)",
+			}),
+		},
+	}}, &fakeTokenSource{token: "eyJfake"}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	p.anthos = &fakeAnthosClient{services: map[string][]*run.Service{
+		"https://gke.example.com/default": {
+			newFakeService("lab2", "http://lab2.default.example.com", map[string]string{
+				"traefik_enable":                 "true",
+				"traefik_http_routers_lab2_rule": "PathPrefix(`/lab2`)",
+			}),
+		},
+	}}
+
+	services, err := p.Discover()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(services) != 2 {
+		t.Fatalf("Expected managed and Anthos services, got %+v", services)
+	}
+	anthos := services[1]
+	if anthos.Platform != PlatformGKE || anthos.Cluster != "hybrid" || anthos.Namespace != "default" ||
+		anthos.ProjectID != "gke-project" || anthos.Region != "us-central1-a" {
+		t.Errorf("Unexpected Anthos service: %+v", anthos)
+	}
+	if services[0].Platform != PlatformManaged {
+		t.Errorf("Expected managed platform, got %q", services[0].Platform)
+	}
+
+	config, err := p.Build(services)
+	if err != nil {
+		t.Fatalf("Unexpected build error: %v", err)
+	}
+	if _, ok := config.HTTP.Middlewares["lab1-auth"]; !ok {
+		t.Error("Expected auth middleware for managed service")
+	}
+	if _, ok := config.HTTP.Middlewares["lab2-auth"]; ok {
+		t.Error("Expected no auth middleware for Anthos service")
+	}
+	if router, ok := config.HTTP.Routers["lab2"]; !ok || router.Service != "lab2" {
+		t.Errorf("Expected lab2 router for Anthos service, got %+v", router)
+	}
+}
+
+func TestDiscover_AnthosError(t *testing.T) {
+	p, err := NewWithClients(&Config{
+		Region:        "us-central1",
+		AnthosTargets: []AnthosTarget{{ProjectID: "gke-project", Cluster: "hybrid", Endpoint: "https://gke.example.com/"}},
+	}, nil, &fakeTokenSource{}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	p.anthos = &fakeAnthosClient{err: errors.New("forbidden")}
+
+	if _, err := p.Discover(); err == nil {
+		t.Error("Expected Anthos listing error to be returned")
+	}
+}
+
+func TestNew_InvalidAnthosTarget(t *testing.T) {
+	_, err := newProvider(&Config{
+		ProjectIDs:    []string{"test-project"},
+		Region:        "us-central1",
+		AnthosTargets: []AnthosTarget{{ProjectID: "gke-project"}},
+	})
+	if err == nil {
+		t.Error("Expected error for Anthos target without cluster and endpoint")
+	}
+}
@@ -0,0 +1,65 @@
+package provider
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// APICallStats summarizes the Cloud Run API List calls made for one project
+// since the provider was created
+type APICallStats struct {
+	Project   string
+	Calls     int           // List calls, one per page
+	Errors    int           // Calls that failed
+	Last      time.Duration // Latency of the most recent call
+	Max       time.Duration // Slowest call
+	Total     time.Duration // Sum of all latencies (Total/Calls is the mean)
+	LastError string        // Most recent failure
+}
+
+// apiStats records List call latencies per project
+type apiStats struct {
+	mu       sync.Mutex
+	projects map[string]*APICallStats
+}
+
+// newAPIStats creates an empty latency record
+func newAPIStats() *apiStats {
+	return &apiStats{projects: make(map[string]*APICallStats)}
+}
+
+// observe records one List call for project
+func (s *apiStats) observe(project string, latency time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.projects[project]
+	if !ok {
+		entry = &APICallStats{Project: project}
+		s.projects[project] = entry
+	}
+	entry.Calls++
+	entry.Last = latency
+	entry.Total += latency
+	if latency > entry.Max {
+		entry.Max = latency
+	}
+	if err != nil {
+		entry.Errors++
+		entry.LastError = err.Error()
+	}
+}
+
+// snapshot returns a copy of every project's stats, sorted by project
+func (s *apiStats) snapshot() []APICallStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := make([]APICallStats, 0, len(s.projects))
+	for _, entry := range s.projects {
+		stats = append(stats, *entry)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Project < stats[j].Project })
+	return stats
+}
@@ -0,0 +1,47 @@
+package provider
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAPIStats_Observe(t *testing.T) {
+	s := newAPIStats()
+	s.observe("proj-b", 20*time.Millisecond, nil)
+	s.observe("proj-a", 10*time.Millisecond, nil)
+	s.observe("proj-a", 30*time.Millisecond, errors.New("deadline exceeded"))
+	s.observe("proj-a", 5*time.Millisecond, nil)
+
+	stats := s.snapshot()
+	if len(stats) != 2 || stats[0].Project != "proj-a" || stats[1].Project != "proj-b" {
+		t.Fatalf("Expected stats sorted by project, got %+v", stats)
+	}
+	a := stats[0]
+	if a.Calls != 3 || a.Errors != 1 || a.LastError != "deadline exceeded" {
+		t.Errorf("Unexpected counts %+v", a)
+	}
+	if a.Last != 5*time.Millisecond || a.Max != 30*time.Millisecond || a.Total != 45*time.Millisecond {
+		t.Errorf("Unexpected latencies %+v", a)
+	}
+}
+
+func TestProvider_APIStats(t *testing.T) {
+	p, err := NewWithClients(&Config{
+		ProjectIDs: []string{"test-project"},
+		Region:     "us-central1",
+	}, &fakeCloudRunClient{err: errors.New("API disabled")}, &fakeTokenSource{token: "eyJfake"}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	configChan := make(chan *DynamicConfig, 1)
+	if err := p.RunOnce(configChan); err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+	<-configChan
+	stats := p.APIStats()
+	if len(stats) != 1 || stats[0].Project != "test-project" || stats[0].Errors != stats[0].Calls || stats[0].Calls == 0 {
+		t.Errorf("Expected failed calls recorded for test-project, got %+v", stats)
+	}
+}
@@ -0,0 +1,331 @@
+package provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/internal/gcp"
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/internal/logging"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// Auth providers
+//
+// Auth middlewares get their header from an AuthProvider. Services use the
+// GCP identity (identity or access tokens, see traefik_auth_type) unless
+// labelled traefik_auth_provider=<name>, which selects one of the providers
+// configured in Config.AuthProviders or registered with
+// RegisterAuthProvider. That lets one Traefik route to Cloud Run services
+// fronting non-GCP backends that expect different credentials.
+
+// AuthProvider supplies the header an auth middleware sets on requests to a
+// service
+type AuthProvider interface {
+	// AuthHeader returns the header name and value, e.g.
+	// ("Authorization", "Bearer <token>")
+	AuthHeader(service CloudRunService) (header, value string, err error)
+}
+
+// Auth provider types in AuthProviderConfig
+const (
+	AuthProviderStatic = "static" // Fixed bearer token from an environment variable or Secret Manager
+	AuthProviderOIDC   = "oidc"   // OAuth2/OIDC client credentials flow
+)
+
+// authProviderLabel selects a named auth provider for a service
+const authProviderLabel = "traefik_auth_provider"
+
+// staticTokenRefresh is how long a static token is reused before the
+// environment variable or secret is read again
+const staticTokenRefresh = 5 * time.Minute
+
+// AuthProviderConfig configures a named auth provider
+type AuthProviderConfig struct {
+	Name string `json:"name" yaml:"name"` // Referenced by the traefik_auth_provider label
+	Type string `json:"type" yaml:"type"` // static or oidc
+
+	// Glob patterns on the project IDs and service names allowed to use the
+	// provider (unset: any); set at least one, e.g. services: ["*"] for all.
+	// Other services labelled with its name are refused, so a service can't
+	// obtain another backend's credentials just by labelling itself.
+	Projects []string `json:"projects,omitempty" yaml:"projects,omitempty"`
+	Services []string `json:"services,omitempty" yaml:"services,omitempty"`
+
+	// Header the credential is written to (default Authorization) and the
+	// scheme it is prefixed with (default Bearer for Authorization, none for
+	// other headers, e.g. X-API-Key)
+	Header string `json:"header,omitempty" yaml:"header,omitempty"`
+	Scheme string `json:"scheme,omitempty" yaml:"scheme,omitempty"`
+
+	// static: the token is read from TokenEnv or the Secret Manager secret
+	// TokenSecret (projects/<p>/secrets/<s>[/versions/<v>]); set one
+	TokenEnv    string `json:"tokenEnv,omitempty" yaml:"tokenEnv,omitempty"`
+	TokenSecret string `json:"tokenSecret,omitempty" yaml:"tokenSecret,omitempty"`
+
+	// oidc: client credentials grant against TokenURL. The client secret is
+	// read from ClientSecretEnv or the Secret Manager secret ClientSecretSecret.
+	TokenURL           string   `json:"tokenURL,omitempty" yaml:"tokenURL,omitempty"`
+	ClientID           string   `json:"clientID,omitempty" yaml:"clientID,omitempty"`
+	ClientSecretEnv    string   `json:"clientSecretEnv,omitempty" yaml:"clientSecretEnv,omitempty"`
+	ClientSecretSecret string   `json:"clientSecretSecret,omitempty" yaml:"clientSecretSecret,omitempty"`
+	Scopes             []string `json:"scopes,omitempty" yaml:"scopes,omitempty"`
+	Audience           string   `json:"audience,omitempty" yaml:"audience,omitempty"` // Sent as the audience parameter (Auth0, Okta)
+}
+
+// validate checks the required fields for the provider type
+func (c AuthProviderConfig) validate() error {
+	if c.Name == "" {
+		return errors.New("name must be specified")
+	}
+	if len(c.Projects) == 0 && len(c.Services) == 0 {
+		return fmt.Errorf("auth provider %s: set projects or services it may be used by", c.Name)
+	}
+	for _, pattern := range append(append([]string{}, c.Projects...), c.Services...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("auth provider %s: invalid pattern %q: %w", c.Name, pattern, err)
+		}
+	}
+	switch c.Type {
+	case AuthProviderStatic:
+		if (c.TokenEnv == "") == (c.TokenSecret == "") {
+			return fmt.Errorf("auth provider %s: set one of tokenEnv and tokenSecret", c.Name)
+		}
+	case AuthProviderOIDC:
+		if c.TokenURL == "" || c.ClientID == "" {
+			return fmt.Errorf("auth provider %s: tokenURL and clientID must be specified", c.Name)
+		}
+		if u, err := url.Parse(c.TokenURL); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("auth provider %s: tokenURL %q is not a URL", c.Name, c.TokenURL)
+		}
+		if (c.ClientSecretEnv == "") == (c.ClientSecretSecret == "") {
+			return fmt.Errorf("auth provider %s: set one of clientSecretEnv and clientSecretSecret", c.Name)
+		}
+	default:
+		return fmt.Errorf("auth provider %s: invalid type %q (expected %q or %q)", c.Name, c.Type, AuthProviderStatic, AuthProviderOIDC)
+	}
+	return nil
+}
+
+// headerValue returns the header name and value for a credential
+func (c AuthProviderConfig) headerValue(credential string) (string, string) {
+	header, scheme := c.Header, c.Scheme
+	if header == "" {
+		header = "Authorization"
+	}
+	if scheme == "" && header == "Authorization" {
+		scheme = "Bearer"
+	}
+	if scheme == "" {
+		return header, credential
+	}
+	return header, scheme + " " + credential
+}
+
+// secretSource returns a function reading a value from an environment
+// variable or, when env is empty, a Secret Manager secret
+func secretSource(env, secret string, credentials *gcp.Credentials) func() (string, error) {
+	if env != "" {
+		return func() (string, error) {
+			value := os.Getenv(env)
+			if value == "" {
+				return "", fmt.Errorf("environment variable %s is not set", env)
+			}
+			return value, nil
+		}
+	}
+	return func() (string, error) {
+		return gcp.AccessSecret(context.Background(), secret, credentials)
+	}
+}
+
+// newAuthProvider creates the provider for a validated config
+func newAuthProvider(config AuthProviderConfig, credentials *gcp.Credentials) AuthProvider {
+	if config.Type == AuthProviderOIDC {
+		return &oidcAuthProvider{
+			config:       config,
+			clientSecret: secretSource(config.ClientSecretEnv, config.ClientSecretSecret, credentials),
+		}
+	}
+	return &staticAuthProvider{
+		config: config,
+		load:   secretSource(config.TokenEnv, config.TokenSecret, credentials),
+	}
+}
+
+// boundAuthProvider is a named auth provider with the projects and services
+// allowed to use it
+type boundAuthProvider struct {
+	AuthProvider
+	projects, services []string
+}
+
+// binds reports whether a service may use the auth provider. A provider
+// bound to nothing can't be used.
+func (b boundAuthProvider) binds(service CloudRunService) bool {
+	if len(b.projects) == 0 && len(b.services) == 0 {
+		return false
+	}
+	return matchesAny(b.projects, service.ProjectID) && matchesAny(b.services, service.Name)
+}
+
+// staticAuthProvider sets a fixed token, re-read every staticTokenRefresh
+// so a rotated secret is picked up
+type staticAuthProvider struct {
+	config AuthProviderConfig
+	load   func() (string, error)
+
+	mu       sync.Mutex
+	token    string
+	loadedAt time.Time
+}
+
+// AuthHeader returns the static token
+func (s *staticAuthProvider) AuthHeader(CloudRunService) (string, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token == "" || time.Since(s.loadedAt) > staticTokenRefresh {
+		token, err := s.load()
+		if err != nil {
+			return "", "", err
+		}
+		s.token, s.loadedAt = token, time.Now()
+	}
+	header, value := s.config.headerValue(s.token)
+	return header, value, nil
+}
+
+// oidcAuthProvider sets an access token from the client credentials flow.
+// The token source reuses a token until it expires.
+type oidcAuthProvider struct {
+	config       AuthProviderConfig
+	clientSecret func() (string, error)
+
+	mu     sync.Mutex
+	source oauth2.TokenSource
+}
+
+// AuthHeader returns the current access token, fetching a new one when the
+// previous one has expired
+func (o *oidcAuthProvider) AuthHeader(CloudRunService) (string, string, error) {
+	o.mu.Lock()
+	if o.source == nil {
+		secret, err := o.clientSecret()
+		if err != nil {
+			o.mu.Unlock()
+			return "", "", fmt.Errorf("failed to read client secret: %w", err)
+		}
+		cc := &clientcredentials.Config{
+			ClientID:     o.config.ClientID,
+			ClientSecret: secret,
+			TokenURL:     o.config.TokenURL,
+			Scopes:       o.config.Scopes,
+		}
+		if o.config.Audience != "" {
+			cc.EndpointParams = url.Values{"audience": {o.config.Audience}}
+		}
+		o.source = cc.TokenSource(context.Background())
+	}
+	source := o.source
+	o.mu.Unlock()
+
+	token, err := source.Token()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to fetch token from %s: %w", o.config.TokenURL, err)
+	}
+	header, value := o.config.headerValue(token.AccessToken)
+	return header, value, nil
+}
+
+// gcpAuthProvider is the default provider: identity tokens for the service
+// URL, or access tokens for services labelled traefik_auth_type=access_token
+type gcpAuthProvider struct {
+	p *Provider
+}
+
+// AuthHeader fetches the service's GCP token
+func (g gcpAuthProvider) AuthHeader(service CloudRunService) (string, string, error) {
+	if g.p.authType(service) == AuthTypeAccessToken {
+		token, err := g.p.fetchAccessToken(service)
+		return "Authorization", "Bearer " + token, err
+	}
+	token, err := g.p.fetchServiceToken(service)
+	return "X-Serverless-Authorization", "Bearer " + token, err
+}
+
+// RegisterAuthProvider makes an auth provider available to services
+// labelled traefik_auth_provider=<name> whose project ID and name match the
+// glob patterns in projects and services (nil: any; at least one must be
+// set, or no service may use it), replacing a configured provider of the
+// same name. Call it before the provider starts.
+func (p *Provider) RegisterAuthProvider(name string, authProvider AuthProvider, projects, services []string) {
+	p.authProviders[name] = boundAuthProvider{AuthProvider: authProvider, projects: projects, services: services}
+}
+
+// authProvider returns the auth provider selected by the service's
+// traefik_auth_provider label, or the GCP provider when there is none. A
+// named provider must exist and be bound to the service.
+func (p *Provider) authProvider(service CloudRunService) (string, AuthProvider, error) {
+	name, ok := service.Labels[authProviderLabel]
+	if !ok || name == "" {
+		return "", gcpAuthProvider{p}, nil
+	}
+	bound, ok := p.authProviders[name]
+	if !ok {
+		return name, nil, fmt.Errorf("%w: %s", ErrUnknownAuthProvider, name)
+	}
+	if !bound.binds(service) {
+		return name, nil, fmt.Errorf("%w: %s is not bound to %s/%s", ErrAuthProviderNotBound, name, service.ProjectID, service.Name)
+	}
+	return name, bound.AuthProvider, nil
+}
+
+// authMiddlewareName returns the name of a service's auth middleware:
+// <service>-auth, or with ShareAuthMiddlewares auth-<hash> of what its
+// credential depends on (auth type, auth provider and audience), so
+// services sharing a backend reference one middleware
+func (p *Provider) authMiddlewareName(service CloudRunService, serviceName string) string {
+	if !p.config.ShareAuthMiddlewares {
+		return serviceName + "-auth"
+	}
+	key := p.authType(service) + "\x00" + service.Labels[authProviderLabel] + "\x00" + service.URL
+	sum := sha256.Sum256([]byte(key))
+	return "auth-" + hex.EncodeToString(sum[:6])
+}
+
+// authHeader returns the auth middleware header for a service. Errors are
+// *TokenError so the token failure policy applies to every provider.
+func (p *Provider) authHeader(service CloudRunService) (string, string, error) {
+	name, authProvider, err := p.authProvider(service)
+	if err != nil {
+		p.logger.Warn("Refusing auth provider for service",
+			logging.String("service", service.Name),
+			logging.String("project", service.ProjectID),
+			logging.String("authProvider", name),
+			logging.Error(err),
+		)
+		return "", "", &TokenError{Service: service.Name, URL: service.URL, Err: err}
+	}
+	header, value, err := authProvider.AuthHeader(service)
+	if err == nil || name == "" {
+		// The GCP provider logs and wraps its own errors
+		return header, value, err
+	}
+
+	p.logger.Error("Failed to get auth header from auth provider",
+		logging.GetCodeField(logging.CodeTokenFetchError),
+		logging.String("service", service.Name),
+		logging.String("authProvider", name),
+		logging.Error(err),
+	)
+	return "", "", &TokenError{Service: service.Name, URL: service.URL, Err: err}
+}
@@ -0,0 +1,249 @@
+package provider
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestAuthProviderConfig_Validate(t *testing.T) {
+	anyService := []string{"*"}
+	tests := []struct {
+		name   string
+		config AuthProviderConfig
+		want   string
+	}{
+		{"valid static", AuthProviderConfig{Name: "a", Type: AuthProviderStatic, Services: anyService, TokenEnv: "TOKEN"}, ""},
+		{"valid oidc", AuthProviderConfig{Name: "a", Type: AuthProviderOIDC, Projects: []string{"p"}, TokenURL: "https://idp/token", ClientID: "id", ClientSecretEnv: "SECRET"}, ""},
+		{"no name", AuthProviderConfig{Type: AuthProviderStatic, Services: anyService, TokenEnv: "TOKEN"}, "name must be specified"},
+		{"unbound", AuthProviderConfig{Name: "a", Type: AuthProviderStatic, TokenEnv: "TOKEN"}, "set projects or services"},
+		{"bad pattern", AuthProviderConfig{Name: "a", Type: AuthProviderStatic, Services: []string{"["}, TokenEnv: "TOKEN"}, "invalid pattern"},
+		{"bad type", AuthProviderConfig{Name: "a", Type: "basic", Services: anyService}, "invalid type"},
+		{"static without source", AuthProviderConfig{Name: "a", Type: AuthProviderStatic, Services: anyService}, "tokenEnv and tokenSecret"},
+		{"static with both sources", AuthProviderConfig{Name: "a", Type: AuthProviderStatic, Services: anyService, TokenEnv: "T", TokenSecret: "projects/p/secrets/s"}, "tokenEnv and tokenSecret"},
+		{"oidc without client", AuthProviderConfig{Name: "a", Type: AuthProviderOIDC, Services: anyService, TokenURL: "https://idp/token", ClientSecretEnv: "S"}, "clientID"},
+		{"oidc bad url", AuthProviderConfig{Name: "a", Type: AuthProviderOIDC, Services: anyService, TokenURL: "idp/token", ClientID: "id", ClientSecretEnv: "S"}, "not a URL"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.validate()
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("Expected valid config, got %v", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestAuthProviderConfig_HeaderValue(t *testing.T) {
+	tests := []struct {
+		config        AuthProviderConfig
+		header, value string
+	}{
+		{AuthProviderConfig{}, "Authorization", "Bearer tok"},
+		{AuthProviderConfig{Header: "X-API-Key"}, "X-API-Key", "tok"},
+		{AuthProviderConfig{Scheme: "Token"}, "Authorization", "Token tok"},
+	}
+	for _, tt := range tests {
+		header, value := tt.config.headerValue("tok")
+		if header != tt.header || value != tt.value {
+			t.Errorf("headerValue for %+v = %q, %q; expected %q, %q", tt.config, header, value, tt.header, tt.value)
+		}
+	}
+}
+
+func TestStaticAuthProvider(t *testing.T) {
+	t.Setenv("BACKEND_TOKEN", "static-token")
+	authProvider := newAuthProvider(AuthProviderConfig{Name: "backend", Type: AuthProviderStatic, TokenEnv: "BACKEND_TOKEN"}, nil)
+
+	header, value, err := authProvider.AuthHeader(CloudRunService{})
+	if err != nil || header != "Authorization" || value != "Bearer static-token" {
+		t.Errorf("AuthHeader = %q, %q, %v", header, value, err)
+	}
+
+	missing := newAuthProvider(AuthProviderConfig{Name: "missing", Type: AuthProviderStatic, TokenEnv: "UNSET_BACKEND_TOKEN"}, nil)
+	if _, _, err := missing.AuthHeader(CloudRunService{}); err == nil {
+		t.Error("Expected error for unset environment variable")
+	}
+}
+
+func TestOIDCAuthProvider(t *testing.T) {
+	var requests int32
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("Failed to parse token request: %v", err)
+		}
+		clientID, secret, _ := r.BasicAuth()
+		if r.Form.Get("grant_type") != "client_credentials" || clientID != "client" || secret != "s3cret" ||
+			r.Form.Get("audience") != "https://api.example.com" {
+			http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"oidc-token","token_type":"Bearer","expires_in":3600}`))
+	}))
+	defer idp.Close()
+
+	t.Setenv("OIDC_SECRET", "s3cret")
+	authProvider := newAuthProvider(AuthProviderConfig{
+		Name:            "idp",
+		Type:            AuthProviderOIDC,
+		TokenURL:        idp.URL,
+		ClientID:        "client",
+		ClientSecretEnv: "OIDC_SECRET",
+		Audience:        "https://api.example.com",
+	}, nil)
+
+	for i := 0; i < 3; i++ {
+		header, value, err := authProvider.AuthHeader(CloudRunService{})
+		if err != nil || header != "Authorization" || value != "Bearer oidc-token" {
+			t.Fatalf("AuthHeader = %q, %q, %v", header, value, err)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("Expected the token to be reused until it expires, got %d token requests", requests)
+	}
+}
+
+// fixedAuthProvider returns a fixed header or error
+type fixedAuthProvider struct {
+	header, value string
+	err           error
+}
+
+func (f fixedAuthProvider) AuthHeader(CloudRunService) (string, string, error) {
+	return f.header, f.value, f.err
+}
+
+func TestProcessService_AuthProviderLabel(t *testing.T) {
+	p, err := NewWithClients(&Config{
+		ProjectIDs:         []string{"test-project"},
+		Region:             "us-central1",
+		TokenInjection:     TokenInjectionPlugin,
+		TokenFailurePolicy: TokenFailureSkipRoute,
+	}, &fakeCloudRunClient{}, &fakeTokenSource{}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	p.RegisterAuthProvider("partner", fixedAuthProvider{header: "X-API-Key", value: "key"}, nil, []string{"partner-*"})
+	p.RegisterAuthProvider("broken", fixedAuthProvider{err: errors.New("idp down")}, nil, []string{"*"})
+	p.RegisterAuthProvider("billing", fixedAuthProvider{header: "Authorization", value: "Bearer billing"}, []string{"billing-*"}, nil)
+	p.RegisterAuthProvider("unbound", fixedAuthProvider{header: "Authorization", value: "Bearer unbound"}, nil, nil)
+
+	service := func(authProvider string) CloudRunService {
+		return CloudRunService{
+			Name:      "partner-proxy",
+			ProjectID: "test-project",
+			URL:       "https://partner-proxy.run.app",
+			Labels: map[string]string{
+				"traefik_auth_provider":                   authProvider,
+				"traefik_http_routers_partner-proxy_rule": "PathPrefix(`/partner`)",
+			},
+		}
+	}
+
+	// Named providers are used even in plugin mode, which only covers GCP tokens
+	config := NewDynamicConfig()
+	if err := p.processService(service("partner"), config); err != nil {
+		t.Fatalf("processService failed: %v", err)
+	}
+	mw := config.HTTP.Middlewares["partner-proxy-auth"]
+	if mw.Headers == nil || mw.Headers.CustomRequestHeaders["X-API-Key"] != "key" {
+		t.Errorf("Expected X-API-Key auth middleware, got %+v", mw)
+	}
+
+	var tokenErr *TokenError
+	err = p.processService(service("broken"), NewDynamicConfig())
+	if !errors.As(err, &tokenErr) {
+		t.Errorf("Expected TokenError from failing auth provider, got %v", err)
+	}
+	err = p.processService(service("nobody"), NewDynamicConfig())
+	if !errors.Is(err, ErrUnknownAuthProvider) {
+		t.Errorf("Expected ErrUnknownAuthProvider, got %v", err)
+	}
+
+	// Providers are refused to services outside their binding, before any
+	// credential is fetched
+	for _, name := range []string{"billing", "unbound"} {
+		config := NewDynamicConfig()
+		err = p.processService(service(name), config)
+		if !errors.As(err, &tokenErr) || !errors.Is(err, ErrAuthProviderNotBound) {
+			t.Errorf("%s: expected ErrAuthProviderNotBound TokenError, got %v", name, err)
+		}
+		if _, ok := config.HTTP.Middlewares["partner-proxy-auth"]; ok {
+			t.Errorf("%s: expected no auth middleware for an unbound service", name)
+		}
+	}
+	billing := service("billing")
+	billing.ProjectID = "billing-prod"
+	if err := p.processService(billing, NewDynamicConfig()); err != nil {
+		t.Errorf("Expected billing provider for a service in a bound project, got %v", err)
+	}
+}
+
+func TestNew_InvalidAuthProviders(t *testing.T) {
+	static := AuthProviderConfig{Name: "a", Type: AuthProviderStatic, Services: []string{"*"}, TokenEnv: "TOKEN"}
+	for _, providers := range [][]AuthProviderConfig{
+		{{Name: "a", Type: "basic"}},
+		{static, static},
+	} {
+		_, err := newProvider(&Config{ProjectIDs: []string{"p"}, Region: "us-central1", AuthProviders: providers})
+		if err == nil {
+			t.Errorf("Expected error for auth providers %+v", providers)
+		}
+	}
+}
+
+func TestProcessService_ShareAuthMiddlewares(t *testing.T) {
+	p, err := NewWithClients(&Config{
+		ProjectIDs:           []string{"test-project"},
+		Region:               "us-central1",
+		ShareAuthMiddlewares: true,
+	}, &fakeCloudRunClient{}, &fakeTokenSource{token: "eyJfake"}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	service := func(name, url string, middlewares string) CloudRunService {
+		labels := map[string]string{"traefik_http_routers_" + name + "_rule": "PathPrefix(`/" + name + "`)"}
+		if middlewares != "" {
+			labels["traefik_http_routers_"+name+"_middlewares"] = middlewares
+		}
+		return CloudRunService{Name: name, URL: url, Labels: labels}
+	}
+
+	config := NewDynamicConfig()
+	for _, s := range []CloudRunService{
+		service("app-a", "https://shared.run.app", ""),
+		service("app-b", "https://shared.run.app", "app-b-auth"),
+		service("other", "https://other.run.app", ""),
+	} {
+		if err := p.processService(s, config); err != nil {
+			t.Fatalf("processService(%s) failed: %v", s.Name, err)
+		}
+	}
+
+	shared := config.HTTP.Routers["app-a"].Middlewares[0]
+	if !strings.HasPrefix(shared, "auth-") || config.HTTP.Middlewares[shared].Headers == nil {
+		t.Fatalf("Expected a shared auth-<hash> middleware first on app-a, got %v", config.HTTP.Routers["app-a"].Middlewares)
+	}
+	if got := config.HTTP.Routers["app-b"].Middlewares; got[0] != shared || slices.Contains(got, "app-b-auth") {
+		t.Errorf("Expected app-b to reference %s in place of app-b-auth, got %v", shared, got)
+	}
+	if got := config.HTTP.Routers["other"].Middlewares[0]; got == shared || !strings.HasPrefix(got, "auth-") {
+		t.Errorf("Expected another backend URL to get its own middleware, got %s", got)
+	}
+	for name := range config.HTTP.Middlewares {
+		if strings.HasSuffix(name, "-auth") {
+			t.Errorf("Expected no per-service auth middleware, got %s", name)
+		}
+	}
+}
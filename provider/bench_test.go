@@ -0,0 +1,110 @@
+package provider_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/provider"
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/provider/providertest"
+)
+
+// Fleet sizes and paging the benchmarks run with; pages hold 100 services
+// (the API's default) spread over three projects
+var benchFleets = []int{100, 1000, 5000}
+
+const (
+	benchProjects = 3
+	benchPageSize = 100
+)
+
+// newSyntheticProvider creates a provider serving a synthetic fleet
+func newSyntheticProvider(tb testing.TB, n, projects, pageSize int) *provider.Provider {
+	tb.Helper()
+	p, err := providertest.NewProvider(providertest.Synthetic(n, projects, pageSize))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return p
+}
+
+func TestSynthetic_LargeFleetPaging(t *testing.T) {
+	const n = 1200
+	p := newSyntheticProvider(t, n, benchProjects, 50)
+
+	services, err := p.Discover()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := providertest.SyntheticRouted(n); len(services) != want {
+		t.Fatalf("Expected %d services across all pages, got %d", want, len(services))
+	}
+	seen := make(map[string]bool, len(services))
+	for _, service := range services {
+		if seen[service.Name] {
+			t.Fatalf("Service %s discovered twice", service.Name)
+		}
+		seen[service.Name] = true
+	}
+
+	config, err := p.Build(services)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		name := fmt.Sprintf("svc-%05d", i)
+		if _, ok := config.HTTP.Routers[name]; ok == (i%10 == 0) {
+			t.Errorf("Router %s: generated = %v", name, ok)
+		}
+	}
+}
+
+func BenchmarkDiscover(b *testing.B) {
+	for _, n := range benchFleets {
+		b.Run(fmt.Sprintf("services=%d", n), func(b *testing.B) {
+			p := newSyntheticProvider(b, n, benchProjects, benchPageSize)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := p.Discover(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkBuild(b *testing.B) {
+	for _, n := range benchFleets {
+		b.Run(fmt.Sprintf("services=%d", n), func(b *testing.B) {
+			p := newSyntheticProvider(b, n, benchProjects, benchPageSize)
+			services, err := p.Discover()
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := p.Build(services); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkGenerate measures a full cycle: paged discovery, generation and
+// encoding routes.yml
+func BenchmarkGenerate(b *testing.B) {
+	for _, n := range benchFleets {
+		b.Run(fmt.Sprintf("services=%d", n), func(b *testing.B) {
+			fixture := providertest.Synthetic(n, benchProjects, benchPageSize)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := providertest.Generate(fixture); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
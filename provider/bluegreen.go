@@ -0,0 +1,124 @@
+package provider
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/internal/logging"
+)
+
+// Blue/green labels. A controller service names its candidate backends with
+// traefik_backend_<name>=<Cloud Run service> and selects one with
+// traefik_active_backend=<name>; the controller's routers are generated as
+// usual but send traffic to the active backend's URL:
+//
+//	traefik_backend_blue=shop-blue
+//	traefik_backend_green=shop-green
+//	traefik_active_backend=green
+//
+// Backends are looked up among the discovered services of the controller's
+// project, so they need traefik_enable=true too.
+const (
+	activeBackendLabel = "traefik_active_backend"
+	backendLabelPrefix = "traefik_backend_"
+)
+
+// blueGreenBackends returns the keys (see fragmentKey) of the services named
+// as a backend by a controller service
+func blueGreenBackends(services []CloudRunService) map[string]bool {
+	backends := make(map[string]bool)
+	for _, service := range services {
+		labels := NormalizeLabels(service.Labels)
+		if _, ok := labels[activeBackendLabel]; !ok {
+			continue
+		}
+		for key, value := range labels {
+			if strings.HasPrefix(key, backendLabelPrefix) {
+				backends[backendKey(service, value)] = true
+			}
+		}
+	}
+	return backends
+}
+
+// backendKey returns the key (see fragmentKey) of the backend service name
+// next to controller, i.e. in the same project (or cluster and namespace)
+func backendKey(controller CloudRunService, name string) string {
+	controller.Name = name
+	return fragmentKey(controller)
+}
+
+// hasRouterLabels reports whether a service defines routers of its own
+func hasRouterLabels(labels map[string]string) bool {
+	for key := range labels {
+		if strings.HasPrefix(key, "traefik_http_routers_") {
+			return true
+		}
+	}
+	return shorthandRule(labels) != ""
+}
+
+// activeBackend returns the service a controller's traefik_active_backend
+// label selects from discovered (keyed by fragmentKey)
+func activeBackend(controller CloudRunService, discovered map[string]CloudRunService) (CloudRunService, error) {
+	active := controller.Labels[activeBackendLabel]
+	name := controller.Labels[backendLabelPrefix+active]
+	if name == "" {
+		return CloudRunService{}, fmt.Errorf("active backend %q has no %s%s label", active, backendLabelPrefix, active)
+	}
+	backend, ok := discovered[backendKey(controller, name)]
+	if !ok {
+		return CloudRunService{}, fmt.Errorf("active backend %s (%s) was not discovered (is it labelled traefik_enable=true?)", active, name)
+	}
+	if backend.URL == "" {
+		return CloudRunService{}, fmt.Errorf("active backend %s (%s) has no URL yet", active, name)
+	}
+	return backend, nil
+}
+
+// gateBackend points a blue/green controller service at its active backend:
+// the returned service has the backend's URL, revision and scaling, so the
+// controller's routers switch backends together at the next generation.
+// When the active backend can't be resolved the controller keeps its last
+// URL (recorded as degraded) or, without one, is skipped. Services without
+// traefik_active_backend are returned as they are. Reports whether the
+// service is routed.
+func (p *Provider) gateBackend(service CloudRunService, discovered map[string]CloudRunService, config *DynamicConfig) (CloudRunService, bool) {
+	if _, ok := service.Labels[activeBackendLabel]; !ok {
+		return service, true
+	}
+
+	backend, err := activeBackend(service, discovered)
+	if err != nil {
+		last := p.urls.last(fragmentKey(service))
+		p.logger.Warn("Blue/green controller has no usable active backend",
+			logging.String("service", service.Name),
+			logging.String("project", service.ProjectID),
+			logging.String("lastURL", last),
+			logging.Error(err),
+		)
+		if last == "" {
+			config.skip(SkippedService{Service: service.Name, Project: service.ProjectID, Reason: SkipReasonInvalid, Detail: err.Error()})
+			return service, false
+		}
+		config.skip(SkippedService{Service: service.Name, Project: service.ProjectID, Reason: SkipReasonInvalid,
+			Detail: err.Error() + "; routing to last URL " + last, Degraded: true})
+		service.URL = last
+		return service, true
+	}
+
+	p.logger.Info("Blue/green controller routed to its active backend",
+		logging.String("service", service.Name),
+		logging.String("active", service.Labels[activeBackendLabel]),
+		logging.String("backend", backend.Name),
+		logging.String("url", backend.URL),
+	)
+	service.URL = backend.URL
+	service.Revision = backend.Revision
+	service.MinScale = backend.MinScale
+	service.MaxScale = backend.MaxScale
+	service.RequestTimeout = backend.RequestTimeout
+	service.NotReady = backend.NotReady
+	service.NoTraffic = backend.NoTraffic
+	return service, true
+}
@@ -0,0 +1,68 @@
+package provider
+
+import (
+	"testing"
+)
+
+func TestBuild_BlueGreen(t *testing.T) {
+	controller := func(active string) CloudRunService {
+		return CloudRunService{Name: "shop", ProjectID: "test-project", URL: "https://shop.run.app", Labels: map[string]string{
+			"traefik_enable":                 "true",
+			"traefik_http_routers_shop_rule": "PathPrefix(`/shop`)",
+			"traefik_http_routers_cart_rule": "PathPrefix(`/cart`)",
+			"traefik_backend_blue":           "shop-blue",
+			"traefik_backend_green":          "shop-green",
+			"traefik_active_backend":         active,
+		}}
+	}
+	backends := []CloudRunService{
+		{Name: "shop-blue", ProjectID: "test-project", URL: "https://shop-blue.run.app", Labels: map[string]string{"traefik_enable": "true"}},
+		{Name: "shop-green", ProjectID: "test-project", URL: "https://shop-green.run.app", Labels: map[string]string{"traefik_enable": "true"}},
+	}
+	provider, err := newProvider(&Config{
+		ProjectIDs:     []string{"test-project"},
+		Region:         "us-central1",
+		TokenInjection: TokenInjectionPlugin,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	backendURL := func(config *DynamicConfig) string {
+		service := config.HTTP.Services[config.HTTP.Routers["shop"].Service]
+		if len(service.LoadBalancer.Servers) != 1 {
+			return ""
+		}
+		return service.LoadBalancer.Servers[0].URL
+	}
+
+	for _, tt := range []struct{ active, want string }{
+		{"blue", "https://shop-blue.run.app"},
+		{"green", "https://shop-green.run.app"},
+	} {
+		config, err := provider.Build(append([]CloudRunService{controller(tt.active)}, backends...))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if got := backendURL(config); got != tt.want {
+			t.Errorf("active=%s: expected %s, got %s", tt.active, tt.want, got)
+		}
+		if config.HTTP.Routers["cart"].Service != config.HTTP.Routers["shop"].Service {
+			t.Errorf("active=%s: expected all routers to share the switched service", tt.active)
+		}
+		if skipped := config.Skipped(); len(skipped) != 0 {
+			t.Errorf("active=%s: expected the backends not to be listed as skipped, got %+v", tt.active, skipped)
+		}
+	}
+
+	// A typo keeps the last backend rather than dropping the routes
+	config, err := provider.Build(append([]CloudRunService{controller("gren")}, backends...))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := backendURL(config); got != "https://shop-green.run.app" {
+		t.Errorf("Expected the last backend to be kept, got %s", got)
+	}
+	if skipped := config.Skipped(); len(skipped) != 1 || skipped[0].Reason != SkipReasonInvalid || !skipped[0].Degraded {
+		t.Errorf("Expected the controller to be reported as degraded, got %+v", skipped)
+	}
+}
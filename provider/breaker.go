@@ -0,0 +1,130 @@
+package provider
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// defaultBreakerCooldown is how long a tripped project or service is skipped
+const defaultBreakerCooldown = 5 * time.Minute
+
+// BreakerStatus reports the error budget state of a project or service
+type BreakerStatus struct {
+	Key                 string    // Project ID, or "project/service" for services
+	ConsecutiveFailures int       // Failures since the last success
+	Open                bool      // True while the key is being skipped
+	OpenUntil           time.Time // When the cool-down ends (zero if closed)
+	LastError           string    // Most recent failure
+}
+
+// circuitBreaker stops retrying projects or services that fail on every poll
+// (e.g. Cloud Run API disabled) for a cool-down period, instead of logging the
+// same error forever and slowing every cycle.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	entries   map[string]*BreakerStatus
+	threshold int           // Consecutive failures before opening (0 = disabled)
+	cooldown  time.Duration // How long to stay open
+}
+
+// newCircuitBreaker creates a circuit breaker from the provider configuration
+func newCircuitBreaker(config *Config) *circuitBreaker {
+	cooldown := config.BreakerCooldown
+	if cooldown == 0 {
+		cooldown = defaultBreakerCooldown
+	}
+	return &circuitBreaker{
+		entries:   make(map[string]*BreakerStatus),
+		threshold: config.BreakerThreshold,
+		cooldown:  cooldown,
+	}
+}
+
+// allow reports whether the key may be attempted now. Once the cool-down has
+// passed the key is allowed again (half-open); one more failure reopens it.
+func (b *circuitBreaker) allow(key string, now time.Time) bool {
+	if b.threshold <= 0 {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	entry, ok := b.entries[key]
+	if !ok || !entry.Open {
+		return true
+	}
+	if now.Before(entry.OpenUntil) {
+		return false
+	}
+	entry.Open = false
+	entry.OpenUntil = time.Time{}
+	return true
+}
+
+// recordSuccess resets the key's error budget
+func (b *circuitBreaker) recordSuccess(key string) {
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.entries, key)
+}
+
+// recordFailure counts a failure and opens the breaker once the threshold is
+// reached. Returns true if this failure opened it.
+func (b *circuitBreaker) recordFailure(key string, err error, now time.Time) bool {
+	if b.threshold <= 0 {
+		return false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	entry, ok := b.entries[key]
+	if !ok {
+		entry = &BreakerStatus{Key: key}
+		b.entries[key] = entry
+	}
+	entry.ConsecutiveFailures++
+	if err != nil {
+		entry.LastError = err.Error()
+	}
+
+	if entry.ConsecutiveFailures >= b.threshold && !entry.Open {
+		entry.Open = true
+		entry.OpenUntil = now.Add(b.cooldown)
+		return true
+	}
+	return false
+}
+
+// snapshot returns the state of all keys with outstanding failures, sorted by key
+func (b *circuitBreaker) snapshot() []BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	statuses := make([]BreakerStatus, 0, len(b.entries))
+	for _, entry := range b.entries {
+		statuses = append(statuses, *entry)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Key < statuses[j].Key })
+	return statuses
+}
+
+// inherit takes over the failure counts and open cool-downs of previous,
+// keeping b's own threshold and cooldown
+func (b *circuitBreaker) inherit(previous *circuitBreaker) {
+	previous.mu.Lock()
+	defer previous.mu.Unlock()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for key, entry := range previous.entries {
+		copied := *entry
+		b.entries[key] = &copied
+	}
+}
@@ -0,0 +1,70 @@
+package provider
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker_OpensAfterThreshold(t *testing.T) {
+	b := newCircuitBreaker(&Config{BreakerThreshold: 2, BreakerCooldown: time.Minute})
+	now := time.Now()
+	apiErr := errors.New("Cloud Run API has not been used in project")
+
+	if opened := b.recordFailure("proj", apiErr, now); opened {
+		t.Error("Expected breaker to stay closed after first failure")
+	}
+	if !b.allow("proj", now) {
+		t.Error("Expected key to be allowed below threshold")
+	}
+	if opened := b.recordFailure("proj", apiErr, now); !opened {
+		t.Error("Expected breaker to open at threshold")
+	}
+	if b.allow("proj", now.Add(30*time.Second)) {
+		t.Error("Expected key to be skipped during cool-down")
+	}
+
+	statuses := b.snapshot()
+	if len(statuses) != 1 || !statuses[0].Open || statuses[0].ConsecutiveFailures != 2 {
+		t.Fatalf("Expected one open entry with 2 failures, got %+v", statuses)
+	}
+	if statuses[0].LastError != apiErr.Error() {
+		t.Errorf("Expected last error %q, got %q", apiErr.Error(), statuses[0].LastError)
+	}
+}
+
+func TestCircuitBreaker_HalfOpenAfterCooldown(t *testing.T) {
+	b := newCircuitBreaker(&Config{BreakerThreshold: 1, BreakerCooldown: time.Minute})
+	now := time.Now()
+
+	b.recordFailure("proj", errors.New("boom"), now)
+	if !b.allow("proj", now.Add(2*time.Minute)) {
+		t.Fatal("Expected key to be retried after cool-down")
+	}
+
+	// One more failure reopens it
+	if opened := b.recordFailure("proj", errors.New("boom"), now.Add(2*time.Minute)); !opened {
+		t.Error("Expected breaker to reopen after failed retry")
+	}
+
+	// A success resets the budget
+	b.recordSuccess("proj")
+	if len(b.snapshot()) != 0 {
+		t.Error("Expected no entries after success")
+	}
+}
+
+func TestCircuitBreaker_Disabled(t *testing.T) {
+	b := newCircuitBreaker(&Config{})
+	now := time.Now()
+
+	for i := 0; i < 10; i++ {
+		b.recordFailure("proj", errors.New("boom"), now)
+	}
+	if !b.allow("proj", now) {
+		t.Error("Expected disabled breaker to always allow")
+	}
+	if len(b.snapshot()) != 0 {
+		t.Error("Expected disabled breaker to record nothing")
+	}
+}
@@ -0,0 +1,78 @@
+package provider
+
+import (
+	"fmt"
+	"path"
+	"sort"
+)
+
+// allowCatchAllLabel lets a service define routers whose rule matches every
+// request (see catchAllRule)
+const allowCatchAllLabel = "traefik_allow_catch_all"
+
+// DefaultCatchAllServices is the CatchAllServices default: the home-index
+// service, whose root route (rule_id home-index-root) is the site's catch-all
+var DefaultCatchAllServices = []string{"home-index*"}
+
+// catchAllRule reports whether a rule can match any path on any host, like
+// PathPrefix(`/`), apart from conditions on headers, methods or queries.
+// Like RulePolicy, a rule without a host and path condition it can analyze
+// (Method(`GET`), PathRegexp(`.*`), an || branch without a path) is taken
+// to match anything. Rules that don't parse are left to the rule check.
+func catchAllRule(rule string) bool {
+	if rule == "" {
+		return false
+	}
+	node, err := parseRule(rule)
+	if err != nil {
+		return false
+	}
+	scope := node.scope()
+	if scope.hosts != nil {
+		return false
+	}
+	if scope.paths == nil {
+		return true
+	}
+	for _, match := range scope.paths {
+		if match.path == "/" && !match.exact {
+			return true
+		}
+	}
+	return false
+}
+
+// catchAllAllowed reports whether a service may define catch-all routers: it
+// is labelled traefik_allow_catch_all=true or matches a CatchAllServices pattern
+func (p *Provider) catchAllAllowed(service CloudRunService) bool {
+	if service.Labels[allowCatchAllLabel] == labelValueTrue {
+		return true
+	}
+	for _, pattern := range p.config.CatchAllServices {
+		if matched, _ := path.Match(pattern, service.Name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// dropCatchAllRouters removes the catch-all routers of a service that isn't
+// allowed to define them, so a mislabeled service (e.g. one copying
+// rule_id home-index-root) can't take over the whole site. It returns a
+// description of each dropped router.
+func (p *Provider) dropCatchAllRouters(service CloudRunService, routerConfigs map[string]RouterConfig) []string {
+	if p.catchAllAllowed(service) {
+		return nil
+	}
+	var dropped []string
+	for routerName, routerConfig := range routerConfigs {
+		if !catchAllRule(routerConfig.Rule) {
+			continue
+		}
+		delete(routerConfigs, routerName)
+		dropped = append(dropped, fmt.Sprintf("router %s: rule %s matches every request (label the service %s=true to allow it)",
+			routerName, routerConfig.Rule, allowCatchAllLabel))
+	}
+	sort.Strings(dropped)
+	return dropped
+}
@@ -0,0 +1,86 @@
+package provider
+
+import (
+	"testing"
+)
+
+func TestCatchAllRule(t *testing.T) {
+	tests := []struct {
+		rule string
+		want bool
+	}{
+		{"PathPrefix(`/`)", true},
+		{"PathPrefix(`/`) && Method(`GET`)", true},
+		{"PathPrefix(`/lab1`) || PathPrefix(`/`)", true},
+		{"PathPrefix(`/`) || Host(`example.com`)", true},
+		{"PathPrefix(`/`) || Method(`GET`)", true},
+		{"PathPrefix(`/lab1`) || Method(`GET`)", true},
+		{"Method(`GET`)", true},
+		{"PathRegexp(`.*`)", true},
+		{"Header(`X-Tenant`, `acme`)", true},
+		{"Host(`example.com`) || Host(`www.example.com`)", false},
+		{"Host(`example.com`) && PathRegexp(`.*`)", false},
+		{"Host(`example.com`) && PathPrefix(`/`)", false},
+		{"Path(`/`)", false},
+		{"PathPrefix(`/lab1`)", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := catchAllRule(tt.rule); got != tt.want {
+			t.Errorf("catchAllRule(%q) = %v, want %v", tt.rule, got, tt.want)
+		}
+	}
+}
+
+func TestBuild_CatchAllGuard(t *testing.T) {
+	provider, err := newProvider(&Config{
+		ProjectIDs:     []string{"test-project"},
+		Region:         "us-central1",
+		TokenInjection: TokenInjectionPlugin,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	config, err := provider.Build([]CloudRunService{
+		{Name: "home-index-stg", ProjectID: "test-project", URL: "https://home-index.run.app", Labels: map[string]string{
+			"traefik_enable": "true", "traefik_http_routers_home-index_rule_id": "home-index-root",
+		}},
+		{Name: "mislabeled", ProjectID: "test-project", URL: "https://mislabeled.run.app", Labels: map[string]string{
+			"traefik_enable": "true", "traefik_http_routers_mislabeled_rule_id": "home-index-root",
+		}},
+		{Name: "partial", ProjectID: "test-project", URL: "https://partial.run.app", Labels: map[string]string{
+			"traefik_enable":                    "true",
+			"traefik_http_routers_partial_rule": "PathPrefix(`/partial`)",
+			"traefik_http_routers_root_rule":    "PathPrefix(`/`)",
+		}},
+		{Name: "landing", ProjectID: "test-project", URL: "https://landing.run.app", Labels: map[string]string{
+			"traefik_enable": "true", "traefik_http_routers_landing_rule": "PathPrefix(`/`)",
+			"traefik_allow_catch_all": "true",
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for _, name := range []string{"home-index", "partial", "landing"} {
+		if _, ok := config.HTTP.Routers[name]; !ok {
+			t.Errorf("Expected router %s", name)
+		}
+	}
+	for _, name := range []string{"mislabeled", "root"} {
+		if _, ok := config.HTTP.Routers[name]; ok {
+			t.Errorf("Expected catch-all router %s to be dropped", name)
+		}
+	}
+	want := map[string]bool{"mislabeled": false, "partial": true}
+	skipped := config.Skipped()
+	if len(skipped) != len(want) {
+		t.Fatalf("Expected %d skipped services, got %+v", len(want), skipped)
+	}
+	for _, s := range skipped {
+		if degraded, ok := want[s.Service]; !ok || s.Reason != SkipReasonCatchAll || s.Degraded != degraded {
+			t.Errorf("Unexpected skipped service %+v", s)
+		}
+	}
+}
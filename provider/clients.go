@@ -0,0 +1,50 @@
+package provider
+
+import (
+	run "google.golang.org/api/run/v1"
+)
+
+// CloudRunClient lists Cloud Run services. The default implementation wraps
+// the Cloud Run Admin API; tests can substitute a fake.
+//
+// Everything the provider reads (labels, annotations, the serving revision,
+// scaling bounds and request timeout) comes from the List response, so there
+// are no per-service Get calls. A feature that needs one should cache it by
+// metadata.generation rather than add a call per service per poll.
+type CloudRunClient interface {
+	// ListServices returns one page of services under parent
+	// ("projects/<id>/locations/<region>"); pageToken is empty for the first page
+	ListServices(parent, pageToken string) (*run.ListServicesResponse, error)
+}
+
+// TokenSource provides identity tokens for Cloud Run service URLs.
+// *gcp.TokenManager is the default implementation.
+type TokenSource interface {
+	GetToken(audience string) (string, error)
+}
+
+// AccessTokenSource is implemented by token sources that can also mint OAuth
+// access tokens for the given scopes, for services labeled
+// traefik_auth_type=access_token. *gcp.TokenManager implements it.
+type AccessTokenSource interface {
+	GetScopedAccessToken(scopes ...string) (string, error)
+}
+
+// apiClient is the CloudRunClient backed by the Cloud Run Admin API
+type apiClient struct {
+	runService *run.APIService
+}
+
+// NewCloudRunClient wraps a Cloud Run Admin API service as a CloudRunClient
+func NewCloudRunClient(runService *run.APIService) CloudRunClient {
+	return &apiClient{runService: runService}
+}
+
+// ListServices lists one page of services using the Cloud Run Admin API
+func (c *apiClient) ListServices(parent, pageToken string) (*run.ListServicesResponse, error) {
+	call := c.runService.Projects.Locations.Services.List(parent)
+	if pageToken != "" {
+		call = call.Continue(pageToken)
+	}
+	return call.Do()
+}
@@ -0,0 +1,322 @@
+package provider
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	run "google.golang.org/api/run/v1"
+)
+
+// fakeCloudRunClient serves canned List responses per parent
+type fakeCloudRunClient struct {
+	services map[string][]*run.Service
+	err      error
+	calls    int
+}
+
+func (c *fakeCloudRunClient) ListServices(parent, _ string) (*run.ListServicesResponse, error) {
+	c.calls++
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &run.ListServicesResponse{Items: c.services[parent]}, nil
+}
+
+// fakeTokenSource returns a fixed token (or error) for every audience
+type fakeTokenSource struct {
+	token string
+	err   error
+}
+
+func (s *fakeTokenSource) GetToken(string) (string, error) {
+	return s.token, s.err
+}
+
+// fakeAccessTokenSource also mints access tokens, recording their scopes
+type fakeAccessTokenSource struct {
+	fakeTokenSource
+	accessToken string
+	scopes      []string
+}
+
+func (s *fakeAccessTokenSource) GetScopedAccessToken(scopes ...string) (string, error) {
+	s.scopes = scopes
+	return s.accessToken, nil
+}
+
+func newFakeService(name, url string, labels map[string]string) *run.Service {
+	return &run.Service{
+		Metadata: &run.ObjectMeta{Name: name, Labels: labels},
+		Status:   &run.ServiceStatus{Url: url},
+	}
+}
+
+func TestNewWithClients_Discovery(t *testing.T) {
+	client := &fakeCloudRunClient{services: map[string][]*run.Service{
+		"projects/test-project/locations/us-central1": {
+			newFakeService("lab1", "https://lab1.run.app", map[string]string{
+				"traefik_enable":                 "true",
+				"traefik_http_routers_lab1_rule": "PathPrefix(`/lab1`)",
+			}),
+			newFakeService("unlabelled", "https://unlabelled.run.app", nil),
+		},
+	}}
+
+	p, err := NewWithClients(&Config{
+		ProjectIDs: []string{"test-project"},
+		Region:     "us-central1",
+	}, client, &fakeTokenSource{token: "eyJfake"}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	configChan := make(chan *DynamicConfig, 1)
+	if err := p.RunOnce(configChan); err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+	config := <-configChan
+
+	if client.calls != 1 {
+		t.Errorf("Expected 1 List call, got %d", client.calls)
+	}
+	router, ok := config.HTTP.Routers["lab1"]
+	if !ok {
+		t.Fatal("Expected lab1 router")
+	}
+	if len(router.Middlewares) == 0 || router.Middlewares[0] != "lab1-auth" {
+		t.Errorf("Expected lab1-auth middleware first, got %v", router.Middlewares)
+	}
+	if mw := config.HTTP.Middlewares["lab1-auth"]; mw.Headers == nil ||
+		mw.Headers.CustomRequestHeaders["X-Serverless-Authorization"] != "Bearer eyJfake" {
+		t.Errorf("Expected auth middleware with injected token, got %+v", mw)
+	}
+	if _, ok := config.HTTP.Services["unlabelled"]; ok {
+		t.Error("Expected service without traefik_enable to be ignored")
+	}
+}
+
+func TestNewWithClients_TokenError(t *testing.T) {
+	p, err := NewWithClients(&Config{
+		ProjectIDs:         []string{"test-project"},
+		Region:             "us-central1",
+		TokenFailurePolicy: TokenFailureSkipRoute,
+	}, &fakeCloudRunClient{}, &fakeTokenSource{err: ErrMetadataUnavailable}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	err = p.processService(CloudRunService{
+		Name:   "lab1",
+		URL:    "https://lab1.run.app",
+		Labels: map[string]string{"traefik_http_routers_lab1_rule": "PathPrefix(`/lab1`)"},
+	}, NewDynamicConfig())
+	if !errors.Is(err, ErrMetadataUnavailable) {
+		t.Errorf("Expected ErrMetadataUnavailable, got: %v", err)
+	}
+}
+
+func TestNewWithClients_Generation(t *testing.T) {
+	var logs bytes.Buffer
+	p, err := NewWithClients(&Config{
+		ProjectIDs: []string{"test-project"},
+		Region:     "us-central1",
+		LogOutput:  &logs,
+	}, &fakeCloudRunClient{}, &fakeTokenSource{token: "eyJfake"}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	configChan := make(chan *DynamicConfig, 1)
+	var ids []uint64
+	for i := 0; i < 2; i++ {
+		if err := p.RunOnce(configChan); err != nil {
+			t.Fatalf("RunOnce failed: %v", err)
+		}
+		config := <-configChan
+		if config.Generation() != p.LastReport().Generation {
+			t.Errorf("Expected config and report of the same generation, got %d and %d", config.Generation(), p.LastReport().Generation)
+		}
+		ids = append(ids, config.Generation())
+	}
+	if ids[0] == 0 || ids[1] != ids[0]+1 {
+		t.Errorf("Expected increasing generation IDs, got %v", ids)
+	}
+	if !strings.Contains(logs.String(), fmt.Sprintf("generation=%d", ids[1])) {
+		t.Errorf("Expected log lines tagged with the generation, got: %s", logs.String())
+	}
+}
+
+func TestNewWithClients_ListError(t *testing.T) {
+	p, err := NewWithClients(&Config{
+		ProjectIDs: []string{"test-project"},
+		Region:     "us-central1",
+	}, &fakeCloudRunClient{err: errors.New("API disabled")}, &fakeTokenSource{token: "eyJfake"}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	configChan := make(chan *DynamicConfig, 1)
+	if err := p.RunOnce(configChan); err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+	config := <-configChan
+	if _, ok := config.HTTP.Services["lab1"]; ok {
+		t.Error("Expected no services when listing fails")
+	}
+}
+
+func TestNewWithClients_AccessToken(t *testing.T) {
+	service := CloudRunService{
+		Name: "api",
+		URL:  "https://api.run.app",
+		Labels: map[string]string{
+			"traefik_auth_type":             "access_token",
+			"traefik_http_routers_api_rule": "PathPrefix(`/api`)",
+		},
+	}
+	config := &Config{ProjectIDs: []string{"test-project"}, Region: "us-central1", AccessTokenServices: []string{"ap*"}}
+
+	tokens := &fakeAccessTokenSource{fakeTokenSource: fakeTokenSource{token: "eyJfake"}, accessToken: "ya29.fake"}
+	p, err := NewWithClients(config, &fakeCloudRunClient{}, tokens, nil)
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	dynamicConfig := NewDynamicConfig()
+	if err := p.processService(service, dynamicConfig); err != nil {
+		t.Fatalf("processService failed: %v", err)
+	}
+	mw := dynamicConfig.HTTP.Middlewares["api-auth"]
+	if mw.Headers == nil || mw.Headers.CustomRequestHeaders["Authorization"] != "Bearer ya29.fake" ||
+		mw.Headers.CustomRequestHeaders["X-Serverless-Authorization"] != "" {
+		t.Errorf("Expected access token in Authorization header, got %+v", mw.Headers)
+	}
+	if len(tokens.scopes) != 1 || tokens.scopes[0] != DefaultAccessTokenScopes[0] {
+		t.Errorf("Expected the default access token scopes, got %v", tokens.scopes)
+	}
+
+	// A token source without access tokens fails like a token fetch error
+	config.TokenFailurePolicy = TokenFailureSkipRoute
+	p, err = NewWithClients(config, &fakeCloudRunClient{}, &fakeTokenSource{token: "eyJfake"}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	err = p.processService(service, NewDynamicConfig())
+	if !errors.Is(err, ErrAccessTokensUnsupported) {
+		t.Errorf("Expected ErrAccessTokensUnsupported, got: %v", err)
+	}
+
+	// Plugin mode emits a plugin middleware with the access token auth type
+	config.TokenInjection = TokenInjectionPlugin
+	p, err = NewWithClients(config, &fakeCloudRunClient{}, &fakeTokenSource{}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	dynamicConfig = NewDynamicConfig()
+	if err := p.processService(service, dynamicConfig); err != nil {
+		t.Fatalf("processService failed: %v", err)
+	}
+	plugin := dynamicConfig.HTTP.Middlewares["api-auth"].Plugin[DefaultTokenPluginName]
+	if plugin["authType"] != AuthTypeAccessToken || plugin["headerName"] != "Authorization" {
+		t.Errorf("Expected access token plugin middleware, got %v", plugin)
+	}
+	if scopes, _ := plugin["scopes"].([]string); len(scopes) != 1 || scopes[0] != DefaultAccessTokenScopes[0] {
+		t.Errorf("Expected the access token scopes in the plugin middleware, got %v", plugin["scopes"])
+	}
+}
+
+func TestNewWithClients_AccessTokenRefused(t *testing.T) {
+	service := CloudRunService{
+		Name:      "api",
+		ProjectID: "test-project",
+		URL:       "https://api.run.app",
+		Labels: map[string]string{
+			"traefik_auth_type":             "access_token",
+			"traefik_http_routers_api_rule": "PathPrefix(`/api`)",
+		},
+	}
+
+	for _, injection := range []string{TokenInjectionStatic, TokenInjectionPlugin} {
+		for _, allowed := range [][]string{nil, {"other"}, {"other-project/*"}} {
+			tokens := &fakeAccessTokenSource{fakeTokenSource: fakeTokenSource{token: "eyJfake"}, accessToken: "ya29.fake"}
+			config := &Config{ProjectIDs: []string{"test-project"}, Region: "us-central1",
+				TokenInjection: injection, AccessTokenServices: allowed}
+			p, err := NewWithClients(config, &fakeCloudRunClient{}, tokens, nil)
+			if err != nil {
+				t.Fatalf("Failed to create provider: %v", err)
+			}
+			dynamicConfig := NewDynamicConfig()
+			if err := p.processService(service, dynamicConfig); err != nil {
+				t.Fatalf("processService failed: %v", err)
+			}
+			if mw, ok := dynamicConfig.HTTP.Middlewares["api-auth"]; ok {
+				t.Errorf("%s, %v: expected no auth middleware for a refused access token, got %+v", injection, allowed, mw)
+			}
+			if tokens.scopes != nil {
+				t.Errorf("%s, %v: expected no access token fetch, got scopes %v", injection, allowed, tokens.scopes)
+			}
+			if skipped := dynamicConfig.Skipped(); len(skipped) != 1 || !strings.Contains(skipped[0].Detail, "ACCESS_TOKEN_SERVICES") {
+				t.Errorf("%s, %v: expected the refusal in the skipped services, got %+v", injection, allowed, skipped)
+			}
+		}
+	}
+
+	// Allowed as <project>/<service>
+	config := &Config{ProjectIDs: []string{"test-project"}, Region: "us-central1",
+		AccessTokenServices: []string{"test-project/*"}, TokenFailurePolicy: TokenFailureSkipRoute}
+	p, err := NewWithClients(config, &fakeCloudRunClient{},
+		&fakeAccessTokenSource{fakeTokenSource: fakeTokenSource{token: "eyJfake"}, accessToken: "ya29.fake"}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	if err := p.processService(service, NewDynamicConfig()); err != nil {
+		t.Errorf("Expected access token for a service allowed by project, got: %v", err)
+	}
+
+	// skip-route leaves a refused service out
+	config.AccessTokenServices = nil
+	p, err = NewWithClients(config, &fakeCloudRunClient{}, &fakeTokenSource{token: "eyJfake"}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	if err := p.processService(service, NewDynamicConfig()); !errors.Is(err, ErrAccessTokenNotAllowed) {
+		t.Errorf("Expected ErrAccessTokenNotAllowed, got: %v", err)
+	}
+}
+
+func TestNewWithClients_FailGenerationNotCountedByBreaker(t *testing.T) {
+	client := &fakeCloudRunClient{services: map[string][]*run.Service{
+		"projects/test-project/locations/us-central1": {
+			newFakeService("lab1", "https://lab1.run.app", map[string]string{
+				"traefik_enable":                 "true",
+				"traefik_http_routers_lab1_rule": "PathPrefix(`/lab1`)",
+			}),
+		},
+	}}
+	p, err := NewWithClients(&Config{
+		ProjectIDs:         []string{"test-project"},
+		Region:             "us-central1",
+		TokenFailurePolicy: TokenFailureFailGeneration,
+		BreakerThreshold:   1,
+		BreakerCooldown:    time.Hour,
+	}, client, &fakeTokenSource{err: ErrMetadataUnavailable}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	// Every cycle aborts: an open breaker would skip lab1 and publish without it
+	configChan := make(chan *DynamicConfig, 1)
+	for i := 0; i < 3; i++ {
+		if err := p.RunOnce(configChan); !errors.Is(err, ErrMetadataUnavailable) {
+			t.Fatalf("Cycle %d: expected the generation to abort, got %v", i, err)
+		}
+		select {
+		case config := <-configChan:
+			t.Fatalf("Cycle %d: expected nothing published, got routers %v", i, config.HTTP.Routers)
+		default:
+		}
+	}
+}
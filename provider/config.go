@@ -2,13 +2,25 @@ package provider
 
 import (
 	"fmt"
+	"os"
 	"strings"
+
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/internal/redact"
 )
 
 // DynamicConfig represents the Traefik dynamic configuration
 type DynamicConfig struct {
 	HTTP          HTTPConfig        `yaml:"http"`
 	routerSources map[string]string `yaml:"-"` // Internal: tracks which service defined each router (not serialized)
+	skipped       []SkippedService  `yaml:"-"` // Internal: services skipped or degraded during generation (not serialized)
+	deprecations  []Deprecation     `yaml:"-"` // Internal: deprecated label forms the services used
+	ties          []PriorityTie     `yaml:"-"` // Internal: routers sharing a priority with overlapping rules
+	envAffixes    []string          `yaml:"-"` // Internal: environment suffixes/prefixes stripped by isDedicatedService (default DefaultEnvironmentSuffixes)
+
+	generation     uint64         `yaml:"-"` // Internal: ID of the generation cycle that built it (0 = not generated by a provider cycle)
+	conflictPolicy string         `yaml:"-"` // Internal: router conflict policy (default dedicated-wins)
+	redactor       *redact.Policy `yaml:"-"` // Internal: redaction of logged credentials and skip details (default redact.Default)
+	conflictErr    error          `yaml:"-"` // Internal: first conflict under the "error" policy
 }
 
 // HTTPConfig represents HTTP-level configuration
@@ -16,12 +28,174 @@ type HTTPConfig struct {
 	Routers     map[string]RouterConfig     `yaml:"routers,omitempty"`
 	Services    map[string]ServiceConfig    `yaml:"services,omitempty"`
 	Middlewares map[string]MiddlewareConfig `yaml:"middlewares,omitempty"`
+
+	ServersTransports map[string]ServersTransportConfig `yaml:"serversTransports,omitempty"`
+}
+
+// ServersTransportConfig represents the connection settings Traefik uses to
+// reach a service's servers
+type ServersTransportConfig struct {
+	ServerName         string                    `yaml:"serverName,omitempty"`
+	DisableHTTP2       bool                      `yaml:"disableHTTP2,omitempty"`
+	ForwardingTimeouts *ForwardingTimeoutsConfig `yaml:"forwardingTimeouts,omitempty"`
+}
+
+// ForwardingTimeoutsConfig represents the timeouts of requests to a
+// service's servers (durations such as "60s")
+type ForwardingTimeoutsConfig struct {
+	DialTimeout           string `yaml:"dialTimeout,omitempty"`
+	ResponseHeaderTimeout string `yaml:"responseHeaderTimeout,omitempty"`
+	IdleConnTimeout       string `yaml:"idleConnTimeout,omitempty"`
 }
 
 // MiddlewareConfig represents a Traefik middleware configuration
 type MiddlewareConfig struct {
-	Headers     *HeadersConfig     `yaml:"headers,omitempty"`
-	ForwardAuth *ForwardAuthConfig `yaml:"forwardAuth,omitempty"`
+	Headers          *HeadersConfig                    `yaml:"headers,omitempty"`
+	ForwardAuth      *ForwardAuthConfig                `yaml:"forwardAuth,omitempty"`
+	Chain            *ChainConfig                      `yaml:"chain,omitempty"`
+	IPAllowList      *IPAllowListConfig                `yaml:"ipAllowList,omitempty"`
+	IPWhiteList      *IPAllowListConfig                `yaml:"ipWhiteList,omitempty"` // Traefik v2 name of ipAllowList
+	BasicAuth        *BasicAuthConfig                  `yaml:"basicAuth,omitempty"`
+	StripPrefix      *StripPrefixConfig                `yaml:"stripPrefix,omitempty"`
+	AddPrefix        *AddPrefixConfig                  `yaml:"addPrefix,omitempty"`
+	ReplacePath      *ReplacePathConfig                `yaml:"replacePath,omitempty"`
+	ReplacePathRegex *ReplacePathRegexConfig           `yaml:"replacePathRegex,omitempty"`
+	RedirectScheme   *RedirectSchemeConfig             `yaml:"redirectScheme,omitempty"`
+	RedirectRegex    *RedirectRegexConfig              `yaml:"redirectRegex,omitempty"`
+	RateLimit        *RateLimitConfig                  `yaml:"rateLimit,omitempty"`
+	Retry            *RetryConfig                      `yaml:"retry,omitempty"`
+	CircuitBreaker   *CircuitBreakerConfig             `yaml:"circuitBreaker,omitempty"`
+	Compress         *CompressConfig                   `yaml:"compress,omitempty"`
+	Buffering        *BufferingConfig                  `yaml:"buffering,omitempty"`
+	Errors           *ErrorsConfig                     `yaml:"errors,omitempty"`
+	Plugin           map[string]map[string]interface{} `yaml:"plugin,omitempty"`
+}
+
+// IPAllowListConfig represents an ipAllowList (v2: ipWhiteList) middleware:
+// only clients in SourceRange are let through
+type IPAllowListConfig struct {
+	SourceRange []string `yaml:"sourceRange"`
+}
+
+// BasicAuthConfig represents a basicAuth middleware: clients must send the
+// credentials of one of Users (htpasswd user:hash entries)
+type BasicAuthConfig struct {
+	Users []string `yaml:"users"`
+}
+
+// StripPrefixConfig represents a stripPrefix middleware: Prefixes are
+// removed from the request path
+type StripPrefixConfig struct {
+	Prefixes   []string `yaml:"prefixes"`
+	ForceSlash bool     `yaml:"forceSlash,omitempty"` // Deprecated in Traefik v3
+}
+
+// AddPrefixConfig represents an addPrefix middleware: Prefix is prepended
+// to the request path
+type AddPrefixConfig struct {
+	Prefix string `yaml:"prefix"`
+}
+
+// ReplacePathConfig represents a replacePath middleware: the request path
+// is replaced with Path
+type ReplacePathConfig struct {
+	Path string `yaml:"path"`
+}
+
+// ReplacePathRegexConfig represents a replacePathRegex middleware: a path
+// matching Regex is rewritten to Replacement (which may use $1 etc.)
+type ReplacePathRegexConfig struct {
+	Regex       string `yaml:"regex"`
+	Replacement string `yaml:"replacement"`
+}
+
+// RedirectSchemeConfig represents a redirectScheme middleware, e.g. to
+// redirect http to https
+type RedirectSchemeConfig struct {
+	Scheme    string `yaml:"scheme"`
+	Port      string `yaml:"port,omitempty"`
+	Permanent bool   `yaml:"permanent,omitempty"`
+}
+
+// RedirectRegexConfig represents a redirectRegex middleware: URLs matching
+// Regex are redirected to Replacement
+type RedirectRegexConfig struct {
+	Regex       string `yaml:"regex"`
+	Replacement string `yaml:"replacement"`
+	Permanent   bool   `yaml:"permanent,omitempty"`
+}
+
+// RateLimitConfig represents a rateLimit middleware: Average requests per
+// Period (default 1s) with bursts of up to Burst, per source
+type RateLimitConfig struct {
+	Average         int64                  `yaml:"average,omitempty"`
+	Period          string                 `yaml:"period,omitempty"`
+	Burst           int64                  `yaml:"burst,omitempty"`
+	SourceCriterion *SourceCriterionConfig `yaml:"sourceCriterion,omitempty"`
+}
+
+// SourceCriterionConfig selects what a rateLimit counts requests by: the
+// client IP (the default, optionally taken from X-Forwarded-For with
+// IPStrategy), a request header or the request host
+type SourceCriterionConfig struct {
+	IPStrategy        *IPStrategyConfig `yaml:"ipStrategy,omitempty"`
+	RequestHeaderName string            `yaml:"requestHeaderName,omitempty"`
+	RequestHost       bool              `yaml:"requestHost,omitempty"`
+}
+
+// IPStrategyConfig selects the client IP from X-Forwarded-For: the Depth-th
+// address from the right, or the first one not in ExcludedIPs
+type IPStrategyConfig struct {
+	Depth       int      `yaml:"depth,omitempty"`
+	ExcludedIPs []string `yaml:"excludedIPs,omitempty"`
+}
+
+// RetryConfig represents a retry middleware: failed requests (network
+// errors, not HTTP error statuses) are retried up to Attempts times
+type RetryConfig struct {
+	Attempts        int    `yaml:"attempts"`
+	InitialInterval string `yaml:"initialInterval,omitempty"`
+}
+
+// CircuitBreakerConfig represents a circuitBreaker middleware: requests fail
+// fast while Expression (e.g. "NetworkErrorRatio() > 0.5") holds
+type CircuitBreakerConfig struct {
+	Expression       string `yaml:"expression"`
+	CheckPeriod      string `yaml:"checkPeriod,omitempty"`
+	FallbackDuration string `yaml:"fallbackDuration,omitempty"`
+	RecoveryDuration string `yaml:"recoveryDuration,omitempty"`
+}
+
+// CompressConfig represents a compress middleware. An empty one compresses
+// every compressible response.
+type CompressConfig struct {
+	ExcludedContentTypes []string `yaml:"excludedContentTypes,omitempty"`
+	MinResponseBodyBytes int      `yaml:"minResponseBodyBytes,omitempty"`
+}
+
+// BufferingConfig represents a buffering middleware: request and response
+// bodies are read fully before being forwarded, with size limits in bytes
+type BufferingConfig struct {
+	MaxRequestBodyBytes  int64  `yaml:"maxRequestBodyBytes,omitempty"`
+	MemRequestBodyBytes  int64  `yaml:"memRequestBodyBytes,omitempty"`
+	MaxResponseBodyBytes int64  `yaml:"maxResponseBodyBytes,omitempty"`
+	MemResponseBodyBytes int64  `yaml:"memResponseBodyBytes,omitempty"`
+	RetryExpression      string `yaml:"retryExpression,omitempty"`
+}
+
+// ErrorsConfig represents an errors middleware: responses with a status in
+// Status (e.g. "502-503") are replaced by the page Service serves at Query,
+// where {status} stands for the original status code
+type ErrorsConfig struct {
+	Status  []string `yaml:"status"`
+	Service string   `yaml:"service"`
+	Query   string   `yaml:"query,omitempty"`
+}
+
+// ChainConfig represents a chain middleware: an ordered list of middlewares
+// that routers can reference as one
+type ChainConfig struct {
+	Middlewares []string `yaml:"middlewares"`
 }
 
 // ForwardAuthConfig represents forwardAuth middleware configuration
@@ -33,10 +207,22 @@ type ForwardAuthConfig struct {
 	AuthRequestHeaders  []string `yaml:"authRequestHeaders,omitempty"`
 }
 
-// HeadersConfig represents headers middleware configuration
+// HeadersConfig represents headers middleware configuration, including the
+// CORS options (see extractHeadersConfigs). An empty custom header value
+// removes the header.
 type HeadersConfig struct {
-	CustomRequestHeaders map[string]string       `yaml:"customRequestHeaders,omitempty"`
-	ForwardedHeaders     *ForwardedHeadersConfig `yaml:"forwardedHeaders,omitempty"`
+	CustomRequestHeaders  map[string]string       `yaml:"customRequestHeaders,omitempty"`
+	CustomResponseHeaders map[string]string       `yaml:"customResponseHeaders,omitempty"`
+	ForwardedHeaders      *ForwardedHeadersConfig `yaml:"forwardedHeaders,omitempty"`
+
+	AccessControlAllowCredentials     bool     `yaml:"accessControlAllowCredentials,omitempty"`
+	AccessControlAllowHeaders         []string `yaml:"accessControlAllowHeaders,omitempty"`
+	AccessControlAllowMethods         []string `yaml:"accessControlAllowMethods,omitempty"`
+	AccessControlAllowOriginList      []string `yaml:"accessControlAllowOriginList,omitempty"`
+	AccessControlAllowOriginListRegex []string `yaml:"accessControlAllowOriginListRegex,omitempty"`
+	AccessControlExposeHeaders        []string `yaml:"accessControlExposeHeaders,omitempty"`
+	AccessControlMaxAge               int64    `yaml:"accessControlMaxAge,omitempty"`
+	AddVaryHeader                     bool     `yaml:"addVaryHeader,omitempty"`
 }
 
 // ForwardedHeadersConfig represents forwarded headers configuration within Headers middleware
@@ -48,7 +234,7 @@ type ForwardedHeadersConfig struct {
 // NOTE: We intentionally do NOT implement MarshalYAML for HeadersConfig
 // The full tokens must be written to the routes.yml file for Traefik to use them.
 // Token sanitization should only be done for logging purposes, not for the actual config file.
-// See sanitizeHeadersForLogging() for log-safe token display.
+// See Sanitized() and internal/redact for log-safe token display.
 
 // NewDynamicConfig creates a new dynamic configuration
 func NewDynamicConfig() *DynamicConfig {
@@ -62,6 +248,24 @@ func NewDynamicConfig() *DynamicConfig {
 	}
 }
 
+// Generation returns the ID of the generation cycle that built the
+// configuration, as logged on every line of that cycle, or 0
+func (c *DynamicConfig) Generation() uint64 {
+	return c.generation
+}
+
+// Deprecations returns the deprecated label forms the configuration was
+// built from
+func (c *DynamicConfig) Deprecations() []Deprecation {
+	return c.deprecations
+}
+
+// PriorityTies returns the groups of routers with the same priority,
+// a shared entry point and overlapping rules
+func (c *DynamicConfig) PriorityTies() []PriorityTie {
+	return c.ties
+}
+
 // AddRouter adds a router to the configuration
 // If a router with the same name already exists, it will be replaced only if
 // the new source is a "dedicated" service for that router (e.g., lab1-c2-stg for lab1-c2 router)
@@ -70,40 +274,81 @@ func (c *DynamicConfig) AddRouter(name string, config RouterConfig) {
 }
 
 // AddRouterWithSource adds a router with source tracking for conflict resolution
-// sourceName is the Cloud Run service name that defines this router
+// sourceName is the Cloud Run service name that defines this router. When
+// another service already defined it, the router conflict policy decides
+// which one keeps it (see replacesRouter).
 func (c *DynamicConfig) AddRouterWithSource(name string, config RouterConfig, sourceName string) {
-	existingSource, exists := c.routerSources[name]
-
-	if exists {
-		// Check if the new source is more specific/dedicated for this router
-		// A dedicated service name contains the router name (e.g., "lab1-c2-stg" for "lab1-c2")
-		newIsDedicated := isDedicatedService(name, sourceName)
-		existingIsDedicated := isDedicatedService(name, existingSource)
-
-		// Only replace if:
-		// 1. New source is dedicated and existing is not, OR
-		// 2. Both are dedicated (or both are not) - last one wins
-		if existingIsDedicated && !newIsDedicated {
-			// Keep existing - it's from a dedicated service
+	if existingSource, exists := c.routerSources[name]; exists && sourceName != existingSource {
+		if !c.replacesRouter(name, config, sourceName, existingSource) {
+			c.skip(SkippedService{Service: sourceName, Reason: SkipReasonRouterConflict, Detail: name + " (kept from " + existingSource + ")"})
 			return
 		}
+		c.skip(SkippedService{Service: existingSource, Reason: SkipReasonRouterConflict, Detail: name + " (replaced by " + sourceName + ")"})
 	}
 
 	c.HTTP.Routers[name] = config
 	c.routerSources[name] = sourceName
 }
 
+// DefaultEnvironmentSuffixes are the environment suffixes stripped from
+// service names when matching them to routers
+var DefaultEnvironmentSuffixes = []string{"-stg", "-prd", "-dev", "-staging", "-production"}
+
+// environmentAffixes returns the environment suffixes and prefixes to strip
+// from service names: affixes if set, otherwise the defaults plus the
+// environment's own suffix (e.g. "-qa" for environment "qa"). Entries ending
+// in "-" are prefixes ("qa-"), others suffixes; a bare name ("sandbox") is
+// taken as a suffix.
+func environmentAffixes(environment string, affixes []string) []string {
+	var result []string
+	add := func(affix string) {
+		if !strings.HasPrefix(affix, "-") && !strings.HasSuffix(affix, "-") {
+			affix = "-" + affix
+		}
+		for _, existing := range result {
+			if existing == affix {
+				return
+			}
+		}
+		result = append(result, affix)
+	}
+	for _, affix := range affixes {
+		if affix = strings.TrimSpace(affix); affix != "" && affix != "-" {
+			add(affix)
+		}
+	}
+	if len(result) > 0 {
+		return result
+	}
+	for _, suffix := range DefaultEnvironmentSuffixes {
+		add(suffix)
+	}
+	if environment = strings.Trim(strings.TrimSpace(environment), "-"); environment != "" {
+		add(environment)
+	}
+	return result
+}
+
 // isDedicatedService checks if a Cloud Run service is dedicated to a specific router
 // e.g., "lab1-c2-stg" is dedicated to "lab1-c2" router
 // e.g., "lab-01-basic-magecart-stg" is NOT dedicated to "lab1-c2" router
-func isDedicatedService(routerName, serviceName string) bool {
+// affixes are the environment suffixes ("-stg") and prefixes ("stg-") to
+// strip from the service name (nil: DefaultEnvironmentSuffixes)
+func isDedicatedService(routerName, serviceName string, affixes []string) bool {
 	// Normalize router name: lab1-c2 -> lab1-c2
 	// Normalize service name: lab1-c2-stg -> lab1-c2, lab-01-basic-magecart-stg -> lab-01-basic-magecart
 
-	// Remove common suffixes like -stg, -prd, -dev
+	// Remove environment suffixes like -stg, -prd, -dev and prefixes like stg-
+	if affixes == nil {
+		affixes = DefaultEnvironmentSuffixes
+	}
 	normalizedService := serviceName
-	for _, suffix := range []string{"-stg", "-prd", "-dev", "-staging", "-production"} {
-		normalizedService = strings.TrimSuffix(normalizedService, suffix)
+	for _, affix := range affixes {
+		if strings.HasSuffix(affix, "-") {
+			normalizedService = strings.TrimPrefix(normalizedService, affix)
+		} else {
+			normalizedService = strings.TrimSuffix(normalizedService, affix)
+		}
 	}
 
 	// Check if the normalized service name matches or contains the router name
@@ -120,61 +365,22 @@ func isDedicatedService(routerName, serviceName string) bool {
 	return normalizedServiceNoHyphen == normalizedRouter
 }
 
+// AddMiddleware adds a middleware to the configuration
+func (c *DynamicConfig) AddMiddleware(name string, config MiddlewareConfig) {
+	c.HTTP.Middlewares[name] = config
+}
+
 // AddService adds a service to the configuration
 func (c *DynamicConfig) AddService(name string, config ServiceConfig) {
 	c.HTTP.Services[name] = config
 }
 
-// truncateToken truncates a token to show first 20 and last 20 characters for security
-func truncateToken(token string) string {
-	if len(token) <= 40 {
-		return token // Too short to truncate meaningfully
-	}
-	return token[:20] + "..." + token[len(token)-20:]
-}
-
-// sanitizeEmail sanitizes an email address to show only first 2 chars + "@" + domain
-// Example: "abraham@example.com" -> "ab@example.com"
-func sanitizeEmail(email string) string {
-	atIndex := strings.Index(email, "@")
-	if atIndex == -1 {
-		// Not a valid email format, return as-is
-		return email
-	}
-
-	localPart := email[:atIndex]
-	domain := email[atIndex+1:]
-
-	// Show first 2 characters of local part, or all if less than 2
-	if len(localPart) <= 2 {
-		return email // Too short to sanitize meaningfully
-	}
-
-	return localPart[:2] + "@" + domain
-}
-
-// sanitizeHeadersForLogging creates a copy of headers with sensitive values sanitized
-func sanitizeHeadersForLogging(headers map[string]string) map[string]string {
-	sanitized := make(map[string]string)
-	for k, v := range headers {
-		// Truncate tokens in Authorization and X-Serverless-Authorization headers
-		if k == "Authorization" || k == "X-Serverless-Authorization" {
-			if strings.HasPrefix(v, "Bearer ") {
-				token := strings.TrimPrefix(v, "Bearer ")
-				sanitized[k] = "Bearer " + truncateToken(token)
-			} else {
-				sanitized[k] = truncateToken(v)
-			}
-		} else if k == "X-User-Email" {
-			// Sanitize email: show first 2 chars + "@" + domain
-			// Example: "abraham@example.com" -> "ab@example.com"
-			sanitized[k] = sanitizeEmail(v)
-		} else {
-			// X-User-Id and other headers: show full value (no sanitization)
-			sanitized[k] = v
-		}
+// AddServersTransport adds a serversTransport to the configuration
+func (c *DynamicConfig) AddServersTransport(name string, config ServersTransportConfig) {
+	if c.HTTP.ServersTransports == nil {
+		c.HTTP.ServersTransports = make(map[string]ServersTransportConfig)
 	}
-	return sanitized
+	c.HTTP.ServersTransports[name] = config
 }
 
 // AddAuthMiddleware adds an authentication middleware with token
@@ -195,7 +401,7 @@ func (c *DynamicConfig) AddAuthMiddleware(name, token string) {
 	// Skip creating middleware if token is empty
 	// Empty headers: {} causes Traefik YAML parsing errors: "headers cannot be a standalone element"
 	if token == "" {
-		fmt.Printf("[ConfigBuilder] ⚠️  Skipping auth middleware '%s' (no token provided)\n", name)
+		fmt.Fprintf(os.Stderr, "[ConfigBuilder] ⚠️  Skipping auth middleware '%s' (no token provided)\n", name)
 		return
 	}
 
@@ -212,14 +418,93 @@ func (c *DynamicConfig) AddAuthMiddleware(name, token string) {
 	mw.Headers.CustomRequestHeaders["X-Serverless-Authorization"] = fmt.Sprintf("Bearer %s", token)
 
 	// Log successful middleware creation with token info (truncated for security)
-	tokenLen := len(token)
-	tokenPreview := truncateToken(token)
-	fmt.Printf("[ConfigBuilder] ✅ Created auth middleware '%s' with X-Serverless-Authorization header (token length: %d, preview: %s)\n",
-		name, tokenLen, tokenPreview)
+	fmt.Fprintf(os.Stderr, "[ConfigBuilder] ✅ Created auth middleware '%s' with X-Serverless-Authorization header (token length: %d, preview: %s)\n",
+		name, len(token), c.redaction().Token(token))
 
 	c.HTTP.Middlewares[name] = mw
 }
 
+// AddHeaderAuthMiddleware adds a headers middleware that sets header to
+// value, the credential an AuthProvider returned for a service. Identity
+// tokens go to X-Serverless-Authorization; other credentials, such as access
+// tokens, usually to Authorization, replacing the user's header.
+func (c *DynamicConfig) AddHeaderAuthMiddleware(name, header, value string) {
+	if value == "" {
+		fmt.Fprintf(os.Stderr, "[ConfigBuilder] ⚠️  Skipping auth middleware '%s' (no credential provided)\n", name)
+		return
+	}
+
+	c.HTTP.Middlewares[name] = MiddlewareConfig{
+		Headers: &HeadersConfig{
+			CustomRequestHeaders: map[string]string{header: value},
+		},
+	}
+
+	fmt.Fprintf(os.Stderr, "[ConfigBuilder] ✅ Created auth middleware '%s' with %s header (value length: %d, preview: %s)\n",
+		name, header, len(value), c.redaction().Header(header, value))
+}
+
+// AddTokenPluginMiddleware adds a middleware that uses the token middleware plugin
+// (see the middleware package) to inject a fresh identity token per request.
+// Unlike AddAuthMiddleware, no token is written to the config, so routes never
+// carry a stale token between polls.
+func (c *DynamicConfig) AddTokenPluginMiddleware(name, pluginName, audience string) {
+	if audience == "" {
+		fmt.Fprintf(os.Stderr, "[ConfigBuilder] ⚠️  Skipping token plugin middleware '%s' (no audience provided)\n", name)
+		return
+	}
+
+	c.HTTP.Middlewares[name] = MiddlewareConfig{
+		Plugin: map[string]map[string]interface{}{
+			pluginName: {
+				"audience": audience,
+			},
+		},
+	}
+
+	fmt.Fprintf(os.Stderr, "[ConfigBuilder] ✅ Created token plugin middleware '%s' (plugin: %s, audience: %s)\n",
+		name, pluginName, audience)
+}
+
+// AddAccessTokenPluginMiddleware adds a middleware that uses the token
+// middleware plugin to set the Authorization header to a fresh OAuth access
+// token for scopes per request
+func (c *DynamicConfig) AddAccessTokenPluginMiddleware(name, pluginName string, scopes []string) {
+	c.HTTP.Middlewares[name] = MiddlewareConfig{
+		Plugin: map[string]map[string]interface{}{
+			pluginName: {
+				"authType":   AuthTypeAccessToken,
+				"headerName": "Authorization",
+				"scopes":     scopes,
+			},
+		},
+	}
+
+	fmt.Fprintf(os.Stderr, "[ConfigBuilder] ✅ Created access token plugin middleware '%s' (plugin: %s)\n", name, pluginName)
+}
+
+// AddRouteTagMiddleware adds a headers middleware that sets header to the
+// router name, so backend logs and Traefik access logs can be joined by route
+func (c *DynamicConfig) AddRouteTagMiddleware(name, header, routerName string) {
+	c.HTTP.Middlewares[name] = MiddlewareConfig{
+		Headers: &HeadersConfig{
+			CustomRequestHeaders: map[string]string{header: routerName},
+		},
+	}
+}
+
+// AddRequestIDMiddleware adds a middleware that uses the token middleware
+// plugin to set header to a random ID on requests that don't carry one
+func (c *DynamicConfig) AddRequestIDMiddleware(name, pluginName, header string) {
+	c.HTTP.Middlewares[name] = MiddlewareConfig{
+		Plugin: map[string]map[string]interface{}{
+			pluginName: {
+				"requestIDHeader": header,
+			},
+		},
+	}
+}
+
 // GetSanitizedMiddlewareForLogging returns a sanitized version of a middleware for logging
 // This truncates tokens in headers to prevent full tokens from appearing in logs
 func (c *DynamicConfig) GetSanitizedMiddlewareForLogging(name string) *MiddlewareConfig {
@@ -232,33 +517,152 @@ func (c *DynamicConfig) GetSanitizedMiddlewareForLogging(name string) *Middlewar
 	sanitized := &MiddlewareConfig{}
 	if mw.Headers != nil {
 		sanitized.Headers = &HeadersConfig{
-			CustomRequestHeaders: sanitizeHeadersForLogging(mw.Headers.CustomRequestHeaders),
+			CustomRequestHeaders: c.redaction().Headers(mw.Headers.CustomRequestHeaders),
 		}
 	}
 
 	return sanitized
 }
 
-// AddTraefikInternalRouters adds Traefik API and Dashboard routers
+// redaction returns the configuration's redaction policy
+func (c *DynamicConfig) redaction() *redact.Policy {
+	if c.redactor == nil {
+		return redact.Default
+	}
+	return c.redactor
+}
+
+// Sanitized returns a copy of the configuration with the credentials and
+// email addresses in headers middlewares redacted by the provider's
+// redaction policy, safe to paste into a ticket. Traefik can't use it.
+func (c *DynamicConfig) Sanitized() *DynamicConfig {
+	sanitized := *c
+	sanitized.HTTP.Middlewares = make(map[string]MiddlewareConfig, len(c.HTTP.Middlewares))
+	for name, mw := range c.HTTP.Middlewares {
+		if mw.Headers != nil {
+			headers := *mw.Headers
+			headers.CustomRequestHeaders = c.redaction().Headers(mw.Headers.CustomRequestHeaders)
+			mw.Headers = &headers
+		}
+		sanitized.HTTP.Middlewares[name] = mw
+	}
+	return &sanitized
+}
+
+// WithoutCredentials returns a copy of the configuration with the values of
+// credential headers in headers middlewares (auth tokens) replaced by a
+// placeholder, so configurations can be compared across token refreshes.
+// Traefik can't use it.
+func (c *DynamicConfig) WithoutCredentials() *DynamicConfig {
+	stripped := *c
+	stripped.HTTP.Middlewares = make(map[string]MiddlewareConfig, len(c.HTTP.Middlewares))
+	for name, mw := range c.HTTP.Middlewares {
+		if mw.Headers != nil {
+			headers := *mw.Headers
+			headers.CustomRequestHeaders = make(map[string]string, len(mw.Headers.CustomRequestHeaders))
+			for header, value := range mw.Headers.CustomRequestHeaders {
+				if c.redaction().CredentialHeader(header) {
+					value = redact.Redacted
+				}
+				headers.CustomRequestHeaders[header] = value
+			}
+			mw.Headers = &headers
+		}
+		stripped.HTTP.Middlewares[name] = mw
+	}
+	return &stripped
+}
+
+// AddTraefikInternalRouters adds unrestricted Traefik API and Dashboard
+// routers on the web entrypoint. See AddDashboardRouters to configure them.
 func (c *DynamicConfig) AddTraefikInternalRouters() {
-	// Traefik API
-	c.HTTP.Routers["traefik-api"] = RouterConfig{
-		Rule:        "PathPrefix(`/api/http`) || PathPrefix(`/api/rawdata`) || PathPrefix(`/api/overview`) || Path(`/api/version`)",
-		Service:     "api@internal",
-		Priority:    1000,
-		EntryPoints: []string{"web"},
+	c.AddDashboardRouters(DashboardConfig{Enabled: true})
+}
+
+// UserAuthConfig configures the forwardAuth middlewares generated when
+// USER_AUTH_ENABLED=true. Zero-value fields fall back to DefaultUserAuthConfig.
+type UserAuthConfig struct {
+	MiddlewareNames     []string // Names of the forwardAuth middlewares routers reference
+	CheckBaseURL        string   // Base URL the auth check is sent to (Traefik itself by default)
+	CheckPath           string   // Path of the auth check endpoint
+	AuthResponseHeaders []string // Headers copied from the auth response to the request
+	AuthRequestHeaders  []string // Headers forwarded to the auth check
+}
+
+// DefaultUserAuthConfig returns the user auth settings used by e-skimming-labs
+func DefaultUserAuthConfig() UserAuthConfig {
+	return UserAuthConfig{
+		MiddlewareNames: []string{
+			"lab1-auth-check",
+			"lab2-auth-check",
+			"lab3-auth-check",
+			"lab4-auth-check",
+		},
+		CheckBaseURL: "http://localhost:8080",
+		CheckPath:    "/api/auth/check",
+		AuthResponseHeaders: []string{
+			"X-User-Id",
+			"X-User-Email",
+			"X-Authorization",
+		},
+		// Authorization: Firebase Bearer token. Cookie: firebase_token fallback.
+		// X-Forwarded-Uri is intentionally omitted - ForwardAuth auto-sets it to the original
+		// request URI; listing it here would copy empty from the browser request and overwrite.
+		AuthRequestHeaders: []string{
+			"Authorization",
+			"Cookie",
+			"X-Forwarded-For",
+			"X-Forwarded-Host",
+		},
 	}
+}
 
-	// Traefik Dashboard
-	c.HTTP.Routers["traefik-dashboard"] = RouterConfig{
-		Rule:        "PathPrefix(`/dashboard`)",
-		Service:     "api@internal",
-		Priority:    1000,
-		EntryPoints: []string{"web"},
+// withDefaults fills unset fields from DefaultUserAuthConfig
+func (u UserAuthConfig) withDefaults() UserAuthConfig {
+	defaults := DefaultUserAuthConfig()
+	if len(u.MiddlewareNames) == 0 {
+		u.MiddlewareNames = defaults.MiddlewareNames
 	}
+	if u.CheckBaseURL == "" {
+		u.CheckBaseURL = defaults.CheckBaseURL
+	}
+	if u.CheckPath == "" {
+		u.CheckPath = defaults.CheckPath
+	}
+	if len(u.AuthResponseHeaders) == 0 {
+		u.AuthResponseHeaders = defaults.AuthResponseHeaders
+	}
+	if len(u.AuthRequestHeaders) == 0 {
+		u.AuthRequestHeaders = defaults.AuthRequestHeaders
+	}
+	return u
+}
+
+// isUserAuthMiddleware reports whether a router middleware is one of the
+// user auth-check middlewares (with or without a provider suffix)
+func (u UserAuthConfig) isUserAuthMiddleware(mw string) bool {
+	if strings.Contains(mw, "auth-check") {
+		return true
+	}
+	name := mw
+	if at := strings.Index(mw, "@"); at != -1 {
+		name = mw[:at]
+	}
+	for _, n := range u.MiddlewareNames {
+		if n == name {
+			return true
+		}
+	}
+	return false
 }
 
 // AddForwardAuthMiddleware adds a forwardAuth middleware for user JWT validation
+// using DefaultUserAuthConfig. See AddUserAuthMiddleware.
+func (c *DynamicConfig) AddForwardAuthMiddleware(name, homeIndexURL string) {
+	c.AddUserAuthMiddleware(name, homeIndexURL, DefaultUserAuthConfig())
+}
+
+// AddUserAuthMiddleware adds a forwardAuth middleware for user JWT validation
 // This middleware forwards auth checks to the home-index service via Traefik's own router.
 //
 // Why localhost:8080 instead of the Cloud Run URL directly?
@@ -272,39 +676,29 @@ func (c *DynamicConfig) AddTraefikInternalRouters() {
 // automatically sets it to the original request's URI (/lab2, /lab3, etc.). Adding it to
 // authRequestHeaders would copy an empty value from the browser request, overwriting the
 // correctly auto-set value and breaking the post-login redirect target.
-func (c *DynamicConfig) AddForwardAuthMiddleware(name, homeIndexURL string) {
+func (c *DynamicConfig) AddUserAuthMiddleware(name, homeIndexURL string, userAuth UserAuthConfig) {
 	if homeIndexURL == "" {
-		fmt.Printf("[ConfigBuilder] ⚠️  Skipping forwardAuth middleware '%s' (no home-index URL provided)\n", name)
+		fmt.Fprintf(os.Stderr, "[ConfigBuilder] ⚠️  Skipping forwardAuth middleware '%s' (no home-index URL provided)\n", name)
 		return
 	}
 
+	userAuth = userAuth.withDefaults()
+
 	// Route auth check through Traefik itself (localhost) so the home-index-auth middleware
 	// can add the Cloud Run identity token (X-Serverless-Authorization) before the request
 	// reaches the private home-index Cloud Run backend.
-	authCheckURL := "http://localhost:8080/api/auth/check"
+	authCheckURL := strings.TrimSuffix(userAuth.CheckBaseURL, "/") + "/" + strings.TrimPrefix(userAuth.CheckPath, "/")
 
 	mw := MiddlewareConfig{
 		ForwardAuth: &ForwardAuthConfig{
-			Address:            authCheckURL,
-			TrustForwardHeader: true,
-			AuthResponseHeaders: []string{
-				"X-User-Id",
-				"X-User-Email",
-				"X-Authorization",
-			},
-			// Authorization: Firebase Bearer token. Cookie: firebase_token fallback.
-			// X-Forwarded-Uri is intentionally omitted - ForwardAuth auto-sets it to the original
-			// request URI; listing it here would copy empty from the browser request and overwrite.
-			AuthRequestHeaders: []string{
-				"Authorization",
-				"Cookie",
-				"X-Forwarded-For",
-				"X-Forwarded-Host",
-			},
+			Address:             authCheckURL,
+			TrustForwardHeader:  true,
+			AuthResponseHeaders: userAuth.AuthResponseHeaders,
+			AuthRequestHeaders:  userAuth.AuthRequestHeaders,
 		},
 	}
 
-	fmt.Printf("[ConfigBuilder] ✅ Created forwardAuth middleware '%s' with address: %s\n",
+	fmt.Fprintf(os.Stderr, "[ConfigBuilder] ✅ Created forwardAuth middleware '%s' with address: %s\n",
 		name, authCheckURL)
 
 	c.HTTP.Middlewares[name] = mw
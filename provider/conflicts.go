@@ -0,0 +1,59 @@
+package provider
+
+import "fmt"
+
+// Router conflict policies: which of two services defining a router with the
+// same name keeps it
+const (
+	ConflictDedicatedWins       = "dedicated-wins"        // Default: the service named after the router, otherwise the last one
+	ConflictFirstWins           = "first-wins"            // The service discovered first
+	ConflictLastWins            = "last-wins"             // The service discovered last
+	ConflictHighestPriorityWins = "highest-priority-wins" // The router with the higher priority, the first one on a tie
+	ConflictError               = "error"                 // Abort the cycle so the previous config stays in place
+)
+
+// validConflictPolicy reports whether policy is a known router conflict policy
+func validConflictPolicy(policy string) bool {
+	switch policy {
+	case ConflictDedicatedWins, ConflictFirstWins, ConflictLastWins, ConflictHighestPriorityWins, ConflictError:
+		return true
+	}
+	return false
+}
+
+// RouterConflictError is the error a generation fails with under the "error"
+// router conflict policy
+type RouterConflictError struct {
+	Router   string
+	Existing string // Service that defined the router first
+	Service  string // Service that defined it again
+}
+
+func (e *RouterConflictError) Error() string {
+	return fmt.Sprintf("router %s is defined by both %s and %s", e.Router, e.Existing, e.Service)
+}
+
+// replacesRouter decides whether router from sourceName replaces the router
+// of the same name already defined by existingSource
+func (c *DynamicConfig) replacesRouter(name string, router RouterConfig, sourceName, existingSource string) bool {
+	switch c.conflictPolicy {
+	case ConflictFirstWins:
+		return false
+	case ConflictLastWins:
+		return true
+	case ConflictHighestPriorityWins:
+		return router.Priority > c.HTTP.Routers[name].Priority
+	case ConflictError:
+		if c.conflictErr == nil {
+			c.conflictErr = &RouterConflictError{Router: name, Existing: existingSource, Service: sourceName}
+		}
+		return false
+	}
+
+	// A dedicated service name contains the router name (e.g., "lab1-c2-stg"
+	// for "lab1-c2"). Only keep the existing router if it is from a dedicated
+	// service and the new one is not; otherwise the last one wins.
+	newIsDedicated := isDedicatedService(name, sourceName, c.envAffixes)
+	existingIsDedicated := isDedicatedService(name, existingSource, c.envAffixes)
+	return !existingIsDedicated || newIsDedicated
+}
@@ -0,0 +1,94 @@
+package provider
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// conflictServices are two services defining the lab1-c2 router: the shared
+// lab1-stg first with the higher priority, then the dedicated lab1-c2-stg
+func conflictServices() []CloudRunService {
+	return []CloudRunService{
+		{Name: "lab1-stg", ProjectID: "test-project", URL: "https://lab1-stg.run.app", Labels: map[string]string{
+			"traefik_enable":                        "true",
+			"traefik_http_routers_lab1-c2_rule":     "PathPrefix(`/lab1/c2`)",
+			"traefik_http_routers_lab1-c2_priority": "500",
+		}},
+		{Name: "lab1-c2-stg", ProjectID: "test-project", URL: "https://lab1-c2-stg.run.app", Labels: map[string]string{
+			"traefik_enable":                        "true",
+			"traefik_http_routers_lab1-c2_rule":     "PathPrefix(`/lab1/c2`)",
+			"traefik_http_routers_lab1-c2_priority": "300",
+		}},
+	}
+}
+
+func TestBuild_RouterConflictPolicy(t *testing.T) {
+	tests := []struct {
+		policy string
+		want   string
+	}{
+		{"", "lab1-c2-stg"},
+		{ConflictDedicatedWins, "lab1-c2-stg"},
+		{ConflictFirstWins, "lab1-stg"},
+		{ConflictLastWins, "lab1-c2-stg"},
+		{ConflictHighestPriorityWins, "lab1-stg"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			provider, err := newProvider(&Config{
+				ProjectIDs:           []string{"test-project"},
+				Region:               "us-central1",
+				TokenInjection:       TokenInjectionPlugin,
+				RouterConflictPolicy: tt.policy,
+			})
+			if err != nil {
+				t.Fatalf("Failed to create provider: %v", err)
+			}
+			config, err := provider.Build(conflictServices())
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got := config.HTTP.Routers["lab1-c2"].Service; got != tt.want {
+				t.Errorf("Expected lab1-c2 from %s, got %s", tt.want, got)
+			}
+			skipped := config.Skipped()
+			if len(skipped) != 1 || skipped[0].Reason != SkipReasonRouterConflict || skipped[0].Service == tt.want {
+				t.Errorf("Expected the other service skipped as a router conflict, got %+v", skipped)
+			}
+		})
+	}
+}
+
+func TestBuild_RouterConflictError(t *testing.T) {
+	provider, err := newProvider(&Config{
+		ProjectIDs:           []string{"test-project"},
+		Region:               "us-central1",
+		TokenInjection:       TokenInjectionPlugin,
+		RouterConflictPolicy: ConflictError,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	_, err = provider.Build(conflictServices())
+	var conflictErr *RouterConflictError
+	if !errors.As(err, &conflictErr) {
+		t.Fatalf("Expected a RouterConflictError, got %v", err)
+	}
+	if conflictErr.Router != "lab1-c2" || conflictErr.Existing != "lab1-stg" || conflictErr.Service != "lab1-c2-stg" {
+		t.Errorf("Unexpected conflict: %+v", conflictErr)
+	}
+
+	// Without conflicts the policy changes nothing
+	if _, err := provider.Build(conflictServices()[:1]); err != nil {
+		t.Errorf("Unexpected error without conflicts: %v", err)
+	}
+}
+
+func TestNew_InvalidRouterConflictPolicy(t *testing.T) {
+	_, err := New(&Config{ProjectIDs: []string{"p"}, Region: "r", RouterConflictPolicy: "random"})
+	if err == nil || !strings.Contains(err.Error(), "router conflict policy") {
+		t.Errorf("Expected invalid policy error, got %v", err)
+	}
+}
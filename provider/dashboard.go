@@ -0,0 +1,113 @@
+package provider
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Names of the generated Traefik API and dashboard routers and middlewares
+const (
+	DashboardAPIRouter           = "traefik-api"
+	DashboardRouter              = "traefik-dashboard"
+	dashboardAllowListMiddleware = "traefik-dashboard-allowlist"
+	dashboardBasicAuthMiddleware = "traefik-dashboard-auth"
+)
+
+// defaultDashboardPriority keeps the internal routers ahead of service routes
+const defaultDashboardPriority = 1000
+
+// DashboardConfig configures the routers exposing Traefik's API and
+// dashboard (api@internal). They are only generated when Enabled is set:
+// anyone who can reach the entrypoint can read the whole routing table.
+type DashboardConfig struct {
+	Enabled     bool     `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+	EntryPoints []string `json:"entryPoints,omitempty" yaml:"entryPoints,omitempty"` // Default: web
+	Host        string   `json:"host,omitempty" yaml:"host,omitempty"`               // Only match this Host (any host when empty)
+	Priority    int      `json:"priority,omitempty" yaml:"priority,omitempty"`       // Default: 1000
+
+	// Client source ranges let through by an ipAllowList middleware
+	AllowedIPs []string `json:"allowedIPs,omitempty" yaml:"allowedIPs,omitempty"`
+	// htpasswd entries (user:hash) checked by a basicAuth middleware
+	BasicAuthUsers []string `json:"basicAuthUsers,omitempty" yaml:"basicAuthUsers,omitempty"`
+}
+
+// validate checks the allowed IPs and basic auth users
+func (d DashboardConfig) validate() error {
+	for _, source := range d.AllowedIPs {
+		if net.ParseIP(source) == nil {
+			if _, _, err := net.ParseCIDR(source); err != nil {
+				return fmt.Errorf("invalid dashboard allowed IP %q", source)
+			}
+		}
+	}
+	for _, user := range d.BasicAuthUsers {
+		if name, hash, ok := strings.Cut(user, ":"); !ok || name == "" || hash == "" {
+			return fmt.Errorf("invalid dashboard basic auth user %q (expected user:hash)", name)
+		}
+	}
+	return nil
+}
+
+// AddDashboardRouters adds the Traefik API and dashboard routers, with the
+// middlewares restricting them, if dashboard.Enabled is set
+func (c *DynamicConfig) AddDashboardRouters(dashboard DashboardConfig) {
+	if !dashboard.Enabled {
+		return
+	}
+	entryPoints := dashboard.EntryPoints
+	if len(entryPoints) == 0 {
+		entryPoints = []string{"web"}
+	}
+	priority := dashboard.Priority
+	if priority == 0 {
+		priority = defaultDashboardPriority
+	}
+
+	var middlewares []string
+	if len(dashboard.AllowedIPs) > 0 {
+		c.AddMiddleware(dashboardAllowListMiddleware, MiddlewareConfig{
+			IPAllowList: &IPAllowListConfig{SourceRange: dashboard.AllowedIPs},
+		})
+		middlewares = append(middlewares, dashboardAllowListMiddleware)
+	}
+	if len(dashboard.BasicAuthUsers) > 0 {
+		c.AddMiddleware(dashboardBasicAuthMiddleware, MiddlewareConfig{
+			BasicAuth: &BasicAuthConfig{Users: dashboard.BasicAuthUsers},
+		})
+		middlewares = append(middlewares, dashboardBasicAuthMiddleware)
+	}
+
+	rule := func(paths string) string {
+		if dashboard.Host == "" {
+			return paths
+		}
+		return fmt.Sprintf("Host(`%s`) && (%s)", dashboard.Host, paths)
+	}
+	c.HTTP.Routers[DashboardAPIRouter] = RouterConfig{
+		Rule:        rule("PathPrefix(`/api/http`) || PathPrefix(`/api/rawdata`) || PathPrefix(`/api/overview`) || Path(`/api/version`)"),
+		Service:     "api@internal",
+		Priority:    priority,
+		EntryPoints: entryPoints,
+		Middlewares: middlewares,
+	}
+	c.HTTP.Routers[DashboardRouter] = RouterConfig{
+		Rule:        rule("PathPrefix(`/dashboard`)"),
+		Service:     "api@internal",
+		Priority:    priority,
+		EntryPoints: entryPoints,
+		Middlewares: middlewares,
+	}
+}
+
+// DrainConfig returns the configuration written while the provider drains:
+// no service routes, only the API and dashboard routers if enabled
+func (p *Provider) DrainConfig() *DynamicConfig {
+	config := NewDynamicConfig()
+	config.AddDashboardRouters(p.config.Dashboard)
+	config.useTraefikVersion(p.config.TraefikVersion)
+	if p.config.NamePrefix != "" {
+		config = config.WithNamePrefix(p.config.NamePrefix)
+	}
+	return config
+}
@@ -0,0 +1,86 @@
+package provider
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAddDashboardRouters_Disabled(t *testing.T) {
+	config := NewDynamicConfig()
+	config.AddDashboardRouters(DashboardConfig{Host: "traefik.example.com"})
+
+	if len(config.HTTP.Routers) != 0 || len(config.HTTP.Middlewares) != 0 {
+		t.Errorf("Expected no routers or middlewares, got %v and %v", config.HTTP.Routers, config.HTTP.Middlewares)
+	}
+}
+
+func TestAddDashboardRouters_Defaults(t *testing.T) {
+	config := NewDynamicConfig()
+	config.AddDashboardRouters(DashboardConfig{Enabled: true})
+
+	router, ok := config.HTTP.Routers[DashboardRouter]
+	if !ok {
+		t.Fatalf("Expected %s router, got %v", DashboardRouter, config.HTTP.Routers)
+	}
+	if router.Rule != "PathPrefix(`/dashboard`)" || router.Priority != defaultDashboardPriority {
+		t.Errorf("Unexpected dashboard router: %+v", router)
+	}
+	if !reflect.DeepEqual(router.EntryPoints, []string{"web"}) || len(router.Middlewares) != 0 {
+		t.Errorf("Expected web entrypoint without middlewares, got %+v", router)
+	}
+	if _, ok := config.HTTP.Routers[DashboardAPIRouter]; !ok {
+		t.Errorf("Expected %s router", DashboardAPIRouter)
+	}
+}
+
+func TestAddDashboardRouters_Restricted(t *testing.T) {
+	config := NewDynamicConfig()
+	config.AddDashboardRouters(DashboardConfig{
+		Enabled:        true,
+		EntryPoints:    []string{"admin"},
+		Host:           "traefik.example.com",
+		Priority:       5000,
+		AllowedIPs:     []string{"10.0.0.0/8"},
+		BasicAuthUsers: []string{"ops:$apr1$hash"},
+	})
+
+	api := config.HTTP.Routers[DashboardAPIRouter]
+	if !strings.HasPrefix(api.Rule, "Host(`traefik.example.com`) && (PathPrefix(`/api/http`)") || !strings.HasSuffix(api.Rule, ")") {
+		t.Errorf("Expected Host-restricted API rule, got %s", api.Rule)
+	}
+	if api.Priority != 5000 || !reflect.DeepEqual(api.EntryPoints, []string{"admin"}) {
+		t.Errorf("Unexpected API router: %+v", api)
+	}
+	want := []string{dashboardAllowListMiddleware, dashboardBasicAuthMiddleware}
+	if !reflect.DeepEqual(api.Middlewares, want) {
+		t.Errorf("Expected middlewares %v, got %v", want, api.Middlewares)
+	}
+	if mw := config.HTTP.Middlewares[dashboardBasicAuthMiddleware]; mw.BasicAuth == nil || mw.BasicAuth.Users[0] != "ops:$apr1$hash" {
+		t.Errorf("Expected basicAuth middleware, got %+v", mw)
+	}
+}
+
+func TestDashboardConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name      string
+		dashboard DashboardConfig
+		wantErr   string
+	}{
+		{"valid", DashboardConfig{AllowedIPs: []string{"10.0.0.1", "192.168.0.0/16"}, BasicAuthUsers: []string{"ops:hash"}}, ""},
+		{"bad source range", DashboardConfig{AllowedIPs: []string{"10.0.0.0/33"}}, "allowed IP"},
+		{"missing hash", DashboardConfig{BasicAuthUsers: []string{"ops"}}, "basic auth user"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.dashboard.validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
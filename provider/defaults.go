@@ -0,0 +1,86 @@
+package provider
+
+import (
+	"strings"
+
+	run "google.golang.org/api/run/v1"
+)
+
+// DefaultDefaultsService is the Cloud Run service whose labels are a
+// project's label defaults when DefaultsService is not set
+const DefaultDefaultsService = "defaults"
+
+// defaultsRouterName stands for every router of a service in router labels
+// of a defaults document, e.g. traefik_http_routers_default_middlewares
+const defaultsRouterName = "default"
+
+// defaultsLabels returns the labels of a project's defaults service (keys
+// normalized, traefik_enable dropped), or false if svc is another service
+func (p *Provider) defaultsLabels(svc *run.Service) (map[string]string, bool) {
+	if svc.Metadata == nil || svc.Metadata.Name != p.config.DefaultsService {
+		return nil, false
+	}
+	labels := make(map[string]string, len(svc.Metadata.Labels))
+	for key, value := range NormalizeLabels(svc.Metadata.Labels) {
+		if strings.HasPrefix(key, "traefik_") && key != "traefik_enable" {
+			labels[key] = value
+		}
+	}
+	return labels, true
+}
+
+// projectDefaults returns a project's label defaults: those from the
+// provider config, overridden by the project's defaults service labels
+func (p *Provider) projectDefaults(projectID string, serviceDefaults map[string]string) map[string]string {
+	configured := NormalizeLabels(p.config.ProjectDefaults[projectID])
+	if len(configured) == 0 {
+		return serviceDefaults
+	}
+	defaults := make(map[string]string, len(configured)+len(serviceDefaults))
+	for key, value := range configured {
+		defaults[key] = value
+	}
+	for key, value := range serviceDefaults {
+		defaults[key] = value
+	}
+	return defaults
+}
+
+// withDefaults returns a service's labels with the defaults it doesn't set
+// itself added. Router labels of the "default" router apply to each of the
+// service's routers that doesn't set that property; a service that only
+// uses shorthand rule labels has one router, named after the service.
+func withDefaults(labels, defaults map[string]string, serviceName string) map[string]string {
+	if len(defaults) == 0 {
+		return labels
+	}
+
+	const routerPrefix = "traefik_http_routers_"
+	routers := make(map[string]bool)
+	for key := range labels {
+		if rest, ok := strings.CutPrefix(key, routerPrefix); ok {
+			if name, _, ok := strings.Cut(rest, "_"); ok {
+				routers[name] = true
+			}
+		}
+	}
+	if len(routers) == 0 && shorthandRule(labels) != "" {
+		routers[serviceName] = true
+	}
+
+	merged := make(map[string]string, len(labels)+len(defaults))
+	for key, value := range defaults {
+		property, ok := strings.CutPrefix(key, routerPrefix+defaultsRouterName+"_")
+		if !ok {
+			merged[key] = value
+			continue
+		}
+		for name := range routers {
+			merged[routerPrefix+name+"_"+property] = value
+		}
+	}
+	for key, value := range labels {
+		merged[key] = value
+	}
+	return merged
+}
@@ -0,0 +1,118 @@
+package provider
+
+import (
+	"reflect"
+	"testing"
+
+	run "google.golang.org/api/run/v1"
+)
+
+func TestWithDefaults(t *testing.T) {
+	defaults := map[string]string{
+		"traefik_auth_type":                        AuthTypeAccessToken,
+		"traefik_token_failure_policy":             TokenFailureSkipRoute,
+		"traefik_http_routers_default_middlewares": "cors-headers-file__rate-limit",
+	}
+
+	tests := []struct {
+		name   string
+		labels map[string]string
+		want   map[string]string
+	}{
+		{
+			name: "defaults fill unset labels and every router",
+			labels: map[string]string{
+				"traefik_enable":                           "true",
+				"traefik_token_failure_policy":             TokenFailureFailGeneration,
+				"traefik_http_routers_app_rule":            "PathPrefix(`/app`)",
+				"traefik_http_routers_app-api_rule":        "PathPrefix(`/app/api`)",
+				"traefik_http_routers_app-api_middlewares": "api-key",
+			},
+			want: map[string]string{
+				"traefik_enable":                           "true",
+				"traefik_auth_type":                        AuthTypeAccessToken,
+				"traefik_token_failure_policy":             TokenFailureFailGeneration,
+				"traefik_http_routers_app_rule":            "PathPrefix(`/app`)",
+				"traefik_http_routers_app_middlewares":     "cors-headers-file__rate-limit",
+				"traefik_http_routers_app-api_rule":        "PathPrefix(`/app/api`)",
+				"traefik_http_routers_app-api_middlewares": "api-key",
+			},
+		},
+		{
+			name:   "shorthand router is named after the service",
+			labels: map[string]string{"traefik_enable": "true", "traefik_host": "shop.example.com"},
+			want: map[string]string{
+				"traefik_enable":                        "true",
+				"traefik_host":                          "shop.example.com",
+				"traefik_auth_type":                     AuthTypeAccessToken,
+				"traefik_token_failure_policy":          TokenFailureSkipRoute,
+				"traefik_http_routers_shop_middlewares": "cors-headers-file__rate-limit",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := withDefaults(tt.labels, defaults, "shop"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("withDefaults =\n%v\nexpected\n%v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListServices_ProjectDefaults(t *testing.T) {
+	client := &fakeCloudRunClient{services: map[string][]*run.Service{
+		"projects/test-project/locations/us-central1": {
+			newFakeService("defaults", "https://defaults.run.app", map[string]string{
+				"traefik_enable":    "true", // Never routed all the same
+				"traefik_auth_type": AuthTypeAccessToken,
+				"team":              "platform",
+			}),
+			newFakeService("app", "https://app.run.app", map[string]string{
+				"traefik_enable":                "true",
+				"traefik_http_routers_app_rule": "PathPrefix(`/app`)",
+			}),
+			newFakeService("legacy", "https://legacy.run.app", map[string]string{
+				"traefik_enable":                   "true",
+				"traefik_http_routers_legacy_rule": "PathPrefix(`/legacy`)",
+				"traefik_route_tag":                "true",
+			}),
+		},
+	}}
+	p, err := NewWithClients(&Config{
+		ProjectIDs: []string{"test-project"},
+		Region:     "us-central1",
+		ProjectDefaults: map[string]map[string]string{
+			"test-project":  {"traefik_auth_type": AuthTypeIDToken, "traefik_route_tag": "false"},
+			"other-project": {"traefik_selftest": "false"},
+		},
+	}, client, &fakeTokenSource{token: "eyJ.test"}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	services, err := p.listServices("test-project", "us-central1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(services) != 2 {
+		t.Fatalf("Expected the defaults service not to be routed, got %d services", len(services))
+	}
+	for _, service := range services {
+		// The defaults service overrides the config's defaults
+		if service.Labels[authTypeLabel] != AuthTypeAccessToken {
+			t.Errorf("%s: expected auth type from the defaults service, got %q", service.Name, service.Labels[authTypeLabel])
+		}
+		if _, ok := service.Labels["traefik_selftest"]; ok {
+			t.Errorf("%s: got another project's defaults", service.Name)
+		}
+		if _, ok := service.Labels["team"]; ok {
+			t.Errorf("%s: non-traefik label of the defaults service was applied", service.Name)
+		}
+	}
+	if got := services[0].Labels[routeTagLabel]; got != "false" {
+		t.Errorf("app: expected route tagging default false, got %q", got)
+	}
+	if got := services[1].Labels[routeTagLabel]; got != "true" {
+		t.Errorf("legacy: expected its own route tag label to win, got %q", got)
+	}
+}
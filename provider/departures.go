@@ -0,0 +1,90 @@
+package provider
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/internal/logging"
+)
+
+// DepartingService is a service missing from discovery whose routes are
+// kept until it has been missing for RouteDeletionDelay polls
+type DepartingService struct {
+	Service     string
+	Project     string
+	MissedPolls int // Consecutive polls the service has been missing from
+}
+
+// departureTracker remembers the services emitted by the previous poll and
+// how many polls in a row each has been missing from discovery
+type departureTracker struct {
+	mu     sync.Mutex
+	last   map[string]CloudRunService
+	missed map[string]int
+}
+
+// newDepartureTracker creates an empty tracker
+func newDepartureTracker() *departureTracker {
+	return &departureTracker{
+		last:   make(map[string]CloudRunService),
+		missed: make(map[string]int),
+	}
+}
+
+// track returns services plus the services of the previous poll that are
+// missing from them but haven't been missing for more than delay polls
+func (d *departureTracker) track(services []CloudRunService, delay int) ([]CloudRunService, []DepartingService) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	current := make(map[string]CloudRunService, len(services))
+	for _, service := range services {
+		key := fragmentKey(service)
+		current[key] = service
+		delete(d.missed, key)
+	}
+
+	// Sorted so that kept services are emitted in a stable order
+	keys := make([]string, 0, len(d.last))
+	for key := range d.last {
+		if _, ok := current[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var departing []DepartingService
+	for _, key := range keys {
+		service := d.last[key]
+		d.missed[key]++
+		if d.missed[key] > delay {
+			delete(d.missed, key)
+			continue
+		}
+		services = append(services, service)
+		current[key] = service
+		departing = append(departing, DepartingService{Service: service.Name, Project: service.ProjectID, MissedPolls: d.missed[key]})
+	}
+
+	d.last = current
+	return services, departing
+}
+
+// withDepartures keeps the routes of services that recently disappeared
+// from discovery (see Config.RouteDeletionDelay)
+func (p *Provider) withDepartures(services []CloudRunService) ([]CloudRunService, []DepartingService) {
+	if p.config.RouteDeletionDelay <= 0 {
+		return services, nil
+	}
+	services, departing := p.departures.track(services, p.config.RouteDeletionDelay)
+	for _, service := range departing {
+		p.logger.Warn("Keeping routes of service missing from discovery",
+			logging.GetCodeField(logging.CodeServiceDeparting),
+			logging.String("service", service.Service),
+			logging.String("project", service.Project),
+			logging.Int("missedPolls", service.MissedPolls),
+			logging.Int("deletionDelay", p.config.RouteDeletionDelay),
+		)
+	}
+	return services, departing
+}
@@ -0,0 +1,56 @@
+package provider
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDepartureTracker(t *testing.T) {
+	api := CloudRunService{Name: "api", ProjectID: "p1"}
+	web := CloudRunService{Name: "web", ProjectID: "p1"}
+	tracker := newDepartureTracker()
+
+	names := func(services []CloudRunService) []string {
+		var names []string
+		for _, service := range services {
+			names = append(names, service.Name)
+		}
+		return names
+	}
+
+	if services, departing := tracker.track([]CloudRunService{api, web}, 2); len(services) != 2 || departing != nil {
+		t.Fatalf("Expected both services and nothing departing, got %v %+v", names(services), departing)
+	}
+
+	// web is kept for two polls, then removed
+	for missed := 1; missed <= 2; missed++ {
+		services, departing := tracker.track([]CloudRunService{api}, 2)
+		want := []DepartingService{{Service: "web", Project: "p1", MissedPolls: missed}}
+		if !reflect.DeepEqual(names(services), []string{"api", "web"}) || !reflect.DeepEqual(departing, want) {
+			t.Errorf("Poll %d: expected web to be kept, got %v %+v", missed, names(services), departing)
+		}
+	}
+	if services, departing := tracker.track([]CloudRunService{api}, 2); len(services) != 1 || departing != nil {
+		t.Errorf("Expected web to be removed after the delay, got %v %+v", names(services), departing)
+	}
+
+	// A service that comes back starts counting from zero again
+	tracker.track([]CloudRunService{api, web}, 2)
+	tracker.track([]CloudRunService{api}, 2)
+	tracker.track([]CloudRunService{api, web}, 2)
+	if _, departing := tracker.track([]CloudRunService{api}, 2); len(departing) != 1 || departing[0].MissedPolls != 1 {
+		t.Errorf("Expected the missed count to reset when web reappeared, got %+v", departing)
+	}
+}
+
+func TestWithDepartures_Disabled(t *testing.T) {
+	p, err := NewWithClients(&Config{ProjectIDs: []string{"test-project"}, Region: "us-central1"},
+		&fakeCloudRunClient{}, &fakeTokenSource{token: "eyJ.test"}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	p.withDepartures([]CloudRunService{{Name: "api", ProjectID: "p1"}})
+	if services, departing := p.withDepartures(nil); len(services) != 0 || departing != nil {
+		t.Errorf("Expected no routes kept without RouteDeletionDelay, got %+v %+v", services, departing)
+	}
+}
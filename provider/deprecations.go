@@ -0,0 +1,134 @@
+package provider
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/internal/logging"
+)
+
+// Deprecation is a deprecated setting or label form in use, and what
+// replaces it. Deprecated settings keep working (they are migrated to
+// their replacement) unless FailOnDeprecated is set.
+type Deprecation struct {
+	Setting     string // Environment variable, config field or label key
+	Service     string // Service carrying the label ("" for settings)
+	Replacement string // What to use instead
+}
+
+func (d Deprecation) String() string {
+	if d.Service != "" {
+		return fmt.Sprintf("label %s on service %s (use %s)", d.Setting, d.Service, d.Replacement)
+	}
+	return fmt.Sprintf("%s (use %s)", d.Setting, d.Replacement)
+}
+
+// DeprecationError is returned when FailOnDeprecated is set and deprecated
+// settings or label forms are in use, e.g. to catch them in CI before the
+// deprecated form is removed
+type DeprecationError struct {
+	Deprecations []Deprecation
+}
+
+func (e *DeprecationError) Error() string {
+	descriptions := make([]string, len(e.Deprecations))
+	for i, d := range e.Deprecations {
+		descriptions[i] = d.String()
+	}
+	return fmt.Sprintf("deprecated settings in use: %s", strings.Join(descriptions, "; "))
+}
+
+// migrateDeprecatedSettings rewrites deprecated settings of config into
+// their replacements and returns what it found
+func migrateDeprecatedSettings(config *Config) []Deprecation {
+	var deprecations []Deprecation
+	if config.SkipAuthCheck != nil && *config.SkipAuthCheck {
+		// Stripping auth-check middlewares is what disabling user auth does
+		disabled := false
+		config.UserAuthEnabled = &disabled
+		deprecations = append(deprecations, Deprecation{
+			Setting:     "SKIP_AUTH_CHECK",
+			Replacement: "USER_AUTH_ENABLED=false",
+		})
+	}
+	return deprecations
+}
+
+// deprecatedLabels returns the deprecated label forms of a service's
+// labels, sorted by key: list values separated by ; or , instead of __,
+// and the ipwhitelist middleware type Traefik renamed to ipallowlist
+func deprecatedLabels(service string, labels map[string]string) []Deprecation {
+	var deprecations []Deprecation
+	for key, value := range labels {
+		if !strings.HasPrefix(key, "traefik_") {
+			continue
+		}
+		if isListLabel(key) && !strings.Contains(value, "__") && strings.ContainsAny(value, ";,") {
+			deprecations = append(deprecations, Deprecation{
+				Setting:     key,
+				Service:     service,
+				Replacement: "__ between values",
+			})
+		}
+		if strings.HasPrefix(key, "traefik_http_middlewares_") && strings.Contains(key, "_ipwhitelist_") {
+			deprecations = append(deprecations, Deprecation{
+				Setting:     key,
+				Service:     service,
+				Replacement: strings.Replace(key, "_ipwhitelist_", "_ipallowlist_", 1),
+			})
+		}
+	}
+	sort.Slice(deprecations, func(i, j int) bool { return deprecations[i].Setting < deprecations[j].Setting })
+	return deprecations
+}
+
+// isListLabel reports whether a label's value is a list split by
+// splitLabelList
+func isListLabel(key string) bool {
+	switch key {
+	case hostLabel, hostRegexpLabel, pathPrefixLabel, pathRegexpLabel:
+		return true
+	}
+	switch {
+	case strings.HasPrefix(key, "traefik_chain_"):
+		return true
+	case strings.HasPrefix(key, "traefik_http_routers_"):
+		return strings.HasSuffix(key, "_middlewares")
+	case strings.HasPrefix(key, "traefik_http_middlewares_"):
+		return strings.HasSuffix(key, "_chain_middlewares") || strings.HasSuffix(key, "_sourcerange") ||
+			strings.HasSuffix(key, "_forwardauth_authresponseheaders") || strings.HasSuffix(key, "_forwardauth_authrequestheaders")
+	case strings.HasPrefix(key, "traefik_forwardauth_"):
+		return strings.HasSuffix(key, "_authresponseheaders") || strings.HasSuffix(key, "_authrequestheaders")
+	}
+	return false
+}
+
+// warnDeprecated logs each deprecation with its replacement
+func warnDeprecated(logger *logging.Logger, deprecations []Deprecation) {
+	for _, d := range deprecations {
+		fields := []logging.Field{
+			logging.GetCodeField(logging.CodeDeprecatedSetting),
+			logging.String("setting", d.Setting),
+			logging.String("replacement", d.Replacement),
+		}
+		if d.Service != "" {
+			fields = append(fields, logging.String("service", d.Service))
+		}
+		logger.Warn("Deprecated setting in use", fields...)
+	}
+}
+
+// guardDeprecations refuses config if it was built from deprecated label
+// forms and FailOnDeprecated is set
+func (p *Provider) guardDeprecations(config *DynamicConfig) error {
+	if !p.config.FailOnDeprecated || len(config.deprecations) == 0 {
+		return nil
+	}
+	err := &DeprecationError{Deprecations: config.deprecations}
+	p.logger.Error("Refusing configuration built from deprecated labels",
+		logging.GetCodeField(logging.CodeDeprecatedRefused),
+		logging.Error(err),
+	)
+	return err
+}
@@ -0,0 +1,102 @@
+package provider
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/api/run/v1"
+)
+
+func boolPtr(b bool) *bool { return &b }
+
+func TestMigrateDeprecatedSettings_SkipAuthCheck(t *testing.T) {
+	config := &Config{UserAuthEnabled: boolPtr(true), SkipAuthCheck: boolPtr(true)}
+	deprecations := migrateDeprecatedSettings(config)
+	if *config.UserAuthEnabled {
+		t.Error("Expected SkipAuthCheck to be migrated to UserAuthEnabled=false")
+	}
+	if len(deprecations) != 1 || !strings.Contains(deprecations[0].Replacement, "USER_AUTH_ENABLED=false") {
+		t.Errorf("Expected one SKIP_AUTH_CHECK deprecation, got %+v", deprecations)
+	}
+
+	if deprecations := migrateDeprecatedSettings(&Config{UserAuthEnabled: boolPtr(true), SkipAuthCheck: boolPtr(false)}); len(deprecations) != 0 {
+		t.Errorf("Expected no deprecations, got %+v", deprecations)
+	}
+}
+
+func TestDeprecatedLabels(t *testing.T) {
+	labels := map[string]string{
+		"traefik_enable":                                          "true",
+		"traefik_http_routers_app_rule":                           "PathPrefix(`/a`)",
+		"traefik_http_routers_app_middlewares":                    "auth;retry-file",
+		"traefik_http_routers_app_entrypoints":                    "web,websecure", // Comma is the entrypoints separator
+		"traefik_http_routers_new_middlewares":                    "auth__retry-file",
+		"traefik_pathprefix":                                      "/a,/b",
+		"traefik_http_middlewares_office_ipwhitelist_sourcerange": "10.0.0.0/8",
+		"com_example_team":                                        "a,b",
+	}
+	got := deprecatedLabels("app", labels)
+	want := []Deprecation{
+		{Setting: "traefik_http_middlewares_office_ipwhitelist_sourcerange", Service: "app", Replacement: "traefik_http_middlewares_office_ipallowlist_sourcerange"},
+		{Setting: "traefik_http_routers_app_middlewares", Service: "app", Replacement: "__ between values"},
+		{Setting: "traefik_pathprefix", Service: "app", Replacement: "__ between values"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("deprecatedLabels() = %+v, want %+v", got, want)
+	}
+}
+
+func TestNewWithClients_FailOnDeprecatedSetting(t *testing.T) {
+	_, err := NewWithClients(&Config{
+		ProjectIDs:       []string{"test-project"},
+		Region:           "us-central1",
+		SkipAuthCheck:    boolPtr(true),
+		FailOnDeprecated: true,
+	}, &fakeCloudRunClient{}, &fakeTokenSource{token: "eyJfake"}, nil)
+	var deprecationErr *DeprecationError
+	if !errors.As(err, &deprecationErr) || !strings.Contains(err.Error(), "SKIP_AUTH_CHECK") {
+		t.Errorf("Expected a DeprecationError for SKIP_AUTH_CHECK, got %v", err)
+	}
+}
+
+func TestUpdateConfig_DeprecatedLabels(t *testing.T) {
+	for _, fail := range []bool{false, true} {
+		client := &fakeCloudRunClient{services: map[string][]*run.Service{
+			"projects/test-project/locations/us-central1": {newFakeService("lab1", "https://lab1.run.app", map[string]string{
+				"traefik_enable":                        "true",
+				"traefik_http_routers_lab1_rule":        "PathPrefix(`/lab1`)",
+				"traefik_http_routers_lab1_middlewares": "a;b",
+			})},
+		}}
+		p, err := NewWithClients(&Config{
+			ProjectIDs:       []string{"test-project"},
+			Region:           "us-central1",
+			FailOnDeprecated: fail,
+		}, client, &fakeTokenSource{token: "eyJfake"}, nil)
+		if err != nil {
+			t.Fatalf("Failed to create provider: %v", err)
+		}
+
+		configChan := make(chan *DynamicConfig, 1)
+		err = p.RunOnce(configChan)
+		if !fail {
+			if err != nil {
+				t.Fatalf("Expected deprecated labels to only warn, got %v", err)
+			}
+			config := <-configChan
+			if len(config.Deprecations()) != 1 || len(p.LastReport().Deprecated) != 1 {
+				t.Errorf("Expected the deprecation on the config and report, got %+v", config.Deprecations())
+			}
+			continue
+		}
+		var deprecationErr *DeprecationError
+		if !errors.As(err, &deprecationErr) || deprecationErr.Deprecations[0].Service != "lab1" {
+			t.Errorf("Expected a DeprecationError for lab1, got %v", err)
+		}
+		if len(configChan) != 0 {
+			t.Error("Expected no configuration to be emitted")
+		}
+	}
+}
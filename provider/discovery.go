@@ -3,8 +3,11 @@ package provider
 import (
 	"encoding/json"
 	"fmt"
+	"path"
 	"strings"
+	"time"
 
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/internal/logging"
 	run "google.golang.org/api/run/v1"
 )
 
@@ -40,6 +43,14 @@ func preferredServiceURL(svc *run.Service) string {
 	return svc.Status.Url
 }
 
+// latestReadyRevision returns the name of the revision currently serving the service
+func latestReadyRevision(svc *run.Service) string {
+	if svc.Status == nil {
+		return ""
+	}
+	return svc.Status.LatestReadyRevisionName
+}
+
 // CloudRunService represents a discovered Cloud Run service with Traefik labels
 type CloudRunService struct {
 	Name      string
@@ -47,65 +58,153 @@ type CloudRunService struct {
 	ProjectID string
 	Region    string
 	Labels    map[string]string
+
+	// ResourceVersion changes whenever the service is updated
+	ResourceVersion string
+	// Revision is the latest ready revision serving the service
+	Revision string
+	// Min and max instances of the serving template (autoscaling.knative.dev
+	// annotations); nil when not set
+	MinScale *int
+	MaxScale *int
+	// RequestTimeout is the serving template's request timeout (0 if unknown)
+	RequestTimeout time.Duration
+	// NotReady explains why the service's Ready condition isn't True, e.g. a
+	// failed deploy of its latest revision ("" when ready)
+	NotReady string
+	// NoTraffic is set when every traffic target of the service is at 0%
+	NoTraffic bool
+
+	// Platform is PlatformManaged or PlatformGKE (Cloud Run for Anthos).
+	// For GKE services Region is the cluster location.
+	Platform  string
+	Cluster   string // GKE cluster (PlatformGKE only)
+	Namespace string // Kubernetes namespace (PlatformGKE only)
 }
 
+// Cloud Run platforms
+const (
+	PlatformManaged = "managed"
+	PlatformGKE     = "gke"
+)
+
 const labelValueTrue = "true"
 
-// listServices lists Cloud Run services with traefik_enable=true label
+// serviceAllowed applies the include/exclude service filters to a service name.
+// Patterns were validated in newProvider, so match errors can't occur here.
+func (p *Provider) serviceAllowed(name string) (bool, string) {
+	for _, pattern := range p.config.ExcludeServices {
+		if matched, _ := path.Match(pattern, name); matched {
+			return false, "matches exclude pattern " + pattern
+		}
+	}
+	if len(p.config.IncludeServices) == 0 {
+		return true, ""
+	}
+	for _, pattern := range p.config.IncludeServices {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true, ""
+		}
+	}
+	return false, "matches no include pattern"
+}
+
+// discoverServices returns the Traefik-enabled services in a project, serving
+// from the list cache when the project was scanned recently or its request
+// budget is exhausted. Falls through to the API when nothing is cached.
+func (p *Provider) discoverServices(projectID string) ([]CloudRunService, error) {
+	now := time.Now()
+
+	if scan, reason := p.listCache.shouldScan(projectID, now); !scan {
+		if cached, ok := p.listCache.get(projectID); ok {
+			p.logger.Debug("Using cached service list",
+				logging.String("project", projectID),
+				logging.String("reason", reason),
+				logging.Int("count", len(cached)),
+			)
+			return cached, nil
+		}
+	}
+
+	services, err := p.listServices(projectID, p.config.Region)
+	if err != nil {
+		return nil, err
+	}
+
+	if changed := p.listCache.store(projectID, services, now); !changed {
+		p.logger.Debug("Project services unchanged since last scan",
+			logging.String("project", projectID),
+		)
+	}
+
+	return services, nil
+}
+
+// traefikLabels returns the labels of a service with traefik_enable=true,
+// with keys normalized (see NormalizeLabels). Service-level labels (set by
+// gcloud run deploy --labels) are checked first, then the revision template's labels.
+func traefikLabels(svc *run.Service) (map[string]string, bool) {
+	if svc.Metadata == nil {
+		return nil, false
+	}
+	if labels := NormalizeLabels(svc.Metadata.Labels); labels["traefik_enable"] == labelValueTrue {
+		return labels, true
+	}
+	if svc.Spec != nil && svc.Spec.Template != nil && svc.Spec.Template.Metadata != nil {
+		if labels := NormalizeLabels(svc.Spec.Template.Metadata.Labels); labels["traefik_enable"] == labelValueTrue {
+			return labels, true
+		}
+	}
+	return nil, false
+}
+
+// listServices lists Cloud Run services with traefik_enable=true label, with
+// the project's label defaults applied (see Config.ProjectDefaults)
 // Extracted from cmd/generate-routes/main.go:237-275
-//
-//nolint:gocyclo
-func (p *Provider) listServices(runService *run.APIService, projectID, region string) ([]CloudRunService, error) {
+func (p *Provider) listServices(projectID, region string) ([]CloudRunService, error) {
+	if p.client == nil {
+		return nil, fmt.Errorf("no Cloud Run client configured")
+	}
 	parent := fmt.Sprintf("projects/%s/locations/%s", projectID, region)
 
 	var services []CloudRunService
+	var serviceDefaults map[string]string
 	pageToken := ""
 
 	for {
-		call := runService.Projects.Locations.Services.List(parent)
-		if pageToken != "" {
-			call = call.Continue(pageToken)
+		if p.listCache != nil {
+			p.listCache.recordCall(projectID, time.Now())
 		}
 
-		resp, err := call.Do()
+		start := time.Now()
+		resp, err := p.client.ListServices(parent, pageToken)
+		p.apiStats.observe(projectID, time.Since(start), err)
 		if err != nil {
 			return nil, fmt.Errorf("failed to list services in %s/%s: %w", projectID, region, err)
 		}
 
-		if resp.Items != nil {
-			for _, svc := range resp.Items {
-				// Check if service has traefik_enable=true label
-				// Check both service-level labels (set by --labels) and template metadata labels
-				var labels map[string]string
-				var hasTraefikEnable bool
-
-				// First check service-level labels (metadata.labels) - set by gcloud run deploy --labels
-				if svc.Metadata != nil && svc.Metadata.Labels != nil {
-					if enabled, ok := svc.Metadata.Labels["traefik_enable"]; ok && enabled == labelValueTrue {
-						hasTraefikEnable = true
-						labels = svc.Metadata.Labels
-					}
-				}
-
-				// Fall back to template metadata labels if not found in service-level labels
-				if !hasTraefikEnable && svc.Spec != nil && svc.Spec.Template != nil && svc.Spec.Template.Metadata != nil {
-					if svc.Spec.Template.Metadata.Labels != nil {
-						if enabled, ok := svc.Spec.Template.Metadata.Labels["traefik_enable"]; ok && enabled == labelValueTrue {
-							hasTraefikEnable = true
-							labels = svc.Spec.Template.Metadata.Labels
-						}
-					}
-				}
-
-				if hasTraefikEnable && labels != nil {
-					services = append(services, CloudRunService{
-						Name:      svc.Metadata.Name,
-						URL:       preferredServiceURL(svc),
-						ProjectID: projectID,
-						Region:    region,
-						Labels:    labels,
-					})
-				}
+		for _, svc := range resp.Items {
+			if labels, ok := p.defaultsLabels(svc); ok {
+				serviceDefaults = labels
+				continue
+			}
+			if labels, ok := traefikLabels(svc); ok {
+				minScale, maxScale := scalingBounds(svc)
+				services = append(services, CloudRunService{
+					Name:            svc.Metadata.Name,
+					URL:             preferredServiceURL(svc),
+					ProjectID:       projectID,
+					Region:          region,
+					Platform:        PlatformManaged,
+					Labels:          labels,
+					ResourceVersion: svc.Metadata.ResourceVersion,
+					Revision:        latestReadyRevision(svc),
+					MinScale:        minScale,
+					MaxScale:        maxScale,
+					RequestTimeout:  requestTimeout(svc),
+					NotReady:        notReady(svc),
+					NoTraffic:       servesNoTraffic(svc),
+				})
 			}
 		}
 
@@ -116,5 +215,10 @@ func (p *Provider) listServices(runService *run.APIService, projectID, region st
 		pageToken = resp.Metadata.Continue
 	}
 
+	if defaults := p.projectDefaults(projectID, serviceDefaults); len(defaults) > 0 {
+		for i := range services {
+			services[i].Labels = withDefaults(services[i].Labels, defaults, services[i].Name)
+		}
+	}
 	return services, nil
 }
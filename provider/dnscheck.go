@@ -0,0 +1,107 @@
+package provider
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/internal/logging"
+)
+
+// Backend DNS check defaults
+const (
+	defaultDNSCheckTimeout = 2 * time.Second
+	dnsCheckConcurrency    = 8
+)
+
+// hostResolver looks up host names; *net.Resolver implements it
+type hostResolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// UnresolvedBackend is a generated backend whose URL is empty or whose host
+// name doesn't resolve, e.g. a service still deploying
+type UnresolvedBackend struct {
+	Service string   // Traefik service name
+	URL     string   // Backend URL ("" if the Cloud Run service has none yet)
+	Routers []string // Routers that use this service
+	Error   string   // Why it doesn't resolve
+}
+
+// checkBackendDNS resolves the host of every generated backend, at most
+// dnsCheckConcurrency at a time, and returns (and logs) those that don't.
+// Backends sharing a host are looked up once.
+func (p *Provider) checkBackendDNS(config *DynamicConfig) []UnresolvedBackend {
+	routersByService := make(map[string][]string)
+	for name, router := range config.HTTP.Routers {
+		routersByService[router.Service] = append(routersByService[router.Service], name)
+	}
+
+	var unresolved []UnresolvedBackend
+	hosts := make(map[string][]UnresolvedBackend) // Backends by host, pending lookup
+	for name, service := range config.HTTP.Services {
+		if len(service.LoadBalancer.Servers) == 0 {
+			continue
+		}
+		backend := UnresolvedBackend{Service: name, URL: service.LoadBalancer.Servers[0].URL, Routers: routersByService[name]}
+		sort.Strings(backend.Routers)
+		parsed, err := url.Parse(backend.URL)
+		switch {
+		case backend.URL == "":
+			backend.Error = "empty backend URL"
+		case err != nil:
+			backend.Error = err.Error()
+		case parsed.Hostname() == "":
+			backend.Error = "backend URL has no host"
+		case net.ParseIP(parsed.Hostname()) != nil:
+			continue
+		default:
+			hosts[parsed.Hostname()] = append(hosts[parsed.Hostname()], backend)
+			continue
+		}
+		unresolved = append(unresolved, backend)
+	}
+
+	timeout := p.config.DNSCheckTimeout
+	if timeout <= 0 {
+		timeout = defaultDNSCheckTimeout
+	}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, dnsCheckConcurrency)
+	for host, backends := range hosts {
+		wg.Add(1)
+		go func(host string, backends []UnresolvedBackend) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			if _, err := p.resolver.LookupHost(ctx, host); err != nil {
+				mu.Lock()
+				for _, backend := range backends {
+					backend.Error = err.Error()
+					unresolved = append(unresolved, backend)
+				}
+				mu.Unlock()
+			}
+		}(host, backends)
+	}
+	wg.Wait()
+
+	sort.Slice(unresolved, func(i, j int) bool { return unresolved[i].Service < unresolved[j].Service })
+	for _, backend := range unresolved {
+		p.logger.Warn("Backend host doesn't resolve",
+			logging.GetCodeField(logging.CodeBackendUnresolved),
+			logging.String("service", backend.Service),
+			logging.String("url", backend.URL),
+			logging.Strings("routers", backend.Routers),
+			logging.String("error", backend.Error),
+		)
+	}
+	return unresolved
+}
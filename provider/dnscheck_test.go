@@ -0,0 +1,61 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+// fakeResolver resolves the hosts in known and counts lookups
+type fakeResolver struct {
+	mu      sync.Mutex
+	known   map[string]bool
+	lookups map[string]int
+}
+
+func (r *fakeResolver) LookupHost(_ context.Context, host string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lookups[host]++
+	if !r.known[host] {
+		return nil, errors.New("no such host")
+	}
+	return []string{"203.0.113.1"}, nil
+}
+
+func TestCheckBackendDNS(t *testing.T) {
+	p, err := NewWithClients(&Config{
+		ProjectIDs: []string{"test-project"},
+		Region:     "us-central1",
+		DNSCheck:   true,
+	}, &fakeCloudRunClient{}, &fakeTokenSource{token: "eyJfake"}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	resolver := &fakeResolver{known: map[string]bool{"ok.run.app": true}, lookups: map[string]int{}}
+	p.resolver = resolver
+
+	config := NewDynamicConfig()
+	for name, url := range map[string]string{
+		"ok":        "https://ok.run.app",
+		"ok-grpc":   "https://ok.run.app",
+		"typo":      "https://typo.run.ap",
+		"deploying": "",
+		"ip":        "http://10.0.0.1:8080",
+	} {
+		config.AddService(name, ServiceConfig{LoadBalancer: LoadBalancerConfig{Servers: []ServerConfig{{URL: url}}}})
+	}
+	config.AddRouter("typo-router", RouterConfig{Rule: "PathPrefix(`/typo`)", Service: "typo"})
+
+	unresolved := p.checkBackendDNS(config)
+	if len(unresolved) != 2 || unresolved[0].Service != "deploying" || unresolved[1].Service != "typo" {
+		t.Fatalf("Expected deploying and typo unresolved, got %+v", unresolved)
+	}
+	if unresolved[0].Error != "empty backend URL" || len(unresolved[1].Routers) != 1 {
+		t.Errorf("Unexpected details %+v", unresolved)
+	}
+	if resolver.lookups["ok.run.app"] != 1 || resolver.lookups["10.0.0.1"] != 0 {
+		t.Errorf("Expected one lookup per host and none for IPs, got %v", resolver.lookups)
+	}
+}
@@ -0,0 +1,98 @@
+package provider
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Rule types routers are assigned entrypoints by when AutoEntryPoints is set
+const (
+	RuleTypeHost = "host" // The rule matches on the host (Host, HostHeader or HostRegexp)
+	RuleTypePath = "path" // Any other rule, e.g. a local PathPrefix-only route
+)
+
+// EntryPointRule is what routers of one rule type get: their entrypoints and
+// whether TLS is enabled on them
+type EntryPointRule struct {
+	EntryPoints []string `json:"entryPoints" yaml:"entryPoints"`
+	TLS         bool     `json:"tls,omitempty" yaml:"tls,omitempty"`
+	// Certificate resolver for the router's TLS (implies TLS)
+	CertResolver string `json:"certResolver,omitempty" yaml:"certResolver,omitempty"`
+}
+
+// defaultEntryPointRules apply to rule types EntryPointRules doesn't set:
+// public Host routes on websecure with TLS, local Path-only routes on web
+var defaultEntryPointRules = map[string]EntryPointRule{
+	RuleTypeHost: {EntryPoints: []string{"websecure"}, TLS: true},
+	RuleTypePath: {EntryPoints: []string{"web"}},
+}
+
+// withEntryPointDefaults validates the EntryPointRules overrides and fills
+// in the defaults for the rule types they leave unset
+func withEntryPointDefaults(rules map[string]EntryPointRule) (map[string]EntryPointRule, error) {
+	merged := make(map[string]EntryPointRule, len(defaultEntryPointRules))
+	for ruleType, rule := range defaultEntryPointRules {
+		merged[ruleType] = rule
+	}
+
+	ruleTypes := make([]string, 0, len(rules))
+	for ruleType := range rules {
+		ruleTypes = append(ruleTypes, ruleType)
+	}
+	sort.Strings(ruleTypes)
+	for _, ruleType := range ruleTypes {
+		rule := rules[ruleType]
+		if _, ok := defaultEntryPointRules[ruleType]; !ok {
+			return nil, fmt.Errorf("invalid entrypoint rule type %q (expected %q or %q)", ruleType, RuleTypeHost, RuleTypePath)
+		}
+		if len(rule.EntryPoints) == 0 {
+			return nil, fmt.Errorf("entrypoint rule %q: entryPoints must be specified", ruleType)
+		}
+		merged[ruleType] = rule
+	}
+	return merged, nil
+}
+
+// ruleType classifies a router rule. A rule is host-based when it has a
+// host matcher that isn't negated; anything else is path-based.
+func ruleType(rule string) string {
+	node, err := parseRule(rule)
+	if err == nil && matchesHost(node) {
+		return RuleTypeHost
+	}
+	return RuleTypePath
+}
+
+// matchesHost reports whether a rule tree has a host matcher outside a negation
+func matchesHost(node *ruleNode) bool {
+	switch node.op {
+	case ruleOpMatcher:
+		return node.name == "Host" || node.name == "HostHeader" || node.name == "HostRegexp"
+	case ruleOpNot:
+		return false
+	}
+	for _, child := range node.children {
+		if matchesHost(child) {
+			return true
+		}
+	}
+	return false
+}
+
+// assignEntryPoints sets the entrypoints and TLS of routers that have no
+// entrypoints label from their rule type. Routers with the label keep what
+// it says.
+func (p *Provider) assignEntryPoints(routers map[string]RouterConfig, labels map[string]string) {
+	for name, router := range routers {
+		if _, ok := labels["traefik_http_routers_"+name+"_entrypoints"]; ok {
+			continue
+		}
+		rule := p.config.EntryPointRules[ruleType(router.Rule)]
+		router.EntryPoints = append([]string(nil), rule.EntryPoints...)
+		router.TLS = nil
+		if rule.TLS || rule.CertResolver != "" {
+			router.TLS = &RouterTLSConfig{CertResolver: rule.CertResolver}
+		}
+		routers[name] = router
+	}
+}
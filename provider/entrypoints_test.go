@@ -0,0 +1,119 @@
+package provider
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRuleType(t *testing.T) {
+	tests := map[string]string{
+		"Host(`app.example.com`)":                               RuleTypeHost,
+		"Host(`app.example.com`) && PathPrefix(`/api`)":         RuleTypeHost,
+		"HostRegexp(`^.+\\.example\\.com$`)":                    RuleTypeHost,
+		"PathPrefix(`/lab1`) || HostHeader(`lab1.example.com`)": RuleTypeHost,
+		"PathPrefix(`/lab1`)":                                   RuleTypePath,
+		"Path(`/health`) && Method(`GET`)":                      RuleTypePath,
+		"PathPrefix(`/api`) && !Host(`internal.example.com`)":   RuleTypePath,
+		"not a rule": RuleTypePath,
+	}
+	for rule, want := range tests {
+		if got := ruleType(rule); got != want {
+			t.Errorf("ruleType(%q) = %s, expected %s", rule, got, want)
+		}
+	}
+}
+
+func TestWithEntryPointDefaults(t *testing.T) {
+	rules, err := withEntryPointDefaults(map[string]EntryPointRule{
+		RuleTypePath: {EntryPoints: []string{"internal"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(rules[RuleTypeHost], defaultEntryPointRules[RuleTypeHost]) {
+		t.Errorf("Expected the default host rule, got %+v", rules[RuleTypeHost])
+	}
+	if got := rules[RuleTypePath].EntryPoints; len(got) != 1 || got[0] != "internal" {
+		t.Errorf("Expected the path override, got %v", got)
+	}
+
+	for name, overrides := range map[string]map[string]EntryPointRule{
+		"unknown rule type": {"tcp": {EntryPoints: []string{"web"}}},
+		"no entrypoints":    {RuleTypeHost: {TLS: true}},
+	} {
+		if _, err := withEntryPointDefaults(overrides); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestProcessService_AutoEntryPoints(t *testing.T) {
+	p, err := NewWithClients(&Config{
+		ProjectIDs:      []string{"test-project"},
+		Region:          "us-central1",
+		AutoEntryPoints: true,
+		EntryPointRules: map[string]EntryPointRule{
+			RuleTypeHost: {EntryPoints: []string{"websecure"}, CertResolver: "letsencrypt"},
+		},
+	}, &fakeCloudRunClient{}, &fakeTokenSource{token: "eyJ.test"}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	config := NewDynamicConfig()
+	err = p.processService(CloudRunService{
+		Name: "app",
+		URL:  "https://app.run.app",
+		Labels: map[string]string{
+			"traefik_http_routers_app_rule":              "Host(`app.example.com`)",
+			"traefik_http_routers_app-local_rule":        "PathPrefix(`/app`)",
+			"traefik_http_routers_app-admin_rule":        "Host(`admin.example.com`)",
+			"traefik_http_routers_app-admin_entrypoints": "admin",
+		},
+	}, config)
+	if err != nil {
+		t.Fatalf("processService failed: %v", err)
+	}
+
+	tests := map[string]struct {
+		entryPoints string
+		tls         *RouterTLSConfig
+	}{
+		"app":       {"websecure", &RouterTLSConfig{CertResolver: "letsencrypt"}},
+		"app-local": {"web", nil},
+		"app-admin": {"admin", nil}, // The entrypoints label wins
+	}
+	for name, want := range tests {
+		router, ok := config.HTTP.Routers[name]
+		if !ok {
+			t.Errorf("Router %s not generated", name)
+			continue
+		}
+		if got := strings.Join(router.EntryPoints, ","); got != want.entryPoints {
+			t.Errorf("Router %s entrypoints = %s, expected %s", name, got, want.entryPoints)
+		}
+		if !reflect.DeepEqual(router.TLS, want.tls) {
+			t.Errorf("Router %s TLS = %+v, expected %+v", name, router.TLS, want.tls)
+		}
+	}
+}
+
+func TestProcessService_EntryPointsDefaultWithoutAuto(t *testing.T) {
+	p, err := NewWithClients(&Config{ProjectIDs: []string{"test-project"}, Region: "us-central1"},
+		&fakeCloudRunClient{}, &fakeTokenSource{token: "eyJ.test"}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	config := NewDynamicConfig()
+	if err := p.processService(CloudRunService{
+		Name:   "app",
+		URL:    "https://app.run.app",
+		Labels: map[string]string{"traefik_http_routers_app_rule": "Host(`app.example.com`)"},
+	}, config); err != nil {
+		t.Fatalf("processService failed: %v", err)
+	}
+	if router := config.HTTP.Routers["app"]; router.TLS != nil || strings.Join(router.EntryPoints, ",") != "web" {
+		t.Errorf("Expected web without TLS, got %v %+v", router.EntryPoints, router.TLS)
+	}
+}
@@ -0,0 +1,134 @@
+package provider
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/internal/logging"
+)
+
+// errorsLabelPrefix starts the error page shorthand labels, which define an
+// errors middleware <service>-errors used by every router of the service,
+// e.g. to show a "warming up" page while a scaled-to-zero service starts:
+//
+//	traefik_errors_status=502-503
+//	traefik_errors_service=warmup-page
+//	traefik_errors_query=/{status}.html
+const errorsLabelPrefix = "traefik_errors_"
+
+// errorsProperties are the properties of traefik_errors_<property> and
+// traefik_http_middlewares_<name>_errors_<property>
+var errorsProperties = map[string]bool{
+	"status":  true,
+	"service": true,
+	"query":   true,
+}
+
+// errorsMiddlewareName is the name of the middleware the error page
+// shorthand labels define
+func errorsMiddlewareName(serviceName string) string {
+	return serviceName + "-errors"
+}
+
+// extractErrorsConfigs extracts errors middleware configurations from labels.
+//
+// Two label forms are supported:
+//   - traefik_http_middlewares_<name>_errors_<property> defines middleware <name>
+//   - traefik_errors_<property> defines middleware <service>-errors
+//
+// Properties: status (codes or ranges, e.g. 502-503__500), service (the
+// router service name of the Cloud Run service serving the pages; a -file
+// suffix refers to a service in the file provider) and query (the page path,
+// {status} is replaced by the status code). Middlewares without a valid
+// status or a service are dropped with a warning.
+func extractErrorsConfigs(labels map[string]string, serviceName string) map[string]ErrorsConfig {
+	configs := make(map[string]ErrorsConfig)
+
+	for key, value := range labels {
+		var name, property string
+
+		switch {
+		case strings.HasPrefix(key, errorsLabelPrefix):
+			name = errorsMiddlewareName(serviceName)
+			property = strings.TrimPrefix(key, errorsLabelPrefix)
+		case strings.HasPrefix(key, "traefik_http_middlewares_"):
+			// Parse: traefik_http_middlewares_<name>_errors_<property>
+			parts := strings.SplitN(key, "_", 6)
+			if len(parts) < 6 || parts[4] != "errors" {
+				continue
+			}
+			name = parts[3]
+			property = parts[5]
+		default:
+			continue
+		}
+
+		errorPage := configs[name]
+		switch property {
+		case "status":
+			errorPage.Status = splitLabelList(value)
+		case "service":
+			errorPage.Service = normalizeMiddlewareRef(strings.TrimSpace(value))
+		case "query":
+			errorPage.Query = strings.TrimSpace(value)
+		default:
+			fmt.Fprintf(os.Stderr, "   WARNING: Unknown errors property %q for middleware %s, ignoring\n", property, name)
+		}
+		configs[name] = errorPage
+	}
+
+	for name, errorPage := range configs {
+		if len(errorPage.Status) == 0 || errorPage.Service == "" {
+			fmt.Fprintf(os.Stderr, "   WARNING: errors middleware %s needs a status and a service, skipping\n", name)
+			delete(configs, name)
+			continue
+		}
+		for _, status := range errorPage.Status {
+			if !validStatusRange(status) {
+				fmt.Fprintf(os.Stderr, "   WARNING: Invalid errors status %q for middleware %s (expected e.g. 503 or 500-599), skipping\n", status, name)
+				delete(configs, name)
+				break
+			}
+		}
+	}
+
+	return configs
+}
+
+// validStatusRange reports whether s is an HTTP status code or a range of
+// them (low-high)
+func validStatusRange(s string) bool {
+	low, high, isRange := strings.Cut(s, "-")
+	if !isRange {
+		high = low
+	}
+	l, err := strconv.Atoi(low)
+	if err != nil {
+		return false
+	}
+	h, err := strconv.Atoi(high)
+	if err != nil {
+		return false
+	}
+	return l >= 100 && h <= 599 && l <= h
+}
+
+// checkErrorPages warns about errors middlewares whose service isn't in
+// config (and isn't qualified with a provider, e.g. @file): Traefik fails
+// the routers using them
+func (p *Provider) checkErrorPages(config *DynamicConfig) {
+	for name, mw := range config.HTTP.Middlewares {
+		if mw.Errors == nil || strings.Contains(mw.Errors.Service, "@") {
+			continue
+		}
+		if _, ok := config.HTTP.Services[mw.Errors.Service]; !ok {
+			p.logger.Warn("Errors middleware references a service that isn't routed",
+				logging.GetCodeField(logging.CodeErrorPageMissing),
+				logging.String("middleware", name),
+				logging.String("service", mw.Errors.Service),
+			)
+		}
+	}
+}
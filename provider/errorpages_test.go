@@ -0,0 +1,82 @@
+package provider
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractErrorsConfigs(t *testing.T) {
+	configs := extractErrorsConfigs(map[string]string{
+		"traefik_errors_status":                         "502-503__504",
+		"traefik_errors_service":                        "warmup-page",
+		"traefik_errors_query":                          "/{status}.html",
+		"traefik_http_middlewares_maint_errors_status":  "500-599",
+		"traefik_http_middlewares_maint_errors_service": "maintenance-file",
+		"traefik_http_middlewares_nosvc_errors_status":  "503",
+		"traefik_http_middlewares_bad_errors_status":    "503-50x",
+		"traefik_http_middlewares_bad_errors_service":   "warmup-page",
+	}, "api")
+
+	want := map[string]ErrorsConfig{
+		"api-errors": {Status: []string{"502-503", "504"}, Service: "warmup-page", Query: "/{status}.html"},
+		"maint":      {Status: []string{"500-599"}, Service: "maintenance@file"},
+	}
+	if !reflect.DeepEqual(configs, want) {
+		t.Errorf("Expected %+v, got %+v", want, configs)
+	}
+}
+
+func TestValidStatusRange(t *testing.T) {
+	for status, want := range map[string]bool{
+		"503": true, "500-599": true, "99": false, "600": false, "503-502": false, "5xx": false, "": false,
+	} {
+		if got := validStatusRange(status); got != want {
+			t.Errorf("validStatusRange(%q) = %v, want %v", status, got, want)
+		}
+	}
+}
+
+func TestBuild_ErrorPages(t *testing.T) {
+	provider, err := newProvider(&Config{
+		ProjectIDs:     []string{"test-project"},
+		Region:         "us-central1",
+		TokenInjection: TokenInjectionPlugin,
+		NamePrefix:     "t1-",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	config, err := provider.Build([]CloudRunService{
+		{Name: "shop", ProjectID: "test-project", URL: "https://shop.run.app", Labels: map[string]string{
+			"traefik_enable":                 "true",
+			"traefik_http_routers_shop_rule": "PathPrefix(`/shop`)",
+			"traefik_cors_origins":           "https://app.example.com",
+			"traefik_errors_status":          "502-503",
+			"traefik_errors_service":         "warmup-page",
+			"traefik_errors_query":           "/warming-up.html",
+		}},
+		{Name: "warmup-page", ProjectID: "test-project", URL: "https://warmup-page.run.app", Labels: map[string]string{
+			"traefik_enable":                        "true",
+			"traefik_http_routers_warmup-page_rule": "PathPrefix(`/warming-up.html`)",
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	mw, ok := config.HTTP.Middlewares["t1-shop-errors"]
+	if !ok || mw.Errors == nil || mw.Errors.Service != "t1-warmup-page" {
+		t.Fatalf("Expected the errors middleware to point at the prefixed error page service, got %+v", mw.Errors)
+	}
+	if middlewares := config.HTTP.Routers["t1-shop"].Middlewares; len(middlewares) < 2 ||
+		middlewares[0] != "t1-shop-cors" || middlewares[1] != "t1-shop-errors" {
+		t.Errorf("Expected CORS then error pages first on the router, got %v", middlewares)
+	}
+	if problems := unknownLabels(map[string]string{
+		"traefik_errors_query":                        "/{status}.html",
+		"traefik_http_middlewares_maint_errors_query": "/",
+	}); len(problems) != 0 {
+		t.Errorf("Expected errors labels to be recognized, got %v", problems)
+	}
+}
@@ -0,0 +1,69 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/internal/gcp"
+)
+
+// Sentinel errors for use with errors.Is
+var (
+	// ErrMetadataUnavailable means the GCP metadata server couldn't provide an identity token
+	ErrMetadataUnavailable = gcp.ErrMetadataUnavailable
+
+	// ErrADCMissing means Application Default Credentials couldn't provide an identity token
+	ErrADCMissing = gcp.ErrADCMissing
+
+	// ErrNoRouterLabels means a service has no traefik router labels and is skipped
+	ErrNoRouterLabels = errors.New("no router labels found")
+
+	// ErrInvalidRule means none of a service's routers has a valid rule
+	ErrInvalidRule = errors.New("invalid router rule")
+
+	// ErrCatchAllRule means all of a service's routers match every request
+	// and the service isn't allowed to define catch-all routes
+	ErrCatchAllRule = errors.New("catch-all rule not allowed")
+
+	// ErrRulePolicy means rule policies reject all of a service's routers
+	ErrRulePolicy = errors.New("rule rejected by policy")
+
+	// ErrUnknownLabels means a service has unrecognized traefik_* labels and
+	// LabelValidation is strict
+	ErrUnknownLabels = errors.New("unrecognized traefik labels")
+
+	// ErrInvalidToken means a fetched token doesn't look like a JWT
+	ErrInvalidToken = errors.New("token doesn't look valid (should start with eyJ for JWT)")
+
+	// ErrAccessTokensUnsupported means a service asks for an access token but
+	// the token source only mints identity tokens
+	ErrAccessTokensUnsupported = errors.New("token source doesn't provide access tokens")
+
+	// ErrAccessTokenNotAllowed means a service asks for an access token but
+	// doesn't match an AccessTokenServices pattern
+	ErrAccessTokenNotAllowed = errors.New("service isn't allowed access tokens (see ACCESS_TOKEN_SERVICES)")
+
+	// ErrUnknownAuthProvider means a service's traefik_auth_provider label
+	// names an auth provider that isn't configured
+	ErrUnknownAuthProvider = errors.New("unknown auth provider")
+
+	// ErrAuthProviderNotBound means a service's traefik_auth_provider label
+	// names an auth provider whose projects and services don't include it
+	ErrAuthProviderNotBound = errors.New("auth provider not bound to service")
+)
+
+// TokenError is returned when an identity token can't be obtained for a service.
+// Use errors.As to get the service, and errors.Is on the result to find the cause.
+type TokenError struct {
+	Service string // Cloud Run service name
+	URL     string // Token audience
+	Err     error  // Underlying error
+}
+
+func (e *TokenError) Error() string {
+	return fmt.Sprintf("failed to fetch identity token for service %s: %v", e.Service, e.Err)
+}
+
+func (e *TokenError) Unwrap() error {
+	return e.Err
+}
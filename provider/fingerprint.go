@@ -0,0 +1,229 @@
+package provider
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// defaultFragmentMaxAge bounds how long a cached service fragment is reused.
+// Fragments embed identity tokens, so they must be rebuilt well before the
+// token expires (GCP identity tokens last 1 hour).
+const defaultFragmentMaxAge = 30 * time.Minute
+
+// serviceFragment is the configuration generated for a single Cloud Run service
+type serviceFragment struct {
+	fingerprint string
+	builtAt     time.Time
+	config      *DynamicConfig
+}
+
+// projectSnapshot records what a project's services added to the last
+// configuration, so that Build can replay it while the project's list
+// fingerprint (see listVersion) is unchanged
+type projectSnapshot struct {
+	version string
+	enabled int // Traefik-enabled services
+	steps   []projectStep
+}
+
+// projectStep is the outcome of one Traefik-enabled service of a project
+type projectStep struct {
+	key         string
+	name        string
+	url         string           // URL routed to, empty if the service was skipped
+	fingerprint string           // Fingerprint of the service's cached fragment
+	skipped     []SkippedService // Recorded before the fragment was merged
+}
+
+// fragmentCache stores the last generated fragment per service so that polls
+// only re-run token fetch and router generation for services that changed.
+type fragmentCache struct {
+	mu       sync.Mutex
+	entries  map[string]*serviceFragment
+	projects map[string]*projectSnapshot
+	maxAge   time.Duration
+}
+
+// newFragmentCache creates a fragment cache from the provider configuration
+func newFragmentCache(config *Config) *fragmentCache {
+	maxAge := config.FragmentMaxAge
+	if maxAge == 0 {
+		maxAge = defaultFragmentMaxAge
+	}
+	return &fragmentCache{
+		entries:  make(map[string]*serviceFragment),
+		projects: make(map[string]*projectSnapshot),
+		maxAge:   maxAge,
+	}
+}
+
+// lookup returns the cached fragment if the fingerprint matches and the
+// fragment is young enough to still carry a valid token
+func (c *fragmentCache) lookup(key, fingerprint string, now time.Time) (*DynamicConfig, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || entry.fingerprint != fingerprint || now.Sub(entry.builtAt) >= c.maxAge {
+		return nil, false
+	}
+	return entry.config, true
+}
+
+// store saves a freshly generated fragment
+func (c *fragmentCache) store(key, fingerprint string, fragment *DynamicConfig, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[key] = &serviceFragment{
+		fingerprint: fingerprint,
+		builtAt:     now,
+		config:      fragment,
+	}
+}
+
+// replay returns the fragments of the project's snapshot, in order, if the
+// project's list fingerprint is unchanged and every fragment is still cached
+// and young enough. Skipped services have a nil fragment.
+func (c *fragmentCache) replay(projectID, version string, now time.Time) (*projectSnapshot, []*DynamicConfig, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	snapshot, ok := c.projects[projectID]
+	if !ok || snapshot.version != version {
+		return nil, nil, false
+	}
+	fragments := make([]*DynamicConfig, len(snapshot.steps))
+	for i, step := range snapshot.steps {
+		if step.fingerprint == "" {
+			continue
+		}
+		entry, ok := c.entries[step.key]
+		if !ok || entry.fingerprint != step.fingerprint || now.Sub(entry.builtAt) >= c.maxAge {
+			return nil, nil, false
+		}
+		fragments[i] = entry.config
+	}
+	return snapshot, fragments, true
+}
+
+// storeProject saves the snapshot of a project
+func (c *fragmentCache) storeProject(projectID string, snapshot *projectSnapshot) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.projects[projectID] = snapshot
+}
+
+// dropProject forgets the snapshot of a project
+func (c *fragmentCache) dropProject(projectID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.projects, projectID)
+}
+
+// clear drops every cached fragment
+func (c *fragmentCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]*serviceFragment)
+	c.projects = make(map[string]*projectSnapshot)
+}
+
+// retain drops fragments for services that were not seen in the last poll,
+// and the snapshots of projects that had no services in it
+func (c *fragmentCache) retain(seen map[string]bool, seenProjects map[string]bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key := range c.entries {
+		if !seen[key] {
+			delete(c.entries, key)
+		}
+	}
+	for projectID := range c.projects {
+		if !seenProjects[projectID] {
+			delete(c.projects, projectID)
+		}
+	}
+}
+
+// fragmentKey identifies a service across projects and Anthos namespaces
+func fragmentKey(service CloudRunService) string {
+	if service.Platform == PlatformGKE {
+		return service.ProjectID + "/" + service.Cluster + "/" + service.Namespace + "/" + service.Name
+	}
+	return service.ProjectID + "/" + service.Name
+}
+
+// serviceFingerprint hashes everything that influences the generated config
+// for a service: its labels, URL, serving revision, scaling bounds and
+// request timeout
+func serviceFingerprint(service CloudRunService) string {
+	keys := make([]string, 0, len(service.Labels))
+	for k := range service.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	for _, k := range keys {
+		h.Write([]byte(k + "=" + service.Labels[k]))
+		h.Write([]byte{0})
+	}
+	h.Write([]byte("url=" + service.URL))
+	h.Write([]byte{0})
+	h.Write([]byte("revision=" + service.Revision))
+	h.Write([]byte{0})
+	h.Write([]byte(fmt.Sprintf("scale=%s-%s", scaleString(service.MinScale), scaleString(service.MaxScale))))
+	h.Write([]byte{0})
+	h.Write([]byte("timeout=" + service.RequestTimeout.String()))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// merge copies a service fragment into the configuration. Routers go through
+// AddRouterWithSource so conflict resolution behaves the same as a full rebuild.
+func (c *DynamicConfig) merge(fragment *DynamicConfig, sourceName string) {
+	for name, router := range fragment.HTTP.Routers {
+		c.AddRouterWithSource(name, router, sourceName)
+	}
+	for name, service := range fragment.HTTP.Services {
+		c.HTTP.Services[name] = service
+	}
+	for name, mw := range fragment.HTTP.Middlewares {
+		c.HTTP.Middlewares[name] = mw
+	}
+	for name, transport := range fragment.HTTP.ServersTransports {
+		c.AddServersTransport(name, transport)
+	}
+	c.skipped = append(c.skipped, fragment.skipped...)
+	c.deprecations = append(c.deprecations, fragment.deprecations...)
+}
+
+// degraded reports whether a fragment recorded a degraded service or a
+// missing token, so it isn't worth caching
+func (c *DynamicConfig) degraded() bool {
+	return degradedSkips(c.skipped)
+}
+
+// degradedSkips reports whether any of the skipped services is degraded or
+// routed without a token
+func degradedSkips(skipped []SkippedService) bool {
+	for _, s := range skipped {
+		if s.Degraded || s.Reason == SkipReasonNoAuth || s.Reason == SkipReasonTokenFailure {
+			return true
+		}
+	}
+	return false
+}
+
+// scaleString formats an optional instance count for fingerprinting
+func scaleString(n *int) string {
+	if n == nil {
+		return "unset"
+	}
+	return fmt.Sprint(*n)
+}
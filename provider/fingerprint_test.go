@@ -0,0 +1,313 @@
+package provider
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestServiceFingerprint(t *testing.T) {
+	base := CloudRunService{
+		Name:     "lab1-stg",
+		URL:      "https://lab1.run.app",
+		Revision: "lab1-stg-00001-abc",
+		Labels: map[string]string{
+			"traefik_enable":                 "true",
+			"traefik_http_routers_lab1_rule": "PathPrefix(`/lab1`)",
+		},
+	}
+
+	if serviceFingerprint(base) != serviceFingerprint(base) {
+		t.Fatal("Expected fingerprint to be deterministic")
+	}
+
+	changedURL := base
+	changedURL.URL = "https://other.run.app"
+	if serviceFingerprint(changedURL) == serviceFingerprint(base) {
+		t.Error("Expected URL change to change fingerprint")
+	}
+
+	changedRevision := base
+	changedRevision.Revision = "lab1-stg-00002-def"
+	if serviceFingerprint(changedRevision) == serviceFingerprint(base) {
+		t.Error("Expected revision change to change fingerprint")
+	}
+
+	changedLabels := base
+	changedLabels.Labels = map[string]string{
+		"traefik_enable":                 "true",
+		"traefik_http_routers_lab1_rule": "PathPrefix(`/lab1-new`)",
+	}
+	if serviceFingerprint(changedLabels) == serviceFingerprint(base) {
+		t.Error("Expected label change to change fingerprint")
+	}
+
+	minScale := 0
+	changedScale := base
+	changedScale.MinScale = &minScale
+	if serviceFingerprint(changedScale) == serviceFingerprint(base) {
+		t.Error("Expected scaling change to change fingerprint")
+	}
+}
+
+func TestFragmentCache_Lookup(t *testing.T) {
+	cache := newFragmentCache(&Config{FragmentMaxAge: time.Minute})
+	now := time.Now()
+	fragment := NewDynamicConfig()
+
+	cache.store("proj/svc", "fp1", fragment, now)
+
+	if _, ok := cache.lookup("proj/svc", "fp1", now.Add(30*time.Second)); !ok {
+		t.Error("Expected cached fragment for matching fingerprint")
+	}
+	if _, ok := cache.lookup("proj/svc", "fp2", now); ok {
+		t.Error("Expected miss for changed fingerprint")
+	}
+	if _, ok := cache.lookup("proj/svc", "fp1", now.Add(2*time.Minute)); ok {
+		t.Error("Expected miss once fragment exceeds max age")
+	}
+
+	cache.retain(map[string]bool{}, map[string]bool{})
+	if _, ok := cache.lookup("proj/svc", "fp1", now); ok {
+		t.Error("Expected fragment for unseen service to be dropped")
+	}
+}
+
+func TestProcessServiceIncremental_ReusesFragment(t *testing.T) {
+	config := &Config{
+		ProjectIDs:         []string{"test-project"},
+		Region:             "us-central1",
+		IncrementalUpdates: true,
+	}
+
+	provider, err := NewWithClients(config, &fakeCloudRunClient{}, &fakeTokenSource{token: "eyJfake"}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	service := CloudRunService{
+		Name:      "test-service",
+		ProjectID: "test-project",
+		URL:       "https://test-service.run.app",
+		Labels: map[string]string{
+			"traefik_enable":                 "true",
+			"traefik_http_routers_test_rule": "Host(`example.com`)",
+		},
+	}
+
+	first := NewDynamicConfig()
+	if err := provider.processServiceIncremental(service, first); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Tamper with the cached fragment: a reused fragment shows the change,
+	// a regenerated one would not
+	cached, ok := provider.fragments.lookup(fragmentKey(service), serviceFingerprint(service), time.Now())
+	if !ok {
+		t.Fatal("Expected fragment to be cached after first pass")
+	}
+	router := cached.HTTP.Routers["test"]
+	router.Priority = 12345
+	cached.HTTP.Routers["test"] = router
+
+	second := NewDynamicConfig()
+	if err := provider.processServiceIncremental(service, second); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if second.HTTP.Routers["test"].Priority != 12345 {
+		t.Error("Expected unchanged service to reuse cached fragment")
+	}
+
+	service.Labels["traefik_http_routers_test_priority"] = "50"
+	third := NewDynamicConfig()
+	if err := provider.processServiceIncremental(service, third); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if third.HTTP.Routers["test"].Priority != 50 {
+		t.Errorf("Expected changed service to be regenerated, got priority %d", third.HTTP.Routers["test"].Priority)
+	}
+}
+
+// rotatingTokenSource mints a new token per fetch and caches it like
+// gcp.TokenManager, including its invalidation methods
+type rotatingTokenSource struct {
+	minted int
+	cache  map[string]string
+}
+
+func (s *rotatingTokenSource) GetToken(audience string) (string, error) {
+	if token, ok := s.cache[audience]; ok {
+		return token, nil
+	}
+	s.minted++
+	s.cache[audience] = fmt.Sprintf("eyJtoken-%d", s.minted)
+	return s.cache[audience], nil
+}
+
+func (s *rotatingTokenSource) Invalidate(audience string) bool {
+	_, ok := s.cache[audience]
+	delete(s.cache, audience)
+	return ok
+}
+
+func (s *rotatingTokenSource) ClearCache() { s.cache = make(map[string]string) }
+
+func TestInvalidateTokens(t *testing.T) {
+	tokens := &rotatingTokenSource{cache: make(map[string]string)}
+	p, err := NewWithClients(&Config{
+		ProjectIDs:         []string{"test-project"},
+		Region:             "us-central1",
+		IncrementalUpdates: true,
+	}, &fakeCloudRunClient{}, tokens, nil)
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	services := []CloudRunService{}
+	for _, name := range []string{"lab1", "lab2"} {
+		services = append(services, CloudRunService{
+			Name: name, ProjectID: "test-project", URL: "https://" + name + ".run.app",
+			Labels: map[string]string{
+				"traefik_enable":                         "true",
+				"traefik_http_routers_" + name + "_rule": "PathPrefix(`/" + name + "`)",
+			},
+		})
+	}
+	authToken := func(config *DynamicConfig, name string) string {
+		return config.HTTP.Middlewares[name+"-auth"].Headers.CustomRequestHeaders["X-Serverless-Authorization"]
+	}
+
+	build := func() *DynamicConfig {
+		t.Helper()
+		config, err := p.Build(services)
+		if err != nil {
+			t.Fatalf("Build failed: %v", err)
+		}
+		return config
+	}
+
+	first := build()
+	p.InvalidateTokens("https://lab1.run.app")
+	second := build()
+	if authToken(second, "lab1") == authToken(first, "lab1") {
+		t.Error("Expected lab1 token to be re-minted despite the cached fragment")
+	}
+	if authToken(second, "lab2") != authToken(first, "lab2") {
+		t.Error("Expected lab2 token to be kept")
+	}
+
+	p.InvalidateTokens()
+	third := build()
+	if authToken(third, "lab1") == authToken(second, "lab1") || authToken(third, "lab2") == authToken(second, "lab2") {
+		t.Error("Expected every token to be re-minted")
+	}
+}
+
+// flakyTokenSource fails while err is set
+type flakyTokenSource struct {
+	err error
+}
+
+func (s *flakyTokenSource) GetToken(string) (string, error) {
+	if s.err != nil {
+		return "", s.err
+	}
+	return "eyJrecovered", nil
+}
+
+func TestProcessServiceIncremental_DoesNotCacheDegraded(t *testing.T) {
+	tokens := &flakyTokenSource{err: ErrMetadataUnavailable}
+	p, err := NewWithClients(&Config{
+		ProjectIDs:         []string{"test-project"},
+		Region:             "us-central1",
+		IncrementalUpdates: true,
+		TokenFailurePolicy: TokenFailureEmitWithoutAuth,
+	}, &fakeCloudRunClient{}, tokens, nil)
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	services := []CloudRunService{{
+		Name: "lab1", ProjectID: "test-project", URL: "https://lab1.run.app",
+		Labels: map[string]string{
+			"traefik_enable":                 "true",
+			"traefik_http_routers_lab1_rule": "PathPrefix(`/lab1`)",
+		},
+	}}
+
+	first, err := p.Build(services)
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	if _, ok := first.HTTP.Middlewares["lab1-auth"]; ok {
+		t.Fatal("Expected lab1 routed without auth while tokens fail")
+	}
+
+	tokens.err = nil
+	second, err := p.Build(services)
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	if _, ok := second.HTTP.Middlewares["lab1-auth"]; !ok {
+		t.Errorf("Expected the auth middleware back once tokens recover, got routers %+v", second.HTTP.Routers)
+	}
+}
+
+func TestBuild_ReplaysUnchangedProject(t *testing.T) {
+	p, err := NewWithClients(&Config{
+		ProjectIDs:         []string{"proj-a", "proj-b"},
+		Region:             "us-central1",
+		IncrementalUpdates: true,
+		ExcludeServices:    []string{"hidden"},
+	}, &fakeCloudRunClient{}, &fakeTokenSource{token: "eyJtest"}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	service := func(project, name, rule string) CloudRunService {
+		return CloudRunService{
+			Name: name, ProjectID: project, URL: "https://" + name + ".run.app", ResourceVersion: "1",
+			Labels: map[string]string{
+				"traefik_enable":                         "true",
+				"traefik_http_routers_" + name + "_rule": rule,
+			},
+		}
+	}
+	services := []CloudRunService{
+		service("proj-a", "lab1", "PathPrefix(`/lab1`)"),
+		service("proj-a", "hidden", "PathPrefix(`/hidden`)"),
+		service("proj-b", "lab2", "PathPrefix(`/lab2`)"),
+	}
+	build := func() *DynamicConfig {
+		t.Helper()
+		config, err := p.Build(services)
+		if err != nil {
+			t.Fatalf("Build failed: %v", err)
+		}
+		return config
+	}
+	build()
+
+	// Same resourceVersions: proj-a is replayed, so its new rule goes unseen
+	services[0].Labels["traefik_http_routers_lab1_rule"] = "PathPrefix(`/changed`)"
+	second := build()
+	if rule := second.HTTP.Routers["lab1"].Rule; rule != "PathPrefix(`/lab1`)" {
+		t.Errorf("Expected proj-a replayed with its previous rule, got %s", rule)
+	}
+	if _, ok := second.HTTP.Routers["lab2"]; !ok {
+		t.Error("Expected proj-b's router")
+	}
+	if skipped := second.Skipped(); len(skipped) != 1 || skipped[0].Service != "hidden" {
+		t.Errorf("Expected the filtered service replayed as skipped, got %+v", skipped)
+	}
+
+	services[0].ResourceVersion = "2"
+	if rule := build().HTTP.Routers["lab1"].Rule; rule != "PathPrefix(`/changed`)" {
+		t.Errorf("Expected proj-a processed again once its list changed, got %s", rule)
+	}
+
+	// Invalidated tokens drop the fragments a snapshot replays
+	services[0].Labels["traefik_http_routers_lab1_rule"] = "PathPrefix(`/again`)"
+	p.InvalidateTokens()
+	if rule := build().HTTP.Routers["lab1"].Rule; rule != "PathPrefix(`/again`)" {
+		t.Errorf("Expected proj-a processed again after token invalidation, got %s", rule)
+	}
+}
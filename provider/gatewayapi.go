@@ -0,0 +1,266 @@
+package provider
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Gateway API defaults
+const (
+	DefaultGatewayName      = "traefik-gateway"
+	DefaultGatewayNamespace = "default"
+)
+
+// GatewayAPIOptions configures the Kubernetes Gateway API export
+type GatewayAPIOptions struct {
+	Namespace        string // Namespace of the generated HTTPRoutes and Services (default: default)
+	GatewayName      string // Gateway the HTTPRoutes attach to (default: traefik-gateway)
+	GatewayNamespace string // Namespace of that Gateway (default: Namespace)
+}
+
+// withDefaults fills unset fields
+func (o GatewayAPIOptions) withDefaults() GatewayAPIOptions {
+	if o.Namespace == "" {
+		o.Namespace = DefaultGatewayNamespace
+	}
+	if o.GatewayName == "" {
+		o.GatewayName = DefaultGatewayName
+	}
+	if o.GatewayNamespace == "" {
+		o.GatewayNamespace = o.Namespace
+	}
+	return o
+}
+
+// K8sObjectMeta is the metadata of a generated Kubernetes object
+type K8sObjectMeta struct {
+	Name      string            `yaml:"name"`
+	Namespace string            `yaml:"namespace"`
+	Labels    map[string]string `yaml:"labels,omitempty"`
+}
+
+// HTTPRoute is a gateway.networking.k8s.io/v1 HTTPRoute
+type HTTPRoute struct {
+	APIVersion string        `yaml:"apiVersion"`
+	Kind       string        `yaml:"kind"`
+	Metadata   K8sObjectMeta `yaml:"metadata"`
+	Spec       HTTPRouteSpec `yaml:"spec"`
+}
+
+// HTTPRouteSpec is the spec of an HTTPRoute
+type HTTPRouteSpec struct {
+	ParentRefs []ParentRef     `yaml:"parentRefs"`
+	Hostnames  []string        `yaml:"hostnames,omitempty"`
+	Rules      []HTTPRouteRule `yaml:"rules"`
+}
+
+// ParentRef references the Gateway an HTTPRoute attaches to
+type ParentRef struct {
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace,omitempty"`
+}
+
+// HTTPRouteRule matches requests and forwards them to backends
+type HTTPRouteRule struct {
+	Matches     []HTTPRouteMatch  `yaml:"matches,omitempty"`
+	Filters     []HTTPRouteFilter `yaml:"filters,omitempty"`
+	BackendRefs []BackendRef      `yaml:"backendRefs"`
+}
+
+// HTTPRouteMatch matches a request path
+type HTTPRouteMatch struct {
+	Path HTTPPathMatch `yaml:"path"`
+}
+
+// HTTPPathMatch is a path match of type Exact or PathPrefix
+type HTTPPathMatch struct {
+	Type  string `yaml:"type"`
+	Value string `yaml:"value"`
+}
+
+// HTTPRouteFilter modifies requests; only URLRewrite is generated
+type HTTPRouteFilter struct {
+	Type       string          `yaml:"type"`
+	URLRewrite *URLRewriteSpec `yaml:"urlRewrite,omitempty"`
+}
+
+// URLRewriteSpec rewrites the request hostname
+type URLRewriteSpec struct {
+	Hostname string `yaml:"hostname,omitempty"`
+}
+
+// BackendRef forwards to a Kubernetes Service
+type BackendRef struct {
+	Name string `yaml:"name"`
+	Port int    `yaml:"port"`
+}
+
+// K8sService is a v1 Service of type ExternalName pointing at a Cloud Run URL
+type K8sService struct {
+	APIVersion string         `yaml:"apiVersion"`
+	Kind       string         `yaml:"kind"`
+	Metadata   K8sObjectMeta  `yaml:"metadata"`
+	Spec       K8sServiceSpec `yaml:"spec"`
+}
+
+// K8sServiceSpec is the spec of an ExternalName Service
+type K8sServiceSpec struct {
+	Type         string           `yaml:"type"`
+	ExternalName string           `yaml:"externalName"`
+	Ports        []K8sServicePort `yaml:"ports"`
+}
+
+// K8sServicePort is a Service port
+type K8sServicePort struct {
+	Name        string `yaml:"name"`
+	Port        int    `yaml:"port"`
+	AppProtocol string `yaml:"appProtocol,omitempty"`
+}
+
+// managedByLabel marks objects generated by the provider
+var managedByLabel = map[string]string{"app.kubernetes.io/managed-by": "traefik-cloudrun-provider"}
+
+// ruleMatcher matches one Host, Path or PathPrefix call in a Traefik rule
+var ruleMatcher = regexp.MustCompile("^(Host|Path|PathPrefix)\\(`([^`]*)`\\)$")
+
+// GatewayAPIObjects converts the configuration into Kubernetes Gateway API
+// manifests: one HTTPRoute per router and one ExternalName Service per Cloud
+// Run backend. Objects are ordered Services first, then HTTPRoutes by
+// descending router priority (Gateway API has no priorities; its own
+// precedence rules favour longer paths).
+//
+// Only rules built from Host, Path and PathPrefix joined with || and && are
+// supported. Routers with other rules, and routers pointing at Traefik
+// internal services, are skipped; the returned warnings say why. Traefik
+// middlewares have no Gateway API equivalent and are not exported.
+func (c *DynamicConfig) GatewayAPIObjects(opts GatewayAPIOptions) ([]interface{}, []string) {
+	opts = opts.withDefaults()
+	var warnings []string
+
+	// Backend services, in name order
+	serviceNames := make([]string, 0, len(c.HTTP.Services))
+	for name := range c.HTTP.Services {
+		serviceNames = append(serviceNames, name)
+	}
+	sort.Strings(serviceNames)
+
+	var objects []interface{}
+	backendPorts := make(map[string]int)
+	backendHosts := make(map[string]string)
+	for _, name := range serviceNames {
+		service := c.HTTP.Services[name]
+		if len(service.LoadBalancer.Servers) == 0 {
+			continue
+		}
+		parsed, err := url.Parse(service.LoadBalancer.Servers[0].URL)
+		if err != nil || parsed.Hostname() == "" {
+			warnings = append(warnings, fmt.Sprintf("service %s: invalid URL %q, skipping", name, service.LoadBalancer.Servers[0].URL))
+			continue
+		}
+
+		port := K8sServicePort{Name: "https", Port: 443}
+		switch parsed.Scheme {
+		case "http":
+			port = K8sServicePort{Name: "http", Port: 80}
+		case "h2c":
+			port = K8sServicePort{Name: "h2c", Port: 80, AppProtocol: "kubernetes.io/h2c"}
+		}
+		backendPorts[name] = port.Port
+		backendHosts[name] = parsed.Hostname()
+
+		objects = append(objects, K8sService{
+			APIVersion: "v1",
+			Kind:       "Service",
+			Metadata:   K8sObjectMeta{Name: name, Namespace: opts.Namespace, Labels: managedByLabel},
+			Spec: K8sServiceSpec{
+				Type:         "ExternalName",
+				ExternalName: parsed.Hostname(),
+				Ports:        []K8sServicePort{port},
+			},
+		})
+	}
+
+	// Routers, highest priority first (name breaks ties for stable output)
+	routerNames := make([]string, 0, len(c.HTTP.Routers))
+	for name := range c.HTTP.Routers {
+		routerNames = append(routerNames, name)
+	}
+	sort.Slice(routerNames, func(i, j int) bool {
+		a, b := c.HTTP.Routers[routerNames[i]], c.HTTP.Routers[routerNames[j]]
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		return routerNames[i] < routerNames[j]
+	})
+
+	for _, name := range routerNames {
+		router := c.HTTP.Routers[name]
+		if strings.HasSuffix(router.Service, "@internal") {
+			continue
+		}
+		port, ok := backendPorts[router.Service]
+		if !ok {
+			warnings = append(warnings, fmt.Sprintf("router %s: service %s not exported, skipping", name, router.Service))
+			continue
+		}
+		hostnames, matches, err := translateRule(router.Rule)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("router %s: %v, skipping", name, err))
+			continue
+		}
+
+		objects = append(objects, HTTPRoute{
+			APIVersion: "gateway.networking.k8s.io/v1",
+			Kind:       "HTTPRoute",
+			Metadata:   K8sObjectMeta{Name: name, Namespace: opts.Namespace, Labels: managedByLabel},
+			Spec: HTTPRouteSpec{
+				ParentRefs: []ParentRef{{Name: opts.GatewayName, Namespace: opts.GatewayNamespace}},
+				Hostnames:  hostnames,
+				Rules: []HTTPRouteRule{{
+					Matches: matches,
+					// Cloud Run routes on the Host header, so it must be the run.app host
+					Filters: []HTTPRouteFilter{{
+						Type:       "URLRewrite",
+						URLRewrite: &URLRewriteSpec{Hostname: backendHosts[router.Service]},
+					}},
+					BackendRefs: []BackendRef{{Name: router.Service, Port: port}},
+				}},
+			},
+		})
+	}
+
+	return objects, warnings
+}
+
+// translateRule converts a Traefik rule into HTTPRoute hostnames and path
+// matches. Alternatives joined with || each contribute a match; Host calls
+// (in any alternative) become route hostnames.
+func translateRule(rule string) ([]string, []HTTPRouteMatch, error) {
+	if strings.TrimSpace(rule) == "" {
+		return nil, nil, fmt.Errorf("empty rule")
+	}
+
+	var hostnames []string
+	var matches []HTTPRouteMatch
+	for _, alternative := range strings.Split(rule, "||") {
+		for _, term := range strings.Split(alternative, "&&") {
+			term = strings.TrimSpace(term)
+			m := ruleMatcher.FindStringSubmatch(term)
+			if m == nil {
+				return nil, nil, fmt.Errorf("unsupported rule expression %q", term)
+			}
+			switch m[1] {
+			case "Host":
+				hostnames = appendMissing(hostnames, m[2])
+			case "Path":
+				matches = append(matches, HTTPRouteMatch{Path: HTTPPathMatch{Type: "Exact", Value: m[2]}})
+			case "PathPrefix":
+				matches = append(matches, HTTPRouteMatch{Path: HTTPPathMatch{Type: "PathPrefix", Value: m[2]}})
+			}
+		}
+	}
+	return hostnames, matches, nil
+}
@@ -0,0 +1,64 @@
+package provider
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTranslateRule(t *testing.T) {
+	hostnames, matches, err := translateRule("Host(`labs.example.com`) && PathPrefix(`/lab1`) || Path(`/lab1/health`)")
+	if err != nil {
+		t.Fatalf("Expected rule to translate, got: %v", err)
+	}
+	if len(hostnames) != 1 || hostnames[0] != "labs.example.com" {
+		t.Errorf("Expected hostname labs.example.com, got %v", hostnames)
+	}
+	if len(matches) != 2 ||
+		matches[0].Path != (HTTPPathMatch{Type: "PathPrefix", Value: "/lab1"}) ||
+		matches[1].Path != (HTTPPathMatch{Type: "Exact", Value: "/lab1/health"}) {
+		t.Errorf("Unexpected matches: %+v", matches)
+	}
+
+	if _, _, err := translateRule("PathRegexp(`^/lab[0-9]+`)"); err == nil {
+		t.Error("Expected unsupported matcher to fail")
+	}
+}
+
+func TestGatewayAPIObjects(t *testing.T) {
+	config := NewDynamicConfig()
+	config.AddService("lab1", ServiceConfig{LoadBalancer: LoadBalancerConfig{
+		Servers: []ServerConfig{{URL: "https://lab1-123.us-central1.run.app"}},
+	}})
+	config.AddRouter("lab1", RouterConfig{Rule: "PathPrefix(`/lab1`)", Service: "lab1", Priority: 200})
+	config.AddRouter("lab1-regex", RouterConfig{Rule: "PathRegexp(`^/x`)", Service: "lab1", Priority: 300})
+	config.AddTraefikInternalRouters()
+
+	objects, warnings := config.GatewayAPIObjects(GatewayAPIOptions{Namespace: "edge"})
+
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "lab1-regex") {
+		t.Errorf("Expected one warning for lab1-regex, got %v", warnings)
+	}
+	if len(objects) != 2 {
+		t.Fatalf("Expected a Service and an HTTPRoute, got %d objects", len(objects))
+	}
+
+	service, ok := objects[0].(K8sService)
+	if !ok || service.Spec.ExternalName != "lab1-123.us-central1.run.app" || service.Spec.Ports[0].Port != 443 {
+		t.Errorf("Unexpected Service: %+v", objects[0])
+	}
+
+	route, ok := objects[1].(HTTPRoute)
+	if !ok {
+		t.Fatalf("Expected HTTPRoute, got %T", objects[1])
+	}
+	if route.Metadata.Namespace != "edge" || route.Spec.ParentRefs[0] != (ParentRef{Name: DefaultGatewayName, Namespace: "edge"}) {
+		t.Errorf("Unexpected route metadata or parent: %+v", route)
+	}
+	rule := route.Spec.Rules[0]
+	if rule.BackendRefs[0] != (BackendRef{Name: "lab1", Port: 443}) {
+		t.Errorf("Unexpected backendRef: %+v", rule.BackendRefs)
+	}
+	if rule.Filters[0].URLRewrite.Hostname != "lab1-123.us-central1.run.app" {
+		t.Errorf("Expected Host rewrite to the run.app host, got %+v", rule.Filters)
+	}
+}
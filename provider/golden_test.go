@@ -0,0 +1,13 @@
+package provider_test
+
+import (
+	"testing"
+
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/provider/providertest"
+)
+
+// TestGolden checks the routes generated from each label fixture in
+// testdata/golden. Run with UPDATE_GOLDEN=1 to regenerate the golden files.
+func TestGolden(t *testing.T) {
+	providertest.RunGolden(t, "testdata/golden")
+}
@@ -0,0 +1,168 @@
+package provider
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// corsLabelPrefix starts the CORS shorthand labels, which define middleware
+// <service>-cors and put it first on every router of the service so
+// preflight requests are answered before any auth middleware runs:
+//
+//	traefik_cors_origins=https://app.example.com__https://admin.example.com
+//	traefik_cors_methods=GET__POST__OPTIONS
+//	traefik_cors_headers=Authorization__Content-Type
+//	traefik_cors_maxage=600
+const corsLabelPrefix = "traefik_cors_"
+
+// corsProperties maps the CORS shorthand properties to the headers
+// middleware properties they set
+var corsProperties = map[string]string{
+	"origins":       "accesscontrolalloworiginlist",
+	"originsregex":  "accesscontrolalloworiginlistregex",
+	"methods":       "accesscontrolallowmethods",
+	"headers":       "accesscontrolallowheaders",
+	"exposeheaders": "accesscontrolexposeheaders",
+	"maxage":        "accesscontrolmaxage",
+	"credentials":   "accesscontrolallowcredentials",
+}
+
+// Prefixes of the headers middleware properties that set one header,
+// e.g. traefik_http_middlewares_<name>_headers_customresponseheaders_x-frame-options
+const (
+	customRequestHeadersPrefix  = "customrequestheaders_"
+	customResponseHeadersPrefix = "customresponseheaders_"
+)
+
+// headersProperties are the properties of
+// traefik_http_middlewares_<name>_headers_<property>: Traefik's CORS options,
+// lowercased. Custom headers use the custom*headers_ prefixes.
+var headersProperties = map[string]bool{
+	"accesscontrolalloworiginlist":      true,
+	"accesscontrolalloworiginlistregex": true,
+	"accesscontrolallowmethods":         true,
+	"accesscontrolallowheaders":         true,
+	"accesscontrolexposeheaders":        true,
+	"accesscontrolmaxage":               true,
+	"accesscontrolallowcredentials":     true,
+	"addvaryheader":                     true,
+}
+
+// corsMiddlewareName is the name of the middleware the CORS shorthand
+// labels define
+func corsMiddlewareName(serviceName string) string {
+	return serviceName + "-cors"
+}
+
+// validHeadersProperty reports whether property is a headers middleware
+// property: a CORS option or a custom request or response header
+func validHeadersProperty(property string) bool {
+	for _, prefix := range []string{customRequestHeadersPrefix, customResponseHeadersPrefix} {
+		if header, ok := strings.CutPrefix(property, prefix); ok {
+			return header != ""
+		}
+	}
+	return headersProperties[property]
+}
+
+// extractHeadersConfigs extracts headers middleware configurations (CORS
+// options and custom headers) from labels.
+//
+// Two label forms are supported:
+//   - traefik_http_middlewares_<name>_headers_<property> defines middleware <name>
+//   - traefik_cors_<property> is shorthand for the CORS options of middleware <service>-cors
+//
+// customrequestheaders_<header> and customresponseheaders_<header> set a
+// request or response header; an empty value removes the header instead.
+// Lists use the same separators as router middlewares (__, ; or ,).
+// Middlewares with CORS options but no allowed origins are dropped with a
+// warning.
+func extractHeadersConfigs(labels map[string]string, serviceName string) map[string]HeadersConfig {
+	configs := make(map[string]HeadersConfig)
+
+	for key, value := range labels {
+		var name, property string
+
+		if shorthand, ok := strings.CutPrefix(key, corsLabelPrefix); ok {
+			name = corsMiddlewareName(serviceName)
+			property, ok = corsProperties[shorthand]
+			if !ok {
+				fmt.Fprintf(os.Stderr, "   WARNING: Unknown CORS property %q for service %s, ignoring\n", shorthand, serviceName)
+				continue
+			}
+		} else if strings.HasPrefix(key, "traefik_http_middlewares_") {
+			// Parse: traefik_http_middlewares_<name>_headers_<property>
+			parts := strings.SplitN(key, "_", 6)
+			if len(parts) < 6 || parts[4] != "headers" {
+				continue
+			}
+			name = parts[3]
+			property = parts[5]
+		} else {
+			continue
+		}
+
+		headers := configs[name]
+		if header, ok := strings.CutPrefix(property, customRequestHeadersPrefix); ok && header != "" {
+			if headers.CustomRequestHeaders == nil {
+				headers.CustomRequestHeaders = make(map[string]string)
+			}
+			headers.CustomRequestHeaders[http.CanonicalHeaderKey(header)] = value
+			configs[name] = headers
+			continue
+		}
+		if header, ok := strings.CutPrefix(property, customResponseHeadersPrefix); ok && header != "" {
+			if headers.CustomResponseHeaders == nil {
+				headers.CustomResponseHeaders = make(map[string]string)
+			}
+			headers.CustomResponseHeaders[http.CanonicalHeaderKey(header)] = value
+			configs[name] = headers
+			continue
+		}
+		switch property {
+		case "accesscontrolalloworiginlist":
+			headers.AccessControlAllowOriginList = splitLabelList(value)
+		case "accesscontrolalloworiginlistregex":
+			headers.AccessControlAllowOriginListRegex = splitLabelList(value)
+		case "accesscontrolallowmethods":
+			headers.AccessControlAllowMethods = splitLabelList(strings.ToUpper(value))
+		case "accesscontrolallowheaders":
+			headers.AccessControlAllowHeaders = splitLabelList(value)
+		case "accesscontrolexposeheaders":
+			headers.AccessControlExposeHeaders = splitLabelList(value)
+		case "accesscontrolmaxage":
+			maxAge, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || maxAge < 0 {
+				fmt.Fprintf(os.Stderr, "   WARNING: Invalid CORS max age %q for middleware %s (expected seconds), ignoring\n", value, name)
+				break
+			}
+			headers.AccessControlMaxAge = maxAge
+		case "accesscontrolallowcredentials":
+			headers.AccessControlAllowCredentials = value == labelValueTrue
+		case "addvaryheader":
+			headers.AddVaryHeader = value == labelValueTrue
+		default:
+			fmt.Fprintf(os.Stderr, "   WARNING: Unknown headers property %q for middleware %s, ignoring\n", property, name)
+		}
+		configs[name] = headers
+	}
+
+	for name, headers := range configs {
+		if headers.hasCORS() && len(headers.AccessControlAllowOriginList) == 0 && len(headers.AccessControlAllowOriginListRegex) == 0 {
+			fmt.Fprintf(os.Stderr, "   WARNING: CORS middleware %s has no allowed origins, skipping\n", name)
+			delete(configs, name)
+		}
+	}
+
+	return configs
+}
+
+// hasCORS reports whether any CORS option is set
+func (h HeadersConfig) hasCORS() bool {
+	return h.AccessControlAllowCredentials || len(h.AccessControlAllowHeaders) > 0 || len(h.AccessControlAllowMethods) > 0 ||
+		len(h.AccessControlAllowOriginList) > 0 || len(h.AccessControlAllowOriginListRegex) > 0 ||
+		len(h.AccessControlExposeHeaders) > 0 || h.AccessControlMaxAge > 0 || h.AddVaryHeader
+}
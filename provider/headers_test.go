@@ -0,0 +1,108 @@
+package provider
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestExtractCORSConfigs(t *testing.T) {
+	labels := map[string]string{
+		"traefik_http_middlewares_api-cors_headers_accesscontrolalloworiginlist":  "https://a.example.com__https://b.example.com",
+		"traefik_http_middlewares_api-cors_headers_accesscontrolallowcredentials": "true",
+		"traefik_http_middlewares_api-cors_headers_addvaryheader":                 "true",
+		"traefik_cors_origins": "https://app.example.com",
+		"traefik_cors_methods": "get__post__options",
+		"traefik_cors_headers": "Authorization__Content-Type",
+		"traefik_cors_maxage":  "600",
+		"traefik_http_middlewares_noorigin_headers_accesscontrolmaxage": "60",
+		"traefik_http_middlewares_sso_forwardauth_address":              "http://auth.internal/verify",
+	}
+
+	configs := extractHeadersConfigs(labels, "my-svc")
+	if len(configs) != 2 {
+		t.Fatalf("Expected 2 CORS configs, got %d: %v", len(configs), configs)
+	}
+
+	want := HeadersConfig{
+		AccessControlAllowOriginList:  []string{"https://a.example.com", "https://b.example.com"},
+		AccessControlAllowCredentials: true,
+		AddVaryHeader:                 true,
+	}
+	if got := configs["api-cors"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("api-cors = %+v, want %+v", got, want)
+	}
+
+	want = HeadersConfig{
+		AccessControlAllowOriginList: []string{"https://app.example.com"},
+		AccessControlAllowMethods:    []string{"GET", "POST", "OPTIONS"},
+		AccessControlAllowHeaders:    []string{"Authorization", "Content-Type"},
+		AccessControlMaxAge:          600,
+	}
+	if got := configs["my-svc-cors"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("my-svc-cors = %+v, want %+v", got, want)
+	}
+}
+
+func TestExtractHeadersConfigs_CustomHeaders(t *testing.T) {
+	labels := map[string]string{
+		"traefik_http_middlewares_secure_headers_customresponseheaders_x-frame-options": "DENY",
+		"traefik_http_middlewares_secure_headers_customresponseheaders_server":          "",
+		"traefik_http_middlewares_secure_headers_customrequestheaders_x-forwarded-by":   "traefik",
+	}
+
+	configs := extractHeadersConfigs(labels, "my-svc")
+	want := HeadersConfig{
+		CustomRequestHeaders:  map[string]string{"X-Forwarded-By": "traefik"},
+		CustomResponseHeaders: map[string]string{"X-Frame-Options": "DENY", "Server": ""},
+	}
+	if got, ok := configs["secure"]; !ok || !reflect.DeepEqual(got, want) {
+		t.Errorf("secure = %+v, want %+v", got, want)
+	}
+
+	// The empty value is written out so Traefik removes the header
+	config := NewDynamicConfig()
+	headers := configs["secure"]
+	config.AddMiddleware("secure", MiddlewareConfig{Headers: &headers})
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		t.Fatalf("Failed to marshal: %v", err)
+	}
+	if !strings.Contains(string(data), `Server: ""`) {
+		t.Errorf("Expected the removed header in the YAML, got:\n%s", data)
+	}
+}
+
+func TestProcessService_CORSShorthand(t *testing.T) {
+	p, err := newProvider(&Config{
+		ProjectIDs:     []string{"test-project"},
+		Region:         "us-central1",
+		TokenInjection: TokenInjectionPlugin,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	config := NewDynamicConfig()
+	err = p.processService(CloudRunService{Name: "api", ProjectID: "test-project", URL: "https://api.run.app", Labels: map[string]string{
+		"traefik_enable":                 "true",
+		"traefik_http_routers_api_rule":  "PathPrefix(`/api`)",
+		"traefik_http_routers_docs_rule": "PathPrefix(`/docs`)",
+		"traefik_cors_origins":           "https://app.example.com",
+	}}, config)
+	if err != nil {
+		t.Fatalf("processService failed: %v", err)
+	}
+
+	mw, ok := config.HTTP.Middlewares["api-cors"]
+	if !ok || mw.Headers == nil || len(mw.Headers.AccessControlAllowOriginList) != 1 {
+		t.Fatalf("Expected the api-cors headers middleware, got %+v", config.HTTP.Middlewares)
+	}
+	for _, name := range []string{"api", "docs"} {
+		if middlewares := config.HTTP.Routers[name].Middlewares; len(middlewares) == 0 || middlewares[0] != "api-cors" {
+			t.Errorf("Expected api-cors first on router %s, got %v", name, middlewares)
+		}
+	}
+}
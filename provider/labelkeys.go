@@ -0,0 +1,160 @@
+package provider
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Label key normalization
+//
+// Cloud Run label keys may only contain lowercase letters, digits, '_' and
+// '-', while Traefik names can also contain dots, and labels copied from
+// docker-compose use dotted, mixed-case keys. NormalizeLabels maps traefik
+// label keys to the canonical form the parsers expect:
+//
+//   - keys are lowercased
+//   - docker-style keys are converted: traefik.http.routers.app.rule -> traefik_http_routers_app_rule
+//   - inside a name, "__" encodes '-' and "___" encodes '.':
+//     traefik_http_routers_my__app_rule -> traefik_http_routers_my-app_rule
+//     traefik_http_routers_api___v1_rule -> traefik_http_routers_api.v1_rule
+//
+// A single '_' always separates key parts, so names can't contain underscores.
+// Canonical keys are left as they are, so normalizing twice is harmless.
+//
+// Label values are limited to 63 characters, so a long value can be split
+// across numbered keys that are joined in numeric order:
+//
+//	traefik_http_routers_app_rule_1=Host(`app.example.com`)
+//	traefik_http_routers_app_rule_2=&&PathPrefix(`/api`)
+//
+// becomes traefik_http_routers_app_rule. An unnumbered key with the same base
+// goes first.
+
+// NormalizeLabels returns the labels with traefik label keys in canonical
+// form. Labels that aren't Traefik's are kept as they are. If two keys
+// normalize to the same key, the lexically first one wins and a warning is
+// printed. The original map is returned when nothing changes.
+func NormalizeLabels(labels map[string]string) map[string]string {
+	changed := false
+	for key := range labels {
+		if normalizeLabelKey(key) != key {
+			changed = true
+			break
+		}
+		if _, _, ok := labelPart(key); ok {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return labels
+	}
+
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	normalized := make(map[string]string, len(labels))
+	source := make(map[string]string, len(labels))
+	for _, key := range keys {
+		canonical := normalizeLabelKey(key)
+		if first, ok := source[canonical]; ok {
+			fmt.Fprintf(os.Stderr, "   WARNING: Labels %s and %s both mean %s, ignoring %s\n", first, key, canonical, key)
+			continue
+		}
+		normalized[canonical] = labels[key]
+		source[canonical] = key
+	}
+	return joinLabelParts(normalized)
+}
+
+// joinLabelParts replaces numbered traefik labels (<key>_1, <key>_2, ...)
+// with <key> set to their values joined in numeric order
+func joinLabelParts(labels map[string]string) map[string]string {
+	type part struct {
+		index int
+		value string
+	}
+	parts := make(map[string][]part)
+	for key, value := range labels {
+		if base, index, ok := labelPart(key); ok {
+			parts[base] = append(parts[base], part{index, value})
+			delete(labels, key)
+		}
+	}
+	for base, values := range parts {
+		sort.Slice(values, func(i, j int) bool { return values[i].index < values[j].index })
+		var b strings.Builder
+		b.WriteString(labels[base])
+		for _, v := range values {
+			b.WriteString(v.value)
+		}
+		labels[base] = b.String()
+	}
+	return labels
+}
+
+// labelPart splits a numbered traefik label key such as
+// traefik_http_routers_app_rule_2 into its base key and index
+func labelPart(key string) (string, int, bool) {
+	if !strings.HasPrefix(key, "traefik_") {
+		return "", 0, false
+	}
+	i := strings.LastIndexByte(key, '_')
+	suffix := key[i+1:]
+	if i <= len("traefik") || suffix == "" || strings.Trim(suffix, "0123456789") != "" {
+		return "", 0, false
+	}
+	index, err := strconv.Atoi(suffix)
+	if err != nil {
+		return "", 0, false
+	}
+	return key[:i], index, true
+}
+
+// normalizeLabelKey returns the canonical form of a traefik label key, or
+// the key unchanged if it isn't a traefik label or can't be decoded
+func normalizeLabelKey(key string) string {
+	lower := strings.ToLower(key)
+	if strings.HasPrefix(lower, "traefik.") {
+		// Docker-style keys separate parts with dots
+		return strings.ReplaceAll(lower, ".", "_")
+	}
+	if !strings.HasPrefix(lower, "traefik_") {
+		return key
+	}
+	if !strings.Contains(lower, "__") {
+		return lower
+	}
+
+	// Decode runs of underscores: "_" separates parts, "__" is '-', "___" is '.'
+	var b strings.Builder
+	for i := 0; i < len(lower); {
+		if lower[i] != '_' {
+			b.WriteByte(lower[i])
+			i++
+			continue
+		}
+		run := 1
+		for i+run < len(lower) && lower[i+run] == '_' {
+			run++
+		}
+		switch run {
+		case 1:
+			b.WriteByte('_')
+		case 2:
+			b.WriteByte('-')
+		case 3:
+			b.WriteByte('.')
+		default:
+			return key
+		}
+		i += run
+	}
+	return b.String()
+}
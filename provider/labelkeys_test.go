@@ -0,0 +1,97 @@
+package provider
+
+import "testing"
+
+func TestNormalizeLabelKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"traefik_http_routers_app_rule", "traefik_http_routers_app_rule"},
+		{"traefik_http_routers_my-app_rule", "traefik_http_routers_my-app_rule"},
+		{"traefik_http_routers_my__app_rule", "traefik_http_routers_my-app_rule"},
+		{"traefik_http_routers_api___v1_rule", "traefik_http_routers_api.v1_rule"},
+		{"traefik_http_routers_my__app_rule_id", "traefik_http_routers_my-app_rule_id"},
+		{"traefik_http_routers_app____x_rule", "traefik_http_routers_app____x_rule"},
+		{"Traefik_HTTP_Routers_App_EntryPoints", "traefik_http_routers_app_entrypoints"},
+		{"traefik.http.routers.my-app.entryPoints", "traefik_http_routers_my-app_entrypoints"},
+		{"traefik.enable", "traefik_enable"},
+		{"Team", "Team"},
+		{"cloud.googleapis.com/location", "cloud.googleapis.com/location"},
+	}
+	for _, tt := range tests {
+		if got := normalizeLabelKey(tt.key); got != tt.want {
+			t.Errorf("normalizeLabelKey(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeLabels(t *testing.T) {
+	canonical := map[string]string{"traefik_enable": "true", "team": "web"}
+	if got := NormalizeLabels(canonical); len(got) != 2 || got["traefik_enable"] != "true" {
+		t.Errorf("Expected canonical labels unchanged, got %v", got)
+	}
+
+	labels := NormalizeLabels(map[string]string{
+		"traefik_enable":                    "true",
+		"traefik_http_routers_my__app_rule": "PathPrefix(`/encoded`)",
+		"traefik_http_routers_my-app_rule":  "PathPrefix(`/plain`)",
+		"Team":                              "web",
+	})
+	// "traefik_http_routers_my-app_rule" sorts first, so it wins the collision
+	if got := labels["traefik_http_routers_my-app_rule"]; got != "PathPrefix(`/plain`)" {
+		t.Errorf("Expected the lexically first key to win, got %q", got)
+	}
+	if labels["Team"] != "web" || len(labels) != 3 {
+		t.Errorf("Unexpected labels %v", labels)
+	}
+}
+
+func TestBuild_EncodedRouterName(t *testing.T) {
+	provider, err := newProvider(&Config{
+		ProjectIDs:     []string{"test-project"},
+		Region:         "us-central1",
+		TokenInjection: TokenInjectionPlugin,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	config, err := provider.Build([]CloudRunService{{
+		Name:      "my-app",
+		ProjectID: "test-project",
+		URL:       "https://my-app.run.app",
+		Labels: map[string]string{
+			"traefik_enable":                    "true",
+			"traefik_http_routers_my__app_rule": "PathPrefix(`/app`)",
+		},
+	}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, ok := config.HTTP.Routers["my-app"]; !ok {
+		t.Errorf("Expected router my-app, got %v", config.HTTP.Routers)
+	}
+}
+
+func TestNormalizeLabels_JoinsNumberedParts(t *testing.T) {
+	labels := NormalizeLabels(map[string]string{
+		"traefik_enable":                   "true",
+		"traefik_http_routers_app_rule_2":  " && PathPrefix(`/api`)",
+		"traefik_http_routers_app_rule_10": " && !Path(`/api/internal`)",
+		"traefik_http_routers_app_rule_1":  "Host(`app.example.com`)",
+		"traefik_http_routers_lab1_rule":   "PathPrefix(`/lab1`)",
+		"build_2":                          "not-traefik",
+	})
+
+	want := "Host(`app.example.com`) && PathPrefix(`/api`) && !Path(`/api/internal`)"
+	if got := labels["traefik_http_routers_app_rule"]; got != want {
+		t.Errorf("Expected joined rule %q, got %q", want, got)
+	}
+	if _, ok := labels["traefik_http_routers_app_rule_1"]; ok {
+		t.Error("Expected numbered parts to be removed")
+	}
+	if labels["traefik_http_routers_lab1_rule"] != "PathPrefix(`/lab1`)" || labels["build_2"] != "not-traefik" {
+		t.Errorf("Expected other labels unchanged, got %v", labels)
+	}
+}
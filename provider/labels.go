@@ -2,33 +2,63 @@ package provider
 
 import (
 	"fmt"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 )
 
-// RouterConfig represents a Traefik router configuration
+// RouterConfig represents a Traefik router configuration.
+// Keys are lowercase for compatibility with routes files written by earlier
+// versions; Traefik matches keys case-insensitively and ParseDynamicConfig
+// accepts Traefik's camelCase spelling too.
 type RouterConfig struct {
-	Rule        string
-	Service     string
-	Priority    int
-	EntryPoints []string
-	Middlewares []string
+	Rule          string                     `yaml:"rule"`
+	Service       string                     `yaml:"service"`
+	Priority      int                        `yaml:"priority"`
+	EntryPoints   []string                   `yaml:"entrypoints"`
+	Middlewares   []string                   `yaml:"middlewares"`
+	TLS           *RouterTLSConfig           `yaml:"tls,omitempty"`
+	Observability *RouterObservabilityConfig `yaml:"observability,omitempty"`
+}
+
+// RouterObservabilityConfig turns access logs, metrics or tracing off (or
+// on) for one router, e.g. to keep health checks out of the access log.
+// Unset options follow the entry point. Traefik v3.1+ only.
+type RouterObservabilityConfig struct {
+	AccessLogs *bool `yaml:"accessLogs,omitempty"`
+	Metrics    *bool `yaml:"metrics,omitempty"`
+	Tracing    *bool `yaml:"tracing,omitempty"`
+}
+
+// RouterTLSConfig enables TLS on a router, with Traefik's default
+// certificate unless a certificate resolver is set
+type RouterTLSConfig struct {
+	CertResolver string `yaml:"certResolver,omitempty"`
 }
 
 // ServiceConfig represents a Traefik service configuration
 type ServiceConfig struct {
-	LoadBalancer LoadBalancerConfig
+	LoadBalancer LoadBalancerConfig `yaml:"loadbalancer"`
 }
 
 // LoadBalancerConfig represents load balancer configuration
 type LoadBalancerConfig struct {
-	Servers        []ServerConfig
-	PassHostHeader bool
+	Servers            []ServerConfig            `yaml:"servers"`
+	PassHostHeader     bool                      `yaml:"passhostheader"`
+	ServersTransport   string                    `yaml:"serverstransport,omitempty"`
+	ResponseForwarding *ResponseForwardingConfig `yaml:"responseforwarding,omitempty"`
+}
+
+// ResponseForwardingConfig controls how Traefik forwards backend responses
+type ResponseForwardingConfig struct {
+	FlushInterval string `yaml:"flushInterval,omitempty"`
 }
 
 // ServerConfig represents a backend server configuration
 type ServerConfig struct {
-	URL string
+	URL string `yaml:"url"`
 }
 
 // ruleMap maps rule IDs to Traefik rule expressions
@@ -122,8 +152,13 @@ func getDefaultPriority(routerName string) int {
 // extractRouterConfigs extracts router configurations from Cloud Run service labels
 // Extracted from cmd/generate-routes/main.go:410-507
 //
+// The traefik_host and traefik_pathprefix shorthand labels supply the rule of
+// routers that don't set one, or define a router named after the service if
+// there are no router labels. Request filter labels (traefik_methods, ...)
+// are then ANDed into every rule.
+//
 //nolint:gocyclo
-func extractRouterConfigs(labels map[string]string, _ string) map[string]RouterConfig {
+func extractRouterConfigs(labels map[string]string, serviceName string) map[string]RouterConfig {
 	routers := make(map[string]RouterConfig)
 
 	// Find all router labels
@@ -187,24 +222,27 @@ func extractRouterConfigs(labels map[string]string, _ string) map[string]RouterC
 				router.EntryPoints = []string{"web"}
 			}
 		case "middlewares":
-			// Support multiple separators: __ (preferred), ; (legacy), , (legacy)
-			var parts []string
-			if strings.Contains(value, "__") {
-				parts = strings.Split(value, "__")
-			} else if strings.Contains(value, ";") {
-				parts = strings.Split(value, ";")
-			} else {
-				parts = strings.Split(value, ",")
+			for _, part := range splitLabelList(value) {
+				router.Middlewares = append(router.Middlewares, normalizeMiddlewareRef(part))
+			}
+		case "methods", "headers", "query":
+			// Request filters, ANDed into the rule below
+		case "observability_accesslogs", "observability_metrics", "observability_tracing":
+			enabled, err := strconv.ParseBool(value)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "   WARNING: Invalid %s %q for router %s (expected true or false), ignoring\n", property, value, routerName)
+				break
+			}
+			if router.Observability == nil {
+				router.Observability = &RouterObservabilityConfig{}
 			}
-			for _, part := range parts {
-				part = strings.TrimSpace(part)
-				if part != "" {
-					// Convert -file suffix to @file
-					if strings.HasSuffix(part, "-file") {
-						part = strings.TrimSuffix(part, "-file") + "@file"
-					}
-					router.Middlewares = append(router.Middlewares, part)
-				}
+			switch property {
+			case "observability_accesslogs":
+				router.Observability.AccessLogs = &enabled
+			case "observability_metrics":
+				router.Observability.Metrics = &enabled
+			case "observability_tracing":
+				router.Observability.Tracing = &enabled
 			}
 		}
 
@@ -215,6 +253,35 @@ func extractRouterConfigs(labels map[string]string, _ string) map[string]RouterC
 		routers[routerName] = router
 	}
 
+	// Compose rules from the shorthand labels
+	if rule := shorthandRule(labels); rule != "" {
+		if len(routers) == 0 {
+			routers[serviceName] = RouterConfig{
+				Rule:        rule,
+				Priority:    getDefaultPriority(serviceName),
+				EntryPoints: []string{"web"},
+				Middlewares: []string{},
+			}
+		}
+		for routerName, router := range routers {
+			if router.Rule == "" {
+				router.Rule = rule
+				routers[routerName] = router
+			}
+		}
+	}
+
+	// AND the service's and each router's request filters into the rules
+	serviceFilter := requestFilterRule(labels[methodsLabel], labels[headersLabel], labels[queryLabel])
+	for routerName, router := range routers {
+		prefix := "traefik_http_routers_" + routerName + "_"
+		routerFilter := requestFilterRule(labels[prefix+"methods"], labels[prefix+"headers"], labels[prefix+"query"])
+		if router.Rule != "" && (serviceFilter != "" || routerFilter != "") {
+			router.Rule = andRules(router.Rule, serviceFilter, routerFilter)
+			routers[routerName] = router
+		}
+	}
+
 	// Final validation: ensure all routers have entryPoints (required by Traefik)
 	for routerName, router := range routers {
 		if len(router.EntryPoints) == 0 {
@@ -226,3 +293,252 @@ func extractRouterConfigs(labels map[string]string, _ string) map[string]RouterC
 
 	return routers
 }
+
+// splitLabelList splits a multi-valued label into its trimmed, non-empty parts.
+// Supports multiple separators: __ (preferred), ; (legacy), , (legacy)
+func splitLabelList(value string) []string {
+	var parts []string
+	if strings.Contains(value, "__") {
+		parts = strings.Split(value, "__")
+	} else if strings.Contains(value, ";") {
+		parts = strings.Split(value, ";")
+	} else {
+		parts = strings.Split(value, ",")
+	}
+
+	result := make([]string, 0, len(parts))
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		if part != "" {
+			result = append(result, part)
+		}
+	}
+	return result
+}
+
+// normalizeMiddlewareRef converts a middleware reference from label form to
+// Traefik form. GCP label values can't contain '@', so "name-file" means "name@file".
+func normalizeMiddlewareRef(ref string) string {
+	if strings.HasSuffix(ref, "-file") {
+		return strings.TrimSuffix(ref, "-file") + "@file"
+	}
+	return ref
+}
+
+// extractChainConfigs extracts chain middleware configurations from labels.
+//
+// Two label forms are supported:
+//   - traefik_chain_<name>=auth__ratelimit__compress
+//   - traefik_http_middlewares_<name>_chain_middlewares=auth__ratelimit__compress
+//
+// Members keep their listed order and use the same separators and -file
+// suffix convention as router middlewares.
+func extractChainConfigs(labels map[string]string) map[string]ChainConfig {
+	chains := make(map[string]ChainConfig)
+
+	for key, value := range labels {
+		var name string
+
+		if strings.HasPrefix(key, "traefik_chain_") {
+			name = strings.TrimPrefix(key, "traefik_chain_")
+		} else if strings.HasPrefix(key, "traefik_http_middlewares_") {
+			// Parse: traefik_http_middlewares_<name>_chain_middlewares
+			parts := strings.SplitN(key, "_", 6)
+			if len(parts) < 6 || parts[4] != "chain" || parts[5] != "middlewares" {
+				continue
+			}
+			name = parts[3]
+		} else {
+			continue
+		}
+
+		members := splitLabelList(value)
+		if name == "" || len(members) == 0 {
+			fmt.Fprintf(os.Stderr, "   WARNING: Chain label %s has no name or members, skipping\n", key)
+			continue
+		}
+		for i := range members {
+			members[i] = normalizeMiddlewareRef(members[i])
+		}
+		chains[name] = ChainConfig{Middlewares: members}
+	}
+
+	return chains
+}
+
+// extractForwardAuthConfigs extracts forwardAuth middleware configurations from labels.
+//
+// Two label forms are supported:
+//   - traefik_http_middlewares_<name>_forwardauth_<property> defines middleware <name>
+//   - traefik_forwardauth_<property> is shorthand for middleware <service>-forwardauth
+//
+// Properties: address, trustforwardheader, authresponseheaders, authrequestheaders.
+// Header lists use the same separators as router middlewares (__, ; or ,).
+// Middlewares without an address are dropped with a warning.
+func extractForwardAuthConfigs(labels map[string]string, serviceName string) map[string]ForwardAuthConfig {
+	configs := make(map[string]ForwardAuthConfig)
+
+	for key, value := range labels {
+		var name, property string
+
+		if strings.HasPrefix(key, "traefik_forwardauth_") {
+			name = serviceName + "-forwardauth"
+			property = strings.TrimPrefix(key, "traefik_forwardauth_")
+		} else if strings.HasPrefix(key, "traefik_http_middlewares_") {
+			// Parse: traefik_http_middlewares_<name>_forwardauth_<property>
+			parts := strings.SplitN(key, "_", 6)
+			if len(parts) < 6 || parts[4] != "forwardauth" {
+				continue
+			}
+			name = parts[3]
+			property = parts[5]
+		} else {
+			continue
+		}
+
+		fa := configs[name]
+		switch property {
+		case "address":
+			fa.Address = value
+		case "trustforwardheader":
+			fa.TrustForwardHeader = value == labelValueTrue
+		case "authresponseheaders":
+			fa.AuthResponseHeaders = splitLabelList(value)
+		case "authrequestheaders":
+			fa.AuthRequestHeaders = splitLabelList(value)
+		default:
+			fmt.Fprintf(os.Stderr, "   WARNING: Unknown forwardAuth property %q for middleware %s, ignoring\n", property, name)
+		}
+		configs[name] = fa
+	}
+
+	for name, fa := range configs {
+		if fa.Address == "" {
+			fmt.Fprintf(os.Stderr, "   WARNING: forwardAuth middleware %s has no address, skipping\n", name)
+			delete(configs, name)
+		}
+	}
+
+	return configs
+}
+
+// extractIPAllowListConfigs extracts ipAllowList middleware configurations
+// from traefik_http_middlewares_<name>_ipallowlist_sourcerange labels (the v2
+// name ipwhitelist is accepted too). Ranges use the same separators as
+// router middlewares (__, ; or ,).
+func extractIPAllowListConfigs(labels map[string]string) map[string]IPAllowListConfig {
+	configs := make(map[string]IPAllowListConfig)
+
+	for key, value := range labels {
+		if !strings.HasPrefix(key, "traefik_http_middlewares_") {
+			continue
+		}
+		// Parse: traefik_http_middlewares_<name>_ipallowlist_sourcerange
+		parts := strings.SplitN(key, "_", 6)
+		if len(parts) < 6 || (parts[4] != "ipallowlist" && parts[4] != "ipwhitelist") {
+			continue
+		}
+		if parts[5] != "sourcerange" {
+			fmt.Fprintf(os.Stderr, "   WARNING: Unknown ipAllowList property %q for middleware %s, ignoring\n", parts[5], parts[3])
+			continue
+		}
+		ranges := splitLabelList(value)
+		if len(ranges) == 0 {
+			fmt.Fprintf(os.Stderr, "   WARNING: ipAllowList middleware %s has no source ranges, skipping\n", parts[3])
+			continue
+		}
+		configs[parts[3]] = IPAllowListConfig{SourceRange: ranges}
+	}
+
+	return configs
+}
+
+// Backend protocols selectable with the traefik_protocol label
+const (
+	ProtocolHTTP = "http" // Default: proxy to the service URL as-is
+	ProtocolH2C  = "h2c"  // Cleartext HTTP/2 (h2c://), for backends reached over plain HTTP
+	ProtocolGRPC = "grpc" // HTTP/2 over TLS to the Cloud Run URL, for gRPC services
+)
+
+// protocolLabel selects the backend protocol for a service
+const protocolLabel = "traefik_protocol"
+
+// extractProtocol returns the backend protocol from the traefik_protocol label.
+// Unknown values fall back to http with a warning.
+func extractProtocol(labels map[string]string) string {
+	protocol, ok := labels[protocolLabel]
+	if !ok {
+		return ProtocolHTTP
+	}
+	switch protocol {
+	case ProtocolHTTP, ProtocolH2C, ProtocolGRPC:
+		return protocol
+	default:
+		fmt.Fprintf(os.Stderr, "   WARNING: Unknown %s %q, using %s\n", protocolLabel, protocol, ProtocolHTTP)
+		return ProtocolHTTP
+	}
+}
+
+// backendService builds the Traefik service for a backend URL and protocol.
+// For gRPC it also returns the serversTransport the service must reference.
+func backendService(name, serviceURL, protocol string) (ServiceConfig, *ServersTransportConfig, error) {
+	serviceConfig := ServiceConfig{
+		LoadBalancer: LoadBalancerConfig{
+			Servers:        []ServerConfig{{URL: serviceURL}},
+			PassHostHeader: false,
+		},
+	}
+
+	switch protocol {
+	case ProtocolH2C:
+		parsed, err := url.Parse(serviceURL)
+		if err != nil {
+			return serviceConfig, nil, fmt.Errorf("invalid service URL %q: %w", serviceURL, err)
+		}
+		parsed.Scheme = "h2c"
+		serviceConfig.LoadBalancer.Servers[0].URL = parsed.String()
+		return serviceConfig, nil, nil
+	case ProtocolGRPC:
+		parsed, err := url.Parse(serviceURL)
+		if err != nil {
+			return serviceConfig, nil, fmt.Errorf("invalid service URL %q: %w", serviceURL, err)
+		}
+		// Cloud Run negotiates HTTP/2 via ALPN on its TLS front end; pin the SNI
+		// to the run.app host since the Host header isn't passed through
+		serviceConfig.LoadBalancer.ServersTransport = name + "-grpc"
+		return serviceConfig, &ServersTransportConfig{ServerName: parsed.Hostname()}, nil
+	default:
+		return serviceConfig, nil, nil
+	}
+}
+
+// Labels for streaming/WebSocket backends
+const (
+	websocketLabel     = "traefik_websocket"      // "true" applies the WebSocket defaults below
+	flushIntervalLabel = "traefik_flush_interval" // e.g. "100ms"; overrides the WebSocket default
+)
+
+// websocketFlushInterval flushes responses to the client almost immediately,
+// so server-sent frames and streamed responses aren't buffered
+const websocketFlushInterval = "1ms"
+
+// extractResponseForwarding returns the response forwarding settings from the
+// traefik_websocket and traefik_flush_interval labels, or nil if neither is set
+func extractResponseForwarding(labels map[string]string) *ResponseForwardingConfig {
+	flushInterval := ""
+	if labels[websocketLabel] == labelValueTrue {
+		flushInterval = websocketFlushInterval
+	}
+	if value, ok := labels[flushIntervalLabel]; ok {
+		if _, err := time.ParseDuration(value); err != nil {
+			fmt.Fprintf(os.Stderr, "   WARNING: Invalid %s %q, ignoring\n", flushIntervalLabel, value)
+		} else {
+			flushInterval = value
+		}
+	}
+
+	if flushInterval == "" {
+		return nil
+	}
+	return &ResponseForwardingConfig{FlushInterval: flushInterval}
+}
@@ -0,0 +1,179 @@
+package provider
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitLabelList(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{"a__b__c", []string{"a", "b", "c"}},
+		{"a;b", []string{"a", "b"}},
+		{"a, b ,,c", []string{"a", "b", "c"}},
+		{"", []string{}},
+	}
+
+	for _, tt := range tests {
+		if got := splitLabelList(tt.value); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitLabelList(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestExtractForwardAuthConfigs(t *testing.T) {
+	labels := map[string]string{
+		"traefik_http_middlewares_sso_forwardauth_address":               "http://auth.internal/verify",
+		"traefik_http_middlewares_sso_forwardauth_trustforwardheader":    "true",
+		"traefik_http_middlewares_sso_forwardauth_authresponseheaders":   "X-User__X-Groups",
+		"traefik_forwardauth_address":                                    "http://other-auth/check",
+		"traefik_forwardauth_authrequestheaders":                         "Cookie",
+		"traefik_http_middlewares_noaddr_forwardauth_authrequestheaders": "Cookie",
+		"traefik_http_middlewares_strip_stripprefix_prefixes":            "/api",
+	}
+
+	configs := extractForwardAuthConfigs(labels, "my-svc")
+
+	if len(configs) != 2 {
+		t.Fatalf("Expected 2 forwardAuth configs, got %d: %v", len(configs), configs)
+	}
+
+	sso, ok := configs["sso"]
+	if !ok {
+		t.Fatal("Expected sso middleware")
+	}
+	if sso.Address != "http://auth.internal/verify" || !sso.TrustForwardHeader {
+		t.Errorf("Unexpected sso config: %+v", sso)
+	}
+	if !reflect.DeepEqual(sso.AuthResponseHeaders, []string{"X-User", "X-Groups"}) {
+		t.Errorf("Unexpected authResponseHeaders: %v", sso.AuthResponseHeaders)
+	}
+
+	shorthand, ok := configs["my-svc-forwardauth"]
+	if !ok {
+		t.Fatal("Expected shorthand middleware named after the service")
+	}
+	if shorthand.Address != "http://other-auth/check" {
+		t.Errorf("Unexpected shorthand address: %s", shorthand.Address)
+	}
+	if !reflect.DeepEqual(shorthand.AuthRequestHeaders, []string{"Cookie"}) {
+		t.Errorf("Unexpected authRequestHeaders: %v", shorthand.AuthRequestHeaders)
+	}
+}
+
+func TestExtractChainConfigs(t *testing.T) {
+	labels := map[string]string{
+		"traefik_chain_secure":                           "auth__ratelimit-file__compress",
+		"traefik_http_middlewares_api_chain_middlewares": "cors,retry-cold-start-file",
+		"traefik_chain_empty":                            "",
+		"traefik_http_routers_app_rule":                  "PathPrefix(`/app`)",
+	}
+
+	chains := extractChainConfigs(labels)
+
+	if len(chains) != 2 {
+		t.Fatalf("Expected 2 chains, got %d: %v", len(chains), chains)
+	}
+
+	want := []string{"auth", "ratelimit@file", "compress"}
+	if !reflect.DeepEqual(chains["secure"].Middlewares, want) {
+		t.Errorf("secure chain = %v, want %v", chains["secure"].Middlewares, want)
+	}
+
+	want = []string{"cors", "retry-cold-start@file"}
+	if !reflect.DeepEqual(chains["api"].Middlewares, want) {
+		t.Errorf("api chain = %v, want %v", chains["api"].Middlewares, want)
+	}
+}
+
+func TestExtractProtocol(t *testing.T) {
+	tests := map[string]string{
+		"":     ProtocolHTTP,
+		"http": ProtocolHTTP,
+		"h2c":  ProtocolH2C,
+		"grpc": ProtocolGRPC,
+		"quic": ProtocolHTTP,
+	}
+	for value, expected := range tests {
+		labels := map[string]string{}
+		if value != "" {
+			labels["traefik_protocol"] = value
+		}
+		if got := extractProtocol(labels); got != expected {
+			t.Errorf("Expected protocol %q for label %q, got %q", expected, value, got)
+		}
+	}
+}
+
+func TestBackendService_Protocols(t *testing.T) {
+	serviceConfig, transport, err := backendService("api", "https://api-abc.a.run.app", ProtocolHTTP)
+	if err != nil || transport != nil {
+		t.Fatalf("Expected plain service, got transport=%v err=%v", transport, err)
+	}
+	if serviceConfig.LoadBalancer.Servers[0].URL != "https://api-abc.a.run.app" {
+		t.Errorf("Expected URL unchanged, got %s", serviceConfig.LoadBalancer.Servers[0].URL)
+	}
+
+	serviceConfig, _, err = backendService("api", "http://10.0.0.5:8080", ProtocolH2C)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if serviceConfig.LoadBalancer.Servers[0].URL != "h2c://10.0.0.5:8080" {
+		t.Errorf("Expected h2c URL, got %s", serviceConfig.LoadBalancer.Servers[0].URL)
+	}
+
+	serviceConfig, transport, err = backendService("api", "https://api-abc.a.run.app", ProtocolGRPC)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if serviceConfig.LoadBalancer.ServersTransport != "api-grpc" {
+		t.Errorf("Expected serversTransport api-grpc, got %q", serviceConfig.LoadBalancer.ServersTransport)
+	}
+	if transport == nil || transport.ServerName != "api-abc.a.run.app" {
+		t.Errorf("Expected transport with server name api-abc.a.run.app, got %+v", transport)
+	}
+}
+
+func TestExtractResponseForwarding(t *testing.T) {
+	if rf := extractResponseForwarding(map[string]string{}); rf != nil {
+		t.Errorf("Expected nil without labels, got %+v", rf)
+	}
+
+	rf := extractResponseForwarding(map[string]string{"traefik_websocket": "true"})
+	if rf == nil || rf.FlushInterval != "1ms" {
+		t.Errorf("Expected WebSocket flush interval 1ms, got %+v", rf)
+	}
+
+	rf = extractResponseForwarding(map[string]string{"traefik_websocket": "true", "traefik_flush_interval": "100ms"})
+	if rf == nil || rf.FlushInterval != "100ms" {
+		t.Errorf("Expected flush interval override 100ms, got %+v", rf)
+	}
+
+	if rf := extractResponseForwarding(map[string]string{"traefik_flush_interval": "soon"}); rf != nil {
+		t.Errorf("Expected invalid flush interval to be ignored, got %+v", rf)
+	}
+}
+
+func TestExtractRouterConfigs_Observability(t *testing.T) {
+	routers := extractRouterConfigs(map[string]string{
+		"traefik_http_routers_health_rule":                     "Path(`/health`)",
+		"traefik_http_routers_health_observability_accesslogs": "false",
+		"traefik_http_routers_health_observability_metrics":    "true",
+		"traefik_http_routers_main_rule":                       "PathPrefix(`/`)",
+		"traefik_http_routers_main_observability_tracing":      "sometimes",
+	}, "app")
+
+	observability := routers["health"].Observability
+	if observability == nil || observability.AccessLogs == nil || *observability.AccessLogs ||
+		observability.Metrics == nil || !*observability.Metrics || observability.Tracing != nil {
+		t.Errorf("Expected access logs off, metrics on and tracing unset, got %+v", observability)
+	}
+	if routers["main"].Observability != nil {
+		t.Errorf("Expected invalid value to be ignored, got %+v", routers["main"].Observability)
+	}
+	if problems := unknownLabels(map[string]string{"traefik_http_routers_health_observability_accesslogs": "false"}); len(problems) != 0 {
+		t.Errorf("Expected observability labels to be known, got %v", problems)
+	}
+}
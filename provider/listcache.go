@@ -0,0 +1,209 @@
+package provider
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// listCache keeps the most recent List response for each project so that
+// polls can be served without hitting the Cloud Run Admin API.
+//
+// Large orgs polling many projects every 10-30s run into Admin API quotas.
+// The cache lets us:
+//   - skip re-listing a project until its (jittered) next-scan time has passed
+//   - cap List calls per project per minute (request budget)
+//   - detect that a project's services are unchanged via resourceVersion, in
+//     which case Build replays the project's last configuration (see
+//     projectSnapshot) instead of processing its services again
+type listCache struct {
+	mu      sync.Mutex
+	entries map[string]*listCacheEntry
+	ttl     time.Duration // Minimum age before a project is listed again (0 = always list)
+	jitter  time.Duration // Max random delay added to each project's next scan
+	budget  int           // Max List calls per project per minute (0 = unlimited)
+}
+
+// listCacheEntry is the cached List result for a single project
+type listCacheEntry struct {
+	services  []CloudRunService
+	version   string      // Fingerprint of service names + resourceVersions
+	fetchedAt time.Time   // When the list was last fetched from the API
+	nextScan  time.Time   // Earliest time the project should be listed again
+	calls     []time.Time // List calls made in the last minute (for budget accounting)
+}
+
+// newListCache creates a list cache from the provider configuration
+func newListCache(config *Config) *listCache {
+	return &listCache{
+		entries: make(map[string]*listCacheEntry),
+		ttl:     config.ListCacheTTL,
+		jitter:  config.ScanJitter,
+		budget:  config.ProjectRequestBudget,
+	}
+}
+
+// shouldScan reports whether the project should be listed from the API now.
+// Returns false (serve from cache) when the project was listed recently or
+// its request budget for the current minute is exhausted. Projects without
+// a cached entry are always scanned.
+func (c *listCache) shouldScan(projectID string, now time.Time) (bool, string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[projectID]
+	if !ok {
+		return true, ""
+	}
+
+	if now.Before(entry.nextScan) {
+		return false, "cached list is still fresh"
+	}
+
+	// pruneCalls reuses the backing array, so keep what it returns
+	entry.calls = pruneCalls(entry.calls, now)
+	if c.budget > 0 && len(entry.calls) >= c.budget {
+		return false, "per-project request budget exhausted"
+	}
+
+	return true, ""
+}
+
+// recordCall accounts for one List API call against the project's budget
+func (c *listCache) recordCall(projectID string, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := c.entry(projectID)
+	entry.calls = append(pruneCalls(entry.calls, now), now)
+}
+
+// store saves a fresh List result and schedules the next scan.
+// Returns true if the project's services changed since the previous scan.
+func (c *listCache) store(projectID string, services []CloudRunService, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := c.entry(projectID)
+	version := listVersion(services)
+	changed := entry.version != version || entry.fetchedAt.IsZero()
+
+	entry.services = services
+	entry.version = version
+	entry.fetchedAt = now
+	entry.nextScan = now.Add(c.ttl)
+	if c.jitter > 0 {
+		// Spread project scans across the interval so that many projects
+		// don't all hit the API on the same tick
+		entry.nextScan = entry.nextScan.Add(time.Duration(rand.Int63n(int64(c.jitter)))) //nolint:gosec // jitter doesn't need crypto randomness
+	}
+
+	return changed
+}
+
+// get returns the cached services for a project
+func (c *listCache) get(projectID string) ([]CloudRunService, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[projectID]
+	if !ok || entry.fetchedAt.IsZero() {
+		return nil, false
+	}
+	return entry.services, true
+}
+
+// last returns the services of the project's last successful list and when
+// it was fetched, regardless of whether the entry is still fresh
+func (c *listCache) last(projectID string) ([]CloudRunService, time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[projectID]
+	if !ok || entry.fetchedAt.IsZero() {
+		return nil, time.Time{}, false
+	}
+	return entry.services, entry.fetchedAt, true
+}
+
+// inherit takes over the lists and budget accounting of previous, with
+// every project due to be listed again
+func (c *listCache) inherit(previous *listCache) {
+	previous.mu.Lock()
+	defer previous.mu.Unlock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for projectID, entry := range previous.entries {
+		copied := *entry
+		copied.nextScan = time.Time{}
+		c.entries[projectID] = &copied
+	}
+}
+
+// entry returns the cache entry for a project, creating it if needed.
+// Caller must hold c.mu.
+func (c *listCache) entry(projectID string) *listCacheEntry {
+	entry, ok := c.entries[projectID]
+	if !ok {
+		entry = &listCacheEntry{}
+		c.entries[projectID] = entry
+	}
+	return entry
+}
+
+// pruneCalls drops budget entries older than one minute
+func pruneCalls(calls []time.Time, now time.Time) []time.Time {
+	cutoff := now.Add(-time.Minute)
+	kept := calls[:0]
+	for _, t := range calls {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	return kept
+}
+
+// listVersion computes a stable fingerprint of a project's services from
+// their keys (see fragmentKey) and resourceVersions. Cloud Run bumps
+// resourceVersion on every change to a service, so an identical fingerprint
+// means nothing changed.
+func listVersion(services []CloudRunService) string {
+	keys := make([]string, 0, len(services))
+	for _, svc := range services {
+		keys = append(keys, fragmentKey(svc)+"@"+svc.ResourceVersion)
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	for _, k := range keys {
+		h.Write([]byte(k))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// projectVersions returns the list fingerprint of each project's services,
+// leaving out projects with a service that has no resourceVersion (e.g. one
+// passed to Build without being listed)
+func projectVersions(services []CloudRunService) map[string]string {
+	byProject := make(map[string][]CloudRunService)
+	unversioned := make(map[string]bool)
+	for _, service := range services {
+		byProject[service.ProjectID] = append(byProject[service.ProjectID], service)
+		if service.ResourceVersion == "" {
+			unversioned[service.ProjectID] = true
+		}
+	}
+
+	versions := make(map[string]string, len(byProject))
+	for projectID, projectServices := range byProject {
+		if !unversioned[projectID] {
+			versions[projectID] = listVersion(projectServices)
+		}
+	}
+	return versions
+}
@@ -0,0 +1,126 @@
+package provider
+
+import (
+	"testing"
+	"time"
+)
+
+func TestListCache_ScanUntilCached(t *testing.T) {
+	cache := newListCache(&Config{ListCacheTTL: time.Minute})
+	now := time.Now()
+
+	if scan, _ := cache.shouldScan("proj", now); !scan {
+		t.Fatal("Expected scan for project with no cached list")
+	}
+
+	cache.store("proj", []CloudRunService{{Name: "svc", ResourceVersion: "1"}}, now)
+
+	if scan, _ := cache.shouldScan("proj", now.Add(30*time.Second)); scan {
+		t.Error("Expected cached list to be used within TTL")
+	}
+	if scan, _ := cache.shouldScan("proj", now.Add(2*time.Minute)); !scan {
+		t.Error("Expected scan after TTL expired")
+	}
+
+	services, ok := cache.get("proj")
+	if !ok || len(services) != 1 {
+		t.Errorf("Expected 1 cached service, got %d (ok=%v)", len(services), ok)
+	}
+}
+
+func TestListCache_ZeroTTLAlwaysScans(t *testing.T) {
+	cache := newListCache(&Config{})
+	now := time.Now()
+
+	cache.store("proj", []CloudRunService{{Name: "svc"}}, now)
+
+	if scan, _ := cache.shouldScan("proj", now); !scan {
+		t.Error("Expected scan on every poll when TTL is 0")
+	}
+}
+
+func TestListCache_RequestBudget(t *testing.T) {
+	cache := newListCache(&Config{ProjectRequestBudget: 2})
+	now := time.Now()
+
+	cache.store("proj", []CloudRunService{{Name: "svc"}}, now)
+	cache.recordCall("proj", now)
+	cache.recordCall("proj", now)
+
+	scan, reason := cache.shouldScan("proj", now.Add(time.Second))
+	if scan {
+		t.Fatal("Expected budget to block scan")
+	}
+	if reason == "" {
+		t.Error("Expected a reason when scan is skipped")
+	}
+
+	// Budget window is one minute
+	if scan, _ := cache.shouldScan("proj", now.Add(61*time.Second)); !scan {
+		t.Error("Expected scan once budget window has passed")
+	}
+}
+
+func TestListCache_StoreDetectsChanges(t *testing.T) {
+	cache := newListCache(&Config{})
+	now := time.Now()
+
+	services := []CloudRunService{
+		{Name: "a", ResourceVersion: "1"},
+		{Name: "b", ResourceVersion: "7"},
+	}
+
+	if changed := cache.store("proj", services, now); !changed {
+		t.Error("Expected first store to report a change")
+	}
+
+	// Same services in a different order are unchanged
+	reordered := []CloudRunService{services[1], services[0]}
+	if changed := cache.store("proj", reordered, now); changed {
+		t.Error("Expected identical services to report no change")
+	}
+
+	services[0].ResourceVersion = "2"
+	if changed := cache.store("proj", services, now); !changed {
+		t.Error("Expected resourceVersion bump to report a change")
+	}
+}
+
+func TestListCache_JitterDelaysNextScan(t *testing.T) {
+	cache := newListCache(&Config{ListCacheTTL: time.Minute, ScanJitter: 10 * time.Second})
+	now := time.Now()
+
+	cache.store("proj", []CloudRunService{{Name: "svc"}}, now)
+
+	next := cache.entries["proj"].nextScan
+	if next.Before(now.Add(time.Minute)) || !next.Before(now.Add(time.Minute+10*time.Second)) {
+		t.Errorf("Expected next scan within [TTL, TTL+jitter), got %s", next.Sub(now))
+	}
+}
+
+func TestListCache_RequestBudgetAcrossWindow(t *testing.T) {
+	cache := newListCache(&Config{ProjectRequestBudget: 2})
+	start := time.Now()
+
+	cache.store("proj", []CloudRunService{{Name: "svc"}}, start)
+	cache.recordCall("proj", start)
+	cache.recordCall("proj", start.Add(40*time.Second))
+
+	// The first call leaves the window: one of two calls is in use
+	at := start.Add(70 * time.Second)
+	if scan, reason := cache.shouldScan("proj", at); !scan {
+		t.Fatalf("Expected scan with one call in the window, got %q", reason)
+	}
+	cache.recordCall("proj", at)
+	if scan, _ := cache.shouldScan("proj", at.Add(time.Second)); scan {
+		t.Error("Expected the budget of two calls to block a third within the window")
+	}
+	if calls := len(cache.entries["proj"].calls); calls != 2 {
+		t.Errorf("Expected 2 calls in the window, got %d", calls)
+	}
+
+	// Once the 40s call leaves the window a scan is allowed again
+	if scan, reason := cache.shouldScan("proj", start.Add(101*time.Second)); !scan {
+		t.Errorf("Expected scan once a call left the window, got %q", reason)
+	}
+}
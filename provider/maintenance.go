@@ -0,0 +1,55 @@
+package provider
+
+import (
+	"path"
+
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/internal/logging"
+)
+
+// maintenanceLabel puts a service in maintenance mode: its routers are
+// pointed at the maintenance service while its own definitions are kept
+const maintenanceLabel = "traefik_maintenance"
+
+// inMaintenance reports whether a service is in maintenance mode, either by
+// its traefik_maintenance label or by matching a Maintenance pattern
+func (p *Provider) inMaintenance(service CloudRunService) bool {
+	if service.Labels[maintenanceLabel] == labelValueTrue {
+		return true
+	}
+	for _, pattern := range p.config.Maintenance {
+		if matched, _ := path.Match(pattern, service.Name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// maintenanceTarget returns the service the routers of a service in
+// maintenance mode point at: MaintenanceService if set, otherwise
+// <service>-maintenance, a load balancer without servers that Traefik
+// answers with 503 Service Unavailable (added to config here)
+func (p *Provider) maintenanceTarget(serviceName string, config *DynamicConfig) string {
+	if p.config.MaintenanceService != "" {
+		return p.config.MaintenanceService
+	}
+	name := serviceName + "-maintenance"
+	config.AddService(name, ServiceConfig{})
+	return name
+}
+
+// enterMaintenance points the routers of a service in maintenance mode at
+// the maintenance target and records the service as degraded
+func (p *Provider) enterMaintenance(service CloudRunService, serviceName string, routerConfigs map[string]RouterConfig, config *DynamicConfig) {
+	target := p.maintenanceTarget(serviceName, config)
+	for routerName, routerConfig := range routerConfigs {
+		routerConfig.Service = target
+		routerConfigs[routerName] = routerConfig
+	}
+	p.logger.Warn("Service in maintenance mode, routing to the maintenance service",
+		logging.String("service", service.Name),
+		logging.String("project", service.ProjectID),
+		logging.String("maintenanceService", target),
+	)
+	config.skip(SkippedService{Service: service.Name, Project: service.ProjectID, Reason: SkipReasonMaintenance,
+		Detail: "routed to " + target, Degraded: true})
+}
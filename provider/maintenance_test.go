@@ -0,0 +1,102 @@
+package provider
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestBuild_Maintenance(t *testing.T) {
+	services := []CloudRunService{
+		{Name: "shop", ProjectID: "test-project", URL: "https://shop.run.app", Labels: map[string]string{
+			"traefik_enable": "true", "traefik_http_routers_shop_rule": "PathPrefix(`/shop`)",
+			"traefik_maintenance": "true",
+		}},
+		{Name: "blog", ProjectID: "test-project", URL: "https://blog.run.app", Labels: map[string]string{
+			"traefik_enable": "true", "traefik_http_routers_blog_rule": "PathPrefix(`/blog`)",
+		}},
+		{Name: "lab1", ProjectID: "test-project", URL: "https://lab1.run.app", Labels: map[string]string{
+			"traefik_enable": "true", "traefik_http_routers_lab1_rule": "PathPrefix(`/lab1`)",
+		}},
+	}
+
+	tests := []struct {
+		name               string
+		maintenanceService string
+		wantTarget         string
+	}{
+		{"503 service", "", "shop-maintenance"},
+		{"maintenance service", "maintenance@file", "maintenance@file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, err := newProvider(&Config{
+				ProjectIDs:         []string{"test-project"},
+				Region:             "us-central1",
+				TokenInjection:     TokenInjectionPlugin,
+				Maintenance:        []string{"lab*"},
+				MaintenanceService: tt.maintenanceService,
+			})
+			if err != nil {
+				t.Fatalf("Failed to create provider: %v", err)
+			}
+			config, err := provider.Build(services)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if got := config.HTTP.Routers["shop"].Service; got != tt.wantTarget {
+				t.Errorf("Expected shop to route to %s, got %s", tt.wantTarget, got)
+			}
+			if got := config.HTTP.Routers["blog"].Service; got != "blog" {
+				t.Errorf("Expected blog to be unaffected, got %s", got)
+			}
+			if got := config.HTTP.Routers["lab1"].Service; got == "lab1" {
+				t.Error("Expected lab1 to be in maintenance by pattern")
+			}
+			if _, ok := config.HTTP.Services["shop"]; !ok {
+				t.Error("Expected the shop service definition to be kept")
+			}
+			if target, ok := config.HTTP.Services["shop-maintenance"]; ok != (tt.maintenanceService == "") ||
+				len(target.LoadBalancer.Servers) != 0 {
+				t.Errorf("Unexpected shop-maintenance service %+v", target)
+			}
+			reasons := 0
+			for _, skipped := range config.Skipped() {
+				if skipped.Reason == SkipReasonMaintenance && skipped.Degraded {
+					reasons++
+				}
+			}
+			if reasons != 2 {
+				t.Errorf("Expected shop and lab1 listed as in maintenance, got %+v", config.Skipped())
+			}
+		})
+	}
+}
+
+func TestProcessService_MaintenanceLeftOut(t *testing.T) {
+	p, err := NewWithClients(&Config{
+		ProjectIDs:         []string{"test-project"},
+		Region:             "us-central1",
+		TokenFailurePolicy: TokenFailureSkipRoute,
+	}, &fakeCloudRunClient{}, &fakeTokenSource{err: errors.New("metadata server down")}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	service := CloudRunService{Name: "shop", ProjectID: "test-project", URL: "https://shop.run.app", Labels: map[string]string{
+		"traefik_http_routers_shop_rule": "PathPrefix(`/shop`)",
+		"traefik_maintenance":            "true",
+	}}
+
+	// A service left out for its token adds nothing for maintenance either
+	config := NewDynamicConfig()
+	var tokenErr *TokenError
+	if err := p.processService(service, config); !errors.As(err, &tokenErr) {
+		t.Fatalf("Expected TokenError, got %v", err)
+	}
+	if len(config.HTTP.Services) != 0 || len(config.HTTP.Routers) != 0 {
+		t.Errorf("Expected nothing in config, got services %v and routers %v", config.HTTP.Services, config.HTTP.Routers)
+	}
+	if skipped := config.Skipped(); len(skipped) != 0 {
+		t.Errorf("Expected no maintenance entry for a service left out, got %+v", skipped)
+	}
+}
@@ -0,0 +1,100 @@
+package provider
+
+import (
+	"sort"
+	"time"
+)
+
+// Auth modes reported in a RouteManifest besides the AuthType* values
+const (
+	ManifestAuthNone     = "none"      // Routed without a credential (Anthos, internal routers, degraded)
+	ManifestAuthProvider = "provider:" // Prefix of a named auth provider (traefik_auth_provider)
+)
+
+// RouteManifest lists every generated route with its backend, auth mode,
+// middlewares and the Cloud Run service and revision it came from, as
+// compliance evidence and for change review pipelines
+type RouteManifest struct {
+	GeneratedAt time.Time       `json:"generatedAt"`
+	Routes      []ManifestRoute `json:"routes"`
+}
+
+// ManifestRoute is one router in a RouteManifest
+type ManifestRoute struct {
+	Router      string          `json:"router"`
+	Rule        string          `json:"rule"`
+	EntryPoints []string        `json:"entryPoints,omitempty"`
+	Service     string          `json:"service"`  // Traefik service the router forwards to
+	Backends    []string        `json:"backends"` // Server URLs of that service
+	Auth        string          `json:"auth"`     // id_token, access_token, provider:<name> or none
+	Middlewares []string        `json:"middlewares,omitempty"`
+	Source      *ManifestSource `json:"source,omitempty"` // Nil for routers not generated from a Cloud Run service
+}
+
+// ManifestSource is the Cloud Run service a route was generated from
+type ManifestSource struct {
+	Service  string `json:"service"`
+	Project  string `json:"project"`
+	Region   string `json:"region"`
+	Platform string `json:"platform"`
+	Revision string `json:"revision,omitempty"`
+}
+
+// Manifest returns the route manifest of the configuration. services are
+// the discovered services the configuration was built from.
+func (c *DynamicConfig) Manifest(services []CloudRunService, generatedAt time.Time) *RouteManifest {
+	byName := make(map[string]CloudRunService, len(services))
+	for _, service := range services {
+		byName[service.Name] = service
+	}
+	unauthenticated := make(map[string]bool)
+	for _, skipped := range c.skipped {
+		if skipped.Reason == SkipReasonNoAuth {
+			unauthenticated[skipped.Service] = true
+		}
+	}
+
+	manifest := &RouteManifest{GeneratedAt: generatedAt, Routes: []ManifestRoute{}}
+	for name, router := range c.HTTP.Routers {
+		route := ManifestRoute{
+			Router:      name,
+			Rule:        router.Rule,
+			EntryPoints: router.EntryPoints,
+			Service:     router.Service,
+			Backends:    []string{},
+			Auth:        ManifestAuthNone,
+			Middlewares: router.Middlewares,
+		}
+		for _, server := range c.HTTP.Services[router.Service].LoadBalancer.Servers {
+			route.Backends = append(route.Backends, server.URL)
+		}
+		if service, ok := byName[c.routerSources[name]]; ok {
+			route.Source = &ManifestSource{
+				Service:  service.Name,
+				Project:  service.ProjectID,
+				Region:   service.Region,
+				Platform: service.Platform,
+				Revision: service.Revision,
+			}
+			if !unauthenticated[service.Name] {
+				route.Auth = manifestAuth(service)
+			}
+		}
+		manifest.Routes = append(manifest.Routes, route)
+	}
+	sort.Slice(manifest.Routes, func(i, j int) bool { return manifest.Routes[i].Router < manifest.Routes[j].Router })
+	return manifest
+}
+
+// manifestAuth returns how requests from a service's routers are authenticated
+func manifestAuth(service CloudRunService) string {
+	switch {
+	case service.Platform == PlatformGKE:
+		return ManifestAuthNone
+	case service.Labels[authProviderLabel] != "":
+		return ManifestAuthProvider + service.Labels[authProviderLabel]
+	case service.Labels[authTypeLabel] == AuthTypeAccessToken:
+		return AuthTypeAccessToken
+	}
+	return AuthTypeIDToken
+}
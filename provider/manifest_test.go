@@ -0,0 +1,74 @@
+package provider
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDynamicConfig_Manifest(t *testing.T) {
+	provider, err := newProvider(&Config{
+		ProjectIDs:          []string{"test-project"},
+		Region:              "us-central1",
+		TokenInjection:      TokenInjectionPlugin,
+		AccessTokenServices: []string{"files"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	service := func(name string, labels map[string]string) CloudRunService {
+		labels["traefik_enable"] = "true"
+		labels["traefik_http_routers_"+name+"_rule"] = "PathPrefix(`/" + name + "`)"
+		return CloudRunService{
+			Name: name, ProjectID: "test-project", Region: "us-central1", Platform: PlatformManaged,
+			URL: "https://" + name + ".run.app", Revision: name + "-00001", Labels: labels,
+		}
+	}
+	gke := service("legacy", map[string]string{})
+	gke.Platform = PlatformGKE
+	services := []CloudRunService{
+		service("api", map[string]string{}),
+		service("files", map[string]string{authTypeLabel: AuthTypeAccessToken}),
+		gke,
+	}
+
+	config, err := provider.Build(services)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	generatedAt := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	manifest := config.Manifest(services, generatedAt)
+	if !manifest.GeneratedAt.Equal(generatedAt) || len(manifest.Routes) != len(config.HTTP.Routers) {
+		t.Fatalf("Expected one route per router, got %+v", manifest)
+	}
+
+	routes := make(map[string]ManifestRoute)
+	for i, route := range manifest.Routes {
+		if i > 0 && manifest.Routes[i-1].Router > route.Router {
+			t.Errorf("Expected routes sorted by router, got %s before %s", manifest.Routes[i-1].Router, route.Router)
+		}
+		routes[route.Router] = route
+	}
+
+	api := routes["api"]
+	if api.Auth != AuthTypeIDToken || api.Rule != "PathPrefix(`/api`)" || len(api.Backends) != 1 || api.Backends[0] != "https://api.run.app" {
+		t.Errorf("Unexpected api route %+v", api)
+	}
+	if api.Source == nil || api.Source.Revision != "api-00001" || api.Source.Project != "test-project" {
+		t.Errorf("Expected api's source service and revision, got %+v", api.Source)
+	}
+	if len(api.Middlewares) == 0 {
+		t.Error("Expected api's middlewares to be listed")
+	}
+	if auth := routes["files"].Auth; auth != AuthTypeAccessToken {
+		t.Errorf("Expected access_token auth for files, got %s", auth)
+	}
+	if auth := routes["legacy"].Auth; auth != ManifestAuthNone {
+		t.Errorf("Expected no auth for the Anthos service, got %s", auth)
+	}
+	for name, route := range routes {
+		if route.Source == nil && route.Auth != ManifestAuthNone {
+			t.Errorf("Expected router %s without a source to have no auth, got %s", name, route.Auth)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package provider
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/internal/schema"
+	"gopkg.in/yaml.v3"
+)
+
+// ParseDynamicConfig parses a routes file back into a DynamicConfig. Files
+// written by older provider versions are migrated to the current schema
+// first. Keys are matched case-insensitively, so hand-written files using
+// Traefik's camelCase spelling (entryPoints, loadBalancer, passHostHeader)
+// load as well as generated ones.
+//
+// Keys the provider doesn't model (e.g. an inFlightReq middleware or a tcp section)
+// are dropped and returned as warnings, one per key path, so callers can
+// decide whether a partial configuration is acceptable.
+func ParseDynamicConfig(data []byte) (*DynamicConfig, []string, error) {
+	body, _, _, err := schema.Migrate(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(body, &doc); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse routes file: %w", err)
+	}
+
+	config := NewDynamicConfig()
+	if len(doc.Content) == 0 {
+		return config, nil, nil // Empty file
+	}
+
+	var warnings []string
+	normalizeNode(doc.Content[0], reflect.TypeOf(*config), "", &warnings)
+	if err := doc.Decode(config); err != nil {
+		return nil, warnings, fmt.Errorf("failed to decode routes file: %w", err)
+	}
+
+	// Sections missing from the file decode as nil maps
+	if config.HTTP.Routers == nil {
+		config.HTTP.Routers = make(map[string]RouterConfig)
+	}
+	if config.HTTP.Services == nil {
+		config.HTTP.Services = make(map[string]ServiceConfig)
+	}
+	if config.HTTP.Middlewares == nil {
+		config.HTTP.Middlewares = make(map[string]MiddlewareConfig)
+	}
+	return config, warnings, nil
+}
+
+// normalizeNode rewrites the keys of YAML mappings decoded into structs of
+// type t to the struct's own yaml key names, matching them
+// case-insensitively, and removes keys the struct has no field for, adding
+// their paths to warnings. Keys of Go maps (router, service and middleware
+// names) are left untouched.
+func normalizeNode(node *yaml.Node, t reflect.Type, path string, warnings *[]string) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return // Let Decode report the type mismatch
+		}
+		fields := yamlFields(t)
+		content := node.Content[:0]
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			field, ok := fields[strings.ToLower(key.Value)]
+			if !ok {
+				*warnings = append(*warnings, fmt.Sprintf("unsupported key %s", joinKeyPath(path, key.Value)))
+				continue
+			}
+			key.Value = field.name
+			normalizeNode(value, field.typ, joinKeyPath(path, field.name), warnings)
+			content = append(content, key, value)
+		}
+		node.Content = content
+
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			normalizeNode(node.Content[i+1], t.Elem(), joinKeyPath(path, node.Content[i].Value), warnings)
+		}
+
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			return
+		}
+		for i, item := range node.Content {
+			normalizeNode(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i), warnings)
+		}
+	}
+}
+
+// yamlField is a struct field as yaml.v3 sees it
+type yamlField struct {
+	name string
+	typ  reflect.Type
+}
+
+// yamlFields returns the serialized fields of a struct type keyed by their
+// lowercased yaml key
+func yamlFields(t reflect.Type) map[string]yamlField {
+	fields := make(map[string]yamlField, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name) // yaml.v3's default key
+		}
+		fields[strings.ToLower(name)] = yamlField{name: name, typ: f.Type}
+	}
+	return fields
+}
+
+// joinKeyPath appends a key to a dotted key path
+func joinKeyPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
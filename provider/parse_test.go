@@ -0,0 +1,158 @@
+package provider
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// marshalRoutes encodes a configuration the way routes.yml is written
+func marshalRoutes(t *testing.T, config *DynamicConfig) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(config); err != nil {
+		t.Fatalf("Failed to encode YAML: %v", err)
+	}
+	encoder.Close()
+	return buf.Bytes()
+}
+
+func TestParseDynamicConfig_RoundTripGolden(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "golden", "*.golden.yml"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("No golden files found: %v", err)
+	}
+
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatalf("Failed to read %s: %v", file, err)
+			}
+			config, warnings, err := ParseDynamicConfig(data)
+			if err != nil {
+				t.Fatalf("ParseDynamicConfig failed: %v", err)
+			}
+			if len(warnings) > 0 {
+				t.Errorf("Unexpected warnings: %v", warnings)
+			}
+			if got := marshalRoutes(t, config); !bytes.Equal(got, data) {
+				t.Errorf("Round trip changed the file\n--- got ---\n%s\n--- want ---\n%s", got, data)
+			}
+		})
+	}
+}
+
+func TestParseDynamicConfig_HandWritten(t *testing.T) {
+	// Unversioned file in Traefik's own spelling, as people wrote before the
+	// provider generated routes
+	data := []byte(`# Hand-written routes
+http:
+  routers:
+    lab1:
+      rule: PathPrefix(` + "`/lab1`" + `)
+      service: lab1
+      entryPoints:
+        - web
+      middlewares:
+        - lab1-auth
+        - retry
+        - limit
+  services:
+    lab1:
+      loadBalancer:
+        passHostHeader: false
+        servers:
+          - url: https://lab1.run.app
+  middlewares:
+    lab1-auth:
+      headers:
+        customRequestHeaders:
+          X-Serverless-Authorization: Bearer token
+    retry:
+      retry:
+        attempts: 3
+    limit:
+      inFlightReq:
+        amount: 10
+tcp:
+  routers: {}
+`)
+
+	config, warnings, err := ParseDynamicConfig(data)
+	if err != nil {
+		t.Fatalf("ParseDynamicConfig failed: %v", err)
+	}
+
+	wantRouter := RouterConfig{
+		Rule:        "PathPrefix(`/lab1`)",
+		Service:     "lab1",
+		EntryPoints: []string{"web"},
+		Middlewares: []string{"lab1-auth", "retry", "limit"},
+	}
+	if got := config.HTTP.Routers["lab1"]; !reflect.DeepEqual(got, wantRouter) {
+		t.Errorf("Router = %+v, want %+v", got, wantRouter)
+	}
+	if got := config.HTTP.Services["lab1"].LoadBalancer.Servers; len(got) != 1 || got[0].URL != "https://lab1.run.app" {
+		t.Errorf("Servers = %+v", got)
+	}
+	auth := config.HTTP.Middlewares["lab1-auth"].Headers
+	if auth == nil || auth.CustomRequestHeaders["X-Serverless-Authorization"] != "Bearer token" {
+		t.Errorf("Headers middleware = %+v", auth)
+	}
+	if retry := config.HTTP.Middlewares["retry"].Retry; retry == nil || retry.Attempts != 3 {
+		t.Errorf("Retry middleware = %+v", retry)
+	}
+	if _, ok := config.HTTP.Middlewares["limit"]; !ok {
+		t.Error("Expected the limit middleware to be kept (without its unsupported settings)")
+	}
+
+	wantWarnings := []string{"unsupported key http.middlewares.limit.inFlightReq", "unsupported key tcp"}
+	if !reflect.DeepEqual(warnings, wantWarnings) {
+		t.Errorf("Warnings = %v, want %v", warnings, wantWarnings)
+	}
+
+	// The parsed configuration must be usable like a generated one
+	config.AddRouterWithSource("extra", RouterConfig{Rule: "Path(`/x`)", Service: "lab1"}, "extra")
+	if _, ok := config.HTTP.Routers["extra"]; !ok {
+		t.Error("Expected router added after parsing")
+	}
+}
+
+func TestParseDynamicConfig_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"newer schema", "# Schema-Version: 99\nhttp: {}\n", "newer than supported"},
+		{"invalid yaml", "http: [\n", "failed to parse"},
+		{"wrong type", "http:\n  routers:\n    r:\n      priority: high\n", "failed to decode"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := ParseDynamicConfig([]byte(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestParseDynamicConfig_Empty(t *testing.T) {
+	config, warnings, err := ParseDynamicConfig(nil)
+	if err != nil || len(warnings) > 0 {
+		t.Fatalf("ParseDynamicConfig(nil) = %v, %v", warnings, err)
+	}
+	config.AddRouterWithSource("r", RouterConfig{Service: "s"}, "s")
+	config.HTTP.Services["s"] = ServiceConfig{}
+	config.HTTP.Middlewares["m"] = MiddlewareConfig{}
+}
@@ -0,0 +1,288 @@
+package provider
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/internal/logging"
+)
+
+// Policies for routers that share an entry point and a priority and whose
+// rules overlap, so which one Traefik picks for a request is arbitrary
+const (
+	PriorityTieWarn = "warn" // Default: log and report them
+	PriorityTieBump = "bump" // Also raise priorities so the most specific rule wins
+)
+
+// PriorityTie is a group of routers with the same priority, a shared
+// entry point and overlapping rules
+type PriorityTie struct {
+	Priority    int      // The priority they share (Traefik's rule-length default if unset)
+	EntryPoints []string // Entry points of the routers
+	Routers     []string // Most specific rule first
+	Bumped      bool     // Whether priorities were raised to order them
+}
+
+// pathMatch is one Path (exact) or PathPrefix condition of a rule
+type pathMatch struct {
+	path  string
+	exact bool
+}
+
+// overlaps reports whether a request path can satisfy both conditions
+func (m pathMatch) overlaps(o pathMatch) bool {
+	switch {
+	case m.exact && o.exact:
+		return m.path == o.path
+	case m.exact:
+		return strings.HasPrefix(m.path, o.path)
+	case o.exact:
+		return strings.HasPrefix(o.path, m.path)
+	}
+	return strings.HasPrefix(m.path, o.path) || strings.HasPrefix(o.path, m.path)
+}
+
+// ruleScope approximates the requests a rule matches by its hosts and paths.
+// A nil list matches any host or path; the approximation errs on the side of
+// overlapping.
+type ruleScope struct {
+	hosts []string
+	paths []pathMatch
+}
+
+// scopeOf computes the scope of a rule. Rules that don't parse match anything.
+func scopeOf(rule string) ruleScope {
+	node, err := parseRule(rule)
+	if err != nil {
+		return ruleScope{}
+	}
+	return node.scope()
+}
+
+// scope computes the scope of a rule node. Negations and matchers on
+// anything but hosts and paths don't narrow it.
+func (n *ruleNode) scope() ruleScope {
+	switch n.op {
+	case ruleOpMatcher:
+		switch n.name {
+		case "Host", "HostHeader":
+			hosts := make([]string, len(n.args))
+			for i, host := range n.args {
+				hosts[i] = strings.ToLower(host)
+			}
+			return ruleScope{hosts: hosts}
+		case "Path", "PathPrefix":
+			paths := make([]pathMatch, len(n.args))
+			for i, path := range n.args {
+				paths[i] = pathMatch{path: path, exact: n.name == "Path"}
+			}
+			return ruleScope{paths: paths}
+		}
+	case ruleOpOr:
+		scope := n.children[0].scope()
+		for _, child := range n.children[1:] {
+			other := child.scope()
+			if scope.hosts == nil || other.hosts == nil {
+				scope.hosts = nil
+			} else {
+				scope.hosts = append(scope.hosts, other.hosts...)
+			}
+			if scope.paths == nil || other.paths == nil {
+				scope.paths = nil
+			} else {
+				scope.paths = append(scope.paths, other.paths...)
+			}
+		}
+		return scope
+	case ruleOpAnd:
+		var scope ruleScope
+		for _, child := range n.children {
+			other := child.scope()
+			switch {
+			case scope.hosts == nil:
+				scope.hosts = other.hosts
+			case other.hosts != nil:
+				hosts := []string{}
+				for _, host := range scope.hosts {
+					if containsString(other.hosts, host) {
+						hosts = append(hosts, host)
+					}
+				}
+				scope.hosts = hosts
+			}
+			if scope.paths == nil {
+				scope.paths = other.paths
+			}
+		}
+		return scope
+	}
+	return ruleScope{}
+}
+
+// overlaps reports whether a request can match both scopes
+func (s ruleScope) overlaps(o ruleScope) bool {
+	if s.hosts != nil && o.hosts != nil {
+		shared := false
+		for _, host := range s.hosts {
+			if containsString(o.hosts, host) {
+				shared = true
+				break
+			}
+		}
+		if !shared {
+			return false
+		}
+	}
+	if s.paths == nil || o.paths == nil {
+		return true
+	}
+	for _, a := range s.paths {
+		for _, b := range o.paths {
+			if a.overlaps(b) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// containsString reports whether values contains value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// sharesEntryPoint reports whether two routers listen on a common entry
+// point. A router without entry points listens on all of them.
+func sharesEntryPoint(a, b RouterConfig) bool {
+	if len(a.EntryPoints) == 0 || len(b.EntryPoints) == 0 {
+		return true
+	}
+	for _, ep := range a.EntryPoints {
+		if containsString(b.EntryPoints, ep) {
+			return true
+		}
+	}
+	return false
+}
+
+// effectivePriority is the priority Traefik orders a router by: its
+// priority, or the length of its rule when unset
+func effectivePriority(router RouterConfig) int {
+	if router.Priority > 0 {
+		return router.Priority
+	}
+	return len(router.Rule)
+}
+
+// checkPriorityTies finds the routers of config that Traefik can't
+// order deterministically and logs them. Under the bump policy, each group
+// is ordered by rule length (the most specific rule first, then by name) and
+// given consecutive priorities from the shared one up, so the least specific
+// router keeps its priority. Raised priorities aren't checked again.
+func (p *Provider) checkPriorityTies(config *DynamicConfig) []PriorityTie {
+	names := make([]string, 0, len(config.HTTP.Routers))
+	for name := range config.HTTP.Routers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	byPriority := make(map[int][]string)
+	for _, name := range names {
+		priority := effectivePriority(config.HTTP.Routers[name])
+		byPriority[priority] = append(byPriority[priority], name)
+	}
+	priorities := make([]int, 0, len(byPriority))
+	for priority := range byPriority {
+		priorities = append(priorities, priority)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(priorities)))
+
+	bump := p.config.PriorityTiePolicy == PriorityTieBump
+	var ties []PriorityTie
+	for _, priority := range priorities {
+		for _, group := range tiedGroups(config, byPriority[priority]) {
+			sort.SliceStable(group, func(i, j int) bool {
+				return len(config.HTTP.Routers[group[i]].Rule) > len(config.HTTP.Routers[group[j]].Rule)
+			})
+			tie := PriorityTie{Priority: priority, Routers: group, Bumped: bump}
+			for _, name := range group {
+				for _, ep := range config.HTTP.Routers[name].EntryPoints {
+					if !containsString(tie.EntryPoints, ep) {
+						tie.EntryPoints = append(tie.EntryPoints, ep)
+					}
+				}
+			}
+			sort.Strings(tie.EntryPoints)
+			ties = append(ties, tie)
+
+			p.logger.Warn("Routers share a priority and overlapping rules, their order is arbitrary",
+				logging.GetCodeField(logging.CodePriorityTie),
+				logging.Strings("routers", group),
+				logging.Int("priority", priority),
+				logging.Strings("entryPoints", tie.EntryPoints),
+				logging.Bool("bumped", bump),
+			)
+			if bump {
+				for i, name := range group {
+					router := config.HTTP.Routers[name]
+					router.Priority = priority + len(group) - 1 - i
+					config.HTTP.Routers[name] = router
+				}
+			}
+		}
+	}
+	return ties
+}
+
+// tiedGroups splits routers of the same priority into groups connected
+// by a shared entry point and overlapping rules, leaving out routers that
+// tie with none. names is sorted, and so is each group.
+func tiedGroups(config *DynamicConfig, names []string) [][]string {
+	if len(names) < 2 {
+		return nil
+	}
+	scopes := make([]ruleScope, len(names))
+	for i, name := range names {
+		scopes[i] = scopeOf(config.HTTP.Routers[name].Rule)
+	}
+	group := make([]int, len(names)) // Union-find parent of each router
+	for i := range group {
+		group[i] = i
+	}
+	var root func(i int) int
+	root = func(i int) int {
+		if group[i] != i {
+			group[i] = root(group[i])
+		}
+		return group[i]
+	}
+	for i := range names {
+		for j := i + 1; j < len(names); j++ {
+			a, b := config.HTTP.Routers[names[i]], config.HTTP.Routers[names[j]]
+			if sharesEntryPoint(a, b) && scopes[i].overlaps(scopes[j]) {
+				group[root(j)] = root(i)
+			}
+		}
+	}
+
+	members := make(map[int][]string)
+	var roots []int
+	for i, name := range names {
+		r := root(i)
+		if _, ok := members[r]; !ok {
+			roots = append(roots, r)
+		}
+		members[r] = append(members[r], name)
+	}
+	var groups [][]string
+	for _, r := range roots {
+		if len(members[r]) > 1 {
+			groups = append(groups, members[r])
+		}
+	}
+	return groups
+}
@@ -0,0 +1,84 @@
+package provider
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRuleScopeOverlaps(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"PathPrefix(`/app`)", "PathPrefix(`/app/api`)", true},
+		{"PathPrefix(`/app`)", "PathPrefix(`/other`)", false},
+		{"Path(`/app/health`)", "PathPrefix(`/app`)", true},
+		{"Path(`/a`)", "Path(`/b`)", false},
+		{"Host(`a.example.com`)", "Host(`b.example.com`) && PathPrefix(`/`)", false},
+		{"Host(`A.example.com`) && PathPrefix(`/x`)", "Host(`a.example.com`)", true},
+		{"PathPrefix(`/a`) || PathPrefix(`/b`)", "PathPrefix(`/b/c`)", true},
+		{"Header(`X-Lab`, `1`)", "PathPrefix(`/anything`)", true},
+		{"!PathPrefix(`/a`)", "PathPrefix(`/a`)", true},
+	}
+	for _, tt := range tests {
+		if got := scopeOf(tt.a).overlaps(scopeOf(tt.b)); got != tt.want {
+			t.Errorf("%s overlaps %s = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestBuild_PriorityTies(t *testing.T) {
+	services := []CloudRunService{
+		{Name: "shop", ProjectID: "test-project", URL: "https://shop.run.app", Labels: map[string]string{
+			"traefik_enable": "true", "traefik_http_routers_shop_rule": "PathPrefix(`/shop`)",
+		}},
+		{Name: "shop-api", ProjectID: "test-project", URL: "https://shop-api.run.app", Labels: map[string]string{
+			"traefik_enable": "true", "traefik_http_routers_shop-api_rule": "PathPrefix(`/shop/api`)",
+		}},
+		{Name: "blog", ProjectID: "test-project", URL: "https://blog.run.app", Labels: map[string]string{
+			"traefik_enable": "true", "traefik_http_routers_blog_rule": "PathPrefix(`/blog`)",
+		}},
+		{Name: "admin", ProjectID: "test-project", URL: "https://admin.run.app", Labels: map[string]string{
+			"traefik_enable": "true", "traefik_http_routers_admin_rule": "PathPrefix(`/shop/admin`)",
+			"traefik_http_routers_admin_entrypoints": "internal",
+		}},
+	}
+
+	for _, policy := range []string{"", PriorityTieBump} {
+		t.Run(policy, func(t *testing.T) {
+			provider, err := newProvider(&Config{
+				ProjectIDs:        []string{"test-project"},
+				Region:            "us-central1",
+				TokenInjection:    TokenInjectionPlugin,
+				PriorityTiePolicy: policy,
+			})
+			if err != nil {
+				t.Fatalf("Failed to create provider: %v", err)
+			}
+			config, err := provider.Build(services)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			ties := config.PriorityTies()
+			if len(ties) != 1 || strings.Join(ties[0].Routers, ",") != "shop-api,shop" || ties[0].Priority != 200 {
+				t.Fatalf("Expected shop-api and shop to tie at 200, got %+v", ties)
+			}
+			shop, api := config.HTTP.Routers["shop"].Priority, config.HTTP.Routers["shop-api"].Priority
+			if policy == PriorityTieBump {
+				if !ties[0].Bumped || shop != 200 || api != 201 {
+					t.Errorf("Expected shop-api bumped above shop, got %d and %d", api, shop)
+				}
+			} else if shop != 200 || api != 200 {
+				t.Errorf("Expected priorities to be left alone, got %d and %d", api, shop)
+			}
+		})
+	}
+}
+
+func TestNew_InvalidPriorityTiePolicy(t *testing.T) {
+	_, err := New(&Config{ProjectIDs: []string{"p"}, Region: "r", PriorityTiePolicy: "shuffle"})
+	if err == nil || !strings.Contains(err.Error(), "priority tie policy") {
+		t.Errorf("Expected invalid policy error, got %v", err)
+	}
+}
@@ -2,13 +2,19 @@ package provider
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"net"
 	"os"
+	"path"
 	"strings"
 	"time"
 
 	"github.com/pci-tamper-protect/traefik-cloudrun-provider/internal/gcp"
 	"github.com/pci-tamper-protect/traefik-cloudrun-provider/internal/logging"
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/internal/redact"
 	run "google.golang.org/api/run/v1"
 )
 
@@ -25,15 +31,314 @@ type Config struct {
 
 	// Token cache settings
 	TokenRefreshBefore time.Duration // Refresh tokens this long before expiry
+
+	// Token injection: "static" bakes identity tokens into headers middlewares,
+	// "plugin" emits middlewares for the token middleware plugin which fetches
+	// a fresh token per request
+	TokenInjection  string
+	TokenPluginName string // Name the token middleware plugin is registered under in Traefik's static config
+
+	// Give services with the same backend URL (token audience), auth type and
+	// auth provider one auth-<hash> middleware instead of a <service>-auth
+	// middleware each carrying the same token
+	ShareAuthMiddlewares bool
+
+	// What to do when a service's identity token can't be fetched (static mode only):
+	// "emit-without-auth" (default), "skip-route" or "fail-generation".
+	// Overridable per service with the traefik_token_failure_policy label.
+	TokenFailurePolicy string
+
+	// Services allowed traefik_auth_type=access_token (glob patterns on
+	// service names, or on <project>/<service>). The label is refused for
+	// other services, which would otherwise get the provider's access token
+	// just by labelling themselves. Their tokens are requested for
+	// AccessTokenScopes (default DefaultAccessTokenScopes)
+	// (env: ACCESS_TOKEN_SERVICES, ACCESS_TOKEN_SCOPES)
+	AccessTokenServices []string
+	AccessTokenScopes   []string
+
+	// What to do with traefik_* labels the provider doesn't recognize, such as
+	// a misspelled router property: "ignore" (default), "warn" or "strict"
+	// (skip the service)
+	LabelValidation string
+
+	// Explicit credentials (service account key, impersonated service account
+	// or external account JSON) used for listing services and minting tokens
+	// instead of the ambient identity. Set at most one of the two.
+	CredentialsFile string
+	CredentialsJSON string
+
+	// Cloud Run Admin API endpoint instead of run.googleapis.com, e.g. a
+	// regional endpoint or an emulator such as tests/fake-run-api (plain
+	// http endpoints are called without credentials)
+	RunAPIEndpoint string
+
+	// Named auth providers for services labelled traefik_auth_provider=<name>,
+	// e.g. static bearer tokens or OIDC client credentials for non-GCP
+	// backends. Services without the label use the GCP identity.
+	AuthProviders []AuthProviderConfig
+
+	// Project label defaults: labels applied to every Traefik-enabled
+	// service in a project unless the service sets them itself, keyed by
+	// project ID. A service named DefaultsService (default "defaults") in
+	// the project contributes defaults too, overriding these, and is never
+	// routed. Router properties given for the router named "default" (e.g.
+	// traefik_http_routers_default_middlewares) apply to every router.
+	ProjectDefaults map[string]map[string]string
+	DefaultsService string
+
+	// Cloud Run for Anthos namespaces discovered alongside the fully managed
+	// projects. Anthos services don't check identity tokens, so no auth
+	// middleware is generated for them.
+	AnthosTargets []AnthosTarget
+
+	// API quota settings
+	ListCacheTTL         time.Duration // Reuse a project's cached service list for this long (0 = list every poll)
+	ScanJitter           time.Duration // Max random delay added to each project's next scan
+	ProjectRequestBudget int           // Max List API calls per project per minute (0 = unlimited)
+
+	// Keep the routes of a project that fails to list (or is skipped after
+	// repeated failures) from its last successful list for this long,
+	// instead of dropping them until it recovers (0 = drop immediately)
+	StaleRouteGracePeriod time.Duration
+	// Keep the routes of a service that disappears from discovery for this
+	// many polls before removing them, to ride out transient API
+	// inconsistencies and deploy races (0 = remove immediately)
+	RouteDeletionDelay int
+	// Refuse to emit a configuration that removes more than this percentage
+	// of the previous configuration's routers, keeping the previous one,
+	// unless AllowRouterRemoval is set (0 = no limit)
+	MaxRouterRemoval   int
+	AllowRouterRemoval bool
+	// Fail instead of warning when deprecated settings or label forms are
+	// in use: the provider refuses to start, or a generation to emit (env:
+	// FAIL_ON_DEPRECATED)
+	FailOnDeprecated bool
+
+	// User auth (forwardAuth) settings. The env variables only apply when
+	// UserAuthEnabled or SkipAuthCheck is nil, so an explicit false wins.
+	UserAuthEnabled *bool          // Generate forwardAuth middlewares and keep auth-check middlewares on routers (env: USER_AUTH_ENABLED)
+	SkipAuthCheck   *bool          // Deprecated: use UserAuthEnabled=false, which it is migrated to (env: SKIP_AUTH_CHECK)
+	HomeIndexURL    string         // Fallback home-index URL when discovery doesn't find it (env: HOME_INDEX_URL)
+	UserAuth        UserAuthConfig // Middleware names, check path and headers used when UserAuthEnabled
+
+	// Middlewares appended to every generated router (default:
+	// retry-cold-start@file, or none when ColdStartByScale is set)
+	DefaultMiddlewares []string
+
+	// Cold-start handling by scaling: instead of retrying cold starts on
+	// every router, only services that scale to zero (minScale unset or 0)
+	// get ColdStartMiddlewares (default retry-cold-start@file) and a
+	// serversTransport with ColdStartTimeout (default 60s) as response
+	// header timeout (env: COLD_START_BY_SCALE, COLD_START_TIMEOUT)
+	ColdStartByScale     bool
+	ColdStartMiddlewares []string
+	ColdStartTimeout     time.Duration
+
+	// Request timeouts: give each service a serversTransport whose response
+	// header timeout matches its Cloud Run request timeout (up to 60m), so
+	// long-running requests aren't cut off by a shorter Traefik-wide default
+	// (env: REQUEST_TIMEOUTS). The traefik_request_timeout label overrides
+	// the timeout per service, also when this is off.
+	RequestTimeouts bool
+
+	// Prefix for the names of all generated routers, services and middlewares
+	// (e.g. "cloudrun-"), to avoid collisions with objects from other Traefik
+	// providers. References to provider-qualified names (name@file) are kept.
+	NamePrefix string
+
+	// Traefik version the configuration is written for: "v2" (default) or
+	// "v3". Router rules are rewritten into that version's syntax (e.g.
+	// Headers vs Header, multi-value matchers) and ipAllowList middlewares
+	// are written as ipWhiteList for v2.
+	TraefikVersion string
+
+	// Entrypoint auto-assignment: routers without an entrypoints label get
+	// the entrypoints (and TLS) of their rule type instead of "web" -
+	// websecure with TLS for Host rules, web for Path-only rules (env:
+	// AUTO_ENTRYPOINTS). EntryPointRules overrides that per rule type
+	// (RuleTypeHost, RuleTypePath).
+	AutoEntryPoints bool
+	EntryPointRules map[string]EntryPointRule
+
+	// Environment the services are deployed for (e.g. "stg") and the
+	// environment suffixes stripped from service names when deciding which
+	// service a router is dedicated to in a router name conflict (env:
+	// ENVIRONMENT, ENVIRONMENT_SUFFIXES). Entries ending in "-" are prefixes
+	// ("qa-"). Default: DefaultEnvironmentSuffixes plus "-<Environment>".
+	Environment         string
+	EnvironmentSuffixes []string
+
+	// What to do with services whose Ready condition isn't True (e.g. the
+	// latest revision failed to deploy): "ignore" (default), "skip", or
+	// "failover" to keep routing while an older revision is still serving
+	// (env: READINESS_POLICY)
+	ReadinessPolicy string
+
+	// What to do with services serving 0% of traffic (e.g. fully rolled
+	// back): "route" (default), "drop", or "deprioritize" to route them at
+	// the lowest priority so their rules can't shadow live routes
+	// (env: ZERO_TRAFFIC_POLICY)
+	ZeroTrafficPolicy string
+
+	// What to do with routers that share an entry point and a priority and
+	// whose rules overlap (e.g. two services both on the default 200):
+	// "warn" (default) or "bump" to raise priorities so the most specific
+	// rule wins (env: PRIORITY_TIE_POLICY)
+	PriorityTiePolicy string
+
+	// Which service keeps a router defined by several services:
+	// "dedicated-wins" (default: the service named after the router, e.g.
+	// lab1-c2-stg for lab1-c2), "first-wins", "last-wins",
+	// "highest-priority-wins" or "error" to fail generation
+	// (env: ROUTER_CONFLICT_POLICY)
+	RouterConflictPolicy string
+
+	// Traefik API and dashboard routers (api@internal), off unless
+	// Dashboard.Enabled is set (env: DASHBOARD_*)
+	Dashboard DashboardConfig
+
+	// Route tagging: attach a headers middleware to every generated router that
+	// sets RouteTagHeader to the router name, so backend logs and Traefik access
+	// logs can be joined by route. Services labelled traefik_route_tag=false are skipped.
+	RouteTagging   bool
+	RouteTagHeader string // Header carrying the router name (default X-Route-Name)
+
+	// Request IDs: prepend a middleware to every generated router that sets
+	// RequestIDHeader when the request has none. Uses the token middleware
+	// plugin (registered as TokenPluginName), since headers middlewares can
+	// only set static values.
+	RequestIDEnabled bool
+	RequestIDHeader  string // Default X-Request-ID
+
+	// Service filters: glob patterns (path.Match syntax) on Cloud Run service names.
+	// When IncludeServices is set only matching services are routed; ExcludeServices wins over it.
+	IncludeServices []string
+	ExcludeServices []string
+
+	// Maintenance mode: the routers of services matching Maintenance (glob
+	// patterns, like the service filters) or labelled traefik_maintenance=true
+	// point at MaintenanceService, or at a 503 service when it is unset
+	// (env: MAINTENANCE, MAINTENANCE_SERVICE)
+	Maintenance        []string
+	MaintenanceService string
+
+	// Services allowed to define routers matching every request, like
+	// PathPrefix(`/`) (glob patterns; default DefaultCatchAllServices). Other
+	// services need the traefik_allow_catch_all=true label
+	// (env: CATCH_ALL_SERVICES)
+	CatchAllServices []string
+
+	// Guardrails on the rules services may generate, e.g. no /admin routes
+	// from staging projects; routers a policy rejects are dropped (set from
+	// rulePolicies in the CONFIG_FILE)
+	RulePolicies []RulePolicy
+
+	// Logging (env: LOG_LEVEL, LOG_FORMAT)
+	LogLevel  string
+	LogFormat string
+	LogOutput io.Writer // default os.Stdout
+
+	// LogHandler receives the provider's log entries instead of LogOutput,
+	// for embedders with their own slog (or logr, via logr.ToSlogHandler)
+	// stack. LOG_LEVEL and redaction still apply. (optional)
+	LogHandler slog.Handler
+
+	// Redaction of credentials and email addresses in logs, previews and
+	// reports (env: REDACT_HEADERS, REDACT_CLAIMS, REDACT_EMAILS)
+	RedactHeaders []string // Extra credential headers (Authorization, X-Serverless-Authorization and Proxy-Authorization always are)
+	RedactClaims  []string // JWT claims kept readable (default iss, aud, azp, exp, iat)
+	RedactEmails  string   // mask (default), hide or keep
+
+	// Error budget: after BreakerThreshold consecutive failures a project or
+	// service is skipped for BreakerCooldown (0 threshold = disabled, default cooldown 5m)
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+
+	// Backend self-test: after generation, probe each backend with its minted
+	// token and record reachability in the GenerationReport.
+	// Services labelled traefik_selftest=false are skipped.
+	SelfTest            bool
+	SelfTestConcurrency int           // Max concurrent probes (default 4)
+	SelfTestTimeout     time.Duration // Per-probe timeout (default 5s)
+
+	// Backend DNS check: before emitting, resolve each backend's host name
+	// and warn about (and report) empty URLs and hosts that don't resolve
+	DNSCheck        bool
+	DNSCheckTimeout time.Duration // Per-lookup timeout (default 2s)
+
+	// Incremental update settings
+	IncrementalUpdates bool          // Reuse generated config for services whose fingerprint is unchanged
+	FragmentMaxAge     time.Duration // Rebuild cached service config after this long (default 30m, must be below token lifetime)
 }
 
+// Token injection modes
+const (
+	TokenInjectionStatic = "static"
+	TokenInjectionPlugin = "plugin"
+)
+
+// Token fetch failure policies
+const (
+	TokenFailureEmitWithoutAuth = "emit-without-auth" // Route without the auth middleware (Cloud Run returns 401)
+	TokenFailureSkipRoute       = "skip-route"        // Leave the service out of the generated config
+	TokenFailureFailGeneration  = "fail-generation"   // Abort the cycle so the previous config stays in place
+)
+
+// tokenFailurePolicyLabel overrides TokenFailurePolicy for a single service
+const tokenFailurePolicyLabel = "traefik_token_failure_policy"
+
+// Auth types set with the traefik_auth_type label
+const (
+	AuthTypeIDToken     = "id_token"     // Default: identity token for the service URL in X-Serverless-Authorization
+	AuthTypeAccessToken = "access_token" // OAuth access token in Authorization (API Gateway, GCS-backed sites)
+)
+
+// authTypeLabel selects the kind of token a service's auth middleware carries
+const authTypeLabel = "traefik_auth_type"
+
+// DefaultAccessTokenScopes is the AccessTokenScopes default: enough for a
+// backend to identify the caller, but no access to Google APIs
+var DefaultAccessTokenScopes = []string{"https://www.googleapis.com/auth/userinfo.email"}
+
+// DefaultTokenPluginName is the plugin name used when TokenPluginName is not set
+const DefaultTokenPluginName = "cloudrun-token"
+
+// DefaultRouteTagHeader is the header used when RouteTagHeader is not set
+const DefaultRouteTagHeader = "X-Route-Name"
+
+// DefaultRequestIDHeader is the header used when RequestIDHeader is not set
+const DefaultRequestIDHeader = "X-Request-ID"
+
+// RequestIDMiddlewareName is the middleware generated when RequestIDEnabled is set
+const RequestIDMiddlewareName = "request-id"
+
+// routeTagLabel set to "false" keeps route tagging off a service's routers
+const routeTagLabel = "traefik_route_tag"
+
 // Provider implements the Traefik provider interface for Cloud Run
 type Provider struct {
 	config       *Config
-	runService   *run.APIService
-	tokenManager *gcp.TokenManager
+	client       CloudRunClient
+	tokenManager TokenSource
+	anthos       AnthosClient
+	credentials  *gcp.Credentials
 	logger       *logging.Logger
+	redactor     *redact.Policy
+	listCache    *listCache
+	fragments    *fragmentCache
+	breaker      *circuitBreaker
+	apiStats     *apiStats
+	departures   *departureTracker
+	removals     *removalGuard
+	deprecations []Deprecation // Deprecated settings found in the configuration
+	resolver     hostResolver  // Resolves backend hosts for DNSCheck
+	urls         *urlMemory    // Last URL of each service, for services whose URL goes missing
+	reports      reportStore
 	stopChan     chan struct{}
+
+	// Named auth providers (traefik_auth_provider label)
+	authProviders map[string]boundAuthProvider
 }
 
 // New creates a new Cloud Run provider
@@ -45,73 +350,333 @@ func New(config *Config) (*Provider, error) {
 
 	// Initialize Cloud Run client — requires GCP credentials.
 	ctx := context.Background()
-	runService, err := run.NewService(ctx)
+	runService, err := run.NewService(ctx, gcp.APIClientOptions(config.RunAPIEndpoint, p.credentials)...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Cloud Run service: %w", err)
 	}
-	p.logger.Debug("Cloud Run API client initialized")
-	p.runService = runService
+	p.logger.Debug("Cloud Run API client initialized", logging.String("endpoint", config.RunAPIEndpoint))
+	p.client = NewCloudRunClient(runService)
+	if len(config.AnthosTargets) > 0 {
+		p.anthos = NewAnthosClient(ctx, p.credentials.ClientOptions()...)
+	}
 
 	return p, nil
 }
 
+// NewWithClients creates a provider with the given Cloud Run client, token
+// source and logger instead of connecting to GCP, so discovery and token
+// fetching can be mocked. A nil tokens or logger gets the default.
+func NewWithClients(config *Config, client CloudRunClient, tokens TokenSource, logger *logging.Logger) (*Provider, error) {
+	if err := prepareConfig(config); err != nil {
+		return nil, err
+	}
+	redactor, err := newRedactor(config)
+	if err != nil {
+		return nil, err
+	}
+
+	if logger == nil {
+		logger = newLogger(config, redactor)
+	}
+
+	deprecations := migrateDeprecatedSettings(config)
+	warnDeprecated(logger, deprecations)
+	if config.FailOnDeprecated && len(deprecations) > 0 {
+		return nil, &DeprecationError{Deprecations: deprecations}
+	}
+
+	logger.Info("Initializing Cloud Run provider",
+		logging.Any("projects", config.ProjectIDs),
+		logging.String("region", config.Region),
+		logging.Duration("pollInterval", config.PollInterval),
+	)
+
+	credentials, err := gcp.LoadCredentials(config.CredentialsFile, config.CredentialsJSON)
+	if err != nil {
+		return nil, err
+	}
+	if credentials != nil {
+		logger.Info("Using explicit credentials instead of the ambient identity")
+	}
+
+	if tokens == nil {
+		tokenManager := gcp.NewTokenManagerWithCredentials(credentials)
+		if credentials == nil && tokenManager.IsDevMode() {
+			logger.Warn("Running in development mode - will use ADC for tokens if metadata server unavailable")
+		}
+		logTokenCredentialPath(logger, tokenManager)
+		tokens = tokenManager
+	}
+
+	authProviders := make(map[string]boundAuthProvider, len(config.AuthProviders))
+	for _, authConfig := range config.AuthProviders {
+		authProviders[authConfig.Name] = boundAuthProvider{
+			AuthProvider: newAuthProvider(authConfig, credentials),
+			projects:     authConfig.Projects,
+			services:     authConfig.Services,
+		}
+	}
+
+	return &Provider{
+		config:        config,
+		client:        client,
+		tokenManager:  tokens,
+		credentials:   credentials,
+		logger:        logger,
+		redactor:      redactor,
+		listCache:     newListCache(config),
+		fragments:     newFragmentCache(config),
+		breaker:       newCircuitBreaker(config),
+		apiStats:      newAPIStats(),
+		departures:    newDepartureTracker(),
+		removals:      &removalGuard{},
+		deprecations:  deprecations,
+		resolver:      net.DefaultResolver,
+		urls:          newURLMemory(),
+		stopChan:      make(chan struct{}),
+		authProviders: authProviders,
+	}, nil
+}
+
+// logTokenCredentialPath logs how identity tokens will be minted, warning
+// when no path is available so misconfiguration shows at startup
+func logTokenCredentialPath(logger *logging.Logger, tokenManager *gcp.TokenManager) {
+	path := tokenManager.CredentialPath()
+	if path == gcp.CredentialPathNone {
+		logger.Warn("Metadata server unavailable and dev mode disabled - identity tokens can't be minted; set CLOUDRUN_PROVIDER_DEV_MODE=true to use ADC",
+			logging.String("credentialPath", path),
+		)
+		return
+	}
+	logger.Info("Identity token credential path selected",
+		logging.String("credentialPath", path),
+		logging.Bool("metadataServer", tokenManager.HasMetadataServer()),
+	)
+}
+
 // newProvider builds a Provider without initializing the Cloud Run API client.
 // Used by New (which adds the real client) and by tests that don't exercise
 // service discovery and therefore don't need GCP credentials.
 func newProvider(config *Config) (*Provider, error) {
+	return NewWithClients(config, nil, nil, nil)
+}
+
+// prepareConfig validates the configuration and fills in defaults and
+// environment fallbacks
+func prepareConfig(config *Config) error {
 	if config == nil {
-		return nil, fmt.Errorf("config cannot be nil")
+		return fmt.Errorf("config cannot be nil")
 	}
 
 	// Validate configuration
-	if len(config.ProjectIDs) == 0 {
-		return nil, fmt.Errorf("at least one project ID must be specified")
+	if len(config.ProjectIDs) == 0 && len(config.AnthosTargets) == 0 {
+		return fmt.Errorf("at least one project ID must be specified")
 	}
 	if config.Region == "" {
-		return nil, fmt.Errorf("region must be specified")
+		return fmt.Errorf("region must be specified")
 	}
 	if config.PollInterval == 0 {
 		config.PollInterval = 30 * time.Second
 	}
+	for i, target := range config.AnthosTargets {
+		if target.ProjectID == "" || target.Cluster == "" || target.Endpoint == "" {
+			return fmt.Errorf("anthos target %d: projectID, cluster and endpoint must be specified", i)
+		}
+		config.AnthosTargets[i] = target.withDefaults()
+	}
+	authProviderNames := make(map[string]bool, len(config.AuthProviders))
+	for i, authConfig := range config.AuthProviders {
+		if err := authConfig.validate(); err != nil {
+			return fmt.Errorf("auth provider %d: %w", i, err)
+		}
+		if authProviderNames[authConfig.Name] {
+			return fmt.Errorf("duplicate auth provider %q", authConfig.Name)
+		}
+		authProviderNames[authConfig.Name] = true
+	}
+	policies := make([]RulePolicy, len(config.RulePolicies))
+	for i, policy := range config.RulePolicies {
+		compiled, err := policy.compiled()
+		if err != nil {
+			return fmt.Errorf("rule policy %d: %w", i, err)
+		}
+		policies[i] = compiled
+	}
+	config.RulePolicies = policies
+	config.EnvironmentSuffixes = environmentAffixes(config.Environment, config.EnvironmentSuffixes)
+	switch config.ReadinessPolicy {
+	case "":
+		config.ReadinessPolicy = ReadinessIgnore
+	case ReadinessIgnore, ReadinessSkip, ReadinessFailover:
+	default:
+		return fmt.Errorf("invalid readiness policy %q (expected %q, %q or %q)",
+			config.ReadinessPolicy, ReadinessIgnore, ReadinessSkip, ReadinessFailover)
+	}
+	switch config.ZeroTrafficPolicy {
+	case "":
+		config.ZeroTrafficPolicy = ZeroTrafficRoute
+	case ZeroTrafficRoute, ZeroTrafficDrop, ZeroTrafficDeprioritize:
+	default:
+		return fmt.Errorf("invalid zero traffic policy %q (expected %q, %q or %q)",
+			config.ZeroTrafficPolicy, ZeroTrafficRoute, ZeroTrafficDrop, ZeroTrafficDeprioritize)
+	}
+	switch config.PriorityTiePolicy {
+	case "":
+		config.PriorityTiePolicy = PriorityTieWarn
+	case PriorityTieWarn, PriorityTieBump:
+	default:
+		return fmt.Errorf("invalid priority tie policy %q (expected %q or %q)",
+			config.PriorityTiePolicy, PriorityTieWarn, PriorityTieBump)
+	}
+	if config.RouterConflictPolicy == "" {
+		config.RouterConflictPolicy = ConflictDedicatedWins
+	} else if !validConflictPolicy(config.RouterConflictPolicy) {
+		return fmt.Errorf("invalid router conflict policy %q (expected %q, %q, %q, %q or %q)", config.RouterConflictPolicy,
+			ConflictDedicatedWins, ConflictFirstWins, ConflictLastWins, ConflictHighestPriorityWins, ConflictError)
+	}
+	if config.MaxRouterRemoval < 0 || config.MaxRouterRemoval > 100 {
+		return fmt.Errorf("invalid max router removal %d%% (expected 0-100)", config.MaxRouterRemoval)
+	}
+	if err := config.Dashboard.validate(); err != nil {
+		return err
+	}
+	if config.AutoEntryPoints {
+		rules, err := withEntryPointDefaults(config.EntryPointRules)
+		if err != nil {
+			return err
+		}
+		config.EntryPointRules = rules
+	}
+	if len(config.CatchAllServices) == 0 {
+		config.CatchAllServices = DefaultCatchAllServices
+	}
+	if len(config.AccessTokenScopes) == 0 {
+		config.AccessTokenScopes = DefaultAccessTokenScopes
+	}
+	patterns := append(append([]string{}, config.IncludeServices...), config.ExcludeServices...)
+	patterns = append(append(patterns, config.Maintenance...), config.CatchAllServices...)
+	patterns = append(patterns, config.AccessTokenServices...)
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid service filter pattern %q: %w", pattern, err)
+		}
+	}
+	switch config.TokenInjection {
+	case "":
+		config.TokenInjection = TokenInjectionStatic
+	case TokenInjectionStatic, TokenInjectionPlugin:
+	default:
+		return fmt.Errorf("invalid token injection mode %q (expected %q or %q)",
+			config.TokenInjection, TokenInjectionStatic, TokenInjectionPlugin)
+	}
+	if config.TokenPluginName == "" {
+		config.TokenPluginName = DefaultTokenPluginName
+	}
+	if config.TokenFailurePolicy == "" {
+		config.TokenFailurePolicy = TokenFailureEmitWithoutAuth
+	} else if !validTokenFailurePolicy(config.TokenFailurePolicy) {
+		return fmt.Errorf("invalid token failure policy %q (expected %q, %q or %q)",
+			config.TokenFailurePolicy, TokenFailureEmitWithoutAuth, TokenFailureSkipRoute, TokenFailureFailGeneration)
+	}
+	switch config.TraefikVersion {
+	case "":
+		config.TraefikVersion = TraefikV2
+	case TraefikV2, TraefikV3:
+	default:
+		return fmt.Errorf("invalid Traefik version %q (expected %q or %q)", config.TraefikVersion, TraefikV2, TraefikV3)
+	}
+	switch config.LabelValidation {
+	case "":
+		config.LabelValidation = LabelValidationIgnore
+	case LabelValidationIgnore, LabelValidationWarn, LabelValidationStrict:
+	default:
+		return fmt.Errorf("invalid label validation mode %q (expected %q, %q or %q)",
+			config.LabelValidation, LabelValidationIgnore, LabelValidationWarn, LabelValidationStrict)
+	}
+	config.UserAuth = config.UserAuth.withDefaults()
+	if config.ColdStartByScale {
+		if len(config.ColdStartMiddlewares) == 0 {
+			config.ColdStartMiddlewares = []string{DefaultColdStartMiddleware}
+		}
+		if config.ColdStartTimeout <= 0 {
+			config.ColdStartTimeout = DefaultColdStartTimeout
+		}
+	} else if len(config.DefaultMiddlewares) == 0 {
+		config.DefaultMiddlewares = []string{DefaultColdStartMiddleware}
+	}
+	if config.DefaultsService == "" {
+		config.DefaultsService = DefaultDefaultsService
+	}
+	if config.RouteTagHeader == "" {
+		config.RouteTagHeader = DefaultRouteTagHeader
+	}
+	if config.RequestIDHeader == "" {
+		config.RequestIDHeader = DefaultRequestIDHeader
+	}
+
+	// Fall back to environment variables for settings not provided in config
+	if config.UserAuthEnabled == nil {
+		enabled := os.Getenv("USER_AUTH_ENABLED") == labelValueTrue
+		config.UserAuthEnabled = &enabled
+	}
+	if config.SkipAuthCheck == nil {
+		skip := os.Getenv("SKIP_AUTH_CHECK") == labelValueTrue
+		config.SkipAuthCheck = &skip
+	}
+	if config.HomeIndexURL == "" {
+		config.HomeIndexURL = strings.TrimSpace(os.Getenv("HOME_INDEX_URL"))
+	}
+	if !config.FailOnDeprecated {
+		config.FailOnDeprecated = os.Getenv("FAIL_ON_DEPRECATED") == labelValueTrue
+	}
+	if config.LogLevel == "" {
+		config.LogLevel = os.Getenv("LOG_LEVEL")
+	}
+	if config.LogFormat == "" {
+		config.LogFormat = os.Getenv("LOG_FORMAT")
+	}
+
+	return nil
+}
+
+// newRedactor returns the redaction policy of the configuration
+func newRedactor(config *Config) (*redact.Policy, error) {
+	return redact.New(redact.Rules{
+		Headers: config.RedactHeaders,
+		Claims:  config.RedactClaims,
+		Emails:  config.RedactEmails,
+	})
+}
 
-	// Setup logger
+// newLogger creates the provider logger from the configured level and
+// format, redacting with redactor
+func newLogger(config *Config, redactor *redact.Policy) *logging.Logger {
 	logLevel := logging.LevelInfo
-	if level := os.Getenv("LOG_LEVEL"); level != "" {
+	if level := config.LogLevel; level != "" {
 		if parsed, err := logging.ParseLevel(level); err == nil {
 			logLevel = parsed
 		}
 	}
 
 	logFormat := logging.FormatText
-	if format := os.Getenv("LOG_FORMAT"); format != "" {
+	if format := config.LogFormat; format != "" {
 		if parsed, err := logging.ParseFormat(format); err == nil {
 			logFormat = parsed
 		}
 	}
 
-	logger := logging.New(&logging.Config{
-		Level:  logLevel,
-		Format: logFormat,
-		Output: os.Stdout,
-	}).WithPrefix("CloudRunProvider")
-
-	logger.Info("Initializing Cloud Run provider",
-		logging.Any("projects", config.ProjectIDs),
-		logging.String("region", config.Region),
-		logging.Duration("pollInterval", config.PollInterval),
-	)
-
-	tokenManager := gcp.NewTokenManager()
-	if tokenManager.IsDevMode() {
-		logger.Warn("Running in development mode - will use ADC for tokens if metadata server unavailable")
+	output := config.LogOutput
+	if output == nil {
+		output = os.Stdout
 	}
 
-	return &Provider{
-		config:       config,
-		tokenManager: tokenManager,
-		logger:       logger,
-		stopChan:     make(chan struct{}),
-	}, nil
+	return logging.New(&logging.Config{
+		Level:   logLevel,
+		Format:  logFormat,
+		Output:  output,
+		Redact:  redactor.String,
+		Handler: config.LogHandler,
+	}).WithPrefix("CloudRunProvider")
 }
 
 // Start begins polling for Cloud Run services and generating configurations
@@ -168,104 +733,284 @@ func (p *Provider) pollLoop(configChan chan<- *DynamicConfig) {
 }
 
 // updateConfig discovers services and generates Traefik configuration
-//
-//nolint:gocyclo
 func (p *Provider) updateConfig(configChan chan<- *DynamicConfig) error {
 	startTime := time.Now()
+
+	// Tag every log line of the cycle with its ID
+	generation := generations.Add(1)
+	p.logger.SetContext(logging.Field{Key: "generation", Value: generation})
+	defer p.logger.SetContext()
+
 	p.logger.Info("Starting service discovery...",
 		logging.GetCodeField(logging.CodeServiceDiscoveryStarted),
 	)
-	config := NewDynamicConfig()
 
-	totalServices := 0
+	// Project failures are logged and counted by Discover; generation goes
+	// ahead with the projects that could be listed
+	services, stale, _ := p.discover()
+	services, departing := p.withDepartures(services)
 
-	// Track home-index URL for user auth middleware generation
-	var homeIndexURL string
+	config, err := p.Build(services)
+	if err != nil {
+		return err
+	}
+	if err := p.guardRemovals(config); err != nil {
+		return err
+	}
+	if err := p.guardDeprecations(config); err != nil {
+		return err
+	}
+	config.generation = generation
+
+	duration := time.Since(startTime)
+	p.logger.Info("Configuration generation complete",
+		logging.GetCodeField(logging.CodeConfigGenerationSuccess),
+		logging.Int("totalServices", len(services)),
+		logging.Int("routers", len(config.HTTP.Routers)),
+		logging.Int("services", len(config.HTTP.Services)),
+		logging.Int("middlewares", len(config.HTTP.Middlewares)),
+		logging.Duration("duration", duration),
+	)
+
+	report := &GenerationReport{
+		Generation:  generation,
+		GeneratedAt: time.Now(),
+		Duration:    duration,
+		Services:    len(services),
+		Discovered:  services,
+		Routers:     len(config.HTTP.Routers),
+		Middlewares: len(config.HTTP.Middlewares),
+		Skipped:     config.Skipped(),
+		Stale:       stale,
+		Departing:   departing,
+		Deprecated:  append(append([]Deprecation(nil), p.deprecations...), config.Deprecations()...),
+		Ties:        config.PriorityTies(),
+	}
+	if p.config.DNSCheck {
+		report.Unresolved = p.checkBackendDNS(config)
+	}
+	if p.config.SelfTest {
+		// Backend URLs of services that opted out of the self-test
+		skipSelfTest := make(map[string]bool)
+		for _, service := range services {
+			if service.Labels[selfTestLabel] == "false" {
+				skipSelfTest[service.URL] = true
+			}
+		}
+		report.Probes = p.selfTest(config, skipSelfTest)
+	}
+	p.reports.set(report)
+
+	// Send configuration to Traefik
+	p.logger.Info("Sending configuration to channel...")
+	configChan <- config
+	p.removals.accept(config)
+	p.logger.Info("Configuration sent successfully",
+		logging.GetCodeField(logging.CodeConfigSentSuccess),
+	)
+
+	return nil
+}
+
+// Discover lists the Traefik-enabled Cloud Run services in every configured
+// project and Anthos namespace. A project that can't be listed is logged, counted against its
+// error budget and left out, or represented by its last listed services
+// within StaleRouteGracePeriod; its error is returned (joined with any
+// others) together with the services of the projects that could be listed.
+func (p *Provider) Discover() ([]CloudRunService, error) {
+	services, _, err := p.discover()
+	return services, err
+}
+
+// discover implements Discover, also returning the projects whose stale
+// services were kept
+func (p *Provider) discover() ([]CloudRunService, []StaleProject, error) {
+	var discovered []CloudRunService
+	var stale []StaleProject
+	var errs []error
+
+	retain := func(projectID, reason string) {
+		if services, project, ok := p.retainStale(projectID, reason, time.Now()); ok {
+			discovered = append(discovered, services...)
+			stale = append(stale, *project)
+		}
+	}
 
-	// Discover services from all configured projects
 	for _, projectID := range p.config.ProjectIDs {
+		if !p.breaker.allow(projectID, time.Now()) {
+			p.logger.Debug("Skipping project (error budget exhausted, cooling down)",
+				logging.GetCodeField(logging.CodeBreakerSkipped),
+				logging.String("project", projectID),
+			)
+			retain(projectID, "error budget exhausted")
+			continue
+		}
+
 		p.logger.Info("Listing Cloud Run services in project",
 			logging.String("project", projectID),
 			logging.String("region", p.config.Region),
 		)
 
-		services, err := p.listServices(p.runService, projectID, p.config.Region)
+		services, err := p.discoverServices(projectID)
 		if err != nil {
 			p.logger.Error("Failed to list services in project",
 				logging.GetCodeField(logging.CodeServiceDiscoveryError),
 				logging.String("project", projectID),
 				logging.Error(err),
 			)
+			p.recordFailure(projectID, err)
+			errs = append(errs, err)
+			retain(projectID, err.Error())
 			continue
 		}
+		p.breaker.recordSuccess(projectID)
 
-		totalServices += len(services)
+		if len(services) == 0 {
+			p.logger.Warn("No Traefik-enabled services found in project",
+				logging.GetCodeField(logging.CodeServiceDiscoveryNoServices),
+				logging.String("project", projectID),
+			)
+			continue
+		}
 		p.logger.Info("Discovered services",
 			logging.GetCodeField(logging.CodeServiceDiscoverySuccess),
 			logging.String("project", projectID),
 			logging.Int("count", len(services)),
 		)
+		discovered = append(discovered, services...)
+	}
 
-		// Filter services with traefik_enable=true
-		traefikEnabledCount := 0
-		for _, service := range services {
-			// Check if service has traefik_enable=true label
-			if enabled, ok := service.Labels["traefik_enable"]; ok && enabled == labelValueTrue {
-				traefikEnabledCount++
-				p.logger.Info("Processing Traefik-enabled service",
-					logging.GetCodeField(logging.CodeServiceProcessingStarted),
-					logging.String("service", service.Name),
-					logging.String("project", projectID),
-				)
-				if err := p.processService(service, config); err != nil {
-					p.logger.Error("Failed to process service",
-						logging.GetCodeField(logging.CodeServiceProcessingError),
-						logging.String("service", service.Name),
-						logging.String("project", projectID),
-						logging.Error(err),
-					)
-					continue
-				}
-				p.logger.Info("Service processed successfully",
-					logging.GetCodeField(logging.CodeServiceProcessingSuccess),
-					logging.String("service", service.Name),
-				)
-
-				// Track home-index URL for user auth middleware
-				if strings.Contains(service.Name, "home-index") && service.URL != "" {
-					homeIndexURL = service.URL
-					p.logger.Info("Found home-index service for user auth",
-						logging.String("url", homeIndexURL),
-					)
+	anthosServices, anthosErrs := p.discoverAnthos()
+	discovered = append(discovered, anthosServices...)
+	errs = append(errs, anthosErrs...)
+
+	return discovered, stale, errors.Join(errs...)
+}
+
+// Build generates the Traefik configuration for a set of discovered services.
+// Services without traefik_enable=true or rejected by the service filters are
+// skipped, and a service that fails to process is logged and left out unless
+// its token failure policy is fail-generation, in which case Build returns
+// the error.
+//
+//nolint:gocyclo
+func (p *Provider) Build(services []CloudRunService) (*DynamicConfig, error) {
+	config := NewDynamicConfig()
+	config.envAffixes = p.config.EnvironmentSuffixes
+	config.conflictPolicy = p.config.RouterConflictPolicy
+	config.redactor = p.redactor
+
+	// Track home-index URL for user auth middleware generation
+	var homeIndexURL string
+
+	// Track which services were seen so stale fragments can be dropped
+	seenServices := make(map[string]bool)
+
+	// Blue/green controllers resolve their active backend among the
+	// discovered services
+	discovered := make(map[string]CloudRunService, len(services))
+	for _, service := range services {
+		discovered[fragmentKey(service)] = service
+	}
+	backends := blueGreenBackends(services)
+
+	// Count Traefik-enabled services per project, in discovery order
+	var projects []string
+	enabledCount := make(map[string]int)
+
+	// Projects whose list fingerprint is unchanged replay their snapshot;
+	// the others record a new one unless a service's outcome can't be replayed
+	versions := make(map[string]string)
+	if p.config.IncrementalUpdates {
+		versions = projectVersions(services)
+	}
+	replayed := make(map[string]bool)
+	recording := make(map[string]*projectSnapshot)
+
+	for _, service := range services {
+		// Discovery already normalizes; services passed in directly may not be
+		service.Labels = NormalizeLabels(service.Labels)
+		projectID := service.ProjectID
+		if _, ok := enabledCount[projectID]; !ok {
+			projects = append(projects, projectID)
+			enabledCount[projectID] = 0
+			if snapshot, ok := p.replayProject(projectID, versions[projectID], config, seenServices); ok {
+				replayed[projectID] = true
+				enabledCount[projectID] = snapshot.enabled
+				for _, step := range snapshot.steps {
+					if strings.Contains(step.name, "home-index") && step.url != "" {
+						homeIndexURL = step.url
+					}
 				}
+			} else if version := versions[projectID]; version != "" {
+				recording[projectID] = &projectSnapshot{version: version}
+			}
+		}
+		if replayed[projectID] {
+			continue
+		}
+
+		step, reusable, err := p.buildService(service, discovered, backends, config)
+		if err != nil {
+			return nil, err
+		}
+		if step.key != "" {
+			enabledCount[projectID]++
+			seenServices[step.key] = true
+		}
+		if snapshot := recording[projectID]; snapshot != nil {
+			if reusable {
+				snapshot.steps = append(snapshot.steps, step)
 			} else {
-				p.logger.Debug("Skipping service (traefik_enable != true)",
-					logging.GetCodeField(logging.CodeServiceSkipped),
-					logging.String("service", service.Name),
-				)
+				delete(recording, projectID)
 			}
 		}
 
-		if traefikEnabledCount == 0 {
+		// Track home-index URL for user auth middleware
+		if strings.Contains(service.Name, "home-index") && step.url != "" {
+			homeIndexURL = step.url
+			p.logger.Info("Found home-index service for user auth",
+				logging.String("url", homeIndexURL),
+			)
+		}
+	}
+
+	if config.conflictErr != nil {
+		return nil, fmt.Errorf("aborting config generation (router conflict policy %s): %w", ConflictError, config.conflictErr)
+	}
+
+	for _, projectID := range projects {
+		if enabledCount[projectID] == 0 {
 			p.logger.Warn("No Traefik-enabled services found in project",
 				logging.GetCodeField(logging.CodeServiceDiscoveryNoServices),
 				logging.String("project", projectID),
-				logging.Int("totalServices", len(services)),
-			)
-		} else {
-			p.logger.Info("Processed Traefik-enabled services",
-				logging.GetCodeField(logging.CodeServiceDiscoverySuccess),
-				logging.String("project", projectID),
-				logging.Int("enabledCount", traefikEnabledCount),
-				logging.Int("totalServices", len(services)),
 			)
+			continue
+		}
+		p.logger.Info("Processed Traefik-enabled services",
+			logging.GetCodeField(logging.CodeServiceDiscoverySuccess),
+			logging.String("project", projectID),
+			logging.Int("enabledCount", enabledCount[projectID]),
+		)
+	}
+
+	seenProjects := make(map[string]bool, len(projects))
+	for _, projectID := range projects {
+		seenProjects[projectID] = true
+		if snapshot := recording[projectID]; snapshot != nil {
+			snapshot.enabled = enabledCount[projectID]
+			p.fragments.storeProject(projectID, snapshot)
+		} else if !replayed[projectID] {
+			p.fragments.dropProject(projectID)
 		}
 	}
+	p.fragments.retain(seenServices, seenProjects)
+	p.urls.retain(seenServices)
 
 	// Fallback: use HOME_INDEX_URL env when discovery didn't find home-index
 	// (e.g. home-index in labs-home-* project, provider SA lacks run.viewer, or service not yet deployed)
 	if homeIndexURL == "" {
-		homeIndexURL = strings.TrimSpace(os.Getenv("HOME_INDEX_URL"))
+		homeIndexURL = p.config.HomeIndexURL
 		if homeIndexURL != "" {
 			p.logger.Info("Using HOME_INDEX_URL from env (discovery did not find home-index)",
 				logging.String("homeIndexURL", homeIndexURL),
@@ -278,21 +1023,31 @@ func (p *Provider) updateConfig(configChan chan<- *DynamicConfig) error {
 	if homeIndexURL != "" {
 		if _, hasService := config.HTTP.Services["home-index"]; !hasService {
 			p.logger.Info("Adding home-index service and routers from HOME_INDEX_URL (not discovered from Cloud Run)")
-			serviceToken, err := p.tokenManager.GetToken(homeIndexURL)
-			if err != nil {
-				p.logger.Warn("Failed to get token for HOME_INDEX_URL fallback",
-					logging.String("homeIndexURL", homeIndexURL),
-					logging.Error(err),
-				)
-			}
-			if serviceToken != "" {
-				config.AddAuthMiddleware("home-index-auth", serviceToken)
+			hasAuth := false
+			if p.config.TokenInjection == TokenInjectionPlugin {
+				config.AddTokenPluginMiddleware("home-index-auth", p.config.TokenPluginName, homeIndexURL)
+				hasAuth = true
+			} else {
+				serviceToken, err := p.tokenManager.GetToken(homeIndexURL)
+				if err != nil {
+					p.logger.Warn("Failed to get token for HOME_INDEX_URL fallback",
+						logging.String("homeIndexURL", homeIndexURL),
+						logging.Error(err),
+					)
+				}
+				if serviceToken != "" {
+					config.AddAuthMiddleware("home-index-auth", serviceToken)
+					hasAuth = true
+				}
 			}
 			routerMiddlewares := []string{"forwarded-headers@file"}
-			if serviceToken != "" {
+			if hasAuth {
 				routerMiddlewares = append([]string{"home-index-auth"}, routerMiddlewares...)
 			}
-			routerMiddlewares = append(routerMiddlewares, "retry-cold-start@file")
+			routerMiddlewares = p.tagRoute(config, "home-index", routerMiddlewares)
+			routerMiddlewares = appendMissing(routerMiddlewares, p.config.DefaultMiddlewares...)
+			signinMiddlewares := p.tagRoute(config, "home-index-signin", []string{"signin-headers@file", "forwarded-headers@file"})
+			signinMiddlewares = appendMissing(signinMiddlewares, p.config.DefaultMiddlewares...)
 			config.AddService("home-index", ServiceConfig{
 				LoadBalancer: LoadBalancerConfig{
 					Servers:        []ServerConfig{{URL: homeIndexURL}},
@@ -311,7 +1066,7 @@ func (p *Provider) updateConfig(configChan chan<- *DynamicConfig) error {
 				Service:     "home-index",
 				Priority:    100,
 				EntryPoints: []string{"web"},
-				Middlewares: []string{"signin-headers@file", "forwarded-headers@file", "retry-cold-start@file"},
+				Middlewares: signinMiddlewares,
 			})
 		}
 	}
@@ -323,70 +1078,426 @@ func (p *Provider) updateConfig(configChan chan<- *DynamicConfig) error {
 
 	// Generate user auth middlewares if USER_AUTH_ENABLED is true
 	// These forwardAuth middlewares call home-index /api/auth/check for JWT validation
-	userAuthEnabled := os.Getenv("USER_AUTH_ENABLED") == labelValueTrue
+	userAuthEnabled := *p.config.UserAuthEnabled
 	if userAuthEnabled && homeIndexURL != "" {
 		p.logger.Info("USER_AUTH_ENABLED=true, generating forwardAuth middlewares",
 			logging.String("homeIndexURL", homeIndexURL),
 		)
-		// Generate lab auth-check middlewares that point to the Cloud Run home-index URL
-		config.AddForwardAuthMiddleware("lab1-auth-check", homeIndexURL)
-		config.AddForwardAuthMiddleware("lab2-auth-check", homeIndexURL)
-		config.AddForwardAuthMiddleware("lab3-auth-check", homeIndexURL)
-		config.AddForwardAuthMiddleware("lab4-auth-check", homeIndexURL)
+		// Generate the configured auth-check middlewares (lab1-4 by default)
+		for _, name := range p.config.UserAuth.MiddlewareNames {
+			config.AddUserAuthMiddleware(name, homeIndexURL, p.config.UserAuth)
+		}
 	} else if userAuthEnabled && homeIndexURL == "" {
 		p.logger.Warn("USER_AUTH_ENABLED=true but home-index URL not found - user auth middlewares not generated")
 	} else {
 		p.logger.Info("USER_AUTH_ENABLED not set or false - skipping user auth middlewares")
 	}
 
-	// Add Traefik API/Dashboard routers
-	p.logger.Debug("Adding Traefik internal routers (API/Dashboard)...")
-	config.AddTraefikInternalRouters()
+	// Request IDs go first so forwardAuth checks and backends see the same ID
+	if p.config.RequestIDEnabled {
+		config.AddRequestIDMiddleware(RequestIDMiddlewareName, p.config.TokenPluginName, p.config.RequestIDHeader)
+		for name, router := range config.HTTP.Routers {
+			router.Middlewares = append([]string{RequestIDMiddlewareName}, router.Middlewares...)
+			config.HTTP.Routers[name] = router
+		}
+	}
 
-	duration := time.Since(startTime)
-	p.logger.Info("Configuration generation complete",
-		logging.GetCodeField(logging.CodeConfigGenerationSuccess),
-		logging.Int("totalServices", totalServices),
-		logging.Int("routers", len(config.HTTP.Routers)),
-		logging.Int("services", len(config.HTTP.Services)),
-		logging.Int("middlewares", len(config.HTTP.Middlewares)),
-		logging.Duration("duration", duration),
-	)
+	if p.config.Dashboard.Enabled {
+		p.logger.Debug("Adding Traefik internal routers (API/Dashboard)...")
+		config.AddDashboardRouters(p.config.Dashboard)
+	}
 
-	// Send configuration to Traefik
-	p.logger.Info("Sending configuration to channel...")
-	configChan <- config
-	p.logger.Info("Configuration sent successfully",
-		logging.GetCodeField(logging.CodeConfigSentSuccess),
-	)
+	config.sortSkipped(services)
+	config.ties = p.checkPriorityTies(config)
+	p.checkErrorPages(config)
+	config.useTraefikVersion(p.config.TraefikVersion)
 
-	return nil
-}
+	if p.config.NamePrefix != "" {
+		config = config.WithNamePrefix(p.config.NamePrefix)
+	}
 
-// processService processes a single Cloud Run service and adds it to the configuration
-//
-//nolint:gocyclo
-func (p *Provider) processService(service CloudRunService, config *DynamicConfig) error {
-	p.logger.Info("Processing service",
-		logging.GetCodeField(logging.CodeServiceProcessingStarted),
-		logging.String("name", service.Name),
-		logging.String("project", service.ProjectID),
-		logging.String("url", service.URL),
-	)
+	return config, nil
+}
 
-	// Extract router configs from labels
-	p.logger.Debug("Extracting router configurations from labels...")
-	routerConfigs := extractRouterConfigs(service.Labels, service.Name)
-	if len(routerConfigs) == 0 {
-		p.logger.Warn("No router labels found for service",
-			logging.GetCodeField(logging.CodeServiceProcessingError),
-			logging.String("service", service.Name),
+// recordFailure counts a failure against a project's or service's error budget
+// and logs when the budget is exhausted
+func (p *Provider) recordFailure(key string, err error) {
+	if p.breaker.recordFailure(key, err, time.Now()) {
+		p.logger.Warn("Error budget exhausted, skipping until cool-down ends",
+			logging.GetCodeField(logging.CodeBreakerOpened),
+			logging.String("key", key),
+			logging.Int("threshold", p.breaker.threshold),
+			logging.Duration("cooldown", p.breaker.cooldown),
 		)
-		return fmt.Errorf("no router labels found")
 	}
+}
 
-	p.logger.Info("Extracted router configurations",
-		logging.String("service", service.Name),
+// LastReport returns the report from the most recent successful generation,
+// or nil if none has completed yet
+func (p *Provider) LastReport() *GenerationReport {
+	return p.reports.get()
+}
+
+// BreakerStatus returns the error budget state of every project and service
+// that has failed since its last success, for metrics and reporting
+func (p *Provider) BreakerStatus() []BreakerStatus {
+	return p.breaker.snapshot()
+}
+
+// APIStats returns the latency of the Cloud Run API List calls made for
+// each project, for diagnostics
+func (p *Provider) APIStats() []APICallStats {
+	return p.apiStats.snapshot()
+}
+
+// SetLogLevel changes the provider's log level (DEBUG, INFO, WARN or ERROR)
+// while it runs
+func (p *Provider) SetLogLevel(level string) error {
+	parsed, err := logging.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	p.logger.SetLevel(parsed)
+	return nil
+}
+
+// LogLevel returns the provider's current log level
+func (p *Provider) LogLevel() string {
+	return p.logger.Level().String()
+}
+
+// Logger returns a log/slog logger writing through the provider's logger,
+// for embedders logging alongside it in the same level, format and
+// redaction (logr users can wrap its handler with logr.FromSlogHandler)
+func (p *Provider) Logger() *slog.Logger {
+	return slog.New(p.logger.Handler())
+}
+
+// TokenStats returns the identity token cache and fetch counters, or false
+// if the token source doesn't keep them (only *gcp.TokenManager does)
+func (p *Provider) TokenStats() (gcp.TokenStats, bool) {
+	source, ok := p.tokenManager.(interface{ Stats() gcp.TokenStats })
+	if !ok {
+		return gcp.TokenStats{}, false
+	}
+	return source.Stats(), true
+}
+
+// InvalidateTokens drops cached identity tokens so the next generation mints
+// fresh ones and rewrites the auth middlewares, e.g. when a token may have
+// been exposed. With no audiences every cached token is dropped. Cached
+// service fragments carry the old tokens, so they are dropped too. Tokens
+// held by the token middleware plugin (TOKEN_INJECTION=plugin) live in
+// Traefik and are not affected.
+func (p *Provider) InvalidateTokens(audiences ...string) {
+	p.fragments.clear()
+
+	if len(audiences) == 0 {
+		if source, ok := p.tokenManager.(interface{ ClearCache() }); ok {
+			source.ClearCache()
+		}
+		p.logger.Warn("Invalidated all cached identity tokens",
+			logging.GetCodeField(logging.CodeTokensInvalidated),
+		)
+		return
+	}
+
+	source, ok := p.tokenManager.(interface{ Invalidate(audience string) bool })
+	if !ok {
+		return
+	}
+	for _, audience := range audiences {
+		p.logger.Warn("Invalidated cached identity token",
+			logging.GetCodeField(logging.CodeTokensInvalidated),
+			logging.String("audience", audience),
+			logging.Any("cached", source.Invalidate(audience)),
+		)
+	}
+}
+
+// tagRoute adds the route tag middleware for routerName to config and
+// appends it to middlewares when RouteTagging is enabled
+func (p *Provider) tagRoute(config *DynamicConfig, routerName string, middlewares []string) []string {
+	if !p.config.RouteTagging {
+		return middlewares
+	}
+	name := routerName + "-route-tag"
+	config.AddRouteTagMiddleware(name, p.config.RouteTagHeader, routerName)
+	return appendMissing(middlewares, name)
+}
+
+// buildService runs a Traefik-enabled service through the filters and gates
+// and adds its configuration. The returned step records the outcome for the
+// project's snapshot, with a key only if the service is Traefik-enabled;
+// reusable is false when the outcome must not be replayed (a failure, an
+// exhausted error budget or a degraded service). Returns an error only when
+// the token failure policy aborts generation.
+func (p *Provider) buildService(service CloudRunService, discovered map[string]CloudRunService, backends map[string]bool, config *DynamicConfig) (projectStep, bool, error) {
+	step := projectStep{name: service.Name}
+	mark := len(config.skipped)
+	// outcome records the services skipped so far, and whether replaying
+	// them is safe
+	outcome := func() (projectStep, bool, error) {
+		step.skipped = append([]SkippedService(nil), config.skipped[mark:]...)
+		return step, !degradedSkips(step.skipped), nil
+	}
+
+	if allowed, reason := p.serviceAllowed(service.Name); !allowed {
+		p.logger.Debug("Skipping service (filtered)",
+			logging.GetCodeField(logging.CodeServiceSkipped),
+			logging.String("service", service.Name),
+			logging.String("reason", reason),
+		)
+		if service.Labels["traefik_enable"] == labelValueTrue {
+			config.skip(SkippedService{Service: service.Name, Project: service.ProjectID, Reason: SkipReasonFiltered, Detail: reason})
+		}
+		return outcome()
+	}
+	// Check if service has traefik_enable=true label
+	if enabled, ok := service.Labels["traefik_enable"]; !ok || enabled != labelValueTrue {
+		p.logger.Debug("Skipping service (traefik_enable != true)",
+			logging.GetCodeField(logging.CodeServiceSkipped),
+			logging.String("service", service.Name),
+		)
+		return outcome()
+	}
+
+	p.logger.Info("Processing Traefik-enabled service",
+		logging.GetCodeField(logging.CodeServiceProcessingStarted),
+		logging.String("service", service.Name),
+		logging.String("project", service.ProjectID),
+	)
+	serviceKey := fragmentKey(service)
+	step.key = serviceKey
+	if !p.breaker.allow(serviceKey, time.Now()) {
+		p.logger.Debug("Skipping service (error budget exhausted, cooling down)",
+			logging.GetCodeField(logging.CodeBreakerSkipped),
+			logging.String("service", service.Name),
+			logging.String("project", service.ProjectID),
+		)
+		config.skip(SkippedService{Service: service.Name, Project: service.ProjectID, Reason: SkipReasonErrorBudget})
+		return step, false, nil
+	}
+	if backends[serviceKey] && !hasRouterLabels(service.Labels) {
+		p.logger.Debug("Skipping service (blue/green backend, routed through its controller)",
+			logging.String("service", service.Name),
+			logging.String("project", service.ProjectID),
+		)
+		return outcome()
+	}
+	var routed bool
+	if service, routed = p.gateBackend(service, discovered, config); !routed {
+		return outcome()
+	}
+	if !p.gateReadiness(service, config) || !p.gateZeroTraffic(service, config) {
+		return outcome()
+	}
+	if service, routed = p.gateURL(service, config); !routed {
+		return outcome()
+	}
+	// The fragment's own skipped services are replayed with the fragment
+	step.skipped = append([]SkippedService(nil), config.skipped[mark:]...)
+	if err := p.processServiceIncremental(service, config); err != nil {
+		p.logger.Error("Failed to process service",
+			logging.GetCodeField(logging.CodeServiceProcessingError),
+			logging.String("service", service.Name),
+			logging.String("project", service.ProjectID),
+			logging.Error(err),
+		)
+		// Not counted against the error budget: an open breaker would
+		// skip the service and publish without it, which the policy forbids
+		var tokenErr *TokenError
+		if errors.As(err, &tokenErr) && p.tokenFailurePolicy(service) == TokenFailureFailGeneration {
+			return step, false, fmt.Errorf("aborting config generation (token failure policy %s): %w", TokenFailureFailGeneration, err)
+		}
+		p.recordFailure(serviceKey, err)
+		config.skip(SkippedService{Service: service.Name, Project: service.ProjectID, Reason: skipReason(err), Detail: err.Error()})
+		return step, false, nil
+	}
+	p.breaker.recordSuccess(serviceKey)
+	p.deprioritizeZeroTraffic(service, config)
+	p.logger.Info("Service processed successfully",
+		logging.GetCodeField(logging.CodeServiceProcessingSuccess),
+		logging.String("service", service.Name),
+	)
+	step.url = service.URL
+	step.fingerprint = serviceFingerprint(service)
+	return step, !degradedSkips(config.skipped[mark:]), nil
+}
+
+// replayProject adds the configuration of a project whose list fingerprint
+// is unchanged since its snapshot was recorded, without running its services
+// through the gates again
+func (p *Provider) replayProject(projectID, version string, config *DynamicConfig, seen map[string]bool) (*projectSnapshot, bool) {
+	if version == "" {
+		return nil, false
+	}
+	snapshot, fragments, ok := p.fragments.replay(projectID, version, time.Now())
+	if !ok {
+		return nil, false
+	}
+	p.logger.Debug("Project services unchanged, reusing cached configuration",
+		logging.String("project", projectID),
+		logging.Int("enabledCount", snapshot.enabled),
+	)
+	for i, step := range snapshot.steps {
+		if step.key != "" {
+			seen[step.key] = true
+		}
+		config.skipped = append(config.skipped, step.skipped...)
+		if fragments[i] != nil {
+			config.merge(fragments[i], step.name)
+		}
+	}
+	return snapshot, true
+}
+
+// processServiceIncremental adds a service to the configuration, reusing the
+// fragment generated on a previous poll when the service's fingerprint
+// (labels + URL + revision) is unchanged. This skips token fetch and router
+// generation for stable services when IncrementalUpdates is enabled.
+func (p *Provider) processServiceIncremental(service CloudRunService, config *DynamicConfig) error {
+	if !p.config.IncrementalUpdates {
+		return p.processService(service, config)
+	}
+
+	key := fragmentKey(service)
+	fingerprint := serviceFingerprint(service)
+	now := time.Now()
+
+	if fragment, ok := p.fragments.lookup(key, fingerprint, now); ok {
+		p.logger.Debug("Service unchanged, reusing cached configuration",
+			logging.String("service", service.Name),
+			logging.String("project", service.ProjectID),
+		)
+		config.merge(fragment, service.Name)
+		return nil
+	}
+
+	fragment := NewDynamicConfig()
+	fragment.redactor = config.redactor
+	if err := p.processService(service, fragment); err != nil {
+		return err
+	}
+	// A degraded fragment, e.g. routed without auth after a token failure,
+	// is regenerated on the next poll instead of outliving the failure
+	if !fragment.degraded() {
+		p.fragments.store(key, fingerprint, fragment, now)
+	}
+	config.merge(fragment, service.Name)
+	return nil
+}
+
+// processService processes a single Cloud Run service and adds it to the configuration
+//
+//nolint:gocyclo
+func (p *Provider) processService(service CloudRunService, config *DynamicConfig) error {
+	p.logger.Info("Processing service",
+		logging.GetCodeField(logging.CodeServiceProcessingStarted),
+		logging.String("name", service.Name),
+		logging.String("project", service.ProjectID),
+		logging.String("url", service.URL),
+	)
+
+	// Expand templates in label values, e.g. Host(`{{ .Service }}.{{ env "DOMAIN" }}`)
+	labels, err := expandLabelTemplates(service)
+	if err != nil {
+		return err
+	}
+	service.Labels = labels
+
+	if p.config.LabelValidation != LabelValidationIgnore {
+		if problems := unknownLabels(service.Labels); len(problems) > 0 {
+			if p.config.LabelValidation == LabelValidationStrict {
+				return fmt.Errorf("%w: %s", ErrUnknownLabels, strings.Join(problems, "; "))
+			}
+			p.logger.Warn("Ignoring unrecognized traefik labels",
+				logging.String("service", service.Name),
+				logging.Any("labels", problems),
+			)
+		}
+	}
+
+	if deprecations := deprecatedLabels(service.Name, service.Labels); len(deprecations) > 0 {
+		warnDeprecated(p.logger, deprecations)
+		config.deprecations = append(config.deprecations, deprecations...)
+	}
+
+	// Extract router configs from labels
+	p.logger.Debug("Extracting router configurations from labels...")
+	routerConfigs := extractRouterConfigs(service.Labels, service.Name)
+	if len(routerConfigs) == 0 {
+		p.logger.Warn("No router labels found for service",
+			logging.GetCodeField(logging.CodeServiceProcessingError),
+			logging.String("service", service.Name),
+		)
+		return ErrNoRouterLabels
+	}
+
+	// Drop catch-all routers unless the service may define them
+	if dropped := p.dropCatchAllRouters(service, routerConfigs); len(dropped) > 0 {
+		p.logger.Error("Dropping catch-all routers",
+			logging.GetCodeField(logging.CodeCatchAllRule),
+			logging.String("service", service.Name),
+			logging.Strings("routers", dropped),
+		)
+		if len(routerConfigs) == 0 {
+			return fmt.Errorf("%w: %s", ErrCatchAllRule, strings.Join(dropped, "; "))
+		}
+		for _, detail := range dropped {
+			config.skip(SkippedService{Service: service.Name, Project: service.ProjectID, Reason: SkipReasonCatchAll, Detail: detail, Degraded: true})
+		}
+	}
+
+	// Drop routers whose rule Traefik would reject, since one bad rule makes
+	// Traefik discard the whole dynamic configuration, and write the others
+	// in the syntax of the targeted Traefik version
+	var ruleErrs []error
+	for routerName, routerConfig := range routerConfigs {
+		rule, err := ruleForVersion(routerConfig.Rule, p.config.TraefikVersion)
+		if err != nil {
+			p.logger.Error("Dropping router with invalid rule",
+				logging.GetCodeField(logging.CodeServiceProcessingError),
+				logging.String("service", service.Name),
+				logging.String("router", routerName),
+				logging.String("rule", routerConfig.Rule),
+				logging.Error(err),
+			)
+			delete(routerConfigs, routerName)
+			ruleErrs = append(ruleErrs, fmt.Errorf("router %s: %w", routerName, err))
+			continue
+		}
+		routerConfig.Rule = rule
+		routerConfigs[routerName] = routerConfig
+	}
+	if len(routerConfigs) == 0 {
+		return fmt.Errorf("%w: %w", ErrInvalidRule, errors.Join(ruleErrs...))
+	}
+	for _, err := range ruleErrs {
+		config.skip(SkippedService{Service: service.Name, Project: service.ProjectID, Reason: SkipReasonInvalidRule, Detail: err.Error(), Degraded: true})
+	}
+
+	// Drop routers whose rule a rule policy rejects
+	if dropped := p.dropDeniedRouters(service, routerConfigs); len(dropped) > 0 {
+		p.logger.Error("Dropping routers rejected by rule policies",
+			logging.GetCodeField(logging.CodeRulePolicy),
+			logging.String("service", service.Name),
+			logging.String("project", service.ProjectID),
+			logging.Strings("routers", dropped),
+		)
+		if len(routerConfigs) == 0 {
+			return fmt.Errorf("%w: %s", ErrRulePolicy, strings.Join(dropped, "; "))
+		}
+		for _, detail := range dropped {
+			config.skip(SkippedService{Service: service.Name, Project: service.ProjectID, Reason: SkipReasonRulePolicy, Detail: detail, Degraded: true})
+		}
+	}
+	if p.config.AutoEntryPoints {
+		p.assignEntryPoints(routerConfigs, service.Labels)
+	}
+
+	p.logger.Info("Extracted router configurations",
+		logging.String("service", service.Name),
 		logging.Int("routerCount", len(routerConfigs)),
 	)
 
@@ -409,79 +1520,58 @@ func (p *Provider) processService(service CloudRunService, config *DynamicConfig
 			routerConfigs[routerName] = routerConfig
 		}
 	}
-
-	// Get identity token for service
-	// This token will be used in Authorization header for Cloud Run service-to-service auth
-	p.logger.Debug("Fetching identity token for service",
-		logging.String("service", service.Name),
-		logging.String("url", service.URL),
-	)
-
-	serviceToken, err := p.tokenManager.GetToken(service.URL)
-	if err != nil {
-		p.logger.Error("Failed to fetch identity token for service",
-			logging.GetCodeField(logging.CodeTokenFetchError),
+	// Create auth middleware (only if token is available)
+	authMiddlewareName := p.authMiddlewareName(service, serviceNameFromLabel)
+	authMiddlewareCreated := false
+	if service.Platform == PlatformGKE {
+		// Cloud Run for Anthos doesn't check identity tokens
+		p.logger.Debug("Anthos service, no auth middleware",
 			logging.String("service", service.Name),
-			logging.String("url", service.URL),
-			logging.Error(err),
 		)
-		// Log detailed error for debugging
-		if strings.Contains(err.Error(), "metadata server") {
-			p.logger.Error("Metadata server issue - check if running in Cloud Run or set CLOUDRUN_PROVIDER_DEV_MODE=true",
-				logging.String("service", service.Name),
-			)
-		}
-		if strings.Contains(err.Error(), "ADC") {
-			p.logger.Error("ADC issue - run 'gcloud auth application-default login' for local development",
-				logging.String("service", service.Name),
-			)
-		}
-		// Continue without token - service will return 401
-		serviceToken = ""
-	} else {
-		// Validate token format
-		if !strings.HasPrefix(serviceToken, "eyJ") {
-			previewLen := 20
-			if len(serviceToken) < previewLen {
-				previewLen = len(serviceToken)
-			}
-			p.logger.Error("Token doesn't look valid (should start with eyJ for JWT)",
-				logging.GetCodeField(logging.CodeTokenInvalid),
-				logging.String("service", service.Name),
-				logging.String("tokenPreview", serviceToken[:previewLen]),
-				logging.Int("tokenLength", len(serviceToken)),
-			)
-			serviceToken = ""
+	} else if _, hasAuthProvider := service.Labels[authProviderLabel]; p.config.TokenInjection == TokenInjectionPlugin && !hasAuthProvider && !p.accessTokenRefused(service) {
+		// The token middleware plugin fetches a fresh token per request,
+		// so no token is baked into the generated config. Refused access
+		// tokens fail below, like a token fetch error.
+		if p.authType(service) == AuthTypeAccessToken {
+			config.AddAccessTokenPluginMiddleware(authMiddlewareName, p.config.TokenPluginName, p.config.AccessTokenScopes)
+			authMiddlewareCreated = true
 		} else {
-			p.logger.Info("Successfully fetched identity token for service",
-				logging.GetCodeField(logging.CodeTokenFetchSuccess),
-				logging.String("service", service.Name),
-				logging.String("url", service.URL),
-				logging.Int("tokenLength", len(serviceToken)),
-			)
+			config.AddTokenPluginMiddleware(authMiddlewareName, p.config.TokenPluginName, service.URL)
+			authMiddlewareCreated = service.URL != ""
 		}
-	}
-
-	// Create auth middleware (only if token is available)
-	authMiddlewareName := fmt.Sprintf("%s-auth", serviceNameFromLabel)
-	authMiddlewareCreated := false
-	if serviceToken != "" {
-		config.AddAuthMiddleware(authMiddlewareName, serviceToken)
+	} else if header, value, err := p.authHeader(service); err == nil {
+		config.AddHeaderAuthMiddleware(authMiddlewareName, header, value)
 		authMiddlewareCreated = true
+	} else if p.tokenFailurePolicy(service) != TokenFailureEmitWithoutAuth {
+		// Nothing has been added to config yet, so returning leaves the service out
+		return err
 	} else {
 		// Skip creating middleware if no token (avoids empty headers: {} in YAML)
-		p.logger.Debug("Skipping auth middleware creation (no token)",
+		p.logger.Warn("Routing service without auth middleware (no token), requests will get 401",
+			logging.String("service", service.Name),
 			logging.String("middleware", authMiddlewareName),
 		)
+		config.skip(SkippedService{Service: service.Name, Project: service.ProjectID, Reason: SkipReasonNoAuth, Detail: err.Error(), Degraded: true})
+	}
+
+	// Point the routers of a service in maintenance mode at the maintenance
+	// target, now that the service is known to be routed
+	if p.inMaintenance(service) {
+		p.enterMaintenance(service, serviceNameFromLabel, routerConfigs, config)
 	}
 
 	// Add routers (with auth middleware and retry middleware)
 	// USER_AUTH_ENABLED controls whether user JWT auth is required for labs
 	// - When false (default): Skip auth-check middlewares (no user auth required)
 	// - When true: Include auth-check middlewares (user must be authenticated)
-	// Note: SKIP_AUTH_CHECK is deprecated, use USER_AUTH_ENABLED=false instead
-	userAuthEnabled := os.Getenv("USER_AUTH_ENABLED") == labelValueTrue
-	skipAuthCheck := os.Getenv("SKIP_AUTH_CHECK") == "true" || !userAuthEnabled
+	// SKIP_AUTH_CHECK is migrated to USER_AUTH_ENABLED=false (see migrateDeprecatedSettings)
+	skipAuthCheck := !*p.config.UserAuthEnabled
+	headersConfigs := extractHeadersConfigs(service.Labels, serviceNameFromLabel)
+	_, hasCORSShorthand := headersConfigs[corsMiddlewareName(serviceNameFromLabel)]
+	rewriteConfigs := extractReplacePathRegexConfigs(service.Labels, serviceNameFromLabel)
+	_, hasRewriteShorthand := rewriteConfigs[rewriteMiddlewareName(serviceNameFromLabel)]
+	errorsConfigs := extractErrorsConfigs(service.Labels, serviceNameFromLabel)
+	_, hasErrorsShorthand := errorsConfigs[errorsMiddlewareName(serviceNameFromLabel)]
 
 	for routerName, routerConfig := range routerConfigs {
 		// Filter out auth-check middlewares if user auth is disabled
@@ -489,7 +1579,7 @@ func (p *Provider) processService(service CloudRunService, config *DynamicConfig
 		if skipAuthCheck {
 			filteredMiddlewares := make([]string, 0, len(routerConfig.Middlewares))
 			for _, mw := range routerConfig.Middlewares {
-				if !strings.Contains(mw, "auth-check") {
+				if !p.config.UserAuth.isUserAuthMiddleware(mw) {
 					filteredMiddlewares = append(filteredMiddlewares, mw)
 				} else {
 					p.logger.Debug("Skipping auth-check middleware (USER_AUTH_ENABLED=false)",
@@ -500,11 +1590,16 @@ func (p *Provider) processService(service CloudRunService, config *DynamicConfig
 			routerConfig.Middlewares = filteredMiddlewares
 		}
 
+		// The rewrite shorthand rewrites the path itself, in place of strip-prefix
+		if hasRewriteShorthand {
+			routerConfig.Middlewares = appendMissing(routerConfig.Middlewares, rewriteMiddlewareName(serviceNameFromLabel))
+		}
+
 		// Auto-inject strip-prefix middleware for lab routes if not already present
 		// This ensures /lab1 requests get their prefix stripped before reaching the backend
 		// Lab services expect requests at / (root), not /lab1
 		stripPrefixMiddleware := getStripPrefixMiddleware(routerName, routerConfig.Rule)
-		if stripPrefixMiddleware != "" {
+		if stripPrefixMiddleware != "" && !hasRewriteShorthand {
 			hasStripPrefix := false
 			for _, mw := range routerConfig.Middlewares {
 				if strings.Contains(mw, "strip-") && strings.Contains(mw, "-prefix") {
@@ -526,7 +1621,12 @@ func (p *Provider) processService(service CloudRunService, config *DynamicConfig
 		// X-Serverless-Authorization (doesn't conflict with user's Authorization header)
 		if authMiddlewareCreated {
 			hasServiceAuth := false
-			for _, mw := range routerConfig.Middlewares {
+			for i, mw := range routerConfig.Middlewares {
+				if p.config.ShareAuthMiddlewares && mw == serviceNameFromLabel+"-auth" {
+					// Labels written for per-service middlewares point at the shared one
+					routerConfig.Middlewares[i] = authMiddlewareName
+					mw = authMiddlewareName
+				}
 				if mw == authMiddlewareName || mw == fmt.Sprintf("%s@file", authMiddlewareName) {
 					hasServiceAuth = true
 					break
@@ -540,22 +1640,40 @@ func (p *Provider) processService(service CloudRunService, config *DynamicConfig
 			}
 		}
 
-		// Always add retry middleware for cold starts (at the end)
-		hasRetry := false
-		for _, mw := range routerConfig.Middlewares {
-			if mw == "retry-cold-start@file" {
-				hasRetry = true
-				break
+		// Tag requests with the router name unless the service opts out
+		if service.Labels[routeTagLabel] != "false" {
+			routerConfig.Middlewares = p.tagRoute(config, routerName, routerConfig.Middlewares)
+		}
+
+		// Error pages wrap the rest of the chain (unless the labels place them),
+		// so they also replace errors returned by auth or the backend
+		if hasErrorsShorthand {
+			name := errorsMiddlewareName(serviceNameFromLabel)
+			if !containsString(routerConfig.Middlewares, name) {
+				routerConfig.Middlewares = append([]string{name}, routerConfig.Middlewares...)
 			}
 		}
-		if !hasRetry {
-			routerConfig.Middlewares = append(routerConfig.Middlewares, "retry-cold-start@file")
+
+		// CORS goes first (unless the labels place it) so preflight requests
+		// are answered before auth runs
+		if hasCORSShorthand {
+			name := corsMiddlewareName(serviceNameFromLabel)
+			hasCORS := false
+			for _, mw := range routerConfig.Middlewares {
+				if mw == name {
+					hasCORS = true
+					break
+				}
+			}
+			if !hasCORS {
+				routerConfig.Middlewares = append([]string{name}, routerConfig.Middlewares...)
+			}
 		}
 
-		// Log router configuration with middlewares (user-friendly format)
-		middlewareList := strings.Join(routerConfig.Middlewares, ", ")
-		if middlewareList == "" {
-			middlewareList = "none"
+		// Always add default middlewares, e.g. retry for cold starts (at the end)
+		routerConfig.Middlewares = appendMissing(routerConfig.Middlewares, p.config.DefaultMiddlewares...)
+		if p.coldStart(service) {
+			routerConfig.Middlewares = appendMissing(routerConfig.Middlewares, p.config.ColdStartMiddlewares...)
 		}
 
 		// Check if service auth middleware is present for better debugging
@@ -572,9 +1690,9 @@ func (p *Provider) processService(service CloudRunService, config *DynamicConfig
 			logging.String("router", routerName),
 			logging.String("rule", routerConfig.Rule),
 			logging.String("service", routerConfig.Service),
-			logging.String("middlewares", fmt.Sprintf("[%s]", middlewareList)),
+			logging.Strings("middlewares", routerConfig.Middlewares),
 			logging.String("expectedAuthMiddleware", authMiddlewareName),
-			logging.String("hasAuthMiddleware", fmt.Sprintf("%v", hasAuthMw)),
+			logging.Bool("hasAuthMiddleware", hasAuthMw),
 		)
 
 		// Use AddRouterWithSource to handle conflicts when multiple services define the same router
@@ -582,14 +1700,97 @@ func (p *Provider) processService(service CloudRunService, config *DynamicConfig
 		config.AddRouterWithSource(routerName, routerConfig, service.Name)
 	}
 
-	// Add service definition
-	serviceConfig := ServiceConfig{
-		LoadBalancer: LoadBalancerConfig{
-			Servers:        []ServerConfig{{URL: service.URL}},
-			PassHostHeader: false,
-		},
+	// Add forwardAuth middlewares defined by the service's own labels
+	for name, forwardAuth := range extractForwardAuthConfigs(service.Labels, serviceNameFromLabel) {
+		fa := forwardAuth
+		config.AddMiddleware(name, MiddlewareConfig{ForwardAuth: &fa})
+		p.logger.Info("Created forwardAuth middleware from labels",
+			logging.String("service", service.Name),
+			logging.String("middleware", name),
+			logging.String("address", fa.Address),
+		)
+	}
+
+	// Add ipAllowList middlewares defined by the service's labels
+	for name, allowList := range extractIPAllowListConfigs(service.Labels) {
+		al := allowList
+		config.AddMiddleware(name, MiddlewareConfig{IPAllowList: &al})
+		p.logger.Info("Created ipAllowList middleware from labels",
+			logging.String("service", service.Name),
+			logging.String("middleware", name),
+			logging.Strings("sourceRange", al.SourceRange),
+		)
+	}
+
+	// Add chain middlewares defined by the service's labels
+	for name, chain := range extractChainConfigs(service.Labels) {
+		ch := chain
+		config.AddMiddleware(name, MiddlewareConfig{Chain: &ch})
+		p.logger.Info("Created chain middleware from labels",
+			logging.String("service", service.Name),
+			logging.String("middleware", name),
+			logging.Strings("members", ch.Middlewares),
+		)
+	}
+
+	// Add headers middlewares (CORS, custom headers) defined by the service's labels
+	for name, headersConfig := range headersConfigs {
+		headers := headersConfig
+		config.AddMiddleware(name, MiddlewareConfig{Headers: &headers})
+		p.logger.Info("Created headers middleware from labels",
+			logging.String("service", service.Name),
+			logging.String("middleware", name),
+			logging.Strings("origins", append(headers.AccessControlAllowOriginList, headers.AccessControlAllowOriginListRegex...)),
+			logging.Int("requestHeaders", len(headers.CustomRequestHeaders)),
+			logging.Int("responseHeaders", len(headers.CustomResponseHeaders)),
+		)
+	}
+
+	// Add replacePathRegex middlewares (path rewrites) defined by the service's labels
+	for name, rewriteConfig := range rewriteConfigs {
+		rewrite := rewriteConfig
+		config.AddMiddleware(name, MiddlewareConfig{ReplacePathRegex: &rewrite})
+		p.logger.Info("Created replacePathRegex middleware from labels",
+			logging.String("service", service.Name),
+			logging.String("middleware", name),
+			logging.String("regex", rewrite.Regex),
+			logging.String("replacement", rewrite.Replacement),
+		)
+	}
+
+	// Add errors middlewares (custom error pages) defined by the service's labels
+	for name, errorsConfig := range errorsConfigs {
+		errorPage := errorsConfig
+		config.AddMiddleware(name, MiddlewareConfig{Errors: &errorPage})
+		p.logger.Info("Created errors middleware from labels",
+			logging.String("service", service.Name),
+			logging.String("middleware", name),
+			logging.Strings("status", errorPage.Status),
+			logging.String("errorService", errorPage.Service),
+		)
+	}
+
+	// Add service definition, using the protocol from traefik_protocol (http, h2c or grpc)
+	protocol := extractProtocol(service.Labels)
+	serviceConfig, transport, err := backendService(serviceNameFromLabel, service.URL, protocol)
+	if err != nil {
+		return err
+	}
+	if timeout := p.forwardingTimeout(service); timeout > 0 {
+		transport = withForwardingTimeout(&serviceConfig, transport, serviceNameFromLabel, timeout)
+	}
+	if transport != nil {
+		config.AddServersTransport(serviceConfig.LoadBalancer.ServersTransport, *transport)
 	}
+	serviceConfig.LoadBalancer.ResponseForwarding = extractResponseForwarding(service.Labels)
 	config.AddService(serviceNameFromLabel, serviceConfig)
+	if protocol != ProtocolHTTP {
+		p.logger.Info("Using backend protocol from labels",
+			logging.String("service", service.Name),
+			logging.String("protocol", protocol),
+			logging.String("url", serviceConfig.LoadBalancer.Servers[0].URL),
+		)
+	}
 
 	p.logger.Debug("Service processed successfully",
 		logging.String("service", service.Name),
@@ -599,6 +1800,178 @@ func (p *Provider) processService(service CloudRunService, config *DynamicConfig
 	return nil
 }
 
+// validTokenFailurePolicy reports whether policy is a known token failure policy
+func validTokenFailurePolicy(policy string) bool {
+	switch policy {
+	case TokenFailureEmitWithoutAuth, TokenFailureSkipRoute, TokenFailureFailGeneration:
+		return true
+	}
+	return false
+}
+
+// tokenFailurePolicy returns the service's token failure policy, from its
+// traefik_token_failure_policy label or the provider-wide setting
+func (p *Provider) tokenFailurePolicy(service CloudRunService) string {
+	if policy, ok := service.Labels[tokenFailurePolicyLabel]; ok {
+		if validTokenFailurePolicy(policy) {
+			return policy
+		}
+		p.logger.Warn("Ignoring invalid token failure policy label",
+			logging.String("service", service.Name),
+			logging.String("label", tokenFailurePolicyLabel),
+			logging.String("value", policy),
+		)
+	}
+	return p.config.TokenFailurePolicy
+}
+
+// authType returns the service's auth type from its traefik_auth_type label,
+// defaulting to identity tokens
+func (p *Provider) authType(service CloudRunService) string {
+	authType, ok := service.Labels[authTypeLabel]
+	if !ok {
+		return AuthTypeIDToken
+	}
+	switch authType {
+	case AuthTypeIDToken, AuthTypeAccessToken:
+		return authType
+	}
+	p.logger.Warn("Ignoring invalid auth type label",
+		logging.String("service", service.Name),
+		logging.String("label", authTypeLabel),
+		logging.String("value", authType),
+	)
+	return AuthTypeIDToken
+}
+
+// accessTokenAllowed reports whether a service matches an
+// AccessTokenServices pattern, by name or as <project>/<service>
+func (p *Provider) accessTokenAllowed(service CloudRunService) bool {
+	for _, pattern := range p.config.AccessTokenServices {
+		if matched, _ := path.Match(pattern, service.Name); matched {
+			return true
+		}
+		if matched, _ := path.Match(pattern, service.ProjectID+"/"+service.Name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// accessTokenRefused reports whether a service is labeled
+// traefik_auth_type=access_token without being allowed access tokens
+func (p *Provider) accessTokenRefused(service CloudRunService) bool {
+	return service.Labels[authTypeLabel] == AuthTypeAccessToken && !p.accessTokenAllowed(service)
+}
+
+// fetchAccessToken fetches an OAuth access token for a service labeled
+// traefik_auth_type=access_token. Returns a *TokenError if the token can't be
+// fetched, including when the service isn't in AccessTokenServices or the
+// token source only mints identity tokens.
+func (p *Provider) fetchAccessToken(service CloudRunService) (string, error) {
+	if !p.accessTokenAllowed(service) {
+		p.logger.Warn("Refusing access token for service not in ACCESS_TOKEN_SERVICES",
+			logging.String("service", service.Name),
+			logging.String("project", service.ProjectID),
+			logging.String("label", authTypeLabel),
+		)
+		return "", &TokenError{Service: service.Name, URL: service.URL, Err: ErrAccessTokenNotAllowed}
+	}
+	source, ok := p.tokenManager.(AccessTokenSource)
+	if !ok {
+		return "", &TokenError{Service: service.Name, URL: service.URL, Err: ErrAccessTokensUnsupported}
+	}
+
+	token, err := source.GetScopedAccessToken(p.config.AccessTokenScopes...)
+	if err != nil {
+		p.logger.Error("Failed to fetch access token for service",
+			logging.GetCodeField(logging.CodeTokenFetchError),
+			logging.String("service", service.Name),
+			logging.Error(err),
+		)
+		return "", &TokenError{Service: service.Name, URL: service.URL, Err: err}
+	}
+
+	p.logger.Info("Successfully fetched access token for service",
+		logging.GetCodeField(logging.CodeTokenFetchSuccess),
+		logging.String("service", service.Name),
+		logging.Int("tokenLength", len(token)),
+	)
+	return token, nil
+}
+
+// fetchServiceToken fetches an identity token for a service's URL.
+// Returns a *TokenError if the token can't be fetched or doesn't look like a JWT;
+// the caller then skips the auth middleware and the service will return 401.
+func (p *Provider) fetchServiceToken(service CloudRunService) (string, error) {
+	// Get identity token for service
+	// This token will be used in Authorization header for Cloud Run service-to-service auth
+	p.logger.Debug("Fetching identity token for service",
+		logging.String("service", service.Name),
+		logging.String("url", service.URL),
+	)
+
+	serviceToken, err := p.tokenManager.GetToken(service.URL)
+	if err != nil {
+		p.logger.Error("Failed to fetch identity token for service",
+			logging.GetCodeField(logging.CodeTokenFetchError),
+			logging.String("service", service.Name),
+			logging.String("url", service.URL),
+			logging.Error(err),
+		)
+		// Log detailed error for debugging
+		if errors.Is(err, ErrMetadataUnavailable) {
+			p.logger.Error("Metadata server issue - check if running in Cloud Run or set CLOUDRUN_PROVIDER_DEV_MODE=true",
+				logging.String("service", service.Name),
+			)
+		}
+		if errors.Is(err, ErrADCMissing) {
+			p.logger.Error("ADC issue - run 'gcloud auth application-default login' for local development",
+				logging.String("service", service.Name),
+			)
+		}
+		// Continue without token - service will return 401
+		return "", &TokenError{Service: service.Name, URL: service.URL, Err: err}
+	} else {
+		// Validate token format
+		if !strings.HasPrefix(serviceToken, "eyJ") {
+			p.logger.Error("Token doesn't look valid (should start with eyJ for JWT)",
+				logging.GetCodeField(logging.CodeTokenInvalid),
+				logging.String("service", service.Name),
+				logging.String("tokenPreview", p.redactor.Token(serviceToken)),
+				logging.Int("tokenLength", len(serviceToken)),
+			)
+			return "", &TokenError{Service: service.Name, URL: service.URL, Err: ErrInvalidToken}
+		} else {
+			p.logger.Info("Successfully fetched identity token for service",
+				logging.GetCodeField(logging.CodeTokenFetchSuccess),
+				logging.String("service", service.Name),
+				logging.String("url", service.URL),
+				logging.Int("tokenLength", len(serviceToken)),
+			)
+		}
+	}
+
+	return serviceToken, nil
+}
+
+// appendMissing appends each middleware that isn't already in the list
+func appendMissing(middlewares []string, extra ...string) []string {
+	for _, mw := range extra {
+		found := false
+		for _, existing := range middlewares {
+			if existing == mw {
+				found = true
+				break
+			}
+		}
+		if !found {
+			middlewares = append(middlewares, mw)
+		}
+	}
+	return middlewares
+}
+
 // getStripPrefixMiddleware returns the appropriate strip-prefix middleware name
 // for a given router based on its name and rule. Returns empty string if no
 // strip-prefix middleware should be auto-injected.
@@ -1,6 +1,10 @@
 package provider
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
 	"testing"
 	"time"
 )
@@ -37,6 +41,46 @@ func TestNew_ValidConfig(t *testing.T) {
 	}
 }
 
+func TestNew_LogOutput(t *testing.T) {
+	var logs bytes.Buffer
+	provider, err := newProvider(&Config{ProjectIDs: []string{"test-project"}, Region: "us-central1", LogOutput: &logs})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	provider.logger.Info("hello")
+	if !strings.Contains(logs.String(), "hello") {
+		t.Errorf("Expected logs on the configured output, got: %q", logs.String())
+	}
+}
+
+func TestProvider_SetLogLevel(t *testing.T) {
+	var logs bytes.Buffer
+	provider, err := newProvider(&Config{ProjectIDs: []string{"test-project"}, Region: "us-central1", LogLevel: "INFO", LogOutput: &logs})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	provider.logger.Debug("before")
+	if err := provider.SetLogLevel("debug"); err != nil {
+		t.Fatalf("SetLogLevel failed: %v", err)
+	}
+	provider.logger.Debug("after")
+	if strings.Contains(logs.String(), "before") || !strings.Contains(logs.String(), "after") || provider.LogLevel() != "DEBUG" {
+		t.Errorf("Expected debug logs only after switching, got: %q", logs.String())
+	}
+	if err := provider.SetLogLevel("verbose"); err == nil {
+		t.Error("Expected an unknown level to be rejected")
+	}
+}
+
+func TestNew_InvalidRedactEmails(t *testing.T) {
+	_, err := New(&Config{ProjectIDs: []string{"p"}, Region: "r", RedactEmails: "scramble"})
+	if err == nil || !strings.Contains(err.Error(), "invalid email redaction") {
+		t.Errorf("Expected invalid email redaction error, got: %v", err)
+	}
+}
+
 func TestNew_NilConfig(t *testing.T) {
 	provider, err := New(nil)
 
@@ -139,8 +183,8 @@ func TestProcessService_NoRouterLabels(t *testing.T) {
 		t.Fatal("Expected error for service with no router labels")
 	}
 
-	if err.Error() != "no router labels found" {
-		t.Errorf("Expected specific error message, got: %v", err)
+	if !errors.Is(err, ErrNoRouterLabels) {
+		t.Errorf("Expected ErrNoRouterLabels, got: %v", err)
 	}
 }
 
@@ -150,8 +194,8 @@ func TestProcessService_WithValidLabels(t *testing.T) {
 		Region:     "us-central1",
 	}
 
-	// processService does not call the Cloud Run API, so no credentials needed.
-	provider, err := newProvider(config)
+	// A fake token source stands in for the metadata server, so no credentials needed.
+	provider, err := NewWithClients(config, nil, &fakeTokenSource{token: "eyJtest"}, nil)
 	if err != nil {
 		t.Fatalf("Failed to create provider: %v", err)
 	}
@@ -167,10 +211,10 @@ func TestProcessService_WithValidLabels(t *testing.T) {
 	}
 
 	dynamicConfig := NewDynamicConfig()
-	_ = provider.processService(service, dynamicConfig)
+	if err := provider.processService(service, dynamicConfig); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
 
-	// Error is expected because token fetch will fail in test environment
-	// But we should still get the router configured
 	if len(dynamicConfig.HTTP.Routers) == 0 {
 		t.Error("Expected at least one router to be configured")
 	}
@@ -179,9 +223,9 @@ func TestProcessService_WithValidLabels(t *testing.T) {
 		t.Error("Expected at least one service to be configured")
 	}
 
-	// Auth middleware is only added when a GCP identity token is available.
-	// In the test environment there is no metadata server, so no middleware
-	// is expected here — that is correct behavior.
+	if _, ok := dynamicConfig.HTTP.Middlewares["test-service-auth"]; !ok {
+		t.Error("Expected auth middleware to be created from the fake token")
+	}
 }
 
 func TestDynamicConfig_AddRouter(t *testing.T) {
@@ -218,6 +262,63 @@ func TestDynamicConfig_AddRouter(t *testing.T) {
 	}
 }
 
+func TestIsDedicatedService_EnvironmentAffixes(t *testing.T) {
+	tests := []struct {
+		router, service string
+		affixes         []string
+		want            bool
+	}{
+		{"lab1-c2", "lab1-c2-stg", nil, true},
+		{"lab1-c2", "lab-01-basic-magecart-stg", nil, false},
+		{"lab1-c2", "lab1-c2-qa", nil, false},
+		{"lab1-c2", "lab1-c2-qa", environmentAffixes("qa", nil), true},
+		{"lab1-c2", "lab1-c2-sandbox", []string{"-sandbox"}, true},
+		{"lab1-c2", "qa-lab1-c2", []string{"qa-"}, true},
+		{"lab1-c2", "lab1-c2-stg", []string{"qa-"}, false},
+	}
+	for _, tt := range tests {
+		if got := isDedicatedService(tt.router, tt.service, tt.affixes); got != tt.want {
+			t.Errorf("isDedicatedService(%s, %s, %v) = %v, want %v", tt.router, tt.service, tt.affixes, got, tt.want)
+		}
+	}
+}
+
+func TestEnvironmentAffixes(t *testing.T) {
+	if got := environmentAffixes("qa", nil); len(got) != len(DefaultEnvironmentSuffixes)+1 || got[len(got)-1] != "-qa" {
+		t.Errorf("Expected defaults plus -qa, got %v", got)
+	}
+	if got := environmentAffixes("stg", nil); len(got) != len(DefaultEnvironmentSuffixes) {
+		t.Errorf("Expected -stg not to be added twice, got %v", got)
+	}
+	if got := environmentAffixes("qa", []string{"sandbox", " qa- ", ""}); len(got) != 2 || got[0] != "-sandbox" || got[1] != "qa-" {
+		t.Errorf("Expected configured affixes only, got %v", got)
+	}
+}
+
+func TestBuild_RouterConflictEnvironmentSuffix(t *testing.T) {
+	provider, err := newProvider(&Config{
+		ProjectIDs:     []string{"test-project"},
+		Region:         "us-central1",
+		TokenInjection: TokenInjectionPlugin,
+		Environment:    "qa",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	labels := map[string]string{"traefik_enable": "true", "traefik_http_routers_lab1-c2_rule": "PathPrefix(`/lab1/c2`)"}
+	config, err := provider.Build([]CloudRunService{
+		{Name: "lab1-c2-qa", ProjectID: "test-project", URL: "https://lab1-c2-qa.run.app", Labels: labels},
+		{Name: "lab1-qa", ProjectID: "test-project", URL: "https://lab1-qa.run.app", Labels: labels},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if router := config.HTTP.Routers["lab1-c2"]; router.Service != "lab1-c2-qa" {
+		t.Errorf("Expected the dedicated lab1-c2-qa service to keep the router, got %s", router.Service)
+	}
+}
+
 func TestDynamicConfig_AddService(t *testing.T) {
 	config := NewDynamicConfig()
 
@@ -292,6 +393,25 @@ func TestDynamicConfig_AddAuthMiddleware_EmptyToken(t *testing.T) {
 	}
 }
 
+func TestDynamicConfig_Sanitized(t *testing.T) {
+	token := strings.Repeat("a", 30) + strings.Repeat("b", 30)
+	config := NewDynamicConfig()
+	config.AddAuthMiddleware("test-auth", token)
+	config.AddRouter("test", RouterConfig{Rule: "PathPrefix(`/`)", Middlewares: []string{"test-auth"}})
+
+	sanitized := config.Sanitized()
+	header := sanitized.HTTP.Middlewares["test-auth"].Headers.CustomRequestHeaders["X-Serverless-Authorization"]
+	if header != "Bearer "+strings.Repeat("a", 20)+"..."+strings.Repeat("b", 20) {
+		t.Errorf("Expected truncated token, got: %s", header)
+	}
+	if _, ok := sanitized.HTTP.Routers["test"]; !ok {
+		t.Error("Expected routers to be kept")
+	}
+	if config.HTTP.Middlewares["test-auth"].Headers.CustomRequestHeaders["X-Serverless-Authorization"] != "Bearer "+token {
+		t.Error("Sanitized must not modify the original configuration")
+	}
+}
+
 func TestDynamicConfig_AddTraefikInternalRouters(t *testing.T) {
 	config := NewDynamicConfig()
 
@@ -310,3 +430,317 @@ func TestDynamicConfig_AddTraefikInternalRouters(t *testing.T) {
 		t.Error("Expected traefik-dashboard router")
 	}
 }
+
+func TestProcessService_TokenPluginInjection(t *testing.T) {
+	config := &Config{
+		ProjectIDs:     []string{"test-project"},
+		Region:         "us-central1",
+		TokenInjection: TokenInjectionPlugin,
+	}
+
+	provider, err := newProvider(config)
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	service := CloudRunService{
+		Name:      "test-service",
+		ProjectID: "test-project",
+		URL:       "https://test-service.run.app",
+		Labels: map[string]string{
+			"traefik_enable":                 "true",
+			"traefik_http_routers_test_rule": "Host(`example.com`)",
+		},
+	}
+
+	dynamicConfig := NewDynamicConfig()
+	if err := provider.processService(service, dynamicConfig); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	mw, ok := dynamicConfig.HTTP.Middlewares["test-service-auth"]
+	if !ok {
+		t.Fatal("Expected token plugin middleware to be created")
+	}
+	if mw.Headers != nil {
+		t.Error("Expected no static headers in plugin mode")
+	}
+	if got := mw.Plugin[DefaultTokenPluginName]["audience"]; got != service.URL {
+		t.Errorf("Expected audience %s, got %v", service.URL, got)
+	}
+
+	router := dynamicConfig.HTTP.Routers["test"]
+	if len(router.Middlewares) == 0 || router.Middlewares[0] != "test-service-auth" {
+		t.Errorf("Expected auth middleware first in chain, got %v", router.Middlewares)
+	}
+}
+
+func TestNew_InvalidTokenInjection(t *testing.T) {
+	_, err := newProvider(&Config{
+		ProjectIDs:     []string{"test-project"},
+		Region:         "us-central1",
+		TokenInjection: "sideways",
+	})
+	if err == nil {
+		t.Fatal("Expected error for invalid token injection mode")
+	}
+}
+
+func TestDynamicConfig_AddUserAuthMiddleware_Custom(t *testing.T) {
+	config := NewDynamicConfig()
+
+	config.AddUserAuthMiddleware("portal-auth-check", "https://home-index.run.app", UserAuthConfig{
+		CheckBaseURL:        "http://traefik:9000/",
+		CheckPath:           "auth/verify",
+		AuthResponseHeaders: []string{"X-User"},
+	})
+
+	mw, ok := config.HTTP.Middlewares["portal-auth-check"]
+	if !ok || mw.ForwardAuth == nil {
+		t.Fatal("Expected forwardAuth middleware")
+	}
+	if mw.ForwardAuth.Address != "http://traefik:9000/auth/verify" {
+		t.Errorf("Unexpected address: %s", mw.ForwardAuth.Address)
+	}
+	if len(mw.ForwardAuth.AuthResponseHeaders) != 1 || mw.ForwardAuth.AuthResponseHeaders[0] != "X-User" {
+		t.Errorf("Unexpected authResponseHeaders: %v", mw.ForwardAuth.AuthResponseHeaders)
+	}
+	// Unset fields fall back to defaults
+	if len(mw.ForwardAuth.AuthRequestHeaders) != len(DefaultUserAuthConfig().AuthRequestHeaders) {
+		t.Errorf("Expected default authRequestHeaders, got %v", mw.ForwardAuth.AuthRequestHeaders)
+	}
+}
+
+func TestDynamicConfig_AddForwardAuthMiddleware_Defaults(t *testing.T) {
+	config := NewDynamicConfig()
+
+	config.AddForwardAuthMiddleware("lab1-auth-check", "https://home-index.run.app")
+
+	mw := config.HTTP.Middlewares["lab1-auth-check"]
+	if mw.ForwardAuth == nil || mw.ForwardAuth.Address != "http://localhost:8080/api/auth/check" {
+		t.Errorf("Expected default auth check address, got %+v", mw.ForwardAuth)
+	}
+}
+
+func TestUserAuthConfig_IsUserAuthMiddleware(t *testing.T) {
+	userAuth := UserAuthConfig{MiddlewareNames: []string{"portal-sso"}}.withDefaults()
+
+	for _, mw := range []string{"portal-sso", "portal-sso@file", "lab1-auth-check"} {
+		if !userAuth.isUserAuthMiddleware(mw) {
+			t.Errorf("Expected %s to be a user auth middleware", mw)
+		}
+	}
+	if userAuth.isUserAuthMiddleware("retry-cold-start@file") {
+		t.Error("Expected retry-cold-start@file not to be a user auth middleware")
+	}
+}
+
+func TestServiceAllowed_Filters(t *testing.T) {
+	provider, err := newProvider(&Config{
+		ProjectIDs:      []string{"test-project"},
+		Region:          "us-central1",
+		IncludeServices: []string{"lab*"},
+		ExcludeServices: []string{"lab*-canary"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	tests := map[string]bool{
+		"lab1-stg":        true,
+		"lab1-stg-canary": false,
+		"home-index-stg":  false,
+	}
+	for name, want := range tests {
+		if got, reason := provider.serviceAllowed(name); got != want {
+			t.Errorf("serviceAllowed(%q) = %v (%s), want %v", name, got, reason, want)
+		}
+	}
+}
+
+func TestNew_InvalidServiceFilter(t *testing.T) {
+	_, err := newProvider(&Config{
+		ProjectIDs:      []string{"test-project"},
+		Region:          "us-central1",
+		ExcludeServices: []string{"lab[1"},
+	})
+	if err == nil {
+		t.Fatal("Expected error for malformed filter pattern")
+	}
+}
+
+func TestTokenError_Unwrap(t *testing.T) {
+	cause := fmt.Errorf("%w and dev mode disabled", ErrMetadataUnavailable)
+	var err error = &TokenError{Service: "lab1", URL: "https://lab1.run.app", Err: cause}
+
+	if !errors.Is(err, ErrMetadataUnavailable) {
+		t.Error("Expected TokenError to match ErrMetadataUnavailable")
+	}
+	if errors.Is(err, ErrADCMissing) {
+		t.Error("Expected TokenError not to match ErrADCMissing")
+	}
+
+	var tokenErr *TokenError
+	if !errors.As(fmt.Errorf("wrapped: %w", err), &tokenErr) {
+		t.Fatal("Expected errors.As to find TokenError")
+	}
+	if tokenErr.Service != "lab1" {
+		t.Errorf("Expected service lab1, got %s", tokenErr.Service)
+	}
+}
+
+func TestNew_InvalidTokenFailurePolicy(t *testing.T) {
+	_, err := newProvider(&Config{
+		ProjectIDs:         []string{"test-project"},
+		Region:             "us-central1",
+		TokenFailurePolicy: "retry-forever",
+	})
+	if err == nil {
+		t.Fatal("Expected error for invalid token failure policy")
+	}
+}
+
+func TestPrepareConfig_UserAuthExplicitFalseWinsOverEnv(t *testing.T) {
+	t.Setenv("USER_AUTH_ENABLED", "true")
+	t.Setenv("SKIP_AUTH_CHECK", "true")
+
+	config := &Config{ProjectIDs: []string{"test-project"}, Region: "us-central1", UserAuthEnabled: boolPtr(false), SkipAuthCheck: boolPtr(false)}
+	if err := prepareConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	if *config.UserAuthEnabled || *config.SkipAuthCheck {
+		t.Errorf("Expected explicit false kept, got enabled=%v skip=%v", *config.UserAuthEnabled, *config.SkipAuthCheck)
+	}
+
+	config = &Config{ProjectIDs: []string{"test-project"}, Region: "us-central1"}
+	if err := prepareConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	if !*config.UserAuthEnabled || !*config.SkipAuthCheck {
+		t.Errorf("Expected unset values from the environment, got enabled=%v skip=%v", *config.UserAuthEnabled, *config.SkipAuthCheck)
+	}
+}
+
+func TestTokenFailurePolicy_LabelOverride(t *testing.T) {
+	provider, err := newProvider(&Config{
+		ProjectIDs:         []string{"test-project"},
+		Region:             "us-central1",
+		TokenFailurePolicy: TokenFailureFailGeneration,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	tests := []struct {
+		labels   map[string]string
+		expected string
+	}{
+		{map[string]string{}, TokenFailureFailGeneration},
+		{map[string]string{"traefik_token_failure_policy": "skip-route"}, TokenFailureSkipRoute},
+		{map[string]string{"traefik_token_failure_policy": "bogus"}, TokenFailureFailGeneration},
+	}
+	for _, tt := range tests {
+		got := provider.tokenFailurePolicy(CloudRunService{Name: "svc", Labels: tt.labels})
+		if got != tt.expected {
+			t.Errorf("Expected policy %q for labels %v, got %q", tt.expected, tt.labels, got)
+		}
+	}
+}
+
+func TestProcessService_SkipRouteOnTokenFailure(t *testing.T) {
+	provider, err := newProvider(&Config{
+		ProjectIDs: []string{"test-project"},
+		Region:     "us-central1",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	service := CloudRunService{
+		Name:      "test-service",
+		ProjectID: "test-project",
+		URL:       "https://test-service.run.app",
+		Labels: map[string]string{
+			"traefik_enable":                    "true",
+			"traefik_token_failure_policy":      "skip-route",
+			"traefik_http_routers_test_rule":    "PathPrefix(`/test`)",
+			"traefik_http_routers_test_service": "test-service",
+		},
+	}
+
+	dynamicConfig := NewDynamicConfig()
+	err = provider.processService(service, dynamicConfig)
+	if err == nil {
+		t.Skip("Identity token fetched (running on GCP?), token failure path not exercised")
+	}
+
+	var tokenErr *TokenError
+	if !errors.As(err, &tokenErr) {
+		t.Fatalf("Expected TokenError, got: %v", err)
+	}
+	if len(dynamicConfig.HTTP.Routers) != 0 || len(dynamicConfig.HTTP.Services) != 0 {
+		t.Errorf("Expected no routers or services, got %d routers and %d services",
+			len(dynamicConfig.HTTP.Routers), len(dynamicConfig.HTTP.Services))
+	}
+}
+
+func TestProcessService_RouteTagging(t *testing.T) {
+	provider, err := newProvider(&Config{
+		ProjectIDs:     []string{"test-project"},
+		Region:         "us-central1",
+		TokenInjection: TokenInjectionPlugin,
+		RouteTagging:   true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	service := CloudRunService{
+		Name:      "test-service",
+		ProjectID: "test-project",
+		URL:       "https://test-service.run.app",
+		Labels: map[string]string{
+			"traefik_enable":                 "true",
+			"traefik_http_routers_test_rule": "PathPrefix(`/test`)",
+		},
+	}
+
+	dynamicConfig := NewDynamicConfig()
+	if err := provider.processService(service, dynamicConfig); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	mw, ok := dynamicConfig.HTTP.Middlewares["test-route-tag"]
+	if !ok || mw.Headers == nil {
+		t.Fatal("Expected route tag middleware to be created")
+	}
+	if got := mw.Headers.CustomRequestHeaders[DefaultRouteTagHeader]; got != "test" {
+		t.Errorf("Expected %s: test, got %q", DefaultRouteTagHeader, got)
+	}
+	router := dynamicConfig.HTTP.Routers["test"]
+	want := []string{"test-service-auth", "test-route-tag", "retry-cold-start@file"}
+	if strings.Join(router.Middlewares, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected middlewares %v, got %v", want, router.Middlewares)
+	}
+
+	// Services can opt out with traefik_route_tag=false
+	service.Labels["traefik_route_tag"] = "false"
+	dynamicConfig = NewDynamicConfig()
+	if err := provider.processService(service, dynamicConfig); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, ok := dynamicConfig.HTTP.Middlewares["test-route-tag"]; ok {
+		t.Error("Expected no route tag middleware for opted-out service")
+	}
+}
+
+func TestNew_InvalidCredentials(t *testing.T) {
+	_, err := newProvider(&Config{
+		ProjectIDs:      []string{"test-project"},
+		Region:          "us-central1",
+		CredentialsJSON: `{"type": "authorized_user"}`,
+	})
+	if err == nil {
+		t.Error("Expected error for credentials that can't mint identity tokens")
+	}
+}
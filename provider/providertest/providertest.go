@@ -0,0 +1,295 @@
+// Package providertest runs label fixtures through the provider and compares
+// the generated routes against golden files.
+//
+// A fixture is a YAML file describing provider settings and the Cloud Run
+// services (with their labels) that discovery would return:
+//
+//	config:
+//	  userAuthEnabled: true
+//	  homeIndexURL: https://home-index.run.app
+//	services:
+//	  - name: lab1
+//	    url: https://lab1.run.app
+//	    labels:
+//	      traefik_enable: "true"
+//	      traefik_http_routers_lab1_rule_id: lab1
+//
+// Each fixture <name>.yml in a directory is checked against
+// <name>.golden.yml next to it. Set UPDATE_GOLDEN=1 to (re)write the golden
+// files instead of comparing.
+package providertest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/cloudrunprovider"
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/internal/logging"
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/provider"
+	run "google.golang.org/api/run/v1"
+	"gopkg.in/yaml.v3"
+)
+
+// GoldenSuffix is appended to a fixture's base name to form its golden file name
+const GoldenSuffix = ".golden.yml"
+
+// UpdateEnv is the environment variable that switches RunGolden to rewriting golden files
+const UpdateEnv = "UPDATE_GOLDEN"
+
+// Defaults applied to fixtures that leave them unset
+const (
+	DefaultProject = "test-project"
+	DefaultRegion  = "us-central1"
+
+	// DefaultToken is the identity token every service gets. It starts with
+	// "eyJ" so it passes the provider's JWT sanity check.
+	DefaultToken = "eyJhbGciOiJSUzI1NiJ9.golden.signature"
+)
+
+// Fixture describes one provider run: settings plus the discovered services
+type Fixture struct {
+	Config   FixtureConfig    `yaml:"config"`
+	Services []FixtureService `yaml:"services"`
+}
+
+// FixtureConfig holds the provider settings a fixture may override
+type FixtureConfig struct {
+	Region             string   `yaml:"region"`
+	TokenInjection     string   `yaml:"tokenInjection"`
+	TokenPluginName    string   `yaml:"tokenPluginName"`
+	TokenFailurePolicy string   `yaml:"tokenFailurePolicy"`
+	UserAuthEnabled    *bool    `yaml:"userAuthEnabled"`
+	HomeIndexURL       string   `yaml:"homeIndexURL"`
+	DefaultMiddlewares []string `yaml:"defaultMiddlewares"`
+	IncludeServices    []string `yaml:"includeServices"`
+	ExcludeServices    []string `yaml:"excludeServices"`
+	RouteTagging       bool     `yaml:"routeTagging"`
+	RequestIDEnabled   bool     `yaml:"requestIDEnabled"`
+	NamePrefix         string   `yaml:"namePrefix"`
+	TraefikVersion     string   `yaml:"traefikVersion"`
+	AutoEntryPoints    bool     `yaml:"autoEntryPoints"`
+
+	// Entrypoints and TLS per rule type (with autoEntryPoints)
+	EntryPointRules map[string]provider.EntryPointRule `yaml:"entryPointRules"`
+
+	// Traefik API and dashboard routers
+	Dashboard provider.DashboardConfig `yaml:"dashboard"`
+
+	// Services per List page (0 = one page per project), to exercise paging
+	PageSize int `yaml:"pageSize"`
+
+	// Token returned for every service; "" uses DefaultToken
+	Token string `yaml:"token"`
+	// TokenError makes every token fetch fail with this message
+	TokenError string `yaml:"tokenError"`
+}
+
+// FixtureService is a Cloud Run service as returned by discovery
+type FixtureService struct {
+	Name    string            `yaml:"name"`
+	Project string            `yaml:"project"` // Default: DefaultProject
+	URL     string            `yaml:"url"`
+	Labels  map[string]string `yaml:"labels"`
+}
+
+// LoadFixture reads a fixture file
+func LoadFixture(path string) (*Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixture: %w", err)
+	}
+	var fixture Fixture
+	if err := yaml.Unmarshal(data, &fixture); err != nil {
+		return nil, fmt.Errorf("failed to parse fixture %s: %w", path, err)
+	}
+	return &fixture, nil
+}
+
+// Generate runs one discovery cycle over the fixture's services and returns
+// the generated configuration encoded the way routes.yml is written (without
+// the header comments)
+func Generate(fixture *Fixture) ([]byte, error) {
+	p, err := NewProvider(fixture)
+	if err != nil {
+		return nil, err
+	}
+
+	configChan := make(chan *provider.DynamicConfig, 1)
+	if err := p.RunOnce(configChan); err != nil {
+		return nil, err
+	}
+	return Encode(<-configChan)
+}
+
+// NewProvider creates a provider whose Cloud Run client and token source
+// serve the fixture, for tests and benchmarks that drive Discover and Build
+// themselves. Logging is discarded.
+func NewProvider(fixture *Fixture) (*provider.Provider, error) {
+	region := fixture.Config.Region
+	if region == "" {
+		region = DefaultRegion
+	}
+
+	// Projects keep the order they first appear in, so output doesn't depend on map order
+	var projects []string
+	client := &fixtureClient{services: make(map[string][]*run.Service), pageSize: fixture.Config.PageSize}
+	for _, svc := range fixture.Services {
+		project := svc.Project
+		if project == "" {
+			project = DefaultProject
+		}
+		parent := fmt.Sprintf("projects/%s/locations/%s", project, region)
+		if _, seen := client.services[parent]; !seen {
+			projects = append(projects, project)
+		}
+		client.services[parent] = append(client.services[parent], &run.Service{
+			Metadata: &run.ObjectMeta{Name: svc.Name, Labels: svc.Labels},
+			Status:   &run.ServiceStatus{Url: svc.URL},
+		})
+	}
+	if len(projects) == 0 {
+		projects = []string{DefaultProject}
+	}
+
+	tokens := &fixtureTokens{token: fixture.Config.Token}
+	if tokens.token == "" {
+		tokens.token = DefaultToken
+	}
+	if fixture.Config.TokenError != "" {
+		tokens.err = fmt.Errorf("%s", fixture.Config.TokenError)
+	}
+
+	return provider.NewWithClients(&provider.Config{
+		ProjectIDs:         projects,
+		Region:             region,
+		PollInterval:       time.Minute,
+		TokenInjection:     fixture.Config.TokenInjection,
+		TokenPluginName:    fixture.Config.TokenPluginName,
+		TokenFailurePolicy: fixture.Config.TokenFailurePolicy,
+		UserAuthEnabled:    fixture.Config.UserAuthEnabled,
+		HomeIndexURL:       fixture.Config.HomeIndexURL,
+		DefaultMiddlewares: fixture.Config.DefaultMiddlewares,
+		IncludeServices:    fixture.Config.IncludeServices,
+		ExcludeServices:    fixture.Config.ExcludeServices,
+		RouteTagging:       fixture.Config.RouteTagging,
+		RequestIDEnabled:   fixture.Config.RequestIDEnabled,
+		NamePrefix:         fixture.Config.NamePrefix,
+		TraefikVersion:     fixture.Config.TraefikVersion,
+		AutoEntryPoints:    fixture.Config.AutoEntryPoints,
+		EntryPointRules:    fixture.Config.EntryPointRules,
+		Dashboard:          fixture.Config.Dashboard,
+	}, client, tokens, logging.New(&logging.Config{Level: logging.LevelError, Output: io.Discard}))
+}
+
+// Encode encodes a dynamic configuration as YAML with routes.yml's indentation
+func Encode(config *provider.DynamicConfig) ([]byte, error) {
+	return cloudrunprovider.Marshal(config)
+}
+
+// RunGolden runs every fixture in dir as a subtest and compares its generated
+// configuration with the golden file next to it. With UPDATE_GOLDEN=1 the
+// golden files are written instead.
+func RunGolden(t *testing.T, dir string) {
+	t.Helper()
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.yml"))
+	if err != nil {
+		t.Fatalf("Failed to list fixtures in %s: %v", dir, err)
+	}
+
+	update := os.Getenv(UpdateEnv) != ""
+
+	// Settings the provider reads from the environment must not leak into fixtures
+	for _, name := range []string{"USER_AUTH_ENABLED", "SKIP_AUTH_CHECK", "HOME_INDEX_URL"} {
+		t.Setenv(name, "")
+	}
+
+	ran := 0
+	for _, path := range paths {
+		if strings.HasSuffix(path, GoldenSuffix) {
+			continue
+		}
+		ran++
+		name := strings.TrimSuffix(filepath.Base(path), ".yml")
+		goldenPath := filepath.Join(dir, name+GoldenSuffix)
+
+		t.Run(name, func(t *testing.T) {
+			fixture, err := LoadFixture(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Generate(fixture)
+			if err != nil {
+				t.Fatalf("Failed to generate config: %v", err)
+			}
+
+			if update {
+				if err := os.WriteFile(goldenPath, got, 0644); err != nil {
+					t.Fatalf("Failed to write golden file: %v", err)
+				}
+				return
+			}
+
+			want, err := os.ReadFile(goldenPath)
+			if err != nil {
+				t.Fatalf("Failed to read golden file (run with %s=1 to create it): %v", UpdateEnv, err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("Generated config doesn't match %s (run with %s=1 to update)\n--- got ---\n%s\n--- want ---\n%s",
+					goldenPath, UpdateEnv, got, want)
+			}
+		})
+	}
+
+	if ran == 0 {
+		t.Fatalf("No fixtures found in %s", dir)
+	}
+}
+
+// fixtureClient serves a fixture's services per project, in pages of
+// pageSize when set. The continue token is the offset of the next page.
+type fixtureClient struct {
+	services map[string][]*run.Service
+	pageSize int
+}
+
+func (c *fixtureClient) ListServices(parent, pageToken string) (*run.ListServicesResponse, error) {
+	items := c.services[parent]
+	if c.pageSize <= 0 {
+		return &run.ListServicesResponse{Items: items}, nil
+	}
+
+	offset := 0
+	if pageToken != "" {
+		var err error
+		if offset, err = strconv.Atoi(pageToken); err != nil || offset < 0 || offset > len(items) {
+			return nil, fmt.Errorf("invalid page token %q", pageToken)
+		}
+	}
+	end := min(offset+c.pageSize, len(items))
+	resp := &run.ListServicesResponse{Items: items[offset:end], Metadata: &run.ListMeta{}}
+	if end < len(items) {
+		resp.Metadata.Continue = strconv.Itoa(end)
+	}
+	return resp, nil
+}
+
+// fixtureTokens returns the same token (or error) for every audience
+type fixtureTokens struct {
+	token string
+	err   error
+}
+
+func (s *fixtureTokens) GetToken(string) (string, error) {
+	if s.err != nil {
+		return "", s.err
+	}
+	return s.token, nil
+}
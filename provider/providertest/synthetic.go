@@ -0,0 +1,64 @@
+package providertest
+
+import "fmt"
+
+// Synthetic returns a fixture with n services spread round-robin over the
+// given number of projects (at least one), listed in pages of pageSize, for
+// paging stress tests and large-fleet benchmarks. The services cycle through
+// the label shapes real fleets use: Host rules with priorities, PathPrefix
+// rules with router middlewares, services with two routers, forwardAuth and
+// chain middlewares, and every tenth service is not Traefik-enabled.
+func Synthetic(n, projects, pageSize int) *Fixture {
+	if projects < 1 {
+		projects = 1
+	}
+	fixture := &Fixture{
+		Config:   FixtureConfig{PageSize: pageSize},
+		Services: make([]FixtureService, 0, n),
+	}
+	for i := 0; i < n; i++ {
+		project := fmt.Sprintf("%s-%d", DefaultProject, i%projects)
+		name := fmt.Sprintf("svc-%05d", i)
+		fixture.Services = append(fixture.Services, FixtureService{
+			Name:    name,
+			Project: project,
+			URL:     fmt.Sprintf("https://%s-123456.%s.run.app", name, DefaultRegion),
+			Labels:  syntheticLabels(i, name),
+		})
+	}
+	return fixture
+}
+
+// SyntheticRouted returns how many of Synthetic's first n services are
+// Traefik-enabled
+func SyntheticRouted(n int) int {
+	return n - (n+9)/10
+}
+
+// syntheticLabels returns the labels of the i-th synthetic service
+func syntheticLabels(i int, name string) map[string]string {
+	if i%10 == 0 {
+		return map[string]string{"team": "platform"}
+	}
+
+	router := "traefik_http_routers_" + name
+	labels := map[string]string{"traefik_enable": "true"}
+	switch i % 4 {
+	case 0:
+		labels[router+"_rule"] = fmt.Sprintf("Host(`%s.example.com`)", name)
+		labels[router+"_priority"] = fmt.Sprint(100 + i%50)
+	case 1:
+		labels[router+"_rule"] = fmt.Sprintf("PathPrefix(`/%s`)", name)
+		labels[router+"_middlewares"] = "cors-headers-file__rate-limit"
+	case 2:
+		labels[router+"_rule"] = fmt.Sprintf("PathPrefix(`/%s/api`)", name)
+		labels["traefik_http_routers_"+name+"-static_rule"] = fmt.Sprintf("PathPrefix(`/%s/static`)", name)
+		labels["traefik_http_routers_"+name+"-static_service"] = name
+	case 3:
+		labels[router+"_rule"] = fmt.Sprintf("Host(`%s.example.com`) && PathPrefix(`/v1`)", name)
+		labels[router+"_middlewares"] = name + "-chain"
+		labels["traefik_chain_"+name+"-chain"] = name + "-forwardauth__rate-limit"
+		labels["traefik_forwardauth_address"] = "https://auth.example.com/check"
+	}
+	return labels
+}
@@ -0,0 +1,69 @@
+package provider
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/internal/logging"
+	run "google.golang.org/api/run/v1"
+)
+
+// Readiness policies for services whose Ready condition isn't True, e.g.
+// because their latest revision failed to deploy
+const (
+	ReadinessIgnore   = "ignore"   // Default: route them as usual
+	ReadinessSkip     = "skip"     // Leave them out of the generated config
+	ReadinessFailover = "failover" // Keep routing while an older revision is still ready and serving; skip them otherwise
+)
+
+// notReady returns why a service's Ready condition isn't True, or "" when it
+// is (or the service reports no Ready condition)
+func notReady(svc *run.Service) string {
+	if svc.Status == nil {
+		return ""
+	}
+	for _, condition := range svc.Status.Conditions {
+		if condition == nil || condition.Type != "Ready" || condition.Status == "True" {
+			continue
+		}
+		detail := "Ready=" + condition.Status
+		for _, part := range []string{condition.Reason, condition.Message} {
+			if part = strings.TrimSpace(part); part != "" {
+				detail += ": " + part
+			}
+		}
+		return detail
+	}
+	return ""
+}
+
+// gateReadiness applies the readiness policy to a service that isn't ready
+// and reports whether it is routed anyway. Skipped and failed-over services
+// are recorded in config.
+func (p *Provider) gateReadiness(service CloudRunService, config *DynamicConfig) bool {
+	if service.NotReady == "" || p.config.ReadinessPolicy == ReadinessIgnore {
+		return true
+	}
+
+	if p.config.ReadinessPolicy == ReadinessFailover && service.Revision != "" {
+		p.logger.Warn("Service not ready, routing to its last ready revision",
+			logging.GetCodeField(logging.CodeServiceNotReady),
+			logging.String("service", service.Name),
+			logging.String("project", service.ProjectID),
+			logging.String("revision", service.Revision),
+			logging.String("condition", service.NotReady),
+		)
+		config.skip(SkippedService{Service: service.Name, Project: service.ProjectID, Reason: SkipReasonNotReady,
+			Detail: fmt.Sprintf("serving revision %s (%s)", service.Revision, service.NotReady), Degraded: true})
+		return true
+	}
+
+	p.logger.Warn("Skipping service (not ready)",
+		logging.GetCodeField(logging.CodeServiceNotReady),
+		logging.String("service", service.Name),
+		logging.String("project", service.ProjectID),
+		logging.String("condition", service.NotReady),
+	)
+	config.skip(SkippedService{Service: service.Name, Project: service.ProjectID, Reason: SkipReasonNotReady, Detail: service.NotReady})
+	return false
+}
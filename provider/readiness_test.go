@@ -0,0 +1,100 @@
+package provider
+
+import (
+	"strings"
+	"testing"
+
+	run "google.golang.org/api/run/v1"
+)
+
+func TestNotReady(t *testing.T) {
+	svc := newFakeService("lab1", "https://lab1.run.app", nil)
+	if got := notReady(svc); got != "" {
+		t.Errorf("Expected a service without conditions to count as ready, got %q", got)
+	}
+
+	svc.Status.Conditions = []*run.GoogleCloudRunV1Condition{
+		{Type: "ConfigurationsReady", Status: "False"},
+		{Type: "Ready", Status: "True"},
+	}
+	if got := notReady(svc); got != "" {
+		t.Errorf("Expected Ready=True to count as ready, got %q", got)
+	}
+
+	svc.Status.Conditions[1] = &run.GoogleCloudRunV1Condition{Type: "Ready", Status: "False", Reason: "RevisionFailed", Message: "container failed to start"}
+	if got := notReady(svc); got != "Ready=False: RevisionFailed: container failed to start" {
+		t.Errorf("Unexpected not ready detail: %q", got)
+	}
+}
+
+func TestBuild_ReadinessPolicy(t *testing.T) {
+	labels := func(name string) map[string]string {
+		return map[string]string{"traefik_enable": "true", "traefik_http_routers_" + name + "_rule": "PathPrefix(`/" + name + "`)"}
+	}
+	services := []CloudRunService{
+		{Name: "ready", ProjectID: "test-project", URL: "https://ready.run.app", Labels: labels("ready")},
+		{Name: "failed-deploy", ProjectID: "test-project", URL: "https://failed-deploy.run.app", Labels: labels("failed-deploy"),
+			Revision: "failed-deploy-00002", NotReady: "Ready=False: RevisionFailed"},
+		{Name: "never-ready", ProjectID: "test-project", URL: "https://never-ready.run.app", Labels: labels("never-ready"),
+			NotReady: "Ready=Unknown"},
+	}
+
+	tests := []struct {
+		policy   string
+		routed   []string
+		skipped  []string
+		degraded []string
+	}{
+		{"", []string{"ready", "failed-deploy", "never-ready"}, nil, nil},
+		{ReadinessSkip, []string{"ready"}, []string{"failed-deploy", "never-ready"}, nil},
+		{ReadinessFailover, []string{"ready", "failed-deploy"}, []string{"never-ready"}, []string{"failed-deploy"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			provider, err := newProvider(&Config{
+				ProjectIDs:      []string{"test-project"},
+				Region:          "us-central1",
+				TokenInjection:  TokenInjectionPlugin,
+				ReadinessPolicy: tt.policy,
+			})
+			if err != nil {
+				t.Fatalf("Failed to create provider: %v", err)
+			}
+			config, err := provider.Build(services)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if len(config.HTTP.Routers) != len(tt.routed) {
+				t.Errorf("Expected routers %v, got %d routers", tt.routed, len(config.HTTP.Routers))
+			}
+			for _, name := range tt.routed {
+				if _, ok := config.HTTP.Routers[name]; !ok {
+					t.Errorf("Expected router %s", name)
+				}
+			}
+
+			var skipped, degraded []string
+			for _, s := range config.Skipped() {
+				if s.Reason != SkipReasonNotReady {
+					t.Errorf("Unexpected skip reason %s for %s", s.Reason, s.Service)
+				}
+				if s.Degraded {
+					degraded = append(degraded, s.Service)
+				} else {
+					skipped = append(skipped, s.Service)
+				}
+			}
+			if strings.Join(skipped, ",") != strings.Join(tt.skipped, ",") || strings.Join(degraded, ",") != strings.Join(tt.degraded, ",") {
+				t.Errorf("Expected skipped %v and degraded %v, got %v and %v", tt.skipped, tt.degraded, skipped, degraded)
+			}
+		})
+	}
+}
+
+func TestNew_InvalidReadinessPolicy(t *testing.T) {
+	_, err := New(&Config{ProjectIDs: []string{"p"}, Region: "r", ReadinessPolicy: "wait"})
+	if err == nil || !strings.Contains(err.Error(), "readiness policy") {
+		t.Errorf("Expected invalid policy error, got %v", err)
+	}
+}
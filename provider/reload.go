@@ -0,0 +1,29 @@
+package provider
+
+import "reflect"
+
+// Inherit carries the state previous built up across polls over to p, a
+// provider created from a reloaded configuration that replaces it: the
+// removal guard baseline, departure counters, last known service URLs,
+// error budgets, API stats, the last report and a log level changed at
+// runtime (unless the reload changes the log level itself). The projects'
+// last lists are kept for StaleRouteGracePeriod, with every project due to
+// be listed again. Generated fragments and tokens are not carried over,
+// since the new configuration may generate them differently.
+// Call before p's first generation.
+func (p *Provider) Inherit(previous *Provider) {
+	p.removals = previous.removals
+	p.departures = previous.departures
+	p.urls = previous.urls
+	p.apiStats = previous.apiStats
+	p.breaker.inherit(previous.breaker)
+	if report := previous.reports.get(); report != nil {
+		p.reports.set(report)
+	}
+	if p.config.Region == previous.config.Region && reflect.DeepEqual(p.config.ProjectDefaults, previous.config.ProjectDefaults) {
+		p.listCache.inherit(previous.listCache)
+	}
+	if p.config.LogLevel == previous.config.LogLevel {
+		p.logger.SetLevel(previous.logger.Level())
+	}
+}
@@ -0,0 +1,77 @@
+package provider
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestInherit(t *testing.T) {
+	client := &fakeCloudRunClient{}
+	previous := newRemovalGuardProvider(t, client, false)
+	previous.breaker = newCircuitBreaker(&Config{BreakerThreshold: 1, BreakerCooldown: time.Hour})
+	previous.breaker.recordFailure("other-project", errors.New("API disabled"), time.Now())
+	if err := previous.SetLogLevel("DEBUG"); err != nil {
+		t.Fatal(err)
+	}
+	configChan := make(chan *DynamicConfig, 1)
+	if err := previous.RunOnce(configChan); err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+	<-configChan
+
+	// The reloaded configuration changes the poll interval only
+	p, err := NewWithClients(&Config{
+		ProjectIDs:       []string{"test-project"},
+		Region:           "us-central1",
+		MaxRouterRemoval: 50,
+		PollInterval:     time.Minute,
+		BreakerThreshold: 1,
+		BreakerCooldown:  time.Hour,
+	}, client, &fakeTokenSource{token: "eyJfake"}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	p.Inherit(previous)
+
+	if p.LogLevel() != "DEBUG" {
+		t.Errorf("Expected the runtime log level kept, got %s", p.LogLevel())
+	}
+	if p.LastReport() != previous.LastReport() {
+		t.Error("Expected the last report kept")
+	}
+	if statuses := p.BreakerStatus(); len(statuses) != 1 || !statuses[0].Open {
+		t.Errorf("Expected the open breaker kept, got %+v", statuses)
+	}
+	if scan, _ := p.listCache.shouldScan("test-project", time.Now()); !scan {
+		t.Error("Expected the project due to be listed again")
+	}
+	if _, _, ok := p.listCache.last("test-project"); !ok {
+		t.Error("Expected the last list kept for the stale route grace period")
+	}
+
+	// The removal guard still compares against the routers previous emitted
+	client.services["projects/test-project/locations/us-central1"] = nil
+	var guardErr *RemovalGuardError
+	if err := p.RunOnce(configChan); !errors.As(err, &guardErr) {
+		t.Fatalf("Expected RemovalGuardError, got %v", err)
+	}
+}
+
+func TestInherit_LogLevelChangedByReload(t *testing.T) {
+	previous, err := NewWithClients(&Config{ProjectIDs: []string{"p"}, Region: "r", LogLevel: "INFO"}, &fakeCloudRunClient{}, &fakeTokenSource{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := previous.SetLogLevel("DEBUG"); err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewWithClients(&Config{ProjectIDs: []string{"p"}, Region: "r", LogLevel: "WARN"}, &fakeCloudRunClient{}, &fakeTokenSource{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Inherit(previous)
+	if p.LogLevel() != "WARN" {
+		t.Errorf("Expected the reloaded log level, got %s", p.LogLevel())
+	}
+}
@@ -0,0 +1,107 @@
+package provider
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/internal/logging"
+)
+
+// RemovalGuardError is returned when a generation would remove more than
+// MaxRouterRemoval percent of the routers the previous one emitted, e.g.
+// because an API outage returned empty service lists. The configuration
+// isn't emitted, so the previous one stays in place.
+type RemovalGuardError struct {
+	Previous int      // Routers in the previous configuration
+	Removed  []string // Routers the new configuration doesn't have
+	Limit    int      // MaxRouterRemoval
+}
+
+func (e *RemovalGuardError) Error() string {
+	return fmt.Sprintf("refusing to remove %d of %d routers (%d%%, limit %d%%): %s; allow router removal to apply it",
+		len(e.Removed), e.Previous, len(e.Removed)*100/e.Previous, e.Limit, summarizeNames(e.Removed, 10))
+}
+
+// summarizeNames lists up to max names, then how many more there are
+func summarizeNames(names []string, max int) string {
+	if len(names) <= max {
+		return strings.Join(names, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(names[:max], ", "), len(names)-max)
+}
+
+// removalGuard remembers the routers of the last emitted configuration
+type removalGuard struct {
+	mu   sync.Mutex
+	last map[string]bool // nil before the first configuration
+}
+
+// check returns a RemovalGuardError if next removes more than limit percent
+// of the last emitted routers
+func (g *removalGuard) check(next *DynamicConfig, limit int) *RemovalGuardError {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if limit <= 0 || len(g.last) == 0 {
+		return nil
+	}
+	var removed []string
+	for name := range g.last {
+		if _, ok := next.HTTP.Routers[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+	if len(removed)*100 <= limit*len(g.last) {
+		return nil
+	}
+	sort.Strings(removed)
+	return &RemovalGuardError{Previous: len(g.last), Removed: removed, Limit: limit}
+}
+
+// accept records config as the last emitted configuration
+func (g *removalGuard) accept(config *DynamicConfig) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.last = make(map[string]bool, len(config.HTTP.Routers))
+	for name := range config.HTTP.Routers {
+		g.last[name] = true
+	}
+}
+
+// SetPrevious tells the removal guard which configuration is live before
+// the provider has emitted one, e.g. the routes file a run-once process is
+// about to replace. It has no effect once a configuration was emitted.
+func (p *Provider) SetPrevious(config *DynamicConfig) {
+	p.removals.mu.Lock()
+	emitted := p.removals.last != nil
+	p.removals.mu.Unlock()
+	if !emitted && config != nil {
+		p.removals.accept(config)
+	}
+}
+
+// guardRemovals refuses config if it removes too many routers, unless
+// AllowRouterRemoval is set
+func (p *Provider) guardRemovals(config *DynamicConfig) error {
+	err := p.removals.check(config, p.config.MaxRouterRemoval)
+	if err == nil {
+		return nil
+	}
+	if p.config.AllowRouterRemoval {
+		p.logger.Warn("Removing routers beyond the limit (allowed by override)",
+			logging.GetCodeField(logging.CodeRouterRemovalAllowed),
+			logging.Int("removed", len(err.Removed)),
+			logging.Int("previous", err.Previous),
+			logging.String("routers", summarizeNames(err.Removed, 10)),
+		)
+		return nil
+	}
+	p.logger.Error("Keeping the previous configuration",
+		logging.GetCodeField(logging.CodeRouterRemovalRefused),
+		logging.Error(err),
+	)
+	return err
+}
@@ -0,0 +1,107 @@
+package provider
+
+import (
+	"errors"
+	"testing"
+
+	"google.golang.org/api/run/v1"
+)
+
+// newRemovalGuardProvider returns a provider listing three labelled services
+// from client, refusing to remove more than half of its routers
+func newRemovalGuardProvider(t *testing.T, client *fakeCloudRunClient, allow bool) *Provider {
+	t.Helper()
+	var services []*run.Service
+	for _, name := range []string{"lab1", "lab2", "lab3"} {
+		services = append(services, newFakeService(name, "https://"+name+".run.app", map[string]string{
+			"traefik_enable":                         "true",
+			"traefik_http_routers_" + name + "_rule": "PathPrefix(`/" + name + "`)",
+		}))
+	}
+	client.services = map[string][]*run.Service{"projects/test-project/locations/us-central1": services}
+	p, err := NewWithClients(&Config{
+		ProjectIDs:         []string{"test-project"},
+		Region:             "us-central1",
+		MaxRouterRemoval:   50,
+		AllowRouterRemoval: allow,
+	}, client, &fakeTokenSource{token: "eyJfake"}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	return p
+}
+
+func TestRemovalGuard_RefusesMassRemoval(t *testing.T) {
+	client := &fakeCloudRunClient{}
+	p := newRemovalGuardProvider(t, client, false)
+	configChan := make(chan *DynamicConfig, 1)
+	if err := p.RunOnce(configChan); err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+	<-configChan
+
+	// One of three routers removed is within the limit
+	services := client.services["projects/test-project/locations/us-central1"]
+	client.services["projects/test-project/locations/us-central1"] = services[1:]
+	if err := p.RunOnce(configChan); err != nil {
+		t.Fatalf("Expected removing 1 of 3 routers to be allowed, got %v", err)
+	}
+	<-configChan
+
+	// An empty list would remove both remaining routers
+	client.services["projects/test-project/locations/us-central1"] = nil
+	err := p.RunOnce(configChan)
+	var guardErr *RemovalGuardError
+	if !errors.As(err, &guardErr) {
+		t.Fatalf("Expected RemovalGuardError, got %v", err)
+	}
+	if guardErr.Previous != 2 || len(guardErr.Removed) != 2 || guardErr.Removed[0] != "lab2" {
+		t.Errorf("Unexpected removal %+v", guardErr)
+	}
+	select {
+	case config := <-configChan:
+		t.Errorf("Expected no configuration to be emitted, got %d routers", len(config.HTTP.Routers))
+	default:
+	}
+}
+
+func TestRemovalGuard_Override(t *testing.T) {
+	client := &fakeCloudRunClient{}
+	p := newRemovalGuardProvider(t, client, true)
+	configChan := make(chan *DynamicConfig, 1)
+	if err := p.RunOnce(configChan); err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+	<-configChan
+
+	client.services = nil
+	if err := p.RunOnce(configChan); err != nil {
+		t.Fatalf("Expected the override to allow the removal, got %v", err)
+	}
+	if config := <-configChan; len(config.HTTP.Routers) != 0 {
+		t.Errorf("Expected no routers, got %d", len(config.HTTP.Routers))
+	}
+}
+
+func TestRemovalGuard_SetPrevious(t *testing.T) {
+	client := &fakeCloudRunClient{}
+	p := newRemovalGuardProvider(t, client, false)
+	client.services = nil
+
+	// The routes file a run-once process replaces counts as the previous configuration
+	previous := NewDynamicConfig()
+	previous.HTTP.Routers["lab1"] = RouterConfig{Rule: "PathPrefix(`/lab1`)"}
+	p.SetPrevious(previous)
+
+	var guardErr *RemovalGuardError
+	if err := p.RunOnce(make(chan *DynamicConfig, 1)); !errors.As(err, &guardErr) {
+		t.Fatalf("Expected RemovalGuardError, got %v", err)
+	}
+}
+
+func TestPrepareConfig_InvalidMaxRouterRemoval(t *testing.T) {
+	err := prepareConfig(&Config{ProjectIDs: []string{"p"}, Region: "r", MaxRouterRemoval: 150})
+	if err == nil {
+		t.Error("Expected a percentage over 100 to be rejected")
+	}
+}
@@ -0,0 +1,61 @@
+package provider
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// generations numbers generation cycles. It is shared by every provider in
+// the process, so IDs keep increasing when the daemon replaces its provider
+// on a config file reload.
+var generations atomic.Uint64
+
+// GenerationReport summarizes one configuration generation cycle
+type GenerationReport struct {
+	Generation  uint64              // ID of the cycle, on its log lines and in the routes file header
+	GeneratedAt time.Time           // When generation finished
+	Duration    time.Duration       // How long discovery and generation took
+	Services    int                 // Cloud Run services discovered across all projects
+	Discovered  []CloudRunService   // The discovered services, for exporters such as the Consul registrar
+	Routers     int                 // Routers in the generated config
+	Middlewares int                 // Middlewares in the generated config
+	Probes      []ProbeResult       // Backend self-test results (empty unless SelfTest is enabled)
+	Skipped     []SkippedService    // Traefik-enabled services left out or degraded, and why
+	Stale       []StaleProject      // Projects that failed to list whose last listed services were kept
+	Departing   []DepartingService  // Services missing from discovery whose routes were kept
+	Deprecated  []Deprecation       // Deprecated settings and label forms in use
+	Unresolved  []UnresolvedBackend // Backends whose host doesn't resolve (empty unless DNSCheck is enabled)
+	Ties        []PriorityTie       // Routers Traefik can't order (same priority and entry point, overlapping rules)
+}
+
+// FailedProbes returns the probes whose backend wasn't reachable with the minted token
+func (r *GenerationReport) FailedProbes() []ProbeResult {
+	var failed []ProbeResult
+	for _, probe := range r.Probes {
+		if probe.Status != ProbeStatusOK {
+			failed = append(failed, probe)
+		}
+	}
+	return failed
+}
+
+// reportStore holds the most recent GenerationReport
+type reportStore struct {
+	mu     sync.RWMutex
+	report *GenerationReport
+}
+
+// set replaces the stored report
+func (s *reportStore) set(report *GenerationReport) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.report = report
+}
+
+// get returns the stored report, or nil before the first generation
+func (s *reportStore) get() *GenerationReport {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.report
+}
@@ -0,0 +1,85 @@
+package provider
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Path rewrite shorthand labels, which define a replacePathRegex middleware
+// <service>-rewrite used by every router of the service, e.g. to expose
+// /v2/foo while the Cloud Run service serves /foo:
+//
+//	traefik_rewrite_regex=^/v2/(.*)
+//	traefik_rewrite_replacement=/$1
+//
+// The rewrite replaces the automatic strip-prefix middleware of lab routes.
+const (
+	rewriteRegexLabel       = "traefik_rewrite_regex"
+	rewriteReplacementLabel = "traefik_rewrite_replacement"
+)
+
+// rewriteMiddlewareName is the name of the middleware the rewrite shorthand
+// labels define
+func rewriteMiddlewareName(serviceName string) string {
+	return serviceName + "-rewrite"
+}
+
+// extractReplacePathRegexConfigs extracts replacePathRegex middleware
+// configurations from labels.
+//
+// Two label forms are supported:
+//   - traefik_http_middlewares_<name>_replacepathregex_<property> defines middleware <name>
+//   - traefik_rewrite_regex and traefik_rewrite_replacement define middleware <service>-rewrite
+//
+// Properties: regex, replacement. Middlewares without both, or whose regex
+// doesn't compile, are dropped with a warning.
+func extractReplacePathRegexConfigs(labels map[string]string, serviceName string) map[string]ReplacePathRegexConfig {
+	configs := make(map[string]ReplacePathRegexConfig)
+
+	for key, value := range labels {
+		var name, property string
+
+		switch {
+		case key == rewriteRegexLabel || key == rewriteReplacementLabel:
+			name = rewriteMiddlewareName(serviceName)
+			property = strings.TrimPrefix(key, "traefik_rewrite_")
+		case strings.HasPrefix(key, "traefik_http_middlewares_"):
+			// Parse: traefik_http_middlewares_<name>_replacepathregex_<property>
+			parts := strings.SplitN(key, "_", 6)
+			if len(parts) < 6 || parts[4] != "replacepathregex" {
+				continue
+			}
+			name = parts[3]
+			property = parts[5]
+		default:
+			continue
+		}
+
+		rewrite := configs[name]
+		switch property {
+		case "regex":
+			rewrite.Regex = value
+		case "replacement":
+			rewrite.Replacement = value
+		default:
+			fmt.Fprintf(os.Stderr, "   WARNING: Unknown replacePathRegex property %q for middleware %s, ignoring\n", property, name)
+		}
+		configs[name] = rewrite
+	}
+
+	for name, rewrite := range configs {
+		if rewrite.Regex == "" || rewrite.Replacement == "" {
+			fmt.Fprintf(os.Stderr, "   WARNING: replacePathRegex middleware %s needs both a regex and a replacement, skipping\n", name)
+			delete(configs, name)
+			continue
+		}
+		if _, err := regexp.Compile(rewrite.Regex); err != nil {
+			fmt.Fprintf(os.Stderr, "   WARNING: Invalid replacePathRegex regex %q for middleware %s: %v, skipping\n", rewrite.Regex, name, err)
+			delete(configs, name)
+		}
+	}
+
+	return configs
+}
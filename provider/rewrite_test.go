@@ -0,0 +1,66 @@
+package provider
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractReplacePathRegexConfigs(t *testing.T) {
+	configs := extractReplacePathRegexConfigs(map[string]string{
+		"traefik_rewrite_regex":                                        "^/v2/(.*)",
+		"traefik_rewrite_replacement":                                  "/$1",
+		"traefik_http_middlewares_legacy_replacepathregex_regex":       "^/old/(.*)",
+		"traefik_http_middlewares_legacy_replacepathregex_replacement": "/new/$1",
+		"traefik_http_middlewares_half_replacepathregex_regex":         "^/half",
+		"traefik_http_middlewares_broken_replacepathregex_regex":       "^/(unclosed",
+		"traefik_http_middlewares_broken_replacepathregex_replacement": "/",
+	}, "api")
+
+	want := map[string]ReplacePathRegexConfig{
+		"api-rewrite": {Regex: "^/v2/(.*)", Replacement: "/$1"},
+		"legacy":      {Regex: "^/old/(.*)", Replacement: "/new/$1"},
+	}
+	if !reflect.DeepEqual(configs, want) {
+		t.Errorf("Expected %+v, got %+v", want, configs)
+	}
+}
+
+func TestProcessService_RewriteShorthand(t *testing.T) {
+	p, err := newProvider(&Config{
+		ProjectIDs:     []string{"test-project"},
+		Region:         "us-central1",
+		TokenInjection: TokenInjectionPlugin,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	config := NewDynamicConfig()
+	err = p.processService(CloudRunService{Name: "lab1", ProjectID: "test-project", URL: "https://lab1.run.app", Labels: map[string]string{
+		"traefik_enable":                 "true",
+		"traefik_http_routers_lab1_rule": "PathPrefix(`/v2/lab1`)",
+		"traefik_rewrite_regex":          "^/v2/lab1/?(.*)",
+		"traefik_rewrite_replacement":    "/$1",
+	}}, config)
+	if err != nil {
+		t.Fatalf("processService failed: %v", err)
+	}
+
+	mw, ok := config.HTTP.Middlewares["lab1-rewrite"]
+	if !ok || mw.ReplacePathRegex == nil || mw.ReplacePathRegex.Replacement != "/$1" {
+		t.Fatalf("Expected the lab1-rewrite replacePathRegex middleware, got %+v", config.HTTP.Middlewares)
+	}
+	middlewares := config.HTTP.Routers["lab1"].Middlewares
+	if !containsString(middlewares, "lab1-rewrite") {
+		t.Errorf("Expected lab1-rewrite on the router, got %v", middlewares)
+	}
+	if containsString(middlewares, "strip-lab1-prefix@file") {
+		t.Errorf("Expected the rewrite to replace the automatic strip-prefix, got %v", middlewares)
+	}
+	if problems := unknownLabels(map[string]string{
+		"traefik_rewrite_regex": "^/v2/(.*)",
+		"traefik_http_middlewares_legacy_replacepathregex_replacement": "/$1",
+	}); len(problems) != 0 {
+		t.Errorf("Expected rewrite labels to be recognized, got %v", problems)
+	}
+}
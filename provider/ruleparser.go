@@ -0,0 +1,280 @@
+package provider
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ruleMatchers are the matchers Traefik accepts in router rules, with their
+// minimum and maximum argument counts (-1 = any) and the index of the
+// argument that is a regular expression (-1 = none). Traefik v2 and v3
+// matcher names are both accepted.
+var ruleMatchers = map[string]struct{ min, max, regexpArg int }{
+	"Host":          {1, -1, -1},
+	"HostHeader":    {1, -1, -1},
+	"HostRegexp":    {1, -1, 0},
+	"Path":          {1, -1, -1},
+	"PathPrefix":    {1, -1, -1},
+	"PathRegexp":    {1, 1, 0},
+	"Method":        {1, -1, -1},
+	"Header":        {2, 2, -1},
+	"HeaderRegexp":  {2, 2, 1},
+	"Headers":       {2, 2, -1},
+	"HeadersRegexp": {2, 2, 1},
+	"Query":         {1, -1, -1},
+	"QueryRegexp":   {2, 2, 1},
+	"ClientIP":      {1, -1, -1},
+}
+
+// Rule expression node operators
+const (
+	ruleOpMatcher = ""
+	ruleOpAnd     = "&&"
+	ruleOpOr      = "||"
+	ruleOpNot     = "!"
+)
+
+// ruleNode is a parsed router rule: a matcher call, or an operator over
+// child nodes
+type ruleNode struct {
+	op       string      // One of the ruleOp constants
+	name     string      // Matcher name (ruleOpMatcher only)
+	args     []string    // Unquoted matcher arguments (ruleOpMatcher only)
+	children []*ruleNode // Operands (&& and || have two or more, ! has one)
+}
+
+// String renders the rule, parenthesizing operands only where needed
+func (n *ruleNode) String() string {
+	switch n.op {
+	case ruleOpMatcher:
+		args := make([]string, len(n.args))
+		for i, arg := range n.args {
+			args[i] = quoteRuleValue(arg)
+		}
+		return n.name + "(" + strings.Join(args, ", ") + ")"
+	case ruleOpNot:
+		return "!" + n.children[0].operand(ruleOpNot)
+	default:
+		parts := make([]string, len(n.children))
+		for i, child := range n.children {
+			parts[i] = child.operand(n.op)
+		}
+		return strings.Join(parts, " "+n.op+" ")
+	}
+}
+
+// operand renders a child of an op node, parenthesized if it binds looser
+func (n *ruleNode) operand(parent string) string {
+	if n.op == ruleOpMatcher || n.op == parent || (n.op == ruleOpAnd && parent == ruleOpOr) || n.op == ruleOpNot {
+		return n.String()
+	}
+	return "(" + n.String() + ")"
+}
+
+// validateRule checks that a router rule parses: known matchers with the
+// right number of quoted arguments, valid regular expressions, and balanced
+// &&, ||, ! and parentheses. Traefik rejects the whole dynamic configuration
+// when one rule is invalid, so bad rules are caught at generation time.
+func validateRule(rule string) error {
+	_, err := parseRule(rule)
+	return err
+}
+
+// parseRule parses a router rule (see validateRule)
+func parseRule(rule string) (*ruleNode, error) {
+	if strings.TrimSpace(rule) == "" {
+		return nil, fmt.Errorf("empty rule")
+	}
+	p := &ruleParser{input: rule}
+	node, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.input) {
+		return nil, p.errorf("unexpected %q", p.input[p.pos:])
+	}
+	return node, nil
+}
+
+// ruleParser is a recursive descent parser for Traefik rule expressions
+type ruleParser struct {
+	input string
+	pos   int
+}
+
+func (p *ruleParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("invalid rule at offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *ruleParser) skipSpace() {
+	for p.pos < len(p.input) && unicode.IsSpace(rune(p.input[p.pos])) {
+		p.pos++
+	}
+}
+
+// consume skips token if it comes next
+func (p *ruleParser) consume(token string) bool {
+	p.skipSpace()
+	if strings.HasPrefix(p.input[p.pos:], token) {
+		p.pos += len(token)
+		return true
+	}
+	return false
+}
+
+// or parses conditions joined with ||
+func (p *ruleParser) or() (*ruleNode, error) {
+	return p.binary(ruleOpOr, p.and)
+}
+
+// and parses conditions joined with &&
+func (p *ruleParser) and() (*ruleNode, error) {
+	return p.binary(ruleOpAnd, p.unary)
+}
+
+// binary parses operands joined with op
+func (p *ruleParser) binary(op string, operand func() (*ruleNode, error)) (*ruleNode, error) {
+	first, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	node := &ruleNode{op: op, children: []*ruleNode{first}}
+	for p.consume(op) {
+		next, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		node.children = append(node.children, next)
+	}
+	if len(node.children) == 1 {
+		return first, nil
+	}
+	return node, nil
+}
+
+// unary parses a negated condition, a parenthesized expression or a matcher
+func (p *ruleParser) unary() (*ruleNode, error) {
+	if p.consume("!") {
+		child, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &ruleNode{op: ruleOpNot, children: []*ruleNode{child}}, nil
+	}
+	if p.consume("(") {
+		node, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if !p.consume(")") {
+			return nil, p.errorf("missing )")
+		}
+		return node, nil
+	}
+	return p.matcher()
+}
+
+// matcher parses Name(arg, ...)
+func (p *ruleParser) matcher() (*ruleNode, error) {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.input) && unicode.IsLetter(rune(p.input[p.pos])) {
+		p.pos++
+	}
+	name := p.input[start:p.pos]
+	if name == "" {
+		if p.pos == len(p.input) {
+			return nil, p.errorf("expected a matcher")
+		}
+		return nil, p.errorf("expected a matcher, got %q", p.input[p.pos:])
+	}
+	spec, ok := ruleMatchers[name]
+	if !ok {
+		return nil, p.errorf("unknown matcher %q", name)
+	}
+	if !p.consume("(") {
+		return nil, p.errorf("expected ( after %s", name)
+	}
+
+	var args []string
+	if !p.consume(")") {
+		for {
+			arg, err := p.stringArg()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if p.consume(")") {
+				break
+			}
+			if !p.consume(",") {
+				return nil, p.errorf("expected , or ) in %s", name)
+			}
+		}
+	}
+
+	if len(args) < spec.min || (spec.max >= 0 && len(args) > spec.max) {
+		return nil, p.errorf("%s takes %s, got %d", name, argCount(spec.min, spec.max), len(args))
+	}
+	for i, arg := range args {
+		// A variadic regexp matcher (v2 HostRegexp) takes only regular expressions
+		if i == spec.regexpArg || (spec.regexpArg == 0 && spec.max < 0) {
+			if _, err := regexp.Compile(arg); err != nil {
+				return nil, p.errorf("%s: %v", name, err)
+			}
+		}
+	}
+	return &ruleNode{op: ruleOpMatcher, name: name, args: args}, nil
+}
+
+// stringArg parses a backtick or double-quoted string
+func (p *ruleParser) stringArg() (string, error) {
+	p.skipSpace()
+	if p.pos >= len(p.input) {
+		return "", p.errorf("expected a quoted string")
+	}
+	switch p.input[p.pos] {
+	case '`':
+		end := strings.IndexByte(p.input[p.pos+1:], '`')
+		if end < 0 {
+			return "", p.errorf("unterminated string")
+		}
+		value := p.input[p.pos+1 : p.pos+1+end]
+		p.pos += end + 2
+		return value, nil
+	case '"':
+		for end := p.pos + 1; end < len(p.input); end++ {
+			if p.input[end] == '\\' {
+				end++
+				continue
+			}
+			if p.input[end] == '"' {
+				value, err := strconv.Unquote(p.input[p.pos : end+1])
+				if err != nil {
+					return "", p.errorf("invalid string: %v", err)
+				}
+				p.pos = end + 1
+				return value, nil
+			}
+		}
+		return "", p.errorf("unterminated string")
+	default:
+		return "", p.errorf("expected a quoted string, got %q", p.input[p.pos:])
+	}
+}
+
+// argCount describes an argument count range for error messages
+func argCount(min, max int) string {
+	switch {
+	case max < 0:
+		return fmt.Sprintf("at least %d argument(s)", min)
+	case min == max:
+		return fmt.Sprintf("%d argument(s)", min)
+	default:
+		return fmt.Sprintf("%d to %d arguments", min, max)
+	}
+}
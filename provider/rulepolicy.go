@@ -0,0 +1,201 @@
+package provider
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// RulePolicy restricts the router rules the services it applies to may
+// generate, e.g. so nothing discovered in a staging project can route /admin
+// or a production host on a shared Traefik.
+//
+// Hosts and paths are checked on what the rule can match (see scopeOf), so
+// quoting, matcher spelling or a shorter prefix can't get around them: a
+// rule without a host (or path) condition, or with one the provider can't
+// analyze (HostRegexp, PathRegexp, negations), can match any host (or path).
+// Allow and Deny are regular expressions matched anywhere in the rule after
+// it is rewritten into canonical form (backquoted values, normalized
+// spacing); prefer the host and path lists, which regular expressions can't
+// express safely.
+type RulePolicy struct {
+	Name       string   `json:"name,omitempty" yaml:"name,omitempty"`             // Shown when a rule is rejected
+	Projects   []string `json:"projects,omitempty" yaml:"projects,omitempty"`     // Glob patterns on project IDs (default: all)
+	Services   []string `json:"services,omitempty" yaml:"services,omitempty"`     // Glob patterns on service names (default: all)
+	AllowHosts []string `json:"allowHosts,omitempty" yaml:"allowHosts,omitempty"` // If set, rules may only match hosts matching these glob patterns
+	DenyHosts  []string `json:"denyHosts,omitempty" yaml:"denyHosts,omitempty"`   // Rules that can match a host matching these glob patterns are rejected
+	AllowPaths []string `json:"allowPaths,omitempty" yaml:"allowPaths,omitempty"` // If set, rules may only match paths under these prefixes
+	DenyPaths  []string `json:"denyPaths,omitempty" yaml:"denyPaths,omitempty"`   // Rules that can match a path under these prefixes are rejected
+	Allow      []string `json:"allow,omitempty" yaml:"allow,omitempty"`           // If set, rules must match one of these
+	Deny       []string `json:"deny,omitempty" yaml:"deny,omitempty"`             // Rules matching one of these are rejected
+
+	allow, deny []*regexp.Regexp
+}
+
+// compiled returns the policy with its patterns compiled
+func (r RulePolicy) compiled() (RulePolicy, error) {
+	patterns := append(append([]string{}, r.Projects...), r.Services...)
+	patterns = append(append(patterns, r.AllowHosts...), r.DenyHosts...)
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return r, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	for _, prefix := range append(append([]string{}, r.AllowPaths...), r.DenyPaths...) {
+		if !strings.HasPrefix(prefix, "/") {
+			return r, fmt.Errorf("invalid path prefix %q: must start with /", prefix)
+		}
+	}
+	compile := func(patterns []string) ([]*regexp.Regexp, error) {
+		res := make([]*regexp.Regexp, len(patterns))
+		for i, pattern := range patterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid rule pattern %q: %w", pattern, err)
+			}
+			res[i] = re
+		}
+		return res, nil
+	}
+	var err error
+	if r.allow, err = compile(r.Allow); err != nil {
+		return r, err
+	}
+	if r.deny, err = compile(r.Deny); err != nil {
+		return r, err
+	}
+	return r, nil
+}
+
+// appliesTo reports whether the policy covers a service
+func (r RulePolicy) appliesTo(service CloudRunService) bool {
+	return matchesAny(r.Projects, service.ProjectID) && matchesAny(r.Services, service.Name)
+}
+
+// matchesAny reports whether name matches one of the glob patterns, or
+// patterns is empty
+func matchesAny(patterns []string, name string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// canonicalRule returns rule in canonical form, or as it is if it doesn't
+// parse
+func canonicalRule(rule string) string {
+	node, err := parseRule(rule)
+	if err != nil {
+		return rule
+	}
+	return node.String()
+}
+
+// check returns why the policy rejects rule, or "" if it doesn't
+func (r RulePolicy) check(rule string) string {
+	name := r.Name
+	if name == "" {
+		name = "rule policy"
+	}
+	if reason := r.checkScope(scopeOf(rule)); reason != "" {
+		return name + " " + reason
+	}
+
+	canonical := canonicalRule(rule)
+	for i, re := range r.deny {
+		if re.MatchString(canonical) {
+			return fmt.Sprintf("%s denies %q", name, r.Deny[i])
+		}
+	}
+	if len(r.allow) == 0 {
+		return ""
+	}
+	for _, re := range r.allow {
+		if re.MatchString(canonical) {
+			return ""
+		}
+	}
+	return fmt.Sprintf("matches none of the patterns %s allows", name)
+}
+
+// checkScope returns why the policy's host and path lists reject a rule with
+// scope, or "" if they don't
+func (r RulePolicy) checkScope(scope ruleScope) string {
+	if len(r.DenyHosts) > 0 || len(r.AllowHosts) > 0 {
+		if scope.hosts == nil {
+			return "requires a Host condition (the rule can match any host)"
+		}
+		for _, host := range scope.hosts {
+			if pattern := matchingPattern(r.DenyHosts, host); pattern != "" {
+				return fmt.Sprintf("denies host %s (%s)", host, pattern)
+			}
+			if len(r.AllowHosts) > 0 && matchingPattern(r.AllowHosts, host) == "" {
+				return fmt.Sprintf("doesn't allow host %s", host)
+			}
+		}
+	}
+	if len(r.DenyPaths) > 0 || len(r.AllowPaths) > 0 {
+		if scope.paths == nil {
+			return "requires a Path or PathPrefix condition (the rule can match any path)"
+		}
+		for _, match := range scope.paths {
+			for _, prefix := range r.DenyPaths {
+				if match.overlaps(pathMatch{path: prefix}) {
+					return fmt.Sprintf("denies paths under %s (the rule matches %s)", prefix, match.path)
+				}
+			}
+			if len(r.AllowPaths) > 0 && !underAnyPrefix(r.AllowPaths, match.path) {
+				return fmt.Sprintf("doesn't allow path %s", match.path)
+			}
+		}
+	}
+	return ""
+}
+
+// matchingPattern returns the first glob pattern host matches, or ""
+func matchingPattern(patterns []string, host string) string {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(strings.ToLower(pattern), host); matched {
+			return pattern
+		}
+	}
+	return ""
+}
+
+// underAnyPrefix reports whether every path starting with p starts with one
+// of prefixes
+func underAnyPrefix(prefixes []string, p string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(p, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// dropDeniedRouters removes the routers of a service whose rule a
+// RulePolicy covering the service rejects. It returns a description of each
+// dropped router.
+func (p *Provider) dropDeniedRouters(service CloudRunService, routerConfigs map[string]RouterConfig) []string {
+	var dropped []string
+	for _, policy := range p.config.RulePolicies {
+		if !policy.appliesTo(service) {
+			continue
+		}
+		for routerName, routerConfig := range routerConfigs {
+			if reason := policy.check(routerConfig.Rule); reason != "" {
+				delete(routerConfigs, routerName)
+				dropped = append(dropped, fmt.Sprintf("router %s: rule %s rejected (%s)", routerName, routerConfig.Rule, reason))
+			}
+		}
+	}
+	sort.Strings(dropped)
+	return dropped
+}
@@ -0,0 +1,137 @@
+package provider
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuild_RulePolicies(t *testing.T) {
+	provider, err := newProvider(&Config{
+		ProjectIDs:     []string{"labs-stg", "labs-prd"},
+		Region:         "us-central1",
+		TokenInjection: TokenInjectionPlugin,
+		RulePolicies: []RulePolicy{
+			{Name: "staging guardrails", Projects: []string{"*-stg"}, Deny: []string{"PathPrefix\\(`/admin", "Host\\(`[^`]*prod\\.example\\.com`"}},
+			{Name: "lab paths", Services: []string{"lab*"}, Allow: []string{"PathPrefix\\(`/lab"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	service := func(project, name string, rules map[string]string) CloudRunService {
+		labels := map[string]string{"traefik_enable": "true"}
+		for router, rule := range rules {
+			labels["traefik_http_routers_"+router+"_rule"] = rule
+		}
+		return CloudRunService{Name: name, ProjectID: project, URL: "https://" + name + ".run.app", Labels: labels}
+	}
+	config, err := provider.Build([]CloudRunService{
+		service("labs-stg", "admin", map[string]string{"admin": "PathPrefix(`/admin`)"}),
+		service("labs-stg", "shop", map[string]string{
+			"shop":      "PathPrefix(`/shop`)",
+			"shop-prod": "Host(`shop.prod.example.com`) && PathPrefix(`/`)",
+		}),
+		service("labs-prd", "admin-prd", map[string]string{"admin-prd": "PathPrefix(`/admin`)"}),
+		service("labs-prd", "lab1", map[string]string{"lab1": "PathPrefix(`/lab1`)", "lab1-api": "PathPrefix(`/api`)"}),
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for _, name := range []string{"shop", "admin-prd", "lab1"} {
+		if _, ok := config.HTTP.Routers[name]; !ok {
+			t.Errorf("Expected router %s", name)
+		}
+	}
+	for _, name := range []string{"admin", "shop-prod", "lab1-api"} {
+		if _, ok := config.HTTP.Routers[name]; ok {
+			t.Errorf("Expected router %s to be rejected", name)
+		}
+	}
+	want := map[string]bool{"admin": false, "shop": true, "lab1": true} // Service: degraded
+	skipped := config.Skipped()
+	if len(skipped) != len(want) {
+		t.Fatalf("Expected %d skipped services, got %+v", len(want), skipped)
+	}
+	for _, s := range skipped {
+		if degraded, ok := want[s.Service]; !ok || s.Reason != SkipReasonRulePolicy || s.Degraded != degraded {
+			t.Errorf("Unexpected skipped service %+v", s)
+		}
+	}
+}
+
+func TestNew_InvalidRulePolicy(t *testing.T) {
+	_, err := New(&Config{ProjectIDs: []string{"p"}, Region: "r", RulePolicies: []RulePolicy{{Deny: []string{"PathPrefix("}}}})
+	if err == nil || !strings.Contains(err.Error(), "rule policy 0") {
+		t.Errorf("Expected invalid rule policy error, got %v", err)
+	}
+}
+
+func TestRulePolicy_CheckCanonicalAndScope(t *testing.T) {
+	policy, err := RulePolicy{
+		Name:      "staging",
+		DenyHosts: []string{"prod.example.com", "*.prod.example.com"},
+		DenyPaths: []string{"/admin"},
+		Deny:      []string{"Host\\(`legacy\\.example\\.com`\\)"},
+	}.compiled()
+	if err != nil {
+		t.Fatalf("Failed to compile policy: %v", err)
+	}
+	tests := []struct {
+		rule   string
+		reject bool
+	}{
+		{"Host(`stg.example.com`) && PathPrefix(`/shop`)", false},
+		{"Host(`stg.example.com`) && Path(`/adm`)", false},
+		// Quoting and case don't get around host patterns or regexps
+		{`Host("prod.example.com") && PathPrefix("/shop")`, true},
+		{"Host(`SHOP.Prod.Example.com`) && PathPrefix(`/shop`)", true},
+		{`Host("legacy.example.com") && PathPrefix("/shop")`, true},
+		// Host conditions that can't be analyzed can match any host
+		{"HostRegexp(`^prod\\.example\\.com$`) && PathPrefix(`/shop`)", true},
+		{"!Host(`stg.example.com`) && PathPrefix(`/shop`)", true},
+		{"Host(`stg.example.com`) || HostRegexp(`.*`) && PathPrefix(`/shop`)", true},
+		{"PathPrefix(`/shop`)", true},
+		// Shorter or catch-all prefixes match paths under /admin too
+		{"Host(`stg.example.com`) && PathPrefix(`/adm`)", true},
+		{"Host(`stg.example.com`) && PathPrefix(`/`)", true},
+		{"Host(`stg.example.com`) && PathPrefix(`/admin/users`)", true},
+		{"Host(`stg.example.com`) && PathRegexp(`^/shop`)", true},
+		{"Host(`stg.example.com`)", true},
+	}
+	for _, tt := range tests {
+		if reason := policy.check(tt.rule); (reason != "") != tt.reject {
+			t.Errorf("check(%s) = %q, expected rejected=%v", tt.rule, reason, tt.reject)
+		}
+	}
+
+	allow, err := RulePolicy{AllowHosts: []string{"*.stg.example.com"}, AllowPaths: []string{"/lab"}}.compiled()
+	if err != nil {
+		t.Fatalf("Failed to compile policy: %v", err)
+	}
+	for rule, reject := range map[string]bool{
+		"Host(`a.stg.example.com`) && PathPrefix(`/lab1`)":                              false,
+		"Host(`a.stg.example.com`, `b.stg.example.com`) && Path(`/lab2/x`)":             false,
+		"Host(`a.stg.example.com`) && PathPrefix(`/la`)":                                true,
+		"Host(`a.stg.example.com`) && (PathPrefix(`/lab1`) || PathPrefix(`/api`))":      true,
+		"(Host(`a.stg.example.com`) || Host(`prod.example.com`)) && PathPrefix(`/lab`)": true,
+		"HostRegexp(`.*\\.stg\\.example\\.com`) && PathPrefix(`/lab1`)":                 true,
+	} {
+		if reason := allow.check(rule); (reason != "") != reject {
+			t.Errorf("check(%s) = %q, expected rejected=%v", rule, reason, reject)
+		}
+	}
+}
+
+func TestRulePolicy_InvalidPatterns(t *testing.T) {
+	for _, policy := range []RulePolicy{
+		{DenyHosts: []string{"["}},
+		{AllowPaths: []string{"admin"}},
+		{Deny: []string{"("}},
+	} {
+		if _, err := policy.compiled(); err == nil {
+			t.Errorf("Expected error for %+v", policy)
+		}
+	}
+}
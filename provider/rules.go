@@ -0,0 +1,188 @@
+package provider
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Rule shorthand labels, composed into a router rule so services don't need
+// raw Traefik rule syntax:
+//
+//	traefik_host=app.example.com          -> Host(`app.example.com`)
+//	traefik_hostregexp=^[a-z]+\.example\.com$ -> HostRegexp(`^[a-z]+\.example\.com$`)
+//	traefik_pathprefix=/api               -> PathPrefix(`/api`)
+//	traefik_pathregexp=^/api/v[0-9]+/     -> PathRegexp(`^/api/v[0-9]+/`)
+//
+// Each may list several values (separated by __, ; or ,). Host conditions are
+// ORed with each other, as are path conditions; the two groups are ANDed. A
+// value starting with ! excludes it instead (traefik_pathprefix=/api__!/api/internal
+// -> PathPrefix(`/api`) && !PathPrefix(`/api/internal`)).
+const (
+	hostLabel       = "traefik_host"
+	hostRegexpLabel = "traefik_hostregexp"
+	pathPrefixLabel = "traefik_pathprefix"
+	pathRegexpLabel = "traefik_pathregexp"
+)
+
+// Request filter labels, ANDed into the rule of every router of the service
+// (or of one router, as traefik_http_routers_<name>_methods, _headers and
+// _query), e.g. to restrict an API to some verbs at the edge:
+//
+//	traefik_methods=GET,POST              -> (Method(`GET`) || Method(`POST`))
+//	traefik_headers=X-Api-Version:2       -> Header(`X-Api-Version`, `2`)
+//	traefik_query=debug=1                 -> Query(`debug`, `1`)
+//
+// Methods are ORed, headers and query parameters are all required. A header
+// without a value must be present (HeaderRegexp(`X-Api-Key`, `.+`)). Values
+// starting with ! are excluded, as for the shorthand labels. Rules use the
+// Traefik v3 matchers and are rewritten for v2 like any other rule.
+const (
+	methodsLabel = "traefik_methods"
+	headersLabel = "traefik_headers"
+	queryLabel   = "traefik_query"
+)
+
+// shorthandRule returns the rule composed from the shorthand labels, or ""
+// if none is set
+func shorthandRule(labels map[string]string) string {
+	prefixes := splitLabelList(labels[pathPrefixLabel])
+	for i, prefix := range prefixes {
+		negated := strings.HasPrefix(prefix, "!")
+		prefix = strings.TrimPrefix(prefix, "!")
+		if !strings.HasPrefix(prefix, "/") {
+			prefix = "/" + prefix
+		}
+		if negated {
+			prefix = "!" + prefix
+		}
+		prefixes[i] = prefix
+	}
+	hosts, notHosts := conditionCalls("Host", splitLabelList(labels[hostLabel]))
+	hostRegexps, notHostRegexps := conditionCalls("HostRegexp", splitLabelList(labels[hostRegexpLabel]))
+	paths, notPaths := conditionCalls("PathPrefix", prefixes)
+	pathRegexps, notPathRegexps := conditionCalls("PathRegexp", splitLabelList(labels[pathRegexpLabel]))
+	hosts = append(hosts, hostRegexps...)
+	paths = append(paths, pathRegexps...)
+	if len(hosts) == 0 && len(paths) == 0 {
+		// Exclusions alone would match almost every request
+		return ""
+	}
+	return composeRule(
+		oneOf(hosts), each(notHosts), each(notHostRegexps),
+		oneOf(paths), each(notPaths), each(notPathRegexps),
+	)
+}
+
+// requestFilterRule returns the rule composed from request filter values
+// (see methodsLabel), or "" if all are empty
+func requestFilterRule(methods, headers, query string) string {
+	methodValues := splitLabelList(methods)
+	for i, method := range methodValues {
+		methodValues[i] = strings.ToUpper(method)
+	}
+	methodCalls, notMethods := conditionCalls("Method", methodValues)
+
+	var headerCalls []string
+	for _, header := range splitLabelList(headers) {
+		negated := strings.HasPrefix(header, "!")
+		name, value, ok := strings.Cut(strings.TrimPrefix(header, "!"), ":")
+		call := "Header(" + quoteRuleValue(strings.TrimSpace(name)) + ", " + quoteRuleValue(strings.TrimSpace(value)) + ")"
+		if !ok {
+			call = "HeaderRegexp(" + quoteRuleValue(strings.TrimSpace(name)) + ", `.+`)"
+		}
+		if negated {
+			call = "!" + call
+		}
+		headerCalls = append(headerCalls, call)
+	}
+
+	var queryCalls []string
+	for _, param := range splitLabelList(query) {
+		negated := strings.HasPrefix(param, "!")
+		key, value, _ := strings.Cut(strings.TrimPrefix(param, "!"), "=")
+		call := "Query(" + quoteRuleValue(key) + ", " + quoteRuleValue(value) + ")"
+		if negated {
+			call = "!" + call
+		}
+		queryCalls = append(queryCalls, call)
+	}
+
+	return composeRule(oneOf(methodCalls), each(notMethods), each(headerCalls), each(queryCalls))
+}
+
+// andRules ANDs rules, skipping empty ones and parenthesizing those with a
+// top-level ||
+func andRules(rules ...string) string {
+	var parts []string
+	for _, rule := range rules {
+		if rule == "" {
+			continue
+		}
+		if node, err := parseRule(rule); err == nil && node.op == ruleOpOr {
+			rule = "(" + rule + ")"
+		}
+		parts = append(parts, rule)
+	}
+	return strings.Join(parts, " && ")
+}
+
+// conditionCalls returns one matcher call per value, separating the values
+// starting with ! into negated calls
+func conditionCalls(matcher string, values []string) (calls, negated []string) {
+	for _, value := range values {
+		if rest, ok := strings.CutPrefix(value, "!"); ok {
+			negated = append(negated, "!"+matcher+"("+quoteRuleValue(rest)+")")
+			continue
+		}
+		calls = append(calls, matcher+"("+quoteRuleValue(value)+")")
+	}
+	return calls, negated
+}
+
+// oneOf makes conditions a single group of alternatives
+func oneOf(conditions []string) [][]string {
+	if len(conditions) == 0 {
+		return nil
+	}
+	return [][]string{conditions}
+}
+
+// each makes every condition a group of its own
+func each(conditions []string) [][]string {
+	groups := make([][]string, len(conditions))
+	for i, condition := range conditions {
+		groups[i] = []string{condition}
+	}
+	return groups
+}
+
+// composeRule ANDs groups of conditions, ORing the conditions within each group
+func composeRule(groupLists ...[][]string) string {
+	var groups [][]string
+	for _, list := range groupLists {
+		groups = append(groups, list...)
+	}
+	conditions := make([]string, len(groups))
+	for i, group := range groups {
+		conditions[i] = anyOf(group, len(groups) > 1)
+	}
+	return strings.Join(conditions, " && ")
+}
+
+// anyOf ORs conditions, parenthesized when combined with other conditions
+func anyOf(conditions []string, combined bool) string {
+	rule := strings.Join(conditions, " || ")
+	if combined && len(conditions) > 1 {
+		rule = "(" + rule + ")"
+	}
+	return rule
+}
+
+// quoteRuleValue quotes a value for a Traefik rule: with backticks, or as a
+// double-quoted string with escapes if the value contains a backtick
+func quoteRuleValue(value string) string {
+	if strings.Contains(value, "`") {
+		return strconv.Quote(value)
+	}
+	return "`" + value + "`"
+}
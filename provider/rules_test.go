@@ -0,0 +1,223 @@
+package provider
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestShorthandRule(t *testing.T) {
+	tests := []struct {
+		name   string
+		labels map[string]string
+		want   string
+	}{
+		{"none", map[string]string{}, ""},
+		{"host", map[string]string{"traefik_host": "app.example.com"}, "Host(`app.example.com`)"},
+		{"prefix without slash", map[string]string{"traefik_pathprefix": "api"}, "PathPrefix(`/api`)"},
+		{
+			"host and prefix",
+			map[string]string{"traefik_host": "app.example.com", "traefik_pathprefix": "/api"},
+			"Host(`app.example.com`) && PathPrefix(`/api`)",
+		},
+		{
+			"several hosts",
+			map[string]string{"traefik_host": "a.example.com__b.example.com", "traefik_pathprefix": "/api"},
+			"(Host(`a.example.com`) || Host(`b.example.com`)) && PathPrefix(`/api`)",
+		},
+		{"backtick escaped", map[string]string{"traefik_pathprefix": "/a`b"}, "PathPrefix(\"/a`b\")"},
+		{
+			"negated prefix",
+			map[string]string{"traefik_pathprefix": "/api__!api/internal"},
+			"PathPrefix(`/api`) && !PathPrefix(`/api/internal`)",
+		},
+		{"only exclusions", map[string]string{"traefik_pathprefix": "!/admin"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := shorthandRule(tt.labels); got != tt.want {
+				t.Errorf("shorthandRule() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractRouterConfigs_Shorthand(t *testing.T) {
+	routers := extractRouterConfigs(map[string]string{
+		"traefik_host":       "app.example.com",
+		"traefik_pathprefix": "/api",
+	}, "app-svc")
+	router, ok := routers["app-svc"]
+	if !ok || len(routers) != 1 {
+		t.Fatalf("Expected one router named after the service, got %v", routers)
+	}
+	if router.Rule != "Host(`app.example.com`) && PathPrefix(`/api`)" {
+		t.Errorf("Unexpected rule %q", router.Rule)
+	}
+
+	// An explicit rule wins over the shorthand
+	routers = extractRouterConfigs(map[string]string{
+		"traefik_host":                  "app.example.com",
+		"traefik_http_routers_app_rule": "PathPrefix(`/explicit`)",
+	}, "app-svc")
+	if routers["app"].Rule != "PathPrefix(`/explicit`)" || len(routers) != 1 {
+		t.Errorf("Expected explicit rule to be kept, got %v", routers)
+	}
+}
+
+func TestShorthandRule_Regexp(t *testing.T) {
+	got := shorthandRule(map[string]string{
+		"traefik_host":       "example.com",
+		"traefik_hostregexp": `^[a-z]+\.example\.com$`,
+		"traefik_pathregexp": `^/api/v[0-9]+/`,
+	})
+	want := "(Host(`example.com`) || HostRegexp(`^[a-z]+\\.example\\.com$`)) && PathRegexp(`^/api/v[0-9]+/`)"
+	if got != want {
+		t.Errorf("shorthandRule() = %q, want %q", got, want)
+	}
+}
+
+func TestRequestFilterRule(t *testing.T) {
+	tests := []struct {
+		name                    string
+		methods, headers, query string
+		want                    string
+	}{
+		{"none", "", "", "", ""},
+		{"methods", "get,POST", "", "", "Method(`GET`) || Method(`POST`)"},
+		{"excluded method", "!DELETE", "", "", "!Method(`DELETE`)"},
+		{
+			"headers and query",
+			"GET__HEAD", "X-Api-Version:2__X-Api-Key__!X-Debug", "tenant=acme",
+			"(Method(`GET`) || Method(`HEAD`)) && Header(`X-Api-Version`, `2`) && HeaderRegexp(`X-Api-Key`, `.+`) && " +
+				"!HeaderRegexp(`X-Debug`, `.+`) && Query(`tenant`, `acme`)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := requestFilterRule(tt.methods, tt.headers, tt.query)
+			if got != tt.want {
+				t.Errorf("requestFilterRule() = %q, want %q", got, tt.want)
+			}
+			if got != "" {
+				if err := validateRule(got); err != nil {
+					t.Errorf("Composed rule %q doesn't validate: %v", got, err)
+				}
+			}
+		})
+	}
+}
+
+func TestExtractRouterConfigs_RequestFilters(t *testing.T) {
+	routers := extractRouterConfigs(map[string]string{
+		"traefik_methods":                        "GET,POST",
+		"traefik_http_routers_api_rule":          "PathPrefix(`/api`) || PathPrefix(`/v2`)",
+		"traefik_http_routers_api_headers":       "X-Api-Version:2",
+		"traefik_http_routers_admin_rule":        "PathPrefix(`/admin`)",
+		"traefik_http_routers_admin_methods":     "!DELETE",
+		"traefik_http_routers_admin_middlewares": "auth",
+	}, "api-svc")
+
+	want := "(PathPrefix(`/api`) || PathPrefix(`/v2`)) && (Method(`GET`) || Method(`POST`)) && Header(`X-Api-Version`, `2`)"
+	if got := routers["api"].Rule; got != want {
+		t.Errorf("api rule = %q, want %q", got, want)
+	}
+	want = "PathPrefix(`/admin`) && (Method(`GET`) || Method(`POST`)) && !Method(`DELETE`)"
+	if got := routers["admin"].Rule; got != want {
+		t.Errorf("admin rule = %q, want %q", got, want)
+	}
+
+	// Rewritten for Traefik v2 like any other rule
+	v2, err := ruleForVersion(routers["api"].Rule, TraefikV2)
+	if err != nil || !strings.Contains(v2, "Headers(`X-Api-Version`, `2`)") {
+		t.Errorf("Expected the v2 header matcher, got %q (%v)", v2, err)
+	}
+}
+
+func TestValidateRule(t *testing.T) {
+	valid := []string{
+		"PathPrefix(`/`)",
+		"Host(`a.example.com`) && PathPrefix(`/api`)",
+		"(Host(`a.example.com`) || Host(`b.example.com`)) && !Path(`/health`)",
+		"HostRegexp(`^[a-z]+\\.example\\.com$`)",
+		"HostRegexp(`{subdomain:[a-z]+}.example.com`)",
+		"PathRegexp(`^/api/v[0-9]+/`)",
+		"Header(`X-Env`, `staging`) && Method(`GET`, `HEAD`)",
+		"HeaderRegexp(`User-Agent`, `^curl/`)",
+		"Path(\"/a`b\")",
+	}
+	for _, rule := range valid {
+		if err := validateRule(rule); err != nil {
+			t.Errorf("validateRule(%q) = %v, want nil", rule, err)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"PathPrefix(`/`",
+		"PathPrefix(/api)",
+		"Hots(`example.com`)",
+		"Host(`a.example.com`) &&",
+		"Host(`a.example.com`) & Path(`/`)",
+		"(Host(`a.example.com`)",
+		"PathRegexp(`^/api/(`)",
+		"Header(`X-Env`)",
+		"PathRegexp(`a`, `b`)",
+		"PathPrefix(`/unterminated)",
+	}
+	for _, rule := range invalid {
+		if err := validateRule(rule); err == nil {
+			t.Errorf("validateRule(%q) = nil, want error", rule)
+		}
+	}
+}
+
+func TestValidateRule_RuleMap(t *testing.T) {
+	for id, rule := range ruleMap {
+		if err := validateRule(rule); err != nil {
+			t.Errorf("ruleMap[%q]: %v", id, err)
+		}
+	}
+}
+
+func TestProcessService_InvalidRule(t *testing.T) {
+	provider, err := newProvider(&Config{
+		ProjectIDs:     []string{"test-project"},
+		Region:         "us-central1",
+		TokenInjection: TokenInjectionPlugin,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	service := CloudRunService{
+		Name:      "test-service",
+		ProjectID: "test-project",
+		URL:       "https://test-service.run.app",
+		Labels: map[string]string{
+			"traefik_enable":                 "true",
+			"traefik_http_routers_good_rule": "PathPrefix(`/good`)",
+			"traefik_http_routers_bad_rule":  "PathRegexp(`^/bad/(`)",
+		},
+	}
+
+	config := NewDynamicConfig()
+	if err := provider.processService(service, config); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, ok := config.HTTP.Routers["bad"]; ok {
+		t.Error("Expected router with invalid rule to be dropped")
+	}
+	if _, ok := config.HTTP.Routers["good"]; !ok {
+		t.Error("Expected valid router to be kept")
+	}
+	if skipped := config.Skipped(); len(skipped) != 1 || skipped[0].Reason != SkipReasonInvalidRule || !skipped[0].Degraded {
+		t.Errorf("Expected one degraded invalid-rule entry, got %+v", skipped)
+	}
+
+	delete(service.Labels, "traefik_http_routers_good_rule")
+	err = provider.processService(service, NewDynamicConfig())
+	if !errors.Is(err, ErrInvalidRule) {
+		t.Errorf("Expected ErrInvalidRule when no router is valid, got %v", err)
+	}
+}
@@ -0,0 +1,200 @@
+package provider
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Traefik versions generated configuration can target
+const (
+	TraefikV2 = "v2"
+	TraefikV3 = "v3"
+)
+
+// v2Placeholder matches a v2 HostRegexp/Path placeholder: {name} or {name:regexp}
+var v2Placeholder = regexp.MustCompile(`\{[A-Za-z_][A-Za-z0-9_]*(:[^{}]*)?\}`)
+
+// ruleForVersion parses a router rule and rewrites the matchers that differ
+// between Traefik v2 and v3 into the syntax of version. Rules that need no
+// rewriting are returned exactly as written. Rules that can't be expressed
+// for version (e.g. PathRegexp for v2) are an error.
+func ruleForVersion(rule, version string) (string, error) {
+	node, err := parseRule(rule)
+	if err != nil {
+		return "", err
+	}
+	changed, err := translateNode(node, version)
+	if err != nil || !changed {
+		return rule, err
+	}
+	return node.String(), nil
+}
+
+// translateNode rewrites a rule tree in place and reports whether anything changed
+func translateNode(node *ruleNode, version string) (bool, error) {
+	if node.op != ruleOpMatcher {
+		changed := false
+		for _, child := range node.children {
+			c, err := translateNode(child, version)
+			if err != nil {
+				return false, err
+			}
+			changed = changed || c
+		}
+		return changed, nil
+	}
+	if version == TraefikV3 {
+		return toV3(node)
+	}
+	return toV2(node)
+}
+
+// v3HeaderMatchers maps the v2 header matchers to their v3 names
+var v3HeaderMatchers = map[string]string{"Headers": "Header", "HeadersRegexp": "HeaderRegexp"}
+
+// toV3 rewrites a v2 matcher for Traefik v3: renamed header matchers, single
+// argument matchers, key/value Query and regexp-based HostRegexp
+func toV3(node *ruleNode) (bool, error) {
+	switch node.name {
+	case "Headers", "HeadersRegexp":
+		node.name = v3HeaderMatchers[node.name]
+		return true, nil
+	case "HostHeader":
+		node.name = "Host"
+		splitArgs(node)
+		return true, nil
+	case "HostRegexp":
+		changed := false
+		for i, arg := range node.args {
+			if v2Placeholder.MatchString(arg) {
+				node.args[i] = placeholderRegexp(arg)
+				changed = true
+			}
+		}
+		return splitArgs(node) || changed, nil
+	case "Path":
+		if len(node.args) == 1 && v2Placeholder.MatchString(node.args[0]) {
+			node.name = "PathRegexp"
+			node.args[0] = placeholderRegexp(node.args[0])
+			return true, nil
+		}
+		return splitArgs(node), nil
+	case "Query":
+		if len(node.args) == 2 && !strings.Contains(node.args[0], "=") {
+			// Already v3 Query(key, value)
+			return false, nil
+		}
+		if len(node.args) == 1 && !strings.Contains(node.args[0], "=") {
+			return false, nil
+		}
+		return splitQueryArgs(node), nil
+	case "Host", "PathPrefix", "Method", "ClientIP":
+		return splitArgs(node), nil
+	}
+	return false, nil
+}
+
+// toV2 rewrites a v3 matcher for Traefik v2
+func toV2(node *ruleNode) (bool, error) {
+	switch node.name {
+	case "Header", "HeaderRegexp":
+		node.name = strings.Replace(node.name, "Header", "Headers", 1)
+		return true, nil
+	case "Query":
+		if len(node.args) == 2 {
+			node.args = []string{node.args[0] + "=" + node.args[1]}
+			return true, nil
+		}
+	case "QueryRegexp":
+		return false, fmt.Errorf("QueryRegexp is not supported by Traefik %s", TraefikV2)
+	case "PathRegexp":
+		return false, fmt.Errorf("PathRegexp is not supported by Traefik %s, use Path with {name:regexp} placeholders", TraefikV2)
+	case "HostRegexp":
+		changed := false
+		for i, arg := range node.args {
+			if v2Placeholder.MatchString(arg) {
+				continue
+			}
+			if strings.ContainsAny(arg, "{}") {
+				return false, fmt.Errorf("HostRegexp %q can't be expressed for Traefik %s", arg, TraefikV2)
+			}
+			node.args[i] = "{host:" + strings.TrimSuffix(strings.TrimPrefix(arg, "^"), "$") + "}"
+			changed = true
+		}
+		return changed, nil
+	}
+	return false, nil
+}
+
+// splitArgs turns a multi-argument matcher into an OR of single-argument
+// matchers (v3 matchers take one value)
+func splitArgs(node *ruleNode) bool {
+	if len(node.args) < 2 {
+		return false
+	}
+	children := make([]*ruleNode, len(node.args))
+	for i, arg := range node.args {
+		children[i] = &ruleNode{op: ruleOpMatcher, name: node.name, args: []string{arg}}
+	}
+	*node = ruleNode{op: ruleOpOr, children: children}
+	return true
+}
+
+// splitQueryArgs turns a v2 Query of key=value pairs into v3
+// Query(key, value) matchers, ANDed if there are several
+func splitQueryArgs(node *ruleNode) bool {
+	children := make([]*ruleNode, len(node.args))
+	for i, arg := range node.args {
+		key, value, _ := strings.Cut(arg, "=")
+		children[i] = &ruleNode{op: ruleOpMatcher, name: "Query", args: []string{key, value}}
+	}
+	if len(children) == 1 {
+		*node = *children[0]
+	} else {
+		*node = ruleNode{op: ruleOpAnd, children: children}
+	}
+	return true
+}
+
+// placeholderRegexp converts a v2 pattern with {name} / {name:regexp}
+// placeholders into an anchored v3 regular expression
+func placeholderRegexp(pattern string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	last := 0
+	for _, loc := range v2Placeholder.FindAllStringIndex(pattern, -1) {
+		b.WriteString(regexp.QuoteMeta(pattern[last:loc[0]]))
+		placeholder := pattern[loc[0]+1 : loc[1]-1]
+		if _, expr, ok := strings.Cut(placeholder, ":"); ok {
+			b.WriteString("(?:" + expr + ")")
+		} else {
+			b.WriteString("[^/.]+")
+		}
+		last = loc[1]
+	}
+	b.WriteString(regexp.QuoteMeta(pattern[last:]))
+	b.WriteString("$")
+	return b.String()
+}
+
+// useTraefikVersion renames middleware types whose name differs between
+// Traefik versions and drops router options v2 doesn't know. Middlewares
+// are generated with v3 names.
+func (c *DynamicConfig) useTraefikVersion(version string) {
+	if version != TraefikV2 {
+		return
+	}
+	for name, router := range c.HTTP.Routers {
+		if router.Observability != nil {
+			router.Observability = nil
+			c.HTTP.Routers[name] = router
+		}
+	}
+	for name, mw := range c.HTTP.Middlewares {
+		if mw.IPAllowList != nil {
+			mw.IPWhiteList, mw.IPAllowList = mw.IPAllowList, nil
+			c.HTTP.Middlewares[name] = mw
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package provider
+
+import "testing"
+
+func TestRuleForVersion(t *testing.T) {
+	tests := []struct {
+		rule, version, want string
+	}{
+		// Rules valid for both versions are kept exactly as written
+		{"PathPrefix(`/lab1`)  &&  Host(`a.example.com`)", TraefikV2, "PathPrefix(`/lab1`)  &&  Host(`a.example.com`)"},
+		{"PathPrefix(`/lab1`)  &&  Host(`a.example.com`)", TraefikV3, "PathPrefix(`/lab1`)  &&  Host(`a.example.com`)"},
+
+		// v2 -> v3
+		{"Headers(`X-Env`, `staging`)", TraefikV3, "Header(`X-Env`, `staging`)"},
+		{"HeadersRegexp(`User-Agent`, `^curl/`)", TraefikV3, "HeaderRegexp(`User-Agent`, `^curl/`)"},
+		{"Host(`a.example.com`, `b.example.com`) && PathPrefix(`/api`)", TraefikV3, "(Host(`a.example.com`) || Host(`b.example.com`)) && PathPrefix(`/api`)"},
+		{"HostHeader(`a.example.com`)", TraefikV3, "Host(`a.example.com`)"},
+		{"Query(`mobile=true`)", TraefikV3, "Query(`mobile`, `true`)"},
+		{"Query(`a=1`, `b=2`)", TraefikV3, "Query(`a`, `1`) && Query(`b`, `2`)"},
+		{"HostRegexp(`{sub:[a-z]+}.example.com`)", TraefikV3, "HostRegexp(`^(?:[a-z]+)\\.example\\.com$`)"},
+		{"Path(`/users/{id:[0-9]+}`)", TraefikV3, "PathRegexp(`^/users/(?:[0-9]+)$`)"},
+		{"!Headers(`X-Debug`, `1`)", TraefikV3, "!Header(`X-Debug`, `1`)"},
+
+		// v3 -> v2
+		{"Header(`X-Env`, `staging`)", TraefikV2, "Headers(`X-Env`, `staging`)"},
+		{"Query(`mobile`, `true`)", TraefikV2, "Query(`mobile=true`)"},
+		{"HostRegexp(`^[a-z]+\\.example\\.com$`)", TraefikV2, "HostRegexp(`{host:[a-z]+\\.example\\.com}`)"},
+	}
+	for _, tt := range tests {
+		got, err := ruleForVersion(tt.rule, tt.version)
+		if err != nil {
+			t.Errorf("ruleForVersion(%q, %s): %v", tt.rule, tt.version, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ruleForVersion(%q, %s) = %q, want %q", tt.rule, tt.version, got, tt.want)
+		}
+		if err := validateRule(got); err != nil {
+			t.Errorf("ruleForVersion(%q, %s) produced an invalid rule: %v", tt.rule, tt.version, err)
+		}
+	}
+}
+
+func TestRuleForVersion_Unsupported(t *testing.T) {
+	for _, rule := range []string{
+		"PathRegexp(`^/api/v[0-9]+/`)",
+		"QueryRegexp(`id`, `^[0-9]+$`)",
+		"HostRegexp(`^[a-z]{3}\\.example\\.com$`)",
+	} {
+		if _, err := ruleForVersion(rule, TraefikV2); err == nil {
+			t.Errorf("ruleForVersion(%q, v2) = nil error, want error", rule)
+		}
+	}
+}
+
+func TestDynamicConfig_UseTraefikVersion(t *testing.T) {
+	config := NewDynamicConfig()
+	config.AddMiddleware("office", MiddlewareConfig{IPAllowList: &IPAllowListConfig{SourceRange: []string{"10.0.0.0/8"}}})
+
+	config.useTraefikVersion(TraefikV3)
+	if config.HTTP.Middlewares["office"].IPAllowList == nil {
+		t.Fatal("Expected ipAllowList to be kept for v3")
+	}
+
+	config.useTraefikVersion(TraefikV2)
+	mw := config.HTTP.Middlewares["office"]
+	if mw.IPAllowList != nil || mw.IPWhiteList == nil || mw.IPWhiteList.SourceRange[0] != "10.0.0.0/8" {
+		t.Errorf("Expected ipWhiteList for v2, got %+v", mw)
+	}
+}
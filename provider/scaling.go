@@ -0,0 +1,57 @@
+package provider
+
+import (
+	"strconv"
+	"time"
+
+	run "google.golang.org/api/run/v1"
+)
+
+// Autoscaling annotations on a service's revision template
+const (
+	minScaleAnnotation = "autoscaling.knative.dev/minScale"
+	maxScaleAnnotation = "autoscaling.knative.dev/maxScale"
+)
+
+// DefaultColdStartMiddleware is attached to the routers of services that
+// scale to zero when ColdStartByScale is set and ColdStartMiddlewares isn't
+const DefaultColdStartMiddleware = "retry-cold-start@file"
+
+// DefaultColdStartTimeout is the response header timeout of services that
+// scale to zero when ColdStartTimeout is not set
+const DefaultColdStartTimeout = 60 * time.Second
+
+// scalingBounds returns the min and max instances of a service's revision
+// template, nil where the annotation is unset or invalid
+func scalingBounds(svc *run.Service) (minScale, maxScale *int) {
+	if svc.Spec == nil || svc.Spec.Template == nil || svc.Spec.Template.Metadata == nil {
+		return nil, nil
+	}
+	annotations := svc.Spec.Template.Metadata.Annotations
+	return scaleAnnotation(annotations, minScaleAnnotation), scaleAnnotation(annotations, maxScaleAnnotation)
+}
+
+// scaleAnnotation parses an instance count annotation
+func scaleAnnotation(annotations map[string]string, key string) *int {
+	value, ok := annotations[key]
+	if !ok {
+		return nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return nil
+	}
+	return &n
+}
+
+// ScalesToZero reports whether the service can have no instances, so
+// requests may hit a cold start. Cloud Run's default minimum is zero.
+func (s CloudRunService) ScalesToZero() bool {
+	return s.MinScale == nil || *s.MinScale == 0
+}
+
+// coldStart reports whether a service gets the cold-start middlewares and
+// timeout: ColdStartByScale is set and the service scales to zero
+func (p *Provider) coldStart(service CloudRunService) bool {
+	return p.config.ColdStartByScale && service.ScalesToZero()
+}
@@ -0,0 +1,117 @@
+package provider
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	run "google.golang.org/api/run/v1"
+)
+
+// withScaling sets autoscaling annotations on a fake service's template
+func withScaling(svc *run.Service, annotations map[string]string) *run.Service {
+	svc.Spec = &run.ServiceSpec{Template: &run.RevisionTemplate{Metadata: &run.ObjectMeta{Annotations: annotations}}}
+	return svc
+}
+
+func intPtr(n int) *int { return &n }
+
+func TestScalingBounds(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		min, max    *int
+	}{
+		{"unset", nil, nil, nil},
+		{"both", map[string]string{minScaleAnnotation: "1", maxScaleAnnotation: "10"}, intPtr(1), intPtr(10)},
+		{"zero min", map[string]string{minScaleAnnotation: "0"}, intPtr(0), nil},
+		{"invalid", map[string]string{minScaleAnnotation: "some", maxScaleAnnotation: "-1"}, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			minScale, maxScale := scalingBounds(withScaling(newFakeService("app", "https://app.run.app", nil), tt.annotations))
+			if !reflect.DeepEqual(minScale, tt.min) || !reflect.DeepEqual(maxScale, tt.max) {
+				t.Errorf("scalingBounds = %v, %v; expected %v, %v", minScale, maxScale, tt.min, tt.max)
+			}
+		})
+	}
+
+	if minScale, maxScale := scalingBounds(newFakeService("app", "https://app.run.app", nil)); minScale != nil || maxScale != nil {
+		t.Errorf("Expected no bounds without a template, got %v, %v", minScale, maxScale)
+	}
+}
+
+func TestListServices_ScalingBounds(t *testing.T) {
+	labels := map[string]string{"traefik_enable": "true", "traefik_http_routers_app_rule": "PathPrefix(`/app`)"}
+	client := &fakeCloudRunClient{services: map[string][]*run.Service{
+		"projects/test-project/locations/us-central1": {
+			withScaling(newFakeService("app", "https://app.run.app", labels), map[string]string{minScaleAnnotation: "2", maxScaleAnnotation: "20"}),
+		},
+	}}
+	p, err := NewWithClients(&Config{ProjectIDs: []string{"test-project"}, Region: "us-central1"}, client, &fakeTokenSource{}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	services, err := p.listServices("test-project", "us-central1")
+	if err != nil || len(services) != 1 {
+		t.Fatalf("listServices = %v, %v", services, err)
+	}
+	if services[0].ScalesToZero() || *services[0].MinScale != 2 || *services[0].MaxScale != 20 {
+		t.Errorf("Unexpected scaling bounds: min %v, max %v", services[0].MinScale, services[0].MaxScale)
+	}
+}
+
+func TestProcessService_ColdStartByScale(t *testing.T) {
+	p, err := NewWithClients(&Config{
+		ProjectIDs:       []string{"test-project"},
+		Region:           "us-central1",
+		ColdStartByScale: true,
+	}, &fakeCloudRunClient{}, &fakeTokenSource{token: "eyJ.test"}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	if len(p.config.DefaultMiddlewares) != 0 {
+		t.Errorf("Expected no default middlewares with ColdStartByScale, got %v", p.config.DefaultMiddlewares)
+	}
+
+	service := func(name string, minScale *int, extra map[string]string) CloudRunService {
+		labels := map[string]string{"traefik_http_routers_" + name + "_rule": "PathPrefix(`/" + name + "`)"}
+		for key, value := range extra {
+			labels[key] = value
+		}
+		return CloudRunService{Name: name, URL: "https://" + name + ".run.app", Labels: labels, MinScale: minScale}
+	}
+
+	config := NewDynamicConfig()
+	for _, svc := range []CloudRunService{
+		service("idle", nil, nil),
+		service("warm", intPtr(1), nil),
+		service("stream", intPtr(0), map[string]string{protocolLabel: ProtocolGRPC}),
+	} {
+		if err := p.processService(svc, config); err != nil {
+			t.Fatalf("processService(%s) failed: %v", svc.Name, err)
+		}
+	}
+
+	hasRetry := func(router string) bool {
+		return strings.Contains(strings.Join(config.HTTP.Routers[router].Middlewares, ","), DefaultColdStartMiddleware)
+	}
+	if !hasRetry("idle") || !hasRetry("stream") || hasRetry("warm") {
+		t.Errorf("Expected the retry middleware only on services scaling to zero: idle %v, stream %v, warm %v",
+			hasRetry("idle"), hasRetry("stream"), hasRetry("warm"))
+	}
+
+	timeout := &ForwardingTimeoutsConfig{ResponseHeaderTimeout: DefaultColdStartTimeout.String()}
+	if lb := config.HTTP.Services["idle"].LoadBalancer; lb.ServersTransport != "idle-timeouts" ||
+		!reflect.DeepEqual(config.HTTP.ServersTransports["idle-timeouts"].ForwardingTimeouts, timeout) {
+		t.Errorf("Expected a cold-start serversTransport for idle, got %q %+v", lb.ServersTransport, config.HTTP.ServersTransports)
+	}
+	// gRPC services keep their transport, with the timeout added
+	grpc := config.HTTP.ServersTransports["stream-grpc"]
+	if grpc.ServerName != "stream.run.app" || !reflect.DeepEqual(grpc.ForwardingTimeouts, timeout) {
+		t.Errorf("Unexpected gRPC transport %+v", grpc)
+	}
+	if lb := config.HTTP.Services["warm"].LoadBalancer; lb.ServersTransport != "" {
+		t.Errorf("Expected no serversTransport for warm, got %q", lb.ServersTransport)
+	}
+}
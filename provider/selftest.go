@@ -0,0 +1,168 @@
+package provider
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/internal/logging"
+)
+
+// Self-test defaults
+const (
+	defaultSelfTestConcurrency = 4
+	defaultSelfTestTimeout     = 5 * time.Second
+)
+
+// selfTestLabel set to "false" excludes a service from backend probing
+const selfTestLabel = "traefik_selftest"
+
+// Probe statuses
+const (
+	ProbeStatusOK              = "ok"              // Backend answered (any non-auth, non-5xx status)
+	ProbeStatusUnauthenticated = "unauthenticated" // 401: token missing or invalid
+	ProbeStatusForbidden       = "forbidden"       // 403: token lacks roles/run.invoker on the service
+	ProbeStatusServerError     = "server-error"    // 5xx from the backend
+	ProbeStatusUnreachable     = "unreachable"     // Request failed (DNS, TLS, timeout)
+)
+
+// ProbeResult is the outcome of probing one generated backend
+type ProbeResult struct {
+	Service    string        // Traefik service name
+	URL        string        // Backend URL probed
+	Routers    []string      // Routers that use this service
+	StatusCode int           // HTTP status (0 if the request failed)
+	Status     string        // One of the ProbeStatus* values
+	Error      string        // Request or token error, if any
+	Latency    time.Duration // Round-trip time
+}
+
+// probeTarget is a backend selected for probing
+type probeTarget struct {
+	service string
+	url     string
+	routers []string
+}
+
+// probeTargets lists the generated load-balancer services to probe, with the
+// routers that reference them. Services whose URL is in skip are left out.
+func probeTargets(config *DynamicConfig, skip map[string]bool) []probeTarget {
+	routersByService := make(map[string][]string)
+	for name, router := range config.HTTP.Routers {
+		routersByService[router.Service] = append(routersByService[router.Service], name)
+	}
+
+	var targets []probeTarget
+	for name, service := range config.HTTP.Services {
+		if len(service.LoadBalancer.Servers) == 0 {
+			continue
+		}
+		url := service.LoadBalancer.Servers[0].URL
+		if !strings.HasPrefix(url, "http") || skip[url] {
+			// h2c:// backends can't be probed with a plain HTTP client
+			continue
+		}
+		routers := routersByService[name]
+		sort.Strings(routers)
+		targets = append(targets, probeTarget{service: name, url: url, routers: routers})
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].service < targets[j].service })
+	return targets
+}
+
+// selfTest probes each generated backend with a minted identity token,
+// at most SelfTestConcurrency at a time, to catch missing invoker IAM early
+func (p *Provider) selfTest(config *DynamicConfig, skip map[string]bool) []ProbeResult {
+	targets := probeTargets(config, skip)
+	results := make([]ProbeResult, len(targets))
+
+	concurrency := p.config.SelfTestConcurrency
+	if concurrency <= 0 {
+		concurrency = defaultSelfTestConcurrency
+	}
+	timeout := p.config.SelfTestTimeout
+	if timeout <= 0 {
+		timeout = defaultSelfTestTimeout
+	}
+	client := &http.Client{
+		Timeout: timeout,
+		// A redirect (e.g. to a sign-in page) already proves the request got past Cloud Run IAM
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target probeTarget) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			token, tokenErr := p.tokenManager.GetToken(target.url)
+			result := probeBackend(client, target, token)
+			if tokenErr != nil && result.Error == "" {
+				result.Error = fmt.Sprintf("token: %v", tokenErr)
+			}
+			results[i] = result
+		}(i, target)
+	}
+	wg.Wait()
+
+	for _, result := range results {
+		if result.Status == ProbeStatusOK {
+			continue
+		}
+		p.logger.Warn("Backend self-test failed",
+			logging.GetCodeField(logging.CodeSelfTestFailed),
+			logging.String("service", result.Service),
+			logging.String("url", result.URL),
+			logging.String("status", result.Status),
+			logging.Int("statusCode", result.StatusCode),
+			logging.String("error", result.Error),
+		)
+	}
+	return results
+}
+
+// probeBackend sends a HEAD request to the backend with the identity token
+// (if any) and classifies the response
+func probeBackend(client *http.Client, target probeTarget, token string) ProbeResult {
+	result := ProbeResult{Service: target.service, URL: target.url, Routers: target.routers}
+
+	req, err := http.NewRequest(http.MethodHead, target.url, nil)
+	if err != nil {
+		result.Status = ProbeStatusUnreachable
+		result.Error = err.Error()
+		return result
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	result.Latency = time.Since(start)
+	if err != nil {
+		result.Status = ProbeStatusUnreachable
+		result.Error = err.Error()
+		return result
+	}
+	resp.Body.Close()
+
+	result.StatusCode = resp.StatusCode
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		result.Status = ProbeStatusUnauthenticated
+	case resp.StatusCode == http.StatusForbidden:
+		result.Status = ProbeStatusForbidden
+	case resp.StatusCode >= 500:
+		result.Status = ProbeStatusServerError
+	default:
+		result.Status = ProbeStatusOK
+	}
+	return result
+}
@@ -0,0 +1,70 @@
+package provider
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestProbeTargets(t *testing.T) {
+	config := NewDynamicConfig()
+	config.AddService("lab1", ServiceConfig{LoadBalancer: LoadBalancerConfig{Servers: []ServerConfig{{URL: "https://lab1.run.app"}}}})
+	config.AddService("lab2", ServiceConfig{LoadBalancer: LoadBalancerConfig{Servers: []ServerConfig{{URL: "https://lab2.run.app"}}}})
+	config.AddRouter("lab1-main", RouterConfig{Rule: "PathPrefix(`/lab1`)", Service: "lab1"})
+	config.AddRouter("lab1-api", RouterConfig{Rule: "PathPrefix(`/lab1/api`)", Service: "lab1"})
+
+	targets := probeTargets(config, map[string]bool{"https://lab2.run.app": true})
+	if len(targets) != 1 {
+		t.Fatalf("Expected 1 target (lab2 opted out), got %d", len(targets))
+	}
+	if targets[0].service != "lab1" || len(targets[0].routers) != 2 {
+		t.Errorf("Expected lab1 with 2 routers, got %+v", targets[0])
+	}
+}
+
+func TestProbeBackend_Status(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		expected string
+	}{
+		{"ok", http.StatusOK, ProbeStatusOK},
+		{"not found still reachable", http.StatusNotFound, ProbeStatusOK},
+		{"unauthenticated", http.StatusUnauthorized, ProbeStatusUnauthenticated},
+		{"missing invoker", http.StatusForbidden, ProbeStatusForbidden},
+		{"server error", http.StatusBadGateway, ProbeStatusServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotAuth string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotAuth = r.Header.Get("Authorization")
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			result := probeBackend(server.Client(), probeTarget{service: "svc", url: server.URL}, "eyJtoken")
+			if result.Status != tt.expected {
+				t.Errorf("Expected status %q, got %q", tt.expected, result.Status)
+			}
+			if result.StatusCode != tt.status {
+				t.Errorf("Expected status code %d, got %d", tt.status, result.StatusCode)
+			}
+			if gotAuth != "Bearer eyJtoken" {
+				t.Errorf("Expected bearer token to be sent, got %q", gotAuth)
+			}
+		})
+	}
+}
+
+func TestProbeBackend_Unreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	result := probeBackend(http.DefaultClient, probeTarget{service: "svc", url: url}, "")
+	if result.Status != ProbeStatusUnreachable || result.Error == "" {
+		t.Errorf("Expected unreachable with error, got %+v", result)
+	}
+}
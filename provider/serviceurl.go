@@ -0,0 +1,79 @@
+package provider
+
+import (
+	"sync"
+
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/internal/logging"
+)
+
+// urlMemory remembers the last URL each service was routed to, so a service
+// whose URL goes missing (e.g. while it is being redeployed) keeps its routes
+type urlMemory struct {
+	mu   sync.Mutex
+	urls map[string]string
+}
+
+// newURLMemory creates an empty URL memory
+func newURLMemory() *urlMemory {
+	return &urlMemory{urls: make(map[string]string)}
+}
+
+// remember records url as the last URL of the service with key
+func (m *urlMemory) remember(key, url string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.urls[key] = url
+}
+
+// last returns the last URL recorded for key, or ""
+func (m *urlMemory) last(key string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.urls[key]
+}
+
+// retain forgets the services that were not seen in the last poll
+func (m *urlMemory) retain(seen map[string]bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key := range m.urls {
+		if !seen[key] {
+			delete(m.urls, key)
+		}
+	}
+}
+
+// gateURL keeps a service without a URL (Cloud Run hasn't populated
+// Status.Url yet) from producing a load balancer with an empty server URL,
+// which makes Traefik reject the whole dynamic configuration. It returns the
+// service routed to its last known URL if it had one, and reports whether
+// the service is routed. Skipped and degraded services are recorded in config.
+func (p *Provider) gateURL(service CloudRunService, config *DynamicConfig) (CloudRunService, bool) {
+	key := fragmentKey(service)
+	if service.URL != "" {
+		p.urls.remember(key, service.URL)
+		return service, true
+	}
+
+	if last := p.urls.last(key); last != "" {
+		p.logger.Warn("Service has no URL, routing to its last known URL",
+			logging.GetCodeField(logging.CodeServiceNoURL),
+			logging.String("service", service.Name),
+			logging.String("project", service.ProjectID),
+			logging.String("url", last),
+		)
+		config.skip(SkippedService{Service: service.Name, Project: service.ProjectID, Reason: SkipReasonNoURL,
+			Detail: "routing to last known URL " + last, Degraded: true})
+		service.URL = last
+		return service, true
+	}
+
+	p.logger.Warn("Skipping service (no URL yet)",
+		logging.GetCodeField(logging.CodeServiceNoURL),
+		logging.String("service", service.Name),
+		logging.String("project", service.ProjectID),
+	)
+	config.skip(SkippedService{Service: service.Name, Project: service.ProjectID, Reason: SkipReasonNoURL,
+		Detail: "Cloud Run hasn't assigned the service a URL yet"})
+	return service, false
+}
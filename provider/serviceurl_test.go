@@ -0,0 +1,71 @@
+package provider
+
+import (
+	"testing"
+)
+
+func TestBuild_ServiceWithoutURL(t *testing.T) {
+	labels := func(name string) map[string]string {
+		return map[string]string{"traefik_enable": "true", "traefik_http_routers_" + name + "_rule": "PathPrefix(`/" + name + "`)"}
+	}
+	provider, err := newProvider(&Config{
+		ProjectIDs:     []string{"test-project"},
+		Region:         "us-central1",
+		TokenInjection: TokenInjectionPlugin,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	// A service still deploying is skipped rather than given an empty backend
+	config, err := provider.Build([]CloudRunService{
+		{Name: "lab1", ProjectID: "test-project", URL: "https://lab1.run.app", Labels: labels("lab1")},
+		{Name: "deploying", ProjectID: "test-project", Labels: labels("deploying")},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, ok := config.HTTP.Routers["deploying"]; ok {
+		t.Error("Expected the service without a URL to have no router")
+	}
+	for name, service := range config.HTTP.Services {
+		for _, server := range service.LoadBalancer.Servers {
+			if server.URL == "" {
+				t.Errorf("Service %s has an empty server URL", name)
+			}
+		}
+	}
+	if skipped := config.Skipped(); len(skipped) != 1 || skipped[0].Service != "deploying" ||
+		skipped[0].Reason != SkipReasonNoURL || skipped[0].Degraded {
+		t.Errorf("Expected deploying to be skipped with reason no-url, got %+v", skipped)
+	}
+
+	// A service whose URL goes missing keeps routing to its last known URL
+	config, err = provider.Build([]CloudRunService{
+		{Name: "lab1", ProjectID: "test-project", Labels: labels("lab1")},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	service, ok := config.HTTP.Services["lab1"]
+	if !ok || len(service.LoadBalancer.Servers) != 1 || service.LoadBalancer.Servers[0].URL != "https://lab1.run.app" {
+		t.Errorf("Expected lab1 to keep its last known URL, got %+v", service)
+	}
+	if skipped := config.Skipped(); len(skipped) != 1 || !skipped[0].Degraded {
+		t.Errorf("Expected lab1 to be reported as degraded, got %+v", skipped)
+	}
+
+	// Services that disappear are forgotten
+	if _, err := provider.Build(nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	config, err = provider.Build([]CloudRunService{
+		{Name: "lab1", ProjectID: "test-project", Labels: labels("lab1")},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, ok := config.HTTP.Services["lab1"]; ok {
+		t.Error("Expected the last known URL to be forgotten once the service disappeared")
+	}
+}
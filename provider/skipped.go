@@ -0,0 +1,86 @@
+package provider
+
+import (
+	"errors"
+	"sort"
+)
+
+// Reasons a Traefik-enabled service is missing from, or only partly
+// configured in, the generated configuration
+const (
+	SkipReasonFiltered       = "filtered"         // Rejected by INCLUDE_SERVICES / EXCLUDE_SERVICES
+	SkipReasonNoRouterLabels = "no-router-labels" // traefik_enable=true but no router labels
+	SkipReasonTokenFailure   = "token-failure"    // Identity token couldn't be fetched
+	SkipReasonErrorBudget    = "error-budget"     // Cooling down after repeated failures
+	SkipReasonInvalid        = "invalid"          // Labels couldn't be turned into configuration
+	SkipReasonInvalidRule    = "invalid-rule"     // A router was dropped because its rule doesn't parse (degraded)
+	SkipReasonRouterConflict = "router-conflict"  // Another service defines the same router
+	SkipReasonNoAuth         = "no-auth"          // Routed without an auth middleware (degraded)
+	SkipReasonNotReady       = "not-ready"        // Ready condition isn't True (degraded when failing over to an older revision)
+	SkipReasonNoTraffic      = "no-traffic"       // Serves 0% of traffic (degraded when deprioritized)
+	SkipReasonNoURL          = "no-url"           // Cloud Run hasn't assigned a URL yet (degraded when routed to the last known URL)
+	SkipReasonMaintenance    = "maintenance"      // In maintenance mode, routed to the maintenance service (degraded)
+	SkipReasonCatchAll       = "catch-all"        // A router matching every request was dropped (degraded when others remain)
+	SkipReasonRulePolicy     = "rule-policy"      // A rule policy rejected a router (degraded when others remain)
+)
+
+// SkippedService is a Traefik-enabled service that was left out of the
+// generated configuration, or routed in a degraded way
+type SkippedService struct {
+	Service  string // Cloud Run service name
+	Project  string // Project ID
+	Reason   string // One of the SkipReason constants
+	Detail   string // Error or router name, for operators
+	Degraded bool   // The service is still routed, but not as configured
+}
+
+// Skipped returns the services skipped or degraded while generating the
+// configuration, sorted by project and service
+func (c *DynamicConfig) Skipped() []SkippedService {
+	return c.skipped
+}
+
+// skip records a skipped or degraded service, redacting credentials and
+// addresses an error may have put in its detail
+func (c *DynamicConfig) skip(skipped SkippedService) {
+	skipped.Detail = c.redaction().String(skipped.Detail)
+	c.skipped = append(c.skipped, skipped)
+}
+
+// skipReason classifies a processService error
+func skipReason(err error) string {
+	var tokenErr *TokenError
+	switch {
+	case errors.As(err, &tokenErr):
+		return SkipReasonTokenFailure
+	case errors.Is(err, ErrNoRouterLabels):
+		return SkipReasonNoRouterLabels
+	case errors.Is(err, ErrCatchAllRule):
+		return SkipReasonCatchAll
+	case errors.Is(err, ErrRulePolicy):
+		return SkipReasonRulePolicy
+	default:
+		return SkipReasonInvalid
+	}
+}
+
+// sortSkipped fills in missing projects from the discovered services and
+// orders the skipped services for stable output
+func (c *DynamicConfig) sortSkipped(services []CloudRunService) {
+	projects := make(map[string]string, len(services))
+	for _, service := range services {
+		projects[service.Name] = service.ProjectID
+	}
+	for i := range c.skipped {
+		if c.skipped[i].Project == "" {
+			c.skipped[i].Project = projects[c.skipped[i].Service]
+		}
+	}
+	sort.SliceStable(c.skipped, func(i, j int) bool {
+		a, b := c.skipped[i], c.skipped[j]
+		if a.Project != b.Project {
+			return a.Project < b.Project
+		}
+		return a.Service < b.Service
+	})
+}
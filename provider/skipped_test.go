@@ -0,0 +1,69 @@
+package provider
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestBuild_SkippedServices(t *testing.T) {
+	provider, err := newProvider(&Config{
+		ProjectIDs:      []string{"test-project"},
+		Region:          "us-central1",
+		TokenInjection:  TokenInjectionPlugin,
+		ExcludeServices: []string{"gamma"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	enabled := func(name string, labels map[string]string) CloudRunService {
+		labels["traefik_enable"] = "true"
+		return CloudRunService{Name: name, ProjectID: "test-project", URL: "https://" + name + ".run.app", Labels: labels}
+	}
+	services := []CloudRunService{
+		enabled("alpha", map[string]string{"traefik_http_routers_shared_rule": "PathPrefix(`/a`)"}),
+		enabled("beta", map[string]string{"traefik_http_routers_shared_rule": "PathPrefix(`/b`)"}),
+		enabled("gamma", map[string]string{"traefik_http_routers_gamma_rule": "PathPrefix(`/g`)"}),
+		enabled("delta", map[string]string{}),
+		{Name: "epsilon", ProjectID: "test-project", Labels: map[string]string{}},
+	}
+
+	config, err := provider.Build(services)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := []struct{ service, reason string }{
+		{"alpha", SkipReasonRouterConflict},
+		{"delta", SkipReasonNoRouterLabels},
+		{"gamma", SkipReasonFiltered},
+	}
+	skipped := config.Skipped()
+	if len(skipped) != len(want) {
+		t.Fatalf("Expected %d skipped services, got %+v", len(want), skipped)
+	}
+	for i, w := range want {
+		if skipped[i].Service != w.service || skipped[i].Reason != w.reason {
+			t.Errorf("Skipped[%d]: expected %s/%s, got %s/%s", i, w.service, w.reason, skipped[i].Service, skipped[i].Reason)
+		}
+		if skipped[i].Project != "test-project" {
+			t.Errorf("Skipped[%d]: expected project test-project, got %q", i, skipped[i].Project)
+		}
+	}
+}
+
+func TestSkipReason(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{&TokenError{Service: "svc", Err: errors.New("boom")}, SkipReasonTokenFailure},
+		{ErrNoRouterLabels, SkipReasonNoRouterLabels},
+		{errors.New("bad url"), SkipReasonInvalid},
+	}
+	for _, tt := range tests {
+		if got := skipReason(tt.err); got != tt.want {
+			t.Errorf("skipReason(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package provider
+
+import (
+	"time"
+
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/internal/logging"
+)
+
+// StaleProject is a project that couldn't be listed whose services from its
+// last successful list were kept (see Config.StaleRouteGracePeriod)
+type StaleProject struct {
+	Project  string
+	ListedAt time.Time // When the retained services were listed
+	Services int       // How many services were retained
+	Error    string    // Why the project couldn't be listed this time
+}
+
+// retainStale returns the services of projectID's last successful list if
+// it is within the stale route grace period, so that a project failing to
+// list keeps its routes instead of vanishing for a poll
+func (p *Provider) retainStale(projectID string, reason string, now time.Time) ([]CloudRunService, *StaleProject, bool) {
+	if p.config.StaleRouteGracePeriod <= 0 {
+		return nil, nil, false
+	}
+	services, listedAt, ok := p.listCache.last(projectID)
+	if !ok || now.Sub(listedAt) > p.config.StaleRouteGracePeriod {
+		return nil, nil, false
+	}
+
+	p.logger.Warn("Keeping stale routes for project",
+		logging.GetCodeField(logging.CodeStaleRoutesRetained),
+		logging.String("project", projectID),
+		logging.Int("services", len(services)),
+		logging.Duration("age", now.Sub(listedAt)),
+		logging.String("reason", reason),
+	)
+	return services, &StaleProject{Project: projectID, ListedAt: listedAt, Services: len(services), Error: reason}, true
+}
@@ -0,0 +1,57 @@
+package provider
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	run "google.golang.org/api/run/v1"
+)
+
+func TestStaleRouteGracePeriod(t *testing.T) {
+	client := &fakeCloudRunClient{services: map[string][]*run.Service{
+		"projects/test-project/locations/us-central1": {
+			newFakeService("api", "https://api.run.app", map[string]string{
+				"traefik_enable":                "true",
+				"traefik_http_routers_api_rule": "PathPrefix(`/api`)",
+			}),
+		},
+	}}
+	p, err := NewWithClients(&Config{
+		ProjectIDs:            []string{"test-project"},
+		Region:                "us-central1",
+		StaleRouteGracePeriod: 10 * time.Minute,
+	}, client, &fakeTokenSource{token: "eyJ.test"}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	poll := func() *DynamicConfig {
+		t.Helper()
+		configChan := make(chan *DynamicConfig, 1)
+		if err := p.RunOnce(configChan); err != nil {
+			t.Fatalf("RunOnce failed: %v", err)
+		}
+		return <-configChan
+	}
+
+	if _, ok := poll().HTTP.Routers["api"]; !ok || len(p.LastReport().Stale) != 0 {
+		t.Fatalf("Expected a fresh api router (stale %+v)", p.LastReport().Stale)
+	}
+
+	client.err = errors.New("quota exceeded")
+	if _, ok := poll().HTTP.Routers["api"]; !ok {
+		t.Error("Expected the api router to be kept while the project fails to list")
+	}
+	stale := p.LastReport().Stale
+	if len(stale) != 1 || stale[0].Project != "test-project" || stale[0].Services != 1 || stale[0].Error == "" {
+		t.Errorf("Expected test-project to be reported stale, got %+v", stale)
+	}
+
+	// Once the last list is older than the grace period the routes go
+	services, _, _ := p.listCache.last("test-project")
+	p.listCache.store("test-project", services, time.Now().Add(-time.Hour))
+	if _, ok := poll().HTTP.Routers["api"]; ok || len(p.LastReport().Stale) != 0 {
+		t.Errorf("Expected routes to be dropped after the grace period (stale %+v)", p.LastReport().Stale)
+	}
+}
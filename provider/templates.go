@@ -0,0 +1,84 @@
+package provider
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// labelTemplateData is what templates in label values can refer to, e.g.
+// Host(`{{ .Service }}.{{ env "DOMAIN" }}`)
+type labelTemplateData struct {
+	Service  string            // Cloud Run service name
+	Project  string            // Project ID
+	Region   string            // Region (cluster location for Anthos services)
+	URL      string            // Service URL
+	Host     string            // Host of the service URL
+	Revision string            // Latest ready revision
+	Platform string            // "managed" or "gke"
+	Labels   map[string]string // Raw (unexpanded) labels
+}
+
+// labelTemplateFuncs are the functions available to label templates
+var labelTemplateFuncs = template.FuncMap{
+	"env": os.Getenv,
+	// default returns value, or fallback when value is empty: {{ env "DOMAIN" | default "example.com" }}
+	"default": func(fallback, value string) string {
+		if value == "" {
+			return fallback
+		}
+		return value
+	},
+}
+
+// expandLabelTemplates evaluates Go templates in a service's label values so
+// labels can stay generic across environments. Values without "{{" are kept
+// as they are; the original map is returned when nothing needs expanding.
+// Templates are evaluated at generation time, so with IncrementalUpdates an
+// environment change is picked up when cached fragments expire.
+func expandLabelTemplates(service CloudRunService) (map[string]string, error) {
+	var data *labelTemplateData
+	var expanded map[string]string
+
+	for key, value := range service.Labels {
+		if !strings.Contains(value, "{{") {
+			continue
+		}
+		if data == nil {
+			data = &labelTemplateData{
+				Service:  service.Name,
+				Project:  service.ProjectID,
+				Region:   service.Region,
+				URL:      service.URL,
+				Revision: service.Revision,
+				Platform: service.Platform,
+				Labels:   service.Labels,
+			}
+			if parsed, err := url.Parse(service.URL); err == nil {
+				data.Host = parsed.Hostname()
+			}
+			expanded = make(map[string]string, len(service.Labels))
+			for k, v := range service.Labels {
+				expanded[k] = v
+			}
+		}
+
+		tmpl, err := template.New(key).Funcs(labelTemplateFuncs).Option("missingkey=error").Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template in label %s: %w", key, err)
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return nil, fmt.Errorf("failed to expand template in label %s: %w", key, err)
+		}
+		expanded[key] = buf.String()
+	}
+
+	if expanded == nil {
+		return service.Labels, nil
+	}
+	return expanded, nil
+}
@@ -0,0 +1,94 @@
+package provider
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExpandLabelTemplates(t *testing.T) {
+	t.Setenv("DOMAIN", "staging.example.com")
+
+	service := CloudRunService{
+		Name:      "api",
+		ProjectID: "my-project",
+		Region:    "us-central1",
+		URL:       "https://api-abc123-uc.a.run.app",
+		Labels: map[string]string{
+			"traefik_enable":                "true",
+			"traefik_http_routers_api_rule": "Host(`{{ .Service }}.{{ env \"DOMAIN\" }}`)",
+			"traefik_http_routers_api_pass": "{{ .Host }}",
+			"traefik_http_routers_api_prio": "{{ env \"PRIORITY\" | default \"100\" }}",
+		},
+	}
+
+	labels, err := expandLabelTemplates(service)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	tests := map[string]string{
+		"traefik_enable":                "true",
+		"traefik_http_routers_api_rule": "Host(`api.staging.example.com`)",
+		"traefik_http_routers_api_pass": "api-abc123-uc.a.run.app",
+		"traefik_http_routers_api_prio": "100",
+	}
+	for key, want := range tests {
+		if labels[key] != want {
+			t.Errorf("%s: expected %q, got %q", key, want, labels[key])
+		}
+	}
+	if !strings.Contains(service.Labels["traefik_http_routers_api_rule"], "{{") {
+		t.Error("Expected the service's labels to be left unchanged")
+	}
+}
+
+func TestExpandLabelTemplates_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"parse error", "Host(`{{ .Service `)"},
+		{"unknown field", "Host(`{{ .Missing }}`)"},
+		{"unknown function", "Host(`{{ lookup \"x\" }}`)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := expandLabelTemplates(CloudRunService{
+				Name:   "api",
+				Labels: map[string]string{"traefik_http_routers_api_rule": tt.value},
+			})
+			if err == nil || !strings.Contains(err.Error(), "traefik_http_routers_api_rule") {
+				t.Errorf("Expected error naming the label, got %v", err)
+			}
+		})
+	}
+}
+
+func TestProcessService_LabelTemplates(t *testing.T) {
+	t.Setenv("DOMAIN", "example.com")
+
+	provider, err := newProvider(&Config{
+		ProjectIDs: []string{"test-project"},
+		Region:     "us-central1",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	service := CloudRunService{
+		Name:      "test-service",
+		ProjectID: "test-project",
+		URL:       "https://test-service.run.app",
+		Labels: map[string]string{
+			"traefik_enable":                 "true",
+			"traefik_http_routers_test_rule": "Host(`{{ .Service }}.{{ env \"DOMAIN\" }}`)",
+		},
+	}
+
+	dynamicConfig := NewDynamicConfig()
+	if err := provider.processService(service, dynamicConfig); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := dynamicConfig.HTTP.Routers["test"].Rule; got != "Host(`test-service.example.com`)" {
+		t.Errorf("Expected expanded rule, got %q", got)
+	}
+}
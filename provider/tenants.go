@@ -0,0 +1,181 @@
+package provider
+
+import "strings"
+
+// TenantLabel assigns a service to a tenant in multi-tenant output.
+// Services without it belong to the tenant named after their project.
+const TenantLabel = "traefik_tenant"
+
+// Tenants maps each service name to its tenant: the traefik_tenant label,
+// or the service's project ID
+func Tenants(services []CloudRunService) map[string]string {
+	tenants := make(map[string]string, len(services))
+	for _, service := range services {
+		tenant := strings.TrimSpace(service.Labels[TenantLabel])
+		if tenant == "" {
+			tenant = service.ProjectID
+		}
+		tenants[service.Name] = tenant
+	}
+	return tenants
+}
+
+// SplitTenants partitions the configuration into one DynamicConfig per
+// tenant, keyed by tenant name. tenants maps Cloud Run service names to
+// tenants (see Tenants); each router goes to the tenant of the service that
+// defined it and is renamed <tenant>-<router>, together with the services,
+// serversTransports and middlewares it references, so tenants can share a
+// Traefik instance without name collisions. Middlewares shared by several
+// tenants are copied into each.
+//
+// Objects not generated from a tenant's service (Traefik internal routers,
+// the HOME_INDEX_URL fallback, unreferenced middlewares) keep their names and
+// are returned under the "" key, together with the skipped services.
+func (c *DynamicConfig) SplitTenants(tenants map[string]string) map[string]*DynamicConfig {
+	parts := map[string]*DynamicConfig{"": c.derived()}
+	shared := &namespacer{src: c, dst: parts[""], used: make(map[string]bool)}
+	usedByTenant := make(map[string]bool)
+
+	for name, router := range c.HTTP.Routers {
+		tenant := tenants[c.routerSources[name]]
+		if tenant == "" {
+			shared.router(name, router)
+			continue
+		}
+		if parts[tenant] == nil {
+			parts[tenant] = c.derived()
+		}
+		n := &namespacer{src: c, dst: parts[tenant], prefix: tenant + "-", used: usedByTenant}
+		n.router(name, router)
+	}
+
+	// Keep everything no tenant took over in the shared configuration
+	for name, service := range c.HTTP.Services {
+		if !usedByTenant["service/"+name] {
+			parts[""].HTTP.Services[name] = service
+		}
+	}
+	for name, mw := range c.HTTP.Middlewares {
+		if !usedByTenant["middleware/"+name] {
+			parts[""].HTTP.Middlewares[name] = mw
+		}
+	}
+	for name, transport := range c.HTTP.ServersTransports {
+		if !usedByTenant["transport/"+name] {
+			parts[""].AddServersTransport(name, transport)
+		}
+	}
+	parts[""].skipped = c.skipped
+	for _, part := range parts {
+		part.generation = c.generation
+	}
+
+	return parts
+}
+
+// WithNamePrefix returns a copy of the configuration with every router,
+// service, serversTransport and middleware renamed prefix+name and the
+// references between them updated. References qualified with a provider
+// (e.g. retry-cold-start@file) are kept.
+func (c *DynamicConfig) WithNamePrefix(prefix string) *DynamicConfig {
+	prefixed := c.derived()
+	n := &namespacer{src: c, dst: prefixed, prefix: prefix, used: make(map[string]bool)}
+	for name, router := range c.HTTP.Routers {
+		n.router(name, router)
+	}
+	for name := range c.HTTP.Services {
+		n.service(name)
+	}
+	for name := range c.HTTP.Middlewares {
+		n.middleware(name)
+	}
+	for name, transport := range c.HTTP.ServersTransports {
+		if !n.used["transport/"+name] {
+			prefixed.AddServersTransport(prefix+name, transport)
+		}
+	}
+	prefixed.skipped = c.skipped
+	prefixed.deprecations = c.deprecations
+	prefixed.ties = c.ties
+	prefixed.generation = c.generation
+	return prefixed
+}
+
+// derived returns an empty configuration with the settings of c (conflict
+// policy, environment affixes, redaction), for configurations rebuilt from it
+func (c *DynamicConfig) derived() *DynamicConfig {
+	d := NewDynamicConfig()
+	d.conflictPolicy = c.conflictPolicy
+	d.envAffixes = c.envAffixes
+	d.redactor = c.redactor
+	return d
+}
+
+// namespacer copies routers from src to dst, renaming them and the services,
+// serversTransports and middlewares they reference to prefix+name.
+// References qualified with a provider (e.g. @file, @internal) and names src
+// doesn't define are kept as they are. used records the copied objects as
+// "<kind>/<name>".
+type namespacer struct {
+	src, dst *DynamicConfig
+	prefix   string
+	used     map[string]bool
+}
+
+// router copies one router and everything it references
+func (n *namespacer) router(name string, router RouterConfig) {
+	router.Service = n.service(router.Service)
+	if len(router.Middlewares) > 0 {
+		middlewares := make([]string, len(router.Middlewares))
+		for i, mw := range router.Middlewares {
+			middlewares[i] = n.middleware(mw)
+		}
+		router.Middlewares = middlewares
+	}
+	n.dst.AddRouterWithSource(n.prefix+name, router, n.src.routerSources[name])
+}
+
+// service copies a referenced service and returns its new name
+func (n *namespacer) service(name string) string {
+	service, ok := n.src.HTTP.Services[name]
+	if strings.Contains(name, "@") || !ok {
+		return name
+	}
+	n.used["service/"+name] = true
+	if transport, ok := n.src.HTTP.ServersTransports[service.LoadBalancer.ServersTransport]; ok {
+		n.used["transport/"+service.LoadBalancer.ServersTransport] = true
+		service.LoadBalancer.ServersTransport = n.prefix + service.LoadBalancer.ServersTransport
+		n.dst.AddServersTransport(service.LoadBalancer.ServersTransport, transport)
+	}
+	n.dst.HTTP.Services[n.prefix+name] = service
+	return n.prefix + name
+}
+
+// middleware copies a referenced middleware (and the members of a chain or
+// the service of an errors middleware) and returns its new name
+func (n *namespacer) middleware(name string) string {
+	mw, ok := n.src.HTTP.Middlewares[name]
+	if strings.Contains(name, "@") || !ok {
+		return name
+	}
+	if _, done := n.dst.HTTP.Middlewares[n.prefix+name]; done {
+		return n.prefix + name
+	}
+	n.used["middleware/"+name] = true
+	n.dst.HTTP.Middlewares[n.prefix+name] = mw
+	if mw.Chain != nil {
+		members := make([]string, len(mw.Chain.Middlewares))
+		for i, member := range mw.Chain.Middlewares {
+			members[i] = n.middleware(member)
+		}
+		mw.Chain = &ChainConfig{Middlewares: members}
+		n.dst.HTTP.Middlewares[n.prefix+name] = mw
+	}
+	if mw.Errors != nil {
+		errorPage := *mw.Errors
+		errorPage.Service = n.service(errorPage.Service)
+		mw.Errors = &errorPage
+		n.dst.HTTP.Middlewares[n.prefix+name] = mw
+	}
+	return n.prefix + name
+}
@@ -0,0 +1,153 @@
+package provider
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTenants(t *testing.T) {
+	tenants := Tenants([]CloudRunService{
+		{Name: "lab1", ProjectID: "labs-stg"},
+		{Name: "shop", ProjectID: "labs-stg", Labels: map[string]string{TenantLabel: "team-shop"}},
+	})
+	want := map[string]string{"lab1": "labs-stg", "shop": "team-shop"}
+	if !reflect.DeepEqual(tenants, want) {
+		t.Errorf("Expected %v, got %v", want, tenants)
+	}
+}
+
+func TestDynamicConfig_SplitTenants(t *testing.T) {
+	config := NewDynamicConfig()
+	config.AddRouterWithSource("lab1", RouterConfig{
+		Rule:        "PathPrefix(`/lab1`)",
+		Service:     "lab1",
+		Middlewares: []string{"lab1-auth", "secure", "retry-cold-start@file"},
+	}, "lab1")
+	config.AddRouterWithSource("shop", RouterConfig{
+		Rule:        "PathPrefix(`/shop`)",
+		Service:     "shop",
+		Middlewares: []string{"secure"},
+	}, "shop")
+	config.AddService("lab1", ServiceConfig{LoadBalancer: LoadBalancerConfig{ServersTransport: "lab1-h2c"}})
+	config.AddService("shop", ServiceConfig{})
+	config.AddServersTransport("lab1-h2c", ServersTransportConfig{ServerName: "lab1.run.app"})
+	config.AddAuthMiddleware("lab1-auth", "eyJtoken")
+	config.AddMiddleware("secure", MiddlewareConfig{Chain: &ChainConfig{Middlewares: []string{"lab1-auth", "forwarded-headers@file"}}})
+	config.AddUserAuthMiddleware("lab2-auth-check", "https://home.run.app", DefaultUserAuthConfig())
+	config.AddTraefikInternalRouters()
+
+	parts := config.SplitTenants(map[string]string{"lab1": "labs", "shop": "team-shop"})
+	if len(parts) != 3 {
+		t.Fatalf("Expected shared, labs and team-shop configs, got %d", len(parts))
+	}
+
+	labs := parts["labs"]
+	router, ok := labs.HTTP.Routers["labs-lab1"]
+	if !ok {
+		t.Fatalf("Expected labs-lab1 router, got %v", labs.HTTP.Routers)
+	}
+	if router.Service != "labs-lab1" || !reflect.DeepEqual(router.Middlewares, []string{"labs-lab1-auth", "labs-secure", "retry-cold-start@file"}) {
+		t.Errorf("Expected namespaced references, got %+v", router)
+	}
+	if got := labs.HTTP.Services["labs-lab1"].LoadBalancer.ServersTransport; got != "labs-lab1-h2c" {
+		t.Errorf("Expected namespaced serversTransport, got %q", got)
+	}
+	if _, ok := labs.HTTP.ServersTransports["labs-lab1-h2c"]; !ok {
+		t.Error("Expected serversTransport copied into tenant")
+	}
+	if got := labs.HTTP.Middlewares["labs-secure"].Chain.Middlewares; !reflect.DeepEqual(got, []string{"labs-lab1-auth", "forwarded-headers@file"}) {
+		t.Errorf("Expected namespaced chain members, got %v", got)
+	}
+
+	// Middlewares used by several tenants are copied into each
+	shop := parts["team-shop"]
+	if _, ok := shop.HTTP.Middlewares["team-shop-secure"]; !ok {
+		t.Error("Expected shared chain copied into team-shop")
+	}
+	if _, ok := shop.HTTP.Middlewares["team-shop-lab1-auth"]; !ok {
+		t.Error("Expected chain member copied into team-shop")
+	}
+
+	shared := parts[""]
+	if _, ok := shared.HTTP.Routers["traefik-api"]; !ok {
+		t.Error("Expected internal routers in the shared config")
+	}
+	if _, ok := shared.HTTP.Middlewares["lab2-auth-check"]; !ok {
+		t.Error("Expected unreferenced middlewares in the shared config")
+	}
+	if _, ok := shared.HTTP.Routers["lab1"]; ok {
+		t.Error("Expected tenant routers to leave the shared config")
+	}
+	if _, ok := shared.HTTP.Services["lab1"]; ok {
+		t.Error("Expected tenant services to leave the shared config")
+	}
+}
+
+func TestDynamicConfig_WithNamePrefix(t *testing.T) {
+	config := NewDynamicConfig()
+	config.AddRouter("lab1", RouterConfig{Service: "lab1", Middlewares: []string{"lab1-auth", "retry-cold-start@file"}})
+	config.AddService("lab1", ServiceConfig{LoadBalancer: LoadBalancerConfig{ServersTransport: "lab1-h2c"}})
+	config.AddServersTransport("lab1-h2c", ServersTransportConfig{ServerName: "lab1.run.app"})
+	config.AddServersTransport("unused", ServersTransportConfig{})
+	config.AddAuthMiddleware("lab1-auth", "eyJtoken")
+	config.AddUserAuthMiddleware("lab2-auth-check", "https://home.run.app", DefaultUserAuthConfig())
+
+	prefixed := config.WithNamePrefix("cloudrun-")
+
+	router := prefixed.HTTP.Routers["cloudrun-lab1"]
+	if router.Service != "cloudrun-lab1" || !reflect.DeepEqual(router.Middlewares, []string{"cloudrun-lab1-auth", "retry-cold-start@file"}) {
+		t.Errorf("Expected prefixed references, got %+v", router)
+	}
+	if got := prefixed.HTTP.Services["cloudrun-lab1"].LoadBalancer.ServersTransport; got != "cloudrun-lab1-h2c" {
+		t.Errorf("Expected prefixed serversTransport reference, got %q", got)
+	}
+	for _, name := range []string{"cloudrun-lab1-h2c", "cloudrun-unused"} {
+		if _, ok := prefixed.HTTP.ServersTransports[name]; !ok {
+			t.Errorf("Expected serversTransport %s", name)
+		}
+	}
+	if _, ok := prefixed.HTTP.Middlewares["cloudrun-lab2-auth-check"]; !ok {
+		t.Error("Expected unreferenced middleware to be prefixed too")
+	}
+	if len(prefixed.HTTP.Routers) != 1 || len(prefixed.HTTP.Services) != 1 || len(prefixed.HTTP.Middlewares) != 2 {
+		t.Errorf("Expected no unprefixed leftovers, got %+v", prefixed.HTTP)
+	}
+}
+
+func TestDynamicConfig_RebuiltConfigsKeepRedaction(t *testing.T) {
+	provider, err := newProvider(&Config{
+		ProjectIDs:     []string{"test-project"},
+		Region:         "us-central1",
+		TokenInjection: TokenInjectionPlugin,
+		RedactHeaders:  []string{"X-Api-Key"},
+		NamePrefix:     "cr-",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	config, err := provider.Build([]CloudRunService{{Name: "partner", ProjectID: "test-project", URL: "https://partner.run.app", Labels: map[string]string{
+		"traefik_enable":                           "true",
+		"traefik_http_routers_partner_rule":        "PathPrefix(`/partner`)",
+		"traefik_http_routers_partner_middlewares": "partner-key",
+		"traefik_http_middlewares_partner-key_headers_customrequestheaders_x-api-key": "supersecretvalue",
+	}}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	check := func(what string, c *DynamicConfig) {
+		t.Helper()
+		for name, mw := range c.Sanitized().HTTP.Middlewares {
+			if mw.Headers != nil && mw.Headers.CustomRequestHeaders["X-Api-Key"] == "supersecretvalue" {
+				t.Errorf("%s: middleware %s leaks X-Api-Key in Sanitized()", what, name)
+			}
+		}
+	}
+	if _, ok := config.HTTP.Middlewares["cr-partner-key"]; !ok {
+		t.Fatalf("Expected the prefixed headers middleware, got %v", config.HTTP.Middlewares)
+	}
+	check("name prefix", config)
+	for tenant, part := range config.SplitTenants(map[string]string{"partner": "acme"}) {
+		check("tenant "+tenant, part)
+	}
+}
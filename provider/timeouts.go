@@ -0,0 +1,68 @@
+package provider
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	run "google.golang.org/api/run/v1"
+)
+
+// requestTimeoutLabel overrides the response header timeout Traefik allows
+// a service, as a duration ("30m") or seconds ("1800")
+const requestTimeoutLabel = "traefik_request_timeout"
+
+// requestTimeout returns the request timeout of a service's revision
+// template (Cloud Run defaults it to 5 minutes, at most 60), or 0 if unset
+func requestTimeout(svc *run.Service) time.Duration {
+	if svc.Spec == nil || svc.Spec.Template == nil || svc.Spec.Template.Spec == nil {
+		return 0
+	}
+	return time.Duration(svc.Spec.Template.Spec.TimeoutSeconds) * time.Second
+}
+
+// parseRequestTimeout parses a traefik_request_timeout value
+func parseRequestTimeout(value string) (time.Duration, error) {
+	if seconds, err := strconv.Atoi(value); err == nil {
+		value = strconv.Itoa(seconds) + "s"
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("invalid %s %q (expected a positive duration such as 30m)", requestTimeoutLabel, value)
+	}
+	return timeout, nil
+}
+
+// forwardingTimeout returns the response header timeout to generate for a
+// service, or 0 for Traefik's default: the traefik_request_timeout label,
+// else the Cloud Run request timeout when RequestTimeouts is set. Services
+// that get cold-start handling are allowed at least ColdStartTimeout.
+func (p *Provider) forwardingTimeout(service CloudRunService) time.Duration {
+	var timeout time.Duration
+	if value, ok := service.Labels[requestTimeoutLabel]; ok {
+		parsed, err := parseRequestTimeout(value)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "   WARNING: %v, ignoring\n", err)
+		}
+		timeout = parsed
+	}
+	if timeout == 0 && p.config.RequestTimeouts {
+		timeout = service.RequestTimeout
+	}
+	if p.coldStart(service) && timeout < p.config.ColdStartTimeout {
+		timeout = p.config.ColdStartTimeout
+	}
+	return timeout
+}
+
+// withForwardingTimeout returns the service's serversTransport (a new one
+// named <service>-timeouts if it has none) with the response header timeout set
+func withForwardingTimeout(serviceConfig *ServiceConfig, transport *ServersTransportConfig, name string, timeout time.Duration) *ServersTransportConfig {
+	if transport == nil {
+		transport = &ServersTransportConfig{}
+		serviceConfig.LoadBalancer.ServersTransport = name + "-timeouts"
+	}
+	transport.ForwardingTimeouts = &ForwardingTimeoutsConfig{ResponseHeaderTimeout: timeout.String()}
+	return transport
+}
@@ -0,0 +1,114 @@
+package provider
+
+import (
+	"testing"
+	"time"
+
+	run "google.golang.org/api/run/v1"
+)
+
+func TestRequestTimeout(t *testing.T) {
+	svc := newFakeService("app", "https://app.run.app", nil)
+	if got := requestTimeout(svc); got != 0 {
+		t.Errorf("Expected no timeout without a template, got %s", got)
+	}
+	svc.Spec = &run.ServiceSpec{Template: &run.RevisionTemplate{Spec: &run.RevisionSpec{TimeoutSeconds: 3600}}}
+	if got := requestTimeout(svc); got != time.Hour {
+		t.Errorf("Expected 1h, got %s", got)
+	}
+}
+
+func TestParseRequestTimeout(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"30m", 30 * time.Minute, false},
+		{"1800", 30 * time.Minute, false},
+		{"0", 0, true},
+		{"-5s", 0, true},
+		{"soon", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseRequestTimeout(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseRequestTimeout(%q) = %s, %v; expected %s (error %v)", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestProcessService_RequestTimeouts(t *testing.T) {
+	p, err := NewWithClients(&Config{
+		ProjectIDs:       []string{"test-project"},
+		Region:           "us-central1",
+		RequestTimeouts:  true,
+		ColdStartByScale: true,
+	}, &fakeCloudRunClient{}, &fakeTokenSource{token: "eyJ.test"}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	service := func(name string, timeout time.Duration, extra map[string]string) CloudRunService {
+		labels := map[string]string{"traefik_http_routers_" + name + "_rule": "PathPrefix(`/" + name + "`)"}
+		for key, value := range extra {
+			labels[key] = value
+		}
+		return CloudRunService{Name: name, URL: "https://" + name + ".run.app", Labels: labels, MinScale: intPtr(1), RequestTimeout: timeout}
+	}
+
+	config := NewDynamicConfig()
+	for _, svc := range []CloudRunService{
+		service("report", time.Hour, nil),
+		service("override", time.Hour, map[string]string{requestTimeoutLabel: "90s"}),
+		service("stream", 20*time.Minute, map[string]string{protocolLabel: ProtocolGRPC}),
+		service("unknown", 0, nil),
+	} {
+		if err := p.processService(svc, config); err != nil {
+			t.Fatalf("processService(%s) failed: %v", svc.Name, err)
+		}
+	}
+
+	for name, want := range map[string]string{"report-timeouts": "1h0m0s", "override-timeouts": "1m30s", "stream-grpc": "20m0s"} {
+		transport, ok := config.HTTP.ServersTransports[name]
+		if !ok || transport.ForwardingTimeouts == nil || transport.ForwardingTimeouts.ResponseHeaderTimeout != want {
+			t.Errorf("Expected serversTransport %s with a %s response header timeout, got %+v", name, want, transport)
+		}
+	}
+	if lb := config.HTTP.Services["report"].LoadBalancer; lb.ServersTransport != "report-timeouts" {
+		t.Errorf("Expected report to use its timeouts transport, got %q", lb.ServersTransport)
+	}
+	if lb := config.HTTP.Services["unknown"].LoadBalancer; lb.ServersTransport != "" {
+		t.Errorf("Expected no serversTransport without a request timeout, got %q", lb.ServersTransport)
+	}
+}
+
+func TestForwardingTimeout(t *testing.T) {
+	p, err := NewWithClients(&Config{
+		ProjectIDs:       []string{"test-project"},
+		Region:           "us-central1",
+		ColdStartByScale: true,
+	}, &fakeCloudRunClient{}, &fakeTokenSource{token: "eyJ.test"}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		service CloudRunService
+		want    time.Duration
+	}{
+		{"service timeout needs the option", CloudRunService{RequestTimeout: time.Hour, MinScale: intPtr(1)}, 0},
+		{"label applies without the option", CloudRunService{Labels: map[string]string{requestTimeoutLabel: "10m"}, MinScale: intPtr(1)}, 10 * time.Minute},
+		{"invalid label is ignored", CloudRunService{Labels: map[string]string{requestTimeoutLabel: "later"}, MinScale: intPtr(1)}, 0},
+		{"cold start raises a shorter timeout", CloudRunService{Labels: map[string]string{requestTimeoutLabel: "10s"}}, DefaultColdStartTimeout},
+		{"cold start keeps a longer timeout", CloudRunService{Labels: map[string]string{requestTimeoutLabel: "10m"}}, 10 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.forwardingTimeout(tt.service); got != tt.want {
+				t.Errorf("forwardingTimeout = %s, expected %s", got, tt.want)
+			}
+		})
+	}
+}
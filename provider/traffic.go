@@ -0,0 +1,66 @@
+package provider
+
+import (
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/internal/logging"
+	run "google.golang.org/api/run/v1"
+)
+
+// Policies for services that exist but serve no traffic (every traffic
+// target at 0%, e.g. after a full rollback to a tag-only revision)
+const (
+	ZeroTrafficRoute        = "route"        // Default: route them as usual
+	ZeroTrafficDrop         = "drop"         // Leave them out of the generated config
+	ZeroTrafficDeprioritize = "deprioritize" // Route them at the lowest priority so other routes win
+)
+
+// zeroTrafficPriority is the router priority of deprioritized services: the
+// lowest explicit priority (0 means Traefik's rule-length default)
+const zeroTrafficPriority = 1
+
+// servesNoTraffic reports whether all of a service's traffic targets are at
+// 0%. Services without traffic status count as serving.
+func servesNoTraffic(svc *run.Service) bool {
+	if svc.Status == nil || len(svc.Status.Traffic) == 0 {
+		return false
+	}
+	for _, target := range svc.Status.Traffic {
+		if target != nil && target.Percent > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// gateZeroTraffic drops a service serving no traffic under the drop policy
+// and reports whether it is routed
+func (p *Provider) gateZeroTraffic(service CloudRunService, config *DynamicConfig) bool {
+	if !service.NoTraffic || p.config.ZeroTrafficPolicy != ZeroTrafficDrop {
+		return true
+	}
+	p.logger.Info("Skipping service (serves no traffic)",
+		logging.GetCodeField(logging.CodeServiceSkipped),
+		logging.String("service", service.Name),
+		logging.String("project", service.ProjectID),
+	)
+	config.skip(SkippedService{Service: service.Name, Project: service.ProjectID, Reason: SkipReasonNoTraffic, Detail: "all traffic targets at 0%"})
+	return false
+}
+
+// deprioritizeZeroTraffic moves the routers of a service serving no traffic
+// to the lowest priority under the deprioritize policy, so a stale catch-all
+// rule can't shadow the routes of live services
+func (p *Provider) deprioritizeZeroTraffic(service CloudRunService, config *DynamicConfig) {
+	if !service.NoTraffic || p.config.ZeroTrafficPolicy != ZeroTrafficDeprioritize {
+		return
+	}
+	for name, source := range config.routerSources {
+		if source != service.Name {
+			continue
+		}
+		router := config.HTTP.Routers[name]
+		router.Priority = zeroTrafficPriority
+		config.HTTP.Routers[name] = router
+	}
+	config.skip(SkippedService{Service: service.Name, Project: service.ProjectID, Reason: SkipReasonNoTraffic,
+		Detail: "all traffic targets at 0%, routed at lowest priority", Degraded: true})
+}
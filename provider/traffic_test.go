@@ -0,0 +1,93 @@
+package provider
+
+import (
+	"strings"
+	"testing"
+
+	run "google.golang.org/api/run/v1"
+)
+
+func TestServesNoTraffic(t *testing.T) {
+	svc := newFakeService("lab1", "https://lab1.run.app", nil)
+	if servesNoTraffic(svc) {
+		t.Error("Expected a service without traffic status to count as serving")
+	}
+
+	svc.Status.Traffic = []*run.TrafficTarget{{RevisionName: "lab1-00001", Percent: 100}, {RevisionName: "lab1-00002", Tag: "canary"}}
+	if servesNoTraffic(svc) {
+		t.Error("Expected a service with a 100% target to count as serving")
+	}
+
+	svc.Status.Traffic = []*run.TrafficTarget{{RevisionName: "lab1-00002", Tag: "rollback"}}
+	if !servesNoTraffic(svc) {
+		t.Error("Expected a service with only 0% targets to serve no traffic")
+	}
+}
+
+func TestBuild_ZeroTrafficPolicy(t *testing.T) {
+	services := []CloudRunService{
+		{Name: "live", ProjectID: "test-project", URL: "https://live.run.app", Labels: map[string]string{
+			"traefik_enable": "true", "traefik_http_routers_live_rule": "PathPrefix(`/live`)", "traefik_http_routers_live_priority": "100",
+		}},
+		{Name: "dead", ProjectID: "test-project", URL: "https://dead.run.app", NoTraffic: true, Labels: map[string]string{
+			"traefik_enable": "true", "traefik_http_routers_dead_rule": "PathPrefix(`/`)", "traefik_http_routers_dead_priority": "500",
+			"traefik_allow_catch_all": "true",
+		}},
+	}
+
+	tests := []struct {
+		policy       string
+		deadPriority int // 0: no dead router
+		reason       string
+		degraded     bool
+	}{
+		{"", 500, "", false},
+		{ZeroTrafficDrop, 0, SkipReasonNoTraffic, false},
+		{ZeroTrafficDeprioritize, zeroTrafficPriority, SkipReasonNoTraffic, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			provider, err := newProvider(&Config{
+				ProjectIDs:        []string{"test-project"},
+				Region:            "us-central1",
+				TokenInjection:    TokenInjectionPlugin,
+				ZeroTrafficPolicy: tt.policy,
+			})
+			if err != nil {
+				t.Fatalf("Failed to create provider: %v", err)
+			}
+			config, err := provider.Build(services)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if live := config.HTTP.Routers["live"]; live.Priority != 100 {
+				t.Errorf("Expected live router at priority 100, got %d", live.Priority)
+			}
+			dead, ok := config.HTTP.Routers["dead"]
+			if tt.deadPriority == 0 && ok {
+				t.Errorf("Expected dead router to be dropped, got %+v", dead)
+			} else if tt.deadPriority != 0 && dead.Priority != tt.deadPriority {
+				t.Errorf("Expected dead router at priority %d, got %d", tt.deadPriority, dead.Priority)
+			}
+
+			skipped := config.Skipped()
+			if tt.reason == "" {
+				if len(skipped) != 0 {
+					t.Errorf("Expected no skipped services, got %+v", skipped)
+				}
+				return
+			}
+			if len(skipped) != 1 || skipped[0].Service != "dead" || skipped[0].Reason != tt.reason || skipped[0].Degraded != tt.degraded {
+				t.Errorf("Expected dead skipped with %s (degraded %v), got %+v", tt.reason, tt.degraded, skipped)
+			}
+		})
+	}
+}
+
+func TestNew_InvalidZeroTrafficPolicy(t *testing.T) {
+	_, err := New(&Config{ProjectIDs: []string{"p"}, Region: "r", ZeroTrafficPolicy: "hide"})
+	if err == nil || !strings.Contains(err.Error(), "zero traffic policy") {
+		t.Errorf("Expected invalid policy error, got %v", err)
+	}
+}
@@ -0,0 +1,158 @@
+package provider
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Label validation modes for traefik_* label keys the provider doesn't recognize
+const (
+	LabelValidationIgnore = "ignore" // Default: unknown labels are silently ignored
+	LabelValidationWarn   = "warn"   // Unknown labels are logged, the service is still routed
+	LabelValidationStrict = "strict" // Services with unknown labels are skipped
+)
+
+// knownLabels are the recognized traefik_* labels without a name component
+var knownLabels = map[string]bool{
+	"traefik_enable":        true,
+	protocolLabel:           true,
+	websocketLabel:          true,
+	flushIntervalLabel:      true,
+	requestTimeoutLabel:     true,
+	tokenFailurePolicyLabel: true,
+	authTypeLabel:           true,
+	authProviderLabel:       true,
+	routeTagLabel:           true,
+	selfTestLabel:           true,
+	TenantLabel:             true,
+	hostLabel:               true,
+	hostRegexpLabel:         true,
+	pathPrefixLabel:         true,
+	pathRegexpLabel:         true,
+	methodsLabel:            true,
+	headersLabel:            true,
+	queryLabel:              true,
+	rewriteRegexLabel:       true,
+	rewriteReplacementLabel: true,
+	maintenanceLabel:        true,
+	activeBackendLabel:      true,
+	allowCatchAllLabel:      true,
+}
+
+// routerProperties are the properties of traefik_http_routers_<name>_<property>
+var routerProperties = map[string]bool{
+	"rule":        true,
+	"rule_id":     true,
+	"service":     true,
+	"priority":    true,
+	"entrypoints": true,
+	"middlewares": true,
+	"methods":     true,
+	"headers":     true,
+	"query":       true,
+
+	"observability_accesslogs": true,
+	"observability_metrics":    true,
+	"observability_tracing":    true,
+}
+
+// forwardAuthProperties are the properties of traefik_forwardauth_<property>
+// and traefik_http_middlewares_<name>_forwardauth_<property>
+var forwardAuthProperties = map[string]bool{
+	"address":             true,
+	"trustforwardheader":  true,
+	"authresponseheaders": true,
+	"authrequestheaders":  true,
+}
+
+// unknownLabels returns a description of each traefik_* label key that isn't
+// recognized or is malformed (e.g. a misspelled property or a missing
+// router name), sorted by key. Other labels are not Traefik's and are ignored.
+func unknownLabels(labels map[string]string) []string {
+	var problems []string
+	for key := range labels {
+		if !strings.HasPrefix(key, "traefik_") || knownLabels[key] {
+			continue
+		}
+		if problem := labelKeyProblem(key); problem != "" {
+			problems = append(problems, key+": "+problem)
+		}
+	}
+	sort.Strings(problems)
+	return problems
+}
+
+// labelKeyProblem explains what is wrong with a traefik_* label key, or
+// returns "" if the key is recognized
+func labelKeyProblem(key string) string {
+	switch {
+	case strings.HasPrefix(key, "traefik_http_routers_"):
+		parts := strings.SplitN(key, "_", 5)
+		if len(parts) < 5 || parts[3] == "" {
+			return "expected traefik_http_routers_<name>_<property>"
+		}
+		if !routerProperties[parts[4]] {
+			return fmt.Sprintf("unknown router property %q", parts[4])
+		}
+	case strings.HasPrefix(key, "traefik_http_middlewares_"):
+		parts := strings.SplitN(key, "_", 6)
+		if len(parts) < 6 || parts[3] == "" {
+			return "expected traefik_http_middlewares_<name>_<type>_<property>"
+		}
+		switch parts[4] {
+		case "chain":
+			if parts[5] != "middlewares" {
+				return fmt.Sprintf("unknown chain property %q", parts[5])
+			}
+		case "forwardauth":
+			if !forwardAuthProperties[parts[5]] {
+				return fmt.Sprintf("unknown forwardAuth property %q", parts[5])
+			}
+		case "ipallowlist", "ipwhitelist":
+			if parts[5] != "sourcerange" {
+				return fmt.Sprintf("unknown ipAllowList property %q", parts[5])
+			}
+		case "headers":
+			if !validHeadersProperty(parts[5]) {
+				return fmt.Sprintf("unknown headers property %q", parts[5])
+			}
+		case "errors":
+			if !errorsProperties[parts[5]] {
+				return fmt.Sprintf("unknown errors property %q", parts[5])
+			}
+		case "replacepathregex":
+			if parts[5] != "regex" && parts[5] != "replacement" {
+				return fmt.Sprintf("unknown replacePathRegex property %q", parts[5])
+			}
+		default:
+			return fmt.Sprintf("unsupported middleware type %q", parts[4])
+		}
+	case strings.HasPrefix(key, "traefik_http_services_"):
+		// Accepted for docker-compose compatibility; the backend is always the
+		// Cloud Run service URL
+	case strings.HasPrefix(key, "traefik_forwardauth_"):
+		if property := strings.TrimPrefix(key, "traefik_forwardauth_"); !forwardAuthProperties[property] {
+			return fmt.Sprintf("unknown forwardAuth property %q", property)
+		}
+	case strings.HasPrefix(key, corsLabelPrefix):
+		if property := strings.TrimPrefix(key, corsLabelPrefix); corsProperties[property] == "" {
+			return fmt.Sprintf("unknown CORS property %q", property)
+		}
+	case strings.HasPrefix(key, errorsLabelPrefix):
+		if property := strings.TrimPrefix(key, errorsLabelPrefix); !errorsProperties[property] {
+			return fmt.Sprintf("unknown errors property %q", property)
+		}
+	case strings.HasPrefix(key, backendLabelPrefix):
+		if key == backendLabelPrefix {
+			return "expected traefik_backend_<name>"
+		}
+	case strings.HasPrefix(key, "traefik_chain_"):
+		if key == "traefik_chain_" {
+			return "expected traefik_chain_<name>"
+		}
+	default:
+		return "unknown label"
+	}
+	return ""
+}
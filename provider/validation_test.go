@@ -0,0 +1,95 @@
+package provider
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestUnknownLabels(t *testing.T) {
+	labels := map[string]string{
+		"traefik_enable":                                    "true",
+		"traefik_http_routers_app_rule":                     "PathPrefix(`/app`)",
+		"traefik_http_routers_app_rule_id":                  "lab1",
+		"traefik_http_routers_app_rulee":                    "PathPrefix(`/app`)",
+		"traefik_http_routers_app":                          "x",
+		"traefik_http_middlewares_auth_forwardauth_address": "https://auth",
+		"traefik_http_middlewares_auth_forwardauth_adress":  "https://auth",
+		"traefik_http_middlewares_stack_chain_middlewares":  "a__b",
+		"traefik_http_middlewares_gzip_compress_enabled":    "true",
+		"traefik_http_services_app_lb_port":                 "8080",
+		"traefik_forwardauth_address":                       "https://auth",
+		"traefik_chain_secure":                              "a__b",
+		"traefik_protocol":                                  "grpc",
+		"traefik_enabled":                                   "true",
+		"app":                                               "not-traefik",
+	}
+
+	got := unknownLabels(labels)
+	want := []string{
+		"traefik_enabled: unknown label",
+		"traefik_http_middlewares_auth_forwardauth_adress: unknown forwardAuth property \"adress\"",
+		"traefik_http_middlewares_gzip_compress_enabled: unsupported middleware type \"compress\"",
+		"traefik_http_routers_app: expected traefik_http_routers_<name>_<property>",
+		"traefik_http_routers_app_rulee: unknown router property \"rulee\"",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestProcessService_LabelValidation(t *testing.T) {
+	service := CloudRunService{
+		Name:      "test-service",
+		ProjectID: "test-project",
+		URL:       "https://test-service.run.app",
+		Labels: map[string]string{
+			"traefik_enable":                  "true",
+			"traefik_http_routers_test_rule":  "PathPrefix(`/test`)",
+			"traefik_http_routers_test_rulee": "PathPrefix(`/typo`)",
+		},
+	}
+
+	for _, mode := range []string{LabelValidationIgnore, LabelValidationWarn, LabelValidationStrict} {
+		t.Run(mode, func(t *testing.T) {
+			provider, err := newProvider(&Config{
+				ProjectIDs:      []string{"test-project"},
+				Region:          "us-central1",
+				TokenInjection:  TokenInjectionPlugin,
+				LabelValidation: mode,
+			})
+			if err != nil {
+				t.Fatalf("Failed to create provider: %v", err)
+			}
+
+			dynamicConfig := NewDynamicConfig()
+			err = provider.processService(service, dynamicConfig)
+			if mode == LabelValidationStrict {
+				if !errors.Is(err, ErrUnknownLabels) {
+					t.Fatalf("Expected ErrUnknownLabels, got %v", err)
+				}
+				if len(dynamicConfig.HTTP.Routers) != 0 {
+					t.Error("Expected no routers in strict mode")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if _, ok := dynamicConfig.HTTP.Routers["test"]; !ok {
+				t.Error("Expected router test to be created")
+			}
+		})
+	}
+}
+
+func TestNew_InvalidLabelValidation(t *testing.T) {
+	_, err := newProvider(&Config{
+		ProjectIDs:      []string{"test-project"},
+		Region:          "us-central1",
+		LabelValidation: "pedantic",
+	})
+	if err == nil {
+		t.Error("Expected error for invalid label validation mode")
+	}
+}
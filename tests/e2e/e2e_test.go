@@ -0,0 +1,57 @@
+// Package e2e runs the docker-compose.e2e.yml stack (Traefik, frontend and
+// backend) and checks routing and header propagation through the gateway.
+// Skipped unless E2E=1 is set:
+//
+//	E2E=1 go test -v ./tests/e2e/...
+package e2e
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/tests/e2e/e2etest"
+)
+
+func TestGateway(t *testing.T) {
+	stack := e2etest.Compose(t, "../../docker-compose.e2e.yml")
+	stack.WaitForRouters(t, 30*time.Second, "frontend@docker", "backend@docker")
+
+	t.Run("routes", func(t *testing.T) {
+		e2etest.AssertRoute(t, stack, "api.localhost", "/api/query", "backend")
+		e2etest.AssertRoute(t, stack, "app.localhost", "/", "frontend")
+		for _, host := range []string{"api.localhost", "app.localhost"} {
+			resp, err := stack.Get(host, "/health", nil)
+			if err != nil {
+				t.Errorf("%s/health: %v", host, err)
+			} else if resp.StatusCode != http.StatusOK {
+				t.Errorf("%s/health: expected status 200, got %d", host, resp.StatusCode)
+			}
+		}
+	})
+
+	t.Run("headers", func(t *testing.T) {
+		query := e2etest.Query(t, stack, "api.localhost", "/api/query", http.Header{"X-Request-ID": {"e2e-request"}})
+		e2etest.AssertHeaderPropagated(t, query, "X-Request-ID", "e2e-request")
+		e2etest.AssertHeaderPropagated(t, query, "X-Forwarded-By", "traefik")
+		e2etest.AssertHeaderPropagated(t, query, "X-Forwarded-Host", "api.localhost")
+	})
+
+	t.Run("service to service", func(t *testing.T) {
+		// The frontend calls the backend back through the gateway
+		resp, err := stack.Get("app.localhost", "/", http.Header{"X-Request-ID": {"e2e-chain"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var frontend e2etest.FrontendResponse
+		if err := resp.JSON(&frontend); err != nil {
+			t.Fatal(err)
+		}
+		backend := &frontend.BackendQueryResult
+		if backend.Service.Name != "backend" {
+			t.Fatalf("Expected the frontend's call to reach the backend, got %+v", backend.Service)
+		}
+		e2etest.AssertHeaderPropagated(t, backend, "X-Request-ID", "e2e-chain")
+		e2etest.AssertHeaderPropagated(t, backend, "X-Forwarded-By", "traefik")
+	})
+}
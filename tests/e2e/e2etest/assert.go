@@ -0,0 +1,135 @@
+package e2etest
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// QueryResponse is what tests/e2e/backend's /api/query (and the frontend's
+// backend_query_result) reports about the request it received
+type QueryResponse struct {
+	Request RequestDetails `json:"request"`
+	Service ServiceInfo    `json:"service"`
+}
+
+// RequestDetails is the request as the backend saw it
+type RequestDetails struct {
+	Method  string              `json:"method"`
+	Path    string              `json:"path"`
+	Headers map[string][]string `json:"headers"`
+	Host    string              `json:"host"`
+}
+
+// ServiceInfo identifies the service that answered
+type ServiceInfo struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// FrontendResponse is what tests/e2e/frontend returns: the headers it
+// received and the result of its own call through the gateway to the backend
+type FrontendResponse struct {
+	FrontendHeaders    map[string][]string `json:"frontend_headers"`
+	BackendQueryResult QueryResponse       `json:"backend_query_result"`
+	FrontendInfo       ServiceInfo         `json:"frontend_info"`
+}
+
+// servedBy returns the name of the test service that produced the response
+func servedBy(resp *Response) (string, error) {
+	var body struct {
+		Service      ServiceInfo `json:"service"`
+		FrontendInfo ServiceInfo `json:"frontend_info"`
+	}
+	if err := resp.JSON(&body); err != nil {
+		return "", err
+	}
+	if body.Service.Name != "" {
+		return body.Service.Name, nil
+	}
+	return body.FrontendInfo.Name, nil
+}
+
+// CheckRoute checks that a response came back with status 200 from the
+// named service
+func CheckRoute(resp *Response, service string) error {
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("expected status 200, got %d: %s", resp.StatusCode, strings.TrimSpace(string(resp.Body)))
+	}
+	name, err := servedBy(resp)
+	if err != nil {
+		return err
+	}
+	if name != service {
+		return fmt.Errorf("expected response from %q, got %q", service, name)
+	}
+	return nil
+}
+
+// AssertRoute requests host/path through the gateway and fails the test
+// unless the named service answers with 200
+func AssertRoute(t testing.TB, s *Stack, host, path, service string) *Response {
+	t.Helper()
+	resp, err := s.Get(host, path, nil)
+	if err != nil {
+		t.Fatalf("%s%s: %v", host, path, err)
+	}
+	if err := CheckRoute(resp, service); err != nil {
+		t.Errorf("%s%s: %v", host, path, err)
+	}
+	return resp
+}
+
+// Query sends a request through the gateway to a backend's /api/query-style
+// endpoint and returns what the backend saw
+func Query(t testing.TB, s *Stack, host, path string, header http.Header) *QueryResponse {
+	t.Helper()
+	resp, err := s.Get(host, path, header)
+	if err != nil {
+		t.Fatalf("%s%s: %v", host, path, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("%s%s: expected status 200, got %d: %s", host, path, resp.StatusCode, resp.Body)
+	}
+	var query QueryResponse
+	if err := resp.JSON(&query); err != nil {
+		t.Fatalf("%s%s: %v", host, path, err)
+	}
+	return &query
+}
+
+// CheckHeader checks that headers carry name with a value starting with
+// prefix ("" accepts any value). Names are matched case-insensitively.
+func CheckHeader(headers map[string][]string, name, prefix string) error {
+	for key, values := range headers {
+		if !strings.EqualFold(key, name) {
+			continue
+		}
+		for _, value := range values {
+			if strings.HasPrefix(value, prefix) {
+				return nil
+			}
+		}
+		return fmt.Errorf("header %s = %q, expected a value starting with %q", name, values, prefix)
+	}
+	return fmt.Errorf("header %s not received", name)
+}
+
+// AssertHeaderPropagated fails the test unless the backend received header
+// name with a value starting with prefix
+func AssertHeaderPropagated(t testing.TB, query *QueryResponse, name, prefix string) {
+	t.Helper()
+	if err := CheckHeader(query.Request.Headers, name, prefix); err != nil {
+		t.Errorf("%s: %v", query.Service.Name, err)
+	}
+}
+
+// AssertHeaderAbsent fails the test if the backend received header name,
+// e.g. a credential that must not leak to it
+func AssertHeaderAbsent(t testing.TB, query *QueryResponse, name string) {
+	t.Helper()
+	if err := CheckHeader(query.Request.Headers, name, ""); err == nil {
+		t.Errorf("%s: header %s received, expected it to be absent", query.Service.Name, name)
+	}
+}
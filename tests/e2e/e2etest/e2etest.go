@@ -0,0 +1,285 @@
+// Package e2etest runs end-to-end tests against a Traefik gateway with
+// backends behind it, such as the docker-compose.e2e.yml stack (Traefik,
+// tests/e2e/frontend and tests/e2e/backend).
+//
+// Compose starts a docker compose stack for the duration of a test and tears
+// it down afterwards; NewStack wraps a stack that is already running, e.g. a
+// staging gateway. Requests go through the gateway with Get, and the route
+// and header checks in assert.go compare what the backends report against
+// what the test expects.
+//
+// Compose needs Docker and only runs with E2E=1, so 'go test ./...' stays
+// hermetic:
+//
+//	E2E=1 go test -v ./tests/e2e/...
+package e2etest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// EnableEnv must be set for Compose to start a stack
+const EnableEnv = "E2E"
+
+// Default gateway ports of docker-compose.e2e.yml, overridable with
+// TRAEFIK_WEB_PORT and TRAEFIK_API_PORT as in test-e2e.sh
+const (
+	defaultWebPort = "8090"
+	defaultAPIPort = "8091"
+)
+
+// defaultReadyTimeout bounds how long Compose waits for Traefik's API
+const defaultReadyTimeout = 90 * time.Second
+
+// Stack is a running gateway: WebURL is its web entrypoint and APIURL its
+// API (--api.insecure), used to inspect routers
+type Stack struct {
+	WebURL string
+	APIURL string
+
+	client *http.Client
+}
+
+// NewStack wraps a gateway that is already running
+func NewStack(webURL, apiURL string) *Stack {
+	return &Stack{
+		WebURL: strings.TrimSuffix(webURL, "/"),
+		APIURL: strings.TrimSuffix(apiURL, "/"),
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// composeOptions are the settings Compose's options change
+type composeOptions struct {
+	project      string
+	env          []string
+	readyTimeout time.Duration
+	build        bool
+}
+
+// Option changes how Compose starts a stack
+type Option func(*composeOptions)
+
+// WithProject sets the compose project name (default cloudrun-e2e)
+func WithProject(name string) Option {
+	return func(o *composeOptions) { o.project = name }
+}
+
+// WithEnv adds KEY=value settings to the compose environment, e.g. the
+// TRAEFIK_WEB_PORT the compose file interpolates
+func WithEnv(env ...string) Option {
+	return func(o *composeOptions) { o.env = append(o.env, env...) }
+}
+
+// WithReadyTimeout sets how long to wait for Traefik's API to answer
+func WithReadyTimeout(d time.Duration) Option {
+	return func(o *composeOptions) { o.readyTimeout = d }
+}
+
+// WithoutBuild starts the stack from existing images
+func WithoutBuild() Option {
+	return func(o *composeOptions) { o.build = false }
+}
+
+// Compose starts the services of a docker compose file and waits until
+// Traefik's API answers. The stack is torn down when the test ends; if the
+// test failed, the service logs are written to the test log first. The test
+// is skipped unless E2E=1 is set and the docker CLI is available.
+func Compose(t testing.TB, file string, opts ...Option) *Stack {
+	t.Helper()
+
+	if os.Getenv(EnableEnv) == "" {
+		t.Skipf("set %s=1 to run end-to-end tests (requires Docker)", EnableEnv)
+	}
+	if _, err := exec.LookPath("docker"); err != nil {
+		t.Skip("docker CLI not found")
+	}
+
+	o := composeOptions{project: "cloudrun-e2e", readyTimeout: defaultReadyTimeout, build: true}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	file, err := filepath.Abs(file)
+	if err != nil {
+		t.Fatalf("Invalid compose file: %v", err)
+	}
+
+	compose := func(args ...string) ([]byte, error) {
+		cmd := exec.Command("docker", append([]string{"compose", "-p", o.project, "-f", file}, args...)...)
+		cmd.Dir = filepath.Dir(file)
+		cmd.Env = append(os.Environ(), o.env...)
+		return cmd.CombinedOutput()
+	}
+
+	// Leftovers from an interrupted run would hold the ports
+	_, _ = compose("down", "-v")
+
+	up := []string{"up", "-d"}
+	if o.build {
+		up = append(up, "--build")
+	}
+	if out, err := compose(up...); err != nil {
+		t.Fatalf("docker compose up failed: %v\n%s", err, out)
+	}
+	t.Cleanup(func() {
+		if t.Failed() {
+			if out, err := compose("logs", "--no-color"); err == nil {
+				t.Logf("docker compose logs:\n%s", out)
+			}
+		}
+		if out, err := compose("down", "-v"); err != nil {
+			t.Logf("docker compose down failed: %v\n%s", err, out)
+		}
+	})
+
+	webPort, apiPort := lookupEnv(o.env, "TRAEFIK_WEB_PORT", defaultWebPort), lookupEnv(o.env, "TRAEFIK_API_PORT", defaultAPIPort)
+	stack := NewStack("http://localhost:"+webPort, "http://localhost:"+apiPort)
+	if err := stack.waitReady(o.readyTimeout); err != nil {
+		t.Fatal(err)
+	}
+	return stack
+}
+
+// lookupEnv returns key from the compose environment overrides, then the
+// process environment, then def
+func lookupEnv(overrides []string, key, def string) string {
+	for i := len(overrides) - 1; i >= 0; i-- {
+		if value, ok := strings.CutPrefix(overrides[i], key+"="); ok {
+			return value
+		}
+	}
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return def
+}
+
+// waitReady polls Traefik's router API until it answers
+func (s *Stack) waitReady(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	var lastErr error
+	for time.Now().Before(deadline) {
+		if _, lastErr = s.Routers(); lastErr == nil {
+			return nil
+		}
+		time.Sleep(time.Second)
+	}
+	return fmt.Errorf("traefik API at %s not ready after %s: %w", s.APIURL, timeout, lastErr)
+}
+
+// Router is an HTTP router as Traefik's API reports it
+type Router struct {
+	Name        string   `json:"name"`
+	Rule        string   `json:"rule"`
+	Service     string   `json:"service"`
+	Status      string   `json:"status"`
+	Middlewares []string `json:"middlewares,omitempty"`
+	Provider    string   `json:"provider"`
+}
+
+// Routers returns the HTTP routers Traefik has loaded
+func (s *Stack) Routers() ([]Router, error) {
+	resp, err := s.client.Get(s.APIURL + "/api/http/routers")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list routers: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("listing routers returned %d", resp.StatusCode)
+	}
+
+	var routers []Router
+	if err := json.NewDecoder(resp.Body).Decode(&routers); err != nil {
+		return nil, fmt.Errorf("failed to parse routers: %w", err)
+	}
+	return routers, nil
+}
+
+// WaitForRouters waits until Traefik has loaded every named router (names
+// as the API reports them, e.g. backend@docker) and fails the test otherwise
+func (s *Stack) WaitForRouters(t testing.TB, timeout time.Duration, names ...string) {
+	t.Helper()
+
+	deadline := time.Now().Add(timeout)
+	var missing []string
+	for {
+		routers, err := s.Routers()
+		if err == nil {
+			missing = missingRouters(routers, names)
+			if len(missing) == 0 {
+				return
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Routers %v not loaded after %s (last error: %v)", missing, timeout, err)
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// missingRouters returns the names not among routers
+func missingRouters(routers []Router, names []string) []string {
+	loaded := make(map[string]bool, len(routers))
+	for _, router := range routers {
+		loaded[router.Name] = true
+	}
+	var missing []string
+	for _, name := range names {
+		if !loaded[name] {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// Response is a response received through the gateway
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// JSON decodes the body into v
+func (r *Response) JSON(v interface{}) error {
+	if err := json.Unmarshal(r.Body, v); err != nil {
+		return fmt.Errorf("failed to parse response (status %d): %w\n%s", r.StatusCode, err, r.Body)
+	}
+	return nil
+}
+
+// Get sends a GET request for path through the gateway with the given Host
+// header (the router's Host rule) and extra request headers
+func (s *Stack) Get(host, path string, header http.Header) (*Response, error) {
+	req, err := http.NewRequest(http.MethodGet, s.WebURL+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Host = host
+	for name, values := range header {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request to %s%s failed: %w", host, path, err)
+	}
+	defer resp.Body.Close()
+
+	var body bytes.Buffer
+	if _, err := io.Copy(&body, resp.Body); err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	return &Response{StatusCode: resp.StatusCode, Header: resp.Header, Body: body.Bytes()}, nil
+}
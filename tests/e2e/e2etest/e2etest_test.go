@@ -0,0 +1,109 @@
+package e2etest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newFakeGateway serves a Traefik router API and routes by Host to a fake
+// backend that echoes the request like tests/e2e/backend
+func newFakeGateway(t *testing.T) *Stack {
+	t.Helper()
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/http/routers" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode([]Router{{Name: "backend@docker", Rule: "Host(`api.localhost`)", Service: "backend", Status: "enabled"}})
+	}))
+	t.Cleanup(api.Close)
+
+	web := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host != "api.localhost" {
+			http.NotFound(w, r)
+			return
+		}
+		headers := r.Header.Clone()
+		headers.Set("X-Serverless-Authorization", "Bearer eyJ.test")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"request": map[string]interface{}{"method": r.Method, "path": r.URL.Path, "headers": headers, "host": r.Host},
+			"service": map[string]string{"name": "backend", "version": "1.0.0"},
+		})
+	}))
+	t.Cleanup(web.Close)
+
+	return NewStack(web.URL, api.URL+"/")
+}
+
+func TestStack_Routers(t *testing.T) {
+	stack := newFakeGateway(t)
+
+	routers, err := stack.Routers()
+	if err != nil || len(routers) != 1 || routers[0].Name != "backend@docker" {
+		t.Fatalf("Routers = %+v, %v", routers, err)
+	}
+	stack.WaitForRouters(t, time.Second, "backend@docker")
+
+	if missing := missingRouters(routers, []string{"backend@docker", "frontend@docker"}); len(missing) != 1 || missing[0] != "frontend@docker" {
+		t.Errorf("Expected frontend@docker to be missing, got %v", missing)
+	}
+}
+
+func TestCheckRoute(t *testing.T) {
+	stack := newFakeGateway(t)
+
+	AssertRoute(t, stack, "api.localhost", "/api/query", "backend")
+
+	resp, err := stack.Get("api.localhost", "/api/query", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckRoute(resp, "frontend"); err == nil || !strings.Contains(err.Error(), `got "backend"`) {
+		t.Errorf("Expected wrong service error, got %v", err)
+	}
+
+	resp, err = stack.Get("unknown.localhost", "/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckRoute(resp, "backend"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Expected status error, got %v", err)
+	}
+}
+
+func TestHeaderPropagation(t *testing.T) {
+	stack := newFakeGateway(t)
+
+	query := Query(t, stack, "api.localhost", "/api/query", http.Header{"X-Request-Id": {"req-1"}})
+	if query.Request.Path != "/api/query" || query.Request.Host != "api.localhost" {
+		t.Errorf("Unexpected request details: %+v", query.Request)
+	}
+	AssertHeaderPropagated(t, query, "x-request-id", "req-1")
+	AssertHeaderPropagated(t, query, "X-Serverless-Authorization", "Bearer eyJ")
+	AssertHeaderAbsent(t, query, "Authorization")
+
+	if err := CheckHeader(query.Request.Headers, "X-Request-ID", "other"); err == nil {
+		t.Error("Expected mismatched header value to fail")
+	}
+	if err := CheckHeader(query.Request.Headers, "X-Missing", ""); err == nil {
+		t.Error("Expected missing header to fail")
+	}
+}
+
+func TestLookupEnv(t *testing.T) {
+	t.Setenv("TRAEFIK_WEB_PORT", "9000")
+	if got := lookupEnv(nil, "TRAEFIK_WEB_PORT", defaultWebPort); got != "9000" {
+		t.Errorf("Expected process environment, got %s", got)
+	}
+	if got := lookupEnv([]string{"TRAEFIK_WEB_PORT=9100"}, "TRAEFIK_WEB_PORT", defaultWebPort); got != "9100" {
+		t.Errorf("Expected override, got %s", got)
+	}
+	if got := lookupEnv(nil, "TRAEFIK_API_PORT_UNSET", defaultAPIPort); got != defaultAPIPort {
+		t.Errorf("Expected default, got %s", got)
+	}
+}
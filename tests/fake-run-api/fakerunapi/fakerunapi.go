@@ -0,0 +1,299 @@
+// Package fakerunapi emulates the subset of the Cloud Run Admin API (run/v1)
+// the provider uses, plus the metadata server's identity token endpoint, so
+// the provider can be integration tested without GCP.
+//
+// Served endpoints:
+//
+//	GET /v1/projects/<project>/locations/<region>/services[?continue=&limit=]
+//	GET /v1/projects/<project>/locations/<region>/services/<name>
+//	GET /computeMetadata/v1/instance/service-accounts/default/identity?audience=<url>
+//	GET /computeMetadata/v1/instance/service-accounts/default/token
+//
+// Point the provider's RunAPIEndpoint (CLOUDRUN_API_ENDPOINT) at the server
+// and GCE_METADATA_HOST at its host:port. Listing is paged: each page holds
+// at most the fixture's pageSize services (or the limit parameter), and the
+// continue token is the offset of the next page.
+package fakerunapi
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	run "google.golang.org/api/run/v1"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultPageSize is the page size when the fixture doesn't set one
+const defaultPageSize = 100
+
+// Fixture is the set of services the server lists
+type Fixture struct {
+	// PageSize is the number of services per list page (default 100)
+	PageSize int       `yaml:"pageSize,omitempty"`
+	Services []Service `yaml:"services"`
+}
+
+// Service is one Cloud Run service in a fixture
+type Service struct {
+	Project     string            `yaml:"project"`
+	Region      string            `yaml:"region"`
+	Name        string            `yaml:"name"`
+	URL         string            `yaml:"url,omitempty"` // Default https://<name>-<project>.<region>.run.app
+	Revision    string            `yaml:"revision,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+// LoadFixture reads a YAML fixture file
+func LoadFixture(path string) (*Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixture: %w", err)
+	}
+	var fixture Fixture
+	if err := yaml.Unmarshal(data, &fixture); err != nil {
+		return nil, fmt.Errorf("failed to parse fixture %s: %w", path, err)
+	}
+	return &fixture, nil
+}
+
+// Server is the fake API. It is safe for concurrent use; SetFixture swaps
+// the services between requests.
+type Server struct {
+	mu       sync.Mutex
+	fixture  Fixture
+	requests map[string]int // Requests per endpoint kind: list, get, identity, token
+}
+
+// New creates a server serving fixture
+func New(fixture *Fixture) *Server {
+	s := &Server{requests: make(map[string]int)}
+	s.SetFixture(fixture)
+	return s
+}
+
+// SetFixture replaces the served services
+func (s *Server) SetFixture(fixture *Fixture) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fixture = Fixture{}
+	if fixture != nil {
+		s.fixture = *fixture
+	}
+}
+
+// Requests returns how many requests of a kind ("list", "get", "identity"
+// or "token") were served
+func (s *Server) Requests(kind string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[kind]
+}
+
+// ServeHTTP routes API and metadata requests
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "only GET is supported")
+		return
+	}
+
+	if strings.HasPrefix(r.URL.Path, "/computeMetadata/v1/") {
+		s.serveMetadata(w, r)
+		return
+	}
+
+	// /v1/projects/<project>/locations/<region>/services[/<name>]
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) < 6 || parts[0] != "v1" || parts[1] != "projects" || parts[3] != "locations" || parts[5] != "services" {
+		writeError(w, http.StatusNotFound, "unknown path "+r.URL.Path)
+		return
+	}
+	project, region := parts[2], parts[4]
+	switch len(parts) {
+	case 6:
+		s.list(w, r, project, region)
+	case 7:
+		s.get(w, project, region, parts[6])
+	default:
+		writeError(w, http.StatusNotFound, "unknown path "+r.URL.Path)
+	}
+}
+
+// matching returns the fixture services in project and region
+func (s *Server) matching(project, region string) []Service {
+	var services []Service
+	for _, svc := range s.fixture.Services {
+		if svc.Project == project && svc.Region == region {
+			services = append(services, svc)
+		}
+	}
+	return services
+}
+
+// list serves one page of services
+func (s *Server) list(w http.ResponseWriter, r *http.Request, project, region string) {
+	s.mu.Lock()
+	s.requests["list"]++
+	services := s.matching(project, region)
+	pageSize := s.fixture.PageSize
+	s.mu.Unlock()
+
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid limit "+limit)
+			return
+		}
+		pageSize = n
+	}
+
+	offset := 0
+	if token := r.URL.Query().Get("continue"); token != "" {
+		n, err := strconv.Atoi(token)
+		if err != nil || n < 0 || n > len(services) {
+			writeError(w, http.StatusBadRequest, "invalid continue token "+token)
+			return
+		}
+		offset = n
+	}
+
+	end := offset + pageSize
+	if end > len(services) {
+		end = len(services)
+	}
+	resp := &run.ListServicesResponse{
+		ApiVersion: "serving.knative.dev/v1",
+		Kind:       "ServiceList",
+		Items:      []*run.Service{},
+		Metadata:   &run.ListMeta{},
+	}
+	for _, svc := range services[offset:end] {
+		resp.Items = append(resp.Items, svc.toRun())
+	}
+	if end < len(services) {
+		resp.Metadata.Continue = strconv.Itoa(end)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// get serves a single service
+func (s *Server) get(w http.ResponseWriter, project, region, name string) {
+	s.mu.Lock()
+	s.requests["get"]++
+	services := s.matching(project, region)
+	s.mu.Unlock()
+
+	for _, svc := range services {
+		if svc.Name == name {
+			writeJSON(w, http.StatusOK, svc.toRun())
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, fmt.Sprintf("service %s not found in %s/%s", name, project, region))
+}
+
+// Metadata server paths of the fake identity and access tokens
+const (
+	identityPath    = "/computeMetadata/v1/instance/service-accounts/default/identity"
+	accessTokenPath = "/computeMetadata/v1/instance/service-accounts/default/token"
+)
+
+// AccessToken is the access token the fake metadata server returns
+const AccessToken = "ya29.fake-access-token"
+
+// serveMetadata answers the metadata server probe and token requests with
+// fake tokens: identity tokens are unsigned JWTs carrying the audience
+func (s *Server) serveMetadata(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Metadata-Flavor") != "Google" {
+		http.Error(w, "missing Metadata-Flavor header", http.StatusForbidden)
+		return
+	}
+	w.Header().Set("Metadata-Flavor", "Google")
+
+	switch r.URL.Path {
+	case identityPath:
+		audience := r.URL.Query().Get("audience")
+		if audience == "" {
+			http.Error(w, "audience required", http.StatusBadRequest)
+			return
+		}
+		s.count("identity")
+		fmt.Fprint(w, IdentityToken(audience))
+	case accessTokenPath:
+		s.count("token")
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"access_token": AccessToken,
+			"expires_in":   3599,
+			"token_type":   "Bearer",
+		})
+	default:
+		// The provider's startup probe only needs a Google answer
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// count records a served request of kind
+func (s *Server) count(kind string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests[kind]++
+}
+
+// IdentityToken returns the fake identity token the server mints for
+// audience: an unsigned JWT whose payload carries the audience
+func IdentityToken(audience string) string {
+	encode := base64.RawURLEncoding.EncodeToString
+	payload, _ := json.Marshal(map[string]string{"aud": audience, "iss": "https://accounts.google.com"})
+	return encode([]byte(`{"alg":"none","typ":"JWT"}`)) + "." + encode(payload) + ".fake-signature"
+}
+
+// toRun converts the fixture service to the API representation
+func (svc Service) toRun() *run.Service {
+	url := svc.URL
+	if url == "" {
+		url = fmt.Sprintf("https://%s-%s.%s.run.app", svc.Name, svc.Project, svc.Region)
+	}
+	return &run.Service{
+		ApiVersion: "serving.knative.dev/v1",
+		Kind:       "Service",
+		Metadata: &run.ObjectMeta{
+			Name:        svc.Name,
+			Namespace:   svc.Project,
+			Labels:      svc.Labels,
+			Annotations: svc.Annotations,
+		},
+		Status: &run.ServiceStatus{
+			Url:                     url,
+			Address:                 &run.Addressable{Url: url},
+			LatestReadyRevisionName: svc.Revision,
+			Conditions:              []*run.GoogleCloudRunV1Condition{{Type: "Ready", Status: "True"}},
+		},
+	}
+}
+
+// writeJSON writes v with the given status
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes a Google API error body
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]interface{}{
+		"error": map[string]interface{}{
+			"code":    status,
+			"message": message,
+			"status":  http.StatusText(status),
+		},
+	})
+}
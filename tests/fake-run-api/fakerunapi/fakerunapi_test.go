@@ -0,0 +1,174 @@
+package fakerunapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	run "google.golang.org/api/run/v1"
+)
+
+// newFixture returns n Traefik-enabled services in fake-project/us-central1
+func newFixture(n, pageSize int) *Fixture {
+	fixture := &Fixture{PageSize: pageSize}
+	for i := 0; i < n; i++ {
+		fixture.Services = append(fixture.Services, Service{
+			Project: "fake-project",
+			Region:  "us-central1",
+			Name:    fmt.Sprintf("svc-%d", i),
+			Labels:  map[string]string{"traefik_enable": "true"},
+		})
+	}
+	return fixture
+}
+
+// get requests path from the server and returns the response
+func get(t *testing.T, s *Server, path string, header http.Header) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestServer_ListPaging(t *testing.T) {
+	s := New(newFixture(5, 2))
+	// A service in another region isn't listed
+	s.fixture.Services = append(s.fixture.Services, Service{Project: "fake-project", Region: "europe-west1", Name: "elsewhere"})
+
+	var names []string
+	token := ""
+	for page := 0; ; page++ {
+		if page > 5 {
+			t.Fatal("Paging did not terminate")
+		}
+		path := "/v1/projects/fake-project/locations/us-central1/services"
+		if token != "" {
+			path += "?continue=" + token
+		}
+		rec := get(t, s, path, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("List returned %d: %s", rec.Code, rec.Body)
+		}
+		var resp run.ListServicesResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if len(resp.Items) > 2 {
+			t.Errorf("Page %d has %d items, expected at most 2", page, len(resp.Items))
+		}
+		for _, item := range resp.Items {
+			names = append(names, item.Metadata.Name)
+		}
+		if token = resp.Metadata.Continue; token == "" {
+			break
+		}
+	}
+
+	if got := strings.Join(names, ","); got != "svc-0,svc-1,svc-2,svc-3,svc-4" {
+		t.Errorf("Listed %s", got)
+	}
+	if got := s.Requests("list"); got != 3 {
+		t.Errorf("Expected 3 list requests, got %d", got)
+	}
+}
+
+func TestServer_ListLimitAndErrors(t *testing.T) {
+	s := New(newFixture(3, 0))
+
+	rec := get(t, s, "/v1/projects/fake-project/locations/us-central1/services?limit=1", nil)
+	var resp run.ListServicesResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Items) != 1 || resp.Metadata.Continue != "1" {
+		t.Errorf("Expected one item and continue token 1, got %d items and %q", len(resp.Items), resp.Metadata.Continue)
+	}
+
+	for _, path := range []string{
+		"/v1/projects/fake-project/locations/us-central1/services?continue=bogus",
+		"/v1/projects/fake-project/locations/us-central1/services?continue=9",
+		"/v1/projects/fake-project/locations/us-central1/services?limit=0",
+	} {
+		if rec := get(t, s, path, nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", path, rec.Code)
+		}
+	}
+	if rec := get(t, s, "/v2/projects/fake-project/services", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Unknown path: expected 404, got %d", rec.Code)
+	}
+}
+
+func TestServer_Get(t *testing.T) {
+	fixture := newFixture(1, 0)
+	fixture.Services[0].Revision = "svc-0-00001-abc"
+	s := New(fixture)
+
+	rec := get(t, s, "/v1/projects/fake-project/locations/us-central1/services/svc-0", nil)
+	var svc run.Service
+	if err := json.Unmarshal(rec.Body.Bytes(), &svc); err != nil {
+		t.Fatal(err)
+	}
+	if svc.Status.Url != "https://svc-0-fake-project.us-central1.run.app" || svc.Status.LatestReadyRevisionName != "svc-0-00001-abc" {
+		t.Errorf("Unexpected service status: %+v", svc.Status)
+	}
+
+	rec = get(t, s, "/v1/projects/fake-project/locations/us-central1/services/missing", nil)
+	if rec.Code != http.StatusNotFound || !strings.Contains(rec.Body.String(), `"code":404`) {
+		t.Errorf("Expected a Google API 404 error, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestServer_Metadata(t *testing.T) {
+	s := New(nil)
+	flavor := http.Header{"Metadata-Flavor": {"Google"}}
+
+	if rec := get(t, s, "/computeMetadata/v1/", nil); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected requests without Metadata-Flavor to be rejected, got %d", rec.Code)
+	}
+	if rec := get(t, s, "/computeMetadata/v1/", flavor); rec.Code != http.StatusOK || rec.Header().Get("Metadata-Flavor") != "Google" {
+		t.Errorf("Probe: got %d, Metadata-Flavor %q", rec.Code, rec.Header().Get("Metadata-Flavor"))
+	}
+
+	rec := get(t, s, identityPath+"?audience=https%3A%2F%2Fsvc.run.app", flavor)
+	if rec.Body.String() != IdentityToken("https://svc.run.app") || !strings.HasPrefix(rec.Body.String(), "eyJ") {
+		t.Errorf("Unexpected identity token %q", rec.Body)
+	}
+	if rec := get(t, s, identityPath, flavor); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected missing audience to be rejected, got %d", rec.Code)
+	}
+
+	rec = get(t, s, accessTokenPath, flavor)
+	if !strings.Contains(rec.Body.String(), AccessToken) {
+		t.Errorf("Unexpected access token response %q", rec.Body)
+	}
+	if s.Requests("identity") != 1 || s.Requests("token") != 1 {
+		t.Errorf("Expected one identity and one token request, got %d and %d", s.Requests("identity"), s.Requests("token"))
+	}
+}
+
+func TestLoadFixture(t *testing.T) {
+	fixture, err := LoadFixture("../fixture.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fixture.PageSize != 2 || len(fixture.Services) != 4 {
+		t.Errorf("Unexpected fixture: page size %d, %d services", fixture.PageSize, len(fixture.Services))
+	}
+
+	path := filepath.Join(t.TempDir(), "fixture.yml")
+	if err := os.WriteFile(path, []byte("services: [unclosed"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadFixture(path); err == nil {
+		t.Error("Expected invalid YAML to fail")
+	}
+}
@@ -0,0 +1,80 @@
+package fakerunapi_test
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/provider"
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/tests/fake-run-api/fakerunapi"
+)
+
+// TestProvider runs the real provider, Cloud Run API client and token
+// manager against the fake API and metadata server
+func TestProvider(t *testing.T) {
+	fixture, err := fakerunapi.LoadFixture("../fixture.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := fakerunapi.New(fixture)
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	t.Setenv("GCE_METADATA_HOST", strings.TrimPrefix(server.URL, "http://"))
+	t.Setenv("METADATA_RETRIES", "0")
+
+	p, err := provider.New(&provider.Config{
+		ProjectIDs:          []string{"fake-project"},
+		Region:              "us-central1",
+		PollInterval:        time.Minute,
+		RunAPIEndpoint:      server.URL + "/",
+		AccessTokenServices: []string{"admin"},
+		LogLevel:            "error",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	configChan := make(chan *provider.DynamicConfig, 1)
+	if err := p.RunOnce(configChan); err != nil {
+		t.Fatal(err)
+	}
+	config := <-configChan
+
+	// The fixture's four services span two pages; three are enabled
+	if got := fake.Requests("list"); got != 2 {
+		t.Errorf("Expected 2 list pages, got %d", got)
+	}
+	for name, rule := range map[string]string{
+		"frontend": "Host(`app.localhost`)",
+		"backend":  "Host(`api.localhost`)",
+		"admin":    "Host(`admin.localhost`)",
+	} {
+		router, ok := config.HTTP.Routers[name]
+		if !ok {
+			t.Errorf("Router %s not generated", name)
+			continue
+		}
+		if router.Rule != rule {
+			t.Errorf("Router %s rule = %q, expected %q", name, router.Rule, rule)
+		}
+	}
+	if _, ok := config.HTTP.Routers["internal-worker"]; ok {
+		t.Error("Disabled service was routed")
+	}
+	if router := config.HTTP.Routers["backend"]; router.Priority != 200 {
+		t.Errorf("Expected backend priority 200, got %d", router.Priority)
+	}
+
+	// Identity tokens come from the fake metadata server, access tokens too
+	backendAuth := config.HTTP.Middlewares["backend-auth"].Headers
+	want := "Bearer " + fakerunapi.IdentityToken("https://backend-fake-project.us-central1.run.app")
+	if backendAuth == nil || backendAuth.CustomRequestHeaders["X-Serverless-Authorization"] != want {
+		t.Errorf("Unexpected backend auth middleware: %+v", backendAuth)
+	}
+	adminAuth := config.HTTP.Middlewares["admin-auth"].Headers
+	if adminAuth == nil || adminAuth.CustomRequestHeaders["Authorization"] != "Bearer "+fakerunapi.AccessToken {
+		t.Errorf("Unexpected admin auth middleware: %+v", adminAuth)
+	}
+}
@@ -0,0 +1,40 @@
+// Command fake-run-api serves an emulated Cloud Run Admin API and metadata
+// server from a fixture file, so the provider can run against it without GCP:
+//
+//	go run ./tests/fake-run-api -fixture tests/fake-run-api/fixture.yml -addr :8085
+//	CLOUDRUN_API_ENDPOINT=http://localhost:8085/ GCE_METADATA_HOST=localhost:8085 \
+//	  LABS_PROJECT_ID=fake-project REGION=us-central1 ./bin/traefik-cloudrun-provider /tmp/routes.yml
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/pci-tamper-protect/traefik-cloudrun-provider/tests/fake-run-api/fakerunapi"
+)
+
+func main() {
+	addr := flag.String("addr", ":"+envOr("PORT", "8085"), "Listen address")
+	fixturePath := flag.String("fixture", envOr("FIXTURE", "tests/fake-run-api/fixture.yml"), "Service fixture file")
+	flag.Parse()
+
+	fixture, err := fakerunapi.LoadFixture(*fixturePath)
+	if err != nil {
+		log.Fatalf("Failed to load fixture: %v", err)
+	}
+
+	log.Printf("Serving %d fake Cloud Run services on %s", len(fixture.Services), *addr)
+	if err := http.ListenAndServe(*addr, fakerunapi.New(fixture)); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// envOr returns the environment variable key, or def when unset
+func envOr(key, def string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return def
+}